@@ -38,6 +38,22 @@ type Config struct {
 	BackupEnabled   bool
 	BackupHour      int // local hour 0-23 to run the daily backup
 	BackupRetention int // number of automatic backups to keep
+
+	// Spot price files dropped into SpotPriceDir are imported automatically
+	// (empty disables the watcher).
+	SpotPriceDir         string
+	SpotPricePollMinutes int
+
+	// Daily dunning run: overdue invoices are escalated at this local hour.
+	DunningHour int
+
+	// PDFRenderer selects how invoice PDFs are produced: "native" (in-process),
+	// "external" (wkhtmltopdf/chromium) or "auto" (external when installed).
+	PDFRenderer string
+
+	// ArchiveDir holds the tamper-evident copies of issued invoices. Defaults
+	// to an "archive" directory next to the database.
+	ArchiveDir string
 }
 
 func Load() *Config {
@@ -60,6 +76,15 @@ func Load() *Config {
 		BackupEnabled:   getEnvBool("BACKUP_ENABLED", true),
 		BackupHour:      getEnvInt("BACKUP_HOUR", 3),
 		BackupRetention: getEnvInt("BACKUP_RETENTION", 14),
+
+		SpotPriceDir:         getEnv("SPOT_PRICE_DIR", ""),
+		SpotPricePollMinutes: getEnvInt("SPOT_PRICE_POLL_MINUTES", 15),
+
+		DunningHour: getEnvInt("DUNNING_HOUR", 8),
+
+		PDFRenderer: getEnv("PDF_RENDERER", "auto"),
+
+		ArchiveDir: getEnv("ARCHIVE_DIR", filepath.Join(filepath.Dir(dbPath), "archive")),
 	}
 }
 
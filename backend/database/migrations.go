@@ -577,6 +577,456 @@ func RunMigrations(db *sql.DB) error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+
+		// Time-of-use (HT/NT) grid tariff windows per building. weekday_mask:
+		// bit 0 = Monday … bit 6 = Sunday; end_time exclusive, wraps past midnight.
+		`CREATE TABLE IF NOT EXISTS tariff_windows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			weekday_mask INTEGER NOT NULL DEFAULT 127,
+			start_time TEXT NOT NULL DEFAULT '00:00',
+			end_time TEXT NOT NULL DEFAULT '00:00',
+			applies_on_holidays INTEGER NOT NULL DEFAULT 0,
+			price REAL NOT NULL DEFAULT 0,
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			is_active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS tariff_holidays (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL,
+			holiday_date TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE,
+			UNIQUE(building_id, holiday_date)
+		)`,
+
+		// Dynamic (day-ahead) grid prices per price area, one row per 15-minute
+		// interval. interval_start is UTC "YYYY-MM-DD HH:MM:SS"; price is per kWh.
+		`CREATE TABLE IF NOT EXISTS spot_prices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			price_area TEXT NOT NULL,
+			interval_start TEXT NOT NULL,
+			price REAL NOT NULL,
+			source TEXT NOT NULL DEFAULT 'upload',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(price_area, interval_start)
+		)`,
+
+		// Advance payment (Akonto) plans: a fixed monthly amount a tenant is
+		// invoiced in advance and that the annual settlement is netted against.
+		// valid_to is inclusive; NULL = open-ended.
+		`CREATE TABLE IF NOT EXISTS advance_plans (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			building_id INTEGER NOT NULL,
+			monthly_amount REAL NOT NULL,
+			currency TEXT NOT NULL DEFAULT 'CHF',
+			valid_from DATE NOT NULL,
+			valid_to DATE,
+			is_active INTEGER NOT NULL DEFAULT 1,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// Per-building payment terms and dunning (Mahnwesen) schedule. Reminder
+		// intervals count days past the due date (level 1) or past the previous
+		// reminder (levels 2 and 3). Buildings without a row use the defaults.
+		`CREATE TABLE IF NOT EXISTS dunning_settings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL UNIQUE,
+			payment_terms_days INTEGER NOT NULL DEFAULT 30,
+			reminder1_after_days INTEGER NOT NULL DEFAULT 10,
+			reminder2_after_days INTEGER NOT NULL DEFAULT 14,
+			final_notice_after_days INTEGER NOT NULL DEFAULT 14,
+			reminder_deadline_days INTEGER NOT NULL DEFAULT 10,
+			reminder1_fee REAL NOT NULL DEFAULT 0,
+			reminder2_fee REAL NOT NULL DEFAULT 0,
+			final_notice_fee REAL NOT NULL DEFAULT 0,
+			auto_escalate INTEGER NOT NULL DEFAULT 0,
+			auto_send_email INTEGER NOT NULL DEFAULT 0,
+			sender_name TEXT NOT NULL DEFAULT '',
+			sender_address TEXT NOT NULL DEFAULT '',
+			sender_city TEXT NOT NULL DEFAULT '',
+			sender_zip TEXT NOT NULL DEFAULT '',
+			sender_country TEXT NOT NULL DEFAULT '',
+			bank_name TEXT NOT NULL DEFAULT '',
+			bank_iban TEXT NOT NULL DEFAULT '',
+			bank_account_holder TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// One row per reminder sent for an invoice (level 1 = reminder,
+		// 2 = second reminder, 3 = final notice). total_due includes the fees
+		// of this and all earlier levels.
+		`CREATE TABLE IF NOT EXISTS invoice_reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			invoice_id INTEGER NOT NULL,
+			level INTEGER NOT NULL,
+			reminder_number TEXT NOT NULL UNIQUE,
+			outstanding_amount REAL NOT NULL,
+			fee REAL NOT NULL DEFAULT 0,
+			total_due REAL NOT NULL,
+			deadline DATE NOT NULL,
+			pdf_path TEXT NOT NULL DEFAULT '',
+			emailed_at DATETIME,
+			email_error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+			UNIQUE(invoice_id, level)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS bank_imports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			file_name TEXT NOT NULL DEFAULT '',
+			message_id TEXT NOT NULL DEFAULT '',
+			format TEXT NOT NULL,
+			account_iban TEXT NOT NULL DEFAULT '',
+			transactions INTEGER NOT NULL DEFAULT 0,
+			duplicates INTEGER NOT NULL DEFAULT 0,
+			matched INTEGER NOT NULL DEFAULT 0,
+			unmatched INTEGER NOT NULL DEFAULT 0,
+			imported_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS bank_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			import_id INTEGER NOT NULL,
+			dedupe_key TEXT NOT NULL UNIQUE,
+			booking_date DATE,
+			value_date DATE,
+			amount REAL NOT NULL,
+			currency TEXT NOT NULL DEFAULT 'CHF',
+			credit_debit TEXT NOT NULL,
+			reference TEXT NOT NULL DEFAULT '',
+			reference_type TEXT NOT NULL DEFAULT '',
+			end_to_end_id TEXT NOT NULL DEFAULT '',
+			debtor_name TEXT NOT NULL DEFAULT '',
+			debtor_iban TEXT NOT NULL DEFAULT '',
+			remittance_info TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'unmatched',
+			invoice_id INTEGER,
+			match_method TEXT NOT NULL DEFAULT '',
+			review_note TEXT NOT NULL DEFAULT '',
+			matched_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (import_id) REFERENCES bank_imports(id) ON DELETE CASCADE,
+			FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE SET NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS direct_debit_mandates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			mandate_id TEXT NOT NULL UNIQUE,
+			scheme TEXT NOT NULL DEFAULT 'lsv',
+			signature_date DATE NOT NULL,
+			status TEXT NOT NULL DEFAULT 'active',
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS direct_debit_exports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id TEXT NOT NULL UNIQUE,
+			scheme TEXT NOT NULL,
+			collection_date DATE NOT NULL,
+			creditor_name TEXT NOT NULL,
+			creditor_iban TEXT NOT NULL,
+			creditor_id TEXT NOT NULL,
+			currency TEXT NOT NULL,
+			transactions INTEGER NOT NULL DEFAULT 0,
+			total_amount REAL NOT NULL DEFAULT 0,
+			xml TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS invoice_number_schemes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL DEFAULT '',
+			pattern TEXT NOT NULL DEFAULT '{PREFIX}{YYYY}-{COUNTER}',
+			counter_digits INTEGER NOT NULL DEFAULT 5,
+			reset_policy TEXT NOT NULL DEFAULT 'yearly',
+			per_building INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS invoice_number_scheme_buildings (
+			building_id INTEGER PRIMARY KEY,
+			scheme_id INTEGER NOT NULL,
+			building_code TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (building_id) REFERENCES buildings(id),
+			FOREIGN KEY (scheme_id) REFERENCES invoice_number_schemes(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS invoice_number_counters (
+			scheme_id INTEGER NOT NULL,
+			building_id INTEGER NOT NULL DEFAULT 0,
+			period TEXT NOT NULL DEFAULT '',
+			last_value INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (scheme_id, building_id, period),
+			FOREIGN KEY (scheme_id) REFERENCES invoice_number_schemes(id)
+		)`,
+
+		// Grid price components per building (energy, grid usage, levies, fees,
+		// metering), each priced per kWh with its own validity and VAT treatment.
+		// Dates are "YYYY-MM-DD"; an empty valid_to is open-ended.
+		`CREATE TABLE IF NOT EXISTS price_components (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			component_type TEXT NOT NULL DEFAULT 'other',
+			price REAL NOT NULL DEFAULT 0,
+			vat_exempt INTEGER NOT NULL DEFAULT 0,
+			valid_from TEXT NOT NULL,
+			valid_to TEXT NOT NULL DEFAULT '',
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			is_active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// Peak-demand (kW) tariffs: set on a user (all their apartment meters)
+		// or on one meter. price_per_kw is per kW and month; peak_count > 1
+		// bills the mean of the highest 15-minute intervals.
+		`CREATE TABLE IF NOT EXISTS demand_tariffs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL,
+			user_id INTEGER,
+			meter_id INTEGER,
+			name TEXT NOT NULL DEFAULT '',
+			price_per_kw REAL NOT NULL DEFAULT 0,
+			peak_count INTEGER NOT NULL DEFAULT 1,
+			valid_from TEXT NOT NULL,
+			valid_to TEXT NOT NULL DEFAULT '',
+			is_active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (meter_id) REFERENCES meters(id) ON DELETE CASCADE
+		)`,
+
+		// LEG (Lokale Elektrizitätsgemeinschaft): a community of consumers and
+		// producers across buildings that share local production over the
+		// public grid. Local kWh are allocated per 15-minute interval; the grid
+		// usage fee on them is reduced by grid_fee_discount percent.
+		`CREATE TABLE IF NOT EXISTS leg_communities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			dso_name TEXT NOT NULL DEFAULT '',
+			community_code TEXT NOT NULL DEFAULT '',
+			local_energy_price REAL NOT NULL DEFAULT 0,
+			producer_price REAL NOT NULL DEFAULT 0,
+			grid_fee_price REAL NOT NULL DEFAULT 0,
+			grid_fee_discount REAL NOT NULL DEFAULT 40,
+			allocation_method TEXT NOT NULL DEFAULT 'proportional',
+			is_active INTEGER NOT NULL DEFAULT 1,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Metering points of a LEG: a consumer's apartment meter or a producer's
+		// solar meter, with the user billed or credited for it.
+		`CREATE TABLE IF NOT EXISTS leg_participants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			community_id INTEGER NOT NULL,
+			building_id INTEGER NOT NULL,
+			meter_id INTEGER NOT NULL,
+			user_id INTEGER,
+			role TEXT NOT NULL DEFAULT 'consumer',
+			valid_from TEXT NOT NULL,
+			valid_to TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (community_id) REFERENCES leg_communities(id) ON DELETE CASCADE,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE,
+			FOREIGN KEY (meter_id) REFERENCES meters(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+		)`,
+
+		// Producer accounts: the owner or investor of PV systems who is paid for
+		// their production by credit statements. Prices left NULL follow the
+		// building's solar and export prices.
+		`CREATE TABLE IF NOT EXISTS producer_accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			building_id INTEGER NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			self_consumption_price REAL,
+			export_price REAL,
+			vat_rate REAL NOT NULL DEFAULT 0,
+			is_active INTEGER NOT NULL DEFAULT 1,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// Solar meters of a producer account; share_percent is the account's
+		// ownership share when several investors own one system.
+		`CREATE TABLE IF NOT EXISTS producer_meters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			producer_account_id INTEGER NOT NULL,
+			meter_id INTEGER NOT NULL,
+			share_percent REAL NOT NULL DEFAULT 100,
+			valid_from TEXT NOT NULL,
+			valid_to TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (producer_account_id) REFERENCES producer_accounts(id) ON DELETE CASCADE,
+			FOREIGN KEY (meter_id) REFERENCES meters(id) ON DELETE CASCADE
+		)`,
+
+		// Water, heat and gas prices per building. price is per unit of the
+		// utility's meters (m³, kWh, MWh, ...); base_fee is charged per month
+		// and meter.
+		`CREATE TABLE IF NOT EXISTS utility_prices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL,
+			utility TEXT NOT NULL,
+			unit TEXT NOT NULL DEFAULT '',
+			price REAL NOT NULL DEFAULT 0,
+			base_fee REAL NOT NULL DEFAULT 0,
+			valid_from TEXT NOT NULL,
+			valid_to TEXT NOT NULL DEFAULT '',
+			is_active INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// Recalculations of issued invoices after late or corrected data.
+		// The billed_* columns hold the invoice plus any earlier corrections,
+		// new_* the result of billing the period again; lines is the JSON
+		// per-line diff. status: pending, corrected or dismissed.
+		`CREATE TABLE IF NOT EXISTS invoice_recalculations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			invoice_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			billed_total REAL NOT NULL DEFAULT 0,
+			billed_net REAL NOT NULL DEFAULT 0,
+			billed_vat REAL NOT NULL DEFAULT 0,
+			new_total REAL NOT NULL DEFAULT 0,
+			new_net REAL NOT NULL DEFAULT 0,
+			new_vat REAL NOT NULL DEFAULT 0,
+			difference REAL NOT NULL DEFAULT 0,
+			lines TEXT NOT NULL DEFAULT '[]',
+			error TEXT NOT NULL DEFAULT '',
+			correction_invoice_id INTEGER,
+			note TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			resolved_at DATETIME,
+			FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+			FOREIGN KEY (correction_invoice_id) REFERENCES invoices(id) ON DELETE SET NULL
+		)`,
+
+		// Payment ledger: one row per payment booked on a document. source:
+		// manual, camt, direct_debit, credit_note (offset by a credit note) or
+		// credit_balance (an earlier overpayment used up). invoices.paid_amount,
+		// paid_at and payment_status are derived from these rows.
+		`CREATE TABLE IF NOT EXISTS payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			invoice_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			payment_date TEXT NOT NULL,
+			amount REAL NOT NULL,
+			currency TEXT NOT NULL DEFAULT 'CHF',
+			method TEXT NOT NULL DEFAULT 'bank_transfer',
+			reference TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL DEFAULT 'manual',
+			bank_transaction_id INTEGER,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+			FOREIGN KEY (bank_transaction_id) REFERENCES bank_transactions(id) ON DELETE SET NULL
+		)`,
+
+		// VAT codes put on invoice lines. A code without a rate (standard)
+		// uses the building's VAT rate from its billing settings; an exempt
+		// code keeps its lines outside the VAT base.
+		`CREATE TABLE IF NOT EXISTS vat_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL DEFAULT '',
+			rate REAL,
+			exempt INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// The VAT code each kind of invoice line gets: energy, charging,
+		// shared_meter, utility or custom_<category>.
+		`CREATE TABLE IF NOT EXISTS vat_code_assignments (
+			source TEXT PRIMARY KEY,
+			vat_code TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Accounting journal export: the ledger accounts journal entries are
+		// booked to (receivables, revenue per line category, VAT per code,
+		// ...) and the DATEV consultant and client numbers.
+		`CREATE TABLE IF NOT EXISTS accounting_settings (
+			setting_key TEXT PRIMARY KEY,
+			value TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Custom invoice templates (html/template source) per building;
+		// buildings without one use the built-in template.
+		`CREATE TABLE IF NOT EXISTS invoice_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			building_id INTEGER NOT NULL UNIQUE,
+			name TEXT DEFAULT '',
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (building_id) REFERENCES buildings(id) ON DELETE CASCADE
+		)`,
+
+		// Tamper-evident invoice archive: one row per archived document with
+		// the SHA-256 of its PDF/A-3B file and a hash chaining it to the row
+		// before. archived_at is TEXT because it is part of the chain hash
+		// and must read back exactly as written. Archived invoices cannot be
+		// deleted (no ON DELETE action) and the rows themselves are
+		// append-only (see createTriggers).
+		`CREATE TABLE IF NOT EXISTS invoice_archive (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			invoice_id INTEGER NOT NULL UNIQUE,
+			invoice_number TEXT NOT NULL,
+			file_name TEXT NOT NULL,
+			format TEXT NOT NULL,
+			sha256 TEXT NOT NULL,
+			previous_hash TEXT NOT NULL DEFAULT '',
+			chain_hash TEXT NOT NULL,
+			archived_at TEXT NOT NULL,
+			FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+		)`,
+
+		// Head of the invoice archive chain: the entry count and the chain
+		// hash of the last entry, updated in the same transaction as every
+		// insert. Removing entries from the end of the chain leaves the chain
+		// itself intact; this row is what gives it away.
+		`CREATE TABLE IF NOT EXISTS invoice_archive_head (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			entry_count INTEGER NOT NULL,
+			head_hash TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
 	}
 
 	for _, migration := range migrations {
@@ -599,6 +1049,20 @@ func RunMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_invoices_user ON invoices(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_invoices_building ON invoices(building_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_health_history_timestamp ON health_history(timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_tariff_windows_building ON tariff_windows(building_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_advance_plans_user ON advance_plans(user_id, building_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_invoice_reminders_invoice ON invoice_reminders(invoice_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_bank_transactions_status ON bank_transactions(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_bank_transactions_invoice ON bank_transactions(invoice_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_direct_debit_mandates_user ON direct_debit_mandates(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_price_components_building ON price_components(building_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_demand_tariffs_building ON demand_tariffs(building_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_leg_participants_community ON leg_participants(community_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_producer_meters_account ON producer_meters(producer_account_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_utility_prices_building ON utility_prices(building_id, utility)`,
+		`CREATE INDEX IF NOT EXISTS idx_invoice_recalculations_invoice ON invoice_recalculations(invoice_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_invoice ON payments(invoice_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_user ON payments(user_id, currency)`,
 	}
 
 	for _, index := range indexes {
@@ -703,6 +1167,83 @@ func RunMigrations(db *sql.DB) error {
 	if err := runVersioned(db, "0021_solar_split_mode", addSolarSplitModeColumn); err != nil {
 		return err
 	}
+	// Cancellation (Storno): credit notes and corrected replacement invoices are
+	// linked back to the invoice they reverse/replace instead of deleting it.
+	if err := runVersioned(db, "0022_invoice_cancellation", addInvoiceCancellationColumns); err != nil {
+		return err
+	}
+	// Opt-in per pricing row: price the grid share by the building's HT/NT windows.
+	if err := runVersioned(db, "0023_tariff_windows_enabled", addTariffWindowsEnabledColumn); err != nil {
+		return err
+	}
+	// Opt-in per pricing row: price the grid share by imported spot prices.
+	if err := runVersioned(db, "0024_spot_pricing", addSpotPricingColumns); err != nil {
+		return err
+	}
+	// Auto-billing configs can issue advance (Akonto) invoices instead of
+	// consumption invoices.
+	if err := runVersioned(db, "0025_auto_billing_invoice_type", addAutoBillingInvoiceTypeColumn); err != nil {
+		return err
+	}
+	// Due dates and the current reminder level for dunning.
+	if err := runVersioned(db, "0026_invoice_dunning", addInvoiceDunningColumns); err != nil {
+		return err
+	}
+	// Link from an invoice to the direct-debit file that collects it.
+	if err := runVersioned(db, "0027_direct_debit", addDirectDebitColumn); err != nil {
+		return err
+	}
+	// Configurable gap-free number sequences: the scheme an invoice number
+	// was drawn from, and the scheme an auto-billing config uses.
+	if err := runVersioned(db, "0028_invoice_number_schemes", addInvoiceNumberSchemeColumns); err != nil {
+		return err
+	}
+	// Producer credit statements: the producer account a statement pays.
+	if err := runVersioned(db, "0029_invoice_producer_account", addInvoiceProducerAccountColumn); err != nil {
+		return err
+	}
+	// Water, heat and gas: meter reading factors and consumption-based
+	// allocation of shared meters.
+	if err := runVersioned(db, "0030_utility_meters", addUtilityMeterColumns); err != nil {
+		return err
+	}
+	// The billing scope an invoice was generated with, so a recalculation
+	// can bill its period again the same way.
+	if err := runVersioned(db, "0031_invoice_billing_scope", addInvoiceBillingScopeColumns); err != nil {
+		return err
+	}
+	// Approval workflow: auto-billing configs can hold their invoices as
+	// drafts in a review queue until an admin approves them.
+	if err := runVersioned(db, "0032_invoice_approval", addInvoiceApprovalColumns); err != nil {
+		return err
+	}
+	// Payment ledger: book the aggregated paid_amount of existing invoices
+	// as payment rows.
+	if err := runVersioned(db, "0033_payment_ledger", backfillPayments); err != nil {
+		return err
+	}
+	// Per-line VAT: the VAT code and rate of every invoice line, a VAT code
+	// override on custom items, and the default codes.
+	if err := runVersioned(db, "0034_vat_codes", addVATCodes); err != nil {
+		return err
+	}
+	// Default ledger accounts of the accounting journal export.
+	if err := runVersioned(db, "0035_accounting_accounts", seedAccountingAccounts); err != nil {
+		return err
+	}
+	// Bill layouts can add a consumption history chart to the invoice.
+	if err := runVersioned(db, "0036_bill_layout_consumption_chart", addBillLayoutConsumptionChartColumn); err != nil {
+		return err
+	}
+	// Credit notes are numbered from the scheme too, with their own prefix
+	// and counter.
+	if err := runVersioned(db, "0037_credit_note_numbers", addCreditNotePrefixColumn); err != nil {
+		return err
+	}
+	// Start of the fiscal year in the DATEV export header.
+	if err := runVersioned(db, "0038_datev_fiscal_year_start", seedDATEVFiscalYearStart); err != nil {
+		return err
+	}
 
 	// One-time cleanup of historical per-interval consumption spikes left by
 	// meters added with a large existing counter (before the spike cap existed).
@@ -1047,6 +1588,453 @@ func addInvoicePaymentColumns(db *sql.DB) error {
 	return nil
 }
 
+// addTariffWindowsEnabledColumn adds billing_settings.tariff_windows_enabled,
+// which switches a pricing row's grid tariff to the time-of-use windows.
+func addTariffWindowsEnabledColumn(db *sql.DB) error {
+	var ddl string
+	if err := db.QueryRow(
+		`SELECT sql FROM sqlite_master WHERE type='table' AND name='billing_settings'`,
+	).Scan(&ddl); err != nil {
+		return err
+	}
+	if contains(ddl, "tariff_windows_enabled") {
+		log.Println("✓ tariff_windows_enabled column already exists")
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE billing_settings ADD COLUMN tariff_windows_enabled INTEGER NOT NULL DEFAULT 0`); err != nil {
+		if contains(err.Error(), "duplicate column") {
+			log.Println("✓ tariff_windows_enabled column already exists")
+			return nil
+		}
+		return fmt.Errorf("failed to add tariff_windows_enabled column: %v", err)
+	}
+	log.Println("✓ tariff_windows_enabled column added successfully")
+	return nil
+}
+
+// addSpotPricingColumns adds the billing_settings columns that switch a pricing
+// row's grid tariff to imported per-interval spot prices.
+func addSpotPricingColumns(db *sql.DB) error {
+	cols := []struct{ name, ddl string }{
+		{"grid_pricing_mode", "ALTER TABLE billing_settings ADD COLUMN grid_pricing_mode TEXT NOT NULL DEFAULT 'fixed'"},
+		{"spot_price_area", "ALTER TABLE billing_settings ADD COLUMN spot_price_area TEXT NOT NULL DEFAULT ''"},
+		{"spot_price_markup", "ALTER TABLE billing_settings ADD COLUMN spot_price_markup REAL NOT NULL DEFAULT 0"},
+	}
+	var ddl string
+	if err := db.QueryRow(
+		`SELECT sql FROM sqlite_master WHERE type='table' AND name='billing_settings'`,
+	).Scan(&ddl); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if contains(ddl, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add billing_settings.%s: %v", c.name, err)
+			}
+		}
+		log.Printf("✓ billing_settings.%s column added", c.name)
+	}
+	return nil
+}
+
+// addAutoBillingInvoiceTypeColumn adds auto_billing_configs.invoice_type:
+// 'consumption' (default) or 'advance'.
+func addAutoBillingInvoiceTypeColumn(db *sql.DB) error {
+	var ddl string
+	if err := db.QueryRow(
+		`SELECT sql FROM sqlite_master WHERE type='table' AND name='auto_billing_configs'`,
+	).Scan(&ddl); err != nil {
+		return err
+	}
+	if contains(ddl, "invoice_type") {
+		log.Println("✓ auto_billing_configs.invoice_type column already exists")
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE auto_billing_configs ADD COLUMN invoice_type TEXT NOT NULL DEFAULT 'consumption'`); err != nil {
+		if contains(err.Error(), "duplicate column") {
+			log.Println("✓ auto_billing_configs.invoice_type column already exists")
+			return nil
+		}
+		return fmt.Errorf("failed to add invoice_type column: %v", err)
+	}
+	log.Println("✓ auto_billing_configs.invoice_type column added successfully")
+	return nil
+}
+
+// addDirectDebitColumn adds direct_debit_export_id to invoices: the pain.008
+// file an invoice was last submitted for collection in.
+func addDirectDebitColumn(db *sql.DB) error {
+	var invoicesSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoices'`).Scan(&invoicesSQL); err != nil {
+		return err
+	}
+	if contains(invoicesSQL, "direct_debit_export_id") {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE invoices ADD COLUMN direct_debit_export_id INTEGER REFERENCES direct_debit_exports(id)`); err != nil {
+		if !contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to add invoices.direct_debit_export_id: %v", err)
+		}
+	}
+	log.Printf("✓ invoices.direct_debit_export_id column added")
+	return nil
+}
+
+// addInvoiceNumberSchemeColumns adds invoices.number_scheme_id and
+// auto_billing_configs.number_scheme_id. Existing invoices keep their legacy
+// numbers and stay outside any sequence.
+func addInvoiceNumberSchemeColumns(db *sql.DB) error {
+	for _, table := range []string{"invoices", "auto_billing_configs"} {
+		var tableSQL string
+		if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&tableSQL); err != nil {
+			return err
+		}
+		if contains(tableSQL, "number_scheme_id") {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN number_scheme_id INTEGER REFERENCES invoice_number_schemes(id)`); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add %s.number_scheme_id: %v", table, err)
+			}
+		}
+		log.Printf("✓ %s.number_scheme_id column added", table)
+	}
+	return nil
+}
+
+// addInvoiceProducerAccountColumn links a producer credit statement to the
+// producer account it pays.
+func addInvoiceProducerAccountColumn(db *sql.DB) error {
+	var tableSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoices'`).Scan(&tableSQL); err != nil {
+		return err
+	}
+	if contains(tableSQL, "producer_account_id") {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE invoices ADD COLUMN producer_account_id INTEGER REFERENCES producer_accounts(id)`); err != nil {
+		if !contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to add invoices.producer_account_id: %v", err)
+		}
+	}
+	log.Printf("✓ invoices.producer_account_id column added")
+	return nil
+}
+
+// addUtilityMeterColumns adds meters.reading_factor (the factor that turns a
+// meter's raw counter into its billing unit, e.g. 0.001 for a water meter
+// counting litres) and shared_meter_configs.consumption_share (the percentage
+// of a shared meter's cost allocated by the tenants' own consumption of the
+// same utility; the rest follows split_type). It also adds the users'
+// apartment_area and unit_count, which the by_area and by_units splits have
+// always read but no migration created, so those splits fell back to equal.
+func addUtilityMeterColumns(db *sql.DB) error {
+	cols := []struct{ table, name, ddl string }{
+		{"meters", "reading_factor", "ALTER TABLE meters ADD COLUMN reading_factor REAL NOT NULL DEFAULT 1"},
+		{"shared_meter_configs", "consumption_share", "ALTER TABLE shared_meter_configs ADD COLUMN consumption_share REAL NOT NULL DEFAULT 0"},
+		{"users", "apartment_area", "ALTER TABLE users ADD COLUMN apartment_area REAL NOT NULL DEFAULT 0"},
+		{"users", "unit_count", "ALTER TABLE users ADD COLUMN unit_count INTEGER NOT NULL DEFAULT 1"},
+	}
+	for _, c := range cols {
+		var tableSQL string
+		if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, c.table).Scan(&tableSQL); err != nil {
+			return err
+		}
+		if contains(tableSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add %s.%s: %v", c.table, c.name, err)
+			}
+		}
+		log.Printf("✓ %s.%s column added", c.table, c.name)
+	}
+	return nil
+}
+
+// addInvoiceBillingScopeColumns adds the billing mode, bill content, charger
+// and selected custom items (comma-separated IDs) an invoice was generated
+// with. They stay NULL on invoices generated before the columns existed.
+func addInvoiceBillingScopeColumns(db *sql.DB) error {
+	cols := []struct{ name, ddl string }{
+		{"billing_mode", "ALTER TABLE invoices ADD COLUMN billing_mode TEXT"},
+		{"bill_content", "ALTER TABLE invoices ADD COLUMN bill_content TEXT"},
+		{"charger_id", "ALTER TABLE invoices ADD COLUMN charger_id INTEGER"},
+		{"custom_item_ids", "ALTER TABLE invoices ADD COLUMN custom_item_ids TEXT"},
+	}
+	var invoicesSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoices'`).Scan(&invoicesSQL); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if contains(invoicesSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add invoices.%s: %v", c.name, err)
+			}
+		}
+		log.Printf("✓ invoices.%s column added", c.name)
+	}
+	return nil
+}
+
+// addInvoiceApprovalColumns adds auto_billing_configs.require_approval and,
+// on invoices, the review state of a draft (draft, approved or sent), the
+// review warnings (JSON), the auto-billing config that generated the invoice
+// and the approval and dispatch times.
+func addInvoiceApprovalColumns(db *sql.DB) error {
+	cols := []struct{ table, name, ddl string }{
+		{"auto_billing_configs", "require_approval", "ALTER TABLE auto_billing_configs ADD COLUMN require_approval INTEGER NOT NULL DEFAULT 0"},
+		{"invoices", "review_status", "ALTER TABLE invoices ADD COLUMN review_status TEXT"},
+		{"invoices", "review_warnings", "ALTER TABLE invoices ADD COLUMN review_warnings TEXT"},
+		{"invoices", "auto_billing_config_id", "ALTER TABLE invoices ADD COLUMN auto_billing_config_id INTEGER"},
+		{"invoices", "approved_at", "ALTER TABLE invoices ADD COLUMN approved_at DATETIME"},
+		{"invoices", "sent_at", "ALTER TABLE invoices ADD COLUMN sent_at DATETIME"},
+	}
+	for _, c := range cols {
+		var tableSQL string
+		if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, c.table).Scan(&tableSQL); err != nil {
+			return err
+		}
+		if contains(tableSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add %s.%s: %v", c.table, c.name, err)
+			}
+		}
+		log.Printf("✓ %s.%s column added", c.table, c.name)
+	}
+	return nil
+}
+
+func addVATCodes(db *sql.DB) error {
+	cols := []struct{ table, name, ddl string }{
+		{"invoice_items", "vat_code", "ALTER TABLE invoice_items ADD COLUMN vat_code TEXT NOT NULL DEFAULT ''"},
+		{"invoice_items", "vat_rate", "ALTER TABLE invoice_items ADD COLUMN vat_rate REAL NOT NULL DEFAULT 0"},
+		{"custom_line_items", "vat_code", "ALTER TABLE custom_line_items ADD COLUMN vat_code TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range cols {
+		var tableSQL string
+		if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, c.table).Scan(&tableSQL); err != nil {
+			return err
+		}
+		if contains(tableSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add %s.%s: %v", c.table, c.name, err)
+			}
+		}
+		log.Printf("✓ %s.%s column added", c.table, c.name)
+	}
+
+	// Swiss rates: standard follows the billing settings, 2.6% reduced
+	// (e.g. water), exempt for pass-through fees. Every source starts on
+	// standard, which keeps existing invoices' VAT unchanged.
+	if _, err := db.Exec(`
+		INSERT OR IGNORE INTO vat_codes (code, name, rate, exempt) VALUES
+			('standard', 'Standard rate', NULL, 0),
+			('reduced', 'Reduced rate', 2.6, 0),
+			('exempt', 'Exempt', 0, 1)
+	`); err != nil {
+		return fmt.Errorf("failed to seed VAT codes: %v", err)
+	}
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO vat_code_assignments (source, vat_code) VALUES
+			('energy', 'standard'), ('charging', 'standard'), ('shared_meter', 'standard'), ('utility', 'standard'),
+			('custom_meter_rent', 'standard'), ('custom_maintenance', 'standard'),
+			('custom_service', 'standard'), ('custom_other', 'standard')
+	`)
+	return err
+}
+
+// seedAccountingAccounts fills in the accounts of the Swiss SME chart of
+// accounts (KMU-Kontenrahmen); they are meant to be adjusted to the client's
+// chart.
+func seedAccountingAccounts(db *sql.DB) error {
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO accounting_settings (setting_key, value) VALUES
+			('receivables', '1100'), ('bank', '1020'), ('input_vat', '1170'),
+			('payables', '2000'), ('advances', '2030'),
+			('vat_standard', '2200'), ('vat_reduced', '2200'),
+			('revenue_normal_power', '3200'), ('revenue_solar_power', '3200'), ('revenue_battery_power', '3200'),
+			('revenue_car_charging', '3200'), ('revenue_shared_meter', '3200'), ('revenue_utility', '3200'),
+			('revenue_custom', '3400'), ('revenue_other', '3200'),
+			('reminder_fees', '3600'), ('energy_purchase', '4000'),
+			('datev_consultant_number', ''), ('datev_client_number', '')
+	`)
+	return err
+}
+
+// addBillLayoutConsumptionChartColumn adds bill_layouts.show_consumption_chart,
+// which puts the tenant's monthly consumption history on the invoice.
+func addBillLayoutConsumptionChartColumn(db *sql.DB) error {
+	var tableSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='bill_layouts'`).Scan(&tableSQL); err != nil {
+		return err
+	}
+	if contains(tableSQL, "show_consumption_chart") {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE bill_layouts ADD COLUMN show_consumption_chart INTEGER NOT NULL DEFAULT 0`); err != nil {
+		if !contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to add bill_layouts.show_consumption_chart: %v", err)
+		}
+	}
+	log.Printf("✓ bill_layouts.show_consumption_chart column added")
+	return nil
+}
+
+// seedDATEVFiscalYearStart adds the fiscal year start (MMDD) of the DATEV
+// export, the calendar year by default.
+func seedDATEVFiscalYearStart(db *sql.DB) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO accounting_settings (setting_key, value) VALUES ('datev_fiscal_year_start', '0101')`)
+	return err
+}
+
+// addCreditNotePrefixColumn gives number schemes the prefix of their credit
+// note series.
+func addCreditNotePrefixColumn(db *sql.DB) error {
+	var tableSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoice_number_schemes'`).Scan(&tableSQL); err != nil {
+		return err
+	}
+	if contains(tableSQL, "credit_note_prefix") {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE invoice_number_schemes ADD COLUMN credit_note_prefix TEXT NOT NULL DEFAULT 'CN-'`); err != nil {
+		if !contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to add invoice_number_schemes.credit_note_prefix: %v", err)
+		}
+	}
+	log.Printf("✓ invoice_number_schemes.credit_note_prefix column added")
+	return nil
+}
+
+// backfillPayments turns the single paid_amount of existing documents into
+// payment rows: matched and assigned bank transactions become camt payments,
+// any remainder one payment on paid_at (from a direct debit collection or
+// entered by hand). Every credit note is booked as an offset on the invoice
+// it reverses or corrects, so money paid on a cancelled invoice shows up as
+// the tenant's credit balance.
+func backfillPayments(db *sql.DB) error {
+	steps := []string{
+		`INSERT INTO payments (invoice_id, user_id, payment_date, amount, currency, method, reference, source, bank_transaction_id)
+		 SELECT t.invoice_id, i.user_id, COALESCE(date(t.booking_date), date(t.matched_at), date('now')), t.amount, i.currency,
+		        CASE WHEN t.match_method = 'direct_debit' THEN 'direct_debit' ELSE 'bank_transfer' END,
+		        CASE WHEN t.reference != '' THEN t.reference ELSE t.end_to_end_id END, 'camt', t.id
+		 FROM bank_transactions t
+		 JOIN invoices i ON i.id = t.invoice_id
+		 WHERE t.status IN ('matched', 'assigned')`,
+		`INSERT INTO payments (invoice_id, user_id, payment_date, amount, currency, method, source, notes)
+		 SELECT i.id, i.user_id, COALESCE(date(i.paid_at), date(i.generated_at)),
+		        ROUND(i.paid_amount - COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = i.id), 0), 2),
+		        i.currency,
+		        CASE WHEN i.direct_debit_export_id IS NOT NULL THEN 'direct_debit' ELSE 'bank_transfer' END,
+		        CASE WHEN i.direct_debit_export_id IS NOT NULL THEN 'direct_debit' ELSE 'manual' END,
+		        'Carried over from paid_amount'
+		 FROM invoices i
+		 WHERE COALESCE(i.paid_amount, 0) - COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = i.id), 0) > 0.005`,
+		`INSERT INTO payments (invoice_id, user_id, payment_date, amount, currency, method, reference, source)
+		 SELECT c.original_invoice_id, c.user_id, date(c.generated_at), -c.total_amount, c.currency, 'offset', c.invoice_number, 'credit_note'
+		 FROM invoices c
+		 WHERE c.document_type = 'credit_note' AND c.original_invoice_id IS NOT NULL`,
+		`UPDATE invoices SET payment_status = 'paid', paid_amount = total_amount, paid_at = generated_at
+		 WHERE document_type = 'credit_note' AND original_invoice_id IS NOT NULL`,
+		`UPDATE invoices SET
+		    paid_amount = ROUND((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = invoices.id), 2),
+		    paid_at = (SELECT MAX(p.payment_date) FROM payments p WHERE p.invoice_id = invoices.id),
+		    payment_status = CASE
+		        WHEN (SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = invoices.id) >= total_amount - 0.005 THEN 'paid'
+		        WHEN payment_status = 'collection_pending' THEN payment_status
+		        ELSE 'partial' END
+		 WHERE id IN (SELECT invoice_id FROM payments WHERE source = 'credit_note')`,
+	}
+	for _, q := range steps {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("failed to backfill payments: %v", err)
+		}
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM payments`).Scan(&n)
+	log.Printf("✓ %d payment(s) carried over to the payment ledger", n)
+	return nil
+}
+
+// addInvoiceDunningColumns adds the payment due date, the reached reminder
+// level and the date of the last reminder to invoices. Existing payable
+// documents get the default 30-day term counted from their generation date.
+func addInvoiceDunningColumns(db *sql.DB) error {
+	cols := []struct{ name, ddl string }{
+		{"due_date", "ALTER TABLE invoices ADD COLUMN due_date DATE"},
+		{"dunning_level", "ALTER TABLE invoices ADD COLUMN dunning_level INTEGER NOT NULL DEFAULT 0"},
+		{"last_reminder_at", "ALTER TABLE invoices ADD COLUMN last_reminder_at DATETIME"},
+	}
+	var invoicesSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoices'`).Scan(&invoicesSQL); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if contains(invoicesSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add invoices.%s: %v", c.name, err)
+			}
+		}
+		log.Printf("✓ invoices.%s column added", c.name)
+	}
+	if _, err := db.Exec(`
+		UPDATE invoices SET due_date = date(generated_at, '+30 days')
+		WHERE due_date IS NULL AND document_type != 'credit_note'
+	`); err != nil {
+		return fmt.Errorf("failed to backfill invoices.due_date: %v", err)
+	}
+	return nil
+}
+
+// addInvoiceCancellationColumns adds the columns that link an invoice to its
+// credit note and replacement: document_type ('invoice' | 'credit_note'),
+// original_invoice_id (the invoice a credit note reverses or a replacement
+// corrects) and the cancellation timestamp/reason on the original.
+func addInvoiceCancellationColumns(db *sql.DB) error {
+	cols := []struct{ name, ddl string }{
+		{"document_type", "ALTER TABLE invoices ADD COLUMN document_type TEXT NOT NULL DEFAULT 'invoice'"},
+		{"original_invoice_id", "ALTER TABLE invoices ADD COLUMN original_invoice_id INTEGER REFERENCES invoices(id)"},
+		{"cancelled_at", "ALTER TABLE invoices ADD COLUMN cancelled_at DATETIME"},
+		{"cancellation_reason", "ALTER TABLE invoices ADD COLUMN cancellation_reason TEXT NOT NULL DEFAULT ''"},
+	}
+	var invoicesSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='invoices'`).Scan(&invoicesSQL); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if contains(invoicesSQL, c.name) {
+			continue
+		}
+		if _, err := db.Exec(c.ddl); err != nil {
+			if !contains(err.Error(), "duplicate column") {
+				return fmt.Errorf("failed to add invoices.%s: %v", c.name, err)
+			}
+		}
+		log.Printf("✓ invoices.%s column added", c.name)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_invoices_original ON invoices(original_invoice_id)`); err != nil {
+		log.Printf("Index creation warning: %v", err)
+	}
+	return nil
+}
+
 // addAutoBillingScopeColumns adds billing_mode and charger_id columns to auto_billing_configs.
 func addAutoBillingScopeColumns(db *sql.DB) error {
 	var autoBillingConfigsSql string
@@ -1352,6 +2340,31 @@ func createTriggers(db *sql.DB) error {
 			SET updated_at = CURRENT_TIMESTAMP 
 			WHERE id = NEW.id;
 		END`,
+
+		`CREATE TRIGGER IF NOT EXISTS invoice_archive_no_update
+		BEFORE UPDATE ON invoice_archive
+		BEGIN
+			SELECT RAISE(ABORT, 'invoice archive entries cannot be changed');
+		END`,
+
+		`CREATE TRIGGER IF NOT EXISTS invoice_archive_no_delete
+		BEFORE DELETE ON invoice_archive
+		BEGIN
+			SELECT RAISE(ABORT, 'invoice archive entries cannot be deleted');
+		END`,
+
+		`CREATE TRIGGER IF NOT EXISTS invoice_archive_head_no_delete
+		BEFORE DELETE ON invoice_archive_head
+		BEGIN
+			SELECT RAISE(ABORT, 'the invoice archive head cannot be deleted');
+		END`,
+
+		`CREATE TRIGGER IF NOT EXISTS invoices_archived_no_delete
+		BEFORE DELETE ON invoices
+		WHEN EXISTS (SELECT 1 FROM invoice_archive WHERE invoice_id = OLD.id)
+		BEGIN
+			SELECT RAISE(ABORT, 'archived invoices cannot be deleted; cancel them instead');
+		END`,
 	}
 
 	for _, trigger := range triggers {
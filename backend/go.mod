@@ -12,6 +12,7 @@ require (
 	github.com/rs/cors v1.11.1
 	github.com/spali/go-rscp v0.2.2
 	golang.org/x/crypto v0.43.0
+	golang.org/x/text v0.30.0
 )
 
 require (
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// validateAdvancePlan checks that a plan names a tenant and building, bills a
+// positive monthly amount and has a valid date range. The currency defaults
+// to CHF. Returns the problem, or "" when the plan is valid.
+func validateAdvancePlan(p *models.AdvancePlan) string {
+	if p.UserID == 0 || p.BuildingID == 0 {
+		return "user_id and building_id are required"
+	}
+	if p.MonthlyAmount <= 0 {
+		return "monthly_amount must be greater than zero"
+	}
+	p.Currency = strings.ToUpper(strings.TrimSpace(p.Currency))
+	if p.Currency == "" {
+		p.Currency = "CHF"
+	}
+	if _, err := time.Parse("2006-01-02", p.ValidFrom); err != nil {
+		return "Invalid valid_from format. Use YYYY-MM-DD"
+	}
+	p.ValidTo = strings.TrimSpace(p.ValidTo)
+	if p.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", p.ValidTo); err != nil {
+			return "Invalid valid_to format. Use YYYY-MM-DD"
+		}
+		if p.ValidTo < p.ValidFrom {
+			return "valid_to must not be before valid_from"
+		}
+	}
+	return ""
+}
+
+// nullableDay stores an empty date as NULL.
+func nullableDay(day string) interface{} {
+	if day == "" {
+		return nil
+	}
+	return day
+}
+
+func (h *BillingHandler) ListAdvancePlans(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT id, user_id, building_id, monthly_amount, currency, date(valid_from),
+		       COALESCE(date(valid_to), ''), is_active, notes, created_at, updated_at
+		FROM advance_plans
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY building_id, user_id, valid_from DESC"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query advance plans: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	plans := []models.AdvancePlan{}
+	for rows.Next() {
+		var p models.AdvancePlan
+		if err := rows.Scan(&p.ID, &p.UserID, &p.BuildingID, &p.MonthlyAmount, &p.Currency, &p.ValidFrom,
+			&p.ValidTo, &p.IsActive, &p.Notes, &p.CreatedAt, &p.UpdatedAt); err == nil {
+			plans = append(plans, p)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plans)
+}
+
+func (h *BillingHandler) CreateAdvancePlan(w http.ResponseWriter, r *http.Request) {
+	var p models.AdvancePlan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode advance plan: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateAdvancePlan(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO advance_plans (
+			user_id, building_id, monthly_amount, currency, valid_from, valid_to, is_active, notes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.UserID, p.BuildingID, p.MonthlyAmount, p.Currency, p.ValidFrom, nullableDay(p.ValidTo), p.IsActive, p.Notes)
+	if err != nil {
+		log.Printf("ERROR: Failed to create advance plan: %v", err)
+		http.Error(w, "Failed to create advance plan", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	p.ID = int(id)
+	log.Printf("SUCCESS: Created advance plan ID %d (user %d, %s %.2f/month)", p.ID, p.UserID, p.Currency, p.MonthlyAmount)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *BillingHandler) UpdateAdvancePlan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var p models.AdvancePlan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode advance plan: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateAdvancePlan(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE advance_plans SET
+			user_id = ?, building_id = ?, monthly_amount = ?, currency = ?,
+			valid_from = ?, valid_to = ?, is_active = ?, notes = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, p.UserID, p.BuildingID, p.MonthlyAmount, p.Currency,
+		p.ValidFrom, nullableDay(p.ValidTo), p.IsActive, p.Notes, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update advance plan %d: %v", id, err)
+		http.Error(w, "Failed to update advance plan", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Advance plan not found", http.StatusNotFound)
+		return
+	}
+
+	p.ID = id
+	log.Printf("SUCCESS: Updated advance plan ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *BillingHandler) DeleteAdvancePlan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM advance_plans WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete advance plan %d: %v", id, err)
+		http.Error(w, "Failed to delete advance plan", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted advance plan ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type AdvanceInvoicesRequest struct {
+	BuildingIDs []int  `json:"building_ids"`
+	UserIDs     []int  `json:"user_ids"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Preview     bool   `json:"preview"`
+	PreviewPDF  bool   `json:"preview_pdf"`
+	DocumentParties
+}
+
+// IssueAdvanceInvoices bills the active advance plans of the selected
+// buildings for one period (or previews them with "preview": true).
+func (h *BillingHandler) IssueAdvanceInvoices(w http.ResponseWriter, r *http.Request) {
+	var req AdvanceInvoicesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.BuildingIDs) == 0 {
+		http.Error(w, "building_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Preview {
+		invoices, skipped, err := h.billingService.PreviewAdvanceInvoices(req.BuildingIDs, req.UserIDs, req.StartDate, req.EndDate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"preview":  true,
+			"invoices": services.RenderInvoicePreviews(h.pdfGenerator, invoices, req.senderInfo(), req.bankingInfo(), req.PreviewPDF),
+			"skipped":  skipped,
+		})
+		return
+	}
+
+	invoices, skipped, err := h.billingService.GenerateAdvanceInvoices(req.BuildingIDs, req.UserIDs, req.StartDate, req.EndDate)
+	if err != nil {
+		log.Printf("ERROR: Advance invoice generation failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range invoices {
+		pdfPath, err := h.generateInvoicePDF(invoices[i].ID, req.senderInfo(), req.bankingInfo())
+		if err != nil {
+			log.Printf("WARNING: Failed to generate PDF for advance invoice %d: %v", invoices[i].ID, err)
+			continue
+		}
+		invoices[i].PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Advance Invoices Generated",
+		fmt.Sprintf("%d advance invoices (%d skipped), period %s to %s", len(invoices), len(skipped), req.StartDate, req.EndDate),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoices": invoices,
+		"skipped":  skipped,
+	})
+}
+
+type SettlementRequest struct {
+	UserID        int    `json:"user_id"`
+	BuildingID    int    `json:"building_id"`
+	StartDate     string `json:"start_date"`
+	EndDate       string `json:"end_date"`
+	IsVZEV        bool   `json:"is_vzev"`
+	CustomItemIDs []int  `json:"custom_item_ids"`
+	Basis         string `json:"basis"` // "billed" (default) or "paid"
+	Preview       bool   `json:"preview"`
+	PreviewPDF    bool   `json:"preview_pdf"`
+	DocumentParties
+}
+
+// CreateSettlement issues the annual settlement of a tenant: real consumption
+// minus the advances of the period, with the suggested new monthly advance.
+func (h *BillingHandler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
+	var req SettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == 0 || req.BuildingID == 0 {
+		http.Error(w, "user_id and building_id are required", http.StatusBadRequest)
+		return
+	}
+	customItemIDs := req.CustomItemIDs
+	if customItemIDs == nil {
+		customItemIDs = []int{}
+	}
+
+	if req.Preview {
+		invoice, summary, err := h.billingService.PreviewSettlement(req.UserID, req.BuildingID, req.StartDate, req.EndDate,
+			req.IsVZEV, customItemIDs, req.Basis)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"preview":    true,
+			"settlement": summary,
+			"invoices": services.RenderInvoicePreviews(h.pdfGenerator, []models.Invoice{*invoice},
+				req.senderInfo(), req.bankingInfo(), req.PreviewPDF),
+		})
+		return
+	}
+
+	var existing string
+	err := h.db.QueryRow(`
+		SELECT invoice_number FROM invoices
+		WHERE user_id = ? AND building_id = ? AND document_type = ? AND status = ?
+		AND period_start = ? AND period_end = ?
+		LIMIT 1
+	`, req.UserID, req.BuildingID, services.DocumentTypeSettlement, services.InvoiceStatusIssued,
+		req.StartDate, req.EndDate).Scan(&existing)
+	if err == nil {
+		http.Error(w, fmt.Sprintf("Settlement %s already exists for this period; cancel it first", existing), http.StatusConflict)
+		return
+	} else if err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	invoice, summary, err := h.billingService.GenerateSettlement(req.UserID, req.BuildingID, req.StartDate, req.EndDate,
+		req.IsVZEV, customItemIDs, req.Basis)
+	if err != nil {
+		log.Printf("ERROR: Settlement for user %d failed: %v", req.UserID, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if pdfPath, err := h.generateInvoicePDF(invoice.ID, req.senderInfo(), req.bankingInfo()); err != nil {
+		log.Printf("WARNING: Failed to generate PDF for settlement %d: %v", invoice.ID, err)
+	} else {
+		invoice.PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Settlement Generated",
+		fmt.Sprintf("%s: consumption %.2f, advances %.2f, balance %.2f", invoice.InvoiceNumber,
+			summary.ConsumptionTotal, summary.AdvancesTotal, summary.Balance), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoice":    invoice,
+		"settlement": summary,
+	})
+}
@@ -69,13 +69,22 @@ func normalizeBillContent(v string) string {
 	}
 }
 
+// normalizeInvoiceType validates what a config issues, defaulting to consumption bills.
+func normalizeInvoiceType(v string) string {
+	if v == services.AutoBillingTypeAdvance {
+		return v
+	}
+	return services.AutoBillingTypeConsumption
+}
+
 // autoBillingSelectColumns is the column list shared by List and Get so the two
 // stay in lock-step with scanAutoBillingConfigRow.
 const autoBillingSelectColumns = `id, name, building_ids, apartments_json, custom_item_ids, frequency, generation_day,
 	first_execution_date, is_active, is_vzev, billing_mode, COALESCE(bill_content, 'both'), charger_id,
 	COALESCE(auto_send_email, 0), last_run, next_run,
 	sender_name, sender_address, sender_city, sender_zip, sender_country,
-	bank_name, bank_iban, bank_account_holder, created_at, updated_at`
+	bank_name, bank_iban, bank_account_holder, created_at, updated_at,
+	COALESCE(invoice_type, 'consumption'), number_scheme_id, COALESCE(require_approval, 0)`
 
 // rowScanner is satisfied by both *sql.Row and *sql.Rows.
 type rowScanner interface {
@@ -90,8 +99,8 @@ func scanAutoBillingConfigRow(sc rowScanner) (map[string]interface{}, error) {
 	var apartmentsJSON, customItemIDsStr, firstExecutionDate sql.NullString
 	var isVZEV bool
 	var billingMode, billContent sql.NullString
-	var chargerID sql.NullInt64
-	var autoSendEmail bool
+	var chargerID, numberSchemeID sql.NullInt64
+	var autoSendEmail, requireApproval bool
 	var lastRun, nextRun sql.NullTime
 	var senderName, senderAddress, senderCity, senderZip, senderCountry sql.NullString
 	var bankName, bankIBAN, bankAccountHolder sql.NullString
@@ -102,7 +111,7 @@ func scanAutoBillingConfigRow(sc rowScanner) (map[string]interface{}, error) {
 		&config.IsActive, &isVZEV, &billingMode, &billContent, &chargerID, &autoSendEmail, &lastRun, &nextRun,
 		&senderName, &senderAddress, &senderCity, &senderZip, &senderCountry,
 		&bankName, &bankIBAN, &bankAccountHolder,
-		&config.CreatedAt, &config.UpdatedAt,
+		&config.CreatedAt, &config.UpdatedAt, &config.InvoiceType, &numberSchemeID, &requireApproval,
 	); err != nil {
 		return nil, err
 	}
@@ -133,7 +142,9 @@ func scanAutoBillingConfigRow(sc rowScanner) (map[string]interface{}, error) {
 		"created_at":      config.CreatedAt,
 		"updated_at":      config.UpdatedAt,
 		"auto_send_email": autoSendEmail,
+		"invoice_type":    config.InvoiceType,
 	}
+	m["require_approval"] = requireApproval
 	if billingMode.Valid && billingMode.String != "" {
 		m["billing_mode"] = billingMode.String
 	} else {
@@ -147,6 +158,9 @@ func scanAutoBillingConfigRow(sc rowScanner) (map[string]interface{}, error) {
 	if chargerID.Valid {
 		m["charger_id"] = int(chargerID.Int64)
 	}
+	if numberSchemeID.Valid {
+		m["number_scheme_id"] = int(numberSchemeID.Int64)
+	}
 	if firstExecutionDate.Valid {
 		m["first_execution_date"] = firstExecutionDate.String
 	}
@@ -244,8 +258,11 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		IsVZEV             bool                 `json:"is_vzev"`
 		BillingMode        string               `json:"billing_mode"`
 		BillContent        string               `json:"bill_content"`
+		InvoiceType        string               `json:"invoice_type"`
 		ChargerID          *int                 `json:"charger_id"`
+		NumberSchemeID     *int                 `json:"number_scheme_id"`
 		AutoSendEmail      bool                 `json:"auto_send_email"`
+		RequireApproval    bool                 `json:"require_approval"`
 		SenderName         string               `json:"sender_name"`
 		SenderAddress      string               `json:"sender_address"`
 		SenderCity         string               `json:"sender_city"`
@@ -261,6 +278,9 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	if req.NumberSchemeID != nil && *req.NumberSchemeID == 0 {
+		req.NumberSchemeID = nil // 0 = use the buildings' schemes
+	}
 
 	// Convert building IDs to comma-separated string
 	buildingIDsStr := idListToString(req.BuildingIDs)
@@ -292,6 +312,7 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		billingMode = "apartments"
 	}
 	billContent := normalizeBillContent(req.BillContent)
+	invoiceType := normalizeInvoiceType(req.InvoiceType)
 	var chargerIDValue interface{}
 	if billingMode == "charger" && req.ChargerID != nil {
 		chargerIDValue = *req.ChargerID
@@ -304,12 +325,13 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 			name, building_ids, apartments_json, custom_item_ids, frequency, generation_day,
 			first_execution_date, is_active, is_vzev, billing_mode, bill_content, charger_id, auto_send_email, next_run,
 			sender_name, sender_address, sender_city, sender_zip, sender_country,
-			bank_name, bank_iban, bank_account_holder
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			bank_name, bank_iban, bank_account_holder, invoice_type, number_scheme_id, require_approval
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, req.Name, buildingIDsStr, string(apartmentsJSON), customItemIDsStr, req.Frequency, req.GenerationDay,
 		firstExecDateValue, req.IsActive, req.IsVZEV, billingMode, billContent, chargerIDValue, req.AutoSendEmail, nextRun,
 		req.SenderName, req.SenderAddress, req.SenderCity,
-		req.SenderZip, req.SenderCountry, req.BankName, req.BankIBAN, req.BankAccountHolder)
+		req.SenderZip, req.SenderCountry, req.BankName, req.BankIBAN, req.BankAccountHolder, invoiceType, req.NumberSchemeID,
+		req.RequireApproval)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create auto billing config: %v", err)
@@ -333,7 +355,9 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		"is_vzev":             req.IsVZEV,
 		"billing_mode":        billingMode,
 		"bill_content":        billContent,
+		"invoice_type":        invoiceType,
 		"auto_send_email":     req.AutoSendEmail,
+		"require_approval":    req.RequireApproval,
 		"next_run":            nextRun.Format(time.RFC3339),
 		"sender_name":         req.SenderName,
 		"sender_address":      req.SenderAddress,
@@ -348,6 +372,9 @@ func (h *AutoBillingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if req.ChargerID != nil {
 		response["charger_id"] = *req.ChargerID
 	}
+	if req.NumberSchemeID != nil {
+		response["number_scheme_id"] = *req.NumberSchemeID
+	}
 	if req.FirstExecutionDate != "" {
 		response["first_execution_date"] = req.FirstExecutionDate
 	}
@@ -377,8 +404,11 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 		IsVZEV             bool                 `json:"is_vzev"`
 		BillingMode        string               `json:"billing_mode"`
 		BillContent        string               `json:"bill_content"`
+		InvoiceType        string               `json:"invoice_type"`
 		ChargerID          *int                 `json:"charger_id"`
+		NumberSchemeID     *int                 `json:"number_scheme_id"`
 		AutoSendEmail      bool                 `json:"auto_send_email"`
+		RequireApproval    bool                 `json:"require_approval"`
 		SenderName         string               `json:"sender_name"`
 		SenderAddress      string               `json:"sender_address"`
 		SenderCity         string               `json:"sender_city"`
@@ -394,6 +424,9 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	if req.NumberSchemeID != nil && *req.NumberSchemeID == 0 {
+		req.NumberSchemeID = nil // 0 = use the buildings' schemes
+	}
 
 	// Convert building IDs to comma-separated string
 	buildingIDsStr := idListToString(req.BuildingIDs)
@@ -425,6 +458,7 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 		billingMode = "apartments"
 	}
 	billContent := normalizeBillContent(req.BillContent)
+	invoiceType := normalizeInvoiceType(req.InvoiceType)
 	var chargerIDValue interface{}
 	if billingMode == "charger" && req.ChargerID != nil {
 		chargerIDValue = *req.ChargerID
@@ -439,14 +473,14 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 			billing_mode = ?, bill_content = ?, charger_id = ?, auto_send_email = ?, next_run = ?,
 			sender_name = ?, sender_address = ?, sender_city = ?,
 			sender_zip = ?, sender_country = ?, bank_name = ?,
-			bank_iban = ?, bank_account_holder = ?,
-			updated_at = CURRENT_TIMESTAMP
+			bank_iban = ?, bank_account_holder = ?, invoice_type = ?, number_scheme_id = ?,
+			require_approval = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, req.Name, buildingIDsStr, string(apartmentsJSON), customItemIDsStr, req.Frequency, req.GenerationDay,
 		firstExecDateValue, req.IsActive, req.IsVZEV, billingMode, billContent, chargerIDValue, req.AutoSendEmail, nextRun,
 		req.SenderName, req.SenderAddress, req.SenderCity,
 		req.SenderZip, req.SenderCountry, req.BankName, req.BankIBAN,
-		req.BankAccountHolder, id)
+		req.BankAccountHolder, invoiceType, req.NumberSchemeID, req.RequireApproval, id)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to update auto billing config: %v", err)
@@ -469,7 +503,9 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 		"is_vzev":             req.IsVZEV,
 		"billing_mode":        billingMode,
 		"bill_content":        billContent,
+		"invoice_type":        invoiceType,
 		"auto_send_email":     req.AutoSendEmail,
+		"require_approval":    req.RequireApproval,
 		"next_run":            nextRun.Format(time.RFC3339),
 		"sender_name":         req.SenderName,
 		"sender_address":      req.SenderAddress,
@@ -484,6 +520,9 @@ func (h *AutoBillingHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if req.ChargerID != nil {
 		response["charger_id"] = *req.ChargerID
 	}
+	if req.NumberSchemeID != nil {
+		response["number_scheme_id"] = *req.NumberSchemeID
+	}
 	if req.FirstExecutionDate != "" {
 		response["first_execution_date"] = req.FirstExecutionDate
 	}
@@ -515,6 +554,8 @@ func (h *AutoBillingHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // Generates the bills, produces PDFs, and — if auto_send_email is set on the
 // config — also sends them to the recipients via SMTP. The scheduled next_run
 // is left untouched so the periodic schedule still fires as configured.
+// With ?preview=true the run is a dry run (add &pdf=true for rendered PDFs):
+// nothing is stored, e-mailed or rescheduled.
 func (h *AutoBillingHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -528,6 +569,27 @@ func (h *AutoBillingHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if preview, _ := strconv.ParseBool(r.URL.Query().Get("preview")); preview {
+		withPDF, _ := strconv.ParseBool(r.URL.Query().Get("pdf"))
+		result, err := h.scheduler.PreviewConfig(id, withPDF)
+		if err != nil {
+			log.Printf("ERROR: Auto-billing preview for config %d failed: %v", id, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "preview",
+			"preview": result,
+		})
+		return
+	}
+
 	result, err := h.scheduler.RunConfigNow(id)
 	if err != nil {
 		log.Printf("ERROR: Manual auto-billing run for config %d failed: %v", id, err)
@@ -617,3 +679,57 @@ func (h *AutoBillingHandler) GetCustomItemsForBuildings(w http.ResponseWriter, r
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(items)
 }
+
+// ReviewQueue lists the draft invoices waiting for approval with their
+// warnings (?config_id= narrows to one config). With ?render=true each draft
+// is returned as a rendered preview (add &pdf=true for PDFs).
+func (h *AutoBillingHandler) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	configID, _ := strconv.Atoi(q.Get("config_id"))
+	drafts, err := services.LoadReviewQueue(h.db, configID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load review queue: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if render, _ := strconv.ParseBool(q.Get("render")); render && h.scheduler != nil {
+		withPDF, _ := strconv.ParseBool(q.Get("pdf"))
+		json.NewEncoder(w).Encode(h.scheduler.RenderDrafts(drafts, withPDF))
+		return
+	}
+	json.NewEncoder(w).Encode(drafts)
+}
+
+// ApproveInvoices approves draft invoices ({"invoice_ids": [...]}): they are
+// numbered and issued, and only then rendered and e-mailed.
+func (h *AutoBillingHandler) ApproveInvoices(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		InvoiceIDs []int `json:"invoice_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.InvoiceIDs) == 0 {
+		http.Error(w, "invoice_ids is required", http.StatusBadRequest)
+		return
+	}
+	if h.scheduler == nil {
+		http.Error(w, "Auto-billing scheduler not initialised", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.scheduler.ApproveInvoices(req.InvoiceIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("SUCCESS: Approved %d invoice(s) - %d PDFs, %d emails sent, %d failed",
+		result.Approved, result.PDFsGenerated, result.EmailsSent, result.EmailsFailed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// BankImportHandler imports camt.053/camt.054 bank statements and serves the
+// review queue of credits that could not be matched to an invoice.
+type BankImportHandler struct {
+	db *sql.DB
+}
+
+func NewBankImportHandler(db *sql.DB) *BankImportHandler {
+	return &BankImportHandler{db: db}
+}
+
+func (h *BankImportHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// Import accepts a camt XML file, either as multipart upload (field "file")
+// or as the raw request body with an optional file_name query parameter.
+func (h *BankImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var fileName string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No statement file provided", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Failed to read statement file", http.StatusBadRequest)
+			return
+		}
+		fileName = header.Filename
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		fileName = r.URL.Query().Get("file_name")
+	}
+
+	result, err := services.ImportBankStatement(h.db, fileName, data)
+	if err != nil {
+		log.Printf("ERROR: Failed to import bank statement %s: %v", fileName, err)
+		http.Error(w, "Invalid bank statement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.logToDatabase("Bank Statement Imported",
+		fmt.Sprintf("%s %s: %d transactions, %d matched, %d to review, %d duplicates", result.Import.Format, fileName,
+			result.Import.Transactions, result.Import.Matched, result.Import.Unmatched, result.Import.Duplicates),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *BankImportHandler) ListImports(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT id, file_name, message_id, format, account_iban, transactions, duplicates,
+		       matched, unmatched, imported_at
+		FROM bank_imports ORDER BY imported_at DESC, id DESC
+	`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query bank imports: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	imports := []models.BankImport{}
+	for rows.Next() {
+		var bi models.BankImport
+		if err := rows.Scan(&bi.ID, &bi.FileName, &bi.MessageID, &bi.Format, &bi.AccountIBAN, &bi.Transactions,
+			&bi.Duplicates, &bi.Matched, &bi.Unmatched, &bi.ImportedAt); err == nil {
+			imports = append(imports, bi)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imports)
+}
+
+// ListTransactions returns imported transactions; ?status=unmatched is the
+// review queue.
+func (h *BankImportHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	importID := 0
+	if v := r.URL.Query().Get("import_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid import_id", http.StatusBadRequest)
+			return
+		}
+		importID = id
+	}
+
+	transactions, err := services.ListBankTransactions(h.db, r.URL.Query().Get("status"), importID)
+	if err != nil {
+		log.Printf("ERROR: Failed to query bank transactions: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// bankTransactionState loads the status and direction of a transaction,
+// writing a 404/500 response when it cannot.
+func (h *BankImportHandler) bankTransactionState(w http.ResponseWriter, id int) (string, string, bool) {
+	var status, creditDebit string
+	err := h.db.QueryRow(`SELECT status, credit_debit FROM bank_transactions WHERE id = ?`, id).Scan(&status, &creditDebit)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return "", "", false
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	return status, creditDebit, true
+}
+
+// Assign books a transaction from the review queue on an invoice by hand.
+func (h *BankImportHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		InvoiceID int `json:"invoice_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.InvoiceID == 0 {
+		http.Error(w, "invoice_id is required", http.StatusBadRequest)
+		return
+	}
+
+	status, creditDebit, ok := h.bankTransactionState(w, id)
+	if !ok {
+		return
+	}
+	if status == services.BankTxMatched || status == services.BankTxAssigned {
+		http.Error(w, "Transaction is already booked on an invoice", http.StatusConflict)
+		return
+	}
+	if creditDebit != "CRDT" {
+		http.Error(w, "Only credits can be assigned to an invoice", http.StatusConflict)
+		return
+	}
+
+	var invoiceStatus, documentType string
+	err = h.db.QueryRow(`SELECT status, COALESCE(document_type, 'invoice') FROM invoices WHERE id = ?`, req.InvoiceID).Scan(&invoiceStatus, &documentType)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if invoiceStatus != services.InvoiceStatusIssued || services.IsCreditDocument(documentType) {
+		http.Error(w, fmt.Sprintf("Cannot book a payment on a %s %s", invoiceStatus, documentType), http.StatusConflict)
+		return
+	}
+
+	if err := services.AssignBankTransaction(h.db, id, req.InvoiceID); err != nil {
+		log.Printf("ERROR: Failed to assign bank transaction %d to invoice %d: %v", id, req.InvoiceID, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	h.logToDatabase("Bank Transaction Assigned",
+		fmt.Sprintf("Transaction #%d booked on invoice #%d", id, req.InvoiceID), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transaction_id": id,
+		"invoice_id":     req.InvoiceID,
+		"status":         services.BankTxAssigned,
+	})
+}
+
+// Ignore removes a transaction from the review queue without booking it
+// (e.g. a refund or a payment that does not belong to billing).
+func (h *BankImportHandler) Ignore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, _, ok := h.bankTransactionState(w, id)
+	if !ok {
+		return
+	}
+	if status != services.BankTxUnmatched {
+		http.Error(w, "Only unmatched transactions can be ignored", http.StatusConflict)
+		return
+	}
+
+	if _, err := h.db.Exec(`UPDATE bank_transactions SET status = ?, review_note = ? WHERE id = ?`,
+		services.BankTxIgnored, strings.TrimSpace(req.Note), id); err != nil {
+		log.Printf("ERROR: Failed to ignore bank transaction %d: %v", id, err)
+		http.Error(w, "Failed to update transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -31,12 +31,14 @@ func (h *BillLayoutHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	var (
 		title, intro, footer, color string
+		showChart                   bool
 	)
 	err = h.db.QueryRow(`
 		SELECT COALESCE(title, ''), COALESCE(intro_text, ''),
-		       COALESCE(footer_text, ''), COALESCE(primary_color, '#667EEA')
+		       COALESCE(footer_text, ''), COALESCE(primary_color, '#667EEA'),
+		       show_consumption_chart
 		FROM bill_layouts WHERE building_id = ?
-	`, buildingID).Scan(&title, &intro, &footer, &color)
+	`, buildingID).Scan(&title, &intro, &footer, &color, &showChart)
 
 	if err != nil && err != sql.ErrNoRows {
 		log.Printf("ERROR: Failed to load bill layout for building %d: %v", buildingID, err)
@@ -49,11 +51,12 @@ func (h *BillLayoutHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := map[string]interface{}{
-		"building_id":   buildingID,
-		"title":         title,
-		"intro_text":    intro,
-		"footer_text":   footer,
-		"primary_color": color,
+		"building_id":            buildingID,
+		"title":                  title,
+		"intro_text":             intro,
+		"footer_text":            footer,
+		"primary_color":          color,
+		"show_consumption_chart": showChart,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -69,10 +72,11 @@ func (h *BillLayoutHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Title        string `json:"title"`
-		IntroText    string `json:"intro_text"`
-		FooterText   string `json:"footer_text"`
-		PrimaryColor string `json:"primary_color"`
+		Title                string `json:"title"`
+		IntroText            string `json:"intro_text"`
+		FooterText           string `json:"footer_text"`
+		PrimaryColor         string `json:"primary_color"`
+		ShowConsumptionChart bool   `json:"show_consumption_chart"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
@@ -83,15 +87,16 @@ func (h *BillLayoutHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_, err = h.db.Exec(`
-		INSERT INTO bill_layouts (building_id, title, intro_text, footer_text, primary_color, updated_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO bill_layouts (building_id, title, intro_text, footer_text, primary_color, show_consumption_chart, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(building_id) DO UPDATE SET
 			title = excluded.title,
 			intro_text = excluded.intro_text,
 			footer_text = excluded.footer_text,
 			primary_color = excluded.primary_color,
+			show_consumption_chart = excluded.show_consumption_chart,
 			updated_at = CURRENT_TIMESTAMP
-	`, buildingID, req.Title, req.IntroText, req.FooterText, req.PrimaryColor)
+	`, buildingID, req.Title, req.IntroText, req.FooterText, req.PrimaryColor, req.ShowConsumptionChart)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to save bill layout for building %d: %v", buildingID, err)
@@ -100,11 +105,12 @@ func (h *BillLayoutHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := map[string]interface{}{
-		"building_id":   buildingID,
-		"title":         req.Title,
-		"intro_text":    req.IntroText,
-		"footer_text":   req.FooterText,
-		"primary_color": req.PrimaryColor,
+		"building_id":            buildingID,
+		"title":                  req.Title,
+		"intro_text":             req.IntroText,
+		"footer_text":            req.FooterText,
+		"primary_color":          req.PrimaryColor,
+		"show_consumption_chart": req.ShowConsumptionChart,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
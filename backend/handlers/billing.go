@@ -62,12 +62,22 @@ type GenerateBillsRequest struct {
 	// Custom item IDs to include in bills (NEW)
 	CustomItemIDs []int `json:"custom_item_ids"`
 
+	// Preview runs the generation as a dry run: the response carries the
+	// would-be invoices, skipped tenants and rendered HTML (plus PDF when
+	// PreviewPDF is set) — nothing is stored and no invoice number is used.
+	Preview    bool `json:"preview"`
+	PreviewPDF bool `json:"preview_pdf"`
+
+	// EmbedEInvoice attaches the EN 16931 XML to each PDF (Factur-X).
+	EmbedEInvoice bool `json:"embed_einvoice"`
+
 	// Sender information
-	SenderName    string `json:"sender_name"`
-	SenderAddress string `json:"sender_address"`
-	SenderCity    string `json:"sender_city"`
-	SenderZip     string `json:"sender_zip"`
-	SenderCountry string `json:"sender_country"`
+	SenderName      string `json:"sender_name"`
+	SenderAddress   string `json:"sender_address"`
+	SenderCity      string `json:"sender_city"`
+	SenderZip       string `json:"sender_zip"`
+	SenderCountry   string `json:"sender_country"`
+	SenderVATNumber string `json:"sender_vat_number"`
 
 	// Banking information
 	BankName          string `json:"bank_name"`
@@ -85,7 +95,8 @@ func (h *BillingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 		query = `
 			SELECT id, building_id, is_complex, normal_power_price, solar_power_price,
 			       battery_power_price, battery_charging_price, car_charging_normal_price, car_charging_priority_price,
-			       vzev_export_price, COALESCE(solar_split_mode, 'metered'), vat_included, vat_rate, currency, valid_from,
+			       vzev_export_price, COALESCE(solar_split_mode, 'metered'), COALESCE(tariff_windows_enabled, 0),
+			       COALESCE(grid_pricing_mode, 'fixed'), COALESCE(spot_price_area, ''), COALESCE(spot_price_markup, 0), vat_included, vat_rate, currency, valid_from,
 			       valid_to, is_active, created_at, updated_at
 			FROM billing_settings
 			WHERE building_id = ?
@@ -96,7 +107,8 @@ func (h *BillingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 		query = `
     		SELECT id, building_id, is_complex, normal_power_price, solar_power_price,
            			battery_power_price, battery_charging_price, car_charging_normal_price, car_charging_priority_price,
-           			vzev_export_price, COALESCE(solar_split_mode, 'metered'), vat_included, vat_rate, currency, valid_from,
+           			vzev_export_price, COALESCE(solar_split_mode, 'metered'), COALESCE(tariff_windows_enabled, 0),
+			       COALESCE(grid_pricing_mode, 'fixed'), COALESCE(spot_price_area, ''), COALESCE(spot_price_markup, 0), vat_included, vat_rate, currency, valid_from,
            			valid_to, is_active, created_at, updated_at
     		FROM billing_settings
     		ORDER BY building_id, valid_from DESC
@@ -118,7 +130,8 @@ func (h *BillingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 		err := rows.Scan(
 			&s.ID, &s.BuildingID, &s.IsComplex, &s.NormalPowerPrice, &s.SolarPowerPrice,
 			&s.BatteryPowerPrice, &s.BatteryChargingPrice, &s.CarChargingNormalPrice, &s.CarChargingPriorityPrice, &s.VZEVExportPrice,
-			&s.SolarSplitMode, &s.VATIncluded, &s.VATRate,
+			&s.SolarSplitMode, &s.TariffWindowsEnabled,
+			&s.GridPricingMode, &s.SpotPriceArea, &s.SpotPriceMarkup, &s.VATIncluded, &s.VATRate,
 			&s.Currency, &s.ValidFrom, &validTo, &s.IsActive, &s.CreatedAt, &s.UpdatedAt,
 		)
 		if err == nil {
@@ -133,6 +146,20 @@ func (h *BillingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(settings)
 }
 
+// normalizeGridPricing defaults the grid pricing mode and checks that a spot
+// priced row names its price area. Returns a 400 message ("" when valid).
+func normalizeGridPricing(s *models.BillingSettings) string {
+	s.SpotPriceArea = strings.ToUpper(strings.TrimSpace(s.SpotPriceArea))
+	if s.GridPricingMode != services.GridPricingSpot {
+		s.GridPricingMode = services.GridPricingFixed
+		return ""
+	}
+	if s.SpotPriceArea == "" {
+		return "spot_price_area is required for spot pricing"
+	}
+	return ""
+}
+
 func (h *BillingHandler) CreateSettings(w http.ResponseWriter, r *http.Request) {
 	var s models.BillingSettings
 	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
@@ -150,16 +177,21 @@ func (h *BillingHandler) CreateSettings(w http.ResponseWriter, r *http.Request)
 	if s.SolarSplitMode != "total" {
 		s.SolarSplitMode = "metered"
 	}
+	if msg := normalizeGridPricing(&s); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	result, err := h.db.Exec(`
     	INSERT INTO billing_settings (
         	building_id, is_complex, normal_power_price, solar_power_price, battery_power_price, battery_charging_price,
         	car_charging_normal_price, car_charging_priority_price,
-        	vzev_export_price, solar_split_mode, vat_included, vat_rate, currency, valid_from, valid_to, is_active
-    		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        	vzev_export_price, solar_split_mode, tariff_windows_enabled, grid_pricing_mode, spot_price_area, spot_price_markup,
+        	vat_included, vat_rate, currency, valid_from, valid_to, is_active
+    		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, s.BuildingID, s.IsComplex, s.NormalPowerPrice, s.SolarPowerPrice, s.BatteryPowerPrice, s.BatteryChargingPrice,
 		s.CarChargingNormalPrice, s.CarChargingPriorityPrice,
-		s.VZEVExportPrice, s.SolarSplitMode, s.VATIncluded, s.VATRate, s.Currency, s.ValidFrom, validTo, s.IsActive)
+		s.VZEVExportPrice, s.SolarSplitMode, s.TariffWindowsEnabled, s.GridPricingMode, s.SpotPriceArea, s.SpotPriceMarkup, s.VATIncluded, s.VATRate, s.Currency, s.ValidFrom, validTo, s.IsActive)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create billing settings: %v", err)
@@ -222,17 +254,22 @@ func (h *BillingHandler) UpdateSettings(w http.ResponseWriter, r *http.Request)
 	if s.SolarSplitMode != "total" {
 		s.SolarSplitMode = "metered"
 	}
+	if msg := normalizeGridPricing(&s); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	result, err := h.db.Exec(`
 		UPDATE billing_settings SET
 			building_id = ?, is_complex = ?, normal_power_price = ?, solar_power_price = ?, battery_power_price = ?, battery_charging_price = ?,
 			car_charging_normal_price = ?, car_charging_priority_price = ?,
-			vzev_export_price = ?, solar_split_mode = ?, vat_included = ?, vat_rate = ?, currency = ?, valid_from = ?, valid_to = ?,
+			vzev_export_price = ?, solar_split_mode = ?, tariff_windows_enabled = ?,
+			grid_pricing_mode = ?, spot_price_area = ?, spot_price_markup = ?, vat_included = ?, vat_rate = ?, currency = ?, valid_from = ?, valid_to = ?,
 			is_active = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, s.BuildingID, s.IsComplex, s.NormalPowerPrice, s.SolarPowerPrice, s.BatteryPowerPrice, s.BatteryChargingPrice,
 		s.CarChargingNormalPrice, s.CarChargingPriorityPrice,
-		s.VZEVExportPrice, s.SolarSplitMode, s.VATIncluded, s.VATRate, s.Currency, s.ValidFrom, validTo,
+		s.VZEVExportPrice, s.SolarSplitMode, s.TariffWindowsEnabled, s.GridPricingMode, s.SpotPriceArea, s.SpotPriceMarkup, s.VATIncluded, s.VATRate, s.Currency, s.ValidFrom, validTo,
 		s.IsActive, s.ID)
 
 	if err != nil {
@@ -351,6 +388,11 @@ func (h *BillingHandler) GenerateBills(w http.ResponseWriter, r *http.Request) {
 		customItemIDs = []int{}
 	}
 
+	if req.Preview {
+		h.previewBills(w, req, customItemIDs)
+		return
+	}
+
 	invoices, skipped, err := h.billingService.GenerateBillsWithOptions(
 		req.BuildingIDs,
 		req.UserIDs,
@@ -390,11 +432,12 @@ func (h *BillingHandler) GenerateBills(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare sender and banking info for PDF generation
 	senderInfo := services.SenderInfo{
-		Name:    req.SenderName,
-		Address: req.SenderAddress,
-		City:    req.SenderCity,
-		Zip:     req.SenderZip,
-		Country: req.SenderCountry,
+		Name:      req.SenderName,
+		Address:   req.SenderAddress,
+		City:      req.SenderCity,
+		Zip:       req.SenderZip,
+		Country:   req.SenderCountry,
+		VATNumber: req.SenderVATNumber,
 	}
 
 	bankingInfo := services.BankingInfo{
@@ -406,31 +449,20 @@ func (h *BillingHandler) GenerateBills(w http.ResponseWriter, r *http.Request) {
 	// Generate PDFs for each invoice
 	successCount := 0
 	for i, invoice := range invoices {
-		// Load full invoice with items and user details
-		fullInvoice, err := h.loadFullInvoice(invoice.ID)
-		if err != nil {
-			log.Printf("WARNING: Failed to load full invoice %d: %v", invoice.ID, err)
-			continue
-		}
-
-		// Convert invoice struct to map for PDF generator
-		invoiceMap := h.invoiceToMap(fullInvoice)
-
-		// Generate PDF
-		pdfPath, err := h.pdfGenerator.GenerateInvoicePDF(invoiceMap, senderInfo, bankingInfo)
+		pdfPath, err := h.renderInvoicePDF(invoice.ID, senderInfo, bankingInfo)
 		if err != nil {
 			log.Printf("WARNING: Failed to generate PDF for invoice %d: %v", invoice.ID, err)
 			continue
 		}
-
-		// Update invoice with PDF path
-		_, err = h.db.Exec("UPDATE invoices SET pdf_path = ? WHERE id = ?", pdfPath, invoice.ID)
-		if err != nil {
-			log.Printf("WARNING: Failed to update PDF path for invoice %d: %v", invoice.ID, err)
-		} else {
-			successCount++
-			log.Printf("✓ Generated PDF %d/%d: %s", i+1, len(invoices), pdfPath)
+		successCount++
+		log.Printf("✓ Generated PDF %d/%d: %s", i+1, len(invoices), pdfPath)
+		if req.EmbedEInvoice {
+			if err := h.attachEInvoice(invoice.ID, pdfPath, senderInfo, bankingInfo); err != nil {
+				log.Printf("WARNING: Failed to embed e-invoice in PDF of invoice %d: %v", invoice.ID, err)
+			}
 		}
+		// Archived last, so the archived copy carries the e-invoice.
+		h.archiveInvoicePDF(invoice.ID, pdfPath)
 	}
 
 	log.Printf("=== Bill generation completed successfully ===")
@@ -452,36 +484,177 @@ func (h *BillingHandler) GenerateBills(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// previewBills answers a dry-run GenerateBills request: the same invoices and
+// skipped tenants a real run would produce, rendered but never stored.
+func (h *BillingHandler) previewBills(w http.ResponseWriter, req GenerateBillsRequest, customItemIDs []int) {
+	invoices, skipped, err := h.billingService.PreviewBillsWithOptions(
+		req.BuildingIDs,
+		req.UserIDs,
+		req.StartDate,
+		req.EndDate,
+		req.IsVZEV,
+		customItemIDs,
+		services.BillingScope{
+			Mode:      req.BillingMode,
+			ChargerID: req.ChargerID,
+			Content:   req.BillContent,
+		},
+	)
+	if err != nil {
+		log.Printf("ERROR: Bill preview failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	senderInfo := services.SenderInfo{
+		Name:      req.SenderName,
+		Address:   req.SenderAddress,
+		City:      req.SenderCity,
+		Zip:       req.SenderZip,
+		Country:   req.SenderCountry,
+		VATNumber: req.SenderVATNumber,
+	}
+	bankingInfo := services.BankingInfo{
+		Name:          req.BankName,
+		IBAN:          req.BankIBAN,
+		AccountHolder: req.BankAccountHolder,
+	}
+	previews := services.RenderInvoicePreviews(h.pdfGenerator, invoices, senderInfo, bankingInfo, req.PreviewPDF)
+
+	log.Printf("Bill preview: %d invoices, %d skipped (nothing stored)", len(invoices), len(skipped))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preview":  true,
+		"invoices": previews,
+		"skipped":  skipped,
+	})
+}
+
+// generateInvoicePDF renders the PDF of a stored invoice (or credit note),
+// records its filename in invoices.pdf_path and archives it.
+func (h *BillingHandler) generateInvoicePDF(invoiceID int, sender services.SenderInfo, banking services.BankingInfo) (string, error) {
+	pdfPath, err := h.renderInvoicePDF(invoiceID, sender, banking)
+	if err != nil {
+		return "", err
+	}
+	h.archiveInvoicePDF(invoiceID, pdfPath)
+	return pdfPath, nil
+}
+
+// archiveInvoicePDF stores the final PDF of an issued document in the
+// invoice archive. A failure does not undo the issued document: it is
+// recorded in the admin log, and the document stays listed as unarchived by
+// the archive verification until it is archived from there.
+func (h *BillingHandler) archiveInvoicePDF(invoiceID int, pdfPath string) {
+	if _, err := services.NewInvoiceArchive(h.db).Archive(invoiceID, pdfPath); err != nil {
+		log.Printf("ERROR: Failed to archive invoice %d: %v", invoiceID, err)
+		h.logToDatabase("Invoice Archive Failed", fmt.Sprintf("Invoice #%d: %v", invoiceID, err), "system")
+	}
+}
+
+// renderInvoicePDF renders the PDF of a stored invoice (or credit note) and
+// records its filename in invoices.pdf_path.
+func (h *BillingHandler) renderInvoicePDF(invoiceID int, sender services.SenderInfo, banking services.BankingInfo) (string, error) {
+	fullInvoice, err := h.loadFullInvoice(invoiceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load invoice: %v", err)
+	}
+	pdfPath, err := h.pdfGenerator.GenerateInvoicePDF(h.invoiceToMap(fullInvoice), sender, banking)
+	if err != nil {
+		return "", err
+	}
+	if _, err := h.db.Exec("UPDATE invoices SET pdf_path = ? WHERE id = ?", pdfPath, invoiceID); err != nil {
+		return "", fmt.Errorf("failed to update PDF path: %v", err)
+	}
+	return pdfPath, nil
+}
+
+// attachEInvoice embeds the EN 16931 XML of an invoice into its generated PDF
+// as a Factur-X attachment, rewriting the file in place.
+func (h *BillingHandler) attachEInvoice(invoiceID int, pdfFilename string, sender services.SenderInfo, banking services.BankingInfo) error {
+	fullInvoice, err := h.loadFullInvoice(invoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to load invoice: %v", err)
+	}
+	xmlData, err := services.BuildEInvoiceXML(fullInvoice, sender, banking)
+	if err != nil {
+		return err
+	}
+	filePath := resolveInvoicePDFPath(sql.NullString{String: pdfFilename, Valid: true}, fullInvoice.InvoiceNumber)
+	if filePath == "" {
+		return fmt.Errorf("PDF file %s not found", pdfFilename)
+	}
+	pdfData, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	out, err := services.EmbedFacturX(pdfData, xmlData, fullInvoice.GeneratedAt)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, out, 0644)
+}
+
 // Helper function to load full invoice with items and user
 func (h *BillingHandler) loadFullInvoice(invoiceID int) (models.Invoice, error) {
 	var inv models.Invoice
 
-	var paidAt sql.NullString
+	var paidAt, cancelledAt, originalNumber, lastReminderAt sql.NullString
+	var originalID sql.NullInt64
 	err := h.db.QueryRow(`
 		SELECT i.id, i.invoice_number, i.user_id, i.building_id,
 		       i.period_start, i.period_end, i.total_amount, i.currency,
 		       i.status, i.generated_at,
 		       i.net_amount, i.vat_amount, i.vat_rate, i.vat_included,
-		       COALESCE(i.payment_status, 'unpaid'), COALESCE(i.paid_amount, 0), i.paid_at
-		FROM invoices i WHERE i.id = ?
+		       COALESCE(i.payment_status, 'unpaid'), COALESCE(i.paid_amount, 0), i.paid_at,
+		       COALESCE(i.document_type, 'invoice'), i.original_invoice_id, o.invoice_number,
+		       i.cancelled_at, COALESCE(i.cancellation_reason, ''),
+		       COALESCE(date(i.due_date), ''), COALESCE(i.dunning_level, 0), i.last_reminder_at,
+		       EXISTS(SELECT 1 FROM invoice_recalculations r WHERE r.correction_invoice_id = i.id)
+		FROM invoices i
+		LEFT JOIN invoices o ON o.id = i.original_invoice_id
+		WHERE i.id = ?
 	`, invoiceID).Scan(
 		&inv.ID, &inv.InvoiceNumber, &inv.UserID, &inv.BuildingID,
 		&inv.PeriodStart, &inv.PeriodEnd, &inv.TotalAmount, &inv.Currency,
 		&inv.Status, &inv.GeneratedAt,
 		&inv.NetAmount, &inv.VATAmount, &inv.VATRate, &inv.VATIncluded,
 		&inv.PaymentStatus, &inv.PaidAmount, &paidAt,
+		&inv.DocumentType, &originalID, &originalNumber,
+		&cancelledAt, &inv.CancellationReason,
+		&inv.DueDate, &inv.DunningLevel, &lastReminderAt,
+		&inv.Correction,
 	)
 
 	if err != nil {
 		return inv, err
 	}
+	if lastReminderAt.Valid {
+		inv.LastReminderAt = &lastReminderAt.String
+	}
 	if paidAt.Valid {
 		inv.PaidAt = &paidAt.String
 	}
+	if originalID.Valid {
+		id := int(originalID.Int64)
+		inv.OriginalInvoiceID = &id
+		inv.OriginalInvoiceNumber = originalNumber.String
+	}
+	if cancelledAt.Valid {
+		inv.CancelledAt = &cancelledAt.String
+	}
+
+	// Credit note / replacement / original documents linked to this one.
+	if related, err := services.LoadInvoiceChain(h.db, inv.ID, inv.OriginalInvoiceID); err == nil && len(related) > 0 {
+		inv.Related = related
+	}
+	if payments, err := services.LoadPayments(h.db, services.PaymentFilter{InvoiceID: inv.ID}); err == nil && len(payments) > 0 {
+		inv.Payments = payments
+	}
 
 	// Load invoice items
 	itemRows, err := h.db.Query(`
-		SELECT id, invoice_id, description, quantity, unit_price, total_price, item_type
+		SELECT id, invoice_id, description, quantity, unit_price, total_price, item_type, vat_code, vat_rate
 		FROM invoice_items WHERE invoice_id = ?
 		ORDER BY id ASC
 	`, inv.ID)
@@ -492,10 +665,11 @@ func (h *BillingHandler) loadFullInvoice(invoiceID int) (models.Invoice, error)
 		for itemRows.Next() {
 			var item models.InvoiceItem
 			if err := itemRows.Scan(&item.ID, &item.InvoiceID, &item.Description,
-				&item.Quantity, &item.UnitPrice, &item.TotalPrice, &item.ItemType); err == nil {
+				&item.Quantity, &item.UnitPrice, &item.TotalPrice, &item.ItemType, &item.VATCode, &item.VATRate); err == nil {
 				inv.Items = append(inv.Items, item)
 			}
 		}
+		inv.VATSummary = services.VATSummary(inv)
 	}
 
 	// Load user details
@@ -527,56 +701,7 @@ func (h *BillingHandler) loadFullInvoice(invoiceID int) (models.Invoice, error)
 
 // Helper function to convert Invoice struct to map for PDF generator
 func (h *BillingHandler) invoiceToMap(inv models.Invoice) map[string]interface{} {
-	invoiceMap := make(map[string]interface{})
-
-	invoiceMap["id"] = inv.ID
-	invoiceMap["invoice_number"] = inv.InvoiceNumber
-	invoiceMap["user_id"] = inv.UserID
-	invoiceMap["building_id"] = inv.BuildingID
-	invoiceMap["period_start"] = inv.PeriodStart
-	invoiceMap["period_end"] = inv.PeriodEnd
-	invoiceMap["total_amount"] = inv.TotalAmount
-	invoiceMap["net_amount"] = inv.NetAmount
-	invoiceMap["vat_amount"] = inv.VATAmount
-	invoiceMap["vat_rate"] = inv.VATRate
-	invoiceMap["vat_included"] = inv.VATIncluded
-	invoiceMap["currency"] = inv.Currency
-	invoiceMap["status"] = inv.Status
-	invoiceMap["generated_at"] = inv.GeneratedAt.Format("2006-01-02")
-
-	// Convert items
-	items := make([]interface{}, len(inv.Items))
-	for i, item := range inv.Items {
-		itemMap := make(map[string]interface{})
-		itemMap["id"] = item.ID
-		itemMap["invoice_id"] = item.InvoiceID
-		itemMap["description"] = item.Description
-		itemMap["quantity"] = item.Quantity
-		itemMap["unit_price"] = item.UnitPrice
-		itemMap["total_price"] = item.TotalPrice
-		itemMap["item_type"] = item.ItemType
-		items[i] = itemMap
-	}
-	invoiceMap["items"] = items
-
-	// Convert user
-	if inv.User != nil {
-		userMap := make(map[string]interface{})
-		userMap["id"] = inv.User.ID
-		userMap["first_name"] = inv.User.FirstName
-		userMap["last_name"] = inv.User.LastName
-		userMap["email"] = inv.User.Email
-		userMap["phone"] = inv.User.Phone
-		userMap["address_street"] = inv.User.AddressStreet
-		userMap["address_city"] = inv.User.AddressCity
-		userMap["address_zip"] = inv.User.AddressZip
-		userMap["address_country"] = inv.User.AddressCountry
-		userMap["language"] = inv.User.Language
-		userMap["is_active"] = inv.User.IsActive
-		invoiceMap["user"] = userMap
-	}
-
-	return invoiceMap
+	return services.InvoiceToMap(inv)
 }
 
 func (h *BillingHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
@@ -590,8 +715,12 @@ func (h *BillingHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
 		       i.period_start, i.period_end, i.total_amount, i.currency,
 		       i.status, i.generated_at, i.pdf_path,
 		       i.net_amount, i.vat_amount, i.vat_rate, i.vat_included,
-		       COALESCE(i.payment_status, 'unpaid'), COALESCE(i.paid_amount, 0), i.paid_at
+		       COALESCE(i.payment_status, 'unpaid'), COALESCE(i.paid_amount, 0), i.paid_at,
+		       COALESCE(i.document_type, 'invoice'), i.original_invoice_id, o.invoice_number,
+		       i.cancelled_at, COALESCE(i.cancellation_reason, ''),
+		       COALESCE(date(i.due_date), ''), COALESCE(i.dunning_level, 0), i.last_reminder_at
 		FROM invoices i
+		LEFT JOIN invoices o ON o.id = i.original_invoice_id
 		WHERE 1=1
 	`
 	args := []interface{}{}
@@ -618,13 +747,17 @@ func (h *BillingHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
 	invoices := []models.Invoice{}
 	for rows.Next() {
 		var inv models.Invoice
-		var pdfPath, paidAt sql.NullString
+		var pdfPath, paidAt, cancelledAt, originalNumber, lastReminderAt sql.NullString
+		var originalID sql.NullInt64
 		err := rows.Scan(
 			&inv.ID, &inv.InvoiceNumber, &inv.UserID, &inv.BuildingID,
 			&inv.PeriodStart, &inv.PeriodEnd, &inv.TotalAmount, &inv.Currency,
 			&inv.Status, &inv.GeneratedAt, &pdfPath,
 			&inv.NetAmount, &inv.VATAmount, &inv.VATRate, &inv.VATIncluded,
 			&inv.PaymentStatus, &inv.PaidAmount, &paidAt,
+			&inv.DocumentType, &originalID, &originalNumber,
+			&cancelledAt, &inv.CancellationReason,
+			&inv.DueDate, &inv.DunningLevel, &lastReminderAt,
 		)
 		if err == nil {
 			if pdfPath.Valid {
@@ -633,6 +766,17 @@ func (h *BillingHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
 			if paidAt.Valid {
 				inv.PaidAt = &paidAt.String
 			}
+			if originalID.Valid {
+				id := int(originalID.Int64)
+				inv.OriginalInvoiceID = &id
+				inv.OriginalInvoiceNumber = originalNumber.String
+			}
+			if cancelledAt.Valid {
+				inv.CancelledAt = &cancelledAt.String
+			}
+			if lastReminderAt.Valid {
+				inv.LastReminderAt = &lastReminderAt.String
+			}
 			invoices = append(invoices, inv)
 		}
 	}
@@ -667,8 +811,48 @@ func (h *BillingHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(inv)
 }
 
+// DownloadEInvoice returns the EN 16931 (Factur-X / ZUGFeRD CII) XML of an
+// invoice. The body carries the sender and bank details, as for the PDF.
+func (h *BillingHandler) DownloadEInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req DocumentParties
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	inv, err := h.loadFullInvoice(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to query invoice ID %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := services.BuildEInvoiceXML(inv, req.senderInfo(), req.bankingInfo())
+	if err != nil {
+		log.Printf("ERROR: Failed to build e-invoice for %s: %v", inv.InvoiceNumber, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xml", inv.InvoiceNumber))
+	w.Write(data)
+}
+
 // UpdateInvoicePayment records the payment state of an invoice: unpaid, partial
-// or fully paid (with the paid amount and a timestamp).
+// or fully paid. The change is booked as a manual payment in the ledger.
 func (h *BillingHandler) UpdateInvoicePayment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -690,47 +874,27 @@ func (h *BillingHandler) UpdateInvoicePayment(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var total float64
-	if err := h.db.QueryRow("SELECT total_amount FROM invoices WHERE id = ?", id).Scan(&total); err == sql.ErrNoRows {
+	var status string
+	if err := h.db.QueryRow("SELECT status FROM invoices WHERE id = ?", id).Scan(&status); err == sql.ErrNoRows {
 		http.Error(w, "Invoice not found", http.StatusNotFound)
 		return
 	} else if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-
-	var paidAmount float64
-	var paidAt interface{}
-	switch req.PaymentStatus {
-	case "paid":
-		paidAmount = total
-		if req.PaidAmount != nil {
-			paidAmount = *req.PaidAmount
-		}
-		paidAt = time.Now().Format("2006-01-02 15:04:05")
-	case "partial":
-		if req.PaidAmount == nil || *req.PaidAmount <= 0 {
-			http.Error(w, "paid_amount is required for a partial payment", http.StatusBadRequest)
-			return
-		}
-		paidAmount = *req.PaidAmount
-		paidAt = time.Now().Format("2006-01-02 15:04:05")
-	default: // unpaid
-		paidAmount = 0
-		paidAt = nil
+	if status == services.InvoiceStatusDraft {
+		http.Error(w, "Draft invoices cannot take payments", http.StatusConflict)
+		return
 	}
 
-	if _, err := h.db.Exec(
-		`UPDATE invoices SET payment_status = ?, paid_amount = ?, paid_at = ? WHERE id = ?`,
-		req.PaymentStatus, paidAmount, paidAt, id,
-	); err != nil {
+	if err := services.SetPaymentStatus(h.db, id, req.PaymentStatus, req.PaidAmount); err != nil {
 		log.Printf("ERROR: Failed to update invoice %d payment: %v", id, err)
-		http.Error(w, "Failed to update payment", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
 
 	h.logToDatabase("Invoice Payment Updated",
-		fmt.Sprintf("Invoice #%d marked %s (%.2f)", id, req.PaymentStatus, paidAmount), getClientIP(r))
+		fmt.Sprintf("Invoice #%d marked %s", id, req.PaymentStatus), getClientIP(r))
 
 	inv, err := h.loadFullInvoice(id)
 	if err != nil {
@@ -741,6 +905,157 @@ func (h *BillingHandler) UpdateInvoicePayment(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(inv)
 }
 
+// DocumentParties carries the sender and bank details printed on a generated
+// document, with the same field names as GenerateBillsRequest.
+type DocumentParties struct {
+	SenderName        string `json:"sender_name"`
+	SenderAddress     string `json:"sender_address"`
+	SenderCity        string `json:"sender_city"`
+	SenderZip         string `json:"sender_zip"`
+	SenderCountry     string `json:"sender_country"`
+	SenderVATNumber   string `json:"sender_vat_number"`
+	BankName          string `json:"bank_name"`
+	BankIBAN          string `json:"bank_iban"`
+	BankAccountHolder string `json:"bank_account_holder"`
+}
+
+func (p DocumentParties) senderInfo() services.SenderInfo {
+	return services.SenderInfo{Name: p.SenderName, Address: p.SenderAddress, City: p.SenderCity, Zip: p.SenderZip, Country: p.SenderCountry, VATNumber: p.SenderVATNumber}
+}
+
+func (p DocumentParties) bankingInfo() services.BankingInfo {
+	return services.BankingInfo{Name: p.BankName, IBAN: p.BankIBAN, AccountHolder: p.BankAccountHolder}
+}
+
+type CancelInvoiceRequest struct {
+	Reason string `json:"reason"`
+	DocumentParties
+}
+
+// CancelInvoice reverses an issued invoice (Storno): a linked credit note with
+// its own number and PDF is created and the original is marked cancelled.
+func (h *BillingHandler) CancelInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CancelInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var status, documentType string
+	err = h.db.QueryRow(`SELECT status, COALESCE(document_type, 'invoice') FROM invoices WHERE id = ?`, id).Scan(&status, &documentType)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if services.IsCreditDocument(documentType) || status != services.InvoiceStatusIssued {
+		http.Error(w, fmt.Sprintf("Only issued invoices can be cancelled (this document is %s)", status), http.StatusConflict)
+		return
+	}
+
+	creditNote, err := h.billingService.CancelInvoice(id, strings.TrimSpace(req.Reason))
+	if err != nil {
+		log.Printf("ERROR: Failed to cancel invoice %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if pdfPath, err := h.generateInvoicePDF(creditNote.ID, req.senderInfo(), req.bankingInfo()); err != nil {
+		log.Printf("WARNING: Failed to generate PDF for credit note %d: %v", creditNote.ID, err)
+	} else {
+		creditNote.PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Invoice Cancelled",
+		fmt.Sprintf("Invoice #%d cancelled by credit note %s", id, creditNote.InvoiceNumber), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(creditNote)
+}
+
+type ReissueInvoiceRequest struct {
+	BillingMode   string `json:"billing_mode"`
+	ChargerID     *int   `json:"charger_id,omitempty"`
+	BillContent   string `json:"bill_content"`
+	CustomItemIDs []int  `json:"custom_item_ids"`
+	DocumentParties
+}
+
+// ReissueInvoice issues the corrected replacement for a cancelled invoice by
+// billing the same tenant and period again; the new invoice references the
+// original.
+func (h *BillingHandler) ReissueInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ReissueInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	customItemIDs := req.CustomItemIDs
+	if customItemIDs == nil {
+		customItemIDs = []int{}
+	}
+
+	var exists int
+	h.db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE id = ?`, id).Scan(&exists)
+	if exists == 0 {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+
+	invoices, skipped, err := h.billingService.ReissueInvoice(id, customItemIDs, services.BillingScope{
+		Mode:      req.BillingMode,
+		ChargerID: req.ChargerID,
+		Content:   req.BillContent,
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to reissue invoice %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if len(invoices) == 0 {
+		var reasons []string
+		for _, sk := range skipped {
+			reasons = append(reasons, fmt.Sprintf("%s — %s", sk.UserName, sk.Reason))
+		}
+		http.Error(w, "No replacement invoice was created. "+strings.Join(reasons, "; "), http.StatusUnprocessableEntity)
+		return
+	}
+
+	for i := range invoices {
+		pdfPath, err := h.generateInvoicePDF(invoices[i].ID, req.senderInfo(), req.bankingInfo())
+		if err != nil {
+			log.Printf("WARNING: Failed to generate PDF for replacement invoice %d: %v", invoices[i].ID, err)
+			continue
+		}
+		invoices[i].PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Invoice Reissued",
+		fmt.Sprintf("Invoice #%d replaced by %s", id, invoices[0].InvoiceNumber), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoices": invoices,
+		"skipped":  skipped,
+	})
+}
+
 func (h *BillingHandler) DeleteInvoice(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -749,6 +1064,23 @@ func (h *BillingHandler) DeleteInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only drafts were never issued; everything else is reversed with a
+	// credit note.
+	var status string
+	err = h.db.QueryRow(`SELECT status FROM invoices WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if status != services.InvoiceStatusDraft {
+		http.Error(w, fmt.Sprintf("Invoice is %s; only drafts can be deleted. Cancel it instead (POST /api/billing/invoices/%d/cancel)", status, id),
+			http.StatusConflict)
+		return
+	}
+
 	// Get PDF path before deletion to clean up file
 	var pdfPath sql.NullString
 	h.db.QueryRow("SELECT pdf_path FROM invoices WHERE id = ?", id).Scan(&pdfPath)
@@ -1004,6 +1336,14 @@ func (h *BillingHandler) DownloadPDF(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// An archived document is served from the archive, whatever happened to
+	// its working copy since.
+	if archived := services.NewInvoiceArchive(h.db).Path(invoiceID); archived != "" {
+		if _, err := os.Stat(archived); err == nil {
+			filePath = archived
+		}
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		log.Printf("PDF file not found: %s", filePath)
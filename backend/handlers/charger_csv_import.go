@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aj9599/zev-billing/backend/services"
 	"github.com/gorilla/mux"
 )
 
@@ -84,6 +85,19 @@ func (h *ChargerHandler) ImportChargerSessionsFromCSV(w http.ResponseWriter, r *
 	}
 	defer tx.Rollback()
 
+	// The days the replaced and the imported sessions cover; invoices issued
+	// for them are recalculated after the import.
+	var firstDay, lastDay string
+	err = tx.QueryRow(`
+		SELECT COALESCE(substr(MIN(session_time), 1, 10), ''), COALESCE(substr(MAX(session_time), 1, 10), '')
+		FROM charger_sessions WHERE charger_id = ?
+	`, chargerID).Scan(&firstDay, &lastDay)
+	if err != nil {
+		log.Printf("Failed to load existing session range: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
 	// Delete existing sessions for this charger
 	deleteResult, err := tx.Exec("DELETE FROM charger_sessions WHERE charger_id = ?", chargerID)
 	if err != nil {
@@ -221,6 +235,14 @@ func (h *ChargerHandler) ImportChargerSessionsFromCSV(w http.ResponseWriter, r *
 		}
 
 		importedCount++
+		if len(sessionTimeStr) >= 10 {
+			if day := sessionTimeStr[:10]; firstDay == "" || day < firstDay {
+				firstDay = day
+			}
+			if day := sessionTimeStr[:10]; day > lastDay {
+				lastDay = day
+			}
+		}
 
 		// Log progress every 100 records
 		if importedCount%100 == 0 {
@@ -252,11 +274,47 @@ func (h *ChargerHandler) ImportChargerSessionsFromCSV(w http.ResponseWriter, r *
 	if firstError != "" {
 		response["first_error"] = firstError
 	}
+	if firstDay != "" {
+		h.addRecalculation(response, chargerID, firstDay, lastDay)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// recalculateInvoicedPeriods recalculates the issued invoices of a charger's
+// building whose period overlaps [from, to] after its sessions changed, so
+// late data does not go unnoticed. Returns the number of invoices whose
+// amount changed; they are listed under /billing/recalculations.
+func (h *ChargerHandler) recalculateInvoicedPeriods(chargerID int, from, to string) (int, error) {
+	var buildingID int
+	if err := h.db.QueryRow("SELECT building_id FROM chargers WHERE id = ?", chargerID).Scan(&buildingID); err != nil {
+		return 0, fmt.Errorf("failed to load building of charger %d: %v", chargerID, err)
+	}
+	recs, err := services.NewBillingService(h.db).RecalculateInvoices([]int{buildingID}, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if len(recs) > 0 {
+		log.Printf("Session change on charger %d affects %d issued invoice(s)", chargerID, len(recs))
+	}
+	return len(recs), nil
+}
+
+// addRecalculation runs recalculateInvoicedPeriods after a session change
+// that has already been committed and reports its outcome in the response:
+// invoices_to_review, or recalculation_error when the invoices could not be
+// checked.
+func (h *ChargerHandler) addRecalculation(response map[string]interface{}, chargerID int, from, to string) {
+	count, err := h.recalculateInvoicedPeriods(chargerID, from, to)
+	if err != nil {
+		log.Printf("WARNING: Recalculation after session change on charger %d failed: %v", chargerID, err)
+		response["recalculation_error"] = err.Error()
+		return
+	}
+	response["invoices_to_review"] = count
+}
+
 // GetChargerSessions returns sessions for a specific charger
 func (h *ChargerHandler) GetChargerSessions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -492,12 +550,15 @@ func (h *ChargerHandler) RescanE3DCBackfill(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"status":   "ok",
 		"deleted":  deleted,
 		"inserted": inserted,
-	})
+	}
+	h.addRecalculation(response, chargerID, req.From, req.To)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // DeleteChargerSessions deletes all sessions for a specific charger
@@ -25,6 +25,7 @@ type CustomLineItem struct {
 	Amount      float64 `json:"amount"`
 	Frequency   string  `json:"frequency"`
 	Category    string  `json:"category"`
+	VATCode     string  `json:"vat_code"` // empty: the code assigned to the category
 	IsActive    bool    `json:"is_active"`
 	CreatedAt   string  `json:"created_at"`
 	UpdatedAt   string  `json:"updated_at"`
@@ -35,7 +36,7 @@ func (h *CustomItemHandler) List(w http.ResponseWriter, r *http.Request) {
 	includeInactive := r.URL.Query().Get("include_inactive") == "true"
 
 	query := `
-		SELECT id, building_id, description, amount, frequency, category, is_active, created_at, updated_at
+		SELECT id, building_id, description, amount, frequency, category, vat_code, is_active, created_at, updated_at
 		FROM custom_line_items
 		WHERE 1=1
 	`
@@ -66,7 +67,7 @@ func (h *CustomItemHandler) List(w http.ResponseWriter, r *http.Request) {
 		var isActive int
 		err := rows.Scan(
 			&item.ID, &item.BuildingID, &item.Description, &item.Amount,
-			&item.Frequency, &item.Category, &isActive, &item.CreatedAt, &item.UpdatedAt,
+			&item.Frequency, &item.Category, &item.VATCode, &isActive, &item.CreatedAt, &item.UpdatedAt,
 		)
 		if err == nil {
 			item.IsActive = isActive == 1
@@ -91,12 +92,12 @@ func (h *CustomItemHandler) Get(w http.ResponseWriter, r *http.Request) {
 	var item CustomLineItem
 	var isActive int
 	err = h.db.QueryRow(`
-		SELECT id, building_id, description, amount, frequency, category, is_active, created_at, updated_at
+		SELECT id, building_id, description, amount, frequency, category, vat_code, is_active, created_at, updated_at
 		FROM custom_line_items
 		WHERE id = ?
 	`, id).Scan(
 		&item.ID, &item.BuildingID, &item.Description, &item.Amount,
-		&item.Frequency, &item.Category, &isActive, &item.CreatedAt, &item.UpdatedAt,
+		&item.Frequency, &item.Category, &item.VATCode, &isActive, &item.CreatedAt, &item.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -146,6 +147,10 @@ func (h *CustomItemHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid category. Must be: meter_rent, maintenance, service, or other", http.StatusBadRequest)
 		return
 	}
+	if item.VATCode != "" && !vatCodeExists(h.db, item.VATCode) {
+		http.Error(w, "Unknown VAT code", http.StatusBadRequest)
+		return
+	}
 
 	// Set default values
 	if item.Frequency == "" {
@@ -162,9 +167,9 @@ func (h *CustomItemHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.db.Exec(`
 		INSERT INTO custom_line_items (
-			building_id, description, amount, frequency, category, is_active
-		) VALUES (?, ?, ?, ?, ?, ?)
-	`, item.BuildingID, item.Description, item.Amount, item.Frequency, item.Category, isActive)
+			building_id, description, amount, frequency, category, vat_code, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, item.BuildingID, item.Description, item.Amount, item.Frequency, item.Category, item.VATCode, isActive)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create custom line item: %v", err)
@@ -220,6 +225,10 @@ func (h *CustomItemHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid category. Must be: meter_rent, maintenance, service, or other", http.StatusBadRequest)
 		return
 	}
+	if item.VATCode != "" && !vatCodeExists(h.db, item.VATCode) {
+		http.Error(w, "Unknown VAT code", http.StatusBadRequest)
+		return
+	}
 
 	isActive := 0
 	if item.IsActive {
@@ -229,10 +238,10 @@ func (h *CustomItemHandler) Update(w http.ResponseWriter, r *http.Request) {
 	_, err = h.db.Exec(`
 		UPDATE custom_line_items SET
 			building_id = ?, description = ?, amount = ?, 
-			frequency = ?, category = ?, is_active = ?,
+			frequency = ?, category = ?, vat_code = ?, is_active = ?,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
-	`, item.BuildingID, item.Description, item.Amount, item.Frequency, item.Category, isActive, id)
+	`, item.BuildingID, item.Description, item.Amount, item.Frequency, item.Category, item.VATCode, isActive, id)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to update custom line item: %v", err)
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// DemandTariffHandler manages peak-demand (kW) tariffs and previews the peak a
+// tariff would bill.
+type DemandTariffHandler struct {
+	db *sql.DB
+}
+
+func NewDemandTariffHandler(db *sql.DB) *DemandTariffHandler {
+	return &DemandTariffHandler{db: db}
+}
+
+const demandTariffColumns = `id, building_id, user_id, meter_id, name, price_per_kw, peak_count,
+	valid_from, valid_to, is_active, created_at, updated_at`
+
+func scanDemandTariff(scan func(dest ...interface{}) error) (models.DemandTariff, error) {
+	var t models.DemandTariff
+	var userID, meterID sql.NullInt64
+	err := scan(&t.ID, &t.BuildingID, &userID, &meterID, &t.Name, &t.PricePerKW, &t.PeakCount,
+		&t.ValidFrom, &t.ValidTo, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+	if userID.Valid {
+		v := int(userID.Int64)
+		t.UserID = &v
+	}
+	if meterID.Valid {
+		v := int(meterID.Int64)
+		t.MeterID = &v
+	}
+	return t, err
+}
+
+// validateDemandTariff checks that a tariff applies to exactly one user or
+// meter of its building, with 1–96 peaks averaged and a valid date range.
+func (h *DemandTariffHandler) validateDemandTariff(t *models.DemandTariff) string {
+	t.Name = strings.TrimSpace(t.Name)
+	t.ValidFrom = strings.TrimSpace(t.ValidFrom)
+	t.ValidTo = strings.TrimSpace(t.ValidTo)
+	if t.PeakCount == 0 {
+		t.PeakCount = 1
+	}
+	if t.BuildingID == 0 {
+		return "building_id is required"
+	}
+	if (t.UserID == nil) == (t.MeterID == nil) {
+		return "set either user_id or meter_id"
+	}
+	if t.PricePerKW < 0 {
+		return "price_per_kw must not be negative"
+	}
+	if t.PeakCount < 1 || t.PeakCount > 96 {
+		return "peak_count must be between 1 and 96"
+	}
+	if _, err := time.Parse("2006-01-02", t.ValidFrom); err != nil {
+		return "Invalid valid_from format. Use YYYY-MM-DD"
+	}
+	if t.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", t.ValidTo); err != nil {
+			return "Invalid valid_to format. Use YYYY-MM-DD"
+		}
+		if t.ValidTo < t.ValidFrom {
+			return "valid_to must not be before valid_from"
+		}
+	}
+
+	var count int
+	if t.MeterID != nil {
+		h.db.QueryRow(`SELECT COUNT(*) FROM meters WHERE id = ? AND building_id = ?`, *t.MeterID, t.BuildingID).Scan(&count)
+		if count == 0 {
+			return "meter not found in this building"
+		}
+	} else {
+		h.db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ? AND building_id = ?`, *t.UserID, t.BuildingID).Scan(&count)
+		if count == 0 {
+			return "user not found in this building"
+		}
+	}
+	return ""
+}
+
+func (h *DemandTariffHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := `SELECT ` + demandTariffColumns + ` FROM demand_tariffs WHERE 1=1`
+	args := []interface{}{}
+	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY building_id, valid_from, id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query demand tariffs: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	tariffs := []models.DemandTariff{}
+	for rows.Next() {
+		if t, err := scanDemandTariff(rows.Scan); err == nil {
+			tariffs = append(tariffs, t)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tariffs)
+}
+
+func (h *DemandTariffHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var t models.DemandTariff
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		log.Printf("ERROR: Failed to decode demand tariff: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validateDemandTariff(&t); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO demand_tariffs (
+			building_id, user_id, meter_id, name, price_per_kw, peak_count,
+			valid_from, valid_to, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.BuildingID, t.UserID, t.MeterID, t.Name, t.PricePerKW, t.PeakCount,
+		t.ValidFrom, t.ValidTo, t.IsActive)
+	if err != nil {
+		log.Printf("ERROR: Failed to create demand tariff: %v", err)
+		http.Error(w, "Failed to create demand tariff", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	t.ID = int(id)
+	log.Printf("SUCCESS: Created demand tariff ID %d for building %d", t.ID, t.BuildingID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *DemandTariffHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var t models.DemandTariff
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		log.Printf("ERROR: Failed to decode demand tariff: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validateDemandTariff(&t); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE demand_tariffs SET
+			building_id = ?, user_id = ?, meter_id = ?, name = ?, price_per_kw = ?, peak_count = ?,
+			valid_from = ?, valid_to = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, t.BuildingID, t.UserID, t.MeterID, t.Name, t.PricePerKW, t.PeakCount,
+		t.ValidFrom, t.ValidTo, t.IsActive, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update demand tariff %d: %v", id, err)
+		http.Error(w, "Failed to update demand tariff", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Demand tariff not found", http.StatusNotFound)
+		return
+	}
+
+	t.ID = id
+	log.Printf("SUCCESS: Updated demand tariff ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *DemandTariffHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM demand_tariffs WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete demand tariff %d: %v", id, err)
+		http.Error(w, "Failed to delete demand tariff", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted demand tariff ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Peak returns the peak demand a tariff measures over a date range
+// (?start_date=YYYY-MM-DD&end_date=YYYY-MM-DD, end inclusive).
+func (h *DemandTariffHandler) Peak(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	start, err1 := time.Parse("2006-01-02", r.URL.Query().Get("start_date"))
+	end, err2 := time.Parse("2006-01-02", r.URL.Query().Get("end_date"))
+	if err1 != nil || err2 != nil || end.Before(start) {
+		http.Error(w, "Invalid start_date/end_date. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	t, err := scanDemandTariff(h.db.QueryRow(`SELECT `+demandTariffColumns+` FROM demand_tariffs WHERE id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Demand tariff not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to load demand tariff %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	peak, err := services.ComputeDemandTariffPeak(h.db, t, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("ERROR: Failed to compute peak demand for tariff %d: %v", id, err)
+		http.Error(w, "Failed to compute peak demand", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(peak)
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// DirectDebitHandler manages direct-debit mandates and the pain.008
+// collection files built from them.
+type DirectDebitHandler struct {
+	db *sql.DB
+}
+
+func NewDirectDebitHandler(db *sql.DB) *DirectDebitHandler {
+	return &DirectDebitHandler{db: db}
+}
+
+func (h *DirectDebitHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// validateMandate checks a mandate reference: at most 35 characters, the
+// limit of the debit file formats. The scheme defaults to LSV+ and the status
+// to active.
+func validateMandate(m *models.DirectDebitMandate) string {
+	m.MandateID = strings.TrimSpace(m.MandateID)
+	if m.UserID == 0 || m.MandateID == "" {
+		return "user_id and mandate_id are required"
+	}
+	if len(m.MandateID) > 35 {
+		return "mandate_id must not exceed 35 characters"
+	}
+	m.Scheme = strings.ToLower(strings.TrimSpace(m.Scheme))
+	if m.Scheme == "" {
+		m.Scheme = "lsv"
+	}
+	if !services.ValidDebitScheme(m.Scheme) {
+		return "scheme must be lsv, chdd or sepa"
+	}
+	if _, err := time.Parse("2006-01-02", m.SignatureDate); err != nil {
+		return "Invalid signature_date format. Use YYYY-MM-DD"
+	}
+	if m.Status == "" {
+		m.Status = services.MandateActive
+	}
+	if m.Status != services.MandateActive && m.Status != services.MandateRevoked {
+		return "status must be active or revoked"
+	}
+	return ""
+}
+
+func (h *DirectDebitHandler) ListMandates(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT m.id, m.user_id, COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''),
+		       m.mandate_id, m.scheme, date(m.signature_date), m.status, m.notes, m.created_at, m.updated_at
+		FROM direct_debit_mandates m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		query += " AND m.user_id = ?"
+		args = append(args, userID)
+	}
+	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
+		query += " AND u.building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY m.user_id, m.signature_date DESC"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query mandates: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	mandates := []models.DirectDebitMandate{}
+	for rows.Next() {
+		var m models.DirectDebitMandate
+		if err := rows.Scan(&m.ID, &m.UserID, &m.UserName, &m.MandateID, &m.Scheme, &m.SignatureDate,
+			&m.Status, &m.Notes, &m.CreatedAt, &m.UpdatedAt); err == nil {
+			m.UserName = strings.TrimSpace(m.UserName)
+			mandates = append(mandates, m)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mandates)
+}
+
+func (h *DirectDebitHandler) CreateMandate(w http.ResponseWriter, r *http.Request) {
+	var m models.DirectDebitMandate
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		log.Printf("ERROR: Failed to decode mandate: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateMandate(&m); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO direct_debit_mandates (user_id, mandate_id, scheme, signature_date, status, notes)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, m.UserID, m.MandateID, m.Scheme, m.SignatureDate, m.Status, m.Notes)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "A mandate with this mandate_id already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("ERROR: Failed to create mandate: %v", err)
+		http.Error(w, "Failed to create mandate", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	m.ID = int(id)
+	log.Printf("SUCCESS: Created %s mandate %s for user %d", m.Scheme, m.MandateID, m.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+func (h *DirectDebitHandler) UpdateMandate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var m models.DirectDebitMandate
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateMandate(&m); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE direct_debit_mandates SET
+			user_id = ?, mandate_id = ?, scheme = ?, signature_date = ?, status = ?, notes = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, m.UserID, m.MandateID, m.Scheme, m.SignatureDate, m.Status, m.Notes, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "A mandate with this mandate_id already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("ERROR: Failed to update mandate %d: %v", id, err)
+		http.Error(w, "Failed to update mandate", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Mandate not found", http.StatusNotFound)
+		return
+	}
+
+	m.ID = id
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+func (h *DirectDebitHandler) DeleteMandate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM direct_debit_mandates WHERE id = ?", id)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete mandate %d: %v", id, err)
+		http.Error(w, "Failed to delete mandate", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Mandate not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DirectDebitHandler) ListExports(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT id, message_id, scheme, date(collection_date), creditor_name, creditor_iban, creditor_id,
+		       currency, transactions, total_amount, created_at
+		FROM direct_debit_exports ORDER BY created_at DESC, id DESC
+	`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query direct debit exports: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	exports := []models.DirectDebitExport{}
+	index := map[int]int{}
+	for rows.Next() {
+		var e models.DirectDebitExport
+		if err := rows.Scan(&e.ID, &e.MessageID, &e.Scheme, &e.CollectionDate, &e.CreditorName, &e.CreditorIBAN,
+			&e.CreditorID, &e.Currency, &e.Transactions, &e.TotalAmount, &e.CreatedAt); err == nil {
+			e.InvoiceIDs = []int{}
+			index[e.ID] = len(exports)
+			exports = append(exports, e)
+		}
+	}
+	rows.Close()
+
+	invRows, err := h.db.Query(`SELECT id, direct_debit_export_id FROM invoices WHERE direct_debit_export_id IS NOT NULL ORDER BY id`)
+	if err == nil {
+		defer invRows.Close()
+		for invRows.Next() {
+			var invoiceID, exportID int
+			if err := invRows.Scan(&invoiceID, &exportID); err == nil {
+				if i, ok := index[exportID]; ok {
+					exports[i].InvoiceIDs = append(exports[i].InvoiceIDs, invoiceID)
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exports)
+}
+
+type CreateDirectDebitRequest struct {
+	InvoiceIDs     []int  `json:"invoice_ids"`
+	Scheme         string `json:"scheme"`
+	CollectionDate string `json:"collection_date"`
+	CreditorID     string `json:"creditor_id"`
+	DocumentParties
+}
+
+// CreateExport builds a pain.008 file from the selected invoices. The file is
+// stored and can be downloaded with DownloadExport.
+func (h *DirectDebitHandler) CreateExport(w http.ResponseWriter, r *http.Request) {
+	var req CreateDirectDebitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.InvoiceIDs) == 0 {
+		http.Error(w, "invoice_ids are required", http.StatusBadRequest)
+		return
+	}
+	if req.Scheme == "" {
+		req.Scheme = "lsv"
+	}
+	creditorName := req.BankAccountHolder
+	if creditorName == "" {
+		creditorName = req.SenderName
+	}
+
+	export, skipped, err := services.CreateDirectDebitExport(h.db, services.DirectDebitRequest{
+		InvoiceIDs:     req.InvoiceIDs,
+		Scheme:         strings.ToLower(req.Scheme),
+		CollectionDate: req.CollectionDate,
+		CreditorName:   creditorName,
+		CreditorIBAN:   req.BankIBAN,
+		CreditorID:     req.CreditorID,
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to create direct debit export: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   err.Error(),
+			"skipped": skipped,
+		})
+		return
+	}
+
+	h.logToDatabase("Direct Debit Exported",
+		fmt.Sprintf("%s: %d invoices, %s %.2f, collection on %s", export.MessageID, export.Transactions,
+			export.Currency, export.TotalAmount, export.CollectionDate), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"export":  export,
+		"skipped": skipped,
+	})
+}
+
+func (h *DirectDebitHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var messageID, content string
+	err = h.db.QueryRow(`SELECT message_id, xml FROM direct_debit_exports WHERE id = ?`, id).Scan(&messageID, &content)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.xml\"", messageID))
+	w.Write([]byte(content))
+}
+
+// Confirm books the pending invoices of a file (all, or the given
+// invoice_ids) as collected.
+func (h *DirectDebitHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, true)
+}
+
+// Reject returns the pending invoices of a file (all, or the given
+// invoice_ids) to unpaid, e.g. after a debit was refused by the bank.
+func (h *DirectDebitHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.settle(w, r, false)
+}
+
+func (h *DirectDebitHandler) settle(w http.ResponseWriter, r *http.Request, collected bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		InvoiceIDs []int `json:"invoice_ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	n, err := services.SettleDirectDebit(h.db, id, req.InvoiceIDs, collected)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to settle direct debit export %d: %v", id, err)
+		http.Error(w, "Failed to update invoices", http.StatusInternalServerError)
+		return
+	}
+
+	action := "Direct Debit Rejected"
+	if collected {
+		action = "Direct Debit Confirmed"
+	}
+	h.logToDatabase(action, fmt.Sprintf("Export #%d: %d invoices", id, n), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"export_id": id,
+		"updated":   n,
+	})
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+type DunningHandler struct {
+	db             *sql.DB
+	dunningService *services.DunningService
+}
+
+func NewDunningHandler(db *sql.DB, dunningService *services.DunningService) *DunningHandler {
+	return &DunningHandler{
+		db:             db,
+		dunningService: dunningService,
+	}
+}
+
+// GetSettings returns the payment terms and reminder schedule of a building,
+// or the defaults when none are saved.
+func (h *DunningHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	buildingID, err := strconv.Atoi(r.URL.Query().Get("building_id"))
+	if err != nil {
+		http.Error(w, "building_id is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.LoadDunningSettings(h.db, buildingID))
+}
+
+func (h *DunningHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var s models.DunningSettings
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		log.Printf("ERROR: Failed to decode dunning settings: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if s.BuildingID == 0 {
+		http.Error(w, "building_id is required", http.StatusBadRequest)
+		return
+	}
+	if s.PaymentTermsDays <= 0 || s.Reminder1AfterDays < 0 || s.Reminder2AfterDays < 0 ||
+		s.FinalNoticeAfterDays < 0 || s.ReminderDeadlineDays <= 0 {
+		http.Error(w, "Payment terms and reminder deadlines must be positive", http.StatusBadRequest)
+		return
+	}
+	if s.Reminder1Fee < 0 || s.Reminder2Fee < 0 || s.FinalNoticeFee < 0 {
+		http.Error(w, "Reminder fees must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.db.Exec(`
+		INSERT INTO dunning_settings (
+			building_id, payment_terms_days, reminder1_after_days, reminder2_after_days,
+			final_notice_after_days, reminder_deadline_days, reminder1_fee, reminder2_fee,
+			final_notice_fee, auto_escalate, auto_send_email, sender_name, sender_address,
+			sender_city, sender_zip, sender_country, bank_name, bank_iban, bank_account_holder
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(building_id) DO UPDATE SET
+			payment_terms_days = excluded.payment_terms_days,
+			reminder1_after_days = excluded.reminder1_after_days,
+			reminder2_after_days = excluded.reminder2_after_days,
+			final_notice_after_days = excluded.final_notice_after_days,
+			reminder_deadline_days = excluded.reminder_deadline_days,
+			reminder1_fee = excluded.reminder1_fee,
+			reminder2_fee = excluded.reminder2_fee,
+			final_notice_fee = excluded.final_notice_fee,
+			auto_escalate = excluded.auto_escalate,
+			auto_send_email = excluded.auto_send_email,
+			sender_name = excluded.sender_name,
+			sender_address = excluded.sender_address,
+			sender_city = excluded.sender_city,
+			sender_zip = excluded.sender_zip,
+			sender_country = excluded.sender_country,
+			bank_name = excluded.bank_name,
+			bank_iban = excluded.bank_iban,
+			bank_account_holder = excluded.bank_account_holder,
+			updated_at = CURRENT_TIMESTAMP
+	`, s.BuildingID, s.PaymentTermsDays, s.Reminder1AfterDays, s.Reminder2AfterDays,
+		s.FinalNoticeAfterDays, s.ReminderDeadlineDays, s.Reminder1Fee, s.Reminder2Fee,
+		s.FinalNoticeFee, s.AutoEscalate, s.AutoSendEmail, s.SenderName, s.SenderAddress,
+		s.SenderCity, s.SenderZip, s.SenderCountry, s.BankName, s.BankIBAN, s.BankAccountHolder)
+	if err != nil {
+		log.Printf("ERROR: Failed to save dunning settings: %v", err)
+		http.Error(w, "Failed to save dunning settings", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Saved dunning settings for building %d (%d days payment terms)", s.BuildingID, s.PaymentTermsDays)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.LoadDunningSettings(h.db, s.BuildingID))
+}
+
+// Overview lists all outstanding documents with their dunning state.
+func (h *DunningHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	buildingID := 0
+	if v := r.URL.Query().Get("building_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid building_id", http.StatusBadRequest)
+			return
+		}
+		buildingID = id
+	}
+	overdueOnly := r.URL.Query().Get("overdue_only") == "true"
+
+	outstanding, err := services.ListOutstanding(h.db, buildingID, time.Now(), overdueOnly)
+	if err != nil {
+		log.Printf("ERROR: Failed to list outstanding invoices: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outstanding)
+}
+
+// Run escalates every overdue invoice that is due for its next reminder,
+// the same as the daily background run.
+func (h *DunningHandler) Run(w http.ResponseWriter, r *http.Request) {
+	result := h.dunningService.RunOnce(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *DunningHandler) ListReminders(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	reminders, err := services.LoadReminders(h.db, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to load reminders for invoice %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminders)
+}
+
+type IssueReminderRequest struct {
+	SendEmail bool `json:"send_email"`
+	DocumentParties
+}
+
+// IssueReminder sends the next reminder level for an invoice right away,
+// regardless of the escalation schedule.
+func (h *DunningHandler) IssueReminder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req IssueReminderRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var status, documentType, paymentStatus string
+	var level int
+	err = h.db.QueryRow(`
+		SELECT status, COALESCE(document_type, 'invoice'), COALESCE(payment_status, 'unpaid'),
+		       COALESCE(dunning_level, 0)
+		FROM invoices WHERE id = ?
+	`, id).Scan(&status, &documentType, &paymentStatus, &level)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if status != services.InvoiceStatusIssued || services.IsCreditDocument(documentType) {
+		http.Error(w, fmt.Sprintf("Cannot send a reminder for a %s %s", status, documentType), http.StatusConflict)
+		return
+	}
+	if paymentStatus == "paid" {
+		http.Error(w, "Invoice is already paid", http.StatusConflict)
+		return
+	}
+	if level >= services.DunningFinalNotice {
+		http.Error(w, "The final notice has already been sent", http.StatusConflict)
+		return
+	}
+
+	var sender *services.SenderInfo
+	if req.SenderName != "" {
+		s := req.senderInfo()
+		sender = &s
+	}
+	var banking *services.BankingInfo
+	if req.BankIBAN != "" {
+		b := req.bankingInfo()
+		banking = &b
+	}
+
+	reminder, err := h.dunningService.IssueReminder(id, sender, banking, req.SendEmail, time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to issue reminder for invoice %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Issued reminder %s (level %d) for invoice %d", reminder.ReminderNumber, reminder.Level, id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reminder)
+}
+
+func (h *DunningHandler) DownloadReminderPDF(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var reminderNumber string
+	var pdfPath sql.NullString
+	err = h.db.QueryRow(`SELECT reminder_number, pdf_path FROM invoice_reminders WHERE id = ?`, id).Scan(&reminderNumber, &pdfPath)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Reminder not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	filePath := resolveInvoicePDFPath(pdfPath, reminderNumber)
+	if filePath == "" {
+		http.Error(w, "PDF not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s.pdf", reminderNumber))
+	http.ServeFile(w, r, filePath)
+}
@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/aj9599/zev-billing/backend/models"
 	"github.com/aj9599/zev-billing/backend/services"
 )
 
@@ -51,6 +55,12 @@ func (h *ExportHandler) ExportData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Accounting journals are written in the accounting software's own format.
+	if format, ok := strings.CutPrefix(exportType, "journal-"); ok {
+		h.exportJournal(w, format, startDate, endDate)
+		return
+	}
+
 	var data [][]string
 	var err error
 
@@ -73,9 +83,11 @@ func (h *ExportHandler) ExportData(w http.ResponseWriter, r *http.Request) {
 		data, err = h.exportBuildingSummary(startDate, endDate)
 	case "vat-summary":
 		data, err = h.exportVATSummary(startDate, endDate)
+	case "invoices":
+		data, err = h.exportInvoiceRegister(startDate, endDate)
 	default:
 		log.Printf("Invalid export type: %s", exportType)
-		http.Error(w, "Invalid export type. Must be 'meters', 'chargers', 'building-summary' or 'vat-summary'", http.StatusBadRequest)
+		http.Error(w, "Invalid export type. Must be 'meters', 'chargers', 'building-summary', 'vat-summary', 'invoices', 'journal-bexio', 'journal-abacus' or 'journal-datev'", http.StatusBadRequest)
 		return
 	}
 
@@ -355,7 +367,7 @@ func (h *ExportHandler) exportBuildingSummary(startDate, endDate string) ([][]st
 		       SUM(CASE WHEN COALESCE(i.payment_status,'unpaid') = 'paid' THEN 0 ELSE i.total_amount END) AS outstanding
 		FROM invoices i
 		LEFT JOIN buildings b ON b.id = i.building_id
-		WHERE date(i.generated_at) BETWEEN ? AND ?
+		WHERE date(i.generated_at) BETWEEN ? AND ? AND i.status != 'draft'
 		GROUP BY i.building_id, i.currency
 		ORDER BY building, i.currency
 	`, startDate, endDate)
@@ -381,40 +393,285 @@ func (h *ExportHandler) exportBuildingSummary(startDate, endDate string) ([][]st
 	return data, rows.Err()
 }
 
+// exportJournal writes the accounting journal of the date range as a Bexio
+// CSV, an Abacus XML or a DATEV ASCII file.
+func (h *ExportHandler) exportJournal(w http.ResponseWriter, format, startDate, endDate string) {
+	var contentType, filename string
+	switch format {
+	case services.JournalFormatBexio:
+		contentType, filename = "text/csv; charset=utf-8", fmt.Sprintf("journal-bexio-%s-to-%s.csv", startDate, endDate)
+	case services.JournalFormatAbacus:
+		contentType, filename = "application/xml; charset=utf-8", fmt.Sprintf("journal-abacus-%s-to-%s.xml", startDate, endDate)
+	case services.JournalFormatDATEV:
+		contentType, filename = "text/csv; charset=windows-1252", fmt.Sprintf("EXTF_Buchungsstapel_%s_%s.csv", startDate, endDate)
+	default:
+		http.Error(w, "Invalid journal format. Must be 'journal-bexio', 'journal-abacus' or 'journal-datev'", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := services.LoadAccountingSettings(h.db)
+	if err != nil {
+		log.Printf("Export error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to export data: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if format == services.JournalFormatDATEV {
+		if _, err := services.DATEVFiscalYear(settings, startDate, endDate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := services.BuildJournal(h.db, startDate, endDate)
+	if err != nil {
+		log.Printf("Export error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to export data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case services.JournalFormatBexio:
+		err = services.WriteBexioCSV(&buf, entries)
+	case services.JournalFormatAbacus:
+		err = services.WriteAbacusXML(&buf, entries)
+	case services.JournalFormatDATEV:
+		err = services.WriteDATEV(&buf, entries, settings, startDate, endDate, time.Now())
+	}
+	if err != nil {
+		log.Printf("Export error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to export data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+	log.Printf("Export completed successfully: %s (%d journal entries)", filename, len(entries))
+}
+
+// GetAccountingSettings returns the ledger accounts and DATEV numbers of the
+// accounting journal export.
+func (h *ExportHandler) GetAccountingSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := services.LoadAccountingSettings(h.db)
+	if err != nil {
+		log.Printf("ERROR: Failed to load accounting settings: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	list := []models.AccountingSetting{}
+	for key, value := range settings {
+		list = append(list, models.AccountingSetting{Key: key, Value: value})
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Key < list[b].Key })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateAccountingSettings stores the settings in the body; keys not listed
+// keep their value.
+func (h *ExportHandler) UpdateAccountingSettings(w http.ResponseWriter, r *http.Request) {
+	var list []models.AccountingSetting
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		log.Printf("ERROR: Failed to decode accounting settings: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	for i := range list {
+		list[i].Value = strings.TrimSpace(list[i].Value)
+		if !services.ValidAccountingSettingKey(list[i].Key) {
+			http.Error(w, fmt.Sprintf("Unknown accounting setting %q", list[i].Key), http.StatusBadRequest)
+			return
+		}
+		if list[i].Key == services.SettingDATEVFiscalYearStart {
+			if err := services.ValidateDATEVFiscalYearStart(list[i].Value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, s := range list {
+		if _, err := tx.Exec(`
+			INSERT INTO accounting_settings (setting_key, value) VALUES (?, ?)
+			ON CONFLICT(setting_key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+		`, s.Key, s.Value); err != nil {
+			log.Printf("ERROR: Failed to save accounting setting %s: %v", s.Key, err)
+			http.Error(w, "Failed to save accounting settings", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to save accounting settings", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Updated %d accounting settings", len(list))
+	h.GetAccountingSettings(w, r)
+}
+
 // exportVATSummary aggregates invoices issued in the date range per building,
-// currency and VAT rate — the breakdown an accountant needs for VAT filing.
+// currency, VAT code and rate — the breakdown an accountant needs for VAT
+// filing. Each invoice is split by the VAT codes of its lines.
 func (h *ExportHandler) exportVATSummary(startDate, endDate string) ([][]string, error) {
 	rows, err := h.db.Query(`
-		SELECT COALESCE(b.name, 'Building #' || i.building_id) AS building,
+		SELECT i.id, COALESCE(b.name, 'Building #' || i.building_id) AS building,
+		       i.currency, i.total_amount, COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0),
+		       COALESCE(i.vat_rate, 0), COALESCE(i.vat_included, 0)
+		FROM invoices i
+		LEFT JOIN buildings b ON b.id = i.building_id
+		WHERE date(i.generated_at) BETWEEN ? AND ? AND i.status != 'draft'
+	`, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	type invoiceRef struct {
+		building string
+		inv      models.Invoice
+	}
+	var invoices []*invoiceRef
+	byID := map[int]*invoiceRef{}
+	for rows.Next() {
+		ref := &invoiceRef{}
+		if err := rows.Scan(&ref.inv.ID, &ref.building, &ref.inv.Currency, &ref.inv.TotalAmount, &ref.inv.NetAmount,
+			&ref.inv.VATAmount, &ref.inv.VATRate, &ref.inv.VATIncluded); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Invoices from before the VAT columns carry no net amount.
+		if ref.inv.NetAmount == 0 && ref.inv.VATAmount == 0 {
+			ref.inv.NetAmount = ref.inv.TotalAmount
+		}
+		invoices = append(invoices, ref)
+		byID[ref.inv.ID] = ref
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemRows, err := h.db.Query(`
+		SELECT it.invoice_id, it.total_price, it.vat_code, it.vat_rate
+		FROM invoice_items it
+		JOIN invoices i ON i.id = it.invoice_id
+		WHERE date(i.generated_at) BETWEEN ? AND ? AND i.status != 'draft'
+		  AND it.vat_code != '' AND it.total_price != 0
+	`, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	for itemRows.Next() {
+		var item models.InvoiceItem
+		if err := itemRows.Scan(&item.InvoiceID, &item.TotalPrice, &item.VATCode, &item.VATRate); err != nil {
+			itemRows.Close()
+			return nil, err
+		}
+		if ref := byID[item.InvoiceID]; ref != nil {
+			ref.inv.Items = append(ref.inv.Items, item)
+		}
+	}
+	itemRows.Close()
+	if err := itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	type groupKey struct {
+		building, currency, code string
+		rate                     float64
+	}
+	type group struct {
+		invoices        int
+		net, vat, gross float64
+	}
+	groups := map[groupKey]*group{}
+	var keys []groupKey
+	for _, ref := range invoices {
+		for _, l := range services.VATSummary(ref.inv) {
+			k := groupKey{ref.building, ref.inv.Currency, l.Code, l.Rate}
+			g := groups[k]
+			if g == nil {
+				g = &group{}
+				groups[k] = g
+				keys = append(keys, k)
+			}
+			g.invoices++
+			g.net += l.Net
+			g.vat += l.VAT
+			g.gross += l.Gross
+		}
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		ka, kb := keys[a], keys[b]
+		if ka.building != kb.building {
+			return ka.building < kb.building
+		}
+		if ka.currency != kb.currency {
+			return ka.currency < kb.currency
+		}
+		if ka.rate != kb.rate {
+			return ka.rate < kb.rate
+		}
+		return ka.code < kb.code
+	})
+
+	data := [][]string{{"Building", "Currency", "VAT Code", "VAT Rate %", "Invoices", "Net", "VAT", "Gross"}}
+	for _, k := range keys {
+		g := groups[k]
+		data = append(data, []string{
+			k.building, k.currency, k.code, fmt.Sprintf("%.1f", k.rate), strconv.Itoa(g.invoices),
+			fmt.Sprintf("%.2f", g.net), fmt.Sprintf("%.2f", g.vat), fmt.Sprintf("%.2f", g.gross),
+		})
+	}
+	return data, nil
+}
+
+// exportInvoiceRegister lists every document issued in the date range —
+// invoices, credit notes and replacements — with the invoice it reverses or
+// corrects, so the cancellation chain is visible to the accountant.
+func (h *ExportHandler) exportInvoiceRegister(startDate, endDate string) ([][]string, error) {
+	rows, err := h.db.Query(`
+		SELECT i.invoice_number,
+		       COALESCE(i.document_type, 'invoice'),
+		       i.status,
+		       COALESCE(o.invoice_number, ''),
+		       COALESCE(b.name, 'Building #' || i.building_id) AS building,
+		       COALESCE(u.first_name || ' ' || u.last_name, 'User #' || i.user_id) AS user_name,
+		       substr(i.period_start, 1, 10), substr(i.period_end, 1, 10),
 		       i.currency,
-		       COALESCE(i.vat_rate, 0) AS vat_rate,
-		       COUNT(*) AS invoices,
-		       SUM(COALESCE(i.net_amount, 0)) AS net,
-		       SUM(COALESCE(i.vat_amount, 0)) AS vat,
-		       SUM(i.total_amount) AS gross
+		       COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0), i.total_amount,
+		       substr(COALESCE(i.cancelled_at, ''), 1, 10), COALESCE(i.cancellation_reason, '')
 		FROM invoices i
+		LEFT JOIN invoices o ON o.id = i.original_invoice_id
 		LEFT JOIN buildings b ON b.id = i.building_id
-		WHERE date(i.generated_at) BETWEEN ? AND ?
-		GROUP BY i.building_id, i.currency, i.vat_rate
-		ORDER BY building, i.currency, vat_rate
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE date(i.generated_at) BETWEEN ? AND ? AND i.status != 'draft'
+		ORDER BY i.generated_at, i.id
 	`, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	data := [][]string{{"Building", "Currency", "VAT Rate %", "Invoices", "Net", "VAT", "Gross"}}
+	data := [][]string{{"Number", "Document Type", "Status", "Original Invoice", "Building", "User",
+		"Period Start", "Period End", "Currency", "Net", "VAT", "Gross", "Cancelled At", "Cancellation Reason"}}
 	for rows.Next() {
-		var building, currency string
-		var vatRate float64
-		var count int
+		var number, docType, status, original, building, user, periodStart, periodEnd, currency, cancelledAt, reason string
 		var net, vat, gross float64
-		if err := rows.Scan(&building, &currency, &vatRate, &count, &net, &vat, &gross); err != nil {
+		if err := rows.Scan(&number, &docType, &status, &original, &building, &user, &periodStart, &periodEnd,
+			&currency, &net, &vat, &gross, &cancelledAt, &reason); err != nil {
 			return nil, err
 		}
 		data = append(data, []string{
-			building, currency, fmt.Sprintf("%.1f", vatRate), strconv.Itoa(count),
+			number, docType, status, original, building, user, periodStart, periodEnd, currency,
 			fmt.Sprintf("%.2f", net), fmt.Sprintf("%.2f", vat), fmt.Sprintf("%.2f", gross),
+			cancelledAt, reason,
 		})
 	}
 	return data, rows.Err()
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// InvoiceArchiveHandler exposes the tamper-evident invoice archive: the
+// archive entry of a document, archiving documents issued before the archive
+// existed, and verifying the whole archive.
+type InvoiceArchiveHandler struct {
+	db *sql.DB
+}
+
+func NewInvoiceArchiveHandler(db *sql.DB) *InvoiceArchiveHandler {
+	return &InvoiceArchiveHandler{db: db}
+}
+
+func (h *InvoiceArchiveHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// Get returns the archive entry of a document.
+func (h *InvoiceArchiveHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := services.NewInvoiceArchive(h.db).Entry(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice is not archived", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to load archive entry of invoice %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// Archive archives the current PDF of an issued document that has no archive
+// entry yet, typically one issued before the archive existed. Archived
+// documents are returned unchanged.
+func (h *InvoiceArchiveHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var pdfPath sql.NullString
+	err = h.db.QueryRow(`SELECT pdf_path FROM invoices WHERE id = ?`, id).Scan(&pdfPath)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	entry, err := services.NewInvoiceArchive(h.db).Archive(id, pdfPath.String)
+	if err != nil {
+		log.Printf("ERROR: Failed to archive invoice %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	h.logToDatabase("Invoice Archived",
+		fmt.Sprintf("Invoice %s archived (sha256 %s)", entry.InvoiceNumber, entry.SHA256), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// Verify checks every archived document against its recorded hash and the
+// hash chain, reporting modified and missing files and broken links.
+func (h *InvoiceArchiveHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	result, err := services.NewInvoiceArchive(h.db).Verify()
+	if err != nil {
+		log.Printf("ERROR: Failed to verify invoice archive: %v", err)
+		http.Error(w, "Failed to verify invoice archive", http.StatusInternalServerError)
+		return
+	}
+	if !result.Valid {
+		log.Printf("WARNING: Invoice archive verification found %d problem(s)", len(result.Problems))
+	}
+	h.logToDatabase("Invoice Archive Verified",
+		fmt.Sprintf("%d entries checked, %d problem(s), %d unarchived", result.Checked, len(result.Problems), len(result.Unarchived)),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// InvoiceTemplateHandler manages custom invoice templates per building. A
+// template is html/template source rendered against
+// services.InvoiceTemplateData; buildings without one use the built-in
+// template. Templates apply to the HTML converter path only: the native
+// renderer always draws the built-in layout, which saves and previews warn
+// about.
+type InvoiceTemplateHandler struct {
+	db           *sql.DB
+	pdfGenerator *services.PDFGenerator
+}
+
+func NewInvoiceTemplateHandler(db *sql.DB, pdfGenerator *services.PDFGenerator) *InvoiceTemplateHandler {
+	return &InvoiceTemplateHandler{db: db, pdfGenerator: pdfGenerator}
+}
+
+func (h *InvoiceTemplateHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// Builtin returns the source of the built-in template.
+func (h *InvoiceTemplateHandler) Builtin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"content": services.BuiltinInvoiceTemplate(),
+	})
+}
+
+// Get returns the template of a building, or the built-in one with custom
+// set to false when it has none.
+func (h *InvoiceTemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	buildingID, err := strconv.Atoi(mux.Vars(r)["building_id"])
+	if err != nil {
+		http.Error(w, "Invalid building_id", http.StatusBadRequest)
+		return
+	}
+
+	var name, content, updatedAt string
+	err = h.db.QueryRow(`
+		SELECT COALESCE(name, ''), content, COALESCE(updated_at, '')
+		FROM invoice_templates WHERE building_id = ?
+	`, buildingID).Scan(&name, &content, &updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("ERROR: Failed to load invoice template for building %d: %v", buildingID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"building_id": buildingID,
+		"name":        name,
+		"content":     content,
+		"custom":      err == nil,
+		"updated_at":  updatedAt,
+	}
+	if err == sql.ErrNoRows {
+		resp["content"] = services.BuiltinInvoiceTemplate()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Upsert validates and stores the template of a building. Templates that do
+// not parse or fail on the sample invoices are rejected with the template
+// error.
+func (h *InvoiceTemplateHandler) Upsert(w http.ResponseWriter, r *http.Request) {
+	buildingID, err := strconv.Atoi(mux.Vars(r)["building_id"])
+	if err != nil {
+		http.Error(w, "Invalid building_id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, err := services.ParseInvoiceTemplate(req.Content); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var exists int
+	h.db.QueryRow(`SELECT COUNT(*) FROM buildings WHERE id = ?`, buildingID).Scan(&exists)
+	if exists == 0 {
+		http.Error(w, "Building not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = h.db.Exec(`
+		INSERT INTO invoice_templates (building_id, name, content, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(building_id) DO UPDATE SET
+			name = excluded.name,
+			content = excluded.content,
+			updated_at = CURRENT_TIMESTAMP
+	`, buildingID, req.Name, req.Content)
+	if err != nil {
+		log.Printf("ERROR: Failed to save invoice template for building %d: %v", buildingID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	h.pdfGenerator.ForgetInvoiceTemplate(buildingID)
+	log.Printf("SUCCESS: Saved invoice template for building %d", buildingID)
+	h.logToDatabase("Invoice Template Saved",
+		fmt.Sprintf("Building #%d: %s", buildingID, req.Name), getClientIP(r))
+
+	resp := map[string]interface{}{
+		"building_id": buildingID,
+		"name":        req.Name,
+		"content":     req.Content,
+		"custom":      true,
+	}
+	if h.pdfGenerator.UsesNativeRenderer() {
+		resp["warning"] = services.NativeRendererTemplateWarning
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Delete removes the template of a building, which returns to the built-in
+// one.
+func (h *InvoiceTemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	buildingID, err := strconv.Atoi(mux.Vars(r)["building_id"])
+	if err != nil {
+		http.Error(w, "Invalid building_id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM invoice_templates WHERE building_id = ?`, buildingID)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete invoice template for building %d: %v", buildingID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Invoice template not found", http.StatusNotFound)
+		return
+	}
+
+	h.pdfGenerator.ForgetInvoiceTemplate(buildingID)
+	h.logToDatabase("Invoice Template Deleted", fmt.Sprintf("Building #%d", buildingID), getClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type InvoiceTemplatePreviewRequest struct {
+	// Content is the template to preview; empty previews the building's
+	// stored template, or the built-in one.
+	Content  string `json:"content"`
+	Language string `json:"language"`
+	DocumentParties
+}
+
+// Preview renders a sample invoice of the building with a template, as HTML
+// or, with ?format=pdf, as PDF through the external converter. While the
+// native renderer is active the X-Template-Warning header says that real
+// invoices will not look like the preview.
+func (h *InvoiceTemplateHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	buildingID, err := strconv.Atoi(mux.Vars(r)["building_id"])
+	if err != nil {
+		http.Error(w, "Invalid building_id", http.StatusBadRequest)
+		return
+	}
+
+	var req InvoiceTemplatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Content == "" {
+		h.db.QueryRow(`SELECT content FROM invoice_templates WHERE building_id = ?`, buildingID).Scan(&req.Content)
+	}
+	if req.Language == "" {
+		req.Language = "de"
+	}
+
+	withPDF := r.URL.Query().Get("format") == "pdf"
+	html, pdf, err := h.pdfGenerator.RenderInvoiceTemplatePreview(req.Content, buildingID, req.Language,
+		req.senderInfo(), req.bankingInfo(), withPDF)
+	if err != nil && html == "" {
+		http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to convert invoice template preview to PDF: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to render PDF: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.pdfGenerator.UsesNativeRenderer() {
+		w.Header().Set("X-Template-Warning", services.NativeRendererTemplateWarning)
+	}
+	if withPDF {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// validateLEGCommunity requires a name, defaults the allocation method to
+// proportional and rejects negative prices and a grid fee discount outside
+// 0–100 %. Returns "" when the community can be saved.
+func validateLEGCommunity(c *models.LEGCommunity) string {
+	c.Name = strings.TrimSpace(c.Name)
+	c.DSOName = strings.TrimSpace(c.DSOName)
+	c.CommunityCode = strings.TrimSpace(c.CommunityCode)
+	if c.AllocationMethod == "" {
+		c.AllocationMethod = services.LEGAllocationProportional
+	}
+	if c.Name == "" {
+		return "name is required"
+	}
+	if !services.ValidLEGAllocationMethod(c.AllocationMethod) {
+		return "allocation_method must be proportional or equal"
+	}
+	if c.LocalEnergyPrice < 0 || c.ProducerPrice < 0 || c.GridFeePrice < 0 {
+		return "prices must not be negative"
+	}
+	if c.GridFeeDiscount < 0 || c.GridFeeDiscount > 100 {
+		return "grid_fee_discount must be between 0 and 100"
+	}
+	return ""
+}
+
+// validateLEGParticipant checks a participant against its meter: a consumer
+// is an apartment meter, a producer a solar meter. The building is taken from
+// the meter.
+func (h *BillingHandler) validateLEGParticipant(p *models.LEGParticipant) string {
+	p.Role = strings.TrimSpace(p.Role)
+	p.ValidFrom = strings.TrimSpace(p.ValidFrom)
+	p.ValidTo = strings.TrimSpace(p.ValidTo)
+	if p.Role == "" {
+		p.Role = services.LEGRoleConsumer
+	}
+	if p.Role != services.LEGRoleConsumer && p.Role != services.LEGRoleProducer {
+		return "role must be consumer or producer"
+	}
+	if p.MeterID == 0 {
+		return "meter_id is required"
+	}
+	var meterType string
+	if err := h.db.QueryRow(`SELECT meter_type, building_id FROM meters WHERE id = ?`, p.MeterID).Scan(&meterType, &p.BuildingID); err != nil {
+		return "meter not found"
+	}
+	if p.Role == services.LEGRoleConsumer && meterType != "apartment_meter" {
+		return "a consumer must be an apartment meter"
+	}
+	if p.Role == services.LEGRoleProducer && meterType != "solar_meter" {
+		return "a producer must be a solar meter"
+	}
+	if p.UserID != nil {
+		var count int
+		h.db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ?`, *p.UserID).Scan(&count)
+		if count == 0 {
+			return "user not found"
+		}
+	}
+	if _, err := time.Parse("2006-01-02", p.ValidFrom); err != nil {
+		return "Invalid valid_from format. Use YYYY-MM-DD"
+	}
+	if p.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", p.ValidTo); err != nil {
+			return "Invalid valid_to format. Use YYYY-MM-DD"
+		}
+		if p.ValidTo < p.ValidFrom {
+			return "valid_to must not be before valid_from"
+		}
+	}
+	return ""
+}
+
+func (h *BillingHandler) ListLEGCommunities(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT id, name, dso_name, community_code, local_energy_price, producer_price,
+		       grid_fee_price, grid_fee_discount, allocation_method, is_active, notes, created_at, updated_at
+		FROM leg_communities
+		ORDER BY name, id
+	`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query LEG communities: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	communities := []models.LEGCommunity{}
+	for rows.Next() {
+		var c models.LEGCommunity
+		if err := rows.Scan(&c.ID, &c.Name, &c.DSOName, &c.CommunityCode, &c.LocalEnergyPrice, &c.ProducerPrice,
+			&c.GridFeePrice, &c.GridFeeDiscount, &c.AllocationMethod, &c.IsActive, &c.Notes, &c.CreatedAt, &c.UpdatedAt); err == nil {
+			communities = append(communities, c)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(communities)
+}
+
+func (h *BillingHandler) GetLEGCommunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	c, err := services.LoadLEGCommunity(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "LEG community not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to load LEG community %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *BillingHandler) CreateLEGCommunity(w http.ResponseWriter, r *http.Request) {
+	var c models.LEGCommunity
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		log.Printf("ERROR: Failed to decode LEG community: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateLEGCommunity(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO leg_communities (
+			name, dso_name, community_code, local_energy_price, producer_price,
+			grid_fee_price, grid_fee_discount, allocation_method, is_active, notes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.Name, c.DSOName, c.CommunityCode, c.LocalEnergyPrice, c.ProducerPrice,
+		c.GridFeePrice, c.GridFeeDiscount, c.AllocationMethod, c.IsActive, c.Notes)
+	if err != nil {
+		log.Printf("ERROR: Failed to create LEG community: %v", err)
+		http.Error(w, "Failed to create LEG community", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.ID = int(id)
+	log.Printf("SUCCESS: Created LEG community ID %d (%s)", c.ID, c.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *BillingHandler) UpdateLEGCommunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var c models.LEGCommunity
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		log.Printf("ERROR: Failed to decode LEG community: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateLEGCommunity(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE leg_communities SET
+			name = ?, dso_name = ?, community_code = ?, local_energy_price = ?, producer_price = ?,
+			grid_fee_price = ?, grid_fee_discount = ?, allocation_method = ?, is_active = ?, notes = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, c.Name, c.DSOName, c.CommunityCode, c.LocalEnergyPrice, c.ProducerPrice,
+		c.GridFeePrice, c.GridFeeDiscount, c.AllocationMethod, c.IsActive, c.Notes, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update LEG community %d: %v", id, err)
+		http.Error(w, "Failed to update LEG community", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "LEG community not found", http.StatusNotFound)
+		return
+	}
+
+	c.ID = id
+	log.Printf("SUCCESS: Updated LEG community ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *BillingHandler) DeleteLEGCommunity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM leg_communities WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete LEG community %d: %v", id, err)
+		http.Error(w, "Failed to delete LEG community", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted LEG community ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BillingHandler) CreateLEGParticipant(w http.ResponseWriter, r *http.Request) {
+	communityID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var p models.LEGParticipant
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode LEG participant: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	p.CommunityID = communityID
+	if msg := h.validateLEGParticipant(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO leg_participants (community_id, building_id, meter_id, user_id, role, valid_from, valid_to)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, p.CommunityID, p.BuildingID, p.MeterID, p.UserID, p.Role, p.ValidFrom, p.ValidTo)
+	if err != nil {
+		log.Printf("ERROR: Failed to create LEG participant: %v", err)
+		http.Error(w, "Failed to create LEG participant", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	p.ID = int(id)
+	log.Printf("SUCCESS: Added meter %d as LEG %s to community %d", p.MeterID, p.Role, p.CommunityID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *BillingHandler) UpdateLEGParticipant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var p models.LEGParticipant
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode LEG participant: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validateLEGParticipant(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE leg_participants SET
+			building_id = ?, meter_id = ?, user_id = ?, role = ?, valid_from = ?, valid_to = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, p.BuildingID, p.MeterID, p.UserID, p.Role, p.ValidFrom, p.ValidTo, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update LEG participant %d: %v", id, err)
+		http.Error(w, "Failed to update LEG participant", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "LEG participant not found", http.StatusNotFound)
+		return
+	}
+
+	p.ID = id
+	log.Printf("SUCCESS: Updated LEG participant ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *BillingHandler) DeleteLEGParticipant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM leg_participants WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete LEG participant %d: %v", id, err)
+		http.Error(w, "Failed to delete LEG participant", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted LEG participant ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LEGBalance returns the per-interval community balance for
+// ?start_date=&end_date= (inclusive) as JSON, or with ?format=csv as a file
+// for the grid operator; ?view=participants lists every metering point.
+func (h *BillingHandler) LEGBalance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	startDate, endDate := r.URL.Query().Get("start_date"), r.URL.Query().Get("end_date")
+	start, err1 := time.Parse("2006-01-02", startDate)
+	end, err2 := time.Parse("2006-01-02", endDate)
+	if err1 != nil || err2 != nil || end.Before(start) {
+		http.Error(w, "Invalid start_date/end_date. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	balance, err := h.billingService.ComputeLEGBalance(id, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("ERROR: LEG balance for community %d failed: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(balance)
+		return
+	}
+
+	view := r.URL.Query().Get("view")
+	if view != "participants" {
+		view = "community"
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	filename := fmt.Sprintf("leg-%d-%s-balance-%s-to-%s.csv", id, view, startDate, endDate)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+	for _, row := range services.LEGBalanceRows(balance, view) {
+		if err := writer.Write(row); err != nil {
+			log.Printf("Error writing CSV: %v", err)
+			return
+		}
+	}
+}
+
+type LEGBillingRequest struct {
+	UserIDs    []int  `json:"user_ids"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	Preview    bool   `json:"preview"`
+	PreviewPDF bool   `json:"preview_pdf"`
+	DocumentParties
+}
+
+// IssueLEGInvoices bills the consumers of a LEG community (or previews the
+// invoices with "preview": true).
+func (h *BillingHandler) IssueLEGInvoices(w http.ResponseWriter, r *http.Request) {
+	h.issueLEGDocuments(w, r, "LEG Invoices Generated",
+		h.billingService.GenerateLEGInvoices, h.billingService.PreviewLEGInvoices)
+}
+
+// IssueLEGProducerCredits issues the credit statements of a LEG community's
+// producers (or previews them with "preview": true).
+func (h *BillingHandler) IssueLEGProducerCredits(w http.ResponseWriter, r *http.Request) {
+	h.issueLEGDocuments(w, r, "LEG Producer Credits Generated",
+		h.billingService.GenerateLEGProducerCredits, h.billingService.PreviewLEGProducerCredits)
+}
+
+type legDocumentsFunc func(communityID int, userIDs []int, startDate, endDate string) ([]models.Invoice, []services.SkippedBill, error)
+
+func (h *BillingHandler) issueLEGDocuments(w http.ResponseWriter, r *http.Request, action string, generate, preview legDocumentsFunc) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req LEGBillingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Preview {
+		invoices, skipped, err := preview(id, req.UserIDs, req.StartDate, req.EndDate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"preview":  true,
+			"invoices": services.RenderInvoicePreviews(h.pdfGenerator, invoices, req.senderInfo(), req.bankingInfo(), req.PreviewPDF),
+			"skipped":  skipped,
+		})
+		return
+	}
+
+	invoices, skipped, err := generate(id, req.UserIDs, req.StartDate, req.EndDate)
+	if err != nil {
+		log.Printf("ERROR: %s failed for community %d: %v", action, id, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range invoices {
+		pdfPath, err := h.generateInvoicePDF(invoices[i].ID, req.senderInfo(), req.bankingInfo())
+		if err != nil {
+			log.Printf("WARNING: Failed to generate PDF for LEG document %d: %v", invoices[i].ID, err)
+			continue
+		}
+		invoices[i].PDFPath = pdfPath
+	}
+
+	h.logToDatabase(action,
+		fmt.Sprintf("Community %d: %d documents (%d skipped), period %s to %s", id, len(invoices), len(skipped), req.StartDate, req.EndDate),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoices": invoices,
+		"skipped":  skipped,
+	})
+}
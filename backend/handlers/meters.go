@@ -64,7 +64,7 @@ func (h *MeterHandler) List(w http.ResponseWriter, r *http.Request) {
 		       last_reading, last_reading_export, last_reading_time,
 		       is_active, is_mid_certified, is_archived, replaced_by_meter_id,
 		       replaces_meter_id, replacement_date, replacement_notes,
-		       sort_order, created_at, updated_at
+		       sort_order, COALESCE(reading_factor, 1), created_at, updated_at
 		FROM meters
 	`
 
@@ -121,7 +121,7 @@ func (h *MeterHandler) List(w http.ResponseWriter, r *http.Request) {
 			&m.ConnectionType, &m.ConnectionConfig, &deviceType, &m.Notes,
 			&m.LastReading, &m.LastReadingExport, &m.LastReadingTime,
 			&m.IsActive, &m.IsMidCertified, &m.IsArchived, &replacedBy, &replaces,
-			&replacementDate, &replacementNotes, &m.SortOrder, &m.CreatedAt, &m.UpdatedAt,
+			&replacementDate, &replacementNotes, &m.SortOrder, &m.ReadingFactor, &m.CreatedAt, &m.UpdatedAt,
 		)
 		if err != nil {
 			log.Printf("ERROR: Failed to scan meter row: %v", err)
@@ -175,14 +175,14 @@ func (h *MeterHandler) Get(w http.ResponseWriter, r *http.Request) {
 		       last_reading, last_reading_export, last_reading_time, 
 		       is_active, is_mid_certified, is_archived, replaced_by_meter_id,
 		       replaces_meter_id, replacement_date, replacement_notes,
-		       created_at, updated_at
+		       COALESCE(reading_factor, 1), created_at, updated_at
 		FROM meters WHERE id = ?
 	`, id).Scan(
 		&m.ID, &m.Name, &m.MeterType, &m.BuildingID, &m.UserID, &apartmentUnit,
 		&m.ConnectionType, &m.ConnectionConfig, &deviceType, &m.Notes, 
 		&m.LastReading, &m.LastReadingExport, &m.LastReadingTime,
 		&m.IsActive, &m.IsMidCertified, &m.IsArchived, &replacedBy, &replaces,
-		&replacementDate, &replacementNotes, &m.CreatedAt, &m.UpdatedAt,
+		&replacementDate, &replacementNotes, &m.ReadingFactor, &m.CreatedAt, &m.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -271,14 +271,20 @@ func (h *MeterHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if m.DeviceType == "" {
 		m.DeviceType = "generic"
 	}
+	// Raw counters are billed as-is unless a reading factor is set.
+	if m.ReadingFactor <= 0 {
+		m.ReadingFactor = 1
+	}
 
 	result, err := h.db.Exec(`
 		INSERT INTO meters (
 			name, meter_type, building_id, user_id, apartment_unit,
-			connection_type, connection_config, device_type, notes, is_active, is_mid_certified
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			connection_type, connection_config, device_type, notes, is_active, is_mid_certified,
+			reading_factor
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, m.Name, m.MeterType, m.BuildingID, m.UserID, m.ApartmentUnit,
-		m.ConnectionType, m.ConnectionConfig, m.DeviceType, m.Notes, m.IsActive, m.IsMidCertified)
+		m.ConnectionType, m.ConnectionConfig, m.DeviceType, m.Notes, m.IsActive, m.IsMidCertified,
+		m.ReadingFactor)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create meter: %v", err)
@@ -322,15 +328,21 @@ func (h *MeterHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if m.DeviceType == "" {
 		m.DeviceType = "generic"
 	}
+	// Raw counters are billed as-is unless a reading factor is set.
+	if m.ReadingFactor <= 0 {
+		m.ReadingFactor = 1
+	}
 
 	_, err = h.db.Exec(`
 		UPDATE meters SET
 			name = ?, meter_type = ?, building_id = ?, user_id = ?, 
 			apartment_unit = ?, connection_type = ?, connection_config = ?,
-			device_type = ?, notes = ?, is_active = ?, is_mid_certified = ?, updated_at = CURRENT_TIMESTAMP
+			device_type = ?, notes = ?, is_active = ?, is_mid_certified = ?, reading_factor = ?,
+			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, m.Name, m.MeterType, m.BuildingID, m.UserID, m.ApartmentUnit,
-		m.ConnectionType, m.ConnectionConfig, m.DeviceType, m.Notes, m.IsActive, m.IsMidCertified, id)
+		m.ConnectionType, m.ConnectionConfig, m.DeviceType, m.Notes, m.IsActive, m.IsMidCertified,
+		m.ReadingFactor, id)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to update meter: %v", err)
@@ -660,12 +672,13 @@ func (h *MeterHandler) ReplaceMeter(w http.ResponseWriter, r *http.Request) {
 		INSERT INTO meters (
 			name, meter_type, building_id, user_id, apartment_unit,
 			connection_type, connection_config, device_type, notes, is_active, is_archived,
-			replaces_meter_id, last_reading
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 0, ?, ?)
+			replaces_meter_id, last_reading, reading_factor
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 0, ?, ?,
+			(SELECT COALESCE(reading_factor, 1) FROM meters WHERE id = ?))
 	`, req.NewMeterName, meterType, buildingID, userIDValue, apartmentUnitInsert,
 		req.NewConnectionType, newMeterConfig, deviceTypeValue,
 		fmt.Sprintf("Replaces meter: %s", oldMeter.Name),
-		req.OldMeterID, req.NewMeterInitialReading)
+		req.OldMeterID, req.NewMeterInitialReading, req.OldMeterID)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create new meter: %v", err)
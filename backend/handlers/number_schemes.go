@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// NumberSchemeHandler manages the configurable invoice number sequences and
+// their assignment to buildings.
+type NumberSchemeHandler struct {
+	db *sql.DB
+}
+
+func NewNumberSchemeHandler(db *sql.DB) *NumberSchemeHandler {
+	return &NumberSchemeHandler{db: db}
+}
+
+func (h *NumberSchemeHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// normalizeNumberScheme fills in the default pattern, credit note prefix,
+// yearly reset and five counter digits, then reports what
+// services.ValidateNumberScheme objects to ("" if nothing).
+func normalizeNumberScheme(s *models.InvoiceNumberScheme) string {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Pattern = strings.TrimSpace(s.Pattern)
+	if s.Pattern == "" {
+		s.Pattern = services.DefaultNumberPattern
+	}
+	if s.CreditNotePrefix == "" {
+		s.CreditNotePrefix = services.DefaultCreditNotePrefix
+	}
+	if s.ResetPolicy == "" {
+		s.ResetPolicy = services.NumberResetYearly
+	}
+	if s.CounterDigits == 0 {
+		s.CounterDigits = 5
+	}
+	for i := range s.Buildings {
+		s.Buildings[i].BuildingCode = strings.TrimSpace(s.Buildings[i].BuildingCode)
+		if s.Buildings[i].BuildingID == 0 {
+			return "building_id is required for each assigned building"
+		}
+	}
+	if err := services.ValidateNumberScheme(*s); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func (h *NumberSchemeHandler) loadBuildings(schemeID int) []models.NumberSchemeBuilding {
+	buildings := []models.NumberSchemeBuilding{}
+	rows, err := h.db.Query(`
+		SELECT building_id, building_code FROM invoice_number_scheme_buildings
+		WHERE scheme_id = ? ORDER BY building_id
+	`, schemeID)
+	if err != nil {
+		return buildings
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b models.NumberSchemeBuilding
+		if err := rows.Scan(&b.BuildingID, &b.BuildingCode); err == nil {
+			buildings = append(buildings, b)
+		}
+	}
+	return buildings
+}
+
+func (h *NumberSchemeHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT id, name, prefix, credit_note_prefix, pattern, counter_digits, reset_policy, per_building, created_at, updated_at
+		FROM invoice_number_schemes ORDER BY name
+	`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query number schemes: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	schemes := []models.InvoiceNumberScheme{}
+	for rows.Next() {
+		var s models.InvoiceNumberScheme
+		if err := rows.Scan(&s.ID, &s.Name, &s.Prefix, &s.CreditNotePrefix, &s.Pattern, &s.CounterDigits, &s.ResetPolicy,
+			&s.PerBuilding, &s.CreatedAt, &s.UpdatedAt); err == nil {
+			schemes = append(schemes, s)
+		}
+	}
+	rows.Close()
+	for i := range schemes {
+		schemes[i].Buildings = h.loadBuildings(schemes[i].ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schemes)
+}
+
+// saveBuildings replaces the scheme's building assignments. A building
+// belongs to one scheme at a time, so assigning it here moves it.
+func saveBuildings(tx *sql.Tx, schemeID int, buildings []models.NumberSchemeBuilding) error {
+	if _, err := tx.Exec(`DELETE FROM invoice_number_scheme_buildings WHERE scheme_id = ?`, schemeID); err != nil {
+		return err
+	}
+	for _, b := range buildings {
+		if _, err := tx.Exec(`
+			INSERT INTO invoice_number_scheme_buildings (building_id, scheme_id, building_code)
+			VALUES (?, ?, ?)
+			ON CONFLICT(building_id) DO UPDATE SET scheme_id = excluded.scheme_id, building_code = excluded.building_code
+		`, b.BuildingID, schemeID, b.BuildingCode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *NumberSchemeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var s models.InvoiceNumberScheme
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := normalizeNumberScheme(&s); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO invoice_number_schemes (name, prefix, credit_note_prefix, pattern, counter_digits, reset_policy, per_building)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.Name, s.Prefix, s.CreditNotePrefix, s.Pattern, s.CounterDigits, s.ResetPolicy, s.PerBuilding)
+	if err != nil {
+		log.Printf("ERROR: Failed to create number scheme: %v", err)
+		http.Error(w, "Failed to create number scheme", http.StatusInternalServerError)
+		return
+	}
+	id, _ := result.LastInsertId()
+	s.ID = int(id)
+	if err := saveBuildings(tx, s.ID, s.Buildings); err != nil {
+		log.Printf("ERROR: Failed to assign buildings to number scheme %d: %v", s.ID, err)
+		http.Error(w, "Failed to assign buildings", http.StatusBadRequest)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to create number scheme", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Created invoice number scheme %d (%s, %s)", s.ID, s.Name, s.Pattern)
+	h.logToDatabase("Number Scheme Created", fmt.Sprintf("%s: %s, reset %s", s.Name, s.Pattern, s.ResetPolicy), getClientIP(r))
+
+	if s.Buildings == nil {
+		s.Buildings = []models.NumberSchemeBuilding{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+// Update changes a scheme and its building assignments. Once invoices have
+// been numbered from it, only the name and the assignments can change: a new
+// format mid-sequence would break the gap-free series.
+func (h *NumberSchemeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var s models.InvoiceNumberScheme
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := normalizeNumberScheme(&s); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var current models.InvoiceNumberScheme
+	err = h.db.QueryRow(`
+		SELECT prefix, credit_note_prefix, pattern, counter_digits, reset_policy, per_building FROM invoice_number_schemes WHERE id = ?
+	`, id).Scan(&current.Prefix, &current.CreditNotePrefix, &current.Pattern, &current.CounterDigits, &current.ResetPolicy, &current.PerBuilding)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Number scheme not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	formatChanged := current.Prefix != s.Prefix || current.CreditNotePrefix != s.CreditNotePrefix || current.Pattern != s.Pattern || current.CounterDigits != s.CounterDigits ||
+		current.ResetPolicy != s.ResetPolicy || current.PerBuilding != s.PerBuilding
+	if formatChanged {
+		var used int
+		h.db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE number_scheme_id = ?`, id).Scan(&used)
+		if used > 0 {
+			http.Error(w, "The number format cannot change once invoices were numbered from this scheme", http.StatusConflict)
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE invoice_number_schemes SET
+			name = ?, prefix = ?, credit_note_prefix = ?, pattern = ?, counter_digits = ?, reset_policy = ?, per_building = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, s.Name, s.Prefix, s.CreditNotePrefix, s.Pattern, s.CounterDigits, s.ResetPolicy, s.PerBuilding, id); err != nil {
+		log.Printf("ERROR: Failed to update number scheme %d: %v", id, err)
+		http.Error(w, "Failed to update number scheme", http.StatusInternalServerError)
+		return
+	}
+	if err := saveBuildings(tx, id, s.Buildings); err != nil {
+		log.Printf("ERROR: Failed to assign buildings to number scheme %d: %v", id, err)
+		http.Error(w, "Failed to assign buildings", http.StatusBadRequest)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to update number scheme", http.StatusInternalServerError)
+		return
+	}
+
+	h.logToDatabase("Number Scheme Updated", fmt.Sprintf("Scheme #%d %s: %s", id, s.Name, s.Pattern), getClientIP(r))
+
+	s.ID = id
+	if s.Buildings == nil {
+		s.Buildings = []models.NumberSchemeBuilding{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// Delete removes an unused scheme together with its counters and building
+// assignments. Schemes that numbered invoices are kept for the audit trail.
+func (h *NumberSchemeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists, used int
+	h.db.QueryRow(`SELECT COUNT(*) FROM invoice_number_schemes WHERE id = ?`, id).Scan(&exists)
+	if exists == 0 {
+		http.Error(w, "Number scheme not found", http.StatusNotFound)
+		return
+	}
+	h.db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE number_scheme_id = ?`, id).Scan(&used)
+	if used > 0 {
+		http.Error(w, "Number scheme has numbered invoices and cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`UPDATE auto_billing_configs SET number_scheme_id = NULL WHERE number_scheme_id = ?`,
+		`DELETE FROM invoice_number_scheme_buildings WHERE scheme_id = ?`,
+		`DELETE FROM invoice_number_counters WHERE scheme_id = ?`,
+		`DELETE FROM invoice_number_schemes WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			log.Printf("ERROR: Failed to delete number scheme %d: %v", id, err)
+			http.Error(w, "Failed to delete number scheme", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to delete number scheme", http.StatusInternalServerError)
+		return
+	}
+
+	h.logToDatabase("Number Scheme Deleted", fmt.Sprintf("Scheme #%d", id), getClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Next shows the number the next invoice of a building would receive,
+// without using it. ?building_id is required.
+func (h *NumberSchemeHandler) Next(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	buildingID, err := strconv.Atoi(r.URL.Query().Get("building_id"))
+	if err != nil {
+		http.Error(w, "building_id is required", http.StatusBadRequest)
+		return
+	}
+
+	number, err := services.PeekInvoiceNumber(h.db, id, buildingID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheme_id":   id,
+		"building_id": buildingID,
+		"next_number": number,
+	})
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// PaymentHandler manages the payment ledger: the individual payments booked
+// on invoices, the credit balances overpayments leave behind and the tenant
+// account statements built from both.
+type PaymentHandler struct {
+	db           *sql.DB
+	pdfGenerator *services.PDFGenerator
+}
+
+func NewPaymentHandler(db *sql.DB, pdfGenerator *services.PDFGenerator) *PaymentHandler {
+	return &PaymentHandler{db: db, pdfGenerator: pdfGenerator}
+}
+
+func (h *PaymentHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// validatePayment checks a manually entered payment. The amount must be
+// positive. The date defaults to today and the method to bank transfer. The
+// payment is always recorded as manual, without a bank transaction.
+func validatePayment(p *models.Payment) string {
+	if p.Amount <= 0 {
+		return "amount must be positive"
+	}
+	p.PaymentDate = strings.TrimSpace(p.PaymentDate)
+	if p.PaymentDate == "" {
+		p.PaymentDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", p.PaymentDate); err != nil {
+		return "Invalid payment_date format. Use YYYY-MM-DD"
+	}
+	p.Method = strings.ToLower(strings.TrimSpace(p.Method))
+	if p.Method == "" {
+		p.Method = services.PaymentMethodBankTransfer
+	}
+	if !services.ValidPaymentMethod(p.Method) {
+		return "method must be bank_transfer, cash, card, direct_debit or other"
+	}
+	p.Reference = strings.TrimSpace(p.Reference)
+	p.Source = services.PaymentSourceManual
+	p.BankTransactionID = nil
+	return ""
+}
+
+// List returns payments, optionally narrowed by ?invoice_id= or ?user_id=.
+func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
+	var f services.PaymentFilter
+	f.InvoiceID, _ = strconv.Atoi(r.URL.Query().Get("invoice_id"))
+	f.UserID, _ = strconv.Atoi(r.URL.Query().Get("user_id"))
+	if id, err := strconv.Atoi(mux.Vars(r)["id"]); err == nil {
+		f.InvoiceID = id
+	}
+
+	payments, err := services.LoadPayments(h.db, f)
+	if err != nil {
+		log.Printf("ERROR: Failed to load payments: %v", err)
+		http.Error(w, "Failed to load payments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+// Create books a manual payment on the invoice in the URL.
+func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var p models.Payment
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode payment: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validatePayment(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	p.InvoiceID = invoiceID
+
+	created, err := services.RecordPayment(h.db, p)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to record payment on invoice %d: %v", invoiceID, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("SUCCESS: Recorded payment of %s %.2f on invoice %s", created.Currency, created.Amount, created.InvoiceNumber)
+	h.logToDatabase("Payment Recorded",
+		fmt.Sprintf("%s %.2f on invoice %s (%s)", created.Currency, created.Amount, created.InvoiceNumber, created.Method),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Delete removes a manually entered payment.
+func (h *PaymentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DeletePayment(h.db, id); err == sql.ErrNoRows {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to delete payment %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	h.logToDatabase("Payment Deleted", fmt.Sprintf("Payment #%d", id), getClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreditBalances returns the tenants' credit balances, optionally for one
+// ?user_id=.
+func (h *PaymentHandler) CreditBalances(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(r.URL.Query().Get("user_id"))
+	balances, err := services.UserCreditBalances(h.db, userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load credit balances: %v", err)
+		http.Error(w, "Failed to load credit balances", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balances)
+}
+
+// AccountStatements returns the account statements of ?user_id= or of every
+// tenant of ?building_id= for ?from= to ?to=, as JSON or with ?format=pdf as
+// one PDF.
+func (h *PaymentHandler) AccountStatements(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := services.AccountStatementFilter{From: q.Get("from"), To: q.Get("to")}
+	f.UserID, _ = strconv.Atoi(q.Get("user_id"))
+	f.BuildingID, _ = strconv.Atoi(q.Get("building_id"))
+	if f.UserID == 0 && f.BuildingID == 0 {
+		http.Error(w, "user_id or building_id is required", http.StatusBadRequest)
+		return
+	}
+	name := fmt.Sprintf("statement-user-%d", f.UserID)
+	if f.UserID == 0 {
+		name = fmt.Sprintf("statement-building-%d", f.BuildingID)
+	}
+	serveAccountStatements(w, r, h.db, h.pdfGenerator, f, name)
+}
+
+// serveAccountStatements validates the date range, builds the statements and
+// writes them as JSON or, with ?format=pdf, as a PDF named after name and the
+// end date. Shared by the admin API and the tenant portal.
+func serveAccountStatements(w http.ResponseWriter, r *http.Request, db *sql.DB, pg *services.PDFGenerator,
+	f services.AccountStatementFilter, name string) {
+	for _, d := range []string{f.From, f.To} {
+		if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+			http.Error(w, "Invalid date format. Use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if f.From != "" && f.To != "" && f.From > f.To {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	statements, err := services.BuildAccountStatements(db, f)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to build account statements: %v", err)
+		http.Error(w, "Failed to build account statements", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") != "pdf" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statements)
+		return
+	}
+	if len(statements) == 0 {
+		http.Error(w, "No entries in this period", http.StatusNotFound)
+		return
+	}
+	pdf, err := pg.RenderAccountStatementsPDF(statements)
+	if err != nil {
+		log.Printf("ERROR: Failed to render account statements: %v", err)
+		http.Error(w, "Failed to render PDF", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s-%s.pdf", name, statements[0].To))
+	w.Write(pdf)
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/aj9599/zev-billing/backend/middleware"
+	"github.com/aj9599/zev-billing/backend/services"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 )
@@ -23,12 +24,13 @@ import (
 // code (users.portal_token); on success they get a JWT with role="tenant" whose
 // user_id scopes every portal query to their own data.
 type PortalHandler struct {
-	db        *sql.DB
-	jwtSecret string
+	db           *sql.DB
+	jwtSecret    string
+	pdfGenerator *services.PDFGenerator
 }
 
-func NewPortalHandler(db *sql.DB, jwtSecret string) *PortalHandler {
-	return &PortalHandler{db: db, jwtSecret: jwtSecret}
+func NewPortalHandler(db *sql.DB, jwtSecret string, pdfGenerator *services.PDFGenerator) *PortalHandler {
+	return &PortalHandler{db: db, jwtSecret: jwtSecret, pdfGenerator: pdfGenerator}
 }
 
 // newPortalToken returns a URL-safe random access code.
@@ -196,9 +198,13 @@ func (h *PortalHandler) Invoices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	rows, err := h.db.Query(`
-		SELECT id, invoice_number, period_start, period_end, total_amount, currency, status,
-		       COALESCE(pdf_path, '') <> '' AS has_pdf
-		FROM invoices WHERE user_id = ? ORDER BY period_start DESC
+		SELECT i.id, i.invoice_number, i.period_start, i.period_end, i.total_amount, i.currency, i.status,
+		       COALESCE(i.pdf_path, '') <> '' AS has_pdf,
+		       COALESCE(i.document_type, 'invoice'), COALESCE(o.invoice_number, '')
+		FROM invoices i
+		LEFT JOIN invoices o ON o.id = i.original_invoice_id
+		WHERE i.user_id = ? AND i.status != 'draft'
+		ORDER BY i.period_start DESC, i.id DESC
 	`, uid)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -215,12 +221,16 @@ func (h *PortalHandler) Invoices(w http.ResponseWriter, r *http.Request) {
 		Currency      string  `json:"currency"`
 		Status        string  `json:"status"`
 		HasPDF        bool    `json:"has_pdf"`
+		// Cancellation chain: credit notes and replacements name the original.
+		DocumentType          string `json:"document_type"`
+		OriginalInvoiceNumber string `json:"original_invoice_number,omitempty"`
 	}
 	invoices := []Invoice{}
 	for rows.Next() {
 		var inv Invoice
 		if err := rows.Scan(&inv.ID, &inv.InvoiceNumber, &inv.PeriodStart, &inv.PeriodEnd,
-			&inv.TotalAmount, &inv.Currency, &inv.Status, &inv.HasPDF); err != nil {
+			&inv.TotalAmount, &inv.Currency, &inv.Status, &inv.HasPDF,
+			&inv.DocumentType, &inv.OriginalInvoiceNumber); err != nil {
 			continue
 		}
 		invoices = append(invoices, inv)
@@ -245,7 +255,7 @@ func (h *PortalHandler) InvoicePDF(w http.ResponseWriter, r *http.Request) {
 	var invoiceNumber string
 	var pdfPath sql.NullString
 	var ownerID int
-	err = h.db.QueryRow(`SELECT invoice_number, pdf_path, user_id FROM invoices WHERE id = ?`, invoiceID).
+	err = h.db.QueryRow(`SELECT invoice_number, pdf_path, user_id FROM invoices WHERE id = ? AND status != 'draft'`, invoiceID).
 		Scan(&invoiceNumber, &pdfPath, &ownerID)
 	if err == sql.ErrNoRows || (err == nil && ownerID != uid) {
 		// Same response whether it doesn't exist or isn't theirs.
@@ -258,6 +268,11 @@ func (h *PortalHandler) InvoicePDF(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filePath := resolveInvoicePDFPath(pdfPath, invoiceNumber)
+	if archived := services.NewInvoiceArchive(h.db).Path(invoiceID); archived != "" {
+		if _, err := os.Stat(archived); err == nil {
+			filePath = archived
+		}
+	}
 	if filePath == "" {
 		http.Error(w, "PDF not available", http.StatusNotFound)
 		return
@@ -267,6 +282,18 @@ func (h *PortalHandler) InvoicePDF(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, filePath)
 }
 
+// Statement returns the tenant's own account statement for ?from= to ?to=,
+// as JSON or with ?format=pdf as a PDF in their language.
+func (h *PortalHandler) Statement(w http.ResponseWriter, r *http.Request) {
+	uid, ok := portalUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f := services.AccountStatementFilter{UserID: uid, From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+	serveAccountStatements(w, r, h.db, h.pdfGenerator, f, "statement")
+}
+
 // resolveInvoicePDFPath mirrors the admin DownloadPDF lookup: absolute path, or
 // search the known invoice directories by filename. Returns "" if not found.
 func resolveInvoicePDFPath(pdfPath sql.NullString, invoiceNumber string) string {
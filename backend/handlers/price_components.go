@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// PriceComponentHandler manages the grid price components (energy, grid
+// usage, levies, fees, metering) of a building.
+type PriceComponentHandler struct {
+	db *sql.DB
+}
+
+func NewPriceComponentHandler(db *sql.DB) *PriceComponentHandler {
+	return &PriceComponentHandler{db: db}
+}
+
+// validatePriceComponent requires a building, a name, a known component type
+// (other when left empty), a non-negative price and a valid date range.
+func validatePriceComponent(pc *models.PriceComponent) string {
+	pc.Name = strings.TrimSpace(pc.Name)
+	pc.ValidFrom = strings.TrimSpace(pc.ValidFrom)
+	pc.ValidTo = strings.TrimSpace(pc.ValidTo)
+	if pc.ComponentType == "" {
+		pc.ComponentType = services.PriceComponentOther
+	}
+	if pc.BuildingID == 0 {
+		return "building_id is required"
+	}
+	if pc.Name == "" {
+		return "name is required"
+	}
+	if !services.ValidPriceComponentType(pc.ComponentType) {
+		return "component_type must be one of energy, grid_usage, federal_levy, municipal_fee, metering, other"
+	}
+	if pc.Price < 0 {
+		return "price must not be negative"
+	}
+	if _, err := time.Parse("2006-01-02", pc.ValidFrom); err != nil {
+		return "Invalid valid_from format. Use YYYY-MM-DD"
+	}
+	if pc.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", pc.ValidTo); err != nil {
+			return "Invalid valid_to format. Use YYYY-MM-DD"
+		}
+		if pc.ValidTo < pc.ValidFrom {
+			return "valid_to must not be before valid_from"
+		}
+	}
+	return ""
+}
+
+func (h *PriceComponentHandler) List(w http.ResponseWriter, r *http.Request) {
+	buildingID := r.URL.Query().Get("building_id")
+
+	query := `
+		SELECT id, building_id, name, component_type, price, vat_exempt,
+		       valid_from, valid_to, sort_order, is_active, created_at, updated_at
+		FROM price_components
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY building_id, sort_order, valid_from, id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query price components: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	components := []models.PriceComponent{}
+	for rows.Next() {
+		var pc models.PriceComponent
+		if err := rows.Scan(&pc.ID, &pc.BuildingID, &pc.Name, &pc.ComponentType, &pc.Price, &pc.VATExempt,
+			&pc.ValidFrom, &pc.ValidTo, &pc.SortOrder, &pc.IsActive, &pc.CreatedAt, &pc.UpdatedAt); err == nil {
+			components = append(components, pc)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(components)
+}
+
+func (h *PriceComponentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var pc models.PriceComponent
+	if err := json.NewDecoder(r.Body).Decode(&pc); err != nil {
+		log.Printf("ERROR: Failed to decode price component: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validatePriceComponent(&pc); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO price_components (
+			building_id, name, component_type, price, vat_exempt,
+			valid_from, valid_to, sort_order, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, pc.BuildingID, pc.Name, pc.ComponentType, pc.Price, pc.VATExempt,
+		pc.ValidFrom, pc.ValidTo, pc.SortOrder, pc.IsActive)
+	if err != nil {
+		log.Printf("ERROR: Failed to create price component: %v", err)
+		http.Error(w, "Failed to create price component", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	pc.ID = int(id)
+	log.Printf("SUCCESS: Created price component ID %d (%s) for building %d", pc.ID, pc.Name, pc.BuildingID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pc)
+}
+
+func (h *PriceComponentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var pc models.PriceComponent
+	if err := json.NewDecoder(r.Body).Decode(&pc); err != nil {
+		log.Printf("ERROR: Failed to decode price component: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validatePriceComponent(&pc); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE price_components SET
+			building_id = ?, name = ?, component_type = ?, price = ?, vat_exempt = ?,
+			valid_from = ?, valid_to = ?, sort_order = ?, is_active = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, pc.BuildingID, pc.Name, pc.ComponentType, pc.Price, pc.VATExempt,
+		pc.ValidFrom, pc.ValidTo, pc.SortOrder, pc.IsActive, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update price component %d: %v", id, err)
+		http.Error(w, "Failed to update price component", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Price component not found", http.StatusNotFound)
+		return
+	}
+
+	pc.ID = id
+	log.Printf("SUCCESS: Updated price component ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pc)
+}
+
+func (h *PriceComponentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM price_components WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete price component %d: %v", id, err)
+		http.Error(w, "Failed to delete price component", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted price component ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// validateProducerAccount rejects an account without an existing user or a
+// building, with negative prices or with a VAT rate outside 0–100 %.
+func (h *BillingHandler) validateProducerAccount(a *models.ProducerAccount) string {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.UserID == 0 || a.BuildingID == 0 {
+		return "user_id and building_id are required"
+	}
+	if (a.SelfConsumptionPrice != nil && *a.SelfConsumptionPrice < 0) || (a.ExportPrice != nil && *a.ExportPrice < 0) {
+		return "prices must not be negative"
+	}
+	if a.VATRate < 0 || a.VATRate > 100 {
+		return "vat_rate must be between 0 and 100"
+	}
+	var count int
+	h.db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ?`, a.UserID).Scan(&count)
+	if count == 0 {
+		return "user not found"
+	}
+	return ""
+}
+
+func (h *BillingHandler) ListProducerAccounts(w http.ResponseWriter, r *http.Request) {
+	query := `SELECT id FROM producer_accounts WHERE 1=1`
+	args := []interface{}{}
+	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY building_id, name, id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query producer accounts: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	accounts := []models.ProducerAccount{}
+	for _, id := range ids {
+		if a, err := services.LoadProducerAccount(h.db, id); err == nil {
+			accounts = append(accounts, *a)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+func (h *BillingHandler) CreateProducerAccount(w http.ResponseWriter, r *http.Request) {
+	var a models.ProducerAccount
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		log.Printf("ERROR: Failed to decode producer account: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validateProducerAccount(&a); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO producer_accounts (
+			user_id, building_id, name, self_consumption_price, export_price, vat_rate, is_active, notes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.UserID, a.BuildingID, a.Name, a.SelfConsumptionPrice, a.ExportPrice, a.VATRate, a.IsActive, a.Notes)
+	if err != nil {
+		log.Printf("ERROR: Failed to create producer account: %v", err)
+		http.Error(w, "Failed to create producer account", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	a.ID = int(id)
+	log.Printf("SUCCESS: Created producer account ID %d (user %d, building %d)", a.ID, a.UserID, a.BuildingID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+func (h *BillingHandler) UpdateProducerAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var a models.ProducerAccount
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		log.Printf("ERROR: Failed to decode producer account: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validateProducerAccount(&a); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE producer_accounts SET
+			user_id = ?, building_id = ?, name = ?, self_consumption_price = ?, export_price = ?,
+			vat_rate = ?, is_active = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, a.UserID, a.BuildingID, a.Name, a.SelfConsumptionPrice, a.ExportPrice, a.VATRate, a.IsActive, a.Notes, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update producer account %d: %v", id, err)
+		http.Error(w, "Failed to update producer account", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Producer account not found", http.StatusNotFound)
+		return
+	}
+
+	a.ID = id
+	log.Printf("SUCCESS: Updated producer account ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+func (h *BillingHandler) DeleteProducerAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	// Issued statements keep pointing at the account; deactivate it instead.
+	var statements int
+	h.db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE producer_account_id = ?`, id).Scan(&statements)
+	if statements > 0 {
+		http.Error(w, "Producer account has issued statements; deactivate it instead", http.StatusConflict)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM producer_accounts WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete producer account %d: %v", id, err)
+		http.Error(w, "Failed to delete producer account", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted producer account ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddProducerMeter links a solar meter of the account's building to a
+// producer account.
+func (h *BillingHandler) AddProducerMeter(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var pm models.ProducerMeter
+	if err := json.NewDecoder(r.Body).Decode(&pm); err != nil {
+		log.Printf("ERROR: Failed to decode producer meter: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	pm.ProducerAccountID = accountID
+	pm.ValidFrom = strings.TrimSpace(pm.ValidFrom)
+	pm.ValidTo = strings.TrimSpace(pm.ValidTo)
+	if pm.SharePercent == 0 {
+		pm.SharePercent = 100
+	}
+	if pm.SharePercent < 0 || pm.SharePercent > 100 {
+		http.Error(w, "share_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse("2006-01-02", pm.ValidFrom); err != nil {
+		http.Error(w, "Invalid valid_from format. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if pm.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", pm.ValidTo); err != nil || pm.ValidTo < pm.ValidFrom {
+			http.Error(w, "valid_to must be a date not before valid_from", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var count int
+	h.db.QueryRow(`
+		SELECT COUNT(*) FROM meters m
+		JOIN producer_accounts a ON a.building_id = m.building_id
+		WHERE m.id = ? AND a.id = ? AND m.meter_type = 'solar_meter'
+	`, pm.MeterID, accountID).Scan(&count)
+	if count == 0 {
+		http.Error(w, "meter must be a solar meter in the producer account's building", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO producer_meters (producer_account_id, meter_id, share_percent, valid_from, valid_to)
+		VALUES (?, ?, ?, ?, ?)
+	`, pm.ProducerAccountID, pm.MeterID, pm.SharePercent, pm.ValidFrom, pm.ValidTo)
+	if err != nil {
+		log.Printf("ERROR: Failed to add producer meter: %v", err)
+		http.Error(w, "Failed to add producer meter", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	pm.ID = int(id)
+	log.Printf("SUCCESS: Linked meter %d to producer account %d (%.1f%%)", pm.MeterID, accountID, pm.SharePercent)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pm)
+}
+
+func (h *BillingHandler) DeleteProducerMeter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM producer_meters WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete producer meter %d: %v", id, err)
+		http.Error(w, "Failed to delete producer meter", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted producer meter ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ProducerStatementsRequest struct {
+	BuildingIDs []int  `json:"building_ids"`
+	AccountIDs  []int  `json:"account_ids"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Preview     bool   `json:"preview"`
+	PreviewPDF  bool   `json:"preview_pdf"`
+	DocumentParties
+}
+
+// IssueProducerStatements issues the credit statements of the selected
+// producer accounts for one period (or previews them with "preview": true).
+func (h *BillingHandler) IssueProducerStatements(w http.ResponseWriter, r *http.Request) {
+	var req ProducerStatementsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.BuildingIDs) == 0 && len(req.AccountIDs) == 0 {
+		http.Error(w, "building_ids or account_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Preview {
+		invoices, skipped, err := h.billingService.PreviewProducerStatements(req.BuildingIDs, req.AccountIDs, req.StartDate, req.EndDate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"preview":  true,
+			"invoices": services.RenderInvoicePreviews(h.pdfGenerator, invoices, req.senderInfo(), req.bankingInfo(), req.PreviewPDF),
+			"skipped":  skipped,
+		})
+		return
+	}
+
+	invoices, skipped, err := h.billingService.GenerateProducerStatements(req.BuildingIDs, req.AccountIDs, req.StartDate, req.EndDate)
+	if err != nil {
+		log.Printf("ERROR: Producer statement generation failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range invoices {
+		pdfPath, err := h.generateInvoicePDF(invoices[i].ID, req.senderInfo(), req.bankingInfo())
+		if err != nil {
+			log.Printf("WARNING: Failed to generate PDF for producer statement %d: %v", invoices[i].ID, err)
+			continue
+		}
+		invoices[i].PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Producer Statements Generated",
+		fmt.Sprintf("%d producer statements (%d skipped), period %s to %s", len(invoices), len(skipped), req.StartDate, req.EndDate),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invoices": invoices,
+		"skipped":  skipped,
+	})
+}
+
+// RecordProducerPayout books the payout of a producer credit statement
+// ({"paid_at": "YYYY-MM-DD"}, default today).
+func (h *BillingHandler) RecordProducerPayout(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		PaidAt string `json:"paid_at"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.PaidAt == "" {
+		req.PaidAt = time.Now().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", req.PaidAt); err != nil {
+		http.Error(w, "Invalid paid_at format. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.RecordProducerPayout(h.db, id, req.PaidAt); err == sql.ErrNoRows {
+		http.Error(w, "Statement not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("SUCCESS: Recorded payout of producer statement %d on %s", id, req.PaidAt)
+	h.logToDatabase("Producer Payout Recorded", fmt.Sprintf("Statement %d paid out on %s", id, req.PaidAt), getClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+type RecalculateInvoicesRequest struct {
+	BuildingIDs []int  `json:"building_ids"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+}
+
+// RecalculateInvoices bills the periods of issued invoices overlapping
+// [start_date, end_date] again and returns the invoices whose amount changed.
+func (h *BillingHandler) RecalculateInvoices(w http.ResponseWriter, r *http.Request) {
+	var req RecalculateInvoicesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		http.Error(w, "Invalid start_date format. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		http.Error(w, "Invalid end_date format. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) {
+		http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+		return
+	}
+
+	recs, err := h.billingService.RecalculateInvoices(req.BuildingIDs, req.StartDate, req.EndDate)
+	if err != nil {
+		log.Printf("ERROR: Invoice recalculation failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logToDatabase("Invoices Recalculated",
+		fmt.Sprintf("%d affected invoice(s), period %s to %s", len(recs), req.StartDate, req.EndDate), getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recs)
+}
+
+// ListRecalculations lists recalculations, filtered by status, building_id
+// or invoice_id.
+func (h *BillingHandler) ListRecalculations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := services.RecalculationFilter{Status: q.Get("status")}
+	f.BuildingID, _ = strconv.Atoi(q.Get("building_id"))
+	f.InvoiceID, _ = strconv.Atoi(q.Get("invoice_id"))
+
+	recs, err := services.LoadRecalculations(h.db, f)
+	if err != nil {
+		log.Printf("ERROR: Failed to query recalculations: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recs)
+}
+
+// CorrectRecalculation issues the credit note or supplementary invoice for
+// a pending recalculation and renders its PDF.
+func (h *BillingHandler) CorrectRecalculation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req DocumentParties
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.billingService.CorrectRecalculation(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recalculation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to correct recalculation %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if pdfPath, err := h.generateInvoicePDF(doc.ID, req.senderInfo(), req.bankingInfo()); err != nil {
+		log.Printf("WARNING: Failed to generate PDF for correction %d: %v", doc.ID, err)
+	} else {
+		doc.PDFPath = pdfPath
+	}
+
+	h.logToDatabase("Invoice Corrected",
+		fmt.Sprintf("Invoice %s corrected by %s %s (%.2f)", doc.OriginalInvoiceNumber, doc.DocumentType, doc.InvoiceNumber, doc.TotalAmount),
+		getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// DismissRecalculation closes a pending recalculation without a correction
+// ({"note": "..."}).
+func (h *BillingHandler) DismissRecalculation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if err := h.billingService.DismissRecalculation(id, strings.TrimSpace(req.Note)); err == sql.ErrNoRows {
+		http.Error(w, "Recalculation not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("SUCCESS: Dismissed recalculation %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
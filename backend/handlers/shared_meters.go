@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/aj9599/zev-billing/backend/services"
 	"github.com/gorilla/mux"
 )
 
@@ -33,8 +34,12 @@ type SharedMeterConfig struct {
 	PricingMode string  `json:"pricing_mode"`
 	SolarPrice  float64 `json:"solar_price"`
 	GridPrice   float64 `json:"grid_price"`
-	CreatedAt   string  `json:"created_at"`
-	UpdatedAt   string  `json:"updated_at"`
+	// ConsumptionShare is the percentage of the cost allocated by the
+	// tenants' own consumption of the same utility (e.g. 70 for heat billed
+	// 70% by consumption, 30% by area); the rest follows SplitType.
+	ConsumptionShare float64 `json:"consumption_share"`
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
 }
 
 // validPricingMode reports whether m is one of the supported pricing modes.
@@ -48,12 +53,27 @@ func validPricingMode(m string) bool {
 	}
 }
 
+// validateUtilitySplit checks the consumption share and that water, heat and
+// gas meters are priced flat (the solar/grid modes only apply to
+// electricity). Returns a message for a 400 response ("" when valid).
+func (h *SharedMeterHandler) validateUtilitySplit(c *SharedMeterConfig) string {
+	if c.ConsumptionShare < 0 || c.ConsumptionShare > 100 {
+		return "consumption_share must be between 0 and 100"
+	}
+	var meterType string
+	h.db.QueryRow("SELECT meter_type FROM meters WHERE id = ?", c.MeterID).Scan(&meterType)
+	if services.UtilityOfMeterType(meterType) != "" && c.PricingMode != "single" {
+		return "Water, heat and gas meters only support pricing_mode single"
+	}
+	return ""
+}
+
 func (h *SharedMeterHandler) List(w http.ResponseWriter, r *http.Request) {
 	buildingID := r.URL.Query().Get("building_id")
 
 	query := `
 		SELECT id, meter_id, building_id, meter_name, split_type, unit_price,
-		       pricing_mode, solar_price, grid_price, created_at, updated_at
+		       pricing_mode, solar_price, grid_price, consumption_share, created_at, updated_at
 		FROM shared_meter_configs
 		WHERE 1=1
 	`
@@ -80,7 +100,7 @@ func (h *SharedMeterHandler) List(w http.ResponseWriter, r *http.Request) {
 		err := rows.Scan(
 			&c.ID, &c.MeterID, &c.BuildingID, &c.MeterName,
 			&c.SplitType, &c.UnitPrice, &c.PricingMode, &c.SolarPrice, &c.GridPrice,
-			&c.CreatedAt, &c.UpdatedAt,
+			&c.ConsumptionShare, &c.CreatedAt, &c.UpdatedAt,
 		)
 		if err == nil {
 			configs = append(configs, c)
@@ -104,13 +124,13 @@ func (h *SharedMeterHandler) Get(w http.ResponseWriter, r *http.Request) {
 	var c SharedMeterConfig
 	err = h.db.QueryRow(`
 		SELECT id, meter_id, building_id, meter_name, split_type, unit_price,
-		       pricing_mode, solar_price, grid_price, created_at, updated_at
+		       pricing_mode, solar_price, grid_price, consumption_share, created_at, updated_at
 		FROM shared_meter_configs
 		WHERE id = ?
 	`, id).Scan(
 		&c.ID, &c.MeterID, &c.BuildingID, &c.MeterName,
 		&c.SplitType, &c.UnitPrice, &c.PricingMode, &c.SolarPrice, &c.GridPrice,
-		&c.CreatedAt, &c.UpdatedAt,
+		&c.ConsumptionShare, &c.CreatedAt, &c.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -149,6 +169,10 @@ func (h *SharedMeterHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if c.PricingMode == "" {
 		c.PricingMode = "single"
 	}
+	if msg := h.validateUtilitySplit(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	// Get meter name if not provided
 	if c.MeterName == "" && c.MeterID > 0 {
@@ -162,10 +186,10 @@ func (h *SharedMeterHandler) Create(w http.ResponseWriter, r *http.Request) {
 	result, err := h.db.Exec(`
 		INSERT INTO shared_meter_configs (
 			meter_id, building_id, meter_name, split_type, unit_price,
-			pricing_mode, solar_price, grid_price
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			pricing_mode, solar_price, grid_price, consumption_share
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, c.MeterID, c.BuildingID, c.MeterName, c.SplitType, c.UnitPrice,
-		c.PricingMode, c.SolarPrice, c.GridPrice)
+		c.PricingMode, c.SolarPrice, c.GridPrice, c.ConsumptionShare)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to create shared meter config: %v", err)
@@ -220,15 +244,19 @@ func (h *SharedMeterHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if c.PricingMode == "" {
 		c.PricingMode = "single"
 	}
+	if msg := h.validateUtilitySplit(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	_, err = h.db.Exec(`
 		UPDATE shared_meter_configs SET
 			meter_id = ?, building_id = ?, meter_name = ?,
 			split_type = ?, unit_price = ?, pricing_mode = ?, solar_price = ?, grid_price = ?,
-			updated_at = CURRENT_TIMESTAMP
+			consumption_share = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, c.MeterID, c.BuildingID, c.MeterName, c.SplitType, c.UnitPrice,
-		c.PricingMode, c.SolarPrice, c.GridPrice, id)
+		c.PricingMode, c.SolarPrice, c.GridPrice, c.ConsumptionShare, id)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to update shared meter config: %v", err)
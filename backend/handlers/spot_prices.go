@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+)
+
+// SpotPriceHandler imports and inspects the dynamic (day-ahead) grid price
+// series used by spot-priced billing settings.
+type SpotPriceHandler struct {
+	db *sql.DB
+}
+
+func NewSpotPriceHandler(db *sql.DB) *SpotPriceHandler {
+	return &SpotPriceHandler{db: db}
+}
+
+// spotRange parses the start/end (YYYY-MM-DD, end inclusive) query parameters
+// into a half-open local-time range.
+func spotRange(r *http.Request) (time.Time, time.Time, string) {
+	start, err := time.ParseInLocation("2006-01-02", r.URL.Query().Get("start"), time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, "Invalid start format. Use YYYY-MM-DD"
+	}
+	end, err := time.ParseInLocation("2006-01-02", r.URL.Query().Get("end"), time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, "Invalid end format. Use YYYY-MM-DD"
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, "end must not be before start"
+	}
+	return start, end, ""
+}
+
+// ImportPrices accepts a CSV or JSON price file, either as multipart upload
+// (field "file", plus form fields area/unit/resolution_minutes) or as the raw
+// request body with the same values as query parameters.
+func (h *SpotPriceHandler) ImportPrices(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var area, unit, resolution string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No price file provided", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Failed to read price file", http.StatusBadRequest)
+			return
+		}
+		area, unit, resolution = r.FormValue("area"), r.FormValue("unit"), r.FormValue("resolution_minutes")
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		area, unit, resolution = q.Get("area"), q.Get("unit"), q.Get("resolution_minutes")
+	}
+
+	area = strings.ToUpper(strings.TrimSpace(area))
+	if area == "" {
+		http.Error(w, "area is required", http.StatusBadRequest)
+		return
+	}
+	opts := services.SpotImportOptions{Area: area, Unit: unit, Source: "upload"}
+	if resolution != "" {
+		minutes, err := strconv.Atoi(resolution)
+		if err != nil {
+			http.Error(w, "Invalid resolution_minutes", http.StatusBadRequest)
+			return
+		}
+		opts.ResolutionMinutes = minutes
+	}
+
+	prices, err := services.ParseSpotPrices(data, opts)
+	if err != nil {
+		http.Error(w, "Invalid price file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := services.StoreSpotPrices(h.db, area, prices, "upload")
+	if err != nil {
+		log.Printf("ERROR: Failed to store spot prices for %s: %v", area, err)
+		http.Error(w, "Failed to store prices", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Imported %d spot price intervals for area %s", n, area)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"price_area": area,
+		"imported":   n,
+		"first":      prices[0].IntervalStart,
+		"last":       prices[len(prices)-1].IntervalStart,
+	})
+}
+
+// ListAreas returns every price area with its interval count and date range.
+func (h *SpotPriceHandler) ListAreas(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT price_area, COUNT(*), MIN(interval_start), MAX(interval_start)
+		FROM spot_prices GROUP BY price_area ORDER BY price_area
+	`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query spot price areas: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	areas := []map[string]interface{}{}
+	for rows.Next() {
+		var area, first, last string
+		var count int
+		if err := rows.Scan(&area, &count, &first, &last); err == nil {
+			areas = append(areas, map[string]interface{}{
+				"price_area": area,
+				"intervals":  count,
+				"first":      first,
+				"last":       last,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(areas)
+}
+
+// ListPrices returns the prices of one area for a date range.
+func (h *SpotPriceHandler) ListPrices(w http.ResponseWriter, r *http.Request) {
+	area := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("area")))
+	if area == "" {
+		http.Error(w, "area is required", http.StatusBadRequest)
+		return
+	}
+	start, end, msg := spotRange(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	byInterval, err := services.LoadSpotPrices(h.db, area, start, end)
+	if err != nil {
+		log.Printf("ERROR: Failed to load spot prices for %s: %v", area, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	prices := []models.SpotPrice{}
+	for t := start; t.Before(end); t = t.Add(15 * time.Minute) {
+		if p, ok := byInterval[t.Unix()]; ok {
+			prices = append(prices, models.SpotPrice{PriceArea: area, IntervalStart: t, Price: p})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prices)
+}
+
+// Coverage reports the intervals of a date range that have no price, so
+// gaps can be filled before billing (spot-priced bills refuse to price a
+// missing interval at zero).
+func (h *SpotPriceHandler) Coverage(w http.ResponseWriter, r *http.Request) {
+	area := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("area")))
+	if area == "" {
+		http.Error(w, "area is required", http.StatusBadRequest)
+		return
+	}
+	start, end, msg := spotRange(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	cov, err := services.SpotPriceCoverage(h.db, area, start, end)
+	if err != nil {
+		log.Printf("ERROR: Failed to check spot price coverage for %s: %v", area, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cov)
+}
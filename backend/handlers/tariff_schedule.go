@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// TariffWindowHandler manages the time-of-use (HT/NT) grid tariff windows and
+// the holiday calendar of a building.
+type TariffWindowHandler struct {
+	db *sql.DB
+}
+
+func NewTariffWindowHandler(db *sql.DB) *TariffWindowHandler {
+	return &TariffWindowHandler{db: db}
+}
+
+// validateTariffWindow checks that a window applies on at least one day,
+// that its start and end are HH:MM times and that its price is not negative.
+func validateTariffWindow(tw *models.TariffWindow) string {
+	tw.Name = strings.TrimSpace(tw.Name)
+	if tw.BuildingID == 0 {
+		return "building_id is required"
+	}
+	if tw.Name == "" {
+		return "name is required"
+	}
+	if tw.WeekdayMask < 0 || tw.WeekdayMask > 127 {
+		return "weekday_mask must be between 0 and 127 (bit 0 = Monday … bit 6 = Sunday)"
+	}
+	if tw.WeekdayMask == 0 && !tw.AppliesOnHolidays {
+		return "window applies on no day: set weekday_mask or applies_on_holidays"
+	}
+	if _, err := services.ParseTariffClock(tw.StartTime); err != nil {
+		return "start_time: " + err.Error()
+	}
+	if _, err := services.ParseTariffClock(tw.EndTime); err != nil {
+		return "end_time: " + err.Error()
+	}
+	if tw.Price < 0 {
+		return "price must not be negative"
+	}
+	return ""
+}
+
+func (h *TariffWindowHandler) ListWindows(w http.ResponseWriter, r *http.Request) {
+	buildingID := r.URL.Query().Get("building_id")
+
+	query := `
+		SELECT id, building_id, name, weekday_mask, start_time, end_time,
+		       applies_on_holidays, price, sort_order, is_active, created_at, updated_at
+		FROM tariff_windows
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY building_id, sort_order, id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query tariff windows: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	windows := []models.TariffWindow{}
+	for rows.Next() {
+		var tw models.TariffWindow
+		if err := rows.Scan(&tw.ID, &tw.BuildingID, &tw.Name, &tw.WeekdayMask, &tw.StartTime, &tw.EndTime,
+			&tw.AppliesOnHolidays, &tw.Price, &tw.SortOrder, &tw.IsActive, &tw.CreatedAt, &tw.UpdatedAt); err == nil {
+			windows = append(windows, tw)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(windows)
+}
+
+func (h *TariffWindowHandler) CreateWindow(w http.ResponseWriter, r *http.Request) {
+	var tw models.TariffWindow
+	if err := json.NewDecoder(r.Body).Decode(&tw); err != nil {
+		log.Printf("ERROR: Failed to decode tariff window: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateTariffWindow(&tw); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO tariff_windows (
+			building_id, name, weekday_mask, start_time, end_time,
+			applies_on_holidays, price, sort_order, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, tw.BuildingID, tw.Name, tw.WeekdayMask, tw.StartTime, tw.EndTime,
+		tw.AppliesOnHolidays, tw.Price, tw.SortOrder, tw.IsActive)
+	if err != nil {
+		log.Printf("ERROR: Failed to create tariff window: %v", err)
+		http.Error(w, "Failed to create tariff window", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	tw.ID = int(id)
+	log.Printf("SUCCESS: Created tariff window ID %d (%s) for building %d", tw.ID, tw.Name, tw.BuildingID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tw)
+}
+
+func (h *TariffWindowHandler) UpdateWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var tw models.TariffWindow
+	if err := json.NewDecoder(r.Body).Decode(&tw); err != nil {
+		log.Printf("ERROR: Failed to decode tariff window: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateTariffWindow(&tw); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE tariff_windows SET
+			building_id = ?, name = ?, weekday_mask = ?, start_time = ?, end_time = ?,
+			applies_on_holidays = ?, price = ?, sort_order = ?, is_active = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, tw.BuildingID, tw.Name, tw.WeekdayMask, tw.StartTime, tw.EndTime,
+		tw.AppliesOnHolidays, tw.Price, tw.SortOrder, tw.IsActive, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update tariff window %d: %v", id, err)
+		http.Error(w, "Failed to update tariff window", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Tariff window not found", http.StatusNotFound)
+		return
+	}
+
+	tw.ID = id
+	log.Printf("SUCCESS: Updated tariff window ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tw)
+}
+
+func (h *TariffWindowHandler) DeleteWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM tariff_windows WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete tariff window %d: %v", id, err)
+		http.Error(w, "Failed to delete tariff window", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted tariff window ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TariffWindowHandler) ListHolidays(w http.ResponseWriter, r *http.Request) {
+	buildingID := r.URL.Query().Get("building_id")
+
+	query := `SELECT id, building_id, holiday_date, name FROM tariff_holidays WHERE 1=1`
+	args := []interface{}{}
+	if buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	query += " ORDER BY building_id, holiday_date"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query tariff holidays: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	holidays := []models.TariffHoliday{}
+	for rows.Next() {
+		var hd models.TariffHoliday
+		if err := rows.Scan(&hd.ID, &hd.BuildingID, &hd.HolidayDate, &hd.Name); err == nil {
+			holidays = append(holidays, hd)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holidays)
+}
+
+func (h *TariffWindowHandler) CreateHoliday(w http.ResponseWriter, r *http.Request) {
+	var hd models.TariffHoliday
+	if err := json.NewDecoder(r.Body).Decode(&hd); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if hd.BuildingID == 0 {
+		http.Error(w, "building_id is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse("2006-01-02", hd.HolidayDate); err != nil {
+		http.Error(w, "Invalid holiday_date format. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO tariff_holidays (building_id, holiday_date, name) VALUES (?, ?, ?)
+		ON CONFLICT(building_id, holiday_date) DO UPDATE SET name = excluded.name
+	`, hd.BuildingID, hd.HolidayDate, strings.TrimSpace(hd.Name))
+	if err != nil {
+		log.Printf("ERROR: Failed to create tariff holiday: %v", err)
+		http.Error(w, "Failed to create holiday", http.StatusInternalServerError)
+		return
+	}
+	id, _ := result.LastInsertId()
+	hd.ID = int(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hd)
+}
+
+func (h *TariffWindowHandler) DeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM tariff_holidays WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete tariff holiday %d: %v", id, err)
+		http.Error(w, "Failed to delete holiday", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
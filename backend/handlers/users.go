@@ -32,7 +32,7 @@ func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
 		       bank_name, bank_iban, bank_account_holder, charger_ids, 
 		       notes, building_id, apartment_unit, user_type, managed_buildings, 
 		       COALESCE(language, 'de'), COALESCE(is_active, 1), 
-		       rent_start_date, rent_end_date, created_at, updated_at
+		       rent_start_date, rent_end_date, apartment_area, unit_count, created_at, updated_at
 		FROM users
 	`
 
@@ -78,7 +78,7 @@ func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
 			&u.AddressStreet, &u.AddressCity, &u.AddressZip, &u.AddressCountry,
 			&u.BankName, &u.BankIBAN, &u.BankAccountHolder, &u.ChargerIDs,
 			&u.Notes, &u.BuildingID, &apartmentUnit, &userType, &managedBuildings,
-			&language, &isActive, &rentStartDate, &rentEndDate, &u.CreatedAt, &u.UpdatedAt,
+			&language, &isActive, &rentStartDate, &rentEndDate, &u.ApartmentArea, &u.UnitCount, &u.CreatedAt, &u.UpdatedAt,
 		)
 		if err != nil {
 			log.Printf("Error scanning user: %v", err)
@@ -158,14 +158,14 @@ func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 		       bank_name, bank_iban, bank_account_holder, charger_ids, 
 		       notes, building_id, apartment_unit, user_type, managed_buildings, 
 		       COALESCE(language, 'de'), COALESCE(is_active, 1), 
-		       rent_start_date, rent_end_date, created_at, updated_at
+		       rent_start_date, rent_end_date, apartment_area, unit_count, created_at, updated_at
 		FROM users WHERE id = ?
 	`, id).Scan(
 		&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Phone,
 		&u.AddressStreet, &u.AddressCity, &u.AddressZip, &u.AddressCountry,
 		&u.BankName, &u.BankIBAN, &u.BankAccountHolder, &u.ChargerIDs,
 		&u.Notes, &u.BuildingID, &apartmentUnit, &userType, &managedBuildings,
-		&language, &isActive, &rentStartDate, &rentEndDate, &u.CreatedAt, &u.UpdatedAt,
+		&language, &isActive, &rentStartDate, &rentEndDate, &u.ApartmentArea, &u.UnitCount, &u.CreatedAt, &u.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -378,13 +378,15 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 			address_street, address_city, address_zip, address_country,
 			bank_name, bank_iban, bank_account_holder, charger_ids,
 			notes, building_id, apartment_unit, user_type, managed_buildings, 
-			language, is_active, rent_start_date, rent_end_date
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			language, is_active, rent_start_date, rent_end_date,
+			apartment_area, unit_count
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, 0), COALESCE(?, 1))
 	`, u.FirstName, u.LastName, u.Email, u.Phone,
 		u.AddressStreet, u.AddressCity, u.AddressZip, u.AddressCountry,
 		u.BankName, u.BankIBAN, u.BankAccountHolder, u.ChargerIDs,
 		u.Notes, u.BuildingID, u.ApartmentUnit, u.UserType, u.ManagedBuildings,
-		u.Language, isActiveVal, rentStartToSave, rentEndToSave)
+		u.Language, isActiveVal, rentStartToSave, rentEndToSave,
+		u.ApartmentArea, u.UnitCount)
 
 	if err != nil {
 		log.Printf("Error creating user: %v", err)
@@ -587,13 +589,16 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 			bank_name = ?, bank_iban = ?, bank_account_holder = ?, charger_ids = ?,
 			notes = ?, building_id = ?, apartment_unit = ?, user_type = ?, 
 			managed_buildings = ?, language = ?, is_active = ?, 
-			rent_start_date = ?, rent_end_date = ?, updated_at = CURRENT_TIMESTAMP
+			rent_start_date = ?, rent_end_date = ?,
+			apartment_area = COALESCE(?, apartment_area), unit_count = COALESCE(?, unit_count),
+			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, u.FirstName, u.LastName, u.Email, u.Phone,
 		u.AddressStreet, u.AddressCity, u.AddressZip, u.AddressCountry,
 		u.BankName, u.BankIBAN, u.BankAccountHolder, u.ChargerIDs,
 		u.Notes, u.BuildingID, u.ApartmentUnit, u.UserType,
-		u.ManagedBuildings, u.Language, isActiveVal, rentStartToSave, rentEndToSave, id)
+		u.ManagedBuildings, u.Language, isActiveVal, rentStartToSave, rentEndToSave,
+		u.ApartmentArea, u.UnitCount, id)
 
 	if err != nil {
 		log.Printf("Error updating user ID %d: %v", id, err)
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// UtilityPriceHandler manages the water, heat and gas prices of a building.
+type UtilityPriceHandler struct {
+	db *sql.DB
+}
+
+func NewUtilityPriceHandler(db *sql.DB) *UtilityPriceHandler {
+	return &UtilityPriceHandler{db: db}
+}
+
+// validateUtilityPrice requires a building and a known utility. The unit
+// defaults to the utility's usual unit. Price and base fee must not be
+// negative, and the validity range must be valid.
+func validateUtilityPrice(p *models.UtilityPrice) string {
+	p.Unit = strings.TrimSpace(p.Unit)
+	p.ValidFrom = strings.TrimSpace(p.ValidFrom)
+	p.ValidTo = strings.TrimSpace(p.ValidTo)
+	if p.BuildingID == 0 {
+		return "building_id is required"
+	}
+	if !services.ValidUtility(p.Utility) {
+		return "utility must be one of cold_water, hot_water, heat, gas"
+	}
+	if p.Unit == "" {
+		p.Unit = services.DefaultUtilityUnit(p.Utility)
+	}
+	if p.Price < 0 || p.BaseFee < 0 {
+		return "price and base_fee must not be negative"
+	}
+	if _, err := time.Parse("2006-01-02", p.ValidFrom); err != nil {
+		return "Invalid valid_from format. Use YYYY-MM-DD"
+	}
+	if p.ValidTo != "" {
+		if _, err := time.Parse("2006-01-02", p.ValidTo); err != nil {
+			return "Invalid valid_to format. Use YYYY-MM-DD"
+		}
+		if p.ValidTo < p.ValidFrom {
+			return "valid_to must not be before valid_from"
+		}
+	}
+	return ""
+}
+
+func (h *UtilityPriceHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT id, building_id, utility, unit, price, base_fee,
+		       valid_from, valid_to, is_active, created_at, updated_at
+		FROM utility_prices
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
+		query += " AND building_id = ?"
+		args = append(args, buildingID)
+	}
+	if utility := r.URL.Query().Get("utility"); utility != "" {
+		query += " AND utility = ?"
+		args = append(args, utility)
+	}
+	query += " ORDER BY building_id, utility, valid_from, id"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query utility prices: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	prices := []models.UtilityPrice{}
+	for rows.Next() {
+		var p models.UtilityPrice
+		if err := rows.Scan(&p.ID, &p.BuildingID, &p.Utility, &p.Unit, &p.Price, &p.BaseFee,
+			&p.ValidFrom, &p.ValidTo, &p.IsActive, &p.CreatedAt, &p.UpdatedAt); err == nil {
+			prices = append(prices, p)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prices)
+}
+
+func (h *UtilityPriceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var p models.UtilityPrice
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode utility price: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateUtilityPrice(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		INSERT INTO utility_prices (
+			building_id, utility, unit, price, base_fee, valid_from, valid_to, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.BuildingID, p.Utility, p.Unit, p.Price, p.BaseFee, p.ValidFrom, p.ValidTo, p.IsActive)
+	if err != nil {
+		log.Printf("ERROR: Failed to create utility price: %v", err)
+		http.Error(w, "Failed to create utility price", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	p.ID = int(id)
+	log.Printf("SUCCESS: Created %s price ID %d for building %d", p.Utility, p.ID, p.BuildingID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *UtilityPriceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var p models.UtilityPrice
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		log.Printf("ERROR: Failed to decode utility price: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateUtilityPrice(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE utility_prices SET
+			building_id = ?, utility = ?, unit = ?, price = ?, base_fee = ?,
+			valid_from = ?, valid_to = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, p.BuildingID, p.Utility, p.Unit, p.Price, p.BaseFee, p.ValidFrom, p.ValidTo, p.IsActive, id)
+	if err != nil {
+		log.Printf("ERROR: Failed to update utility price %d: %v", id, err)
+		http.Error(w, "Failed to update utility price", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Utility price not found", http.StatusNotFound)
+		return
+	}
+
+	p.ID = id
+	log.Printf("SUCCESS: Updated utility price ID %d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *UtilityPriceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM utility_prices WHERE id = ?", id); err != nil {
+		log.Printf("ERROR: Failed to delete utility price %d: %v", id, err)
+		http.Error(w, "Failed to delete utility price", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted utility price ID %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"github.com/aj9599/zev-billing/backend/services"
+	"github.com/gorilla/mux"
+)
+
+// VATCodeHandler manages the VAT codes invoice lines carry and which code
+// each kind of line (energy, charging, shared meters, utilities, custom item
+// categories) gets.
+type VATCodeHandler struct {
+	db *sql.DB
+}
+
+func NewVATCodeHandler(db *sql.DB) *VATCodeHandler {
+	return &VATCodeHandler{db: db}
+}
+
+func (h *VATCodeHandler) logToDatabase(action, details, ip string) {
+	_, err := h.db.Exec(`
+		INSERT INTO admin_logs (action, details, ip_address)
+		VALUES (?, ?, ?)
+	`, action, details, ip)
+	if err != nil {
+		log.Printf("WARNING: Failed to write admin log: %v", err)
+	}
+}
+
+// vatCodeExists reports whether code is a known VAT code.
+func vatCodeExists(db *sql.DB, code string) bool {
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM vat_codes WHERE code = ?`, code).Scan(&n)
+	return n > 0
+}
+
+// validateVATCode lower-cases the code and checks its rate. An exempt code
+// has no rate. The built-in standard and exempt codes cannot be turned into
+// anything else. Returns the problem, or "" if there is none.
+func validateVATCode(c *models.VATCode) string {
+	c.Code = strings.ToLower(strings.TrimSpace(c.Code))
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Code == "" {
+		return "code is required"
+	}
+	if c.Exempt {
+		c.Rate = nil
+	}
+	if c.Rate != nil && (*c.Rate < 0 || *c.Rate >= 100) {
+		return "rate must be between 0 and 100"
+	}
+	switch c.Code {
+	case services.VATCodeStandard:
+		if c.Rate != nil || c.Exempt {
+			return "the standard code always uses the building's VAT rate"
+		}
+	case services.VATCodeExempt:
+		if !c.Exempt {
+			return "the exempt code must stay exempt"
+		}
+	}
+	return ""
+}
+
+func (h *VATCodeHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`SELECT id, code, name, rate, exempt FROM vat_codes ORDER BY id`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query VAT codes: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	codes := []models.VATCode{}
+	for rows.Next() {
+		var c models.VATCode
+		var rate sql.NullFloat64
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &rate, &c.Exempt); err == nil {
+			if rate.Valid {
+				c.Rate = &rate.Float64
+			}
+			codes = append(codes, c)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *VATCodeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var c models.VATCode
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		log.Printf("ERROR: Failed to decode VAT code: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateVATCode(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if vatCodeExists(h.db, c.Code) {
+		http.Error(w, "VAT code already exists", http.StatusConflict)
+		return
+	}
+
+	result, err := h.db.Exec(`INSERT INTO vat_codes (code, name, rate, exempt) VALUES (?, ?, ?, ?)`,
+		c.Code, c.Name, c.Rate, c.Exempt)
+	if err != nil {
+		log.Printf("ERROR: Failed to create VAT code: %v", err)
+		http.Error(w, "Failed to create VAT code", http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.ID = int(id)
+	log.Printf("SUCCESS: Created VAT code %s", c.Code)
+	h.logToDatabase("VAT Code Created", c.Code, getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Update changes a code's name, rate and exemption. The code itself is
+// fixed once created, as invoice lines refer to it.
+func (h *VATCodeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var c models.VATCode
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		log.Printf("ERROR: Failed to decode VAT code: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	err = h.db.QueryRow(`SELECT code FROM vat_codes WHERE id = ?`, id).Scan(&c.Code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "VAT code not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to load VAT code %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if msg := validateVATCode(&c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec(`
+		UPDATE vat_codes SET name = ?, rate = ?, exempt = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, c.Name, c.Rate, c.Exempt, id); err != nil {
+		log.Printf("ERROR: Failed to update VAT code %d: %v", id, err)
+		http.Error(w, "Failed to update VAT code", http.StatusInternalServerError)
+		return
+	}
+
+	c.ID = id
+	log.Printf("SUCCESS: Updated VAT code %s", c.Code)
+	h.logToDatabase("VAT Code Updated", c.Code, getClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// Delete removes a VAT code that no line kind or custom item uses. The
+// built-in standard and exempt codes cannot be deleted.
+func (h *VATCodeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var code string
+	err = h.db.QueryRow(`SELECT code FROM vat_codes WHERE id = ?`, id).Scan(&code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "VAT code not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("ERROR: Failed to load VAT code %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if code == services.VATCodeStandard || code == services.VATCodeExempt {
+		http.Error(w, "Built-in VAT codes cannot be deleted", http.StatusConflict)
+		return
+	}
+	var uses int
+	h.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM vat_code_assignments WHERE vat_code = ?)
+		     + (SELECT COUNT(*) FROM custom_line_items WHERE vat_code = ?)
+	`, code, code).Scan(&uses)
+	if uses > 0 {
+		http.Error(w, fmt.Sprintf("VAT code %s is still in use", code), http.StatusConflict)
+		return
+	}
+
+	if _, err := h.db.Exec(`DELETE FROM vat_codes WHERE id = ?`, id); err != nil {
+		log.Printf("ERROR: Failed to delete VAT code %d: %v", id, err)
+		http.Error(w, "Failed to delete VAT code", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Deleted VAT code %s", code)
+	h.logToDatabase("VAT Code Deleted", code, getClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAssignments returns the VAT code of every kind of invoice line.
+func (h *VATCodeHandler) ListAssignments(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`SELECT source, vat_code FROM vat_code_assignments ORDER BY source`)
+	if err != nil {
+		log.Printf("ERROR: Failed to query VAT code assignments: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assignments := []models.VATCodeAssignment{}
+	for rows.Next() {
+		var a models.VATCodeAssignment
+		if err := rows.Scan(&a.Source, &a.VATCode); err == nil {
+			assignments = append(assignments, a)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
+
+// UpdateAssignments sets the VAT code of the line kinds in the body; kinds
+// not listed keep their code.
+func (h *VATCodeHandler) UpdateAssignments(w http.ResponseWriter, r *http.Request) {
+	var assignments []models.VATCodeAssignment
+	if err := json.NewDecoder(r.Body).Decode(&assignments); err != nil {
+		log.Printf("ERROR: Failed to decode VAT code assignments: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	for _, a := range assignments {
+		if !services.ValidVATSource(a.Source) {
+			http.Error(w, fmt.Sprintf("Unknown line kind %q", a.Source), http.StatusBadRequest)
+			return
+		}
+		if !vatCodeExists(h.db, a.VATCode) {
+			http.Error(w, fmt.Sprintf("Unknown VAT code %q", a.VATCode), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, a := range assignments {
+		if _, err := tx.Exec(`
+			INSERT INTO vat_code_assignments (source, vat_code) VALUES (?, ?)
+			ON CONFLICT(source) DO UPDATE SET vat_code = excluded.vat_code, updated_at = CURRENT_TIMESTAMP
+		`, a.Source, a.VATCode); err != nil {
+			log.Printf("ERROR: Failed to save VAT code assignment %s: %v", a.Source, err)
+			http.Error(w, "Failed to save VAT code assignments", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to save VAT code assignments", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SUCCESS: Updated %d VAT code assignments", len(assignments))
+	h.logToDatabase("VAT Code Assignments Updated", fmt.Sprintf("%d line kinds", len(assignments)), getClientIP(r))
+	h.ListAssignments(w, r)
+}
@@ -131,6 +131,8 @@ func main() {
 	dataCollector = services.NewDataCollector(db)
 	billingService := services.NewBillingService(db)
 	pdfGenerator := services.NewPDFGenerator(db)
+	pdfGenerator.SetRenderer(cfg.PDFRenderer)
+	services.SetInvoiceArchiveDir(cfg.ArchiveDir)
 	licenseService := services.NewLicenseService(db, cfg.LicensePublicKey, cfg.LicenseActivationURL)
 	autoBillingScheduler = services.NewAutoBillingScheduler(db, billingService, pdfGenerator)
 	autoBillingScheduler.SetLicenseService(licenseService)
@@ -138,6 +140,9 @@ func main() {
 	autoBillingScheduler.SetEmailAlerter(emailAlerter)
 	deviceController = services.NewDeviceController(db, dataCollector)
 	backupScheduler := services.NewBackupScheduler(db, cfg.BackupHour, cfg.BackupRetention)
+	spotPriceWatcher := services.NewSpotPriceWatcher(db, cfg.SpotPriceDir, cfg.SpotPricePollMinutes)
+	dunningService := services.NewDunningService(db, pdfGenerator, cfg.DunningHour)
+	dunningService.SetEmailAlerter(emailAlerter)
 
 	go dataCollector.Start()
 	go autoBillingScheduler.Start()
@@ -148,6 +153,10 @@ func main() {
 	if cfg.BackupEnabled {
 		go backupScheduler.Start()
 	}
+	if cfg.SpotPriceDir != "" {
+		go spotPriceWatcher.Start()
+	}
+	go dunningService.Start()
 
 	// Initialize all handlers
 	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret)
@@ -165,8 +174,21 @@ func main() {
 	customItemHandler := handlers.NewCustomItemHandler(db)
 	emailAlertHandler := handlers.NewEmailAlertHandler(db, emailAlerter)
 	billLayoutHandler := handlers.NewBillLayoutHandler(db)
+	invoiceTemplateHandler := handlers.NewInvoiceTemplateHandler(db, pdfGenerator)
+	invoiceArchiveHandler := handlers.NewInvoiceArchiveHandler(db)
+	tariffWindowHandler := handlers.NewTariffWindowHandler(db)
+	priceComponentHandler := handlers.NewPriceComponentHandler(db)
+	vatCodeHandler := handlers.NewVATCodeHandler(db)
+	utilityPriceHandler := handlers.NewUtilityPriceHandler(db)
+	demandTariffHandler := handlers.NewDemandTariffHandler(db)
+	spotPriceHandler := handlers.NewSpotPriceHandler(db)
 	licenseHandler := handlers.NewLicenseHandler(licenseService)
-	portalHandler := handlers.NewPortalHandler(db, cfg.JWTSecret)
+	portalHandler := handlers.NewPortalHandler(db, cfg.JWTSecret, pdfGenerator)
+	dunningHandler := handlers.NewDunningHandler(db, dunningService)
+	bankImportHandler := handlers.NewBankImportHandler(db)
+	directDebitHandler := handlers.NewDirectDebitHandler(db)
+	paymentHandler := handlers.NewPaymentHandler(db, pdfGenerator)
+	numberSchemeHandler := handlers.NewNumberSchemeHandler(db)
 
 	r := mux.NewRouter()
 
@@ -194,6 +216,7 @@ func main() {
 	portal.HandleFunc("/me", portalHandler.Me).Methods("GET")
 	portal.HandleFunc("/invoices", portalHandler.Invoices).Methods("GET")
 	portal.HandleFunc("/invoices/{id}/pdf", portalHandler.InvoicePDF).Methods("GET")
+	portal.HandleFunc("/statement", portalHandler.Statement).Methods("GET")
 	portal.HandleFunc("/charging", portalHandler.Charging).Methods("GET")
 
 	// Protected API routes (authentication required)
@@ -323,10 +346,68 @@ func main() {
 	api.HandleFunc("/billing/invoices", billingHandler.ListInvoices).Methods("GET")
 	api.HandleFunc("/billing/invoices/{id}", billingHandler.GetInvoice).Methods("GET")
 	api.HandleFunc("/billing/invoices/{id}/payment", billingHandler.UpdateInvoicePayment).Methods("PUT")
+	api.HandleFunc("/billing/invoices/{id}/cancel", billingHandler.CancelInvoice).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/reissue", billingHandler.ReissueInvoice).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/einvoice", billingHandler.DownloadEInvoice).Methods("POST")
 	api.HandleFunc("/billing/invoices/{id}", billingHandler.DeleteInvoice).Methods("DELETE")
 	api.HandleFunc("/billing/backup", billingHandler.BackupDatabase).Methods("GET")
 	api.HandleFunc("/billing/debug/pdfs", billingHandler.DebugListPDFs).Methods("GET")
 
+	// Tamper-evident invoice archive (copies of issued PDFs with a SHA-256 hash chain).
+	api.HandleFunc("/billing/invoices/{id}/archive", invoiceArchiveHandler.Get).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}/archive", invoiceArchiveHandler.Archive).Methods("POST")
+	api.HandleFunc("/billing/archive/verify", invoiceArchiveHandler.Verify).Methods("GET")
+
+	// Advance payments (Akonto) and annual settlements
+	api.HandleFunc("/billing/advance-plans", billingHandler.ListAdvancePlans).Methods("GET")
+	api.HandleFunc("/billing/advance-plans", billingHandler.CreateAdvancePlan).Methods("POST")
+	api.HandleFunc("/billing/advance-plans/{id}", billingHandler.UpdateAdvancePlan).Methods("PUT")
+	api.HandleFunc("/billing/advance-plans/{id}", billingHandler.DeleteAdvancePlan).Methods("DELETE")
+	api.HandleFunc("/billing/advance-invoices", billingHandler.IssueAdvanceInvoices).Methods("POST")
+	api.HandleFunc("/billing/settlements", billingHandler.CreateSettlement).Methods("POST")
+
+	// Dunning: payment terms, overdue overview and reminders
+	api.HandleFunc("/billing/dunning", dunningHandler.Overview).Methods("GET")
+	api.HandleFunc("/billing/dunning/run", dunningHandler.Run).Methods("POST")
+	api.HandleFunc("/billing/dunning/settings", dunningHandler.GetSettings).Methods("GET")
+	api.HandleFunc("/billing/dunning/settings", dunningHandler.UpdateSettings).Methods("PUT")
+	api.HandleFunc("/billing/invoices/{id}/reminders", dunningHandler.ListReminders).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}/reminders", dunningHandler.IssueReminder).Methods("POST")
+	api.HandleFunc("/billing/reminders/{id}/pdf", dunningHandler.DownloadReminderPDF).Methods("GET")
+
+	// Bank statement import (camt.053/054) and payment review queue
+	api.HandleFunc("/billing/bank-imports", bankImportHandler.ListImports).Methods("GET")
+	api.HandleFunc("/billing/bank-imports", bankImportHandler.Import).Methods("POST")
+	api.HandleFunc("/billing/bank-transactions", bankImportHandler.ListTransactions).Methods("GET")
+	api.HandleFunc("/billing/bank-transactions/{id}/assign", bankImportHandler.Assign).Methods("POST")
+	api.HandleFunc("/billing/bank-transactions/{id}/ignore", bankImportHandler.Ignore).Methods("POST")
+
+	// Direct debit mandates and pain.008 collection files
+	api.HandleFunc("/billing/mandates", directDebitHandler.ListMandates).Methods("GET")
+	api.HandleFunc("/billing/mandates", directDebitHandler.CreateMandate).Methods("POST")
+	api.HandleFunc("/billing/mandates/{id}", directDebitHandler.UpdateMandate).Methods("PUT")
+	api.HandleFunc("/billing/mandates/{id}", directDebitHandler.DeleteMandate).Methods("DELETE")
+	api.HandleFunc("/billing/direct-debits", directDebitHandler.ListExports).Methods("GET")
+	api.HandleFunc("/billing/direct-debits", directDebitHandler.CreateExport).Methods("POST")
+	api.HandleFunc("/billing/direct-debits/{id}/file", directDebitHandler.DownloadExport).Methods("GET")
+	api.HandleFunc("/billing/direct-debits/{id}/confirm", directDebitHandler.Confirm).Methods("POST")
+	api.HandleFunc("/billing/direct-debits/{id}/reject", directDebitHandler.Reject).Methods("POST")
+
+	// Payment ledger, tenant credit balances and account statements
+	api.HandleFunc("/billing/payments", paymentHandler.List).Methods("GET")
+	api.HandleFunc("/billing/payments/{id}", paymentHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/billing/invoices/{id}/payments", paymentHandler.List).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}/payments", paymentHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/credit-balances", paymentHandler.CreditBalances).Methods("GET")
+	api.HandleFunc("/billing/account-statements", paymentHandler.AccountStatements).Methods("GET")
+
+	// Invoice number schemes (gap-free sequences per building / year)
+	api.HandleFunc("/billing/number-schemes", numberSchemeHandler.List).Methods("GET")
+	api.HandleFunc("/billing/number-schemes", numberSchemeHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/number-schemes/{id}", numberSchemeHandler.Update).Methods("PUT")
+	api.HandleFunc("/billing/number-schemes/{id}", numberSchemeHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/billing/number-schemes/{id}/next", numberSchemeHandler.Next).Methods("GET")
+
 	// Auto Billing routes
 	api.HandleFunc("/billing/auto-configs", autoBillingHandler.List).Methods("GET")
 	api.HandleFunc("/billing/auto-configs", autoBillingHandler.Create).Methods("POST")
@@ -334,11 +415,91 @@ func main() {
 	api.HandleFunc("/billing/auto-configs/{id}", autoBillingHandler.Update).Methods("PUT")
 	api.HandleFunc("/billing/auto-configs/{id}", autoBillingHandler.Delete).Methods("DELETE")
 	api.HandleFunc("/billing/auto-configs/{id}/run-now", autoBillingHandler.RunNow).Methods("POST")
+	api.HandleFunc("/billing/review-queue", autoBillingHandler.ReviewQueue).Methods("GET")
+	api.HandleFunc("/billing/review-queue/approve", autoBillingHandler.ApproveInvoices).Methods("POST")
 
 	// Bill layout customisation (per building) — main invoice page only.
 	api.HandleFunc("/billing/layouts/{building_id}", billLayoutHandler.Get).Methods("GET")
 	api.HandleFunc("/billing/layouts/{building_id}", billLayoutHandler.Upsert).Methods("PUT")
 
+	// Custom invoice templates (per building); the built-in one is the fallback.
+	api.HandleFunc("/billing/templates/builtin", invoiceTemplateHandler.Builtin).Methods("GET")
+	api.HandleFunc("/billing/templates/{building_id}", invoiceTemplateHandler.Get).Methods("GET")
+	api.HandleFunc("/billing/templates/{building_id}", invoiceTemplateHandler.Upsert).Methods("PUT")
+	api.HandleFunc("/billing/templates/{building_id}", invoiceTemplateHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/billing/templates/{building_id}/preview", invoiceTemplateHandler.Preview).Methods("POST")
+
+	// Time-of-use (HT/NT) grid tariff windows and holiday calendar (per building).
+	api.HandleFunc("/billing/tariff-windows", tariffWindowHandler.ListWindows).Methods("GET")
+	api.HandleFunc("/billing/tariff-windows", tariffWindowHandler.CreateWindow).Methods("POST")
+	api.HandleFunc("/billing/tariff-windows/{id}", tariffWindowHandler.UpdateWindow).Methods("PUT")
+	api.HandleFunc("/billing/tariff-windows/{id}", tariffWindowHandler.DeleteWindow).Methods("DELETE")
+	api.HandleFunc("/billing/tariff-holidays", tariffWindowHandler.ListHolidays).Methods("GET")
+	api.HandleFunc("/billing/tariff-holidays", tariffWindowHandler.CreateHoliday).Methods("POST")
+	api.HandleFunc("/billing/tariff-holidays/{id}", tariffWindowHandler.DeleteHoliday).Methods("DELETE")
+
+	// Grid price components (energy, grid usage, levies, fees, metering) per building.
+	api.HandleFunc("/billing/price-components", priceComponentHandler.List).Methods("GET")
+	api.HandleFunc("/billing/price-components", priceComponentHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/price-components/{id}", priceComponentHandler.Update).Methods("PUT")
+	api.HandleFunc("/billing/price-components/{id}", priceComponentHandler.Delete).Methods("DELETE")
+
+	// VAT codes and the code each kind of invoice line gets
+	api.HandleFunc("/billing/vat-codes", vatCodeHandler.List).Methods("GET")
+	api.HandleFunc("/billing/vat-codes", vatCodeHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/vat-codes/{id}", vatCodeHandler.Update).Methods("PUT")
+	api.HandleFunc("/billing/vat-codes/{id}", vatCodeHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/billing/vat-code-assignments", vatCodeHandler.ListAssignments).Methods("GET")
+	api.HandleFunc("/billing/vat-code-assignments", vatCodeHandler.UpdateAssignments).Methods("PUT")
+
+	// Water, heat and gas prices per building.
+	api.HandleFunc("/billing/utility-prices", utilityPriceHandler.List).Methods("GET")
+	api.HandleFunc("/billing/utility-prices", utilityPriceHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/utility-prices/{id}", utilityPriceHandler.Update).Methods("PUT")
+	api.HandleFunc("/billing/utility-prices/{id}", utilityPriceHandler.Delete).Methods("DELETE")
+
+	// Peak-demand (kW) tariffs per user or meter.
+	api.HandleFunc("/billing/demand-tariffs", demandTariffHandler.List).Methods("GET")
+	api.HandleFunc("/billing/demand-tariffs", demandTariffHandler.Create).Methods("POST")
+	api.HandleFunc("/billing/demand-tariffs/{id}", demandTariffHandler.Update).Methods("PUT")
+	api.HandleFunc("/billing/demand-tariffs/{id}", demandTariffHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/billing/demand-tariffs/{id}/peak", demandTariffHandler.Peak).Methods("GET")
+
+	// LEG (local electricity community): communities, participants, balance and billing.
+	api.HandleFunc("/billing/leg-communities", billingHandler.ListLEGCommunities).Methods("GET")
+	api.HandleFunc("/billing/leg-communities", billingHandler.CreateLEGCommunity).Methods("POST")
+	api.HandleFunc("/billing/leg-communities/{id}", billingHandler.GetLEGCommunity).Methods("GET")
+	api.HandleFunc("/billing/leg-communities/{id}", billingHandler.UpdateLEGCommunity).Methods("PUT")
+	api.HandleFunc("/billing/leg-communities/{id}", billingHandler.DeleteLEGCommunity).Methods("DELETE")
+	api.HandleFunc("/billing/leg-communities/{id}/participants", billingHandler.CreateLEGParticipant).Methods("POST")
+	api.HandleFunc("/billing/leg-participants/{id}", billingHandler.UpdateLEGParticipant).Methods("PUT")
+	api.HandleFunc("/billing/leg-participants/{id}", billingHandler.DeleteLEGParticipant).Methods("DELETE")
+	api.HandleFunc("/billing/leg-communities/{id}/balance", billingHandler.LEGBalance).Methods("GET")
+	api.HandleFunc("/billing/leg-communities/{id}/invoices", billingHandler.IssueLEGInvoices).Methods("POST")
+	api.HandleFunc("/billing/leg-communities/{id}/producer-credits", billingHandler.IssueLEGProducerCredits).Methods("POST")
+
+	// Producer accounts (PV owners and investors) and their credit statements.
+	api.HandleFunc("/billing/producer-accounts", billingHandler.ListProducerAccounts).Methods("GET")
+	api.HandleFunc("/billing/producer-accounts", billingHandler.CreateProducerAccount).Methods("POST")
+	api.HandleFunc("/billing/producer-accounts/{id}", billingHandler.UpdateProducerAccount).Methods("PUT")
+	api.HandleFunc("/billing/producer-accounts/{id}", billingHandler.DeleteProducerAccount).Methods("DELETE")
+	api.HandleFunc("/billing/producer-accounts/{id}/meters", billingHandler.AddProducerMeter).Methods("POST")
+	api.HandleFunc("/billing/producer-meters/{id}", billingHandler.DeleteProducerMeter).Methods("DELETE")
+	api.HandleFunc("/billing/producer-statements", billingHandler.IssueProducerStatements).Methods("POST")
+	api.HandleFunc("/billing/producer-statements/{id}/payout", billingHandler.RecordProducerPayout).Methods("POST")
+
+	// Recalculation of issued invoices after late or corrected data.
+	api.HandleFunc("/billing/recalculations", billingHandler.ListRecalculations).Methods("GET")
+	api.HandleFunc("/billing/recalculations", billingHandler.RecalculateInvoices).Methods("POST")
+	api.HandleFunc("/billing/recalculations/{id}/correct", billingHandler.CorrectRecalculation).Methods("POST")
+	api.HandleFunc("/billing/recalculations/{id}/dismiss", billingHandler.DismissRecalculation).Methods("POST")
+
+	// Dynamic (spot) grid prices
+	api.HandleFunc("/billing/spot-prices", spotPriceHandler.ListPrices).Methods("GET")
+	api.HandleFunc("/billing/spot-prices/areas", spotPriceHandler.ListAreas).Methods("GET")
+	api.HandleFunc("/billing/spot-prices/coverage", spotPriceHandler.Coverage).Methods("GET")
+	api.HandleFunc("/billing/spot-prices/import", spotPriceHandler.ImportPrices).Methods("POST")
+
 	// Shared Meters API
 	api.HandleFunc("/shared-meters", sharedMeterHandler.List).Methods("GET")
 	api.HandleFunc("/shared-meters", sharedMeterHandler.Create).Methods("POST")
@@ -376,8 +537,10 @@ func main() {
 	api.HandleFunc("/dashboard/energy-flow-live", dashboardHandler.GetEnergyFlowLive).Methods("GET")
 	api.HandleFunc("/dashboard/live-meters", dashboardHandler.GetLiveMeters).Methods("GET")
 
-	// Export route
+	// Export routes and the ledger accounts of the accounting journal export
 	api.HandleFunc("/export/data", exportHandler.ExportData).Methods("GET")
+	api.HandleFunc("/export/accounting-settings", exportHandler.GetAccountingSettings).Methods("GET")
+	api.HandleFunc("/export/accounting-settings", exportHandler.UpdateAccountingSettings).Methods("PUT")
 
 	// Origins come from config (CORS_ALLOWED_ORIGINS env, default localhost dev
 	// ports). No "*": with AllowCredentials it would let any site issue
@@ -429,6 +592,12 @@ func main() {
 			backupScheduler.Stop()
 		}
 
+		// Stop spot price watcher
+		spotPriceWatcher.Stop()
+
+		// Stop dunning service
+		dunningService.Stop()
+
 		// Create a deadline for shutdown
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
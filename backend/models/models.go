@@ -33,6 +33,10 @@ type User struct {
 	IsActive          bool      `json:"is_active"`
 	RentStartDate     *string   `json:"rent_start_date"`
 	RentEndDate       *string   `json:"rent_end_date"`
+	// ApartmentArea (m²) and UnitCount are the keys for splitting shared
+	// meters by area or by units; nil on update keeps the stored value.
+	ApartmentArea     *float64  `json:"apartment_area"`
+	UnitCount         *int      `json:"unit_count"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
@@ -87,6 +91,9 @@ type Meter struct {
 	ReplacementDate   *time.Time `json:"replacement_date"`
 	ReplacementNotes  string     `json:"replacement_notes"`
 	SortOrder         int        `json:"sort_order"`
+	// ReadingFactor turns the raw counter into the billing unit (e.g. 0.001
+	// for a water meter that counts litres billed per m³). Defaults to 1.
+	ReadingFactor     float64    `json:"reading_factor"`
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at"`
 }
@@ -176,6 +183,15 @@ type BillingSettings struct {
 	//   "total"   — split solar across the building's true total consumption so
 	//               every consumed Watt (metered or not) draws the same solar share.
 	SolarSplitMode           string    `json:"solar_split_mode"`
+	// TariffWindowsEnabled prices the grid share per 15-min interval by the
+	// building's time-of-use windows (HT/NT) instead of NormalPowerPrice.
+	TariffWindowsEnabled     bool      `json:"tariff_windows_enabled"`
+	// GridPricingMode is "fixed" (NormalPowerPrice or tariff windows) or "spot":
+	// each 15-min grid share is priced at the imported spot price of
+	// SpotPriceArea plus SpotPriceMarkup (grid fees, levies) per kWh.
+	GridPricingMode          string    `json:"grid_pricing_mode"`
+	SpotPriceArea            string    `json:"spot_price_area"`
+	SpotPriceMarkup          float64   `json:"spot_price_markup"`
 	VATIncluded              bool      `json:"vat_included"`
 	VATRate                  float64   `json:"vat_rate"`
 	Currency                 string    `json:"currency"`
@@ -214,7 +230,7 @@ type Invoice struct {
 	VATRate       float64       `json:"vat_rate"`
 	VATIncluded   bool          `json:"vat_included"`
 	Currency      string        `json:"currency"`
-	Status        string        `json:"status"`
+	Status        string        `json:"status"` // "issued" | "cancelled" (reversed by a credit note) | "credited" (the credit note itself)
 	PaymentStatus string        `json:"payment_status"` // "unpaid" | "partial" | "paid"
 	PaidAmount    float64       `json:"paid_amount"`
 	PaidAt        *string       `json:"paid_at,omitempty"`
@@ -223,6 +239,196 @@ type Invoice struct {
 	Items         []InvoiceItem `json:"items,omitempty"`
 	User          *User         `json:"user,omitempty"`
 	GeneratedAt   time.Time     `json:"generated_at"`
+
+	// Cancellation chain: a credit note reverses OriginalInvoiceID, a
+	// replacement invoice (DocumentType "invoice") corrects it.
+	DocumentType          string       `json:"document_type"` // "invoice" | "credit_note" | "supplementary" | "advance" | "settlement"
+	OriginalInvoiceID     *int         `json:"original_invoice_id,omitempty"`
+	OriginalInvoiceNumber string       `json:"original_invoice_number,omitempty"`
+	CancelledAt           *string      `json:"cancelled_at,omitempty"`
+	CancellationReason    string       `json:"cancellation_reason,omitempty"`
+	Related               []InvoiceRef `json:"related,omitempty"`
+	// Correction marks a credit note or supplementary invoice issued from a
+	// recalculation: it corrects OriginalInvoiceID instead of reversing it.
+	Correction            bool         `json:"correction,omitempty"`
+
+	// Dunning: payment deadline and the highest reminder level sent so far.
+	DueDate        string  `json:"due_date,omitempty"`
+	DunningLevel   int     `json:"dunning_level"`
+	LastReminderAt *string `json:"last_reminder_at,omitempty"`
+
+	// Payments booked on the document; PaidAmount is their sum.
+	Payments []Payment `json:"payments,omitempty"`
+
+	// VAT per code and rate, derived from the lines' VAT codes.
+	VATSummary []VATSummaryLine `json:"vat_summary,omitempty"`
+
+	// Approval workflow: a draft waits in the review queue with its warnings
+	// until an admin approves it.
+	ReviewStatus        string          `json:"review_status,omitempty"` // "draft" | "approved" | "sent"
+	ReviewWarnings      []ReviewWarning `json:"review_warnings,omitempty"`
+	AutoBillingConfigID *int            `json:"auto_billing_config_id,omitempty"`
+	ApprovedAt          *string         `json:"approved_at,omitempty"`
+	SentAt              *string         `json:"sent_at,omitempty"`
+}
+
+// Payment is one payment booked on a document. Source tells where it came
+// from: manual, camt (bank statement import), direct_debit, credit_note (an
+// offset by a credit note) or credit_balance (an earlier overpayment used up).
+type Payment struct {
+	ID                int     `json:"id"`
+	InvoiceID         int     `json:"invoice_id"`
+	InvoiceNumber     string  `json:"invoice_number,omitempty"`
+	UserID            int     `json:"user_id"`
+	PaymentDate       string  `json:"payment_date"` // YYYY-MM-DD
+	Amount            float64 `json:"amount"`
+	Currency          string  `json:"currency"`
+	Method            string  `json:"method"` // bank_transfer | cash | card | direct_debit | offset | other
+	Reference         string  `json:"reference,omitempty"`
+	Source            string  `json:"source"`
+	BankTransactionID *int    `json:"bank_transaction_id,omitempty"`
+	Notes             string  `json:"notes,omitempty"`
+	CreatedAt         string  `json:"created_at,omitempty"`
+}
+
+// VATCode is a VAT treatment invoice lines can carry. Without a rate the
+// building's VAT rate applies; exempt lines stay outside the VAT base.
+type VATCode struct {
+	ID     int      `json:"id"`
+	Code   string   `json:"code"`
+	Name   string   `json:"name"`
+	Rate   *float64 `json:"rate"`
+	Exempt bool     `json:"exempt"`
+}
+
+// VATCodeAssignment is the VAT code given to one kind of invoice line:
+// energy, charging, shared_meter, utility or custom_<category>.
+type VATCodeAssignment struct {
+	Source  string `json:"source"`
+	VATCode string `json:"vat_code"`
+}
+
+// VATSummaryLine is the VAT of an invoice's lines at one code and rate.
+type VATSummaryLine struct {
+	Code   string  `json:"code"`
+	Rate   float64 `json:"rate"`
+	Exempt bool    `json:"exempt,omitempty"`
+	Net    float64 `json:"net"`
+	VAT    float64 `json:"vat"`
+	Gross  float64 `json:"gross"`
+}
+
+// JournalEntry is one booking of the accounting export: Amount moves from
+// CreditAccount to DebitAccount.
+type JournalEntry struct {
+	Date           string  `json:"date"` // YYYY-MM-DD
+	DocumentNumber string  `json:"document_number"`
+	Kind           string  `json:"kind"` // document type, "reminder_fee", "payment" or "payout"
+	Text           string  `json:"text"`
+	DebitAccount   string  `json:"debit_account"`
+	CreditAccount  string  `json:"credit_account"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	VATCode        string  `json:"vat_code,omitempty"`
+}
+
+// AccountingSetting is one ledger account mapping or setting of the
+// accounting export, e.g. receivables → 1100.
+type AccountingSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// CreditBalance is what a tenant paid beyond the amount due of their
+// documents, in one currency, not yet used up by a later invoice.
+type CreditBalance struct {
+	UserID   int     `json:"user_id"`
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+// AccountStatement lists a tenant's documents and payments in one currency
+// over a date range with the running balance. A positive balance is owed by
+// the tenant, a negative one is in their favour.
+type AccountStatement struct {
+	UserID         int                    `json:"user_id"`
+	UserName       string                 `json:"user_name"`
+	Address        []string               `json:"address,omitempty"`
+	Language       string                 `json:"language"`
+	BuildingID     int                    `json:"building_id"`
+	Currency       string                 `json:"currency"`
+	From           string                 `json:"from"` // YYYY-MM-DD
+	To             string                 `json:"to"`   // YYYY-MM-DD
+	OpeningBalance float64                `json:"opening_balance"`
+	TotalDebit     float64                `json:"total_debit"`
+	TotalCredit    float64                `json:"total_credit"`
+	ClosingBalance float64                `json:"closing_balance"`
+	Lines          []AccountStatementLine `json:"lines"`
+}
+
+// AccountStatementLine is one entry of an account statement: a document
+// (debit, or credit for credit notes), a reminder fee or a payment.
+type AccountStatementLine struct {
+	Date        string  `json:"date"` // YYYY-MM-DD
+	Kind        string  `json:"kind"` // a document type, reminder_fee, payment or payout
+	InvoiceID   int     `json:"invoice_id,omitempty"`
+	Reference   string  `json:"reference"`
+	Description string  `json:"description"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+	Balance     float64 `json:"balance"`
+}
+
+// InvoiceArchiveEntry records one archived document: the copy kept in the
+// archive directory, its SHA-256 and the chain hash linking it to the
+// entry before it.
+type InvoiceArchiveEntry struct {
+	ID            int    `json:"id"`
+	InvoiceID     int    `json:"invoice_id"`
+	InvoiceNumber string `json:"invoice_number"`
+	FileName      string `json:"file_name"`
+	Format        string `json:"format"` // pdfa-3b | pdf
+	SHA256        string `json:"sha256"`
+	PreviousHash  string `json:"previous_hash"` // chain hash of the entry before, "" for the first
+	ChainHash     string `json:"chain_hash"`
+	ArchivedAt    string `json:"archived_at"`
+}
+
+// ArchiveVerification is the result of checking the invoice archive: every
+// entry's file against its hash and every entry against the chain.
+type ArchiveVerification struct {
+	Valid      bool             `json:"valid"`
+	Checked    int              `json:"checked"`
+	Problems   []ArchiveProblem `json:"problems"`
+	Unarchived []InvoiceRef     `json:"unarchived"` // issued documents without an archive entry
+}
+
+// ArchiveProblem is an archive entry that failed verification, or the archive
+// head when it does not match the last entry (EntryID 0).
+type ArchiveProblem struct {
+	EntryID       int    `json:"entry_id"`
+	InvoiceID     int    `json:"invoice_id"`
+	InvoiceNumber string `json:"invoice_number"`
+	Problem       string `json:"problem"` // missing | modified | chain_broken | head_mismatch
+	Detail        string `json:"detail"`
+}
+
+// ReviewWarning flags something on a draft invoice an admin should check
+// before approving it.
+type ReviewWarning struct {
+	Code    string `json:"code"` // zero_consumption | consumption_jump | missing_data
+	Message string `json:"message"`
+}
+
+// InvoiceRef is a compact pointer to another document in an invoice's
+// cancellation chain (its credit note, replacement or original).
+type InvoiceRef struct {
+	ID            int     `json:"id"`
+	InvoiceNumber string  `json:"invoice_number"`
+	DocumentType  string  `json:"document_type"`
+	Status        string  `json:"status"`
+	TotalAmount   float64 `json:"total_amount"`
+	GeneratedAt   string  `json:"generated_at"`
 }
 
 type InvoiceItem struct {
@@ -233,6 +439,10 @@ type InvoiceItem struct {
 	UnitPrice   float64 `json:"unit_price"`
 	TotalPrice  float64 `json:"total_price"`
 	ItemType    string  `json:"item_type"`
+	// VAT code of a billable line and the rate it was billed at; empty on
+	// lines billed before VAT codes existed (they carry the invoice's rate).
+	VATCode string  `json:"vat_code,omitempty"`
+	VATRate float64 `json:"vat_rate,omitempty"`
 }
 
 // CustomLineItem represents a custom charge item that can be added to invoices
@@ -243,11 +453,360 @@ type CustomLineItem struct {
 	Amount      float64   `json:"amount"`
 	Frequency   string    `json:"frequency"` // once, monthly, quarterly, yearly
 	Category    string    `json:"category"`  // meter_rent, maintenance, service, other
+	VATCode     string    `json:"vat_code"`  // empty: the code assigned to the category
 	IsActive    bool      `json:"is_active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// TariffWindow is a time-of-use grid tariff window (e.g. HT weekdays 06–22).
+// WeekdayMask: bit 0 = Monday … bit 6 = Sunday. EndTime is exclusive; an end at
+// or before the start wraps past midnight (equal times = whole day).
+type TariffWindow struct {
+	ID                int       `json:"id"`
+	BuildingID        int       `json:"building_id"`
+	Name              string    `json:"name"`
+	WeekdayMask       int       `json:"weekday_mask"`
+	StartTime         string    `json:"start_time"` // "HH:MM"
+	EndTime           string    `json:"end_time"`   // "HH:MM"
+	AppliesOnHolidays bool      `json:"applies_on_holidays"`
+	Price             float64   `json:"price"`
+	SortOrder         int       `json:"sort_order"`
+	IsActive          bool      `json:"is_active"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TariffHoliday is a public holiday on which only windows flagged
+// AppliesOnHolidays are in effect.
+type TariffHoliday struct {
+	ID          int    `json:"id"`
+	BuildingID  int    `json:"building_id"`
+	HolidayDate string `json:"holiday_date"` // "YYYY-MM-DD"
+	Name        string `json:"name"`
+}
+
+// PriceComponent is one part of a building's grid tariff (energy, grid usage,
+// federal levy, municipal fee, metering) priced per kWh. While components are
+// valid they replace the single NormalPowerPrice with one invoice line each.
+type PriceComponent struct {
+	ID            int       `json:"id"`
+	BuildingID    int       `json:"building_id"`
+	Name          string    `json:"name"`
+	ComponentType string    `json:"component_type"`
+	Price         float64   `json:"price"`
+	VATExempt     bool      `json:"vat_exempt"` // pass-through fee outside the VAT base
+	ValidFrom     string    `json:"valid_from"` // "YYYY-MM-DD"
+	ValidTo       string    `json:"valid_to"`   // "YYYY-MM-DD", "" = open-ended
+	SortOrder     int       `json:"sort_order"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// UtilityPrice is the price of a building's water, heat or gas per unit of
+// the utility's meters, plus a monthly base fee per meter, for its validity.
+type UtilityPrice struct {
+	ID         int       `json:"id"`
+	BuildingID int       `json:"building_id"`
+	Utility    string    `json:"utility"` // cold_water | hot_water | heat | gas
+	Unit       string    `json:"unit"`    // "" = the utility's default unit
+	Price      float64   `json:"price"`
+	BaseFee    float64   `json:"base_fee"`
+	ValidFrom  string    `json:"valid_from"` // "YYYY-MM-DD"
+	ValidTo    string    `json:"valid_to"`   // "YYYY-MM-DD", "" = open-ended
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RecalculationLine compares one invoice line as billed (including earlier
+// corrections) with the same line after billing the period again.
+type RecalculationLine struct {
+	ItemType         string  `json:"item_type"`
+	Label            string  `json:"label"`
+	BilledQuantity   float64 `json:"billed_quantity"`
+	NewQuantity      float64 `json:"new_quantity"`
+	BilledAmount     float64 `json:"billed_amount"`
+	NewAmount        float64 `json:"new_amount"`
+	UnitPrice        float64 `json:"unit_price"`
+	QuantityChange   float64 `json:"quantity_change"`
+	AmountDifference float64 `json:"amount_difference"`
+}
+
+// InvoiceRecalculation is the result of billing an issued invoice's period
+// again after late or corrected data, and how it was resolved.
+type InvoiceRecalculation struct {
+	ID                  int                 `json:"id"`
+	InvoiceID           int                 `json:"invoice_id"`
+	InvoiceNumber       string              `json:"invoice_number"`
+	UserID              int                 `json:"user_id"`
+	UserName            string              `json:"user_name"`
+	BuildingID          int                 `json:"building_id"`
+	PeriodStart         string              `json:"period_start"`
+	PeriodEnd           string              `json:"period_end"`
+	Currency            string              `json:"currency"`
+	Status              string              `json:"status"` // pending | corrected | dismissed
+	BilledTotal         float64             `json:"billed_total"`
+	BilledNet           float64             `json:"billed_net"`
+	BilledVAT           float64             `json:"billed_vat"`
+	NewTotal            float64             `json:"new_total"`
+	NewNet              float64             `json:"new_net"`
+	NewVAT              float64             `json:"new_vat"`
+	Difference          float64             `json:"difference"`
+	Lines               []RecalculationLine `json:"lines"`
+	Error               string              `json:"error,omitempty"` // the period could not be billed again
+	CorrectionInvoiceID *int                `json:"correction_invoice_id,omitempty"`
+	CorrectionNumber    string              `json:"correction_number,omitempty"`
+	Note                string              `json:"note,omitempty"`
+	CreatedAt           time.Time           `json:"created_at"`
+	ResolvedAt          *string             `json:"resolved_at,omitempty"`
+}
+
+// DemandTariff charges a user (all their apartment meters) or a single meter
+// for its peak demand: PricePerKW per kW and month, applied to the highest
+// 15-minute average power of the month or the mean of the top PeakCount.
+type DemandTariff struct {
+	ID         int       `json:"id"`
+	BuildingID int       `json:"building_id"`
+	UserID     *int      `json:"user_id,omitempty"`
+	MeterID    *int      `json:"meter_id,omitempty"`
+	Name       string    `json:"name"`
+	PricePerKW float64   `json:"price_per_kw"`
+	PeakCount  int       `json:"peak_count"`
+	ValidFrom  string    `json:"valid_from"` // "YYYY-MM-DD"
+	ValidTo    string    `json:"valid_to"`   // "YYYY-MM-DD", "" = open-ended
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// LEGCommunity is a local electricity community (LEG): consumers and producers
+// in several buildings sharing local production over the public grid.
+type LEGCommunity struct {
+	ID               int              `json:"id"`
+	Name             string           `json:"name"`
+	DSOName          string           `json:"dso_name"`
+	CommunityCode    string           `json:"community_code"`     // identifier at the DSO
+	LocalEnergyPrice float64          `json:"local_energy_price"` // per local kWh, paid by consumers
+	ProducerPrice    float64          `json:"producer_price"`     // per local kWh, credited to producers
+	GridFeePrice     float64          `json:"grid_fee_price"`     // full grid usage fee per kWh
+	GridFeeDiscount  float64          `json:"grid_fee_discount"`  // percent off the grid fee on local kWh
+	AllocationMethod string           `json:"allocation_method"`  // "proportional" or "equal"
+	IsActive         bool             `json:"is_active"`
+	Notes            string           `json:"notes"`
+	Participants     []LEGParticipant `json:"participants,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// LEGParticipant is one metering point of a LEG community.
+type LEGParticipant struct {
+	ID          int       `json:"id"`
+	CommunityID int       `json:"community_id"`
+	BuildingID  int       `json:"building_id"`
+	MeterID     int       `json:"meter_id"`
+	MeterName   string    `json:"meter_name,omitempty"`
+	UserID      *int      `json:"user_id,omitempty"`
+	Role        string    `json:"role"`       // "consumer" or "producer"
+	ValidFrom   string    `json:"valid_from"` // "YYYY-MM-DD"
+	ValidTo     string    `json:"valid_to"`   // "YYYY-MM-DD", "" = open-ended
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ProducerAccount is the owner or investor of PV systems in a building who is
+// paid for their production by periodic credit statements. A nil price
+// follows the building's pricing (SolarPowerPrice, VZEVExportPrice).
+type ProducerAccount struct {
+	ID                   int             `json:"id"`
+	UserID               int             `json:"user_id"` // recipient of the statements
+	BuildingID           int             `json:"building_id"`
+	Name                 string          `json:"name"`
+	SelfConsumptionPrice *float64        `json:"self_consumption_price"` // per kWh sold to tenants
+	ExportPrice          *float64        `json:"export_price"`           // per kWh fed into the grid
+	VATRate              float64         `json:"vat_rate"`               // 0 for producers not registered for VAT
+	IsActive             bool            `json:"is_active"`
+	Notes                string          `json:"notes"`
+	Meters               []ProducerMeter `json:"meters,omitempty"`
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+// ProducerMeter links a solar meter to a producer account.
+type ProducerMeter struct {
+	ID                int       `json:"id"`
+	ProducerAccountID int       `json:"producer_account_id"`
+	MeterID           int       `json:"meter_id"`
+	MeterName         string    `json:"meter_name,omitempty"`
+	SharePercent      float64   `json:"share_percent"`
+	ValidFrom         string    `json:"valid_from"` // "YYYY-MM-DD"
+	ValidTo           string    `json:"valid_to"`   // "YYYY-MM-DD", "" = open-ended
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// AdvancePlan is a tenant's fixed monthly advance payment (Akonto), invoiced
+// ahead of consumption and netted against it by the annual settlement.
+type AdvancePlan struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	BuildingID    int       `json:"building_id"`
+	MonthlyAmount float64   `json:"monthly_amount"`
+	Currency      string    `json:"currency"`
+	ValidFrom     string    `json:"valid_from"`
+	ValidTo       string    `json:"valid_to"` // inclusive; "" = open-ended
+	IsActive      bool      `json:"is_active"`
+	Notes         string    `json:"notes"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DunningSettings holds a building's payment terms and reminder schedule.
+type DunningSettings struct {
+	ID                   int       `json:"id"`
+	BuildingID           int       `json:"building_id"`
+	PaymentTermsDays     int       `json:"payment_terms_days"`
+	Reminder1AfterDays   int       `json:"reminder1_after_days"`    // days past the due date
+	Reminder2AfterDays   int       `json:"reminder2_after_days"`    // days past reminder 1
+	FinalNoticeAfterDays int       `json:"final_notice_after_days"` // days past reminder 2
+	ReminderDeadlineDays int       `json:"reminder_deadline_days"`  // new payment deadline printed on a reminder
+	Reminder1Fee         float64   `json:"reminder1_fee"`
+	Reminder2Fee         float64   `json:"reminder2_fee"`
+	FinalNoticeFee       float64   `json:"final_notice_fee"`
+	AutoEscalate         bool      `json:"auto_escalate"`
+	AutoSendEmail        bool      `json:"auto_send_email"`
+	SenderName           string    `json:"sender_name"`
+	SenderAddress        string    `json:"sender_address"`
+	SenderCity           string    `json:"sender_city"`
+	SenderZip            string    `json:"sender_zip"`
+	SenderCountry        string    `json:"sender_country"`
+	BankName             string    `json:"bank_name"`
+	BankIBAN             string    `json:"bank_iban"`
+	BankAccountHolder    string    `json:"bank_account_holder"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// InvoiceReminder is one payment reminder sent for an invoice.
+type InvoiceReminder struct {
+	ID                int       `json:"id"`
+	InvoiceID         int       `json:"invoice_id"`
+	Level             int       `json:"level"` // 1 = reminder, 2 = second reminder, 3 = final notice
+	ReminderNumber    string    `json:"reminder_number"`
+	OutstandingAmount float64   `json:"outstanding_amount"`
+	Fee               float64   `json:"fee"`
+	TotalDue          float64   `json:"total_due"` // outstanding + fees of all levels so far
+	Deadline          string    `json:"deadline"`
+	PDFPath           string    `json:"pdf_path,omitempty"`
+	EmailedAt         *string   `json:"emailed_at,omitempty"`
+	EmailError        string    `json:"email_error,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// BankImport is one uploaded camt.053/camt.054 bank statement file.
+type BankImport struct {
+	ID           int       `json:"id"`
+	FileName     string    `json:"file_name"`
+	MessageID    string    `json:"message_id"`
+	Format       string    `json:"format"` // camt.053 or camt.054
+	AccountIBAN  string    `json:"account_iban"`
+	Transactions int       `json:"transactions"`
+	Duplicates   int       `json:"duplicates"` // already imported from an earlier file
+	Matched      int       `json:"matched"`
+	Unmatched    int       `json:"unmatched"`
+	ImportedAt   time.Time `json:"imported_at"`
+}
+
+// BankTransaction is one booked credit or debit from a bank statement and
+// the invoice it was matched to, if any.
+type BankTransaction struct {
+	ID             int     `json:"id"`
+	ImportID       int     `json:"import_id"`
+	BookingDate    string  `json:"booking_date"`
+	ValueDate      string  `json:"value_date"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	CreditDebit    string  `json:"credit_debit"` // CRDT or DBIT
+	Reference      string  `json:"reference"`
+	ReferenceType  string  `json:"reference_type"` // QRR, SCOR or empty
+	EndToEndID     string  `json:"end_to_end_id"`
+	DebtorName     string  `json:"debtor_name"`
+	DebtorIBAN     string  `json:"debtor_iban"`
+	RemittanceInfo string  `json:"remittance_info"`
+	Status         string  `json:"status"` // matched, unmatched, assigned, ignored
+	InvoiceID      *int    `json:"invoice_id,omitempty"`
+	InvoiceNumber  string  `json:"invoice_number,omitempty"`
+	MatchMethod    string  `json:"match_method,omitempty"`
+	ReviewNote     string  `json:"review_note,omitempty"`
+	MatchedAt      *string `json:"matched_at,omitempty"`
+}
+
+// DirectDebitMandate is a tenant's signed authorisation to collect invoices
+// from their bank account (User.BankIBAN) by direct debit.
+type DirectDebitMandate struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	UserName      string    `json:"user_name,omitempty"`
+	MandateID     string    `json:"mandate_id"`
+	Scheme        string    `json:"scheme"` // lsv, chdd or sepa
+	SignatureDate string    `json:"signature_date"`
+	Status        string    `json:"status"` // active or revoked
+	Notes         string    `json:"notes"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DirectDebitExport is one generated pain.008 collection file.
+type DirectDebitExport struct {
+	ID             int       `json:"id"`
+	MessageID      string    `json:"message_id"`
+	Scheme         string    `json:"scheme"`
+	CollectionDate string    `json:"collection_date"`
+	CreditorName   string    `json:"creditor_name"`
+	CreditorIBAN   string    `json:"creditor_iban"`
+	CreditorID     string    `json:"creditor_id"`
+	Currency       string    `json:"currency"`
+	Transactions   int       `json:"transactions"`
+	TotalAmount    float64   `json:"total_amount"`
+	InvoiceIDs     []int     `json:"invoice_ids"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// InvoiceNumberScheme defines how invoice numbers are formed and counted.
+// Pattern placeholders: {PREFIX}, {YYYY}, {YY}, {BUILDING} and {COUNTER}
+// (zero-padded to CounterDigits). The counter restarts every calendar year
+// with ResetPolicy "yearly" and never with "never"; PerBuilding keeps a
+// separate counter for each assigned building.
+type InvoiceNumberScheme struct {
+	ID               int                    `json:"id"`
+	Name             string                 `json:"name"`
+	Prefix           string                 `json:"prefix"`
+	CreditNotePrefix string                 `json:"credit_note_prefix"` // replaces {PREFIX} in credit notes, which count separately
+	Pattern          string                 `json:"pattern"`
+	CounterDigits    int                    `json:"counter_digits"`
+	ResetPolicy      string                 `json:"reset_policy"`
+	PerBuilding      bool                   `json:"per_building"`
+	Buildings        []NumberSchemeBuilding `json:"buildings"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+}
+
+// NumberSchemeBuilding assigns a building to a numbering scheme, with the
+// short code that replaces {BUILDING} (the building ID when empty).
+type NumberSchemeBuilding struct {
+	BuildingID   int    `json:"building_id"`
+	BuildingCode string `json:"building_code"`
+}
+
+// SpotPrice is one imported dynamic grid price, per kWh, for the 15-minute
+// interval starting at IntervalStart.
+type SpotPrice struct {
+	PriceArea     string    `json:"price_area"`
+	IntervalStart time.Time `json:"interval_start"`
+	Price         float64   `json:"price"`
+	Source        string    `json:"source"`
+}
+
 type AutoBillingConfig struct {
 	ID                 int        `json:"id"`
 	Name               string     `json:"name"`
@@ -261,8 +820,10 @@ type AutoBillingConfig struct {
 	IsVZEV             bool       `json:"is_vzev"`
 	BillingMode        string     `json:"billing_mode,omitempty"` // "apartments" (default), "building", "charger"
 	BillContent        string     `json:"bill_content,omitempty"` // "both" (default), "meters", "chargers"
+	InvoiceType        string     `json:"invoice_type,omitempty"` // "consumption" (default) or "advance" (Akonto)
 	ChargerID          *int       `json:"charger_id,omitempty"`   // required when BillingMode == "charger"
 	AutoSendEmail      bool       `json:"auto_send_email"`        // when true, e-mail the generated PDF to the bill recipient
+	RequireApproval    bool       `json:"require_approval"`       // when true, invoices wait as drafts until approved
 	LastRun            *time.Time `json:"last_run,omitempty"`
 	NextRun            *time.Time `json:"next_run,omitempty"`
 	SenderName         string     `json:"sender_name,omitempty"`
@@ -273,6 +834,7 @@ type AutoBillingConfig struct {
 	BankName           string     `json:"bank_name,omitempty"`
 	BankIBAN           string     `json:"bank_iban,omitempty"`
 	BankAccountHolder  string     `json:"bank_account_holder,omitempty"`
+	NumberSchemeID     *int       `json:"number_scheme_id,omitempty"`
 	CreatedAt          time.Time  `json:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at"`
 }
@@ -280,14 +842,15 @@ type AutoBillingConfig struct {
 // BillLayout customizes the editable parts of the invoice main page (the QR
 // page is intentionally not customizable to keep Swiss QR-bill compliance).
 type BillLayout struct {
-	ID           int       `json:"id"`
-	BuildingID   int       `json:"building_id"`
-	Title        string    `json:"title"`         // overrides translated "Invoice"
-	IntroText    string    `json:"intro_text"`    // optional paragraph before line items
-	FooterText   string    `json:"footer_text"`   // optional paragraph after line items
-	PrimaryColor string    `json:"primary_color"` // hex; overrides #667EEA accent
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                   int       `json:"id"`
+	BuildingID           int       `json:"building_id"`
+	Title                string    `json:"title"`                  // overrides translated "Invoice"
+	IntroText            string    `json:"intro_text"`             // optional paragraph before line items
+	FooterText           string    `json:"footer_text"`            // optional paragraph after line items
+	PrimaryColor         string    `json:"primary_color"`          // hex; overrides #667EEA accent
+	ShowConsumptionChart bool      `json:"show_consumption_chart"` // monthly consumption history of the last 12 months
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 }
 
 type AdminLog struct {
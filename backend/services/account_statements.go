@@ -0,0 +1,383 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Kinds of account statement lines besides the document types.
+const (
+	StatementReminderFee = "reminder_fee"
+	StatementPayment     = "payment"
+	StatementPayout      = "payout"
+)
+
+// AccountStatementFilter selects the statements to build: one tenant, or
+// every tenant billed in a building (then limited to that building's
+// documents). From may be empty for "since the first document"; To defaults
+// to today.
+type AccountStatementFilter struct {
+	UserID     int
+	BuildingID int
+	From       string // YYYY-MM-DD
+	To         string // YYYY-MM-DD
+}
+
+// BuildAccountStatements returns one statement per tenant and currency. Issued
+// documents are debits (credit notes and producer credits are credits),
+// reminder fees are debits and payments credits. Offsets by credit notes and
+// credit balances only move money between documents and are left out. Drafts
+// are never listed.
+func BuildAccountStatements(db *sql.DB, f AccountStatementFilter) ([]models.AccountStatement, error) {
+	if f.To == "" {
+		f.To = time.Now().Format("2006-01-02")
+	}
+	var userIDs []int
+	switch {
+	case f.UserID > 0:
+		userIDs = []int{f.UserID}
+	case f.BuildingID > 0:
+		rows, err := db.Query(`
+			SELECT DISTINCT user_id FROM invoices
+			WHERE building_id = ? AND status IN (?, ?, ?)
+			ORDER BY user_id
+		`, f.BuildingID, InvoiceStatusIssued, InvoiceStatusCancelled, InvoiceStatusCredited)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			userIDs = append(userIDs, id)
+		}
+		rows.Close()
+	default:
+		return nil, fmt.Errorf("a user or a building is required")
+	}
+
+	statements := []models.AccountStatement{}
+	for _, userID := range userIDs {
+		list, err := buildUserStatements(db, userID, f)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, list...)
+	}
+	return statements, nil
+}
+
+// buildUserStatements collects every entry of one tenant up to f.To, folds
+// those before f.From into the opening balance and splits by currency.
+func buildUserStatements(db *sql.DB, userID int, f AccountStatementFilter) ([]models.AccountStatement, error) {
+	var firstName, lastName, street, zip, city, language string
+	var buildingID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT first_name, last_name, COALESCE(address_street, ''), COALESCE(address_zip, ''), COALESCE(address_city, ''),
+		       COALESCE(language, 'de'), building_id
+		FROM users WHERE id = ?
+	`, userID).Scan(&firstName, &lastName, &street, &zip, &city, &language, &buildingID)
+	if err != nil {
+		return nil, err
+	}
+	tr := GetTranslations(language)
+
+	buildingFilter := ""
+	args := []interface{}{userID, InvoiceStatusIssued, InvoiceStatusCancelled, InvoiceStatusCredited}
+	if f.BuildingID > 0 {
+		buildingFilter = " AND i.building_id = ?"
+		args = append(args, f.BuildingID)
+	}
+
+	type entry struct {
+		currency string
+		order    int // documents before fees before payments on the same day
+		line     models.AccountStatementLine
+	}
+	var entries []entry
+
+	rows, err := db.Query(`
+		SELECT i.id, i.invoice_number, COALESCE(i.document_type, 'invoice'), i.generated_at, i.total_amount,
+		       UPPER(i.currency), i.period_start, i.period_end
+		FROM invoices i
+		WHERE i.user_id = ? AND i.status IN (?, ?, ?)`+buildingFilter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %v", err)
+	}
+	for rows.Next() {
+		var e entry
+		var generatedAt time.Time
+		var documentType, periodStart, periodEnd string
+		var total float64
+		if err := rows.Scan(&e.line.InvoiceID, &e.line.Reference, &documentType, &generatedAt, &total,
+			&e.currency, &periodStart, &periodEnd); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.line.Date = generatedAt.Format("2006-01-02")
+		e.line.Kind = documentType
+		e.line.Description = fmt.Sprintf("%s %s – %s", documentTitle(tr, documentType),
+			formatDate(storedDay(periodStart)), formatDate(storedDay(periodEnd)))
+		// A producer credit is owed to the tenant, like a credit note.
+		if documentType == DocumentTypeProducerCredit {
+			total = -total
+		}
+		if total >= 0 {
+			e.line.Debit = total
+		} else {
+			e.line.Credit = -total
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`
+		SELECT r.invoice_id, r.reminder_number, r.created_at, r.fee, UPPER(i.currency), i.invoice_number
+		FROM invoice_reminders r
+		JOIN invoices i ON i.id = r.invoice_id
+		WHERE r.fee > 0 AND i.user_id = ? AND i.status IN (?, ?, ?)`+buildingFilter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder fees: %v", err)
+	}
+	for rows.Next() {
+		e := entry{order: 1}
+		var createdAt time.Time
+		var invoiceNumber string
+		if err := rows.Scan(&e.line.InvoiceID, &e.line.Reference, &createdAt, &e.line.Debit, &e.currency, &invoiceNumber); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.line.Date = createdAt.Format("2006-01-02")
+		e.line.Kind = StatementReminderFee
+		e.line.Description = fmt.Sprintf("%s %s", tr.ReminderFee, invoiceNumber)
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	paymentArgs := append([]interface{}{PaymentSourceCreditNote, PaymentSourceCreditBalance}, args...)
+	rows, err = db.Query(`
+		SELECT p.invoice_id, i.invoice_number, p.payment_date, p.amount, UPPER(p.currency), p.reference,
+		       COALESCE(i.document_type, 'invoice')
+		FROM payments p
+		JOIN invoices i ON i.id = p.invoice_id
+		WHERE p.source NOT IN (?, ?) AND i.user_id = ? AND i.status IN (?, ?, ?)`+buildingFilter, paymentArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %v", err)
+	}
+	for rows.Next() {
+		e := entry{order: 2}
+		var amount float64
+		var reference, documentType string
+		if err := rows.Scan(&e.line.InvoiceID, &e.line.Reference, &e.line.Date, &amount, &e.currency,
+			&reference, &documentType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.line.Date = storedDay(e.line.Date)
+		if documentType == DocumentTypeProducerCredit {
+			e.line.Kind, e.line.Description, e.line.Debit = StatementPayout, tr.Payout, amount
+		} else {
+			e.line.Kind, e.line.Description, e.line.Credit = StatementPayment, tr.PaymentReceived, amount
+		}
+		if reference != "" {
+			e.line.Description += " " + reference
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		if entries[a].line.Date != entries[b].line.Date {
+			return entries[a].line.Date < entries[b].line.Date
+		}
+		if entries[a].order != entries[b].order {
+			return entries[a].order < entries[b].order
+		}
+		return entries[a].line.Reference < entries[b].line.Reference
+	})
+
+	byCurrency := map[string]*models.AccountStatement{}
+	var currencies []string
+	for _, e := range entries {
+		if e.line.Date > f.To {
+			continue
+		}
+		st := byCurrency[e.currency]
+		if st == nil {
+			st = &models.AccountStatement{
+				UserID:     userID,
+				UserName:   strings.TrimSpace(firstName + " " + lastName),
+				Language:   language,
+				BuildingID: f.BuildingID,
+				Currency:   e.currency,
+				From:       f.From,
+				To:         f.To,
+				Lines:      []models.AccountStatementLine{},
+			}
+			for _, l := range []string{street, strings.TrimSpace(zip + " " + city)} {
+				if l != "" {
+					st.Address = append(st.Address, l)
+				}
+			}
+			if st.BuildingID == 0 && buildingID.Valid {
+				st.BuildingID = int(buildingID.Int64)
+			}
+			byCurrency[e.currency] = st
+			currencies = append(currencies, e.currency)
+		}
+		if e.line.Date < f.From {
+			st.OpeningBalance += e.line.Debit - e.line.Credit
+			continue
+		}
+		st.TotalDebit += e.line.Debit
+		st.TotalCredit += e.line.Credit
+		e.line.Balance = math.Round((st.OpeningBalance+st.TotalDebit-st.TotalCredit)*100) / 100
+		st.Lines = append(st.Lines, e.line)
+	}
+
+	sort.Strings(currencies)
+	list := []models.AccountStatement{}
+	for _, c := range currencies {
+		st := byCurrency[c]
+		st.OpeningBalance = math.Round(st.OpeningBalance*100) / 100
+		st.TotalDebit = math.Round(st.TotalDebit*100) / 100
+		st.TotalCredit = math.Round(st.TotalCredit*100) / 100
+		st.ClosingBalance = math.Round((st.OpeningBalance+st.TotalDebit-st.TotalCredit)*100) / 100
+		// Nothing in the range and nothing open: no statement.
+		if len(st.Lines) == 0 && math.Abs(st.OpeningBalance) < 0.005 {
+			continue
+		}
+		list = append(list, *st)
+	}
+	return list, nil
+}
+
+// documentTitle is the printed name of a document type.
+func documentTitle(tr InvoiceTranslations, documentType string) string {
+	switch documentType {
+	case DocumentTypeCreditNote:
+		return tr.CreditNote
+	case DocumentTypeAdvance:
+		return tr.AdvanceInvoice
+	case DocumentTypeSettlement:
+		return tr.SettlementInvoice
+	case DocumentTypeSupplementary:
+		return tr.SupplementaryInvoice
+	case DocumentTypeProducerCredit:
+		return tr.ProducerCredit
+	}
+	return tr.Invoice
+}
+
+// RenderAccountStatementsPDF renders statements into one PDF, each starting
+// on a new page in its tenant's language. The sender printed in the header is
+// the one of the building's dunning settings, the accent colour that of its
+// bill layout.
+func (pg *PDFGenerator) RenderAccountStatementsPDF(statements []models.AccountStatement) ([]byte, error) {
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no statements to render")
+	}
+	if pg.useNativeRenderer() {
+		return pg.renderNativeStatementsPDF(statements)
+	}
+	return pg.convertHTMLToPDFBytes(pg.generateStatementsHTML(statements))
+}
+
+// statementParties returns the sender and accent colour of a statement.
+func (pg *PDFGenerator) statementParties(st models.AccountStatement) (SenderInfo, string) {
+	s := LoadDunningSettings(pg.db, st.BuildingID)
+	sender := SenderInfo{Name: s.SenderName, Address: s.SenderAddress, City: s.SenderCity, Zip: s.SenderZip, Country: s.SenderCountry}
+	accent := pg.loadBillLayout(st.BuildingID).PrimaryColor
+	if accent == "" {
+		accent = "#667EEA"
+	}
+	return sender, accent
+}
+
+// statementPeriod is the printed date range of a statement.
+func statementPeriod(st models.AccountStatement) string {
+	if st.From == "" {
+		return "– " + formatDate(st.To)
+	}
+	return formatDate(st.From) + " – " + formatDate(st.To)
+}
+
+// statementAmount prints a debit or credit cell, blank when zero.
+func statementAmount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func (pg *PDFGenerator) generateStatementsHTML(statements []models.AccountStatement) string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<style>
+		@page { size: A4; margin: 15mm; }
+		body { font-family: Arial, sans-serif; font-size: 9pt; color: #000; margin: 0; }
+		.statement { page-break-after: always; }
+		.statement:last-child { page-break-after: auto; }
+		.header { display: flex; justify-content: space-between; padding-bottom: 8px; margin-bottom: 16px; }
+		.header h1 { margin: 0; font-size: 22pt; }
+		.header-right { text-align: right; font-size: 9pt; }
+		.parties { display: flex; justify-content: space-between; margin-bottom: 16px; }
+		table { width: 100%; border-collapse: collapse; }
+		th { background-color: #f9f9f9; padding: 6px; text-align: left; border-bottom: 2px solid #ddd; }
+		td { padding: 5px 6px; border-bottom: 1px solid #eee; }
+		.text-right { text-align: right; }
+		.balance-row td { font-weight: 600; background-color: #f9f9f9; }
+	</style>
+</head>
+<body>
+`)
+	for _, st := range statements {
+		tr := GetTranslations(st.Language)
+		sender, accent := pg.statementParties(st)
+		esc := template.HTMLEscapeString
+
+		senderHTML := ""
+		if sender.Name != "" {
+			senderHTML = fmt.Sprintf(`<div class="header-right"><strong>%s</strong><br>%s<br>%s<br>%s</div>`,
+				esc(sender.Name), esc(sender.Address), esc(strings.TrimSpace(sender.Zip+" "+sender.City)), esc(sender.Country))
+		}
+		fmt.Fprintf(&b, `<div class="statement">
+	<div class="header" style="border-bottom: 2px solid %s;"><h1 style="color: %s;">%s</h1>%s</div>
+	<div class="parties">
+		<div><strong>%s</strong><br>%s</div>
+		<div><strong>%s:</strong> %s<br><strong>%s:</strong> %s</div>
+	</div>
+	<table>
+		<thead><tr><th>%s</th><th>%s</th><th>%s</th><th class="text-right">%s</th><th class="text-right">%s</th><th class="text-right">%s</th></tr></thead>
+		<tbody>
+`, accent, accent, esc(tr.AccountStatement), senderHTML,
+			esc(st.UserName), esc(strings.Join(st.Address, ", ")),
+			esc(tr.Period), statementPeriod(st), esc(tr.Currency), esc(st.Currency),
+			esc(tr.Date), esc(tr.Document), esc(tr.Description), esc(tr.Debit), esc(tr.CreditColumn), esc(tr.Balance))
+		fmt.Fprintf(&b, `<tr class="balance-row"><td colspan="5">%s</td><td class="text-right">%.2f</td></tr>`+"\n",
+			esc(tr.OpeningBalance), st.OpeningBalance)
+		for _, l := range st.Lines {
+			fmt.Fprintf(&b, `<tr><td>%s</td><td>%s</td><td>%s</td><td class="text-right">%s</td><td class="text-right">%s</td><td class="text-right">%.2f</td></tr>`+"\n",
+				formatDate(l.Date), esc(l.Reference), esc(l.Description), statementAmount(l.Debit), statementAmount(l.Credit), l.Balance)
+		}
+		fmt.Fprintf(&b, `<tr class="balance-row"><td colspan="3">%s</td><td class="text-right">%.2f</td><td class="text-right">%.2f</td><td class="text-right">%s %.2f</td></tr>
+		</tbody>
+	</table>
+</div>
+`, esc(tr.ClosingBalance), st.TotalDebit, st.TotalCredit, esc(st.Currency), st.ClosingBalance)
+	}
+	b.WriteString("</body>\n</html>")
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestAccountStatements(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, language) VALUES (10,'Anne','Dupont','a@b.c',1,'fr')`); err != nil {
+		t.Fatal(err)
+	}
+	items := []models.InvoiceItem{{Description: "Normal power", Quantity: 400, UnitPrice: 0.25, TotalPrice: 100, ItemType: "normal_power"}}
+	var ids []int
+	for _, number := range []string{"INV-1", "INV-2"} {
+		id, _, err := bs.insertInvoiceWithItems(number, 10, 1, "2026-01-01", "2026-01-31", 100, 100, 0, 0, false, "CHF", false, items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, int(id))
+	}
+	if _, err := db.Exec(`UPDATE invoices SET generated_at = '2026-02-01 10:00:00' WHERE id = ?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE invoices SET generated_at = '2026-03-01 10:00:00' WHERE id = ?`, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordPayment(db, models.Payment{InvoiceID: ids[0], PaymentDate: "2026-02-20", Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordPayment(db, models.Payment{InvoiceID: ids[1], PaymentDate: "2026-03-15", Amount: 60, Reference: "RF18"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.CancelInvoice(ids[1], "wrong tariff"); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := BuildAccountStatements(db, AccountStatementFilter{BuildingID: 1, From: "2026-03-01", To: "2099-12-31"})
+	if err != nil || len(statements) != 1 {
+		t.Fatalf("statements %+v, %v", statements, err)
+	}
+	st := statements[0]
+	// Opening: INV-1 paid in full. Then INV-2, a payment of 60 and the credit
+	// note over 100 leave 60 in the tenant's favour.
+	if !almostEqual(st.OpeningBalance, 0) || len(st.Lines) != 3 || !almostEqual(st.ClosingBalance, -60) {
+		t.Fatalf("statement opening %.2f closing %.2f lines %+v", st.OpeningBalance, st.ClosingBalance, st.Lines)
+	}
+	if st.Lines[0].Kind != DocumentTypeInvoice || !almostEqual(st.Lines[0].Debit, 100) || !almostEqual(st.Lines[0].Balance, 100) {
+		t.Errorf("first line %+v", st.Lines[0])
+	}
+	if st.Lines[1].Kind != StatementPayment || !almostEqual(st.Lines[1].Credit, 60) || st.Lines[1].Description != "Paiement reçu RF18" {
+		t.Errorf("payment line %+v", st.Lines[1])
+	}
+	if st.Lines[2].Kind != DocumentTypeCreditNote || !almostEqual(st.Lines[2].Credit, 100) {
+		t.Errorf("credit note line %+v", st.Lines[2])
+	}
+
+	pg := NewPDFGenerator(db)
+	pg.SetRenderer(PDFRendererNative)
+	pdf, err := pg.RenderAccountStatementsPDF(statements)
+	if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatalf("render statement: %v", err)
+	}
+	if html := pg.generateStatementsHTML(statements); !strings.Contains(html, "Relevé de compte") {
+		t.Errorf("statement HTML not in the tenant's language")
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Accounting journal export formats.
+const (
+	JournalFormatBexio  = "bexio"
+	JournalFormatAbacus = "abacus"
+	JournalFormatDATEV  = "datev"
+)
+
+// truncateRunes cuts s to at most n characters.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// WriteBexioCSV writes entries in the column layout of the Bexio manual
+// entries import (semicolon separated, Swiss dates).
+func WriteBexioCSV(w io.Writer, entries []models.JournalEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	rows := [][]string{{"Datum", "Beleg", "Soll", "Haben", "Betrag", "Währung", "Beschreibung", "MWST-Code"}}
+	for _, e := range entries {
+		rows = append(rows, []string{
+			formatDate(e.Date), e.DocumentNumber, e.DebitAccount, e.CreditAccount,
+			fmt.Sprintf("%.2f", e.Amount), e.Currency, e.Text, e.VATCode,
+		})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+type abacusContainer struct {
+	XMLName xml.Name   `xml:"AbaConnectContainer"`
+	Task    abacusTask `xml:"Task"`
+}
+
+type abacusTask struct {
+	Application  string              `xml:"Parameter>Application"`
+	ID           string              `xml:"Parameter>Id"`
+	MapID        string              `xml:"Parameter>MapId"`
+	Version      string              `xml:"Parameter>Version"`
+	Transactions []abacusTransaction `xml:"Transaction"`
+}
+
+type abacusTransaction struct {
+	ID         int             `xml:"id,attr"`
+	Collective abacusEntrySide `xml:"CollectiveInformation"`
+	Individual abacusEntrySide `xml:"IndividualInformation"`
+}
+
+type abacusEntrySide struct {
+	Mode           string `xml:"mode,attr"`
+	EntryType      string `xml:"EntryType,omitempty"`
+	Type           string `xml:"Type"`
+	DebitCredit    string `xml:"DebitCredit"`
+	Currency       string `xml:"Currency"`
+	Amount         string `xml:"Amount"`
+	KeyAmount      string `xml:"KeyAmount"`
+	Account        string `xml:"Account"`
+	TaxCode        string `xml:"TaxCode,omitempty"`
+	Date           string `xml:"Date,omitempty"`
+	DocumentNumber string `xml:"DocumentNumber,omitempty"`
+	Text           string `xml:"Text1"`
+}
+
+// WriteAbacusXML writes entries as an AbaConnect FIBU bookings container:
+// one transaction per entry, the debit account as the collective and the
+// credit account as the individual side.
+func WriteAbacusXML(w io.Writer, entries []models.JournalEntry) error {
+	c := abacusContainer{Task: abacusTask{Application: "FIBU", ID: "Buchungen", MapID: "AbaDefault", Version: "2022.00"}}
+	for i, e := range entries {
+		amount := fmt.Sprintf("%.2f", e.Amount)
+		side := abacusEntrySide{
+			Mode: "SAVE", Type: "Normal", Currency: e.Currency, Amount: amount, KeyAmount: amount, Text: e.Text,
+		}
+		debit, credit := side, side
+		debit.EntryType, debit.DebitCredit, debit.Account = "S", "D", e.DebitAccount
+		debit.Date, debit.DocumentNumber = e.Date, e.DocumentNumber
+		credit.DebitCredit, credit.Account, credit.TaxCode = "C", e.CreditAccount, e.VATCode
+		c.Task.Transactions = append(c.Task.Transactions, abacusTransaction{ID: i + 1, Collective: debit, Individual: credit})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// datevText quotes a DATEV text field. DATEV files are Windows-1252; characters
+// outside it become "?".
+func datevText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if _, ok := charmap.Windows1252.EncodeRune(r); !ok {
+			return '?'
+		}
+		return r
+	}, s)
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// DATEVFiscalYear returns the start of the fiscal year (Wirtschaftsjahr) the
+// period from..to lies in. The year starts on the month and day of the
+// datev_fiscal_year_start setting, 1 January when unset. DATEV rejects a
+// Buchungsstapel that spans two fiscal years, so such a period is an error.
+func DATEVFiscalYear(settings map[string]string, from, to string) (time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, err
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day, err := parseFiscalYearStart(settings[SettingDATEVFiscalYearStart])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	fy := time.Date(start.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if fy.After(start) {
+		fy = fy.AddDate(-1, 0, 0)
+	}
+	if next := fy.AddDate(1, 0, 0); !end.Before(next) {
+		return time.Time{}, fmt.Errorf("a DATEV export must lie within one fiscal year: %s to %s crosses into the fiscal year starting %s",
+			formatDate(from), formatDate(to), formatDate(next.Format("2006-01-02")))
+	}
+	return fy, nil
+}
+
+// ValidateDATEVFiscalYearStart checks a datev_fiscal_year_start value.
+func ValidateDATEVFiscalYearStart(value string) error {
+	_, err := parseFiscalYearStart(value)
+	return err
+}
+
+// parseFiscalYearStart reads an MMDD fiscal year start ("" is 1 January). The
+// day is at most the 28th so that every year has it.
+func parseFiscalYearStart(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		value = "0101"
+	}
+	day, err := time.Parse("0102", value)
+	if err != nil || day.Day() > 28 {
+		return time.Time{}, fmt.Errorf("%s must be a month and day (MMDD) no later than the 28th, got %q", SettingDATEVFiscalYearStart, value)
+	}
+	return day, nil
+}
+
+// WriteDATEV writes entries as a DATEV "Buchungsstapel" in the EXTF ASCII
+// format (version 700), Windows-1252 encoded, for the period from..to, which
+// must lie within one fiscal year (see DATEVFiscalYear). Amounts carry no
+// sign; the debit account is "Konto" with the S flag, the credit account
+// "Gegenkonto". The consultant and client numbers come from settings.
+func WriteDATEV(w io.Writer, entries []models.JournalEntry, settings map[string]string, from, to string, created time.Time) error {
+	fiscalYear, err := DATEVFiscalYear(settings, from, to)
+	if err != nil {
+		return err
+	}
+	start, _ := time.Parse("2006-01-02", from)
+	end, _ := time.Parse("2006-01-02", to)
+	accountLength := 4
+	for _, e := range entries {
+		for _, a := range []string{e.DebitAccount, e.CreditAccount} {
+			if len(a) > accountLength {
+				accountLength = len(a)
+			}
+		}
+	}
+	currency := "CHF"
+	if len(entries) > 0 {
+		currency = entries[0].Currency
+	}
+
+	header := []string{
+		datevText("EXTF"), "700", "21", datevText("Buchungsstapel"), "13", created.Format("20060102150405") + "000",
+		"", datevText(""), datevText(""), datevText(""),
+		settings[SettingDATEVConsultant], settings[SettingDATEVClient],
+		fiscalYear.Format("20060102"), fmt.Sprintf("%d", accountLength),
+		start.Format("20060102"), end.Format("20060102"),
+		datevText(truncateRunes("ZEV Billing "+formatDate(from)+" - "+formatDate(to), 30)),
+		datevText(""), "1", "0", "0", datevText(currency),
+	}
+	columns := []string{
+		"Umsatz (ohne Soll/Haben-Kz)", "Soll/Haben-Kennzeichen", "WKZ Umsatz", "Kurs", "Basis-Umsatz",
+		"WKZ Basis-Umsatz", "Konto", "Gegenkonto (ohne BU-Schlüssel)", "BU-Schlüssel", "Belegdatum",
+		"Belegfeld 1", "Belegfeld 2", "Skonto", "Buchungstext",
+	}
+	lines := []string{strings.Join(header, ";"), strings.Join(columns, ";")}
+	for _, e := range entries {
+		day, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			return fmt.Errorf("entry %s: %v", e.DocumentNumber, err)
+		}
+		lines = append(lines, strings.Join([]string{
+			strings.Replace(fmt.Sprintf("%.2f", e.Amount), ".", ",", 1), datevText("S"), datevText(e.Currency),
+			"", "", datevText(""), e.DebitAccount, e.CreditAccount, datevText(""), day.Format("0201"),
+			datevText(truncateRunes(e.DocumentNumber, 36)), datevText(""), "", datevText(truncateRunes(e.Text, 60)),
+		}, ";"))
+	}
+	out, err := charmap.Windows1252.NewEncoder().String(strings.Join(lines, "\r\n") + "\r\n")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
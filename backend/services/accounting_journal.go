@@ -0,0 +1,364 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Keys of accounting_settings besides the revenue ("revenue_" + category) and
+// VAT ("vat_" + VAT code) accounts.
+const (
+	AccountReceivables    = "receivables"
+	AccountPayables       = "payables" // owed to producers
+	AccountBank           = "bank"
+	AccountAdvances       = "advances" // advance payments received
+	AccountInputVAT       = "input_vat"
+	AccountReminderFees   = "reminder_fees"
+	AccountEnergyPurchase = "energy_purchase" // producer credits
+
+	SettingDATEVConsultant      = "datev_consultant_number"
+	SettingDATEVClient          = "datev_client_number"
+	SettingDATEVFiscalYearStart = "datev_fiscal_year_start" // MMDD, e.g. 0101 or 0701
+)
+
+// Kinds of journal entries besides the document types.
+const (
+	JournalReminderFee = StatementReminderFee
+	JournalPayment     = StatementPayment
+	JournalPayout      = StatementPayout
+)
+
+// journalCategories are the line categories revenue accounts are kept per:
+// those of TranslateItemType plus utilities and everything else.
+var journalCategories = []string{
+	"normal_power", "solar_power", "battery_power", "car_charging", "shared_meter", "custom", "utility", "other",
+}
+
+// RevenueAccountKey is the settings key of a line category's revenue account.
+func RevenueAccountKey(category string) string {
+	return "revenue_" + category
+}
+
+// VATAccountKey is the settings key of the account a VAT code's VAT is
+// booked to.
+func VATAccountKey(code string) string {
+	return "vat_" + code
+}
+
+// ValidAccountingSettingKey reports whether key can be stored in the
+// accounting settings.
+func ValidAccountingSettingKey(key string) bool {
+	switch key {
+	case AccountReceivables, AccountPayables, AccountBank, AccountAdvances, AccountInputVAT,
+		AccountReminderFees, AccountEnergyPurchase, SettingDATEVConsultant, SettingDATEVClient, SettingDATEVFiscalYearStart:
+		return true
+	}
+	for _, c := range journalCategories {
+		if key == RevenueAccountKey(c) {
+			return true
+		}
+	}
+	return strings.HasPrefix(key, "vat_") && len(key) > len("vat_")
+}
+
+// journalCategory maps a line type to the category its revenue is booked
+// under. Advance payments and producer remuneration are not revenue and get
+// categories of their own.
+func journalCategory(itemType string) string {
+	switch {
+	case itemType == "advance_payment" || itemType == "advance_deduction":
+		return "advance"
+	case strings.HasPrefix(itemType, "producer_") || itemType == "leg_producer_credit":
+		return "producer"
+	case strings.HasPrefix(itemType, "car_charging"):
+		return "car_charging"
+	case strings.HasPrefix(itemType, "utility_"):
+		return "utility"
+	}
+	switch itemType {
+	case "normal_power", "demand_charge", "leg_consumption", "leg_grid_fee":
+		return "normal_power"
+	case "solar_power", "vzev_self_solar", "vzev_virtual_pv", "leg_local_energy":
+		return "solar_power"
+	case "battery_power":
+		return "battery_power"
+	case "shared_meter_charge":
+		return "shared_meter"
+	case "custom_item":
+		return "custom"
+	}
+	return "other"
+}
+
+// LoadAccountingSettings returns the accounting settings by key.
+func LoadAccountingSettings(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(`SELECT setting_key, value FROM accounting_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+// account returns the account stored under key, else under fallback.
+func account(settings map[string]string, key, fallback string) string {
+	if a := settings[key]; a != "" {
+		return a
+	}
+	return settings[fallback]
+}
+
+// journalDocument is an issued document as the journal books it.
+type journalDocument struct {
+	models.Invoice
+	date     string
+	userName string
+}
+
+// BuildJournal turns the documents issued, the reminder fees charged and the
+// payments received or paid out between from and to (YYYY-MM-DD, inclusive)
+// into journal entries. Documents are split per revenue account and VAT code;
+// offsets by credit notes and credit balances stay within receivables and are
+// left out, as on the account statements.
+func BuildJournal(db *sql.DB, from, to string) ([]models.JournalEntry, error) {
+	settings, err := LoadAccountingSettings(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load accounting settings: %v", err)
+	}
+	tr := GetTranslations("de")
+
+	rows, err := db.Query(`
+		SELECT i.id, i.invoice_number, COALESCE(i.document_type, 'invoice'), i.generated_at,
+		       i.total_amount, COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0), COALESCE(i.vat_rate, 0),
+		       COALESCE(i.vat_included, 0), UPPER(i.currency),
+		       COALESCE(u.first_name || ' ' || u.last_name, '')
+		FROM invoices i
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE date(i.generated_at) BETWEEN ? AND ? AND i.status IN (?, ?, ?)
+		ORDER BY i.generated_at, i.id
+	`, from, to, InvoiceStatusIssued, InvoiceStatusCancelled, InvoiceStatusCredited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %v", err)
+	}
+	var docs []*journalDocument
+	byID := map[int]*journalDocument{}
+	var ids []int
+	for rows.Next() {
+		d := &journalDocument{}
+		var generatedAt time.Time
+		if err := rows.Scan(&d.ID, &d.InvoiceNumber, &d.DocumentType, &generatedAt, &d.TotalAmount, &d.NetAmount,
+			&d.VATAmount, &d.VATRate, &d.VATIncluded, &d.Currency, &d.userName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.date = generatedAt.Format("2006-01-02")
+		docs = append(docs, d)
+		byID[d.ID] = d
+		ids = append(ids, d.ID)
+	}
+	rows.Close()
+
+	items, err := loadDocumentItems(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		byID[item.InvoiceID].Items = append(byID[item.InvoiceID].Items, item)
+	}
+
+	entries := []models.JournalEntry{}
+	for _, d := range docs {
+		entries = append(entries, documentEntries(d, settings, tr)...)
+	}
+
+	rows, err = db.Query(`
+		SELECT r.reminder_number, r.created_at, r.fee, UPPER(i.currency), i.invoice_number,
+		       COALESCE(u.first_name || ' ' || u.last_name, '')
+		FROM invoice_reminders r
+		JOIN invoices i ON i.id = r.invoice_id
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE r.fee > 0 AND date(r.created_at) BETWEEN ? AND ?
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder fees: %v", err)
+	}
+	for rows.Next() {
+		var createdAt time.Time
+		var invoiceNumber, userName string
+		e := models.JournalEntry{Kind: JournalReminderFee}
+		if err := rows.Scan(&e.DocumentNumber, &createdAt, &e.Amount, &e.Currency, &invoiceNumber, &userName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.Date = createdAt.Format("2006-01-02")
+		e.Text = strings.TrimSpace(fmt.Sprintf("%s %s %s", tr.ReminderFee, invoiceNumber, userName))
+		e.DebitAccount = account(settings, AccountReceivables, "")
+		e.CreditAccount = account(settings, AccountReminderFees, "")
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`
+		SELECT date(p.payment_date), p.amount, UPPER(p.currency), p.reference, i.invoice_number,
+		       COALESCE(i.document_type, 'invoice'), COALESCE(u.first_name || ' ' || u.last_name, '')
+		FROM payments p
+		JOIN invoices i ON i.id = p.invoice_id
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE p.source NOT IN (?, ?) AND date(p.payment_date) BETWEEN ? AND ?
+		ORDER BY p.payment_date, p.id
+	`, PaymentSourceCreditNote, PaymentSourceCreditBalance, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %v", err)
+	}
+	for rows.Next() {
+		var reference, documentType, userName string
+		var e models.JournalEntry
+		if err := rows.Scan(&e.Date, &e.Amount, &e.Currency, &reference, &e.DocumentNumber, &documentType, &userName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		label := tr.PaymentReceived
+		e.Kind = JournalPayment
+		e.DebitAccount = account(settings, AccountBank, "")
+		e.CreditAccount = account(settings, AccountReceivables, "")
+		if documentType == DocumentTypeProducerCredit {
+			label = tr.Payout
+			e.Kind = JournalPayout
+			e.DebitAccount = account(settings, AccountPayables, "")
+			e.CreditAccount = account(settings, AccountBank, "")
+		}
+		e.Text = strings.TrimSpace(fmt.Sprintf("%s %s %s %s", label, e.DocumentNumber, userName, reference))
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Date < entries[b].Date })
+	return entries, nil
+}
+
+// documentEntries books one document against receivables (payables for a
+// producer credit): one entry per revenue account and VAT code for the net
+// amounts and one per VAT code for the VAT. Cent differences to the stored
+// totals go to the largest VAT and revenue entries, so the entries always add
+// up to the document total.
+func documentEntries(d *journalDocument, settings map[string]string, tr InvoiceTranslations) []models.JournalEntry {
+	producer := d.DocumentType == DocumentTypeProducerCredit
+	counter := account(settings, AccountReceivables, "")
+	if producer {
+		counter = account(settings, AccountPayables, "")
+	}
+
+	type bucket struct {
+		account, vatCode string
+		amount           float64
+	}
+	var revenue, vat []*bucket
+	add := func(list *[]*bucket, account, code string, amount float64) {
+		for _, b := range *list {
+			if b.account == account && b.vatCode == code {
+				b.amount += amount
+				return
+			}
+		}
+		*list = append(*list, &bucket{account: account, vatCode: code, amount: amount})
+	}
+
+	for _, it := range d.Items {
+		if it.TotalPrice == 0 {
+			continue
+		}
+		category := journalCategory(it.ItemType)
+		code, rate := it.VATCode, it.VATRate
+		if code == "" {
+			code, rate = VATCodeStandard, d.VATRate
+		}
+		// Advances carry no VAT, neither do documents of buildings without VAT.
+		if category == "advance" || d.VATRate <= 0 {
+			code, rate = "", 0
+		}
+		net := it.TotalPrice
+		if d.VATIncluded && rate > 0 {
+			net = it.TotalPrice / (1 + rate/100)
+		}
+
+		var revenueAccount string
+		switch category {
+		case "advance":
+			revenueAccount = account(settings, AccountAdvances, "")
+		case "producer":
+			revenueAccount = account(settings, AccountEnergyPurchase, "")
+		default:
+			revenueAccount = account(settings, RevenueAccountKey(category), RevenueAccountKey("other"))
+		}
+		add(&revenue, revenueAccount, code, net)
+		if rate > 0 {
+			vatAccount := account(settings, VATAccountKey(code), VATAccountKey(VATCodeStandard))
+			if producer {
+				vatAccount = account(settings, AccountInputVAT, "")
+			}
+			add(&vat, vatAccount, code, net*rate/100)
+		}
+	}
+	if len(revenue) == 0 {
+		add(&revenue, account(settings, RevenueAccountKey("other"), ""), "", d.TotalAmount-d.VATAmount)
+		if d.VATAmount != 0 {
+			add(&vat, account(settings, VATAccountKey(VATCodeStandard), ""), VATCodeStandard, d.VATAmount)
+		}
+	}
+
+	largest := func(list []*bucket) *bucket {
+		var max *bucket
+		for _, b := range list {
+			if max == nil || math.Abs(b.amount) > math.Abs(max.amount) {
+				max = b
+			}
+		}
+		return max
+	}
+	sum := func(list []*bucket) float64 {
+		total := 0.0
+		for _, b := range list {
+			b.amount = math.Round(b.amount*100) / 100
+			total += b.amount
+		}
+		return total
+	}
+	if vatTotal := sum(vat); len(vat) > 0 && math.Abs(d.VATAmount-vatTotal) < 0.05 {
+		largest(vat).amount += d.VATAmount - vatTotal
+	}
+	if diff := d.TotalAmount - sum(revenue) - sum(vat); math.Abs(diff) >= 0.005 {
+		largest(revenue).amount += diff
+	}
+
+	text := strings.TrimSpace(fmt.Sprintf("%s %s %s", documentTitle(tr, d.DocumentType), d.InvoiceNumber, d.userName))
+	var entries []models.JournalEntry
+	for _, b := range append(revenue, vat...) {
+		amount := math.Round(b.amount*100) / 100
+		if math.Abs(amount) < 0.005 {
+			continue
+		}
+		e := models.JournalEntry{
+			Date: d.date, DocumentNumber: d.InvoiceNumber, Kind: d.DocumentType, Text: text,
+			DebitAccount: counter, CreditAccount: b.account, Amount: amount, Currency: d.Currency, VATCode: b.vatCode,
+		}
+		if amount < 0 {
+			e.DebitAccount, e.CreditAccount, e.Amount = b.account, counter, -amount
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
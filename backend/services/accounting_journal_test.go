@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestAccountingJournal(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (10,'Anna','Meier','a@b.c',1)`); err != nil {
+		t.Fatal(err)
+	}
+	items := []models.InvoiceItem{
+		{Description: "Normal power", Quantity: 400, UnitPrice: 0.25, TotalPrice: 100, ItemType: "normal_power", VATCode: VATCodeStandard, VATRate: 8.1},
+		{Description: "Water meter", Quantity: 1, UnitPrice: 20, TotalPrice: 20, ItemType: "custom_item", VATCode: "reduced", VATRate: 2.6},
+	}
+	id, _, err := bs.insertInvoiceWithItems("INV-1", 10, 1, "2026-01-01", "2026-01-31", 128.62, 120, 8.62, 8.1, false, "CHF", false, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	if _, err := RecordPayment(db, models.Payment{InvoiceID: int(id), PaymentDate: today, Amount: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.CancelInvoice(int(id), "wrong meter"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := BuildJournal(db, "2000-01-01", today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := map[string]float64{}
+	for _, e := range entries {
+		balances[e.DebitAccount] += e.Amount
+		balances[e.CreditAccount] -= e.Amount
+	}
+	// Invoice and credit note cancel out; the payment is left as credit.
+	if !almostEqual(balances["1100"], -50) || !almostEqual(balances["1020"], 50) ||
+		!almostEqual(balances["3200"], 0) || !almostEqual(balances["2200"], 0) {
+		t.Errorf("balances %v", balances)
+	}
+	var revenue []string
+	for _, e := range entries {
+		if e.Kind == DocumentTypeInvoice && e.DebitAccount == "1100" {
+			revenue = append(revenue, fmt.Sprintf("%s %s %.2f", e.CreditAccount, e.VATCode, e.Amount))
+		}
+	}
+	if strings.Join(revenue, ", ") != "3200 standard 100.00, 3400 reduced 20.00, 2200 standard 8.10, 2200 reduced 0.52" {
+		t.Errorf("invoice entries: %v", revenue)
+	}
+
+	var csvOut, xmlOut, datev bytes.Buffer
+	if err := WriteBexioCSV(&csvOut, entries); err != nil || !strings.Contains(csvOut.String(), ";INV-1;1100;3200;100.00;CHF;") {
+		t.Errorf("bexio: %v\n%s", err, csvOut.String())
+	}
+	if err := WriteAbacusXML(&xmlOut, entries); err != nil || !strings.Contains(xmlOut.String(), "<Account>3400</Account>") {
+		t.Errorf("abacus: %v\n%s", err, xmlOut.String())
+	}
+	if err := WriteDATEV(&datev, entries, map[string]string{}, today[:4]+"-01-01", today, time.Now()); err != nil ||
+		!strings.HasPrefix(datev.String(), `"EXTF";700;21;"Buchungsstapel"`) || !strings.Contains(datev.String(), `100,00;"S";"CHF";;;"";1100;3200;`) ||
+		!strings.Contains(datev.String(), ";"+today[:4]+"0101;") {
+		t.Errorf("datev: %v\n%s", err, datev.String())
+	}
+	if !strings.Contains(datev.String(), "BU-Schl\xfcssel") {
+		t.Errorf("datev file is not Windows-1252")
+	}
+	if err := WriteDATEV(&datev, entries, nil, "2000-01-01", today, time.Now()); err == nil {
+		t.Errorf("datev export across fiscal years was accepted")
+	}
+	july := map[string]string{SettingDATEVFiscalYearStart: "0701"}
+	if fy, err := DATEVFiscalYear(july, "2026-07-01", "2027-06-30"); err != nil || fy.Format("2006-01-02") != "2026-07-01" {
+		t.Errorf("fiscal year from July = %v, %v", fy, err)
+	}
+	if _, err := DATEVFiscalYear(july, "2026-06-30", "2026-07-01"); err == nil {
+		t.Errorf("period across the July fiscal year start was accepted")
+	}
+}
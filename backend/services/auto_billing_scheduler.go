@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
 )
 
 type AutoBillingScheduler struct {
@@ -36,6 +38,7 @@ type RunConfigResult struct {
 	PeriodStart       string   `json:"period_start"`
 	PeriodEnd         string   `json:"period_end"`
 	InvoicesGenerated int      `json:"invoices_generated"`
+	InvoicesQueued    int      `json:"invoices_queued"` // drafts waiting for approval
 	PDFsGenerated     int      `json:"pdfs_generated"`
 	EmailsSent        int      `json:"emails_sent"`
 	EmailsFailed      int      `json:"emails_failed"`
@@ -144,18 +147,74 @@ func (s *AutoBillingScheduler) RunConfigNow(id int) (*RunConfigResult, error) {
 	return s.runConfig(id, false)
 }
 
-// runConfig loads a single auto-billing config by id, generates the bills,
-// produces PDFs, optionally e-mails them, and updates last_run.
-// When advanceSchedule is true, next_run is recalculated for the next cycle —
-// this is the path taken by the scheduler. The manual test path (false) leaves
-// next_run untouched so the periodic schedule still fires as configured.
-func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConfigResult, error) {
-	if s.licenseService != nil && !s.licenseService.CanBill() {
-		return nil, fmt.Errorf("automated billing is not included in the free plan — activate a license")
+// ConfigPreview is the dry-run result of an auto-billing config.
+type ConfigPreview struct {
+	ConfigID    int              `json:"config_id"`
+	ConfigName  string           `json:"config_name"`
+	PeriodStart string           `json:"period_start"`
+	PeriodEnd   string           `json:"period_end"`
+	Invoices    []InvoicePreview `json:"invoices"`
+	Skipped     []SkippedBill    `json:"skipped"`
+}
+
+// PreviewConfig computes what RunConfigNow would bill right now — invoices,
+// skipped tenants and rendered documents — without storing invoices, sending
+// e-mails or touching last_run/next_run.
+func (s *AutoBillingScheduler) PreviewConfig(id int, withPDF bool) (*ConfigPreview, error) {
+	plan, err := s.loadRunPlan(id, time.Now())
+	if err != nil {
+		return nil, err
 	}
+	var invoices []models.Invoice
+	var skipped []SkippedBill
+	if plan.invoiceType == AutoBillingTypeAdvance {
+		invoices, skipped, err = s.billingService.PreviewAdvanceInvoices(plan.buildingIDs, plan.userIDs,
+			plan.periodStart, plan.periodEnd)
+	} else {
+		invoices, skipped, err = s.billingService.PreviewBillsWithOptions(plan.buildingIDs, plan.userIDs,
+			plan.periodStart, plan.periodEnd, plan.isVZEV, plan.customItemIDs, plan.scope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to preview bills: %v", err)
+	}
+	return &ConfigPreview{
+		ConfigID:    id,
+		ConfigName:  plan.name,
+		PeriodStart: plan.periodStart,
+		PeriodEnd:   plan.periodEnd,
+		Invoices:    RenderInvoicePreviews(s.pdfGenerator, invoices, plan.sender, plan.banking, withPDF),
+		Skipped:     skipped,
+	}, nil
+}
 
-	now := time.Now()
+// autoBillingRun is an auto-billing config resolved for one run: who and what
+// to bill, the period, and the sender/banking details for the documents.
+type autoBillingRun struct {
+	name          string
+	frequency     string
+	generationDay int
+	invoiceType   string
+	buildingIDs   []int
+	userIDs       []int
+	customItemIDs []int
+	isVZEV        bool
+	scope         BillingScope
+	autoSendEmail bool
+	periodStart   string
+	periodEnd     string
+	sender        SenderInfo
+	banking       BankingInfo
+	// numberSchemeID is the config's own invoice number scheme (0: the
+	// buildings' schemes apply).
+	numberSchemeID int
+	// requireApproval holds the invoices as drafts in the review queue;
+	// PDFs and e-mails follow on approval.
+	requireApproval bool
+}
 
+// loadRunPlan loads a config and derives the billing period ending yesterday,
+// or for advance configs the period starting today (advances are billed ahead).
+func (s *AutoBillingScheduler) loadRunPlan(id int, now time.Time) (*autoBillingRun, error) {
 	var name, buildingIDsStr string
 	var apartmentsJSON sql.NullString
 	var customItemIDsStr sql.NullString
@@ -166,22 +225,26 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 	var billingMode sql.NullString
 	var billContent sql.NullString
 	var chargerID sql.NullInt64
-	var autoSendEmail bool
+	var autoSendEmail, requireApproval bool
+	var invoiceType string
 	var senderName, senderAddress, senderCity, senderZip, senderCountry sql.NullString
 	var bankName, bankIBAN, bankAccountHolder sql.NullString
+	var numberSchemeID sql.NullInt64
 
 	err := s.db.QueryRow(`
 		SELECT name, building_ids, apartments_json, custom_item_ids, frequency, generation_day,
 		       first_execution_date, is_vzev, billing_mode, COALESCE(bill_content, 'both'), charger_id,
 		       COALESCE(auto_send_email, 0), sender_name, sender_address,
 		       sender_city, sender_zip, sender_country, bank_name, bank_iban,
-		       bank_account_holder
+		       bank_account_holder, COALESCE(invoice_type, 'consumption'), number_scheme_id,
+		       COALESCE(require_approval, 0)
 		FROM auto_billing_configs
 		WHERE id = ?
 	`, id).Scan(&name, &buildingIDsStr, &apartmentsJSON, &customItemIDsStr, &frequency,
 		&generationDay, &firstExecutionDate, &isVZEV, &billingMode, &billContent, &chargerID,
 		&autoSendEmail, &senderName, &senderAddress,
-		&senderCity, &senderZip, &senderCountry, &bankName, &bankIBAN, &bankAccountHolder)
+		&senderCity, &senderZip, &senderCountry, &bankName, &bankIBAN, &bankAccountHolder, &invoiceType,
+		&numberSchemeID, &requireApproval)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("auto-billing config %d not found", id)
@@ -192,14 +255,6 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 
 	_ = firstExecutionDate // not needed at run time
 
-	result := &RunConfigResult{
-		ConfigID:       id,
-		ConfigName:     name,
-		EmailRequested: autoSendEmail,
-	}
-
-	log.Printf("Processing auto billing config: %s (ID: %d, vZEV: %v, advance: %v)", name, id, isVZEV, advanceSchedule)
-
 	buildingIDs := parseIDList(buildingIDsStr)
 	if len(buildingIDs) == 0 {
 		return nil, fmt.Errorf("config has no buildings")
@@ -248,9 +303,14 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 	default:
 		return nil, fmt.Errorf("unknown frequency: %s", frequency)
 	}
-
-	result.PeriodStart = startDate.Format("2006-01-02")
-	result.PeriodEnd = endDate.Format("2006-01-02")
+	if invoiceType == AutoBillingTypeAdvance {
+		// Same length, shifted forward: today through the day before the next run.
+		months := monthsInPeriod(startDate, endDate)
+		startDate = nextDay
+		endDate = nextDay.AddDate(0, months, -1)
+	} else {
+		invoiceType = AutoBillingTypeConsumption
+	}
 
 	scope := BillingScope{}
 	if billingMode.Valid {
@@ -272,11 +332,80 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 		scope.Content = billContent.String
 	}
 
+	return &autoBillingRun{
+		name:          name,
+		frequency:     frequency,
+		generationDay: generationDay,
+		invoiceType:   invoiceType,
+		buildingIDs:   buildingIDs,
+		userIDs:       userIDs,
+		customItemIDs: customItemIDs,
+		isVZEV:        isVZEV,
+		scope:         scope,
+		autoSendEmail: autoSendEmail,
+		periodStart:   startDate.Format("2006-01-02"),
+		periodEnd:     endDate.Format("2006-01-02"),
+		sender: SenderInfo{
+			Name:    getStringFromNull(senderName),
+			Address: getStringFromNull(senderAddress),
+			City:    getStringFromNull(senderCity),
+			Zip:     getStringFromNull(senderZip),
+			Country: getStringFromNull(senderCountry),
+		},
+		banking: BankingInfo{
+			Name:          getStringFromNull(bankName),
+			IBAN:          getStringFromNull(bankIBAN),
+			AccountHolder: getStringFromNull(bankAccountHolder),
+		},
+		numberSchemeID:  int(numberSchemeID.Int64),
+		requireApproval: requireApproval,
+	}, nil
+}
+
+// runConfig loads a single auto-billing config by id, generates the bills,
+// produces PDFs, optionally e-mails them, and updates last_run. Configs with
+// require_approval instead queue the invoices as drafts for ApproveInvoices.
+// When advanceSchedule is true, next_run is recalculated for the next cycle —
+// this is the path taken by the scheduler. The manual test path (false) leaves
+// next_run untouched so the periodic schedule still fires as configured.
+func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConfigResult, error) {
+	if s.licenseService != nil && !s.licenseService.CanBill() {
+		return nil, fmt.Errorf("automated billing is not included in the free plan — activate a license")
+	}
+
+	now := time.Now()
+	plan, err := s.loadRunPlan(id, now)
+	if err != nil {
+		return nil, err
+	}
+	name, isVZEV, scope, customItemIDs := plan.name, plan.isVZEV, plan.scope, plan.customItemIDs
+
+	result := &RunConfigResult{
+		ConfigID:       id,
+		ConfigName:     name,
+		EmailRequested: plan.autoSendEmail,
+		PeriodStart:    plan.periodStart,
+		PeriodEnd:      plan.periodEnd,
+	}
+
+	log.Printf("Processing auto billing config: %s (ID: %d, vZEV: %v, advance: %v)", name, id, isVZEV, advanceSchedule)
+
 	log.Printf("Generating bills for period: %s to %s (vZEV mode: %v, scope: %q, charger: %v)",
 		result.PeriodStart, result.PeriodEnd, isVZEV, scope.Mode, scope.ChargerID)
 
-	invoices, skipped, err := s.billingService.GenerateBillsWithOptions(buildingIDs, userIDs,
-		result.PeriodStart, result.PeriodEnd, isVZEV, customItemIDs, scope)
+	var invoices []models.Invoice
+	var skipped []SkippedBill
+	billing := s.billingService.WithNumberScheme(plan.numberSchemeID)
+	if plan.requireApproval {
+		billing = billing.AsDrafts()
+	}
+	if plan.invoiceType == AutoBillingTypeAdvance {
+		invoices, skipped, err = billing.GenerateAdvanceInvoices(plan.buildingIDs, plan.userIDs,
+			result.PeriodStart, result.PeriodEnd)
+	} else {
+		invoices, skipped, err = billing.GenerateBillsWithOptions(plan.buildingIDs, plan.userIDs,
+			result.PeriodStart, result.PeriodEnd, isVZEV, customItemIDs, scope)
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate bills: %v", err)
@@ -291,19 +420,6 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 	}
 	log.Printf("SUCCESS: Generated %d invoices for config %s", len(invoices), name)
 
-	senderInfo := SenderInfo{
-		Name:    getStringFromNull(senderName),
-		Address: getStringFromNull(senderAddress),
-		City:    getStringFromNull(senderCity),
-		Zip:     getStringFromNull(senderZip),
-		Country: getStringFromNull(senderCountry),
-	}
-	bankingInfo := BankingInfo{
-		Name:          getStringFromNull(bankName),
-		IBAN:          getStringFromNull(bankIBAN),
-		AccountHolder: getStringFromNull(bankAccountHolder),
-	}
-
 	result.SMTPConfigured = s.emailAlerter != nil
 
 	for _, invoice := range invoices {
@@ -312,55 +428,32 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 			result.FirstInvoiceID = invoice.ID
 		}
 
-		fullInvoice, err := s.loadFullInvoice(invoice.ID)
-		if err != nil {
-			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to load invoice %d: %v", invoice.ID, err))
-			continue
-		}
-
-		invoiceMap := s.invoiceToMap(fullInvoice)
-		pdfPath, err := s.pdfGenerator.GenerateInvoicePDF(invoiceMap, senderInfo, bankingInfo)
-		if err != nil {
-			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to generate PDF for invoice %d: %v", invoice.ID, err))
+		if plan.requireApproval {
+			// Drafts wait for approval; PDF and e-mail follow in ApproveInvoices.
+			if _, err := queueForReview(s.db, id, invoice.ID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to check invoice %d for review: %v", invoice.ID, err))
+			}
+			result.InvoicesQueued++
 			continue
 		}
 
-		if _, err := s.db.Exec("UPDATE invoices SET pdf_path = ? WHERE id = ?", pdfPath, invoice.ID); err != nil {
-			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to update PDF path for invoice %d: %v", invoice.ID, err))
-		} else {
+		delivery := s.deliverInvoice(id, invoice.ID, plan.sender, plan.banking, plan.autoSendEmail)
+		result.Warnings = append(result.Warnings, delivery.warnings...)
+		if delivery.pdf {
 			result.PDFsGenerated++
 		}
-
-		if autoSendEmail && s.emailAlerter != nil {
-			userMap, _ := fullInvoice["user"].(map[string]interface{})
-			recipient, _ := userMap["email"].(string)
-			if recipient == "" {
-				result.EmailsFailed++
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Invoice %d: recipient has no e-mail address", invoice.ID))
-				log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: recipient has no e-mail, skipping", id, invoice.ID)
-			} else {
-				// pdfPath returned by the generator is just the filename — resolve
-				// it to the actual on-disk location before reading it as an email
-				// attachment. The PDF generator writes either to the absolute Pi
-				// path or a local ./invoices fallback.
-				attachmentPath := resolveInvoicePDFPath(pdfPath)
-				subject, body := s.buildInvoiceEmail(fullInvoice)
-				if err := s.emailAlerter.SendEmailWithAttachment(recipient, subject, body, attachmentPath); err != nil {
-					result.EmailsFailed++
-					result.Warnings = append(result.Warnings, fmt.Sprintf("Invoice %d: e-mail to %s failed: %v", invoice.ID, recipient, err))
-					log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: failed to send to %s: %v", id, invoice.ID, recipient, err)
-				} else {
-					result.EmailsSent++
-					log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: sent to %s", id, invoice.ID, recipient)
-				}
-			}
+		if delivery.emailed {
+			result.EmailsSent++
+		}
+		if delivery.failed {
+			result.EmailsFailed++
 		}
 	}
 
-	log.Printf("Generated %d invoices with %d PDFs", result.InvoicesGenerated, result.PDFsGenerated)
+	log.Printf("Generated %d invoices with %d PDFs (%d queued for approval)", result.InvoicesGenerated, result.PDFsGenerated, result.InvoicesQueued)
 
 	if advanceSchedule {
-		nextRunTime := calculateNextRun(frequency, generationDay, now)
+		nextRunTime := calculateNextRun(plan.frequency, plan.generationDay, now)
 		_, err = s.db.Exec(`
 			UPDATE auto_billing_configs
 			SET last_run = ?, next_run = ?, updated_at = CURRENT_TIMESTAMP
@@ -389,9 +482,11 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 		"pdfs_generated":  result.PDFsGenerated,
 		"emails_sent":     result.EmailsSent,
 		"emails_failed":   result.EmailsFailed,
+		"invoices_queued": result.InvoicesQueued,
 		"period_start":    result.PeriodStart,
 		"period_end":      result.PeriodEnd,
 		"is_vzev":         isVZEV,
+		"invoice_type":    plan.invoiceType,
 		"billing_mode":    scope.Mode,
 		"bill_content":    scope.Content,
 		"charger_id":      scope.ChargerID,
@@ -412,24 +507,139 @@ func (s *AutoBillingScheduler) runConfig(id int, advanceSchedule bool) (*RunConf
 	return result, nil
 }
 
+// invoiceDelivery is the outcome of rendering and e-mailing one invoice.
+type invoiceDelivery struct {
+	pdf      bool // PDF rendered and stored
+	emailed  bool
+	failed   bool // e-mail requested but not delivered
+	warnings []string
+}
+
+// deliverInvoice renders an issued invoice's PDF, stores its path and, when
+// sendEmail is set and SMTP is configured, e-mails it to the tenant.
+func (s *AutoBillingScheduler) deliverInvoice(configID, invoiceID int, senderInfo SenderInfo, bankingInfo BankingInfo, sendEmail bool) invoiceDelivery {
+	var d invoiceDelivery
+	fullInvoice, err := s.loadFullInvoice(invoiceID)
+	if err != nil {
+		d.warnings = append(d.warnings, fmt.Sprintf("Failed to load invoice %d: %v", invoiceID, err))
+		return d
+	}
+
+	invoiceMap := s.invoiceToMap(fullInvoice)
+	pdfPath, err := s.pdfGenerator.GenerateInvoicePDF(invoiceMap, senderInfo, bankingInfo)
+	if err != nil {
+		d.warnings = append(d.warnings, fmt.Sprintf("Failed to generate PDF for invoice %d: %v", invoiceID, err))
+		return d
+	}
+
+	if _, err := s.db.Exec("UPDATE invoices SET pdf_path = ? WHERE id = ?", pdfPath, invoiceID); err != nil {
+		d.warnings = append(d.warnings, fmt.Sprintf("Failed to update PDF path for invoice %d: %v", invoiceID, err))
+	} else {
+		d.pdf = true
+		if _, err := NewInvoiceArchive(s.db).Archive(invoiceID, pdfPath); err != nil {
+			d.warnings = append(d.warnings, fmt.Sprintf("Failed to archive invoice %d: %v", invoiceID, err))
+		}
+	}
+
+	if !sendEmail || s.emailAlerter == nil {
+		return d
+	}
+	userMap, _ := fullInvoice["user"].(map[string]interface{})
+	recipient, _ := userMap["email"].(string)
+	if recipient == "" {
+		d.failed = true
+		d.warnings = append(d.warnings, fmt.Sprintf("Invoice %d: recipient has no e-mail address", invoiceID))
+		log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: recipient has no e-mail, skipping", configID, invoiceID)
+		return d
+	}
+	// pdfPath returned by the generator is just the filename — resolve
+	// it to the actual on-disk location before reading it as an email
+	// attachment. The PDF generator writes either to the absolute Pi
+	// path or a local ./invoices fallback.
+	attachmentPath := resolveInvoicePDFPath(pdfPath)
+	subject, body := s.buildInvoiceEmail(fullInvoice)
+	if err := s.emailAlerter.SendEmailWithAttachment(recipient, subject, body, attachmentPath); err != nil {
+		d.failed = true
+		d.warnings = append(d.warnings, fmt.Sprintf("Invoice %d: e-mail to %s failed: %v", invoiceID, recipient, err))
+		log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: failed to send to %s: %v", configID, invoiceID, recipient, err)
+	} else {
+		d.emailed = true
+		log.Printf("[AUTO-BILLING-EMAIL] Config %d invoice %d: sent to %s", configID, invoiceID, recipient)
+	}
+	return d
+}
+
+// deliverySettings are the parts of an auto-billing config needed to issue
+// and deliver an approved draft.
+type deliverySettings struct {
+	sender         SenderInfo
+	banking        BankingInfo
+	autoSendEmail  bool
+	numberSchemeID int
+}
+
+// loadDeliverySettings loads the sender, banking, e-mail and number scheme
+// settings of an auto-billing config.
+func (s *AutoBillingScheduler) loadDeliverySettings(configID int) (*deliverySettings, error) {
+	var senderName, senderAddress, senderCity, senderZip, senderCountry sql.NullString
+	var bankName, bankIBAN, bankAccountHolder sql.NullString
+	var numberSchemeID sql.NullInt64
+	var autoSendEmail bool
+	err := s.db.QueryRow(`
+		SELECT COALESCE(auto_send_email, 0), sender_name, sender_address, sender_city, sender_zip,
+		       sender_country, bank_name, bank_iban, bank_account_holder, number_scheme_id
+		FROM auto_billing_configs
+		WHERE id = ?
+	`, configID).Scan(&autoSendEmail, &senderName, &senderAddress, &senderCity, &senderZip,
+		&senderCountry, &bankName, &bankIBAN, &bankAccountHolder, &numberSchemeID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("auto-billing config %d not found", configID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+	return &deliverySettings{
+		sender: SenderInfo{
+			Name:    getStringFromNull(senderName),
+			Address: getStringFromNull(senderAddress),
+			City:    getStringFromNull(senderCity),
+			Zip:     getStringFromNull(senderZip),
+			Country: getStringFromNull(senderCountry),
+		},
+		banking: BankingInfo{
+			Name:          getStringFromNull(bankName),
+			IBAN:          getStringFromNull(bankIBAN),
+			AccountHolder: getStringFromNull(bankAccountHolder),
+		},
+		autoSendEmail:  autoSendEmail,
+		numberSchemeID: int(numberSchemeID.Int64),
+	}, nil
+}
+
 // Helper function to load full invoice with items and user (INCLUDING LANGUAGE)
 func (s *AutoBillingScheduler) loadFullInvoice(invoiceID int) (map[string]interface{}, error) {
 	inv := make(map[string]interface{})
 
 	var id, userID, buildingID int
-	var invoiceNumber, periodStart, periodEnd, currency, status string
-	var totalAmount float64
+	var invoiceNumber, periodStart, periodEnd, currency, status, documentType, dueDate string
+	var totalAmount, creditApplied float64
 	var generatedAt time.Time
+	var vat models.Invoice
 
 	err := s.db.QueryRow(`
 		SELECT i.id, i.invoice_number, i.user_id, i.building_id, 
 		       i.period_start, i.period_end, i.total_amount, i.currency, 
-		       i.status, i.generated_at
+		       i.status, i.generated_at, COALESCE(i.document_type, 'invoice'),
+		       COALESCE(date(i.due_date), ''),
+		       (SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.invoice_id = i.id AND p.source = ?),
+		       COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0), COALESCE(i.vat_rate, 0), COALESCE(i.vat_included, 0)
 		FROM invoices i WHERE i.id = ?
-	`, invoiceID).Scan(
+	`, PaymentSourceCreditBalance, invoiceID).Scan(
 		&id, &invoiceNumber, &userID, &buildingID,
 		&periodStart, &periodEnd, &totalAmount, &currency,
-		&status, &generatedAt,
+		&status, &generatedAt, &documentType, &dueDate,
+		&creditApplied,
+		&vat.NetAmount, &vat.VATAmount, &vat.VATRate, &vat.VATIncluded,
 	)
 
 	if err != nil {
@@ -445,11 +655,18 @@ func (s *AutoBillingScheduler) loadFullInvoice(invoiceID int) (map[string]interf
 	inv["total_amount"] = totalAmount
 	inv["currency"] = currency
 	inv["status"] = status
+	inv["document_type"] = documentType
 	inv["generated_at"] = generatedAt.Format("2006-01-02")
+	inv["due_date"] = dueDate
+	inv["credit_applied"] = creditApplied
+	inv["net_amount"] = vat.NetAmount
+	inv["vat_amount"] = vat.VATAmount
+	inv["vat_rate"] = vat.VATRate
+	inv["vat_included"] = vat.VATIncluded
 
 	// Load invoice items
 	itemRows, err := s.db.Query(`
-		SELECT id, invoice_id, description, quantity, unit_price, total_price, item_type
+		SELECT id, invoice_id, description, quantity, unit_price, total_price, item_type, vat_code, vat_rate
 		FROM invoice_items WHERE invoice_id = ?
 		ORDER BY id ASC
 	`, id)
@@ -459,10 +676,11 @@ func (s *AutoBillingScheduler) loadFullInvoice(invoiceID int) (map[string]interf
 		items := []interface{}{}
 		for itemRows.Next() {
 			var itemID, invoiceID int
-			var description, itemType string
-			var quantity, unitPrice, totalPrice float64
+			var description, itemType, vatCode string
+			var quantity, unitPrice, totalPrice, vatRate float64
 			if err := itemRows.Scan(&itemID, &invoiceID, &description,
-				&quantity, &unitPrice, &totalPrice, &itemType); err == nil {
+				&quantity, &unitPrice, &totalPrice, &itemType, &vatCode, &vatRate); err == nil {
+				vat.Items = append(vat.Items, models.InvoiceItem{TotalPrice: totalPrice, VATCode: vatCode, VATRate: vatRate})
 				itemMap := make(map[string]interface{})
 				itemMap["id"] = itemID
 				itemMap["invoice_id"] = invoiceID
@@ -475,6 +693,7 @@ func (s *AutoBillingScheduler) loadFullInvoice(invoiceID int) (map[string]interf
 			}
 		}
 		inv["items"] = items
+		inv["vat_summary"] = VATSummary(vat)
 	}
 
 	// Load user details INCLUDING LANGUAGE
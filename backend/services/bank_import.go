@@ -0,0 +1,664 @@
+package services
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Bank transaction states. Matched and assigned transactions have been booked
+// as a payment on their invoice; unmatched ones wait in the review queue.
+const (
+	BankTxMatched   = "matched"
+	BankTxUnmatched = "unmatched"
+	BankTxAssigned  = "assigned"
+	BankTxIgnored   = "ignored"
+)
+
+// Ways a transaction was tied to an invoice.
+const (
+	MatchQRReference   = "qr_reference"
+	MatchInvoiceNumber = "invoice_number"
+	MatchAmountName    = "amount_name"
+	MatchManual        = "manual"
+)
+
+// camt XML, read namespace-agnostically so camt.053/054 versions .04 to .08
+// all parse with the same structs.
+type camtDocument struct {
+	Statement    *camtMessage `xml:"BkToCstmrStmt"`
+	Notification *camtMessage `xml:"BkToCstmrDbtCdtNtfctn"`
+}
+
+type camtMessage struct {
+	MessageID     string       `xml:"GrpHdr>MsgId"`
+	Statements    []camtReport `xml:"Stmt"`
+	Notifications []camtReport `xml:"Ntfctn"`
+}
+
+type camtReport struct {
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtStatus is a plain code up to camt.05x.04 and <Cd> from .05 on.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d camtDate) day() string {
+	if d.Date != "" {
+		return storedDay(strings.TrimSpace(d.Date))
+	}
+	return storedDay(strings.TrimSpace(d.DateTime))
+}
+
+type camtEntry struct {
+	Amount      camtAmount      `xml:"Amt"`
+	CreditDebit string          `xml:"CdtDbtInd"`
+	Status      camtStatus      `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	AcctSvcrRef string          `xml:"AcctSvcrRef"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	AcctSvcrRef    string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndID     string     `xml:"Refs>EndToEndId"`
+	Amount         camtAmount `xml:"Amt"`
+	TxAmount       camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CreditDebit    string     `xml:"CdtDbtInd"`
+	DebtorName     string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPtyName  string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	DebtorIBAN     string     `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+	Reference      string     `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	RefCode        string     `xml:"RmtInf>Strd>CdtrRefInf>Tp>CdOrPrtry>Cd"`
+	RefProprietary string     `xml:"RmtInf>Strd>CdtrRefInf>Tp>CdOrPrtry>Prtry"`
+	Unstructured   []string   `xml:"RmtInf>Ustrd"`
+	AdditionalInfo string     `xml:"AddtlTxInf"`
+}
+
+// BankStatement is a parsed camt file: the booked transactions of one or
+// more accounts.
+type BankStatement struct {
+	Format       string
+	MessageID    string
+	AccountIBAN  string
+	Transactions []BankStatementTx
+}
+
+// BankStatementTx is one booked transaction with the key used to recognise it
+// again in a later file (camt.054 notifications repeat in camt.053).
+type BankStatementTx struct {
+	models.BankTransaction
+	DedupeKey string
+}
+
+// ParseCamt reads a camt.053 statement or camt.054 notification. Only booked
+// entries are returned; batch bookings are split into their transactions.
+func ParseCamt(data []byte) (*BankStatement, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid XML: %v", err)
+	}
+
+	st := &BankStatement{}
+	var msg *camtMessage
+	var reports []camtReport
+	switch {
+	case doc.Statement != nil:
+		st.Format, msg = "camt.053", doc.Statement
+		reports = msg.Statements
+	case doc.Notification != nil:
+		st.Format, msg = "camt.054", doc.Notification
+		reports = msg.Notifications
+	default:
+		return nil, fmt.Errorf("not a camt.053 or camt.054 document")
+	}
+	st.MessageID = strings.TrimSpace(msg.MessageID)
+
+	for _, rep := range reports {
+		iban := normalizeReference(rep.IBAN)
+		if st.AccountIBAN == "" {
+			st.AccountIBAN = iban
+		}
+		for ei, e := range rep.Entries {
+			status := strings.TrimSpace(e.Status.Code + e.Status.Text)
+			if status != "" && status != "BOOK" {
+				continue
+			}
+			details := e.Details
+			if len(details) == 0 {
+				details = []camtTxDetails{{}}
+			}
+			for di, d := range details {
+				tx, err := camtTransaction(e, d, len(details) == 1)
+				if err != nil {
+					return nil, fmt.Errorf("entry %d: %v", ei+1, err)
+				}
+				tx.DedupeKey = camtDedupeKey(iban, e, d, tx, di)
+				st.Transactions = append(st.Transactions, tx)
+			}
+		}
+	}
+	if len(st.Transactions) == 0 {
+		return nil, fmt.Errorf("no booked transactions found")
+	}
+	return st, nil
+}
+
+// camtTransaction flattens an entry and one of its transaction details. A
+// detail without its own amount inherits the entry's when it is the only one.
+func camtTransaction(e camtEntry, d camtTxDetails, single bool) (BankStatementTx, error) {
+	amt := d.Amount
+	if strings.TrimSpace(amt.Value) == "" {
+		amt = d.TxAmount
+	}
+	if strings.TrimSpace(amt.Value) == "" && single {
+		amt = e.Amount
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(amt.Value), 64)
+	if err != nil {
+		return BankStatementTx{}, fmt.Errorf("invalid amount %q", amt.Value)
+	}
+	creditDebit := strings.TrimSpace(d.CreditDebit)
+	if creditDebit == "" {
+		creditDebit = strings.TrimSpace(e.CreditDebit)
+	}
+	debtor := strings.TrimSpace(d.DebtorName)
+	if debtor == "" {
+		debtor = strings.TrimSpace(d.DebtorPtyName)
+	}
+	var remittance []string
+	for _, u := range d.Unstructured {
+		if u = strings.TrimSpace(u); u != "" {
+			remittance = append(remittance, u)
+		}
+	}
+	if info := strings.TrimSpace(d.AdditionalInfo); info != "" {
+		remittance = append(remittance, info)
+	}
+
+	tx := BankStatementTx{BankTransaction: models.BankTransaction{
+		BookingDate:    e.BookingDate.day(),
+		ValueDate:      e.ValueDate.day(),
+		Amount:         math.Round(value*100) / 100,
+		Currency:       strings.ToUpper(strings.TrimSpace(amt.Currency)),
+		CreditDebit:    creditDebit,
+		Reference:      normalizeReference(d.Reference),
+		EndToEndID:     strings.TrimSpace(d.EndToEndID),
+		DebtorName:     debtor,
+		DebtorIBAN:     normalizeReference(d.DebtorIBAN),
+		RemittanceInfo: strings.Join(remittance, " "),
+	}}
+	if tx.Currency == "" {
+		tx.Currency = "CHF"
+	}
+	if tx.EndToEndID == "NOTPROVIDED" {
+		tx.EndToEndID = ""
+	}
+	tx.ReferenceType = referenceType(tx.Reference, d.RefCode, d.RefProprietary)
+	return tx, nil
+}
+
+// camtDedupeKey prefers the bank's own transaction reference; without one it
+// falls back to a hash of the transaction's content.
+func camtDedupeKey(iban string, e camtEntry, d camtTxDetails, tx BankStatementTx, index int) string {
+	if ref := strings.TrimSpace(d.AcctSvcrRef); ref != "" {
+		return iban + ":" + ref
+	}
+	if ref := strings.TrimSpace(e.AcctSvcrRef); ref != "" {
+		return fmt.Sprintf("%s:%s:%d", iban, ref, index)
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%.2f|%s|%s|%s|%s|%s|%d", iban, tx.BookingDate, tx.CreditDebit,
+		tx.Amount, tx.Currency, tx.Reference, tx.EndToEndID, tx.DebtorName, tx.RemittanceInfo, index)))
+	return iban + ":" + hex.EncodeToString(sum[:])
+}
+
+// normalizeReference removes blanks and upper-cases a reference or IBAN.
+func normalizeReference(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// referenceType classifies a structured creditor reference as QRR (27-digit
+// QR reference) or SCOR (ISO 11649 "RF" reference).
+func referenceType(ref, code, proprietary string) string {
+	switch {
+	case ref == "":
+		return ""
+	case strings.EqualFold(strings.TrimSpace(proprietary), "QRR"):
+		return "QRR"
+	case strings.EqualFold(strings.TrimSpace(code), "SCOR"), strings.HasPrefix(ref, "RF"):
+		return "SCOR"
+	case len(ref) == 27 && strings.Trim(ref, "0123456789") == "":
+		return "QRR"
+	}
+	return ""
+}
+
+// paymentCandidate is an invoice a bank credit may pay.
+type paymentCandidate struct {
+	id            int
+	invoiceNumber string
+	currency      string
+	total         float64
+	paid          float64
+	fees          float64 // reminder fees charged so far
+	payable       bool
+	names         []string // tenant and account holder, normalised
+}
+
+func (c *paymentCandidate) outstanding() float64 {
+	return math.Round((c.total-c.paid)*100) / 100
+}
+
+// loadPaymentCandidates returns every issued document with its payment state;
+// only issued, unpaid invoices are payable, the rest are kept to explain a
+// reference that points at a paid or cancelled invoice.
+func loadPaymentCandidates(db *sql.DB) ([]*paymentCandidate, error) {
+	rows, err := db.Query(`
+		SELECT i.id, i.invoice_number, i.currency, i.total_amount, COALESCE(i.paid_amount, 0),
+		       i.status, COALESCE(i.document_type, 'invoice'), COALESCE(i.payment_status, 'unpaid'),
+		       COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), COALESCE(u.bank_account_holder, ''),
+		       (SELECT COALESCE(SUM(r.fee), 0) FROM invoice_reminders r WHERE r.invoice_id = i.id)
+		FROM invoices i
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE COALESCE(i.document_type, 'invoice') NOT IN (?, ?)
+		ORDER BY i.id
+	`, DocumentTypeCreditNote, DocumentTypeProducerCredit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*paymentCandidate
+	for rows.Next() {
+		c := &paymentCandidate{}
+		var status, documentType, paymentStatus, firstName, lastName, holder string
+		if err := rows.Scan(&c.id, &c.invoiceNumber, &c.currency, &c.total, &c.paid, &status,
+			&documentType, &paymentStatus, &firstName, &lastName, &holder, &c.fees); err != nil {
+			return nil, err
+		}
+		c.currency = strings.ToUpper(c.currency)
+		c.payable = status == InvoiceStatusIssued && paymentStatus != "paid" && c.outstanding() > zeroBillEpsilon
+		for _, n := range []string{firstName + " " + lastName, holder} {
+			if n = normalizeName(n); n != "" {
+				c.names = append(c.names, n)
+			}
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// normalizeName lower-cases a person's name and reduces it to single-spaced
+// words so "MÜLLER,  Hans" and "Hans Müller" compare equal by word.
+func normalizeName(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer(",", " ", ".", " ", "-", " ").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// sameName reports whether every word of the tenant's name appears in the
+// debtor name, in any order.
+func sameName(debtor, name string) bool {
+	if debtor == "" || name == "" {
+		return false
+	}
+	words := " " + debtor + " "
+	for _, w := range strings.Fields(name) {
+		if !strings.Contains(words, " "+w+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTransaction finds the invoice a credit pays: by the end-to-end ID of a
+// direct debit, by QR reference, then by an invoice number in the
+// remittance text, then by the outstanding
+// amount (with or without reminder fees) together with the debtor name. It
+// returns the candidate and method, or nil and a note for the review queue.
+func matchTransaction(tx models.BankTransaction, candidates []*paymentCandidate) (*paymentCandidate, string, string) {
+	var notes []string
+
+	// Collected direct debits carry the invoice number as end-to-end ID.
+	if tx.EndToEndID != "" {
+		for _, c := range candidates {
+			if c.payable && c.currency == tx.Currency && strings.EqualFold(c.invoiceNumber, tx.EndToEndID) {
+				return c, MatchDirectDebit, ""
+			}
+		}
+	}
+
+	// QR-bills only carry QRR references. Invoices issued before references
+	// included the invoice id carry the digits of their number, which several
+	// invoices can share: a reference matching more than one goes to review.
+	if tx.ReferenceType == "QRR" {
+		var hits []*paymentCandidate
+		for _, c := range candidates {
+			if tx.Reference == invoiceReference(c.id, c.invoiceNumber) || tx.Reference == qrReference(c.invoiceNumber) {
+				hits = append(hits, c)
+			}
+		}
+		switch {
+		case len(hits) == 0:
+			notes = append(notes, "Reference does not match any invoice")
+		case len(hits) > 1:
+			numbers := make([]string, len(hits))
+			for i, c := range hits {
+				numbers[i] = c.invoiceNumber
+			}
+			return nil, "", fmt.Sprintf("Reference matches several invoices (%s)", strings.Join(numbers, ", "))
+		case hits[0].payable && hits[0].currency == tx.Currency:
+			return hits[0], MatchQRReference, ""
+		default:
+			notes = append(notes, fmt.Sprintf("Reference belongs to %s, which is not open for payment", hits[0].invoiceNumber))
+		}
+	}
+
+	if text := strings.ToUpper(tx.RemittanceInfo); text != "" {
+		for _, c := range candidates {
+			if c.payable && c.currency == tx.Currency && strings.Contains(text, strings.ToUpper(c.invoiceNumber)) {
+				return c, MatchInvoiceNumber, ""
+			}
+		}
+	}
+
+	debtor := normalizeName(tx.DebtorName)
+	var hits []*paymentCandidate
+	for _, c := range candidates {
+		if !c.payable || c.currency != tx.Currency {
+			continue
+		}
+		out := c.outstanding()
+		if math.Abs(out-tx.Amount) > 0.005 && math.Abs(out+c.fees-tx.Amount) > 0.005 {
+			continue
+		}
+		for _, n := range c.names {
+			if sameName(debtor, n) {
+				hits = append(hits, c)
+				break
+			}
+		}
+	}
+	switch {
+	case len(hits) == 1:
+		return hits[0], MatchAmountName, ""
+	case len(hits) > 1:
+		notes = append(notes, fmt.Sprintf("%d open invoices match amount and debtor name", len(hits)))
+	case len(notes) == 0:
+		notes = append(notes, "No invoice matches reference, amount or debtor name")
+	}
+	return nil, "", strings.Join(notes, "; ")
+}
+
+// bankTransactionPayment is the ledger entry for a credit booked on an
+// invoice from a bank statement.
+func bankTransactionPayment(t models.BankTransaction, invoiceID int, paidAt string) models.Payment {
+	p := models.Payment{
+		InvoiceID:         invoiceID,
+		PaymentDate:       paidAt,
+		Amount:            t.Amount,
+		Method:            PaymentMethodBankTransfer,
+		Reference:         t.Reference,
+		Source:            PaymentSourceCamt,
+		BankTransactionID: &t.ID,
+		Notes:             t.DebtorName,
+	}
+	if t.MatchMethod == MatchDirectDebit {
+		p.Method = PaymentMethodDirectDebit
+	}
+	if p.Reference == "" {
+		p.Reference = t.EndToEndID
+	}
+	return p
+}
+
+// BankImportResult is the outcome of one uploaded statement file.
+type BankImportResult struct {
+	Import       models.BankImport        `json:"import"`
+	Transactions []models.BankTransaction `json:"transactions"`
+}
+
+// ImportBankStatement parses a camt.053/054 file, skips transactions already
+// imported, books every credit it can match as a payment and leaves the
+// rest in the review queue. Debits are stored as ignored.
+func ImportBankStatement(db *sql.DB, fileName string, data []byte) (*BankImportResult, error) {
+	st, err := ParseCamt(data)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := loadPaymentCandidates(db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO bank_imports (file_name, message_id, format, account_iban) VALUES (?, ?, ?, ?)`,
+		fileName, st.MessageID, st.Format, st.AccountIBAN)
+	if err != nil {
+		return nil, err
+	}
+	importID, _ := res.LastInsertId()
+	result := &BankImportResult{
+		Import: models.BankImport{ID: int(importID), FileName: fileName, MessageID: st.MessageID,
+			Format: st.Format, AccountIBAN: st.AccountIBAN},
+		Transactions: []models.BankTransaction{},
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for _, stx := range st.Transactions {
+		t := stx.BankTransaction
+		t.ImportID = int(importID)
+		t.Status = BankTxUnmatched
+
+		var match *paymentCandidate
+		if t.CreditDebit != "CRDT" {
+			t.Status, t.ReviewNote = BankTxIgnored, "Debit"
+		} else {
+			match, t.MatchMethod, t.ReviewNote = matchTransaction(t, candidates)
+		}
+
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO bank_transactions (
+				import_id, dedupe_key, booking_date, value_date, amount, currency, credit_debit,
+				reference, reference_type, end_to_end_id, debtor_name, debtor_iban, remittance_info,
+				status, review_note
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, t.ImportID, stx.DedupeKey, nullableDate(t.BookingDate), nullableDate(t.ValueDate), t.Amount,
+			t.Currency, t.CreditDebit, t.Reference, t.ReferenceType, t.EndToEndID, t.DebtorName,
+			t.DebtorIBAN, t.RemittanceInfo, t.Status, t.ReviewNote)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			result.Import.Duplicates++
+			continue
+		}
+		id, _ := res.LastInsertId()
+		t.ID = int(id)
+		result.Import.Transactions++
+
+		if match != nil {
+			paidAt := t.BookingDate
+			if paidAt == "" {
+				paidAt = now
+			}
+			if _, err := recordPayment(tx, bankTransactionPayment(t, match.id, paidAt)); err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec(`
+				UPDATE bank_transactions SET status = ?, invoice_id = ?, match_method = ?, matched_at = ? WHERE id = ?
+			`, BankTxMatched, match.id, t.MatchMethod, now, t.ID); err != nil {
+				return nil, err
+			}
+			// Later credits in the same file see the reduced balance.
+			match.paid += t.Amount
+			match.payable = match.outstanding() > zeroBillEpsilon
+			invoiceID := match.id
+			t.Status, t.InvoiceID, t.InvoiceNumber, t.MatchedAt = BankTxMatched, &invoiceID, match.invoiceNumber, &now
+			result.Import.Matched++
+		} else if t.Status == BankTxUnmatched {
+			result.Import.Unmatched++
+		}
+		result.Transactions = append(result.Transactions, t)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE bank_imports SET transactions = ?, duplicates = ?, matched = ?, unmatched = ? WHERE id = ?
+	`, result.Import.Transactions, result.Import.Duplicates, result.Import.Matched, result.Import.Unmatched, importID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Import.ImportedAt = time.Now()
+
+	log.Printf("Bank import: %s %s - %d transactions (%d matched, %d to review, %d duplicates)",
+		st.Format, fileName, result.Import.Transactions, result.Import.Matched, result.Import.Unmatched, result.Import.Duplicates)
+	return result, nil
+}
+
+// nullableDate stores an empty date as NULL.
+func nullableDate(day string) interface{} {
+	if day == "" {
+		return nil
+	}
+	return day
+}
+
+// AssignBankTransaction books an unmatched (or ignored) credit on the invoice
+// chosen in the review queue.
+func AssignBankTransaction(db *sql.DB, transactionID, invoiceID int) error {
+	var status, creditDebit, currency, bookingDate string
+	t := models.BankTransaction{ID: transactionID}
+	err := db.QueryRow(`
+		SELECT status, credit_debit, currency, COALESCE(date(booking_date), ''), amount,
+		       reference, end_to_end_id, debtor_name
+		FROM bank_transactions WHERE id = ?
+	`, transactionID).Scan(&status, &creditDebit, &currency, &bookingDate, &t.Amount,
+		&t.Reference, &t.EndToEndID, &t.DebtorName)
+	if err != nil {
+		return err
+	}
+	if status == BankTxMatched || status == BankTxAssigned {
+		return fmt.Errorf("transaction %d is already booked", transactionID)
+	}
+	if creditDebit != "CRDT" {
+		return fmt.Errorf("transaction %d is a debit", transactionID)
+	}
+
+	var invoiceStatus, documentType, invoiceCurrency string
+	err = db.QueryRow(`
+		SELECT status, COALESCE(document_type, 'invoice'), currency FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&invoiceStatus, &documentType, &invoiceCurrency)
+	if err != nil {
+		return err
+	}
+	if invoiceStatus != InvoiceStatusIssued || IsCreditDocument(documentType) {
+		return fmt.Errorf("invoice %d is %s and cannot take a payment", invoiceID, invoiceStatus)
+	}
+	if !strings.EqualFold(invoiceCurrency, currency) {
+		return fmt.Errorf("currency %s does not match the invoice currency %s", currency, invoiceCurrency)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	paidAt := bookingDate
+	if paidAt == "" {
+		paidAt = now
+	}
+	if _, err := recordPayment(tx, bankTransactionPayment(t, invoiceID, paidAt)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE bank_transactions SET status = ?, invoice_id = ?, match_method = ?, review_note = '', matched_at = ? WHERE id = ?
+	`, BankTxAssigned, invoiceID, MatchManual, now, transactionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListBankTransactions returns imported transactions, newest first, filtered
+// by status and/or import (empty/0 = all).
+func ListBankTransactions(db *sql.DB, status string, importID int) ([]models.BankTransaction, error) {
+	query := `
+		SELECT t.id, t.import_id, COALESCE(date(t.booking_date), ''), COALESCE(date(t.value_date), ''),
+		       t.amount, t.currency, t.credit_debit, t.reference, t.reference_type, t.end_to_end_id,
+		       t.debtor_name, t.debtor_iban, t.remittance_info, t.status, t.invoice_id,
+		       COALESCE(i.invoice_number, ''), t.match_method, t.review_note, t.matched_at
+		FROM bank_transactions t
+		LEFT JOIN invoices i ON i.id = t.invoice_id
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	if status != "" {
+		query += ` AND t.status = ?`
+		args = append(args, status)
+	}
+	if importID > 0 {
+		query += ` AND t.import_id = ?`
+		args = append(args, importID)
+	}
+	query += ` ORDER BY t.booking_date DESC, t.id DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.BankTransaction{}
+	for rows.Next() {
+		var t models.BankTransaction
+		var invoiceID sql.NullInt64
+		var matchedAt sql.NullString
+		if err := rows.Scan(&t.ID, &t.ImportID, &t.BookingDate, &t.ValueDate, &t.Amount, &t.Currency,
+			&t.CreditDebit, &t.Reference, &t.ReferenceType, &t.EndToEndID, &t.DebtorName, &t.DebtorIBAN,
+			&t.RemittanceInfo, &t.Status, &invoiceID, &t.InvoiceNumber, &t.MatchMethod, &t.ReviewNote,
+			&matchedAt); err != nil {
+			return nil, err
+		}
+		if invoiceID.Valid {
+			id := int(invoiceID.Int64)
+			t.InvoiceID = &id
+		}
+		if matchedAt.Valid {
+			t.MatchedAt = &matchedAt.String
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
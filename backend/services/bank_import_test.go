@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+const testCamt054 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.08">
+  <BkToCstmrDbtCdtNtfctn>
+    <GrpHdr><MsgId>MSG-1</MsgId></GrpHdr>
+    <Ntfctn>
+      <Acct><Id><IBAN>CH44 3199 9123 0008 8901 2</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="CHF">62.50</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2026-02-10</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><AcctSvcrRef>TX-1</AcctSvcrRef></Refs>
+            <Amt Ccy="CHF">32.50</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+            <RmtInf><Strd><CdtrRefInf><Tp><CdOrPrtry><Prtry>QRR</Prtry></CdOrPrtry></Tp><Ref>%s</Ref></CdtrRefInf></Strd></RmtInf>
+          </TxDtls>
+          <TxDtls>
+            <Refs><AcctSvcrRef>TX-2</AcctSvcrRef></Refs>
+            <Amt Ccy="CHF">20.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+            <RltdPties><Dbtr><Pty><Nm>MUSTER, Anna</Nm></Pty></Dbtr></RltdPties>
+          </TxDtls>
+          <TxDtls>
+            <Refs><AcctSvcrRef>TX-3</AcctSvcrRef></Refs>
+            <Amt Ccy="CHF">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+            <RltdPties><Dbtr><Pty><Nm>Someone Else</Nm></Pty></Dbtr></RltdPties>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Ntfctn>
+  </BkToCstmrDbtCdtNtfctn>
+</Document>`
+
+func TestImportBankStatement(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (10,'Hans','Meier','h@b.c',1), (11,'Anna','Muster','a@b.c',1)`); err != nil {
+		t.Fatal(err)
+	}
+	items := []models.InvoiceItem{{Description: "Normal power", TotalPrice: 32.5, ItemType: "normal_power"}}
+	qrID, _, _ := bs.insertInvoiceWithItems("INV-2026-1-10-1", 10, 1, "2026-01-01", "2026-01-31", 32.5, 32.5, 0, 0, false, "CHF", false, items)
+	nameID, _, _ := bs.insertInvoiceWithItems("INV-2026-1-11-2", 11, 1, "2026-01-01", "2026-01-31", 20, 20, 0, 0, false, "CHF", false, items)
+
+	data := []byte(fmt.Sprintf(testCamt054, invoiceReference(int(qrID), "INV-2026-1-10-1")))
+	res, err := ImportBankStatement(db, "notification.xml", data)
+	if err != nil {
+		t.Fatalf("ImportBankStatement: %v", err)
+	}
+	if res.Import.Format != "camt.054" || res.Import.Transactions != 3 || res.Import.Matched != 2 || res.Import.Unmatched != 1 {
+		t.Fatalf("import = %+v", res.Import)
+	}
+	if m := res.Transactions[0]; m.MatchMethod != MatchQRReference || m.InvoiceID == nil || *m.InvoiceID != int(qrID) {
+		t.Errorf("QR transaction = %+v", m)
+	}
+	if m := res.Transactions[1]; m.MatchMethod != MatchAmountName || m.InvoiceID == nil || *m.InvoiceID != int(nameID) {
+		t.Errorf("name transaction = %+v", m)
+	}
+
+	var status string
+	var paid float64
+	db.QueryRow(`SELECT payment_status, paid_amount FROM invoices WHERE id = ?`, qrID).Scan(&status, &paid)
+	if status != "paid" || !almostEqual(paid, 32.5) {
+		t.Errorf("invoice payment = %s %.2f, want paid 32.50", status, paid)
+	}
+
+	// Re-importing the same file books nothing twice.
+	res, err = ImportBankStatement(db, "notification.xml", data)
+	if err != nil || res.Import.Duplicates != 3 || res.Import.Transactions != 0 {
+		t.Errorf("re-import = %+v, %v", res, err)
+	}
+}
+
+func TestMatchTransactionReferenceCollision(t *testing.T) {
+	a := &paymentCandidate{id: 1, invoiceNumber: "A-2026-00001", currency: "CHF", total: 50, payable: true}
+	b := &paymentCandidate{id: 2, invoiceNumber: "B-2026-00001", currency: "CHF", total: 50, payable: true}
+	candidates := []*paymentCandidate{a, b}
+
+	if invoiceReference(a.id, a.invoiceNumber) == invoiceReference(b.id, b.invoiceNumber) {
+		t.Fatal("invoice references of different invoices collide")
+	}
+	tx := models.BankTransaction{Amount: 50, Currency: "CHF", ReferenceType: "QRR", Reference: invoiceReference(b.id, b.invoiceNumber)}
+	if c, method, _ := matchTransaction(tx, candidates); c != b || method != MatchQRReference {
+		t.Errorf("id reference matched %v via %q, want B", c, method)
+	}
+
+	// The number-only reference of older QR-bills is shared by both.
+	tx.Reference = qrReference(a.invoiceNumber)
+	if c, _, note := matchTransaction(tx, candidates); c != nil || !strings.Contains(note, "several invoices") {
+		t.Errorf("ambiguous reference matched %v (%q), want review", c, note)
+	}
+}
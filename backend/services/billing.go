@@ -12,12 +12,48 @@ import (
 
 type BillingService struct {
 	db *sql.DB
+	// dryRun computes invoices without writing them (see PreviewBillsWithOptions).
+	dryRun bool
+	// numberSchemeID overrides the buildings' invoice number scheme (set for
+	// auto-billing configs with their own scheme, see WithNumberScheme).
+	numberSchemeID int
+	// drafts stores invoices as drafts awaiting approval (see AsDrafts).
+	drafts bool
+	// replaces links every invoice written to the cancelled invoice it
+	// replaces (see ReissueInvoice).
+	replaces int
 }
 
 func NewBillingService(db *sql.DB) *BillingService {
 	return &BillingService{db: db}
 }
 
+// WithNumberScheme returns a copy of the service that numbers its invoices
+// from the given scheme instead of the buildings' own (0 keeps the default).
+func (bs *BillingService) WithNumberScheme(schemeID int) *BillingService {
+	c := *bs
+	c.numberSchemeID = schemeID
+	return &c
+}
+
+// AsDrafts returns a copy of the service that stores its invoices as drafts:
+// status "draft", no number from a scheme yet and nothing payable until
+// ApproveInvoices issues them.
+func (bs *BillingService) AsDrafts() *BillingService {
+	c := *bs
+	c.drafts = true
+	return &c
+}
+
+// replacing returns a copy of the service whose invoices replace the given
+// cancelled invoice: original_invoice_id is set in the same transaction that
+// writes each invoice.
+func (bs *BillingService) replacing(invoiceID int) *BillingService {
+	c := *bs
+	c.replaces = invoiceID
+	return &c
+}
+
 // Helper function to safely extract string from interface{}
 func getConfigString(config map[string]interface{}, key string, defaultValue string) string {
 	if val, ok := config[key]; ok {
@@ -218,7 +254,9 @@ func (bs *BillingService) loadPriceSegments(buildingID int, start, end time.Time
 	rows, err := bs.db.Query(`
 		SELECT id, building_id, is_complex, normal_power_price, solar_power_price,
 		       battery_power_price, battery_charging_price, car_charging_normal_price, car_charging_priority_price,
-		       vzev_export_price, vat_included, vat_rate, currency, valid_from, valid_to
+		       vzev_export_price, COALESCE(tariff_windows_enabled, 0),
+		       COALESCE(grid_pricing_mode, 'fixed'), COALESCE(spot_price_area, ''), COALESCE(spot_price_markup, 0),
+		       vat_included, vat_rate, currency, valid_from, valid_to
 		FROM billing_settings
 		WHERE building_id = ? AND is_active = 1
 		ORDER BY valid_from ASC, id ASC
@@ -243,7 +281,9 @@ func (bs *BillingService) loadPriceSegments(buildingID int, start, end time.Time
 			&s.ID, &s.BuildingID, &s.IsComplex,
 			&s.NormalPowerPrice, &s.SolarPowerPrice, &s.BatteryPowerPrice, &s.BatteryChargingPrice,
 			&s.CarChargingNormalPrice, &s.CarChargingPriorityPrice,
-			&s.VZEVExportPrice, &s.VATIncluded, &s.VATRate, &s.Currency,
+			&s.VZEVExportPrice, &s.TariffWindowsEnabled,
+			&s.GridPricingMode, &s.SpotPriceArea, &s.SpotPriceMarkup,
+			&s.VATIncluded, &s.VATRate, &s.Currency,
 			&validFromStr, &validToStr,
 		); err != nil {
 			log.Printf("WARNING: skipping unreadable billing_settings row: %v", err)
@@ -496,6 +536,10 @@ func (bs *BillingService) GenerateBillsWithOptions(buildingIDs, userIDs []int, s
 		}
 	}
 
+	if !bs.dryRun {
+		bs.recordBillingScope(invoices, customItemIDs, scope)
+	}
+
 	log.Printf("\n=== BILL GENERATION COMPLETE: %d total invoices, %d skipped ===\n", len(invoices), len(skipped))
 	return invoices, skipped, nil
 }
@@ -934,10 +978,12 @@ func (bs *BillingService) calculateSharedMeterCostsWithTranslations(buildingID i
 	log.Printf("  [SHARED METERS] Calculating shared meter costs for building %d, user %d (%d active users, proration: %.3f)", buildingID, userID, totalActiveUsers, prorationFactor)
 
 	rows, err := bs.db.Query(`
-		SELECT id, meter_id, meter_name, split_type, unit_price,
-		       COALESCE(pricing_mode, 'single'), COALESCE(solar_price, 0), COALESCE(grid_price, 0)
-		FROM shared_meter_configs
-		WHERE building_id = ?
+		SELECT smc.id, smc.meter_id, smc.meter_name, smc.split_type, smc.unit_price,
+		       COALESCE(smc.pricing_mode, 'single'), COALESCE(smc.solar_price, 0), COALESCE(smc.grid_price, 0),
+		       COALESCE(smc.consumption_share, 0), COALESCE(m.meter_type, ''), COALESCE(m.reading_factor, 1)
+		FROM shared_meter_configs smc
+		LEFT JOIN meters m ON m.id = smc.meter_id
+		WHERE smc.building_id = ?
 	`, buildingID)
 	if err != nil {
 		log.Printf("  [SHARED METERS] ERROR: Failed to query shared meter configs: %v", err)
@@ -955,13 +1001,24 @@ func (bs *BillingService) calculateSharedMeterCostsWithTranslations(buildingID i
 
 	for rows.Next() {
 		var configID, meterID int
-		var meterName, splitType, pricingMode string
-		var unitPrice, solarPrice, gridPrice float64
+		var meterName, splitType, pricingMode, meterType string
+		var unitPrice, solarPrice, gridPrice, consumptionShare, readingFactor float64
 
-		if err := rows.Scan(&configID, &meterID, &meterName, &splitType, &unitPrice, &pricingMode, &solarPrice, &gridPrice); err != nil {
+		if err := rows.Scan(&configID, &meterID, &meterName, &splitType, &unitPrice, &pricingMode, &solarPrice, &gridPrice,
+			&consumptionShare, &meterType, &readingFactor); err != nil {
 			log.Printf("  [SHARED METERS] ERROR: Failed to scan config row: %v", err)
 			continue
 		}
+		// Water, heat and gas meters are always priced flat, in their own unit.
+		utility := UtilityOfMeterType(meterType)
+		unit := "kWh"
+		if utility != "" {
+			pricingMode = "single"
+			unit = DefaultUtilityUnit(utility)
+		}
+		if readingFactor <= 0 {
+			readingFactor = 1
+		}
 		if pricingMode == "" {
 			pricingMode = "single"
 		}
@@ -1046,10 +1103,10 @@ func (bs *BillingService) calculateSharedMeterCostsWithTranslations(buildingID i
 				continue
 			}
 
-			consumption = readingTo - readingFrom
+			consumption = (readingTo - readingFrom) * readingFactor
 			totalMeterCost = consumption * unitPrice
-			costDetail = fmt.Sprintf("%s: %.3f kWh × %.3f %s/kWh = %.3f %s",
-				tr.TotalConsumption, consumption, unitPrice, currency, totalMeterCost, currency)
+			costDetail = fmt.Sprintf("%s: %.3f %s × %.3f %s/%s = %.3f %s",
+				tr.TotalConsumption, consumption, unit, unitPrice, currency, unit, totalMeterCost, currency)
 
 			log.Printf("  [SHARED METERS]   Readings: %.3f → %.3f kWh (consumption: %.3f kWh)",
 				readingFrom, readingTo, consumption)
@@ -1130,6 +1187,29 @@ func (bs *BillingService) calculateSharedMeterCostsWithTranslations(buildingID i
 			splitDescription = fmt.Sprintf("%s %s %d %s", tr.SplitEqually, tr.Among, totalActiveUsers, tr.Users)
 		}
 
+		// Allocate consumptionShare percent of the cost by the tenants' own
+		// consumption of the same utility (apartment meters for electricity);
+		// the split above covers the rest.
+		if consumptionShare > 0 {
+			basisType := "apartment_meter"
+			if utility != "" {
+				basisType = meterType
+			}
+			userCons, allCons, err := bs.tenantUtilityConsumption(buildingID, userID, basisType, start, end)
+			if err == nil && allCons > 0 {
+				f := consumptionShare / 100
+				userShare = userShare*(1-f) + totalMeterCost*f*userCons/allCons
+				consDescription := fmt.Sprintf("%s %.0f%%: %.3f %s %.3f %s", tr.SplitByConsumption, consumptionShare, userCons, tr.Of, allCons, unit)
+				if f < 1 {
+					splitDescription = fmt.Sprintf("%s; %.0f%%: %s", consDescription, 100-consumptionShare, splitDescription)
+				} else {
+					splitDescription = consDescription
+				}
+			} else {
+				log.Printf("  [SHARED METERS]   WARNING: Consumption-based split requested but no tenant consumption found, using %s split", splitType)
+			}
+		}
+
 		// Apply proration factor for shared costs
 		userShare = userShare * prorationFactor
 		if prorationFactor < 1.0 {
@@ -1260,7 +1340,7 @@ func (bs *BillingService) getCustomLineItemsWithTranslations(buildingID int, tr
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, description, amount, frequency, category
+		SELECT id, description, amount, frequency, category, vat_code
 		FROM custom_line_items
 		WHERE building_id = ? AND is_active = 1 AND id IN (%s)
 		ORDER BY category, description
@@ -1279,10 +1359,10 @@ func (bs *BillingService) getCustomLineItemsWithTranslations(buildingID int, tr
 
 	for rows.Next() {
 		var itemID int
-		var description, frequency, category string
+		var description, frequency, category, vatCode string
 		var amount float64
 
-		if err := rows.Scan(&itemID, &description, &amount, &frequency, &category); err != nil {
+		if err := rows.Scan(&itemID, &description, &amount, &frequency, &category, &vatCode); err != nil {
 			log.Printf("  [CUSTOM ITEMS] ERROR: Failed to scan item row: %v", err)
 			continue
 		}
@@ -1385,6 +1465,7 @@ func (bs *BillingService) getCustomLineItemsWithTranslations(buildingID int, tr
 			UnitPrice:   amount,
 			TotalPrice:  proratedAmount,
 			ItemType:    "custom_item",
+			VATCode:     bs.customItemVATCode(vatCode, category),
 		})
 
 		totalCost += proratedAmount
@@ -1415,45 +1496,110 @@ func (bs *BillingService) countActiveUsers(buildingID int) (int, error) {
 // the whole invoice is rolled back, so a half-written invoice (header with
 // missing line items) can never be persisted — previously items were inserted
 // one-by-one and failures were only logged. Shared by the apartment, vZEV and
-// charger-only invoice paths.
+// charger-only invoice paths. In dry-run mode nothing is written and the id is 0.
 func (bs *BillingService) insertInvoiceWithItems(
 	invoiceNumber string, userID, buildingID int,
 	periodStart, periodEnd string,
 	totalAmount, netAmount, vatAmount, vatRate float64, vatIncluded bool, currency string,
 	isVZEV bool, items []models.InvoiceItem,
-) (int64, error) {
+) (int64, string, error) {
+	return bs.insertDocumentWithItems(DocumentTypeInvoice, invoiceNumber, userID, buildingID, periodStart, periodEnd,
+		totalAmount, netAmount, vatAmount, vatRate, vatIncluded, currency, isVZEV, items)
+}
+
+// storedStatus is the status new documents are stored with: draft while
+// awaiting approval, issued otherwise.
+func (bs *BillingService) storedStatus() string {
+	if bs.drafts {
+		return InvoiceStatusDraft
+	}
+	return InvoiceStatusIssued
+}
+
+// insertDocumentWithItems is insertInvoiceWithItems for any document type
+// (advance invoices and settlements use it directly). When the building (or
+// the service's override) has a number scheme, the invoice number is drawn
+// from it in the same transaction and replaces the given legacy number; the
+// number actually stored is returned. Dry runs and drafts never allocate a
+// number.
+func (bs *BillingService) insertDocumentWithItems(
+	documentType, invoiceNumber string, userID, buildingID int,
+	periodStart, periodEnd string,
+	totalAmount, netAmount, vatAmount, vatRate float64, vatIncluded bool, currency string,
+	isVZEV bool, items []models.InvoiceItem,
+) (int64, string, error) {
+	if bs.dryRun {
+		return 0, invoiceNumber, nil
+	}
+	now := time.Now()
+	dueDate := PaymentDueDate(bs.db, buildingID, now)
+
 	tx, err := bs.db.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin invoice transaction: %v", err)
+		return 0, "", fmt.Errorf("failed to begin invoice transaction: %v", err)
 	}
 	defer tx.Rollback() // no-op once committed
 
+	var originalID interface{}
+	if bs.replaces != 0 {
+		originalID = bs.replaces
+	}
+
+	var schemeID interface{}
+	status := bs.storedStatus()
+	if !bs.drafts {
+		number, id, err := allocateInvoiceNumber(tx, bs.numberSchemeID, buildingID, now)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to allocate invoice number: %v", err)
+		}
+		if id != 0 {
+			invoiceNumber, schemeID = number, id
+		}
+	}
+
 	result, err := tx.Exec(`
 		INSERT INTO invoices (
 			invoice_number, user_id, building_id, period_start, period_end,
-			total_amount, net_amount, vat_amount, vat_rate, vat_included, currency, status, is_vzev
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'issued', ?)
+			total_amount, net_amount, vat_amount, vat_rate, vat_included, currency, status, is_vzev,
+			document_type, due_date, number_scheme_id, original_invoice_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, invoiceNumber, userID, buildingID, periodStart, periodEnd,
-		totalAmount, netAmount, vatAmount, vatRate, vatIncluded, currency, isVZEV)
+		totalAmount, netAmount, vatAmount, vatRate, vatIncluded, currency, status, isVZEV, documentType, dueDate, schemeID,
+		originalID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create invoice: %v", err)
+		return 0, "", fmt.Errorf("failed to create invoice: %v", err)
 	}
 	invoiceID, _ := result.LastInsertId()
 
-	for _, item := range items {
-		if _, err := tx.Exec(`
-			INSERT INTO invoice_items (
-				invoice_id, description, quantity, unit_price, total_price, item_type
-			) VALUES (?, ?, ?, ?, ?, ?)
-		`, invoiceID, item.Description, item.Quantity, item.UnitPrice, item.TotalPrice, item.ItemType); err != nil {
-			return 0, fmt.Errorf("failed to insert invoice item: %v", err)
+	if err := insertInvoiceItemsTx(tx, invoiceID, items); err != nil {
+		return 0, "", err
+	}
+	if !bs.drafts {
+		if _, err := applyCreditBalance(tx, int(invoiceID)); err != nil {
+			return 0, "", fmt.Errorf("failed to apply credit balance: %v", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit invoice: %v", err)
+		return 0, "", fmt.Errorf("failed to commit invoice: %v", err)
+	}
+	return invoiceID, invoiceNumber, nil
+}
+
+// insertInvoiceItemsTx writes the line items of an invoice inside the caller's
+// transaction.
+func insertInvoiceItemsTx(tx *sql.Tx, invoiceID int64, items []models.InvoiceItem) error {
+	for _, item := range items {
+		if _, err := tx.Exec(`
+			INSERT INTO invoice_items (
+				invoice_id, description, quantity, unit_price, total_price, item_type, vat_code, vat_rate
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, invoiceID, item.Description, item.Quantity, item.UnitPrice, item.TotalPrice, item.ItemType,
+			item.VATCode, item.VATRate); err != nil {
+			return fmt.Errorf("failed to insert invoice item: %v", err)
+		}
 	}
-	return invoiceID, nil
+	return nil
 }
 
 // generateUserInvoiceForPeriodWithOptions generates invoice with custom item selection (default apartment scope).
@@ -1522,12 +1668,21 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 	// CRITICAL: Calculate consumption per price segment so a bill that spans a
 	// price change is priced correctly. Each segment is clipped to the user's
 	// actual billing period.
+	// When the segment's pricing row uses time-of-use windows (HT/NT), the grid
+	// share of each 15-minute interval is bucketed by the window it falls in; with
+	// spot pricing (which takes precedence) it is priced at that interval's price.
+	// Otherwise the building's price components, when it has any, split the
+	// single grid price into energy, grid usage, levies and fees.
 	type zevSegment struct {
 		seg              PriceSegment
 		segStart, segEnd time.Time
 		normalPower      float64
 		solarPower       float64
 		batteryPower     float64
+		tariff           *tariffSchedule
+		gridByWindow     map[int]float64
+		spot             *spotPricer
+		components       *gridComponents
 	}
 	var zevSegs []zevSegment
 	var totalNormal, totalSolar, totalBattery, totalConsumption float64
@@ -1536,12 +1691,40 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 		if !ok {
 			continue
 		}
-		normalPower, solarPower, batteryPower, segConsumption := bs.calculateZEVConsumption(userPeriod.UserID, buildingID, segStart, segEnd)
+		zs := zevSegment{seg: seg, segStart: segStart, segEnd: segEnd}
+		var onGrid func(time.Time, float64)
+		if includeMeters {
+			spot, err := bs.spotPricerFor(seg.Settings, segStart, segEnd)
+			if err != nil {
+				return nil, err
+			}
+			zs.spot = spot
+		}
+		if zs.spot != nil {
+			onGrid = zs.spot.add
+		} else if zs.tariff = bs.tariffWindowsFor(buildingID, seg.Settings); zs.tariff != nil {
+			zs.gridByWindow = make(map[int]float64)
+			onGrid = func(ts time.Time, kwh float64) { zs.gridByWindow[zs.tariff.windowIndex(ts)] += kwh }
+		} else if includeMeters {
+			components, err := bs.priceComponentsFor(buildingID, segStart, segEnd)
+			if err != nil {
+				return nil, err
+			}
+			zs.components = components
+		}
+		normalPower, solarPower, batteryPower, segConsumption := bs.calculateZEVConsumptionWithGrid(userPeriod.UserID, buildingID, segStart, segEnd, onGrid)
 		totalNormal += normalPower
 		totalSolar += solarPower
 		totalBattery += batteryPower
 		totalConsumption += segConsumption
-		zevSegs = append(zevSegs, zevSegment{seg: seg, segStart: segStart, segEnd: segEnd, normalPower: normalPower, solarPower: solarPower, batteryPower: batteryPower})
+		zs.normalPower, zs.solarPower, zs.batteryPower = normalPower, solarPower, batteryPower
+		// Never price an interval at zero just because its spot price is missing.
+		if zs.spot != nil {
+			if err := zs.spot.missingError(); err != nil {
+				return nil, err
+			}
+		}
+		zevSegs = append(zevSegs, zs)
 	}
 
 	log.Printf("  Meter: %s (Period: %s to %s)", meterName, start.Format("2006-01-02"), end.Format("2006-01-02"))
@@ -1609,7 +1792,13 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 			})
 			log.Printf("  Battery Cost%s: %.3f kWh × %.3f = %.3f %s", suffix, zs.batteryPower, s.BatteryPowerPrice, batteryCost, s.Currency)
 		}
-		if zs.normalPower > 0 {
+		if zs.spot != nil && zs.normalPower > 0 {
+			totalAmount += appendSpotPriceItem(&items, zs.spot, s, suffix, tr)
+		} else if zs.tariff != nil && zs.normalPower > 0 {
+			totalAmount += appendTariffWindowItems(&items, zs.tariff, zs.gridByWindow, s, suffix, tr)
+		} else if zs.components != nil && zs.normalPower > 0 {
+			totalAmount += appendPriceComponentItems(&items, zs.components, zs.normalPower, s, suffix, "", tr)
+		} else if zs.normalPower > 0 {
 			normalCost := zs.normalPower * s.NormalPowerPrice
 			totalAmount += normalCost
 			items = append(items, models.InvoiceItem{
@@ -1623,6 +1812,24 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 		}
 	}
 
+	// Peak-demand (kW) charges of the user's demand tariffs, per calendar month.
+	if includeMeters {
+		demandCost, err := bs.appendDemandChargeItems(&items, userPeriod.UserID, buildingID, start, end, primary.Currency, tr)
+		if err != nil {
+			return nil, err
+		}
+		totalAmount += demandCost
+	}
+
+	// Water, heat and gas meters of the user, each in its own section.
+	if includeMeters {
+		utilityCost, err := bs.appendUtilityItems(&items, userPeriod.UserID, buildingID, start, end, primary.Currency, tr)
+		if err != nil {
+			return nil, err
+		}
+		totalAmount += utilityCost
+	}
+
 	// CRITICAL: Car charging for THIS USER'S ACTUAL PERIOD
 	// In building mode, all chargers in the building are billed (charger_id match, no RFID required).
 	// In default (apartment) mode, only the chargers matching the user's RFIDs are billed.
@@ -1678,7 +1885,10 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 
 	// Resolve VAT (MwSt.) from the primary segment and store the breakdown so the
 	// invoice/PDF can render it. gross becomes the stored total (and QR amount).
-	netAmount, vatAmount, grossAmount := vatBreakdown(totalAmount, primary)
+	// Lines are split by their VAT code; exempt ones (e.g. VAT-exempt price
+	// components) stay outside the VAT base.
+	bs.assignItemVAT(items, primary)
+	netAmount, vatAmount, grossAmount := vatBreakdownItems(totalAmount, items, primary)
 	totalAmount = grossAmount
 
 	// SAFETY: never persist a 0.00 invoice. A zero total almost always signals a data
@@ -1692,7 +1902,7 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 	log.Printf("  INVOICE TOTAL: %s %.3f (net %.3f, VAT %.3f @ %.1f%%)", primary.Currency, totalAmount, netAmount, vatAmount, primary.VATRate)
 	log.Printf("  INVOICE NUMBER: %s (Year: %d)", invoiceNumber, invoiceYear)
 
-	invoiceID, err := bs.insertInvoiceWithItems(
+	invoiceID, invoiceNumber, err := bs.insertInvoiceWithItems(
 		invoiceNumber, userPeriod.UserID, buildingID,
 		fullStart.Format("2006-01-02"), displayEnd.Format("2006-01-02"),
 		totalAmount, netAmount, vatAmount, primary.VATRate, primary.VATIncluded, primary.Currency,
@@ -1715,7 +1925,8 @@ func (bs *BillingService) generateUserInvoiceForPeriodWithOptionsAndScope(userPe
 		VATRate:       primary.VATRate,
 		VATIncluded:   primary.VATIncluded,
 		Currency:      primary.Currency,
-		Status:        "issued",
+		Status:        bs.storedStatus(),
+		DocumentType:  DocumentTypeInvoice,
 		Items:         items,
 		GeneratedAt:   time.Now(),
 	}
@@ -1795,6 +2006,15 @@ func (bs *BillingService) getMeterReadings(userID int, start, end time.Time) (fl
 // ZEV calculation using data at fixed 15-minute intervals
 // FIXED: Now uses ConsumptionExport for solar meters (export energy)
 func (bs *BillingService) calculateZEVConsumption(userID, buildingID int, start, end time.Time) (normal, solar, battery, total float64) {
+	return bs.calculateZEVConsumptionWithGrid(userID, buildingID, start, end, nil)
+}
+
+// calculateZEVConsumptionWithGrid is calculateZEVConsumption that additionally
+// reports the user's grid share of every 15-minute interval to onGrid (when
+// non-nil), so time-of-use tariffs can price each interval by its window.
+// onGrid gets the interval start: a reading's consumption_kwh is the delta
+// since the previous reading, so the reading at T measured [T-15m, T).
+func (bs *BillingService) calculateZEVConsumptionWithGrid(userID, buildingID int, start, end time.Time, onGrid func(ts time.Time, kwh float64)) (normal, solar, battery, total float64) {
 	log.Printf("    [ZEV] Calculating consumption for user %d in building %d", userID, buildingID)
 	log.Printf("    [ZEV] Period: %s to %s", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
 
@@ -1976,6 +2196,9 @@ func (bs *BillingService) calculateZEVConsumption(userID, buildingID int, start,
 		totalSolar += userSolar
 		totalBattery += userBattery
 		totalNormal += userNormal
+		if onGrid != nil && userNormal > 0 {
+			onGrid(timestamp.Add(-15*time.Minute), userNormal)
+		}
 
 		if userSolar > 0 {
 			solarUsed += userSolar
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Advance (Akonto) documents: periodic fixed-amount invoices from a tenant's
+// advance plan, and the annual settlement that bills the real consumption
+// against them.
+const (
+	DocumentTypeAdvance    = "advance"
+	DocumentTypeSettlement = "settlement"
+)
+
+// Invoice types an auto-billing config can issue.
+const (
+	AutoBillingTypeConsumption = "consumption"
+	AutoBillingTypeAdvance     = "advance"
+)
+
+// Settlement bases: deduct the advances that were invoiced, or only what was
+// actually paid on them.
+const (
+	SettlementBasisBilled = "billed"
+	SettlementBasisPaid   = "paid"
+)
+
+// SettlementSummary is the arithmetic behind a settlement invoice.
+type SettlementSummary struct {
+	ConsumptionTotal        float64 `json:"consumption_total"`
+	AdvancesTotal           float64 `json:"advances_total"`
+	AdvanceCount            int     `json:"advance_count"`
+	Basis                   string  `json:"basis"`
+	Balance                 float64 `json:"balance"` // > 0 due from the tenant, < 0 refund
+	Months                  int     `json:"months"`
+	SuggestedMonthlyAdvance float64 `json:"suggested_monthly_advance"`
+}
+
+// monthsInPeriod counts the calendar months touched by [start, end] (end
+// inclusive), at least one.
+func monthsInPeriod(start, end time.Time) int {
+	months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+	if months < 1 {
+		return 1
+	}
+	return months
+}
+
+// suggestedAdvance spreads a year's consumption over the months of the settled
+// period, rounded up to the next 5 so the next settlement tends to refund.
+func suggestedAdvance(consumption float64, months int) float64 {
+	if consumption <= 0 || months < 1 {
+		return 0
+	}
+	return math.Ceil(consumption/float64(months)/5) * 5
+}
+
+// loadAdvancePlans returns the active plans of the given buildings (and users,
+// if any) that cover day.
+func (bs *BillingService) loadAdvancePlans(buildingIDs, userIDs []int, day string) ([]models.AdvancePlan, error) {
+	if len(buildingIDs) == 0 {
+		return nil, nil
+	}
+	query := `
+		SELECT id, user_id, building_id, monthly_amount, COALESCE(currency, 'CHF'),
+		       valid_from, COALESCE(valid_to, ''), is_active, COALESCE(notes, '')
+		FROM advance_plans
+		WHERE is_active = 1 AND valid_from <= ? AND (valid_to IS NULL OR valid_to = '' OR valid_to >= ?)
+		AND building_id IN (?` + strings.Repeat(",?", len(buildingIDs)-1) + `)`
+	args := []interface{}{day, day}
+	for _, id := range buildingIDs {
+		args = append(args, id)
+	}
+	if len(userIDs) > 0 {
+		query += ` AND user_id IN (?` + strings.Repeat(",?", len(userIDs)-1) + `)`
+		for _, id := range userIDs {
+			args = append(args, id)
+		}
+	}
+	query += ` ORDER BY building_id, user_id`
+
+	rows, err := bs.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []models.AdvancePlan
+	for rows.Next() {
+		var p models.AdvancePlan
+		if err := rows.Scan(&p.ID, &p.UserID, &p.BuildingID, &p.MonthlyAmount, &p.Currency,
+			&p.ValidFrom, &p.ValidTo, &p.IsActive, &p.Notes); err != nil {
+			return nil, err
+		}
+		p.ValidFrom, p.ValidTo = storedDay(p.ValidFrom), storedDay(p.ValidTo)
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+// GenerateAdvanceInvoices issues one advance invoice per active plan for
+// [startDate, endDate]: the plan's monthly amount times the months in the
+// period. Advances carry no VAT breakdown; VAT is accounted on the settlement.
+// A tenant who already has an issued advance for the same period start is
+// skipped, so a re-run never bills twice.
+func (bs *BillingService) GenerateAdvanceInvoices(buildingIDs, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid start date: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid end date: %v", err)
+	}
+	if end.Before(start) {
+		return nil, nil, fmt.Errorf("end date must not be before start date")
+	}
+
+	plans, err := bs.loadAdvancePlans(buildingIDs, userIDs, startDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load advance plans: %v", err)
+	}
+	log.Printf("=== ADVANCE INVOICES: %d plan(s), period %s to %s ===", len(plans), startDate, endDate)
+
+	months := monthsInPeriod(start, end)
+	invoices := []models.Invoice{}
+	skipped := []SkippedBill{}
+	for _, plan := range plans {
+		user := loadInvoiceUser(bs.db, plan.UserID)
+		if user == nil {
+			log.Printf("WARNING: Advance plan %d references missing user %d", plan.ID, plan.UserID)
+			continue
+		}
+		userName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+		var existing string
+		err := bs.db.QueryRow(`
+			SELECT invoice_number FROM invoices
+			WHERE user_id = ? AND building_id = ? AND document_type = ? AND status = ? AND period_start = ?
+			LIMIT 1
+		`, plan.UserID, plan.BuildingID, DocumentTypeAdvance, InvoiceStatusIssued, startDate).Scan(&existing)
+		if err == nil {
+			skipped = append(skipped, SkippedBill{
+				UserID: plan.UserID, UserName: userName, BuildingID: plan.BuildingID,
+				Reason: fmt.Sprintf("advance invoice %s already issued for this period", existing),
+			})
+			continue
+		}
+
+		amount := math.Round(plan.MonthlyAmount*float64(months)*100) / 100
+		if amount <= zeroBillEpsilon {
+			skipped = append(skipped, SkippedBill{
+				UserID: plan.UserID, UserName: userName, BuildingID: plan.BuildingID,
+				Reason: "advance plan has no monthly amount",
+			})
+			continue
+		}
+
+		tr := GetTranslations(user.Language)
+		items := []models.InvoiceItem{{
+			Description: fmt.Sprintf("%s %s - %s", tr.AdvancePayment, start.Format("02.01.2006"), end.Format("02.01.2006")),
+			Quantity:    float64(months),
+			UnitPrice:   plan.MonthlyAmount,
+			TotalPrice:  amount,
+			ItemType:    "advance_payment",
+		}}
+
+		invoiceNumber := fmt.Sprintf("ADV-%d-%d-%d-%s", start.Year(), plan.BuildingID, plan.UserID, time.Now().Format("20060102150405"))
+		invoiceID, invoiceNumber, err := bs.insertDocumentWithItems(DocumentTypeAdvance, invoiceNumber, plan.UserID, plan.BuildingID,
+			startDate, endDate, amount, amount, 0, 0, false, plan.Currency, false, items)
+		if err != nil {
+			log.Printf("ERROR: Failed to issue advance invoice for user %d: %v", plan.UserID, err)
+			skipped = append(skipped, SkippedBill{
+				UserID: plan.UserID, UserName: userName, BuildingID: plan.BuildingID,
+				Reason: err.Error(),
+			})
+			continue
+		}
+
+		invoices = append(invoices, models.Invoice{
+			ID:            int(invoiceID),
+			InvoiceNumber: invoiceNumber,
+			UserID:        plan.UserID,
+			BuildingID:    plan.BuildingID,
+			PeriodStart:   startDate,
+			PeriodEnd:     endDate,
+			TotalAmount:   amount,
+			NetAmount:     amount,
+			Currency:      plan.Currency,
+			Status:        bs.storedStatus(),
+			DocumentType:  DocumentTypeAdvance,
+			Items:         items,
+			GeneratedAt:   time.Now(),
+		})
+		log.Printf("  Advance %s: %s %.2f (%d x %.2f) for %s", invoiceNumber, plan.Currency, amount, months, plan.MonthlyAmount, userName)
+	}
+	return invoices, skipped, nil
+}
+
+// PreviewAdvanceInvoices is GenerateAdvanceInvoices without writing anything.
+func (bs *BillingService) PreviewAdvanceInvoices(buildingIDs, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	invoices, skipped, err := dry.GenerateAdvanceInvoices(buildingIDs, userIDs, startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	bs.markPreviews(invoices)
+	return invoices, skipped, nil
+}
+
+// sumAdvances totals the issued (not cancelled) advance invoices of a tenant
+// whose period starts within [startDate, endDate].
+func (bs *BillingService) sumAdvances(userID, buildingID int, startDate, endDate, basis string) (float64, int, error) {
+	column := "total_amount"
+	if basis == SettlementBasisPaid {
+		column = "COALESCE(paid_amount, 0)"
+	}
+	var total float64
+	var count int
+	err := bs.db.QueryRow(`
+		SELECT COALESCE(SUM(`+column+`), 0), COUNT(*) FROM invoices
+		WHERE user_id = ? AND building_id = ? AND document_type = ? AND status = ?
+		AND period_start >= ? AND period_start <= ?
+	`, userID, buildingID, DocumentTypeAdvance, InvoiceStatusIssued, startDate, endDate).Scan(&total, &count)
+	return math.Round(total*100) / 100, count, err
+}
+
+// GenerateSettlement bills a tenant's real consumption for [startDate, endDate]
+// and deducts the advances of that period. The consumption lines are exactly
+// what a regular invoice for the period would contain; the stored total is the
+// balance, negative when the tenant is owed a refund. The settlement also
+// suggests the monthly advance for the next period.
+func (bs *BillingService) GenerateSettlement(userID, buildingID int, startDate, endDate string, isVZEV bool, customItemIDs []int, basis string) (*models.Invoice, *SettlementSummary, error) {
+	if basis != SettlementBasisPaid {
+		basis = SettlementBasisBilled
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid start date: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid end date: %v", err)
+	}
+
+	dry := &BillingService{db: bs.db, dryRun: true}
+	bills, skipped, err := dry.GenerateBillsWithOptions([]int{buildingID}, []int{userID}, startDate, endDate, isVZEV, customItemIDs, BillingScope{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bills) == 0 {
+		if len(skipped) > 0 {
+			return nil, nil, fmt.Errorf("no consumption to settle: %s", skipped[0].Reason)
+		}
+		return nil, nil, fmt.Errorf("no billable period for user %d in building %d", userID, buildingID)
+	}
+
+	advances, count, err := bs.sumAdvances(userID, buildingID, startDate, endDate, basis)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load advances: %v", err)
+	}
+
+	first := bills[0]
+	var consumption, netAmount, vatAmount float64
+	var items []models.InvoiceItem
+	for _, bill := range bills {
+		consumption += bill.TotalAmount
+		netAmount += bill.NetAmount
+		vatAmount += bill.VATAmount
+		items = append(items, bill.Items...)
+	}
+	balance := math.Round((consumption-advances)*100) / 100
+	months := monthsInPeriod(start, end)
+	summary := &SettlementSummary{
+		ConsumptionTotal:        math.Round(consumption*100) / 100,
+		AdvancesTotal:           advances,
+		AdvanceCount:            count,
+		Basis:                   basis,
+		Balance:                 balance,
+		Months:                  months,
+		SuggestedMonthlyAdvance: suggestedAdvance(consumption, months),
+	}
+
+	user := loadInvoiceUser(bs.db, userID)
+	language := "de"
+	if user != nil {
+		language = user.Language
+	}
+	tr := GetTranslations(language)
+	balanceLabel := tr.SettlementDue
+	if balance < 0 {
+		balanceLabel = tr.SettlementRefund
+	}
+	items = append(items,
+		models.InvoiceItem{Description: "", ItemType: "separator"},
+		models.InvoiceItem{
+			Description: fmt.Sprintf("%s (%d)", tr.AdvancesDeducted, count),
+			Quantity:    float64(count),
+			TotalPrice:  negateAmount(advances),
+			ItemType:    "advance_deduction",
+		},
+		models.InvoiceItem{
+			Description: fmt.Sprintf("%s: %s %.2f", balanceLabel, first.Currency, math.Abs(balance)),
+			ItemType:    "settlement_balance",
+		},
+		models.InvoiceItem{
+			Description: fmt.Sprintf("%s: %s %.2f", tr.SuggestedAdvance, first.Currency, summary.SuggestedMonthlyAdvance),
+			UnitPrice:   summary.SuggestedMonthlyAdvance,
+			ItemType:    "advance_suggestion",
+		},
+	)
+
+	invoiceNumber := fmt.Sprintf("STL-%d-%d-%d-%s", start.Year(), buildingID, userID, time.Now().Format("20060102150405"))
+	invoiceID, invoiceNumber, err := bs.insertDocumentWithItems(DocumentTypeSettlement, invoiceNumber, userID, buildingID,
+		startDate, endDate, balance, netAmount, vatAmount, first.VATRate, first.VATIncluded, first.Currency, isVZEV, items)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("SETTLEMENT %s: consumption %.2f - advances %.2f (%s, %d) = %.2f, suggested advance %.2f",
+		invoiceNumber, consumption, advances, basis, count, balance, summary.SuggestedMonthlyAdvance)
+
+	return &models.Invoice{
+		ID:            int(invoiceID),
+		InvoiceNumber: invoiceNumber,
+		UserID:        userID,
+		BuildingID:    buildingID,
+		PeriodStart:   startDate,
+		PeriodEnd:     endDate,
+		TotalAmount:   balance,
+		NetAmount:     netAmount,
+		VATAmount:     vatAmount,
+		VATRate:       first.VATRate,
+		VATIncluded:   first.VATIncluded,
+		Currency:      first.Currency,
+		Status:        bs.storedStatus(),
+		IsVZEV:        isVZEV,
+		DocumentType:  DocumentTypeSettlement,
+		Items:         items,
+		User:          user,
+		GeneratedAt:   time.Now(),
+	}, summary, nil
+}
+
+// PreviewSettlement is GenerateSettlement without writing anything.
+func (bs *BillingService) PreviewSettlement(userID, buildingID int, startDate, endDate string, isVZEV bool, customItemIDs []int, basis string) (*models.Invoice, *SettlementSummary, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	invoice, summary, err := dry.GenerateSettlement(userID, buildingID, startDate, endDate, isVZEV, customItemIDs, basis)
+	if err != nil {
+		return nil, nil, err
+	}
+	previews := []models.Invoice{*invoice}
+	bs.markPreviews(previews)
+	return &previews[0], summary, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestGenerateAdvanceInvoices(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	if _, err := db.Exec(`INSERT INTO advance_plans (user_id, building_id, monthly_amount, valid_from) VALUES (10, 1, 80, '2026-01-01')`); err != nil {
+		t.Fatalf("insert plan: %v", err)
+	}
+
+	invoices, skipped, err := bs.GenerateAdvanceInvoices([]int{1}, nil, "2026-01-01", "2026-03-31")
+	if err != nil || len(invoices) != 1 || len(skipped) != 0 {
+		t.Fatalf("GenerateAdvanceInvoices = %d invoices, %d skipped, %v", len(invoices), len(skipped), err)
+	}
+	if inv := invoices[0]; !almostEqual(inv.TotalAmount, 240) || inv.DocumentType != DocumentTypeAdvance {
+		t.Errorf("advance = %.2f (%s), want 240.00 advance", inv.TotalAmount, inv.DocumentType)
+	}
+
+	// A second run for the same period must not bill again.
+	invoices, skipped, _ = bs.GenerateAdvanceInvoices([]int{1}, nil, "2026-01-01", "2026-03-31")
+	if len(invoices) != 0 || len(skipped) != 1 {
+		t.Errorf("re-run = %d invoices, %d skipped; want 0, 1", len(invoices), len(skipped))
+	}
+
+	if _, err := db.Exec(`UPDATE invoices SET paid_amount = 200`); err != nil {
+		t.Fatal(err)
+	}
+	if total, n, _ := bs.sumAdvances(10, 1, "2026-01-01", "2026-12-31", SettlementBasisBilled); !almostEqual(total, 240) || n != 1 {
+		t.Errorf("billed advances = %.2f (%d)", total, n)
+	}
+	if total, _, _ := bs.sumAdvances(10, 1, "2026-01-01", "2026-12-31", SettlementBasisPaid); !almostEqual(total, 200) {
+		t.Errorf("paid advances = %.2f, want 200", total)
+	}
+
+	if got := suggestedAdvance(1003, 12); got != 85 {
+		t.Errorf("suggestedAdvance(1003, 12) = %.2f, want 85", got)
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Document types stored in invoices.document_type.
+const (
+	DocumentTypeInvoice    = "invoice"
+	DocumentTypeCreditNote = "credit_note"
+)
+
+// Invoice statuses used by the cancellation (Storno) flow. An issued invoice is
+// never deleted once sent: it is reversed by a credit note and flips to
+// "cancelled"; the credit note itself carries "credited".
+const (
+	InvoiceStatusIssued    = "issued"
+	InvoiceStatusCancelled = "cancelled"
+	InvoiceStatusCredited  = "credited"
+)
+
+// negateAmount flips the sign of a money/quantity value without producing a
+// negative zero (which would render as "-0.00" on the PDF).
+func negateAmount(v float64) float64 {
+	if v == 0 {
+		return 0
+	}
+	return -v
+}
+
+// creditNoteItems returns the negated copy of an invoice's items. Informational
+// rows (meter readings, headers, notices) have no price and are copied as-is so
+// the credit note shows the same context as the invoice it reverses.
+func creditNoteItems(items []models.InvoiceItem) []models.InvoiceItem {
+	out := make([]models.InvoiceItem, 0, len(items))
+	for _, item := range items {
+		if item.TotalPrice != 0 {
+			item.Quantity = negateAmount(item.Quantity)
+			item.TotalPrice = negateAmount(item.TotalPrice)
+		}
+		item.ID = 0
+		item.InvoiceID = 0
+		out = append(out, item)
+	}
+	return out
+}
+
+// storedDay trims a stored period date to YYYY-MM-DD. The sqlite driver hands
+// DATE columns back as RFC 3339 timestamps.
+func storedDay(s string) string {
+	if len(s) > 10 {
+		return s[:10]
+	}
+	return s
+}
+
+// creditNoteNumber derives the legacy credit note number from the invoice it
+// reverses: same year/building/user segments, "CN" prefix and a fresh
+// timestamp. A building with a number scheme draws the number from the
+// scheme's credit note series instead (see allocateCreditNumber).
+func creditNoteNumber(invoiceNumber string) string {
+	base := invoiceNumber
+	if i := strings.Index(base, "-"); i >= 0 {
+		base = base[i+1:]
+	}
+	return fmt.Sprintf("CN-%s-%s", base, time.Now().Format("20060102150405"))
+}
+
+// allocateCreditNumber numbers a credit note for the invoice it credits inside
+// the caller's transaction: from the credit note series of the invoice's
+// scheme (or the building's), the legacy number when there is none. The
+// scheme ID is nil for legacy numbers, ready for number_scheme_id.
+func allocateCreditNumber(tx *sql.Tx, orig models.Invoice, schemeID int, issued time.Time) (string, interface{}, error) {
+	number, id, err := allocateCreditNoteNumber(tx, schemeID, orig.BuildingID, issued)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to allocate credit note number: %v", err)
+	}
+	if id == 0 {
+		return creditNoteNumber(orig.InvoiceNumber), nil, nil
+	}
+	return number, id, nil
+}
+
+// CancelInvoice reverses an issued invoice with a credit note: a new document
+// with its own number, the negated items and amounts, linked back through
+// original_invoice_id. The original keeps its row and number and moves to
+// "cancelled". Both writes happen in one transaction.
+func (bs *BillingService) CancelInvoice(invoiceID int, reason string) (*models.Invoice, error) {
+	tx, err := bs.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin cancellation transaction: %v", err)
+	}
+	defer tx.Rollback() // no-op once committed
+
+	var orig models.Invoice
+	var schemeID int
+	err = tx.QueryRow(`
+		SELECT id, invoice_number, user_id, building_id, period_start, period_end,
+		       total_amount, COALESCE(net_amount, 0), COALESCE(vat_amount, 0), COALESCE(vat_rate, 0),
+		       COALESCE(vat_included, 0), currency, status, COALESCE(is_vzev, 0),
+		       COALESCE(document_type, 'invoice'), COALESCE(number_scheme_id, 0)
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(
+		&orig.ID, &orig.InvoiceNumber, &orig.UserID, &orig.BuildingID, &orig.PeriodStart, &orig.PeriodEnd,
+		&orig.TotalAmount, &orig.NetAmount, &orig.VATAmount, &orig.VATRate,
+		&orig.VATIncluded, &orig.Currency, &orig.Status, &orig.IsVZEV,
+		&orig.DocumentType, &schemeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	orig.PeriodStart, orig.PeriodEnd = storedDay(orig.PeriodStart), storedDay(orig.PeriodEnd)
+	if IsCreditDocument(orig.DocumentType) {
+		return nil, fmt.Errorf("invoice %s is a credit document and cannot be cancelled", orig.InvoiceNumber)
+	}
+	if orig.Status != InvoiceStatusIssued {
+		return nil, fmt.Errorf("invoice %s is %s; only issued invoices can be cancelled", orig.InvoiceNumber, orig.Status)
+	}
+
+	rows, err := tx.Query(`
+		SELECT description, quantity, unit_price, total_price, item_type, vat_code, vat_rate
+		FROM invoice_items WHERE invoice_id = ? ORDER BY id ASC
+	`, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice items: %v", err)
+	}
+	var items []models.InvoiceItem
+	for rows.Next() {
+		var item models.InvoiceItem
+		if err := rows.Scan(&item.Description, &item.Quantity, &item.UnitPrice, &item.TotalPrice, &item.ItemType,
+			&item.VATCode, &item.VATRate); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read invoice item: %v", err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	number, creditSchemeID, err := allocateCreditNumber(tx, orig, schemeID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	credit := models.Invoice{
+		InvoiceNumber:         number,
+		UserID:                orig.UserID,
+		BuildingID:            orig.BuildingID,
+		PeriodStart:           orig.PeriodStart,
+		PeriodEnd:             orig.PeriodEnd,
+		TotalAmount:           negateAmount(orig.TotalAmount),
+		NetAmount:             negateAmount(orig.NetAmount),
+		VATAmount:             negateAmount(orig.VATAmount),
+		VATRate:               orig.VATRate,
+		VATIncluded:           orig.VATIncluded,
+		Currency:              orig.Currency,
+		Status:                InvoiceStatusCredited,
+		IsVZEV:                orig.IsVZEV,
+		Items:                 creditNoteItems(items),
+		DocumentType:          DocumentTypeCreditNote,
+		OriginalInvoiceID:     &orig.ID,
+		OriginalInvoiceNumber: orig.InvoiceNumber,
+		GeneratedAt:           time.Now(),
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO invoices (
+			invoice_number, user_id, building_id, period_start, period_end,
+			total_amount, net_amount, vat_amount, vat_rate, vat_included, currency, status, is_vzev,
+			document_type, original_invoice_id, number_scheme_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, credit.InvoiceNumber, credit.UserID, credit.BuildingID, credit.PeriodStart, credit.PeriodEnd,
+		credit.TotalAmount, credit.NetAmount, credit.VATAmount, credit.VATRate, credit.VATIncluded, credit.Currency,
+		credit.Status, credit.IsVZEV, credit.DocumentType, orig.ID, creditSchemeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credit note: %v", err)
+	}
+	creditID, _ := result.LastInsertId()
+	credit.ID = int(creditID)
+
+	if err := insertInvoiceItemsTx(tx, creditID, credit.Items); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE invoices SET status = ?, cancelled_at = ?, cancellation_reason = ? WHERE id = ?
+	`, InvoiceStatusCancelled, time.Now().Format("2006-01-02 15:04:05"), reason, orig.ID); err != nil {
+		return nil, fmt.Errorf("failed to mark invoice cancelled: %v", err)
+	}
+	// The credit note settles the original in full; whatever the tenant
+	// already paid on it becomes credit balance.
+	if err := bookCreditNote(tx, credit.ID, orig.ID, credit.InvoiceNumber, credit.TotalAmount); err != nil {
+		return nil, fmt.Errorf("failed to book credit note: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit cancellation: %v", err)
+	}
+
+	log.Printf("Invoice %s cancelled by credit note %s (%s %.2f)",
+		orig.InvoiceNumber, credit.InvoiceNumber, credit.Currency, credit.TotalAmount)
+	return &credit, nil
+}
+
+// ReissueInvoice bills the period of a cancelled invoice again — picking up
+// corrected readings or prices — and links the new invoice to the original via
+// original_invoice_id, set in the transaction that writes it. Only one
+// replacement per cancelled invoice is allowed.
+func (bs *BillingService) ReissueInvoice(invoiceID int, customItemIDs []int, scope BillingScope) ([]models.Invoice, []SkippedBill, error) {
+	var userID, buildingID int
+	var periodStart, periodEnd, status, documentType, invoiceNumber string
+	var isVZEV bool
+	err := bs.db.QueryRow(`
+		SELECT invoice_number, user_id, building_id, period_start, period_end, status,
+		       COALESCE(is_vzev, 0), COALESCE(document_type, 'invoice')
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&invoiceNumber, &userID, &buildingID, &periodStart, &periodEnd, &status, &isVZEV, &documentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if documentType != DocumentTypeInvoice || status != InvoiceStatusCancelled {
+		return nil, nil, fmt.Errorf("invoice %s must be cancelled before a replacement can be issued", invoiceNumber)
+	}
+
+	var existing int
+	if err := bs.db.QueryRow(`
+		SELECT COUNT(*) FROM invoices WHERE original_invoice_id = ? AND document_type = ?
+	`, invoiceID, DocumentTypeInvoice).Scan(&existing); err != nil {
+		return nil, nil, err
+	}
+	if existing > 0 {
+		return nil, nil, fmt.Errorf("invoice %s already has a replacement invoice", invoiceNumber)
+	}
+
+	invoices, skipped, err := bs.replacing(invoiceID).GenerateBillsWithOptions(
+		[]int{buildingID}, []int{userID},
+		storedDay(periodStart), storedDay(periodEnd),
+		isVZEV, customItemIDs, scope,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range invoices {
+		invoices[i].DocumentType = DocumentTypeInvoice
+		invoices[i].OriginalInvoiceID = &invoiceID
+		invoices[i].OriginalInvoiceNumber = invoiceNumber
+	}
+	return invoices, skipped, nil
+}
+
+// LoadInvoiceChain returns the other documents linked to an invoice: its
+// original (for credit notes and replacements) and every document that
+// references it (credit note, replacement).
+func LoadInvoiceChain(db *sql.DB, invoiceID int, originalID *int) ([]models.InvoiceRef, error) {
+	query := `
+		SELECT id, invoice_number, COALESCE(document_type, 'invoice'), status, total_amount, generated_at
+		FROM invoices
+		WHERE id <> ? AND (original_invoice_id = ?`
+	args := []interface{}{invoiceID, invoiceID}
+	if originalID != nil {
+		query += ` OR id = ? OR original_invoice_id = ?`
+		args = append(args, *originalID, *originalID)
+	}
+	query += `) ORDER BY generated_at ASC, id ASC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := []models.InvoiceRef{}
+	for rows.Next() {
+		var ref models.InvoiceRef
+		if err := rows.Scan(&ref.ID, &ref.InvoiceNumber, &ref.DocumentType, &ref.Status, &ref.TotalAmount, &ref.GeneratedAt); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
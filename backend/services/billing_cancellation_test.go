@@ -0,0 +1,94 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestCancelInvoice(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	items := []models.InvoiceItem{
+		{Description: "Meter 1: 100 → 200 kWh", ItemType: "meter_info"},
+		{Description: "Normal power", Quantity: 100, UnitPrice: 0.25, TotalPrice: 25, ItemType: "normal_power"},
+		{Description: "Solar power", Quantity: 50, UnitPrice: 0.15, TotalPrice: 7.5, ItemType: "solar_power"},
+	}
+	origID, _, err := bs.insertInvoiceWithItems("INV-2026-1-10-1", 10, 1, "2026-01-01", "2026-01-31",
+		32.5, 30.09, 2.41, 8.1, true, "CHF", false, items)
+	if err != nil {
+		t.Fatalf("insert invoice: %v", err)
+	}
+
+	credit, err := bs.CancelInvoice(int(origID), "wrong meter reading")
+	if err != nil {
+		t.Fatalf("CancelInvoice: %v", err)
+	}
+	if credit.DocumentType != DocumentTypeCreditNote || credit.Status != InvoiceStatusCredited {
+		t.Errorf("credit note type/status = %s/%s", credit.DocumentType, credit.Status)
+	}
+	if !almostEqual(credit.TotalAmount, -32.5) || !almostEqual(credit.NetAmount, -30.09) || !almostEqual(credit.VATAmount, -2.41) {
+		t.Errorf("credit amounts = %.2f/%.2f/%.2f, want negated original", credit.TotalAmount, credit.NetAmount, credit.VATAmount)
+	}
+	if credit.InvoiceNumber == "INV-2026-1-10-1" {
+		t.Errorf("credit note must have its own number")
+	}
+
+	var itemSum float64
+	var infoRows int
+	rows, err := db.Query(`SELECT quantity, total_price, item_type FROM invoice_items WHERE invoice_id = ?`, credit.ID)
+	if err != nil {
+		t.Fatalf("query credit items: %v", err)
+	}
+	for rows.Next() {
+		var qty, total float64
+		var itemType string
+		rows.Scan(&qty, &total, &itemType)
+		itemSum += total
+		if itemType == "meter_info" {
+			infoRows++
+			if total != 0 || qty != 0 {
+				t.Errorf("info row should be copied unchanged, got qty=%v total=%v", qty, total)
+			}
+		} else if qty >= 0 {
+			t.Errorf("%s quantity = %v, want negated", itemType, qty)
+		}
+	}
+	rows.Close()
+	if !almostEqual(itemSum, -32.5) || infoRows != 1 {
+		t.Errorf("credit items sum=%.2f info=%d", itemSum, infoRows)
+	}
+
+	var status string
+	var originalRef int
+	db.QueryRow(`SELECT status FROM invoices WHERE id = ?`, origID).Scan(&status)
+	db.QueryRow(`SELECT original_invoice_id FROM invoices WHERE id = ?`, credit.ID).Scan(&originalRef)
+	if status != InvoiceStatusCancelled || originalRef != int(origID) {
+		t.Errorf("original status=%s, credit links to %d (want cancelled, %d)", status, originalRef, origID)
+	}
+
+	if _, err := bs.CancelInvoice(int(origID), ""); err == nil {
+		t.Errorf("cancelling a cancelled invoice should fail")
+	}
+	if _, err := bs.CancelInvoice(credit.ID, ""); err == nil {
+		t.Errorf("cancelling a credit note should fail")
+	}
+
+	chain, err := LoadInvoiceChain(db, credit.ID, credit.OriginalInvoiceID)
+	if err != nil || len(chain) != 1 || chain[0].ID != int(origID) {
+		t.Errorf("chain from credit note = %+v, %v", chain, err)
+	}
+
+	// A replacement is linked to the original as it is written.
+	replacementID, _, err := bs.replacing(int(origID)).insertInvoiceWithItems("INV-2026-1-10-2", 10, 1, "2026-01-01", "2026-01-31",
+		30, 27.75, 2.25, 8.1, true, "CHF", false, items)
+	if err != nil {
+		t.Fatalf("insert replacement: %v", err)
+	}
+	db.QueryRow(`SELECT original_invoice_id FROM invoices WHERE id = ?`, replacementID).Scan(&originalRef)
+	if originalRef != int(origID) {
+		t.Errorf("replacement links to %d, want %d", originalRef, origID)
+	}
+}
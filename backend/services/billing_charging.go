@@ -1188,8 +1188,10 @@ func (bs *BillingService) generateChargerOnlyInvoice(userPeriod UserPeriod, buil
 		}
 	}
 
-	// Resolve VAT (MwSt.) from the primary segment; gross becomes the stored total.
-	netAmount, vatAmount, grossAmount := vatBreakdown(totalAmount, primary)
+	// Resolve VAT (MwSt.) from the primary segment and the lines' VAT codes;
+	// gross becomes the stored total.
+	bs.assignItemVAT(items, primary)
+	netAmount, vatAmount, grossAmount := vatBreakdownItems(totalAmount, items, primary)
 	totalAmount = grossAmount
 
 	// SAFETY: never persist a 0.00 charger invoice (see generateUserInvoiceForPeriodWithOptionsAndScope).
@@ -1198,7 +1200,7 @@ func (bs *BillingService) generateChargerOnlyInvoice(userPeriod UserPeriod, buil
 			primary.Currency, userPeriod.FirstName, userPeriod.LastName)
 	}
 
-	invoiceID, err := bs.insertInvoiceWithItems(
+	invoiceID, invoiceNumber, err := bs.insertInvoiceWithItems(
 		invoiceNumber, userPeriod.UserID, buildingID,
 		fullStart.Format("2006-01-02"), displayEnd.Format("2006-01-02"),
 		totalAmount, netAmount, vatAmount, primary.VATRate, primary.VATIncluded, primary.Currency,
@@ -1221,7 +1223,8 @@ func (bs *BillingService) generateChargerOnlyInvoice(userPeriod UserPeriod, buil
 		VATRate:       primary.VATRate,
 		VATIncluded:   primary.VATIncluded,
 		Currency:      primary.Currency,
-		Status:        "issued",
+		Status:        bs.storedStatus(),
+		DocumentType:  DocumentTypeInvoice,
 		Items:         items,
 		GeneratedAt:   time.Now(),
 	}, nil
@@ -0,0 +1,663 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// LEG (Lokale Elektrizitätsgemeinschaft) participant roles and allocation
+// methods. Unlike a ZEV, a LEG shares production over the public grid: every
+// participant keeps their own grid connection and the community only decides,
+// per 15-minute interval, which part of the measured consumption counts as
+// locally produced.
+const (
+	LEGRoleConsumer = "consumer"
+	LEGRoleProducer = "producer"
+
+	// LEGAllocationProportional shares local production in proportion to each
+	// consumer's consumption in the interval.
+	LEGAllocationProportional = "proportional"
+	// LEGAllocationEqual shares it equally, capped at each consumer's
+	// consumption; what a small consumer cannot take goes to the others.
+	LEGAllocationEqual = "equal"
+)
+
+// DocumentTypeProducerCredit is the credit statement a LEG producer receives
+// for the production the community consumed. Like a credit note it is stored
+// with negative amounts.
+const DocumentTypeProducerCredit = "producer_credit"
+
+// IsCreditDocument reports whether a document credits its recipient rather
+// than billing them, so it cannot take payments, reminders or a cancellation.
+func IsCreditDocument(documentType string) bool {
+	return documentType == DocumentTypeCreditNote || documentType == DocumentTypeProducerCredit
+}
+
+// ValidLEGAllocationMethod reports whether m is a known allocation method.
+func ValidLEGAllocationMethod(m string) bool {
+	return m == LEGAllocationProportional || m == LEGAllocationEqual
+}
+
+// LEGParticipantShare is one participant's figures in one interval. For a
+// consumer Local is the kWh received from the community and Grid what was
+// still drawn from the grid; for a producer Local is the kWh the community
+// consumed and Grid the surplus fed into the grid beyond it.
+type LEGParticipantShare struct {
+	ParticipantID int     `json:"participant_id"`
+	Measured      float64 `json:"measured_kwh"`
+	Local         float64 `json:"local_kwh"`
+	Grid          float64 `json:"grid_kwh"`
+}
+
+// LEGInterval is the community balance of one 15-minute interval.
+type LEGInterval struct {
+	Start        time.Time             `json:"start"`
+	Production   float64               `json:"production_kwh"`
+	Consumption  float64               `json:"consumption_kwh"`
+	Local        float64               `json:"local_kwh"`        // min(production, consumption)
+	GridFeedIn   float64               `json:"grid_feed_in_kwh"` // production not consumed locally
+	GridSupply   float64               `json:"grid_supply_kwh"`  // consumption not covered locally
+	Participants []LEGParticipantShare `json:"participants"`
+}
+
+// LEGBalance is the per-interval allocation of a community over a period.
+type LEGBalance struct {
+	Community models.LEGCommunity `json:"community"`
+	Start     time.Time           `json:"start"`
+	End       time.Time           `json:"end"`
+	Intervals []LEGInterval       `json:"intervals"`
+}
+
+// participantTotals sums the shares of the given participants over the
+// intervals in [start, end).
+func (b *LEGBalance) participantTotals(participantIDs map[int]bool, start, end time.Time) (measured, local float64) {
+	for _, iv := range b.Intervals {
+		if iv.Start.Before(start) || !iv.Start.Before(end) {
+			continue
+		}
+		for _, p := range iv.Participants {
+			if participantIDs[p.ParticipantID] {
+				measured += p.Measured
+				local += p.Local
+			}
+		}
+	}
+	return measured, local
+}
+
+// LoadLEGCommunity loads a community with its participants (and their meter
+// names).
+func LoadLEGCommunity(db *sql.DB, communityID int) (*models.LEGCommunity, error) {
+	var c models.LEGCommunity
+	err := db.QueryRow(`
+		SELECT id, name, dso_name, community_code, local_energy_price, producer_price,
+		       grid_fee_price, grid_fee_discount, allocation_method, is_active, notes, created_at, updated_at
+		FROM leg_communities WHERE id = ?
+	`, communityID).Scan(&c.ID, &c.Name, &c.DSOName, &c.CommunityCode, &c.LocalEnergyPrice, &c.ProducerPrice,
+		&c.GridFeePrice, &c.GridFeeDiscount, &c.AllocationMethod, &c.IsActive, &c.Notes, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, p.community_id, p.building_id, p.meter_id, COALESCE(m.name, ''), p.user_id,
+		       p.role, p.valid_from, p.valid_to, p.created_at, p.updated_at
+		FROM leg_participants p
+		LEFT JOIN meters m ON m.id = p.meter_id
+		WHERE p.community_id = ?
+		ORDER BY p.role, p.building_id, p.id
+	`, communityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.LEGParticipant
+		var userID sql.NullInt64
+		if err := rows.Scan(&p.ID, &p.CommunityID, &p.BuildingID, &p.MeterID, &p.MeterName, &userID,
+			&p.Role, &p.ValidFrom, &p.ValidTo, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			v := int(userID.Int64)
+			p.UserID = &v
+		}
+		c.Participants = append(c.Participants, p)
+	}
+	return &c, rows.Err()
+}
+
+// allocateLocal splits supply across demands. The result never exceeds a
+// demand, and sums to min(supply, total demand).
+func allocateLocal(demands []float64, supply float64, method string) []float64 {
+	out := make([]float64, len(demands))
+	total := 0.0
+	for _, d := range demands {
+		total += d
+	}
+	if supply <= 0 || total <= 0 {
+		return out
+	}
+	if supply >= total {
+		copy(out, demands)
+		return out
+	}
+	if method != LEGAllocationEqual {
+		for i, d := range demands {
+			out[i] = d / total * supply
+		}
+		return out
+	}
+
+	// Equal shares: hand out supply evenly among the consumers still short,
+	// repeating while a consumer's cap frees up supply for the rest.
+	remaining := supply
+	for round := 0; round <= len(demands) && remaining > 1e-12; round++ {
+		open := 0
+		for i, d := range demands {
+			if out[i] < d {
+				open++
+			}
+		}
+		if open == 0 {
+			break
+		}
+		share := remaining / float64(open)
+		for i, d := range demands {
+			if out[i] >= d {
+				continue
+			}
+			give := math.Min(share, d-out[i])
+			out[i] += give
+			remaining -= give
+		}
+	}
+	return out
+}
+
+// ComputeLEGBalance allocates a community's local production to its consumers
+// for every 15-minute interval in [start, end). Consumers are measured on
+// their apartment meter's consumption, producers on their solar meter's
+// export (or main register, see solarUsesMainRegister). A participant only
+// counts on the days of its validity. Each producer is credited with its share
+// of the production in proportion to what it fed in.
+func (bs *BillingService) ComputeLEGBalance(communityID int, start, end time.Time) (*LEGBalance, error) {
+	community, err := LoadLEGCommunity(bs.db, communityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load LEG community %d: %v", communityID, err)
+	}
+	balance := &LEGBalance{Community: *community, Start: start, End: end, Intervals: []LEGInterval{}}
+	if len(community.Participants) == 0 {
+		return balance, nil
+	}
+
+	byMeter := make(map[int][]models.LEGParticipant)
+	meterIDs := []int{}
+	mainRegister := make(map[int]bool)
+	for _, p := range community.Participants {
+		if _, seen := byMeter[p.MeterID]; !seen {
+			meterIDs = append(meterIDs, p.MeterID)
+			if p.Role == LEGRoleProducer {
+				mainRegister[p.MeterID] = bs.solarUsesMainRegister(p.MeterID)
+			}
+		}
+		byMeter[p.MeterID] = append(byMeter[p.MeterID], p)
+	}
+
+	args := make([]interface{}, 0, len(meterIDs)+2)
+	for _, id := range meterIDs {
+		args = append(args, id)
+	}
+	args = append(args, start, end)
+	rows, err := bs.db.Query(`
+		SELECT meter_id, reading_time, consumption_kwh, consumption_export
+		FROM meter_readings
+		WHERE meter_id IN (`+buildPlaceholders(len(meterIDs))+`)
+		AND reading_time >= ? AND reading_time < ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %v", err)
+	}
+	defer rows.Close()
+
+	type measurement struct {
+		participant models.LEGParticipant
+		kwh         float64
+	}
+	intervals := make(map[time.Time]map[int]*measurement)
+	for rows.Next() {
+		var meterID int
+		var ts time.Time
+		var kwh, export float64
+		if err := rows.Scan(&meterID, &ts, &kwh, &export); err != nil {
+			return nil, err
+		}
+		day := ts.Format("2006-01-02")
+		for _, p := range byMeter[meterID] {
+			if day < p.ValidFrom || (p.ValidTo != "" && day > p.ValidTo) {
+				continue
+			}
+			v := kwh
+			if p.Role == LEGRoleProducer && !mainRegister[meterID] {
+				v = export
+			}
+			at := floorTo15min(ts)
+			if intervals[at] == nil {
+				intervals[at] = make(map[int]*measurement)
+			}
+			if intervals[at][p.ID] == nil {
+				intervals[at][p.ID] = &measurement{participant: p}
+			}
+			intervals[at][p.ID].kwh += v
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	timestamps := make([]time.Time, 0, len(intervals))
+	for ts := range intervals {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+
+	for _, ts := range timestamps {
+		// Participants in a fixed order so the allocation is reproducible.
+		ms := make([]*measurement, 0, len(intervals[ts]))
+		for _, m := range intervals[ts] {
+			if m.kwh > 0 {
+				ms = append(ms, m)
+			}
+		}
+		sort.Slice(ms, func(i, j int) bool { return ms[i].participant.ID < ms[j].participant.ID })
+
+		iv := LEGInterval{Start: ts}
+		var consumers, producers []*measurement
+		var demands []float64
+		for _, m := range ms {
+			if m.participant.Role == LEGRoleProducer {
+				producers = append(producers, m)
+				iv.Production += m.kwh
+			} else {
+				consumers = append(consumers, m)
+				demands = append(demands, m.kwh)
+				iv.Consumption += m.kwh
+			}
+		}
+		iv.Local = math.Min(iv.Production, iv.Consumption)
+		iv.GridFeedIn = iv.Production - iv.Local
+		iv.GridSupply = iv.Consumption - iv.Local
+
+		for i, local := range allocateLocal(demands, iv.Production, community.AllocationMethod) {
+			m := consumers[i]
+			iv.Participants = append(iv.Participants, LEGParticipantShare{
+				ParticipantID: m.participant.ID, Measured: m.kwh, Local: local, Grid: m.kwh - local,
+			})
+		}
+		for _, m := range producers {
+			local := m.kwh / iv.Production * iv.Local
+			iv.Participants = append(iv.Participants, LEGParticipantShare{
+				ParticipantID: m.participant.ID, Measured: m.kwh, Local: local, Grid: m.kwh - local,
+			})
+		}
+		balance.Intervals = append(balance.Intervals, iv)
+	}
+
+	log.Printf("[LEG] Community %d: %d intervals, %d participants, %s to %s",
+		communityID, len(balance.Intervals), len(community.Participants), start.Format("2006-01-02"), end.Format("2006-01-02"))
+	return balance, nil
+}
+
+// legParty is one user's participation in a community in one role: all their
+// metering points of that role in a building.
+type legParty struct {
+	userID         int
+	buildingID     int
+	participantIDs map[int]bool
+	meterNames     []string
+}
+
+// legParties groups the community's participants of one role by user and
+// building, optionally restricted to userIDs. Participants without a user
+// cannot be billed and are left out.
+func legParties(c *models.LEGCommunity, role string, userIDs []int) []*legParty {
+	wanted := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	var parties []*legParty
+	index := make(map[[2]int]*legParty)
+	for _, p := range c.Participants {
+		if p.Role != role {
+			continue
+		}
+		if p.UserID == nil {
+			log.Printf("[LEG] Participant %d (meter %d) has no user and is not billed", p.ID, p.MeterID)
+			continue
+		}
+		if len(wanted) > 0 && !wanted[*p.UserID] {
+			continue
+		}
+		key := [2]int{*p.UserID, p.BuildingID}
+		party := index[key]
+		if party == nil {
+			party = &legParty{userID: *p.UserID, buildingID: p.BuildingID, participantIDs: make(map[int]bool)}
+			index[key] = party
+			parties = append(parties, party)
+		}
+		party.participantIDs[p.ID] = true
+		party.meterNames = append(party.meterNames, p.MeterName)
+	}
+	return parties
+}
+
+// parseBillingPeriod parses an inclusive YYYY-MM-DD period into the exclusive
+// [start, end) window billing works on.
+func parseBillingPeriod(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %v", err)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date must not be before start date")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// GenerateLEGInvoices bills the consumers of a LEG community for
+// [startDate, endDate]: the locally allocated kWh at the community's local
+// energy price plus the grid usage fee reduced by the community discount, and
+// the remaining grid kWh at the building's normal grid price (split into its
+// price components if it has any).
+func (bs *BillingService) GenerateLEGInvoices(communityID int, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	start, end, err := parseBillingPeriod(startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	balance, err := bs.ComputeLEGBalance(communityID, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := balance.Community
+	log.Printf("=== LEG INVOICES: community %d (%s), period %s to %s ===", c.ID, c.Name, startDate, endDate)
+
+	invoices := []models.Invoice{}
+	skipped := []SkippedBill{}
+	for _, party := range legParties(&c, LEGRoleConsumer, userIDs) {
+		user := loadInvoiceUser(bs.db, party.userID)
+		if user == nil {
+			continue
+		}
+		skip := func(err error) {
+			skipped = append(skipped, SkippedBill{
+				UserID: party.userID, UserName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+				BuildingID: party.buildingID, Reason: err.Error(),
+			})
+		}
+		invoice, err := bs.generateLEGInvoice(balance, party, user, start, end)
+		if err != nil {
+			log.Printf("ERROR: Failed to generate LEG invoice for user %d: %v", party.userID, err)
+			skip(err)
+			continue
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, skipped, nil
+}
+
+func (bs *BillingService) generateLEGInvoice(balance *LEGBalance, party *legParty, user *models.User, start, end time.Time) (*models.Invoice, error) {
+	c := balance.Community
+	segments, err := bs.loadPriceSegments(party.buildingID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	primary := segments[0].Settings
+	multiSeg := len(segments) > 1
+	tr := GetTranslations(user.Language)
+	discountedFee := c.GridFeePrice * (1 - c.GridFeeDiscount/100)
+
+	items := []models.InvoiceItem{
+		{Description: fmt.Sprintf("⚡ %s: %s", tr.LEGCommunity, c.Name), ItemType: "leg_notice"},
+		{Description: fmt.Sprintf("%s: %s", tr.ApartmentMeter, strings.Join(party.meterNames, ", ")), ItemType: "meter_info"},
+		{ItemType: "separator"},
+	}
+	totalAmount, totalConsumption := 0.0, 0.0
+
+	for _, seg := range segments {
+		suffix := segmentSuffix(seg, multiSeg)
+		s := seg.Settings
+		measured, local := balance.participantTotals(party.participantIDs, seg.Start, seg.End)
+		grid := math.Max(measured-local, 0)
+		totalConsumption += measured
+
+		items = append(items, models.InvoiceItem{
+			Description: fmt.Sprintf("%s%s: %.3f kWh", tr.Consumption, suffix, measured),
+			Quantity:    measured,
+			ItemType:    "leg_consumption",
+		})
+		if local > 0 {
+			cost := local * c.LocalEnergyPrice
+			totalAmount += cost
+			items = append(items, models.InvoiceItem{
+				Description: fmt.Sprintf("  ├─ %s%s: %.3f kWh × %.3f %s/kWh", tr.LocalEnergy, suffix, local, c.LocalEnergyPrice, s.Currency),
+				Quantity:    local,
+				UnitPrice:   c.LocalEnergyPrice,
+				TotalPrice:  cost,
+				ItemType:    "leg_local_energy",
+			})
+			if c.GridFeePrice > 0 {
+				feeCost := local * discountedFee
+				totalAmount += feeCost
+				items = append(items, models.InvoiceItem{
+					Description: fmt.Sprintf("  ├─ %s (−%.0f%%)%s: %.3f kWh × %.3f %s/kWh",
+						tr.LocalGridFee, c.GridFeeDiscount, suffix, local, discountedFee, s.Currency),
+					Quantity:   local,
+					UnitPrice:  discountedFee,
+					TotalPrice: feeCost,
+					ItemType:   "leg_grid_fee",
+				})
+			}
+			log.Printf("  LEG local%s: %.3f kWh × (%.3f + %.3f) %s", suffix, local, c.LocalEnergyPrice, discountedFee, s.Currency)
+		}
+		if grid <= 0 {
+			continue
+		}
+		components, err := bs.priceComponentsFor(party.buildingID, seg.Start, seg.End)
+		if err != nil {
+			return nil, err
+		}
+		if components != nil {
+			totalAmount += appendPriceComponentItems(&items, components, grid, s, suffix, "  └─ ", tr)
+			continue
+		}
+		cost := grid * s.NormalPowerPrice
+		totalAmount += cost
+		items = append(items, models.InvoiceItem{
+			Description: fmt.Sprintf("  └─ %s%s: %.3f kWh × %.3f %s/kWh", tr.NormalPowerGrid, suffix, grid, s.NormalPowerPrice, s.Currency),
+			Quantity:    grid,
+			UnitPrice:   s.NormalPowerPrice,
+			TotalPrice:  cost,
+			ItemType:    "normal_power",
+		})
+		log.Printf("  LEG grid%s: %.3f kWh × %.3f = %.3f %s", suffix, grid, s.NormalPowerPrice, cost, s.Currency)
+	}
+
+	bs.assignItemVAT(items, primary)
+	netAmount, vatAmount, grossAmount := vatBreakdownItems(totalAmount, items, primary)
+	if grossAmount <= zeroBillEpsilon {
+		return nil, fmt.Errorf("%s 0.00 invoice not created: no LEG consumption found for this period (%.3f kWh measured)",
+			primary.Currency, totalConsumption)
+	}
+
+	periodStart, periodEnd := start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")
+	invoiceNumber := fmt.Sprintf("LEG-%d-%d-%d-%s", start.Year(), c.ID, party.userID, time.Now().Format("20060102150405"))
+	invoiceID, invoiceNumber, err := bs.insertInvoiceWithItems(invoiceNumber, party.userID, party.buildingID,
+		periodStart, periodEnd, grossAmount, netAmount, vatAmount, primary.VATRate, primary.VATIncluded, primary.Currency,
+		false, items)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Invoice{
+		ID:            int(invoiceID),
+		InvoiceNumber: invoiceNumber,
+		UserID:        party.userID,
+		BuildingID:    party.buildingID,
+		PeriodStart:   periodStart,
+		PeriodEnd:     periodEnd,
+		TotalAmount:   grossAmount,
+		NetAmount:     netAmount,
+		VATAmount:     vatAmount,
+		VATRate:       primary.VATRate,
+		VATIncluded:   primary.VATIncluded,
+		Currency:      primary.Currency,
+		Status:        bs.storedStatus(),
+		DocumentType:  DocumentTypeInvoice,
+		Items:         items,
+		GeneratedAt:   time.Now(),
+	}, nil
+}
+
+// GenerateLEGProducerCredits issues a credit statement to every producer of a
+// LEG community for [startDate, endDate]: the production the community
+// consumed at the community's producer price. The surplus fed into the grid
+// beyond the community is listed for information only; it is remunerated by
+// the grid operator, not the community. Statements carry no VAT and are
+// stored with negative amounts, like a credit note.
+func (bs *BillingService) GenerateLEGProducerCredits(communityID int, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	start, end, err := parseBillingPeriod(startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	balance, err := bs.ComputeLEGBalance(communityID, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := balance.Community
+	log.Printf("=== LEG PRODUCER CREDITS: community %d (%s), period %s to %s ===", c.ID, c.Name, startDate, endDate)
+
+	periodStart, periodEnd := start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")
+	invoices := []models.Invoice{}
+	skipped := []SkippedBill{}
+	for _, party := range legParties(&c, LEGRoleProducer, userIDs) {
+		user := loadInvoiceUser(bs.db, party.userID)
+		if user == nil {
+			continue
+		}
+		userName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+		measured, local := balance.participantTotals(party.participantIDs, start, end)
+		credit := math.Round(local*c.ProducerPrice*100) / 100
+		if credit <= zeroBillEpsilon {
+			skipped = append(skipped, SkippedBill{
+				UserID: party.userID, UserName: userName, BuildingID: party.buildingID,
+				Reason: fmt.Sprintf("no production consumed by the community (%.3f kWh produced)", measured),
+			})
+			continue
+		}
+		currency := "CHF"
+		if segments, err := bs.loadPriceSegments(party.buildingID, start, end); err == nil {
+			currency = segments[0].Settings.Currency
+		}
+
+		tr := GetTranslations(user.Language)
+		items := []models.InvoiceItem{
+			{Description: fmt.Sprintf("⚡ %s: %s", tr.LEGCommunity, c.Name), ItemType: "leg_notice"},
+			{Description: fmt.Sprintf("%s: %s", tr.ProductionMeter, strings.Join(party.meterNames, ", ")), ItemType: "meter_info"},
+			{ItemType: "separator"},
+			{Description: fmt.Sprintf("%s: %.3f kWh", tr.LocalProduction, measured), Quantity: measured, ItemType: "leg_production"},
+			{
+				Description: fmt.Sprintf("  ├─ %s: %.3f kWh × %.3f %s/kWh", tr.LocalProductionSold, local, c.ProducerPrice, currency),
+				Quantity:    negateAmount(local),
+				UnitPrice:   c.ProducerPrice,
+				TotalPrice:  negateAmount(credit),
+				ItemType:    "leg_producer_credit",
+			},
+			{Description: fmt.Sprintf("  └─ %s: %.3f kWh", tr.GridFeedIn, math.Max(measured-local, 0)), ItemType: "leg_info"},
+		}
+
+		invoiceNumber := fmt.Sprintf("LEGC-%d-%d-%d-%s", start.Year(), c.ID, party.userID, time.Now().Format("20060102150405"))
+		invoiceID, invoiceNumber, err := bs.insertDocumentWithItems(DocumentTypeProducerCredit, invoiceNumber, party.userID, party.buildingID,
+			periodStart, periodEnd, -credit, -credit, 0, 0, false, currency, false, items)
+		if err != nil {
+			log.Printf("ERROR: Failed to issue LEG producer credit for user %d: %v", party.userID, err)
+			skipped = append(skipped, SkippedBill{UserID: party.userID, UserName: userName, BuildingID: party.buildingID, Reason: err.Error()})
+			continue
+		}
+		invoices = append(invoices, models.Invoice{
+			ID:            int(invoiceID),
+			InvoiceNumber: invoiceNumber,
+			UserID:        party.userID,
+			BuildingID:    party.buildingID,
+			PeriodStart:   periodStart,
+			PeriodEnd:     periodEnd,
+			TotalAmount:   -credit,
+			NetAmount:     -credit,
+			Currency:      currency,
+			Status:        bs.storedStatus(),
+			DocumentType:  DocumentTypeProducerCredit,
+			Items:         items,
+			GeneratedAt:   time.Now(),
+		})
+		log.Printf("  Producer credit %s: %s %.2f (%.3f kWh) for %s", invoiceNumber, currency, credit, local, userName)
+	}
+	return invoices, skipped, nil
+}
+
+// PreviewLEGInvoices is GenerateLEGInvoices without writing anything.
+func (bs *BillingService) PreviewLEGInvoices(communityID int, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	return dry.GenerateLEGInvoices(communityID, userIDs, startDate, endDate)
+}
+
+// PreviewLEGProducerCredits is GenerateLEGProducerCredits without writing
+// anything.
+func (bs *BillingService) PreviewLEGProducerCredits(communityID int, userIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	return dry.GenerateLEGProducerCredits(communityID, userIDs, startDate, endDate)
+}
+
+// LEGBalanceRows renders a balance as CSV rows for the grid operator (DSO) to
+// reconcile against its own metering data. The "community" view has one row
+// per interval with the community totals; the "participants" view one row per
+// interval and metering point.
+func LEGBalanceRows(b *LEGBalance, view string) [][]string {
+	f := func(v float64) string { return fmt.Sprintf("%.4f", v) }
+	ts := func(t time.Time) string { return t.Format(time.RFC3339) }
+
+	if view == "participants" {
+		participants := make(map[int]models.LEGParticipant, len(b.Community.Participants))
+		for _, p := range b.Community.Participants {
+			participants[p.ID] = p
+		}
+		rows := [][]string{{"interval_start", "interval_end", "community_code", "participant_id", "meter_id", "meter_name",
+			"building_id", "role", "measured_kwh", "local_kwh", "grid_kwh"}}
+		for _, iv := range b.Intervals {
+			for _, s := range iv.Participants {
+				p := participants[s.ParticipantID]
+				rows = append(rows, []string{ts(iv.Start), ts(iv.Start.Add(15 * time.Minute)), b.Community.CommunityCode,
+					fmt.Sprint(p.ID), fmt.Sprint(p.MeterID), p.MeterName, fmt.Sprint(p.BuildingID), p.Role,
+					f(s.Measured), f(s.Local), f(s.Grid)})
+			}
+		}
+		return rows
+	}
+
+	rows := [][]string{{"interval_start", "interval_end", "community_code", "production_kwh", "consumption_kwh",
+		"local_kwh", "grid_feed_in_kwh", "grid_supply_kwh"}}
+	for _, iv := range b.Intervals {
+		rows = append(rows, []string{ts(iv.Start), ts(iv.Start.Add(15 * time.Minute)), b.Community.CommunityCode,
+			f(iv.Production), f(iv.Consumption), f(iv.Local), f(iv.GridFeedIn), f(iv.GridSupply)})
+	}
+	return rows
+}
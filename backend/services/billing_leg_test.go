@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLEGCommunity(t *testing.T) {
+	if got := allocateLocal([]float64{0.5, 4, 4}, 3, LEGAllocationEqual); !almostEqual(got[0], 0.5) || !almostEqual(got[1], 1.25) || !almostEqual(got[2], 1.25) {
+		t.Errorf("equal allocation: %v", got)
+	}
+
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertBuilding(t, db, 2, "B")
+	insertPricing(t, db, 1, "2026-01-01", "", 0.30)
+	insertPricing(t, db, 2, "2026-01-01", "", 0.30)
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES
+		(10,'Anna','Muster','a@b.c',1), (11,'Hans','Meier','h@b.c',2), (12,'Paul','Solar','p@b.c',2)`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 1, "Wohnung A", "apartment_meter", 1, 10)
+	insertZEVMeter(t, db, 2, "Wohnung B", "apartment_meter", 2, 11)
+	insertZEVMeter(t, db, 3, "PV Dach", "solar_meter", 2, 12)
+	if _, err := db.Exec(`INSERT INTO leg_communities (id, name, community_code, local_energy_price, producer_price, grid_fee_price, grid_fee_discount)
+		VALUES (1, 'Quartier', 'LEG-1', 0.15, 0.12, 0.10, 40)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO leg_participants (community_id, building_id, meter_id, user_id, role, valid_from) VALUES
+		(1, 1, 1, 10, 'consumer', '2026-01-01'), (1, 2, 2, 11, 'consumer', '2026-01-01'), (1, 2, 3, 12, 'producer', '2026-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	insertZEVReading(t, db, 1, at, 2, 0)
+	insertZEVReading(t, db, 2, at, 4, 0)
+	insertZEVReading(t, db, 3, at, 0, 3)
+	insertZEVReading(t, db, 1, at.Add(15*time.Minute), 1, 0)
+
+	bs := NewBillingService(db)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	balance, err := bs.ComputeLEGBalance(1, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balance.Intervals) != 2 {
+		t.Fatalf("intervals: %d", len(balance.Intervals))
+	}
+	// 3 kWh produced for 6 kWh consumed: shared 1:2 by consumption.
+	iv := balance.Intervals[0]
+	if !almostEqual(iv.Local, 3) || !almostEqual(iv.GridSupply, 3) || !almostEqual(iv.Participants[0].Local, 1) || !almostEqual(iv.Participants[1].Local, 2) {
+		t.Fatalf("unexpected interval: %+v", iv)
+	}
+	if rows := LEGBalanceRows(balance, "participants"); len(rows) != 5 || rows[1][2] != "LEG-1" {
+		t.Errorf("participant rows: %v", rows)
+	}
+
+	invoices, skipped, err := bs.GenerateLEGInvoices(1, []int{10}, "2026-01-01", "2026-01-31")
+	if err != nil || len(invoices) != 1 || len(skipped) != 0 {
+		t.Fatalf("LEG invoices: %v %v %v", invoices, skipped, err)
+	}
+	// 1 kWh local × (0.15 + 0.10 × 60%) + 2 kWh grid × 0.30, plus 8.1% VAT.
+	if inv := invoices[0]; !almostEqual(inv.NetAmount, 0.81) || !almostEqual(inv.TotalAmount, 0.81*1.081) {
+		t.Errorf("net %.4f total %.4f", inv.NetAmount, inv.TotalAmount)
+	}
+
+	credits, _, err := bs.GenerateLEGProducerCredits(1, nil, "2026-01-01", "2026-01-31")
+	if err != nil || len(credits) != 1 {
+		t.Fatalf("producer credits: %v %v", credits, err)
+	}
+	if c := credits[0]; c.DocumentType != DocumentTypeProducerCredit || !almostEqual(c.TotalAmount, -0.36) || c.UserID != 12 {
+		t.Errorf("unexpected credit: %+v", c)
+	}
+	if _, err := bs.CancelInvoice(credits[0].ID, ""); err == nil {
+		t.Error("producer credit was cancelled")
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// InvoiceStatusPreview marks a dry-run invoice that was never stored.
+const InvoiceStatusPreview = "preview"
+
+// InvoicePreview is one dry-run invoice with its rendered document.
+type InvoicePreview struct {
+	Invoice   models.Invoice `json:"invoice"`
+	HTML      string         `json:"html"`
+	PDFBase64 string         `json:"pdf_base64,omitempty"`
+	PDFError  string         `json:"pdf_error,omitempty"`
+}
+
+// previewNumber replaces the would-be invoice number so a preview can never be
+// mistaken for (or collide with) a real one.
+func previewNumber(invoiceNumber string) string {
+	if i := strings.Index(invoiceNumber, "-"); i >= 0 {
+		invoiceNumber = invoiceNumber[i+1:]
+	}
+	return "PREVIEW-" + invoiceNumber
+}
+
+// PreviewBillsWithOptions runs the exact generation path of
+// GenerateBillsWithOptions without writing anything: no invoice rows, no line
+// items, no invoice numbers. The returned invoices have ID 0, status "preview"
+// and their tenant attached so they can be rendered.
+func (bs *BillingService) PreviewBillsWithOptions(buildingIDs, userIDs []int, startDate, endDate string, isVZEV bool, customItemIDs []int, scope BillingScope) ([]models.Invoice, []SkippedBill, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	invoices, skipped, err := dry.GenerateBillsWithOptions(buildingIDs, userIDs, startDate, endDate, isVZEV, customItemIDs, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	bs.markPreviews(invoices)
+	return invoices, skipped, nil
+}
+
+// markPreviews turns dry-run results into previews: no id, a PREVIEW number,
+// status "preview" and the tenant attached for rendering.
+func (bs *BillingService) markPreviews(invoices []models.Invoice) {
+	for i := range invoices {
+		invoices[i].ID = 0
+		invoices[i].InvoiceNumber = previewNumber(invoices[i].InvoiceNumber)
+		invoices[i].Status = InvoiceStatusPreview
+		if invoices[i].DocumentType == "" {
+			invoices[i].DocumentType = DocumentTypeInvoice
+		}
+		if invoices[i].User == nil {
+			invoices[i].User = loadInvoiceUser(bs.db, invoices[i].UserID)
+		}
+	}
+}
+
+// RenderInvoicePreviews renders dry-run invoices to HTML (and PDF when
+// withPDF is set). A failed PDF conversion is reported per invoice instead of
+// failing the whole preview.
+func RenderInvoicePreviews(pg *PDFGenerator, invoices []models.Invoice, sender SenderInfo, banking BankingInfo, withPDF bool) []InvoicePreview {
+	previews := make([]InvoicePreview, 0, len(invoices))
+	for _, inv := range invoices {
+		p := InvoicePreview{Invoice: inv}
+		html, pdf, err := pg.RenderInvoicePreview(InvoiceToMap(inv), sender, banking, withPDF)
+		p.HTML = html
+		if err != nil {
+			p.PDFError = err.Error()
+		} else if len(pdf) > 0 {
+			p.PDFBase64 = base64.StdEncoding.EncodeToString(pdf)
+		}
+		previews = append(previews, p)
+	}
+	return previews
+}
+
+// loadInvoiceUser loads the tenant fields the invoice document needs.
+func loadInvoiceUser(db *sql.DB, userID int) *models.User {
+	var user models.User
+	err := db.QueryRow(`
+		SELECT id, first_name, last_name, COALESCE(email, ''), COALESCE(phone, ''),
+		       COALESCE(address_street, ''), COALESCE(address_city, ''),
+		       COALESCE(address_zip, ''), COALESCE(address_country, ''),
+		       COALESCE(language, 'de'), is_active
+		FROM users WHERE id = ?
+	`, userID).Scan(
+		&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Phone,
+		&user.AddressStreet, &user.AddressCity, &user.AddressZip, &user.AddressCountry,
+		&user.Language, &user.IsActive,
+	)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// previewSnapshot lists the invoices with their billing scope, the line item
+// count and the number scheme counters, everything a real run would change.
+func previewSnapshot(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var b strings.Builder
+	queries := []string{
+		`SELECT id, invoice_number, status, COALESCE(billing_mode, ''), COALESCE(bill_content, ''),
+			COALESCE(charger_id, 0), COALESCE(custom_item_ids, ''), total_amount FROM invoices ORDER BY id`,
+		`SELECT COUNT(*) FROM invoice_items`,
+		`SELECT scheme_id, building_id, period, last_value FROM invoice_number_counters ORDER BY scheme_id, building_id, period`,
+	}
+	for _, q := range queries {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintln(&b, values...)
+		}
+		rows.Close()
+	}
+	return b.String()
+}
+
+func TestPreviewBillsWritesNothing(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertPricing(t, db, 1, "2025-12-01", "", 0.25)
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, is_active) VALUES (10,'Anna','Muster','a@b.c',1,1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_schemes (id, name, prefix, pattern, counter_digits, reset_policy, per_building)
+		VALUES (1, 'Sonnenhof', 'ZEV-', '{PREFIX}{YYYY}-{BUILDING}-{COUNTER}', 4, 'yearly', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_scheme_buildings (building_id, scheme_id, building_code) VALUES (1, 1, 'SH')`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 2, "Wohnung A", "apartment_meter", 1, 10)
+	for d := 1; d <= 31; d++ {
+		insertZEVReading(t, db, 2, time.Date(2025, 12, d, 12, 0, 0, 0, time.Local), 10, 0)
+		insertZEVReading(t, db, 2, time.Date(2026, 1, d, 12, 0, 0, 0, time.Local), 10, 0)
+	}
+
+	bs := NewBillingService(db)
+	issued, _, err := bs.GenerateBillsWithOptions([]int{1}, []int{10}, "2025-12-01", "2025-12-31", false, nil, BillingScope{})
+	if err != nil || len(issued) != 1 {
+		t.Fatalf("generate: %d, %v", len(issued), err)
+	}
+	before := previewSnapshot(t, db)
+
+	previews, skipped, err := bs.PreviewBillsWithOptions([]int{1}, []int{10}, "2026-01-01", "2026-01-31", false, nil,
+		BillingScope{Content: BillContentMeters})
+	if err != nil || len(previews) != 1 || len(skipped) != 0 {
+		t.Fatalf("preview: %d, %d skipped, %v", len(previews), len(skipped), err)
+	}
+	p := previews[0]
+	if p.ID != 0 || p.Status != InvoiceStatusPreview || !strings.HasPrefix(p.InvoiceNumber, "PREVIEW-") ||
+		p.User == nil || len(p.Items) == 0 || p.TotalAmount <= 0 {
+		t.Errorf("preview %+v", p)
+	}
+	if after := previewSnapshot(t, db); after != before {
+		t.Errorf("preview changed the database:\nbefore\n%s\nafter\n%s", before, after)
+	}
+	if got := previewNumber("INV-2026-1-10-20260131120000"); got != "PREVIEW-2026-1-10-20260131120000" {
+		t.Errorf("previewNumber = %s", got)
+	}
+}
@@ -0,0 +1,653 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// DocumentTypeSupplementary is the supplementary invoice (Nachtragsrechnung)
+// a recalculation issues when an invoice was billed too low. It is payable
+// like an invoice but never counts as the replacement of a cancelled one.
+const DocumentTypeSupplementary = "supplementary"
+
+// Recalculation statuses.
+const (
+	RecalculationPending   = "pending"
+	RecalculationCorrected = "corrected"
+	RecalculationDismissed = "dismissed"
+)
+
+// recalcQuantityEpsilon is the smallest quantity change (kWh, m³, ...) a
+// recalculation reports.
+const recalcQuantityEpsilon = 0.0005
+
+// recordBillingScope stores the scope invoices were generated with, so a
+// recalculation can bill their period again the same way.
+func (bs *BillingService) recordBillingScope(invoices []models.Invoice, customItemIDs []int, scope BillingScope) {
+	mode, content := scope.Mode, scope.Content
+	if mode == "" {
+		mode = BillingModeApartments
+	}
+	if content == "" {
+		content = BillContentBoth
+	}
+	ids := make([]string, len(customItemIDs))
+	for i, id := range customItemIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	for _, inv := range invoices {
+		if _, err := bs.db.Exec(`
+			UPDATE invoices SET billing_mode = ?, bill_content = ?, charger_id = ?, custom_item_ids = ?
+			WHERE id = ?
+		`, mode, content, scope.ChargerID, strings.Join(ids, ","), inv.ID); err != nil {
+			log.Printf("WARNING: Failed to record billing scope of invoice %d: %v", inv.ID, err)
+		}
+	}
+}
+
+// recalcLineKey groups invoice lines for comparison: the item type and the
+// description up to the first colon, which names the line without its
+// quantities and prices ("Solar Power", "Cold water", a custom item's
+// category). Lines sharing a key are compared as their sum.
+func recalcLineKey(item models.InvoiceItem) (key, label string) {
+	label = item.Description
+	if i := strings.Index(label, ":"); i >= 0 {
+		label = label[:i]
+	}
+	label = strings.TrimSpace(label)
+	return item.ItemType + "\x00" + label, label
+}
+
+// loadDocumentItems returns the line items of the given documents in order.
+func loadDocumentItems(db *sql.DB, ids []int) ([]models.InvoiceItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(`
+		SELECT invoice_id, description, quantity, unit_price, total_price, item_type, vat_code, vat_rate
+		FROM invoice_items WHERE invoice_id IN (`+buildPlaceholders(len(ids))+`)
+		ORDER BY invoice_id, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []models.InvoiceItem
+	for rows.Next() {
+		var item models.InvoiceItem
+		if err := rows.Scan(&item.InvoiceID, &item.Description, &item.Quantity, &item.UnitPrice, &item.TotalPrice, &item.ItemType,
+			&item.VATCode, &item.VATRate); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// diffInvoiceLines compares billed and recalculated line items and returns
+// the lines whose quantity or amount changed, in invoice order.
+func diffInvoiceLines(billed, recalculated []models.InvoiceItem) []models.RecalculationLine {
+	lines := map[string]*models.RecalculationLine{}
+	var order []string
+	line := func(item models.InvoiceItem) *models.RecalculationLine {
+		key, label := recalcLineKey(item)
+		l, ok := lines[key]
+		if !ok {
+			l = &models.RecalculationLine{ItemType: item.ItemType, Label: label}
+			lines[key] = l
+			order = append(order, key)
+		}
+		return l
+	}
+	for _, item := range recalculated {
+		if item.Quantity == 0 && item.TotalPrice == 0 {
+			continue
+		}
+		l := line(item)
+		l.NewQuantity += item.Quantity
+		l.NewAmount += item.TotalPrice
+		if item.UnitPrice != 0 {
+			l.UnitPrice = item.UnitPrice
+		}
+	}
+	for _, item := range billed {
+		if item.Quantity == 0 && item.TotalPrice == 0 {
+			continue
+		}
+		l := line(item)
+		l.BilledQuantity += item.Quantity
+		l.BilledAmount += item.TotalPrice
+		if l.UnitPrice == 0 {
+			l.UnitPrice = item.UnitPrice
+		}
+	}
+
+	diff := []models.RecalculationLine{}
+	for _, key := range order {
+		l := lines[key]
+		l.QuantityChange = l.NewQuantity - l.BilledQuantity
+		l.AmountDifference = l.NewAmount - l.BilledAmount
+		if math.Abs(l.QuantityChange) >= recalcQuantityEpsilon || math.Abs(l.AmountDifference) >= zeroBillEpsilon {
+			diff = append(diff, *l)
+		}
+	}
+	return diff
+}
+
+// recalcTarget is an issued invoice under recalculation.
+type recalcTarget struct {
+	id, userID, buildingID int
+	number                 string
+	periodStart, periodEnd string
+	total, net, vat        float64
+	isVZEV                 bool
+	mode, content          sql.NullString
+	chargerID              sql.NullInt64
+	customItemIDs          sql.NullString
+}
+
+// billingScope returns the scope the invoice was generated with. Invoices
+// from before the scope was recorded are inferred from their lines: charging
+// without any meter consumption means a chargers-only bill, and custom items
+// are found again by their description.
+func (bs *BillingService) billingScope(t recalcTarget, items []models.InvoiceItem) (BillingScope, []int) {
+	if t.mode.Valid {
+		scope := BillingScope{Mode: t.mode.String, Content: t.content.String}
+		if t.chargerID.Valid {
+			id := int(t.chargerID.Int64)
+			scope.ChargerID = &id
+		}
+		return scope, parseIDList(t.customItemIDs.String)
+	}
+
+	hasMeters, hasCharging := false, false
+	var customDescriptions []string
+	for _, item := range items {
+		switch {
+		case item.ItemType == "meter_reading_compact" || item.ItemType == "normal_power" || item.ItemType == "solar_power":
+			hasMeters = true
+		case strings.HasPrefix(item.ItemType, "car_charging") || item.ItemType == "charging_session_compact":
+			hasCharging = true
+		case item.ItemType == "custom_item":
+			customDescriptions = append(customDescriptions, item.Description)
+		}
+	}
+	scope := BillingScope{}
+	if hasCharging && !hasMeters {
+		scope.Content = BillContentChargers
+	}
+
+	customIDs := []int{}
+	if len(customDescriptions) > 0 {
+		rows, err := bs.db.Query(`SELECT id, description FROM custom_line_items WHERE building_id = ?`, t.buildingID)
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var id int
+				var description string
+				if rows.Scan(&id, &description) != nil {
+					continue
+				}
+				for _, d := range customDescriptions {
+					if strings.Contains(d, ": "+description) {
+						customIDs = append(customIDs, id)
+						break
+					}
+				}
+			}
+		}
+	}
+	return scope, customIDs
+}
+
+// RecalculateInvoices bills the periods of issued invoices again and stores
+// a pending recalculation for each invoice whose amount changed — or whose
+// period can no longer be billed — with the per-line diff. buildingIDs
+// limits the run (nil means all buildings); invoices are selected when their
+// period overlaps [startDate, endDate]. LEG community invoices are left to
+// the community billing run. An invoice that matches its recalculation again
+// drops its pending entry. Returns the pending recalculations of the run.
+func (bs *BillingService) RecalculateInvoices(buildingIDs []int, startDate, endDate string) ([]models.InvoiceRecalculation, error) {
+	query := `
+		SELECT i.id, i.invoice_number, i.user_id, i.building_id, i.period_start, i.period_end,
+		       i.total_amount, COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0), COALESCE(i.is_vzev, 0),
+		       i.billing_mode, i.bill_content, i.charger_id, i.custom_item_ids
+		FROM invoices i
+		WHERE COALESCE(i.document_type, 'invoice') = ? AND i.status = ?
+		  AND date(i.period_start) <= ? AND date(i.period_end) >= ?
+		  AND NOT EXISTS (SELECT 1 FROM invoice_items it WHERE it.invoice_id = i.id AND it.item_type LIKE 'leg\_%' ESCAPE '\')`
+	args := []interface{}{DocumentTypeInvoice, InvoiceStatusIssued, endDate, startDate}
+	if len(buildingIDs) > 0 {
+		query += ` AND i.building_id IN (` + buildPlaceholders(len(buildingIDs)) + `)`
+		for _, id := range buildingIDs {
+			args = append(args, id)
+		}
+	}
+	query += ` ORDER BY i.building_id, i.period_start, i.id`
+
+	rows, err := bs.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoices: %v", err)
+	}
+	var targets []recalcTarget
+	for rows.Next() {
+		var t recalcTarget
+		if err := rows.Scan(&t.id, &t.number, &t.userID, &t.buildingID, &t.periodStart, &t.periodEnd,
+			&t.total, &t.net, &t.vat, &t.isVZEV,
+			&t.mode, &t.content, &t.chargerID, &t.customItemIDs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.periodStart, t.periodEnd = storedDay(t.periodStart), storedDay(t.periodEnd)
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	log.Printf("=== RECALCULATION: %d issued invoice(s) overlapping %s to %s ===", len(targets), startDate, endDate)
+	dry := &BillingService{db: bs.db, dryRun: true}
+	var pendingIDs []int
+	for _, t := range targets {
+		rec, err := bs.recalculateInvoice(dry, t)
+		if err != nil {
+			return nil, fmt.Errorf("invoice %s: %v", t.number, err)
+		}
+		if rec != 0 {
+			pendingIDs = append(pendingIDs, rec)
+		}
+	}
+	log.Printf("=== RECALCULATION COMPLETE: %d of %d invoice(s) affected ===", len(pendingIDs), len(targets))
+
+	if len(pendingIDs) == 0 {
+		return []models.InvoiceRecalculation{}, nil
+	}
+	return LoadRecalculations(bs.db, RecalculationFilter{IDs: pendingIDs})
+}
+
+// recalculateInvoice bills one invoice's period again and replaces its
+// pending recalculation. Returns the new recalculation's ID, or 0 when the
+// invoice is unaffected.
+func (bs *BillingService) recalculateInvoice(dry *BillingService, t recalcTarget) (int, error) {
+	// What the tenant has been billed so far: the invoice and the
+	// corrections issued from earlier recalculations.
+	ids := []int{t.id}
+	billedTotal, billedNet, billedVAT := t.total, t.net, t.vat
+	rows, err := bs.db.Query(`
+		SELECT i.id, i.total_amount, COALESCE(i.net_amount, 0), COALESCE(i.vat_amount, 0)
+		FROM invoice_recalculations r
+		JOIN invoices i ON i.id = r.correction_invoice_id
+		WHERE r.invoice_id = ? AND r.status = ?
+	`, t.id, RecalculationCorrected)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id int
+		var total, net, vat float64
+		if err := rows.Scan(&id, &total, &net, &vat); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		billedTotal += total
+		billedNet += net
+		billedVAT += vat
+	}
+	rows.Close()
+
+	billedItems, err := loadDocumentItems(bs.db, ids)
+	if err != nil {
+		return 0, err
+	}
+	var originalItems []models.InvoiceItem
+	for _, item := range billedItems {
+		if item.InvoiceID == t.id {
+			originalItems = append(originalItems, item)
+		}
+	}
+	scope, customItemIDs := bs.billingScope(t, originalItems)
+
+	var newTotal, newNet, newVAT float64
+	var lines []models.RecalculationLine
+	recalcErr := ""
+	invoices, skipped, err := dry.GenerateBillsWithOptions([]int{t.buildingID}, []int{t.userID},
+		t.periodStart, t.periodEnd, t.isVZEV, customItemIDs, scope)
+	var recalculated *models.Invoice
+	for i := range invoices {
+		if invoices[i].UserID == t.userID && invoices[i].BuildingID == t.buildingID {
+			recalculated = &invoices[i]
+			break
+		}
+	}
+	switch {
+	case err != nil:
+		recalcErr = err.Error()
+	case recalculated == nil:
+		recalcErr = "no invoice generated for the period"
+		for _, s := range skipped {
+			if s.UserID == t.userID {
+				recalcErr = s.Reason
+			}
+		}
+	default:
+		newTotal, newNet, newVAT = recalculated.TotalAmount, recalculated.NetAmount, recalculated.VATAmount
+		lines = diffInvoiceLines(billedItems, recalculated.Items)
+	}
+
+	difference := newTotal - billedTotal
+	affected := recalcErr != "" || math.Abs(difference) >= zeroBillEpsilon
+	for _, l := range lines {
+		affected = affected || math.Abs(l.AmountDifference) >= zeroBillEpsilon
+	}
+	if lines == nil {
+		lines = []models.RecalculationLine{}
+	}
+
+	tx, err := bs.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // no-op once committed
+
+	if _, err := tx.Exec(`DELETE FROM invoice_recalculations WHERE invoice_id = ? AND status = ?`, t.id, RecalculationPending); err != nil {
+		return 0, err
+	}
+	var id int64
+	if affected {
+		linesJSON, _ := json.Marshal(lines)
+		result, err := tx.Exec(`
+			INSERT INTO invoice_recalculations (
+				invoice_id, status, billed_total, billed_net, billed_vat,
+				new_total, new_net, new_vat, difference, lines, error
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, t.id, RecalculationPending, billedTotal, billedNet, billedVAT,
+			newTotal, newNet, newVAT, difference, string(linesJSON), recalcErr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store recalculation: %v", err)
+		}
+		id, _ = result.LastInsertId()
+		if recalcErr != "" {
+			log.Printf("  Invoice %s: period could not be billed again: %s", t.number, recalcErr)
+		} else {
+			log.Printf("  Invoice %s: billed %.2f, recalculated %.2f (difference %+.2f, %d line(s))",
+				t.number, billedTotal, newTotal, difference, len(lines))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// RecalculationFilter selects recalculations for LoadRecalculations.
+type RecalculationFilter struct {
+	IDs        []int
+	InvoiceID  int
+	BuildingID int
+	Status     string
+}
+
+// LoadRecalculations returns the recalculations matching the filter, newest
+// first.
+func LoadRecalculations(db *sql.DB, f RecalculationFilter) ([]models.InvoiceRecalculation, error) {
+	query := `
+		SELECT r.id, r.invoice_id, i.invoice_number, i.user_id,
+		       COALESCE(u.first_name || ' ' || u.last_name, ''), i.building_id,
+		       i.period_start, i.period_end, i.currency, r.status,
+		       r.billed_total, r.billed_net, r.billed_vat, r.new_total, r.new_net, r.new_vat,
+		       r.difference, r.lines, r.error, r.correction_invoice_id, COALESCE(c.invoice_number, ''),
+		       r.note, r.created_at, r.resolved_at
+		FROM invoice_recalculations r
+		JOIN invoices i ON i.id = r.invoice_id
+		LEFT JOIN users u ON u.id = i.user_id
+		LEFT JOIN invoices c ON c.id = r.correction_invoice_id
+		WHERE 1=1`
+	args := []interface{}{}
+	if len(f.IDs) > 0 {
+		query += ` AND r.id IN (` + buildPlaceholders(len(f.IDs)) + `)`
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	if f.InvoiceID != 0 {
+		query += ` AND r.invoice_id = ?`
+		args = append(args, f.InvoiceID)
+	}
+	if f.BuildingID != 0 {
+		query += ` AND i.building_id = ?`
+		args = append(args, f.BuildingID)
+	}
+	if f.Status != "" {
+		query += ` AND r.status = ?`
+		args = append(args, f.Status)
+	}
+	query += ` ORDER BY r.created_at DESC, r.id DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs := []models.InvoiceRecalculation{}
+	for rows.Next() {
+		var r models.InvoiceRecalculation
+		var linesJSON string
+		var correctionID sql.NullInt64
+		var resolvedAt sql.NullString
+		if err := rows.Scan(&r.ID, &r.InvoiceID, &r.InvoiceNumber, &r.UserID, &r.UserName, &r.BuildingID,
+			&r.PeriodStart, &r.PeriodEnd, &r.Currency, &r.Status,
+			&r.BilledTotal, &r.BilledNet, &r.BilledVAT, &r.NewTotal, &r.NewNet, &r.NewVAT,
+			&r.Difference, &linesJSON, &r.Error, &correctionID, &r.CorrectionNumber,
+			&r.Note, &r.CreatedAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+		r.PeriodStart, r.PeriodEnd = storedDay(r.PeriodStart), storedDay(r.PeriodEnd)
+		if err := json.Unmarshal([]byte(linesJSON), &r.Lines); err != nil || r.Lines == nil {
+			r.Lines = []models.RecalculationLine{}
+		}
+		if correctionID.Valid {
+			id := int(correctionID.Int64)
+			r.CorrectionInvoiceID = &id
+		}
+		if resolvedAt.Valid {
+			r.ResolvedAt = &resolvedAt.String
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
+
+// supplementaryNumber derives the legacy number of a supplementary invoice
+// from the invoice it corrects, like creditNoteNumber. A building with a
+// number scheme draws the number from the scheme instead.
+func supplementaryNumber(invoiceNumber string) string {
+	base := invoiceNumber
+	if i := strings.Index(base, "-"); i >= 0 {
+		base = base[i+1:]
+	}
+	return fmt.Sprintf("SUP-%s-%s", base, time.Now().Format("20060102150405"))
+}
+
+// correctionItems turns the lines of a recalculation into the items of its
+// correction document: per line the change in quantity and amount.
+func correctionItems(lines []models.RecalculationLine) []models.InvoiceItem {
+	items := make([]models.InvoiceItem, 0, len(lines))
+	for _, l := range lines {
+		description := l.Label
+		if math.Abs(l.QuantityChange) >= recalcQuantityEpsilon {
+			description = fmt.Sprintf("%s: %.3f → %.3f", l.Label, l.BilledQuantity, l.NewQuantity)
+		}
+		items = append(items, models.InvoiceItem{
+			Description: description,
+			Quantity:    l.QuantityChange,
+			UnitPrice:   l.UnitPrice,
+			TotalPrice:  l.AmountDifference,
+			ItemType:    l.ItemType,
+		})
+	}
+	return items
+}
+
+// CorrectRecalculation issues the correction for a pending recalculation:
+// a credit note for the difference when the invoice was billed too high, a
+// supplementary invoice when it was billed too low. Either carries the
+// per-line differences and references the invoice, which stays issued.
+func (bs *BillingService) CorrectRecalculation(recalculationID int) (*models.Invoice, error) {
+	recs, err := LoadRecalculations(bs.db, RecalculationFilter{IDs: []int{recalculationID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	rec := recs[0]
+	if rec.Status != RecalculationPending {
+		return nil, fmt.Errorf("recalculation %d is already %s", rec.ID, rec.Status)
+	}
+	if rec.Error != "" {
+		return nil, fmt.Errorf("invoice %s could not be billed again (%s); cancel and reissue it instead", rec.InvoiceNumber, rec.Error)
+	}
+	if math.Abs(rec.Difference) < zeroBillEpsilon {
+		return nil, fmt.Errorf("recalculation %d leaves the total of invoice %s unchanged; dismiss it instead", rec.ID, rec.InvoiceNumber)
+	}
+
+	var orig models.Invoice
+	var schemeID int
+	err = bs.db.QueryRow(`
+		SELECT id, invoice_number, user_id, building_id, period_start, period_end,
+		       COALESCE(vat_rate, 0), COALESCE(vat_included, 0), currency, status, COALESCE(is_vzev, 0),
+		       COALESCE(number_scheme_id, 0)
+		FROM invoices WHERE id = ?
+	`, rec.InvoiceID).Scan(&orig.ID, &orig.InvoiceNumber, &orig.UserID, &orig.BuildingID, &orig.PeriodStart, &orig.PeriodEnd,
+		&orig.VATRate, &orig.VATIncluded, &orig.Currency, &orig.Status, &orig.IsVZEV, &schemeID)
+	if err != nil {
+		return nil, err
+	}
+	orig.PeriodStart, orig.PeriodEnd = storedDay(orig.PeriodStart), storedDay(orig.PeriodEnd)
+	if orig.Status != InvoiceStatusIssued {
+		return nil, fmt.Errorf("invoice %s is %s; only issued invoices can be corrected", orig.InvoiceNumber, orig.Status)
+	}
+
+	doc := models.Invoice{
+		UserID:                orig.UserID,
+		BuildingID:            orig.BuildingID,
+		PeriodStart:           orig.PeriodStart,
+		PeriodEnd:             orig.PeriodEnd,
+		TotalAmount:           rec.Difference,
+		NetAmount:             rec.NewNet - rec.BilledNet,
+		VATAmount:             rec.NewVAT - rec.BilledVAT,
+		VATRate:               orig.VATRate,
+		VATIncluded:           orig.VATIncluded,
+		Currency:              orig.Currency,
+		IsVZEV:                orig.IsVZEV,
+		Items:                 correctionItems(rec.Lines),
+		OriginalInvoiceID:     &orig.ID,
+		OriginalInvoiceNumber: orig.InvoiceNumber,
+		Correction:            true,
+		GeneratedAt:           time.Now(),
+	}
+
+	if rec.Difference > 0 {
+		// Billed too low: a payable supplementary invoice with its own due
+		// date and, where the building has one, a number from its scheme.
+		doc.DocumentType = DocumentTypeSupplementary
+		doc.Status = InvoiceStatusIssued
+		id, number, err := bs.insertDocumentWithItems(DocumentTypeSupplementary, supplementaryNumber(orig.InvoiceNumber),
+			doc.UserID, doc.BuildingID, doc.PeriodStart, doc.PeriodEnd,
+			doc.TotalAmount, doc.NetAmount, doc.VATAmount, doc.VATRate, doc.VATIncluded, doc.Currency, doc.IsVZEV, doc.Items)
+		if err != nil {
+			return nil, err
+		}
+		doc.ID, doc.InvoiceNumber = int(id), number
+	}
+
+	tx, err := bs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op once committed
+
+	if rec.Difference > 0 {
+		if _, err := tx.Exec(`UPDATE invoices SET original_invoice_id = ? WHERE id = ?`, orig.ID, doc.ID); err != nil {
+			return nil, fmt.Errorf("failed to link supplementary invoice: %v", err)
+		}
+	} else {
+		// Billed too high: a credit note over the difference, numbered like
+		// the credit notes of the cancellation flow.
+		doc.DocumentType = DocumentTypeCreditNote
+		doc.Status = InvoiceStatusCredited
+		number, creditSchemeID, err := allocateCreditNumber(tx, orig, schemeID, doc.GeneratedAt)
+		if err != nil {
+			return nil, err
+		}
+		doc.InvoiceNumber = number
+		result, err := tx.Exec(`
+			INSERT INTO invoices (
+				invoice_number, user_id, building_id, period_start, period_end,
+				total_amount, net_amount, vat_amount, vat_rate, vat_included, currency, status, is_vzev,
+				document_type, original_invoice_id, number_scheme_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, doc.InvoiceNumber, doc.UserID, doc.BuildingID, doc.PeriodStart, doc.PeriodEnd,
+			doc.TotalAmount, doc.NetAmount, doc.VATAmount, doc.VATRate, doc.VATIncluded, doc.Currency,
+			doc.Status, doc.IsVZEV, doc.DocumentType, orig.ID, creditSchemeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create credit note: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		doc.ID = int(id)
+		if err := insertInvoiceItemsTx(tx, id, doc.Items); err != nil {
+			return nil, err
+		}
+		if err := bookCreditNote(tx, doc.ID, orig.ID, doc.InvoiceNumber, doc.TotalAmount); err != nil {
+			return nil, fmt.Errorf("failed to book credit note: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE invoice_recalculations SET status = ?, correction_invoice_id = ?, resolved_at = ?
+		WHERE id = ?
+	`, RecalculationCorrected, doc.ID, time.Now().Format("2006-01-02 15:04:05"), rec.ID); err != nil {
+		return nil, fmt.Errorf("failed to resolve recalculation: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit correction: %v", err)
+	}
+
+	log.Printf("Invoice %s corrected by %s %s (%s %.2f)",
+		orig.InvoiceNumber, doc.DocumentType, doc.InvoiceNumber, doc.Currency, doc.TotalAmount)
+	return &doc, nil
+}
+
+// DismissRecalculation closes a pending recalculation without a correction,
+// e.g. when the difference is too small to bill. Returns sql.ErrNoRows for
+// an unknown recalculation.
+func (bs *BillingService) DismissRecalculation(recalculationID int, note string) error {
+	result, err := bs.db.Exec(`
+		UPDATE invoice_recalculations SET status = ?, note = ?, resolved_at = ?
+		WHERE id = ? AND status = ?
+	`, RecalculationDismissed, note, time.Now().Format("2006-01-02 15:04:05"), recalculationID, RecalculationPending)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var status string
+		if err := bs.db.QueryRow(`SELECT status FROM invoice_recalculations WHERE id = ?`, recalculationID).Scan(&status); err != nil {
+			return err
+		}
+		return fmt.Errorf("recalculation %d is already %s", recalculationID, status)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestInvoiceRecalculation(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertPricing(t, db, 1, "2026-01-01", "", 0.25)
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, is_active) VALUES (10,'Anna','Muster','a@b.c',1,1)`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 2, "Wohnung A", "apartment_meter", 1, 10)
+	insertZEVReading(t, db, 2, time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local), 100, 0)
+
+	bs := NewBillingService(db)
+	invoices, _, err := bs.GenerateBillsWithOptions([]int{1}, []int{10}, "2026-01-01", "2026-01-31", false, nil, BillingScope{})
+	if err != nil || len(invoices) != 1 {
+		t.Fatalf("generate: %d invoices, %v", len(invoices), err)
+	}
+	if recs, err := bs.RecalculateInvoices(nil, "2026-01-01", "2026-01-31"); err != nil || len(recs) != 0 {
+		t.Fatalf("unchanged data flagged: %+v, %v", recs, err)
+	}
+
+	// 20 kWh arrive after billing.
+	insertZEVReading(t, db, 2, time.Date(2026, 1, 20, 12, 0, 0, 0, time.Local), 20, 0)
+	recs, err := bs.RecalculateInvoices([]int{1}, "2026-01-15", "2026-01-15")
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("recalculate: %+v, %v", recs, err)
+	}
+	rec := recs[0]
+	if rec.InvoiceID != invoices[0].ID || rec.Status != RecalculationPending || !almostEqual(rec.Difference, 5*1.081) {
+		t.Fatalf("recalculation %+v", rec)
+	}
+	var grid *models.RecalculationLine
+	for i := range rec.Lines {
+		if rec.Lines[i].ItemType == "normal_power" {
+			grid = &rec.Lines[i]
+		}
+	}
+	if grid == nil || !almostEqual(grid.QuantityChange, 20) || !almostEqual(grid.AmountDifference, 5) {
+		t.Fatalf("grid line %+v", rec.Lines)
+	}
+
+	doc, err := bs.CorrectRecalculation(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.DocumentType != DocumentTypeSupplementary || !almostEqual(doc.TotalAmount, rec.Difference) ||
+		doc.OriginalInvoiceID == nil || *doc.OriginalInvoiceID != invoices[0].ID {
+		t.Errorf("correction %+v", doc)
+	}
+	if _, err := bs.CorrectRecalculation(rec.ID); err == nil {
+		t.Error("recalculation corrected twice")
+	}
+	// The supplementary invoice counts as billed.
+	if recs, err := bs.RecalculateInvoices(nil, "2026-01-01", "2026-01-31"); err != nil || len(recs) != 0 {
+		t.Fatalf("corrected invoice flagged again: %+v, %v", recs, err)
+	}
+
+	// The late reading was wrong after all: credit the difference.
+	if _, err := db.Exec(`UPDATE meter_readings SET consumption_kwh = 4 WHERE consumption_kwh = 20`); err != nil {
+		t.Fatal(err)
+	}
+	recs, err = bs.RecalculateInvoices(nil, "2026-01-01", "2026-01-31")
+	if err != nil || len(recs) != 1 || !almostEqual(recs[0].Difference, -4*1.081) {
+		t.Fatalf("recalculate after fix: %+v, %v", recs, err)
+	}
+	credit, err := bs.CorrectRecalculation(recs[0].ID)
+	if err != nil || credit.DocumentType != DocumentTypeCreditNote || credit.Status != InvoiceStatusCredited {
+		t.Fatalf("credit %+v, %v", credit, err)
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM invoices WHERE id = ?`, invoices[0].ID).Scan(&status)
+	if status != InvoiceStatusIssued {
+		t.Errorf("corrected invoice status %s, want issued", status)
+	}
+}
@@ -160,6 +160,29 @@ func insertBuilding(t *testing.T, db *sql.DB, id int, name string) {
 	}
 }
 
+// insertUser seeds a tenant of the building with a placeholder name and
+// e-mail address.
+func insertUser(t *testing.T, db *sql.DB, id, buildingID int) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (?, 'A', 'B', 'a@b.c', ?)`,
+		id, buildingID); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+}
+
+// insertInvoice issues a CHF invoice for January 2026 without VAT, billing
+// total as a single normal power line.
+func insertInvoice(t *testing.T, bs *BillingService, number string, userID, buildingID int, total float64) int {
+	t.Helper()
+	items := []models.InvoiceItem{{Description: "Normal power", Quantity: total / 0.25, UnitPrice: 0.25, TotalPrice: total, ItemType: "normal_power"}}
+	id, _, err := bs.insertInvoiceWithItems(number, userID, buildingID, "2026-01-01", "2026-01-31",
+		total, total, 0, 0, false, "CHF", false, items)
+	if err != nil {
+		t.Fatalf("insert invoice %s: %v", number, err)
+	}
+	return int(id)
+}
+
 // insertPricing seeds one billing_settings row. validTo "" means open-ended.
 func insertPricing(t *testing.T, db *sql.DB, buildingID int, validFrom, validTo string, normal float64) {
 	t.Helper()
@@ -260,9 +283,7 @@ func TestCalculateZEVConsumptionBattery(t *testing.T) {
 	db := newTestDB(t)
 	bs := NewBillingService(db)
 	insertBuilding(t, db, 1, "Battery building")
-	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (10,'A','B','a@b.c',1)`); err != nil {
-		t.Fatalf("insert user: %v", err)
-	}
+	insertUser(t, db, 10, 1)
 	insertZEVMeter(t, db, 1, "Apt", "apartment_meter", 1, 10)
 	insertZEVMeter(t, db, 2, "Solar", "solar_meter", 1, nil)
 	insertZEVMeter(t, db, 3, "Battery", "battery_meter", 1, nil)
@@ -310,9 +331,7 @@ func TestCalculateZEVConsumptionNoBattery(t *testing.T) {
 	db := newTestDB(t)
 	bs := NewBillingService(db)
 	insertBuilding(t, db, 1, "No battery")
-	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (10,'A','B','a@b.c',1)`); err != nil {
-		t.Fatalf("insert user: %v", err)
-	}
+	insertUser(t, db, 10, 1)
 	insertZEVMeter(t, db, 1, "Apt", "apartment_meter", 1, 10)
 	insertZEVMeter(t, db, 2, "Solar", "solar_meter", 1, nil)
 
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Utilities billed besides electricity. A utility meter has the meter type
+// "<utility>_meter" and is read by the same collectors as an electricity
+// meter: its counter lands in the meter_readings kWh columns in the meter's
+// own unit and is scaled by meters.reading_factor when billed.
+const (
+	UtilityColdWater = "cold_water"
+	UtilityHotWater  = "hot_water"
+	UtilityHeat      = "heat"
+	UtilityGas       = "gas"
+)
+
+// utilityOrder is the order of the utility sections on an invoice.
+var utilityOrder = []string{UtilityColdWater, UtilityHotWater, UtilityHeat, UtilityGas}
+
+// ValidUtility reports whether u is a known utility.
+func ValidUtility(u string) bool {
+	for _, v := range utilityOrder {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
+
+// UtilityMeterType is the meter type of a utility's meters.
+func UtilityMeterType(u string) string {
+	return u + "_meter"
+}
+
+// UtilityOfMeterType returns the utility a meter type measures, or "" for
+// electricity meters.
+func UtilityOfMeterType(meterType string) string {
+	if u := strings.TrimSuffix(meterType, "_meter"); u != meterType && ValidUtility(u) {
+		return u
+	}
+	return ""
+}
+
+// DefaultUtilityUnit is the unit of a utility's price when the price row
+// sets none.
+func DefaultUtilityUnit(u string) string {
+	if u == UtilityHeat {
+		return "kWh"
+	}
+	return "m³"
+}
+
+func utilityLabel(u string, tr InvoiceTranslations) string {
+	switch u {
+	case UtilityColdWater:
+		return tr.ColdWater
+	case UtilityHotWater:
+		return tr.HotWater
+	case UtilityHeat:
+		return tr.Heat
+	case UtilityGas:
+		return tr.Gas
+	}
+	return u
+}
+
+// loadUtilityPrices loads the active prices of a building's utility that are
+// valid on at least one day of [start, end), newest first.
+func (bs *BillingService) loadUtilityPrices(buildingID int, utility string, start, end time.Time) ([]models.UtilityPrice, error) {
+	rows, err := bs.db.Query(`
+		SELECT id, building_id, utility, unit, price, base_fee, valid_from, valid_to, is_active
+		FROM utility_prices
+		WHERE building_id = ? AND utility = ? AND is_active = 1
+		  AND valid_from < ? AND (valid_to = '' OR valid_to >= ?)
+		ORDER BY valid_from DESC, id DESC
+	`, buildingID, utility, end.Format("2006-01-02"), start.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query utility_prices: %v", err)
+	}
+	defer rows.Close()
+
+	var prices []models.UtilityPrice
+	for rows.Next() {
+		var p models.UtilityPrice
+		if err := rows.Scan(&p.ID, &p.BuildingID, &p.Utility, &p.Unit, &p.Price, &p.BaseFee,
+			&p.ValidFrom, &p.ValidTo, &p.IsActive); err != nil {
+			return nil, err
+		}
+		if p.Unit == "" {
+			p.Unit = DefaultUtilityUnit(p.Utility)
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// utilityDailyConsumption returns a meter's consumption per day of [start,
+// end) in its billing unit.
+func (bs *BillingService) utilityDailyConsumption(meterID int, factor float64, start, end time.Time) (map[string]float64, error) {
+	rows, err := bs.db.Query(`
+		SELECT reading_time, COALESCE(consumption_kwh, 0)
+		FROM meter_readings
+		WHERE meter_id = ? AND reading_time >= ? AND reading_time < ?
+	`, meterID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query utility readings: %v", err)
+	}
+	defer rows.Close()
+
+	daily := make(map[string]float64)
+	for rows.Next() {
+		var ts time.Time
+		var v float64
+		if err := rows.Scan(&ts, &v); err != nil {
+			return nil, err
+		}
+		daily[ts.Format("2006-01-02")] += v * factor
+	}
+	return daily, rows.Err()
+}
+
+// utilityCounter returns a meter's scaled counter at its last reading at or
+// before t (0 without readings).
+func (bs *BillingService) utilityCounter(meterID int, factor float64, t time.Time) float64 {
+	var v float64
+	bs.db.QueryRow(`
+		SELECT COALESCE((SELECT power_kwh FROM meter_readings
+		                 WHERE meter_id = ? AND reading_time <= ?
+		                 ORDER BY reading_time DESC LIMIT 1), 0)
+	`, meterID, t).Scan(&v)
+	return v * factor
+}
+
+// appendUtilityItems bills a user's water, heat and gas meters over [start,
+// end): one section per meter with its readings, the consumption priced at
+// the building's utility price valid on each day, and the monthly base fee
+// pro rata by days. Consumption on a day without a price is an error rather
+// than being billed at zero. Returns the cost added.
+func (bs *BillingService) appendUtilityItems(items *[]models.InvoiceItem, userID, buildingID int,
+	start, end time.Time, currency string, tr InvoiceTranslations) (float64, error) {
+	types := make([]interface{}, 0, len(utilityOrder)+2)
+	types = append(types, userID, buildingID)
+	for _, u := range utilityOrder {
+		types = append(types, UtilityMeterType(u))
+	}
+	rows, err := bs.db.Query(`
+		SELECT id, name, meter_type, COALESCE(reading_factor, 1)
+		FROM meters
+		WHERE user_id = ? AND building_id = ? AND meter_type IN (`+buildPlaceholders(len(utilityOrder))+`)
+		ORDER BY sort_order, id
+	`, types...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query utility meters: %v", err)
+	}
+	type utilityMeter struct {
+		id      int
+		name    string
+		utility string
+		factor  float64
+	}
+	var meters []utilityMeter
+	for rows.Next() {
+		var m utilityMeter
+		var meterType string
+		if err := rows.Scan(&m.id, &m.name, &meterType, &m.factor); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if m.factor <= 0 {
+			m.factor = 1
+		}
+		m.utility = UtilityOfMeterType(meterType)
+		meters = append(meters, m)
+	}
+	rows.Close()
+	if len(meters) == 0 {
+		return 0, nil
+	}
+	rank := make(map[string]int, len(utilityOrder))
+	for i, u := range utilityOrder {
+		rank[u] = i
+	}
+	sort.SliceStable(meters, func(i, j int) bool { return rank[meters[i].utility] < rank[meters[j].utility] })
+
+	prices := make(map[string][]models.UtilityPrice)
+	total := 0.0
+	for _, m := range meters {
+		if _, loaded := prices[m.utility]; !loaded {
+			p, err := bs.loadUtilityPrices(buildingID, m.utility, start, end)
+			if err != nil {
+				return 0, err
+			}
+			prices[m.utility] = p
+		}
+		daily, err := bs.utilityDailyConsumption(m.id, m.factor, start, end)
+		if err != nil {
+			return 0, err
+		}
+
+		// Per price row: the quantity, the months of base fee and the days it
+		// covered.
+		type priced struct {
+			qty, months float64
+			first, last time.Time
+			used        bool
+		}
+		rowPrices := prices[m.utility]
+		perRow := make([]priced, len(rowPrices))
+		consumption := 0.0
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			day := d.Format("2006-01-02")
+			idx := -1
+			for i, p := range rowPrices {
+				if p.ValidFrom <= day && (p.ValidTo == "" || day <= p.ValidTo) {
+					idx = i
+					break
+				}
+			}
+			consumption += daily[day]
+			if idx < 0 {
+				if daily[day] > 0 {
+					return 0, fmt.Errorf("no %s price for building %d on %s (meter %s)", m.utility, buildingID, day, m.name)
+				}
+				continue
+			}
+			r := &perRow[idx]
+			r.qty += daily[day]
+			r.months += 1 / float64(time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day())
+			if !r.used {
+				r.first, r.used = d, true
+			}
+			r.last = d
+		}
+
+		// Price rows in the order they applied.
+		order := make([]int, 0, len(perRow))
+		hasFee := false
+		for i, r := range perRow {
+			if r.used {
+				order = append(order, i)
+				hasFee = hasFee || rowPrices[i].BaseFee > 0
+			}
+		}
+		if consumption <= 0 && !hasFee {
+			continue
+		}
+		sort.Slice(order, func(a, b int) bool { return perRow[order[a]].first.Before(perRow[order[b]].first) })
+
+		label := utilityLabel(m.utility, tr)
+		unit := DefaultUtilityUnit(m.utility)
+		if len(order) > 0 {
+			unit = rowPrices[order[0]].Unit
+		}
+		*items = append(*items,
+			models.InvoiceItem{
+				Description: fmt.Sprintf("%s: %s", label, m.name),
+				ItemType:    "utility_header",
+			},
+			models.InvoiceItem{
+				Description: fmt.Sprintf("%s: %s-%s | %s: %.3f %s | %s: %.3f %s | %s: %.3f %s",
+					tr.Period, start.Format("02.01"), end.AddDate(0, 0, -1).Format("02.01"),
+					tr.OldReading, bs.utilityCounter(m.id, m.factor, start), unit,
+					tr.NewReading, bs.utilityCounter(m.id, m.factor, end), unit,
+					tr.Consumption, consumption, unit),
+				Quantity: consumption,
+				ItemType: "meter_reading_compact",
+			})
+
+		for _, i := range order {
+			r, p := perRow[i], rowPrices[i]
+			suffix := segmentSuffix(PriceSegment{Start: r.first, End: r.last.AddDate(0, 0, 1)}, len(order) > 1)
+			if r.qty > 0 {
+				cost := r.qty * p.Price
+				total += cost
+				*items = append(*items, models.InvoiceItem{
+					Description: fmt.Sprintf("%s%s: %.3f %s × %.3f %s/%s", label, suffix, r.qty, p.Unit, p.Price, currency, p.Unit),
+					Quantity:    r.qty,
+					UnitPrice:   p.Price,
+					TotalPrice:  cost,
+					ItemType:    "utility_charge",
+				})
+				log.Printf("  %s%s (%s): %.3f %s × %.3f = %.3f %s", label, suffix, m.name, r.qty, p.Unit, p.Price, cost, currency)
+			}
+			if p.BaseFee > 0 {
+				fee := r.months * p.BaseFee
+				total += fee
+				*items = append(*items, models.InvoiceItem{
+					Description: fmt.Sprintf("%s %s%s: %.2f × %.2f %s", tr.BaseFee, label, suffix, r.months, p.BaseFee, currency),
+					Quantity:    r.months,
+					UnitPrice:   p.BaseFee,
+					TotalPrice:  fee,
+					ItemType:    "utility_base_fee",
+				})
+			}
+		}
+		*items = append(*items, models.InvoiceItem{ItemType: "separator"})
+	}
+	return total, nil
+}
+
+// tenantUtilityConsumption returns the consumption of one user's meters of a
+// meter type in a building over [start, end), and that of all tenants'
+// meters of the type, in the meters' billing unit. It is the basis for
+// allocating a shared meter by consumption.
+func (bs *BillingService) tenantUtilityConsumption(buildingID, userID int, meterType string, start, end time.Time) (user, all float64, err error) {
+	err = bs.db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN m.user_id = ? THEN mr.consumption_kwh * COALESCE(m.reading_factor, 1) ELSE 0 END), 0),
+		       COALESCE(SUM(mr.consumption_kwh * COALESCE(m.reading_factor, 1)), 0)
+		FROM meter_readings mr
+		JOIN meters m ON m.id = mr.meter_id
+		WHERE m.building_id = ? AND m.meter_type = ? AND m.user_id IS NOT NULL
+		  AND mr.reading_time >= ? AND mr.reading_time < ?
+	`, userID, buildingID, meterType, start, end).Scan(&user, &all)
+	return user, all, err
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestUtilityMeterPlausibilityLimit(t *testing.T) {
+	cases := []struct {
+		meterType string
+		factor    float64
+		want      float64
+	}{
+		{"apartment_meter", 0.001, maxIntervalConsumptionKwh}, // electricity stays unscaled
+		{"cold_water_meter", 1, 25},
+		{"cold_water_meter", 0.001, 25000}, // litre counter
+		{"heat_meter", 0.001, 1e6},         // Wh counter
+		{"gas_meter", 0, 100},              // unset factor counts as 1
+	}
+	for _, c := range cases {
+		if got := maxIntervalConsumption(c.meterType, c.factor); !almostEqual(got, c.want) {
+			t.Errorf("maxIntervalConsumption(%s, %g) = %g, want %g", c.meterType, c.factor, got, c.want)
+		}
+	}
+}
+
+func TestUtilityBilling(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, apartment_area, is_active) VALUES
+		(10,'Anna','Muster','a@b.c',1,60,1), (11,'Hans','Meier','h@b.c',1,40,1)`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 2, "Wasser A", "cold_water_meter", 1, 10)
+	if _, err := db.Exec(`UPDATE meters SET reading_factor = 0.001 WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO utility_prices (building_id, utility, price, base_fee, valid_from, valid_to) VALUES
+		(1, 'cold_water', 2.00, 3.10, '2026-01-01', '2026-01-31'), (1, 'cold_water', 2.50, 0, '2026-02-01', '')`); err != nil {
+		t.Fatal(err)
+	}
+	// Litres, billed per m³: 3 m³ in January, 2 m³ in February.
+	insertZEVReading(t, db, 2, time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC), 3000, 0)
+	insertZEVReading(t, db, 2, time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC), 2000, 0)
+
+	bs := NewBillingService(db)
+	tr := GetTranslations("en")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []models.InvoiceItem
+	total, err := bs.appendUtilityItems(&items, 10, 1, start, start.AddDate(0, 1, 14), "CHF", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 m³ × 2.00 + 2 m³ × 2.50 + one month of base fee.
+	if !almostEqual(total, 14.10) || len(items) != 6 || items[0].ItemType != "utility_header" {
+		t.Fatalf("total %.4f, items %+v", total, items)
+	}
+	insertZEVReading(t, db, 2, time.Date(2025, 12, 20, 12, 0, 0, 0, time.UTC), 1000, 0)
+	if _, err := bs.appendUtilityItems(&items, 10, 1, start.AddDate(0, -1, 0), start.AddDate(0, 0, 10), "CHF", tr); err == nil {
+		t.Error("consumption without a price was billed")
+	}
+
+	// Shared heat: 70% by the tenants' heat meters (30 : 10), 30% by area (60 : 40).
+	insertZEVMeter(t, db, 3, "Heizung", "heat_meter", 1, nil)
+	insertZEVMeter(t, db, 4, "Wärme A", "heat_meter", 1, 10)
+	insertZEVMeter(t, db, 5, "Wärme B", "heat_meter", 1, 11)
+	if _, err := db.Exec(`INSERT INTO meter_readings (meter_id, reading_time, power_kwh) VALUES (3, ?, 0), (3, ?, 1000)`,
+		start, start.AddDate(0, 0, 20)); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVReading(t, db, 4, start.AddDate(0, 0, 3), 30, 0)
+	insertZEVReading(t, db, 5, start.AddDate(0, 0, 3), 10, 0)
+	if _, err := db.Exec(`INSERT INTO shared_meter_configs (meter_id, building_id, meter_name, split_type, unit_price, consumption_share)
+		VALUES (3, 1, 'Heizung', 'by_area', 0.10, 70)`); err != nil {
+		t.Fatal(err)
+	}
+	_, shared, err := bs.calculateSharedMeterCostsWithTranslations(1, start, start.AddDate(0, 1, 0), 10, 2, tr, "CHF", 1, models.BillingSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !almostEqual(shared, 100*0.3*0.6+100*0.7*0.75) {
+		t.Errorf("shared heat share %.4f", shared)
+	}
+}
@@ -496,8 +496,14 @@ func (bs *BillingService) generateVZEVInvoiceWithOptions(userPeriod UserPeriod,
 				suffix, er.VirtualPV, s.VZEVExportPrice, cost, s.Currency)
 		}
 
-		// 3. Grid energy
-		if er.GridEnergy > 0 {
+		// 3. Grid energy, split into the building's price components if it has any
+		components, err := bs.priceComponentsFor(buildingID, sr.Segment.Start, sr.Segment.End)
+		if err != nil {
+			return nil, err
+		}
+		if er.GridEnergy > 0 && components != nil {
+			totalAmount += appendPriceComponentItems(&items, components, er.GridEnergy, s, suffix, "  └─ ", tr)
+		} else if er.GridEnergy > 0 {
 			cost := er.GridEnergy * s.NormalPowerPrice
 			totalAmount += cost
 			items = append(items, models.InvoiceItem{
@@ -513,6 +519,20 @@ func (bs *BillingService) generateVZEVInvoiceWithOptions(userPeriod UserPeriod,
 		}
 	}
 
+	// Peak-demand (kW) charges of the user's demand tariffs, per calendar month.
+	demandCost, err := bs.appendDemandChargeItems(&items, userPeriod.UserID, buildingID, start, end, primary.Currency, tr)
+	if err != nil {
+		return nil, err
+	}
+	totalAmount += demandCost
+
+	// Water, heat and gas meters of the user, each in its own section.
+	utilityCost, err := bs.appendUtilityItems(&items, userPeriod.UserID, buildingID, start, end, primary.Currency, tr)
+	if err != nil {
+		return nil, err
+	}
+	totalAmount += utilityCost
+
 	// Car charging — mode-based and solar-split chargers handled together. The solar
 	// split here uses the charger's own building pool (the vZEV virtual-PV sharing
 	// applies to apartment energy, not to charger billing).
@@ -557,7 +577,8 @@ func (bs *BillingService) generateVZEVInvoiceWithOptions(userPeriod UserPeriod,
 	}
 
 	// Resolve VAT (MwSt.) from the primary segment; gross becomes the stored total.
-	netAmount, vatAmount, grossAmount := vatBreakdown(totalAmount, primary)
+	bs.assignItemVAT(items, primary)
+	netAmount, vatAmount, grossAmount := vatBreakdownItems(totalAmount, items, primary)
 	totalAmount = grossAmount
 
 	// SAFETY: never persist a 0.00 vZEV invoice (see generateUserInvoiceForPeriodWithOptionsAndScope).
@@ -567,7 +588,7 @@ func (bs *BillingService) generateVZEVInvoiceWithOptions(userPeriod UserPeriod,
 	}
 
 	// Create invoice record (+ items) atomically; vZEV flag set.
-	invoiceID, err := bs.insertInvoiceWithItems(
+	invoiceID, invoiceNumber, err := bs.insertInvoiceWithItems(
 		invoiceNumber, userPeriod.UserID, buildingID,
 		fullStart.Format("2006-01-02"), displayEnd.Format("2006-01-02"),
 		totalAmount, netAmount, vatAmount, primary.VATRate, primary.VATIncluded, primary.Currency,
@@ -590,7 +611,8 @@ func (bs *BillingService) generateVZEVInvoiceWithOptions(userPeriod UserPeriod,
 		VATRate:       primary.VATRate,
 		VATIncluded:   primary.VATIncluded,
 		Currency:      primary.Currency,
-		Status:        "issued",
+		Status:        bs.storedStatus(),
+		DocumentType:  DocumentTypeInvoice,
 		IsVZEV:        true,
 		Items:         items,
 		GeneratedAt:   time.Now(),
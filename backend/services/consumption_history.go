@@ -0,0 +1,214 @@
+package services
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// ConsumptionMonth is one month of a tenant's consumption history, split the
+// way the invoice bills it.
+type ConsumptionMonth struct {
+	Month           time.Time // first day of the month
+	Solar           float64
+	Battery         float64
+	Grid            float64
+	PreviousYear    float64 // the tenant's consumption in the same month a year earlier
+	BuildingAverage float64 // the building's apartment consumption per apartment meter
+}
+
+// Total is the tenant's consumption in the month.
+func (m ConsumptionMonth) Total() float64 {
+	return m.Solar + m.Battery + m.Grid
+}
+
+// ConsumptionHistory returns the monthly consumption of userID in buildingID
+// for the 12 calendar months up to the one periodEnd (the last billed day)
+// falls in; that month ends with periodEnd. Months are split into solar,
+// battery and grid interval by interval, like the invoice itself. Returns nil
+// when the tenant has no consumption in the whole range.
+func (bs *BillingService) ConsumptionHistory(userID, buildingID int, periodEnd time.Time) []ConsumptionMonth {
+	stop := time.Date(periodEnd.Year(), periodEnd.Month(), periodEnd.Day()+1, 0, 0, 0, 0, periodEnd.Location())
+	last := time.Date(periodEnd.Year(), periodEnd.Month(), 1, 0, 0, 0, 0, periodEnd.Location())
+
+	history := make([]ConsumptionMonth, 0, 12)
+	found := false
+	for i := 11; i >= 0; i-- {
+		start := last.AddDate(0, -i, 0)
+		end := start.AddDate(0, 1, 0)
+		if end.After(stop) {
+			end = stop
+		}
+		m := ConsumptionMonth{Month: start}
+
+		own, building, units, err := bs.apartmentConsumption(userID, buildingID, start, end)
+		if err != nil {
+			log.Printf("WARNING: Failed to load consumption history of user %d: %v", userID, err)
+			return nil
+		}
+		if units > 0 {
+			m.BuildingAverage = building / float64(units)
+		}
+		if own > 0 {
+			m.Grid, m.Solar, m.Battery, _ = bs.calculateZEVConsumption(userID, buildingID, start, end.Add(-time.Second))
+			found = found || m.Total() > 0
+		}
+		if m.PreviousYear, _, _, err = bs.apartmentConsumption(userID, buildingID, start.AddDate(-1, 0, 0), end.AddDate(-1, 0, 0)); err != nil {
+			log.Printf("WARNING: Failed to load consumption history of user %d: %v", userID, err)
+			return nil
+		}
+		history = append(history, m)
+	}
+	if !found {
+		return nil
+	}
+	return history
+}
+
+// apartmentConsumption sums the apartment meter readings of buildingID from
+// start (inclusive) to end (exclusive): the part of userID, the whole
+// building's and the number of apartment meters that reported.
+func (bs *BillingService) apartmentConsumption(userID, buildingID int, start, end time.Time) (own, building float64, units int, err error) {
+	err = bs.db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN m.user_id = ? THEN mr.consumption_kwh ELSE 0 END), 0),
+		       COALESCE(SUM(mr.consumption_kwh), 0), COUNT(DISTINCT m.id)
+		FROM meter_readings mr
+		JOIN meters m ON mr.meter_id = m.id
+		WHERE m.building_id = ? AND m.meter_type = 'apartment_meter'
+		AND mr.reading_time >= ? AND mr.reading_time < ?
+	`, userID, buildingID, start, end).Scan(&own, &building, &units)
+	return own, building, units, err
+}
+
+// consumptionHistory returns the history charted on inv: nil unless the
+// building's bill layout shows the chart and inv bills consumption.
+func (pg *PDFGenerator) consumptionHistory(inv map[string]interface{}, layout billLayout) []ConsumptionMonth {
+	if !layout.ShowConsumptionChart || pg.db == nil {
+		return nil
+	}
+	switch documentType, _ := inv["document_type"].(string); documentType {
+	case "", DocumentTypeInvoice, DocumentTypeSettlement:
+	default:
+		return nil
+	}
+	userID, _ := inv["user_id"].(int)
+	buildingID, _ := inv["building_id"].(int)
+	periodEnd, err := time.Parse("2006-01-02", storedDay(fmt.Sprintf("%v", inv["period_end"])))
+	if userID == 0 || buildingID == 0 || err != nil {
+		return nil
+	}
+	return NewBillingService(pg.db).ConsumptionHistory(userID, buildingID, periodEnd)
+}
+
+// Colours of the consumption chart; solar, battery and grid match the item
+// icons.
+const (
+	chartColorSolar        = "#f59e0b"
+	chartColorBattery      = "#a855f7"
+	chartColorGrid         = "#3b82f6"
+	chartColorPreviousYear = "#111827"
+	chartColorAverage      = "#9ca3af"
+	chartColorAxis         = "#dddddd"
+)
+
+// chartSegment is one part of a month's stacked bar.
+type chartSegment struct {
+	kwh   float64
+	color string
+}
+
+// chartSegments returns the non-empty parts of m's bar from the bottom up:
+// solar, battery, grid.
+func (m ConsumptionMonth) chartSegments() []chartSegment {
+	var segs []chartSegment
+	for _, seg := range []chartSegment{{m.Solar, chartColorSolar}, {m.Battery, chartColorBattery}, {m.Grid, chartColorGrid}} {
+		if seg.kwh > 0 {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// consumptionChartMax is the top of the chart's kWh axis: the largest value
+// shown, rounded up to 1, 2 or 5 times a power of ten.
+func consumptionChartMax(history []ConsumptionMonth) float64 {
+	largest := 0.0
+	for _, m := range history {
+		largest = math.Max(largest, math.Max(m.Total(), math.Max(m.PreviousYear, m.BuildingAverage)))
+	}
+	if largest <= 0 {
+		return 1
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(largest)))
+	for _, f := range []float64{1, 2, 5} {
+		if f*mag >= largest {
+			return f * mag
+		}
+	}
+	return 10 * mag
+}
+
+// consumptionChartLegend lists the chart's series with their colours; the
+// battery only when there is battery energy to show.
+func consumptionChartLegend(history []ConsumptionMonth, tr InvoiceTranslations) [][2]string {
+	legend := [][2]string{{chartColorSolar, tr.SolarPower}}
+	for _, m := range history {
+		if m.Battery > 0 {
+			legend = append(legend, [2]string{chartColorBattery, tr.BatteryPower})
+			break
+		}
+	}
+	return append(legend,
+		[2]string{chartColorGrid, tr.GridPower},
+		[2]string{chartColorPreviousYear, tr.PreviousYear},
+		[2]string{chartColorAverage, tr.BuildingAverage},
+	)
+}
+
+// consumptionChartHTML renders history as an inline SVG: per month the solar,
+// battery and grid kWh stacked, the previous year as a tick across the bar
+// and the building average per unit as a dashed line.
+func consumptionChartHTML(history []ConsumptionMonth, tr InvoiceTranslations) string {
+	const (
+		width, left, right = 700.0, 44.0, 696.0
+		top, bottom        = 12.0, 150.0
+	)
+	scale := consumptionChartMax(history)
+	slot := (right - left) / float64(len(history))
+	y := func(kwh float64) float64 { return bottom - kwh/scale*(bottom-top) }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f 172" width="100%%">`, width)
+	for _, v := range []float64{0, scale / 2, scale} {
+		fmt.Fprintf(&svg, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1"/>`, left, y(v), right, y(v), chartColorAxis)
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="9" fill="#666" text-anchor="end">%.0f</text>`, left-4, y(v)+3, v)
+	}
+	var average []string
+	for i, m := range history {
+		center := left + slot*(float64(i)+0.5)
+		base := bottom
+		for _, seg := range m.chartSegments() {
+			h := seg.kwh / scale * (bottom - top)
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, center-slot*0.25, base-h, slot*0.5, h, seg.color)
+			base -= h
+		}
+		if m.PreviousYear > 0 {
+			fmt.Fprintf(&svg, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`,
+				center-slot*0.35, y(m.PreviousYear), center+slot*0.35, y(m.PreviousYear), chartColorPreviousYear)
+		}
+		average = append(average, fmt.Sprintf("%.1f,%.1f", center, y(m.BuildingAverage)))
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="9" fill="#666" text-anchor="middle">%s</text>`, center, bottom+14, m.Month.Format("01/06"))
+	}
+	fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-dasharray="4 3"/>`, strings.Join(average, " "), chartColorAverage)
+	svg.WriteString(`</svg>`)
+
+	legend := ""
+	for _, l := range consumptionChartLegend(history, tr) {
+		legend += fmt.Sprintf(`<span><i style="background: %s"></i>%s</span>`, l[0], template.HTMLEscapeString(l[1]))
+	}
+	return fmt.Sprintf(`<div class="consumption-chart"><h4>%s</h4>%s<div class="chart-legend">%s</div></div>`,
+		template.HTMLEscapeString(tr.ConsumptionHistory), svg.String(), legend)
+}
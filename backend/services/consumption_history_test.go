@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestConsumptionHistory(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "B")
+	for _, id := range []int{10, 11} {
+		if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (?,'A','B','a@b.c',1)`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertZEVMeter(t, db, 1, "Apt 1", "apartment_meter", 1, 10)
+	insertZEVMeter(t, db, 2, "Apt 2", "apartment_meter", 1, 11)
+	insertZEVMeter(t, db, 3, "Solar", "solar_meter", 1, nil)
+
+	june := time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC)
+	insertZEVReading(t, db, 1, june, 2, 0)
+	insertZEVReading(t, db, 2, june, 4, 0)
+	insertZEVReading(t, db, 3, june, 0, 3)
+	insertZEVReading(t, db, 1, june.AddDate(-1, 0, 0), 3, 0)
+	insertZEVReading(t, db, 1, june.AddDate(0, 1, 0), 5, 0) // after the period
+
+	history := NewBillingService(db).ConsumptionHistory(10, 1, time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC))
+	if len(history) != 12 || history[0].Month.Format("2006-01") != "2025-07" {
+		t.Fatalf("history %+v", history)
+	}
+	// The 3 kWh of solar are shared over the building's 6 kWh: half of the
+	// tenant's 2 kWh is solar.
+	last := history[11]
+	if !almostEqual(last.Solar, 1) || !almostEqual(last.Grid, 1) || !almostEqual(last.PreviousYear, 3) || !almostEqual(last.BuildingAverage, 3) {
+		t.Errorf("June %+v", last)
+	}
+	if consumptionChartMax(history) != 5 {
+		t.Errorf("axis max %.1f", consumptionChartMax(history))
+	}
+
+	pg := NewPDFGenerator(db)
+	inv := InvoiceToMap(models.Invoice{UserID: 10, BuildingID: 1, PeriodStart: "2026-06-01", PeriodEnd: "2026-06-30",
+		DocumentType: DocumentTypeInvoice, Currency: "CHF", Status: "issued"})
+	if html, _ := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); strings.Contains(html, `class="consumption-chart"`) {
+		t.Errorf("chart shown without the bill layout setting")
+	}
+	if _, err := db.Exec(`INSERT INTO bill_layouts (building_id, show_consumption_chart) VALUES (1, 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if html, _ := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); !strings.Contains(html, `class="consumption-chart"`) || strings.Contains(html, "%!") {
+		t.Errorf("chart missing or malformed")
+	}
+	pdf, err := pg.renderNativeInvoicePDF(inv, SenderInfo{}, BankingInfo{})
+	if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatalf("render native invoice: %v", err)
+	}
+	inv["document_type"] = DocumentTypeCreditNote
+	if html, _ := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); strings.Contains(html, `class="consumption-chart"`) {
+		t.Errorf("chart shown on a credit note")
+	}
+}
@@ -1161,7 +1161,36 @@ func (dc *DataCollector) waitForAsyncVirtualSources(virtualMeterIDs []int, at ti
 // unaffected.
 const maxIntervalConsumptionKwh = 1000.0
 
+// maxIntervalUtility bounds a 15-min interval of the utility meters in their
+// billing unit (m³ of water or gas, kWh of heat).
+var maxIntervalUtility = map[string]float64{
+	UtilityColdWater: 25, // 100 m³/h, far beyond a building's water main
+	UtilityHotWater:  25,
+	UtilityHeat:      1000, // same 4 MW bound as electricity
+	UtilityGas:       100,  // ~4 MW of natural gas
+}
+
+// maxIntervalConsumption is the plausibility bound of one 15-min delta of a
+// meter's raw counter. Utility counters are in the meter's own unit, so their
+// bound is divided by reading_factor (a litre counter with factor 0.001 may
+// move 25'000 per interval).
+func maxIntervalConsumption(meterType string, readingFactor float64) float64 {
+	limit, ok := maxIntervalUtility[UtilityOfMeterType(meterType)]
+	if !ok {
+		return maxIntervalConsumptionKwh
+	}
+	if readingFactor <= 0 {
+		readingFactor = 1
+	}
+	return limit / readingFactor
+}
+
 func (dc *DataCollector) saveMeterReading(meterID int, meterName string, currentTime time.Time, reading float64, readingExport float64) error {
+	var meterType string
+	readingFactor := 1.0
+	dc.db.QueryRow(`SELECT meter_type, COALESCE(reading_factor, 1) FROM meters WHERE id = ?`, meterID).Scan(&meterType, &readingFactor)
+	maxInterval := maxIntervalConsumption(meterType, readingFactor)
+
 	// Get last reading for interpolation
 	var lastReading, lastReadingExport float64
 	var lastTime time.Time
@@ -1185,7 +1214,7 @@ func (dc *DataCollector) saveMeterReading(meterID int, meterName string, current
 		
 		for i, point := range interpolated {
 			intervalConsumption := point.value - lastReading
-			if intervalConsumption < 0 || intervalConsumption > maxIntervalConsumptionKwh {
+			if intervalConsumption < 0 || intervalConsumption > maxInterval {
 				intervalConsumption = 0
 			}
 
@@ -1194,7 +1223,7 @@ func (dc *DataCollector) saveMeterReading(meterID int, meterName string, current
 			if i < len(interpolatedExport) {
 				exportValue = interpolatedExport[i].value
 				intervalExport = exportValue - lastReadingExport
-				if intervalExport < 0 || intervalExport > maxIntervalConsumptionKwh {
+				if intervalExport < 0 || intervalExport > maxInterval {
 					intervalExport = 0
 				}
 			}
@@ -1219,8 +1248,8 @@ func (dc *DataCollector) saveMeterReading(meterID int, meterName string, current
 		if consumption < 0 {
 			consumption = 0
 		}
-		if consumption > maxIntervalConsumptionKwh {
-			log.Printf("Meter '%s': implausible interval consumption %.1f kWh (counter init/reset?) — recording 0", meterName, consumption)
+		if consumption > maxInterval {
+			log.Printf("Meter '%s': implausible interval consumption %.1f (counter init/reset?) — recording 0", meterName, consumption)
 			consumption = 0
 		}
 
@@ -1228,7 +1257,7 @@ func (dc *DataCollector) saveMeterReading(meterID int, meterName string, current
 		if consumptionExport < 0 {
 			consumptionExport = 0
 		}
-		if consumptionExport > maxIntervalConsumptionKwh {
+		if consumptionExport > maxInterval {
 			consumptionExport = 0
 		}
 	} else {
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// intervalHours is the length of one metering interval; a 15-minute kWh delta
+// times four is the average power (kW) over that interval.
+const intervalHours = 0.25
+
+// PeakDemand is the demand measured over a period: the highest 15-minute
+// average power and the figure that is billed (the mean of the top N peaks).
+type PeakDemand struct {
+	PeakKW    float64   `json:"peak_kw"`
+	PeakAt    time.Time `json:"peak_at"` // start of the peak interval
+	BilledKW  float64   `json:"billed_kw"`
+	PeakCount int       `json:"peak_count"`
+	Intervals int       `json:"intervals"`
+}
+
+// ComputePeakDemand finds the peak 15-minute average power of the given meters
+// over [start, end). Readings of all meters are summed per interval first, so
+// a tenant with several meters is billed on their combined demand. With
+// peakCount > 1 the billed value is the mean of the highest peakCount
+// intervals. Returns a zero PeakDemand when there are no readings.
+func ComputePeakDemand(db *sql.DB, meterIDs []int, start, end time.Time, peakCount int) (PeakDemand, error) {
+	if peakCount < 1 {
+		peakCount = 1
+	}
+	result := PeakDemand{PeakCount: peakCount}
+	if len(meterIDs) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, len(meterIDs)+2)
+	for _, id := range meterIDs {
+		args = append(args, id)
+	}
+	args = append(args, start, end)
+	rows, err := db.Query(`
+		SELECT reading_time, consumption_kwh FROM meter_readings
+		WHERE meter_id IN (?`+strings.Repeat(",?", len(meterIDs)-1)+`)
+		AND reading_time >= ? AND reading_time < ?
+	`, args...)
+	if err != nil {
+		return result, fmt.Errorf("failed to query meter readings: %v", err)
+	}
+	defer rows.Close()
+
+	byInterval := make(map[time.Time]float64)
+	for rows.Next() {
+		var t time.Time
+		var kwh float64
+		if err := rows.Scan(&t, &kwh); err != nil {
+			return result, err
+		}
+		byInterval[floorTo15min(t)] += kwh
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	if len(byInterval) == 0 {
+		return result, nil
+	}
+
+	type interval struct {
+		at time.Time
+		kw float64
+	}
+	intervals := make([]interval, 0, len(byInterval))
+	for at, kwh := range byInterval {
+		intervals = append(intervals, interval{at: at, kw: kwh / intervalHours})
+	}
+	// Highest first; ties go to the earliest interval so the result is stable.
+	sort.Slice(intervals, func(i, j int) bool {
+		if intervals[i].kw != intervals[j].kw {
+			return intervals[i].kw > intervals[j].kw
+		}
+		return intervals[i].at.Before(intervals[j].at)
+	})
+
+	result.Intervals = len(intervals)
+	result.PeakKW = intervals[0].kw
+	result.PeakAt = intervals[0].at
+	n := peakCount
+	if n > len(intervals) {
+		n = len(intervals)
+	}
+	sum := 0.0
+	for _, iv := range intervals[:n] {
+		sum += iv.kw
+	}
+	result.BilledKW = sum / float64(n)
+	return result, nil
+}
+
+// demandTariffMeters returns the meters a demand tariff measures: its own
+// meter, or every apartment meter of its user in the building.
+func demandTariffMeters(db *sql.DB, t models.DemandTariff) ([]int, error) {
+	if t.MeterID != nil {
+		return []int{*t.MeterID}, nil
+	}
+	if t.UserID == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`
+		SELECT id FROM meters
+		WHERE user_id = ? AND building_id = ? AND meter_type = 'apartment_meter'
+	`, *t.UserID, t.BuildingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// ComputeDemandTariffPeak measures the peak demand of a tariff's meters over
+// [start, end).
+func ComputeDemandTariffPeak(db *sql.DB, t models.DemandTariff, start, end time.Time) (PeakDemand, error) {
+	meterIDs, err := demandTariffMeters(db, t)
+	if err != nil {
+		return PeakDemand{}, err
+	}
+	return ComputePeakDemand(db, meterIDs, start, end, t.PeakCount)
+}
+
+// appendDemandChargeItems bills the demand tariffs that apply to a user over
+// [start, end): those set on the user and those set on one of the user's
+// meters. The charge is per kW and calendar month, so the period is split into
+// months and each month is charged on its own peak, pro rata for months the
+// period (or the tariff's validity) only partly covers. Returns the cost added.
+func (bs *BillingService) appendDemandChargeItems(items *[]models.InvoiceItem, userID, buildingID int,
+	start, end time.Time, currency string, tr InvoiceTranslations) (float64, error) {
+	rows, err := bs.db.Query(`
+		SELECT id, building_id, user_id, meter_id, name, price_per_kw, peak_count, valid_from, valid_to
+		FROM demand_tariffs
+		WHERE building_id = ? AND is_active = 1
+		  AND (user_id = ? OR meter_id IN (SELECT id FROM meters WHERE user_id = ?))
+		  AND valid_from < ? AND (valid_to = '' OR valid_to >= ?)
+		ORDER BY id
+	`, buildingID, userID, userID, end.Format("2006-01-02"), start.Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query demand_tariffs: %v", err)
+	}
+	var tariffs []models.DemandTariff
+	for rows.Next() {
+		var t models.DemandTariff
+		var uid, mid sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.BuildingID, &uid, &mid, &t.Name, &t.PricePerKW, &t.PeakCount, &t.ValidFrom, &t.ValidTo); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if uid.Valid {
+			v := int(uid.Int64)
+			t.UserID = &v
+		}
+		if mid.Valid {
+			v := int(mid.Int64)
+			t.MeterID = &v
+		}
+		tariffs = append(tariffs, t)
+	}
+	rows.Close()
+
+	total := 0.0
+	for _, t := range tariffs {
+		from, to := start, end
+		if vf, err := time.ParseInLocation("2006-01-02", t.ValidFrom, start.Location()); err == nil && vf.After(from) {
+			from = vf
+		}
+		if t.ValidTo != "" {
+			if vt, err := time.ParseInLocation("2006-01-02", t.ValidTo, start.Location()); err == nil && vt.AddDate(0, 0, 1).Before(to) {
+				to = vt.AddDate(0, 0, 1)
+			}
+		}
+		label := tr.DemandCharge
+		if t.Name != "" {
+			label = t.Name
+		}
+
+		for monthStart := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()); monthStart.Before(to); monthStart = monthStart.AddDate(0, 1, 0) {
+			monthEnd := monthStart.AddDate(0, 1, 0)
+			chunkStart, chunkEnd := from, to
+			if monthStart.After(chunkStart) {
+				chunkStart = monthStart
+			}
+			if monthEnd.Before(chunkEnd) {
+				chunkEnd = monthEnd
+			}
+			if !chunkStart.Before(chunkEnd) {
+				continue
+			}
+			peak, err := ComputeDemandTariffPeak(bs.db, t, chunkStart, chunkEnd)
+			if err != nil {
+				return 0, err
+			}
+			if peak.BilledKW <= 0 {
+				continue
+			}
+			fraction := chunkEnd.Sub(chunkStart).Hours() / monthEnd.Sub(monthStart).Hours()
+			unitPrice := t.PricePerKW * fraction
+			cost := peak.BilledKW * unitPrice
+			total += cost
+
+			detail := fmt.Sprintf("%s %s", tr.PeakAt, peak.PeakAt.In(time.Local).Format("02.01.2006 15:04"))
+			if peak.PeakCount > 1 {
+				detail = fmt.Sprintf(tr.TopPeaksAverage, peak.PeakCount) + fmt.Sprintf(", max %.2f kW ", peak.PeakKW) + detail
+			}
+			description := fmt.Sprintf("%s %s: %.2f kW (%s) × %.2f %s/kW", label, monthStart.Format("01.2006"), peak.BilledKW, detail, t.PricePerKW, currency)
+			if fraction < 0.999 {
+				description += fmt.Sprintf(" × %.0f%%", fraction*100)
+			}
+			*items = append(*items, models.InvoiceItem{
+				Description: description,
+				Quantity:    peak.BilledKW,
+				UnitPrice:   unitPrice,
+				TotalPrice:  cost,
+				ItemType:    "demand_charge",
+			})
+			log.Printf("  Demand charge %s %s: %.3f kW (peak %.3f kW at %s) × %.3f × %.3f = %.3f %s",
+				label, monthStart.Format("01.2006"), peak.BilledKW, peak.PeakKW, peak.PeakAt.Format("2006-01-02 15:04"),
+				t.PricePerKW, fraction, cost, currency)
+		}
+	}
+	return total, nil
+}
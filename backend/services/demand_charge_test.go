@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestPeakDemand(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertUser(t, db, 10, 1)
+	insertZEVMeter(t, db, 1, "Wohnung", "apartment_meter", 1, 10)
+	insertZEVMeter(t, db, 2, "Werkstatt", "apartment_meter", 1, 10)
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	// Both meters are summed per interval: 3 kWh at 10:00 is a 12 kW peak.
+	insertZEVReading(t, db, 1, day.Add(10*time.Hour), 2.0, 0)
+	insertZEVReading(t, db, 2, day.Add(10*time.Hour), 1.0, 0)
+	insertZEVReading(t, db, 1, day.Add(11*time.Hour), 2.5, 0)
+	insertZEVReading(t, db, 1, day.Add(12*time.Hour), 0.5, 0)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	peak, err := ComputePeakDemand(db, []int{1, 2}, start, start.AddDate(0, 1, 0), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !almostEqual(peak.PeakKW, 12) || !peak.PeakAt.Equal(day.Add(10*time.Hour)) || !almostEqual(peak.BilledKW, 11) || peak.Intervals != 3 {
+		t.Fatalf("unexpected peak: %+v", peak)
+	}
+
+	if _, err := db.Exec(`INSERT INTO demand_tariffs (building_id, user_id, name, price_per_kw, peak_count, valid_from) VALUES (1, 10, '', 10, 2, '2026-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+	bs := NewBillingService(db)
+	var items []models.InvoiceItem
+	// Half a month is charged pro rata: 11 kW × 10 CHF × 15/31.
+	total, err := bs.appendDemandChargeItems(&items, 10, 1, start, start.AddDate(0, 0, 15), "CHF", GetTranslations("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ItemType != "demand_charge" || !almostEqual(total, 110.0*15/31) {
+		t.Fatalf("total %.3f items %+v", total, items)
+	}
+}
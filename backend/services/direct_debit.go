@@ -0,0 +1,449 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Mandate states and the payment status of an invoice submitted for
+// collection but not yet confirmed by the bank.
+const (
+	MandateActive            = "active"
+	MandateRevoked           = "revoked"
+	PaymentCollectionPending = "collection_pending"
+)
+
+// MatchDirectDebit marks a bank credit tied to an invoice through the
+// end-to-end ID of its direct-debit transaction.
+const MatchDirectDebit = "direct_debit"
+
+// debitScheme holds the pain.008 codes of a direct-debit scheme.
+type debitScheme struct {
+	namespace       string
+	serviceLevel    painCode
+	localInstrument painCode
+	sequenceType    string // SEPA only
+	creditorScheme  string // SchmeNm/Prtry of the creditor identifier
+	swiss           bool   // agents by Swiss clearing number instead of BIC
+	currencies      []string
+}
+
+const swissPain008Namespace = "http://www.six-interbank-clearing.com/de/pain.008.001.02.ch.03.xsd"
+
+// debitSchemes are the supported collection schemes: LSV+ and CH-DD (Swiss
+// pain.008.001.02.ch.03) and SEPA Core (pain.008.001.02).
+var debitSchemes = map[string]debitScheme{
+	"lsv": {
+		namespace:       swissPain008Namespace,
+		serviceLevel:    painCode{Proprietary: "CHTA"},
+		localInstrument: painCode{Proprietary: "LSV+"},
+		creditorScheme:  "CHLS",
+		swiss:           true,
+		currencies:      []string{"CHF", "EUR"},
+	},
+	"chdd": {
+		namespace:       swissPain008Namespace,
+		serviceLevel:    painCode{Proprietary: "CHDD"},
+		localInstrument: painCode{Proprietary: "DDCOR1"},
+		creditorScheme:  "CHDD",
+		swiss:           true,
+		currencies:      []string{"CHF", "EUR"},
+	},
+	"sepa": {
+		namespace:       "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02",
+		serviceLevel:    painCode{Code: "SEPA"},
+		localInstrument: painCode{Code: "CORE"},
+		sequenceType:    "RCUR",
+		creditorScheme:  "SEPA",
+		currencies:      []string{"EUR"},
+	},
+}
+
+// ValidDebitScheme reports whether scheme is a supported collection scheme.
+func ValidDebitScheme(scheme string) bool {
+	_, ok := debitSchemes[scheme]
+	return ok
+}
+
+// pain.008.001.02 document, shared by the Swiss and SEPA variants.
+type painDocument struct {
+	XMLName    xml.Name       `xml:"Document"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Initiation painInitiation `xml:"CstmrDrctDbtInitn"`
+}
+
+type painInitiation struct {
+	MessageID     string          `xml:"GrpHdr>MsgId"`
+	Created       string          `xml:"GrpHdr>CreDtTm"`
+	Count         int             `xml:"GrpHdr>NbOfTxs"`
+	ControlSum    string          `xml:"GrpHdr>CtrlSum"`
+	InitiatorName string          `xml:"GrpHdr>InitgPty>Nm"`
+	PaymentInfo   painPaymentInfo `xml:"PmtInf"`
+}
+
+type painCode struct {
+	Code        string `xml:"Cd,omitempty"`
+	Proprietary string `xml:"Prtry,omitempty"`
+}
+
+// painAgent names a bank either by clearing member or as "other" (nil
+// pointers keep the unused alternative out of the XML).
+type painAgent struct {
+	ClearingMember *painClearingMember `xml:"FinInstnId>ClrSysMmbId,omitempty"`
+	Other          *painOther          `xml:"FinInstnId>Othr,omitempty"`
+}
+
+type painClearingMember struct {
+	System   string `xml:"ClrSysId>Cd"`
+	MemberID string `xml:"MmbId"`
+}
+
+type painOther struct {
+	ID string `xml:"Id"`
+}
+
+type painAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type painPaymentInfo struct {
+	ID              string            `xml:"PmtInfId"`
+	Method          string            `xml:"PmtMtd"`
+	BatchBooking    bool              `xml:"BtchBookg"`
+	Count           int               `xml:"NbOfTxs"`
+	ControlSum      string            `xml:"CtrlSum"`
+	ServiceLevel    painCode          `xml:"PmtTpInf>SvcLvl"`
+	LocalInstrument painCode          `xml:"PmtTpInf>LclInstrm"`
+	SequenceType    string            `xml:"PmtTpInf>SeqTp,omitempty"`
+	CollectionDate  string            `xml:"ReqdColltnDt"`
+	CreditorName    string            `xml:"Cdtr>Nm"`
+	CreditorIBAN    string            `xml:"CdtrAcct>Id>IBAN"`
+	CreditorAgent   painAgent         `xml:"CdtrAgt"`
+	CreditorID      string            `xml:"CdtrSchmeId>Id>PrvtId>Othr>Id"`
+	CreditorScheme  string            `xml:"CdtrSchmeId>Id>PrvtId>Othr>SchmeNm>Prtry"`
+	Transactions    []painTransaction `xml:"DrctDbtTxInf"`
+}
+
+type painTransaction struct {
+	InstructionID string     `xml:"PmtId>InstrId"`
+	EndToEndID    string     `xml:"PmtId>EndToEndId"`
+	Amount        painAmount `xml:"InstdAmt"`
+	MandateID     string     `xml:"DrctDbtTx>MndtRltdInf>MndtId"`
+	SignatureDate string     `xml:"DrctDbtTx>MndtRltdInf>DtOfSgntr"`
+	DebtorAgent   painAgent  `xml:"DbtrAgt"`
+	DebtorName    string     `xml:"Dbtr>Nm"`
+	DebtorIBAN    string     `xml:"DbtrAcct>Id>IBAN"`
+	Remittance    string     `xml:"RmtInf>Ustrd"`
+}
+
+// validIBAN checks the length and ISO 7064 mod-97 check digits of an IBAN
+// (blanks already removed).
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	mod := 0
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			mod = (mod*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			mod = (mod*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return mod == 1
+}
+
+// agentFor identifies the debtor's or creditor's bank: by the clearing
+// number embedded in a CH/LI IBAN for the Swiss schemes, else NOTPROVIDED
+// (IBAN-only SEPA).
+func agentFor(s debitScheme, iban string) painAgent {
+	if s.swiss && (strings.HasPrefix(iban, "CH") || strings.HasPrefix(iban, "LI")) && len(iban) >= 9 {
+		iid := strings.TrimLeft(iban[4:9], "0")
+		return painAgent{ClearingMember: &painClearingMember{System: "CHBCC", MemberID: iid}}
+	}
+	return painAgent{Other: &painOther{ID: "NOTPROVIDED"}}
+}
+
+// DirectDebitRequest selects the invoices of one collection file.
+type DirectDebitRequest struct {
+	InvoiceIDs     []int
+	Scheme         string
+	CollectionDate string // YYYY-MM-DD
+	CreditorName   string
+	CreditorIBAN   string
+	CreditorID     string // LSV+ ID, CH-DD participant ID or SEPA creditor identifier
+}
+
+// SkippedInvoice is a selected invoice left out of a collection file.
+type SkippedInvoice struct {
+	InvoiceID     int    `json:"invoice_id"`
+	InvoiceNumber string `json:"invoice_number,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// CreateDirectDebitExport bundles the selected unpaid invoices of debtors with
+// an active mandate into a pain.008 file. Each included invoice is marked
+// collection_pending until a camt import or a manual confirmation books it.
+func CreateDirectDebitExport(db *sql.DB, req DirectDebitRequest) (*models.DirectDebitExport, []SkippedInvoice, error) {
+	scheme, ok := debitSchemes[req.Scheme]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown direct debit scheme %q", req.Scheme)
+	}
+	if _, err := time.Parse("2006-01-02", req.CollectionDate); err != nil {
+		return nil, nil, fmt.Errorf("invalid collection date %q", req.CollectionDate)
+	}
+	creditorIBAN := normalizeReference(req.CreditorIBAN)
+	if !validIBAN(creditorIBAN) {
+		return nil, nil, fmt.Errorf("invalid creditor IBAN")
+	}
+	if strings.TrimSpace(req.CreditorName) == "" || strings.TrimSpace(req.CreditorID) == "" {
+		return nil, nil, fmt.Errorf("creditor name and creditor ID are required")
+	}
+
+	now := time.Now()
+	messageID := fmt.Sprintf("DD-%d", now.UnixNano()/int64(time.Millisecond))
+	info := painPaymentInfo{
+		ID:              messageID + "-1",
+		Method:          "DD",
+		BatchBooking:    true,
+		ServiceLevel:    scheme.serviceLevel,
+		LocalInstrument: scheme.localInstrument,
+		SequenceType:    scheme.sequenceType,
+		CollectionDate:  req.CollectionDate,
+		CreditorName:    truncate(strings.TrimSpace(req.CreditorName), 70),
+		CreditorIBAN:    creditorIBAN,
+		CreditorAgent:   agentFor(scheme, creditorIBAN),
+		CreditorID:      strings.TrimSpace(req.CreditorID),
+		CreditorScheme:  scheme.creditorScheme,
+	}
+
+	skipped := []SkippedInvoice{}
+	included := []int{}
+	currency := ""
+	total := 0.0
+	for _, id := range req.InvoiceIDs {
+		var number, status, documentType, paymentStatus, invCurrency, periodStart, periodEnd string
+		var firstName, lastName, holder, iban string
+		var amount, paid float64
+		var mandateID, signatureDate, mandateScheme sql.NullString
+		err := db.QueryRow(`
+			SELECT i.invoice_number, i.status, COALESCE(i.document_type, 'invoice'),
+			       COALESCE(i.payment_status, 'unpaid'), i.currency, i.total_amount, COALESCE(i.paid_amount, 0),
+			       date(i.period_start), date(i.period_end),
+			       COALESCE(u.first_name, ''), COALESCE(u.last_name, ''),
+			       COALESCE(u.bank_account_holder, ''), COALESCE(u.bank_iban, ''),
+			       m.mandate_id, date(m.signature_date), m.scheme
+			FROM invoices i
+			LEFT JOIN users u ON u.id = i.user_id
+			LEFT JOIN direct_debit_mandates m ON m.id = (
+				SELECT id FROM direct_debit_mandates
+				WHERE user_id = i.user_id AND status = ? AND signature_date <= ?
+				ORDER BY signature_date DESC, id DESC LIMIT 1
+			)
+			WHERE i.id = ?
+		`, MandateActive, req.CollectionDate, id).Scan(&number, &status, &documentType, &paymentStatus,
+			&invCurrency, &amount, &paid, &periodStart, &periodEnd, &firstName, &lastName, &holder, &iban,
+			&mandateID, &signatureDate, &mandateScheme)
+		if err == sql.ErrNoRows {
+			skipped = append(skipped, SkippedInvoice{InvoiceID: id, Reason: "Invoice not found"})
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		skip := func(reason string) {
+			skipped = append(skipped, SkippedInvoice{InvoiceID: id, InvoiceNumber: number, Reason: reason})
+		}
+		outstanding := math.Round((amount-paid)*100) / 100
+		debtorIBAN := normalizeReference(iban)
+		invCurrency = strings.ToUpper(invCurrency)
+		switch {
+		case status != InvoiceStatusIssued || IsCreditDocument(documentType):
+			skip(fmt.Sprintf("Invoice is %s", status))
+		case paymentStatus == PaymentCollectionPending:
+			skip("Already submitted for collection")
+		case paymentStatus == "paid" || outstanding <= zeroBillEpsilon:
+			skip("Invoice is already paid")
+		case !mandateID.Valid:
+			skip("No active mandate signed before the collection date")
+		case mandateScheme.String != req.Scheme:
+			skip(fmt.Sprintf("Mandate is for the %s scheme", mandateScheme.String))
+		case !validIBAN(debtorIBAN):
+			skip("Tenant has no valid bank IBAN")
+		case !containsString(scheme.currencies, invCurrency):
+			skip(fmt.Sprintf("Currency %s is not supported by the scheme", invCurrency))
+		case currency != "" && invCurrency != currency:
+			skip(fmt.Sprintf("Currency %s differs from the file currency %s", invCurrency, currency))
+		default:
+			currency = invCurrency
+			debtor := holder
+			if strings.TrimSpace(debtor) == "" {
+				debtor = firstName + " " + lastName
+			}
+			info.Transactions = append(info.Transactions, painTransaction{
+				InstructionID: truncate(fmt.Sprintf("%s-%d", messageID, len(info.Transactions)+1), 35),
+				EndToEndID:    truncate(number, 35),
+				Amount:        painAmount{Currency: invCurrency, Value: strconv.FormatFloat(outstanding, 'f', 2, 64)},
+				MandateID:     mandateID.String,
+				SignatureDate: signatureDate.String,
+				DebtorAgent:   agentFor(scheme, debtorIBAN),
+				DebtorName:    truncate(strings.TrimSpace(debtor), 70),
+				DebtorIBAN:    debtorIBAN,
+				Remittance:    truncate(fmt.Sprintf("%s %s - %s", number, periodStart, periodEnd), 140),
+			})
+			total += outstanding
+			included = append(included, id)
+		}
+	}
+	if len(included) == 0 {
+		return nil, skipped, fmt.Errorf("none of the selected invoices can be collected")
+	}
+
+	total = math.Round(total*100) / 100
+	controlSum := strconv.FormatFloat(total, 'f', 2, 64)
+	info.Count, info.ControlSum = len(info.Transactions), controlSum
+	doc := painDocument{
+		Namespace: scheme.namespace,
+		Initiation: painInitiation{
+			MessageID:     messageID,
+			Created:       now.Format("2006-01-02T15:04:05"),
+			Count:         len(info.Transactions),
+			ControlSum:    controlSum,
+			InitiatorName: info.CreditorName,
+			PaymentInfo:   info,
+		},
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	content := xml.Header + string(body) + "\n"
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO direct_debit_exports (
+			message_id, scheme, collection_date, creditor_name, creditor_iban, creditor_id,
+			currency, transactions, total_amount, xml
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, messageID, req.Scheme, req.CollectionDate, info.CreditorName, creditorIBAN, info.CreditorID,
+		currency, len(included), total, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	exportID, _ := res.LastInsertId()
+	for _, id := range included {
+		if _, err := tx.Exec(`UPDATE invoices SET payment_status = ?, direct_debit_export_id = ? WHERE id = ?`,
+			PaymentCollectionPending, exportID, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Direct debit: %s (%s) with %d invoices, %s %.2f, collection on %s",
+		messageID, req.Scheme, len(included), currency, total, req.CollectionDate)
+
+	return &models.DirectDebitExport{
+		ID:             int(exportID),
+		MessageID:      messageID,
+		Scheme:         req.Scheme,
+		CollectionDate: req.CollectionDate,
+		CreditorName:   info.CreditorName,
+		CreditorIBAN:   creditorIBAN,
+		CreditorID:     info.CreditorID,
+		Currency:       currency,
+		Transactions:   len(included),
+		TotalAmount:    total,
+		InvoiceIDs:     included,
+		CreatedAt:      now,
+	}, skipped, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SettleDirectDebit resolves the pending invoices of a collection file (all,
+// or only invoiceIDs): collected ones are booked as paid on the collection
+// date, returned ones go back to unpaid/partial. Returns how many changed.
+func SettleDirectDebit(db *sql.DB, exportID int, invoiceIDs []int, collected bool) (int, error) {
+	var collectionDate, messageID string
+	if err := db.QueryRow(`SELECT date(collection_date), message_id FROM direct_debit_exports WHERE id = ?`, exportID).Scan(&collectionDate, &messageID); err != nil {
+		return 0, err
+	}
+
+	query := `SELECT id, total_amount - COALESCE(paid_amount, 0) FROM invoices WHERE direct_debit_export_id = ? AND payment_status = ?`
+	args := []interface{}{exportID, PaymentCollectionPending}
+	if len(invoiceIDs) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(`, ?`, len(invoiceIDs)-1) + `)`
+		for _, id := range invoiceIDs {
+			args = append(args, id)
+		}
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id     int
+		amount float64
+	}
+	var list []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.amount); err == nil {
+			list = append(list, p)
+		}
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, p := range list {
+		if collected {
+			_, err = recordPayment(tx, models.Payment{
+				InvoiceID:   p.id,
+				PaymentDate: collectionDate,
+				Amount:      p.amount,
+				Method:      PaymentMethodDirectDebit,
+				Reference:   messageID,
+				Source:      PaymentSourceDirectDebit,
+			})
+		} else {
+			_, err = tx.Exec(`
+				UPDATE invoices SET payment_status = CASE WHEN COALESCE(paid_amount, 0) > 0 THEN 'partial' ELSE 'unpaid' END
+				WHERE id = ?
+			`, p.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(list), tx.Commit()
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestCreateDirectDebitExport(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, bank_iban, bank_account_holder) VALUES (10,'Anna','Muster','a@b.c',1,'CH93 0076 2011 6238 5295 7','Anna Muster'), (11,'Hans','Meier','h@b.c',1,'','')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO direct_debit_mandates (user_id, mandate_id, scheme, signature_date) VALUES (10, 'MD-10', 'lsv', '2026-01-15')`); err != nil {
+		t.Fatal(err)
+	}
+	items := []models.InvoiceItem{{Description: "Normal power", TotalPrice: 45.1, ItemType: "normal_power"}}
+	withMandate, _, _ := bs.insertInvoiceWithItems("INV-2026-1-10-1", 10, 1, "2026-01-01", "2026-01-31", 45.1, 45.1, 0, 0, false, "CHF", false, items)
+	without, _, _ := bs.insertInvoiceWithItems("INV-2026-1-11-2", 11, 1, "2026-01-01", "2026-01-31", 45.1, 45.1, 0, 0, false, "CHF", false, items)
+
+	export, skipped, err := CreateDirectDebitExport(db, DirectDebitRequest{
+		InvoiceIDs:     []int{int(withMandate), int(without)},
+		Scheme:         "lsv",
+		CollectionDate: "2026-02-20",
+		CreditorName:   "ZEV Sonnenhof",
+		CreditorIBAN:   "CH56 0483 5012 3456 7800 9",
+		CreditorID:     "ABC1W",
+	})
+	if err != nil {
+		t.Fatalf("CreateDirectDebitExport: %v", err)
+	}
+	if export.Transactions != 1 || !almostEqual(export.TotalAmount, 45.1) || len(skipped) != 1 || skipped[0].InvoiceID != int(without) {
+		t.Fatalf("export = %+v, skipped = %+v", export, skipped)
+	}
+
+	var content string
+	db.QueryRow(`SELECT xml FROM direct_debit_exports WHERE id = ?`, export.ID).Scan(&content)
+	for _, want := range []string{"<MndtId>MD-10</MndtId>", "<EndToEndId>INV-2026-1-10-1</EndToEndId>", `<InstdAmt Ccy="CHF">45.10</InstdAmt>`, "<Prtry>LSV+</Prtry>", "<MmbId>762</MmbId>"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("pain.008 lacks %s", want)
+		}
+	}
+
+	var status string
+	db.QueryRow(`SELECT payment_status FROM invoices WHERE id = ?`, withMandate).Scan(&status)
+	if status != PaymentCollectionPending {
+		t.Errorf("status after export = %s", status)
+	}
+	if n, err := SettleDirectDebit(db, export.ID, nil, true); err != nil || n != 1 {
+		t.Fatalf("SettleDirectDebit = %d, %v", n, err)
+	}
+	db.QueryRow(`SELECT payment_status FROM invoices WHERE id = ?`, withMandate).Scan(&status)
+	if status != "paid" {
+		t.Errorf("status after confirmation = %s", status)
+	}
+}
@@ -0,0 +1,555 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Reminder levels of the dunning process (Mahnstufen).
+const (
+	DunningReminder1   = 1
+	DunningReminder2   = 2
+	DunningFinalNotice = 3
+)
+
+// DocumentTypeReminder marks a rendered reminder. Reminders are stored in
+// invoice_reminders, never as invoices; the type only drives the PDF layout.
+const DocumentTypeReminder = "reminder"
+
+// reminderStatus is the status badge printed on a reminder.
+const reminderStatus = "overdue"
+
+// DefaultDunningSettings are the terms of a building without a saved row.
+func DefaultDunningSettings(buildingID int) models.DunningSettings {
+	return models.DunningSettings{
+		BuildingID:           buildingID,
+		PaymentTermsDays:     30,
+		Reminder1AfterDays:   10,
+		Reminder2AfterDays:   14,
+		FinalNoticeAfterDays: 14,
+		ReminderDeadlineDays: 10,
+	}
+}
+
+// LoadDunningSettings returns the building's payment terms and reminder
+// schedule, or the defaults when none are saved.
+func LoadDunningSettings(db *sql.DB, buildingID int) models.DunningSettings {
+	s := DefaultDunningSettings(buildingID)
+	err := db.QueryRow(`
+		SELECT id, building_id, payment_terms_days, reminder1_after_days, reminder2_after_days,
+		       final_notice_after_days, reminder_deadline_days, reminder1_fee, reminder2_fee,
+		       final_notice_fee, auto_escalate, auto_send_email, sender_name, sender_address,
+		       sender_city, sender_zip, sender_country, bank_name, bank_iban, bank_account_holder,
+		       created_at, updated_at
+		FROM dunning_settings WHERE building_id = ?
+	`, buildingID).Scan(
+		&s.ID, &s.BuildingID, &s.PaymentTermsDays, &s.Reminder1AfterDays, &s.Reminder2AfterDays,
+		&s.FinalNoticeAfterDays, &s.ReminderDeadlineDays, &s.Reminder1Fee, &s.Reminder2Fee,
+		&s.FinalNoticeFee, &s.AutoEscalate, &s.AutoSendEmail, &s.SenderName, &s.SenderAddress,
+		&s.SenderCity, &s.SenderZip, &s.SenderCountry, &s.BankName, &s.BankIBAN, &s.BankAccountHolder,
+		&s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("WARNING: Failed to load dunning settings for building %d: %v", buildingID, err)
+		}
+		return DefaultDunningSettings(buildingID)
+	}
+	return s
+}
+
+// PaymentDueDate is the due date (YYYY-MM-DD) of a document issued on the
+// given day under the building's payment terms.
+func PaymentDueDate(db *sql.DB, buildingID int, issued time.Time) string {
+	return issued.AddDate(0, 0, LoadDunningSettings(db, buildingID).PaymentTermsDays).Format("2006-01-02")
+}
+
+// reminderFee is the fee charged with a reminder of the given level.
+func reminderFee(s models.DunningSettings, level int) float64 {
+	switch level {
+	case DunningReminder1:
+		return s.Reminder1Fee
+	case DunningReminder2:
+		return s.Reminder2Fee
+	case DunningFinalNotice:
+		return s.FinalNoticeFee
+	}
+	return 0
+}
+
+// reminderTitle is the document title of a reminder level.
+func reminderTitle(tr InvoiceTranslations, level int) string {
+	switch level {
+	case DunningReminder2:
+		return tr.SecondReminder
+	case DunningFinalNotice:
+		return tr.FinalNotice
+	}
+	return tr.PaymentReminder
+}
+
+// reminderNumber derives the reminder number from the invoice it chases:
+// "R<level>" prefix, same year/building/user/timestamp segments.
+func reminderNumber(invoiceNumber string, level int) string {
+	base := invoiceNumber
+	if i := strings.Index(base, "-"); i >= 0 {
+		base = base[i+1:]
+	}
+	return fmt.Sprintf("R%d-%s", level, base)
+}
+
+// nextEscalation returns the next reminder level of an invoice and the day it
+// becomes due: level 1 counts from the due date, levels 2 and 3 from the
+// previous reminder. Level 0 means the final notice has been sent.
+func nextEscalation(s models.DunningSettings, dueDate, lastReminder string, level int) (int, time.Time) {
+	due, err := time.ParseInLocation("2006-01-02", dueDate, time.Local)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	base := due
+	if last, err := time.ParseInLocation("2006-01-02", storedDay(lastReminder), time.Local); err == nil {
+		base = last
+	}
+	switch level {
+	case 0:
+		return DunningReminder1, due.AddDate(0, 0, s.Reminder1AfterDays)
+	case DunningReminder1:
+		return DunningReminder2, base.AddDate(0, 0, s.Reminder2AfterDays)
+	case DunningReminder2:
+		return DunningFinalNotice, base.AddDate(0, 0, s.FinalNoticeAfterDays)
+	}
+	return 0, time.Time{}
+}
+
+// OutstandingInvoice is one unpaid or partly paid document in the dunning
+// overview.
+type OutstandingInvoice struct {
+	InvoiceID      int     `json:"invoice_id"`
+	InvoiceNumber  string  `json:"invoice_number"`
+	DocumentType   string  `json:"document_type"`
+	UserID         int     `json:"user_id"`
+	UserName       string  `json:"user_name"`
+	BuildingID     int     `json:"building_id"`
+	BuildingName   string  `json:"building_name"`
+	Currency       string  `json:"currency"`
+	TotalAmount    float64 `json:"total_amount"`
+	PaidAmount     float64 `json:"paid_amount"`
+	Outstanding    float64 `json:"outstanding"`
+	Fees           float64 `json:"fees"` // reminder fees charged so far
+	DueDate        string  `json:"due_date"`
+	DaysOverdue    int     `json:"days_overdue"` // 0 while not yet due
+	PaymentStatus  string  `json:"payment_status"`
+	DunningLevel   int     `json:"dunning_level"`
+	LastReminderAt string  `json:"last_reminder_at,omitempty"`
+	NextLevel      int     `json:"next_level,omitempty"` // 0 once the final notice was sent
+	NextLevelDate  string  `json:"next_level_date,omitempty"`
+	EscalationDue  bool    `json:"escalation_due"`
+}
+
+// ListOutstanding returns every issued, payable document that is not fully
+// paid (buildingID 0 = all buildings), oldest due date first. With
+// overdueOnly, documents not yet past their due date are left out.
+func ListOutstanding(db *sql.DB, buildingID int, today time.Time, overdueOnly bool) ([]OutstandingInvoice, error) {
+	query := `
+		SELECT i.id, i.invoice_number, COALESCE(i.document_type, 'invoice'), i.user_id,
+		       COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), i.building_id, COALESCE(b.name, ''),
+		       i.currency, i.total_amount, COALESCE(i.paid_amount, 0), COALESCE(i.payment_status, 'unpaid'),
+		       COALESCE(date(i.due_date), date(i.generated_at, '+30 days')),
+		       COALESCE(i.dunning_level, 0), COALESCE(date(i.last_reminder_at), ''),
+		       (SELECT COALESCE(SUM(r.fee), 0) FROM invoice_reminders r WHERE r.invoice_id = i.id)
+		FROM invoices i
+		LEFT JOIN users u ON u.id = i.user_id
+		LEFT JOIN buildings b ON b.id = i.building_id
+		WHERE i.status = ? AND COALESCE(i.document_type, 'invoice') != ?
+		AND COALESCE(i.payment_status, 'unpaid') != 'paid' AND i.total_amount > 0
+	`
+	args := []interface{}{InvoiceStatusIssued, DocumentTypeCreditNote}
+	if buildingID > 0 {
+		query += ` AND i.building_id = ?`
+		args = append(args, buildingID)
+	}
+	query += ` ORDER BY COALESCE(date(i.due_date), date(i.generated_at, '+30 days')), i.id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	settings := map[int]models.DunningSettings{}
+	list := []OutstandingInvoice{}
+	for rows.Next() {
+		var o OutstandingInvoice
+		var firstName, lastName string
+		if err := rows.Scan(&o.InvoiceID, &o.InvoiceNumber, &o.DocumentType, &o.UserID,
+			&firstName, &lastName, &o.BuildingID, &o.BuildingName,
+			&o.Currency, &o.TotalAmount, &o.PaidAmount, &o.PaymentStatus, &o.DueDate,
+			&o.DunningLevel, &o.LastReminderAt, &o.Fees); err != nil {
+			return nil, err
+		}
+		o.Outstanding = math.Round((o.TotalAmount-o.PaidAmount)*100) / 100
+		if o.Outstanding <= zeroBillEpsilon {
+			continue
+		}
+		o.UserName = strings.TrimSpace(firstName + " " + lastName)
+		if due, err := time.ParseInLocation("2006-01-02", o.DueDate, time.Local); err == nil && day.After(due) {
+			o.DaysOverdue = int(day.Sub(due).Hours() / 24)
+		}
+		if overdueOnly && o.DaysOverdue == 0 {
+			continue
+		}
+
+		s, ok := settings[o.BuildingID]
+		if !ok {
+			s = LoadDunningSettings(db, o.BuildingID)
+			settings[o.BuildingID] = s
+		}
+		if next, at := nextEscalation(s, o.DueDate, o.LastReminderAt, o.DunningLevel); next > 0 {
+			o.NextLevel = next
+			o.NextLevelDate = at.Format("2006-01-02")
+			// A pending direct debit is not chased until the bank reports back.
+			o.EscalationDue = !day.Before(at) && o.PaymentStatus != PaymentCollectionPending
+		}
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// DunningService issues payment reminders and escalates overdue invoices
+// once a day for buildings with automatic escalation enabled.
+type DunningService struct {
+	db           *sql.DB
+	pdfGenerator *PDFGenerator
+	emailAlerter *EmailAlerter // optional — reminders are only e-mailed when set
+	hour         int
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+}
+
+func NewDunningService(db *sql.DB, pdfGenerator *PDFGenerator, hour int) *DunningService {
+	if hour < 0 || hour > 23 {
+		hour = 8
+	}
+	return &DunningService{
+		db:           db,
+		pdfGenerator: pdfGenerator,
+		hour:         hour,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// SetEmailAlerter wires the SMTP sender used to e-mail reminders.
+func (ds *DunningService) SetEmailAlerter(ea *EmailAlerter) {
+	ds.emailAlerter = ea
+}
+
+func (ds *DunningService) Start() {
+	log.Printf("=== Dunning Service starting (daily escalation at %02d:00) ===", ds.hour)
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), ds.hour, 0, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		select {
+		case <-time.After(next.Sub(now)):
+			ds.RunOnce(time.Now())
+		case <-ds.stopChan:
+			log.Println("Dunning Service stopped")
+			return
+		}
+	}
+}
+
+func (ds *DunningService) Stop() {
+	ds.stopOnce.Do(func() { close(ds.stopChan) })
+}
+
+// DunningRunResult summarises one escalation pass.
+type DunningRunResult struct {
+	Overdue         int                      `json:"overdue"`
+	RemindersIssued int                      `json:"reminders_issued"`
+	EmailsSent      int                      `json:"emails_sent"`
+	Reminders       []models.InvoiceReminder `json:"reminders"`
+	Failures        []string                 `json:"failures"`
+}
+
+// RunOnce sends the next reminder for every overdue invoice whose escalation
+// is due, in buildings with auto_escalate enabled.
+func (ds *DunningService) RunOnce(today time.Time) DunningRunResult {
+	result := DunningRunResult{Reminders: []models.InvoiceReminder{}, Failures: []string{}}
+	overdue, err := ListOutstanding(ds.db, 0, today, true)
+	if err != nil {
+		log.Printf("Dunning: failed to list outstanding invoices: %v", err)
+		result.Failures = append(result.Failures, err.Error())
+		return result
+	}
+	result.Overdue = len(overdue)
+
+	settings := map[int]models.DunningSettings{}
+	for _, o := range overdue {
+		if !o.EscalationDue {
+			continue
+		}
+		s, ok := settings[o.BuildingID]
+		if !ok {
+			s = LoadDunningSettings(ds.db, o.BuildingID)
+			settings[o.BuildingID] = s
+		}
+		if !s.AutoEscalate {
+			continue
+		}
+		reminder, err := ds.IssueReminder(o.InvoiceID, nil, nil, s.AutoSendEmail, today)
+		if err != nil {
+			log.Printf("Dunning: reminder for invoice %s failed: %v", o.InvoiceNumber, err)
+			result.Failures = append(result.Failures, fmt.Sprintf("%s: %v", o.InvoiceNumber, err))
+			continue
+		}
+		result.RemindersIssued++
+		if reminder.EmailedAt != nil {
+			result.EmailsSent++
+		}
+		result.Reminders = append(result.Reminders, *reminder)
+	}
+
+	if result.RemindersIssued > 0 || len(result.Failures) > 0 {
+		details, _ := json.Marshal(result)
+		action := "Dunning Run"
+		if len(result.Failures) > 0 {
+			// "Failed" in the action surfaces it in the e-mail alerter's digest.
+			action = "Dunning Run Failed"
+		}
+		ds.db.Exec(`INSERT INTO admin_logs (action, details, ip_address) VALUES (?, ?, 'system')`, action, string(details))
+	}
+	log.Printf("Dunning: %d overdue, %d reminders issued, %d e-mailed, %d failures",
+		result.Overdue, result.RemindersIssued, result.EmailsSent, len(result.Failures))
+	return result
+}
+
+// IssueReminder sends the next reminder level for an invoice: the reminder
+// is recorded, rendered to PDF (with a QR-bill for the amount due, fees
+// included) and optionally e-mailed to the tenant. sender/banking override
+// the building's dunning settings when given.
+func (ds *DunningService) IssueReminder(invoiceID int, sender *SenderInfo, banking *BankingInfo, sendEmail bool, today time.Time) (*models.InvoiceReminder, error) {
+	var inv models.Invoice
+	var generatedAt time.Time
+	var paymentStatus string
+	err := ds.db.QueryRow(`
+		SELECT invoice_number, user_id, building_id, period_start, period_end, total_amount,
+		       COALESCE(paid_amount, 0), currency, status, COALESCE(document_type, 'invoice'),
+		       COALESCE(payment_status, 'unpaid'), generated_at,
+		       COALESCE(date(due_date), date(generated_at, '+30 days')), COALESCE(dunning_level, 0)
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&inv.InvoiceNumber, &inv.UserID, &inv.BuildingID, &inv.PeriodStart, &inv.PeriodEnd,
+		&inv.TotalAmount, &inv.PaidAmount, &inv.Currency, &inv.Status, &inv.DocumentType,
+		&paymentStatus, &generatedAt, &inv.DueDate, &inv.DunningLevel)
+	if err != nil {
+		return nil, err
+	}
+	outstanding := math.Round((inv.TotalAmount-inv.PaidAmount)*100) / 100
+	if inv.Status != InvoiceStatusIssued || IsCreditDocument(inv.DocumentType) {
+		return nil, fmt.Errorf("invoice %s is %s and cannot be reminded", inv.InvoiceNumber, inv.Status)
+	}
+	if paymentStatus == "paid" || outstanding <= zeroBillEpsilon {
+		return nil, fmt.Errorf("invoice %s is already paid", inv.InvoiceNumber)
+	}
+	level := inv.DunningLevel + 1
+	if level > DunningFinalNotice {
+		return nil, fmt.Errorf("the final notice for invoice %s has already been sent", inv.InvoiceNumber)
+	}
+
+	s := LoadDunningSettings(ds.db, inv.BuildingID)
+	fee := reminderFee(s, level)
+	deadline := today.AddDate(0, 0, s.ReminderDeadlineDays).Format("2006-01-02")
+
+	user := loadInvoiceUser(ds.db, inv.UserID)
+	language := "de"
+	if user != nil {
+		language = user.Language
+	}
+	tr := GetTranslations(language)
+
+	// Earlier reminder fees stay due and are listed again.
+	items := []models.InvoiceItem{{
+		Description: fmt.Sprintf("%s (%s %s)", tr.OutstandingAmount, tr.InvoiceLabel, inv.InvoiceNumber),
+		TotalPrice:  outstanding,
+		ItemType:    "reminder_outstanding",
+	}}
+	totalDue := outstanding
+	rows, err := ds.db.Query(`SELECT level, fee FROM invoice_reminders WHERE invoice_id = ? AND fee > 0 ORDER BY level`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var prevLevel int
+		var prevFee float64
+		if err := rows.Scan(&prevLevel, &prevFee); err == nil {
+			items = append(items, models.InvoiceItem{
+				Description: fmt.Sprintf("%s (%s)", tr.ReminderFee, reminderTitle(tr, prevLevel)),
+				TotalPrice:  prevFee,
+				ItemType:    "reminder_fee",
+			})
+			totalDue += prevFee
+		}
+	}
+	rows.Close()
+	if fee > 0 {
+		items = append(items, models.InvoiceItem{
+			Description: fmt.Sprintf("%s (%s)", tr.ReminderFee, reminderTitle(tr, level)),
+			TotalPrice:  fee,
+			ItemType:    "reminder_fee",
+		})
+		totalDue += fee
+	}
+	totalDue = math.Round(totalDue*100) / 100
+
+	reminder := &models.InvoiceReminder{
+		InvoiceID:         invoiceID,
+		Level:             level,
+		ReminderNumber:    reminderNumber(inv.InvoiceNumber, level),
+		OutstandingAmount: outstanding,
+		Fee:               fee,
+		TotalDue:          totalDue,
+		Deadline:          deadline,
+		CreatedAt:         today,
+	}
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op once committed
+	res, err := tx.Exec(`
+		INSERT INTO invoice_reminders (invoice_id, level, reminder_number, outstanding_amount, fee, total_due, deadline, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, invoiceID, level, reminder.ReminderNumber, outstanding, fee, totalDue, deadline, today.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record reminder: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE invoices SET dunning_level = ?, last_reminder_at = ? WHERE id = ?`,
+		level, today.Format("2006-01-02 15:04:05"), invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to update dunning level: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	reminder.ID = int(id)
+	log.Printf("Dunning: %s issued for invoice %s (level %d, due %s %.2f by %s)",
+		reminder.ReminderNumber, inv.InvoiceNumber, level, inv.Currency, totalDue, deadline)
+
+	intro := tr.ReminderIntro
+	if level == DunningFinalNotice {
+		intro = tr.FinalNoticeIntro
+	}
+	text := fmt.Sprintf(intro, inv.InvoiceNumber, generatedAt.Format("02.01.2006"), formatDate(inv.DueDate), formatDate(deadline))
+
+	if sender == nil {
+		sender = &SenderInfo{Name: s.SenderName, Address: s.SenderAddress, City: s.SenderCity, Zip: s.SenderZip, Country: s.SenderCountry}
+	}
+	if banking == nil {
+		banking = &BankingInfo{Name: s.BankName, IBAN: s.BankIBAN, AccountHolder: s.BankAccountHolder}
+	}
+	doc := InvoiceToMap(models.Invoice{
+		InvoiceNumber:         reminder.ReminderNumber,
+		UserID:                inv.UserID,
+		BuildingID:            inv.BuildingID,
+		PeriodStart:           storedDay(inv.PeriodStart),
+		PeriodEnd:             storedDay(inv.PeriodEnd),
+		TotalAmount:           totalDue,
+		Currency:              inv.Currency,
+		Status:                reminderStatus,
+		DocumentType:          DocumentTypeReminder,
+		OriginalInvoiceNumber: inv.InvoiceNumber,
+		DueDate:               deadline,
+		Items:                 items,
+		User:                  user,
+		GeneratedAt:           today,
+	})
+	doc["dunning_level"] = level
+	doc["reminder_text"] = text
+	// Keep the invoice's QR reference so the payment matches the invoice.
+	doc["payment_reference"] = invoiceReference(invoiceID, inv.InvoiceNumber)
+
+	pdfPath, err := ds.pdfGenerator.GenerateInvoicePDF(doc, *sender, *banking)
+	if err != nil {
+		log.Printf("WARNING: Failed to generate PDF for reminder %s: %v", reminder.ReminderNumber, err)
+		reminder.EmailError = "PDF generation failed: " + err.Error()
+		ds.db.Exec(`UPDATE invoice_reminders SET email_error = ? WHERE id = ?`, reminder.EmailError, reminder.ID)
+		return reminder, nil
+	}
+	reminder.PDFPath = pdfPath
+	ds.db.Exec(`UPDATE invoice_reminders SET pdf_path = ? WHERE id = ?`, pdfPath, reminder.ID)
+
+	if sendEmail {
+		ds.emailReminder(reminder, user, tr, reminderTitle(tr, level), text)
+	}
+	return reminder, nil
+}
+
+// emailReminder sends the reminder PDF to the tenant and records the outcome.
+func (ds *DunningService) emailReminder(reminder *models.InvoiceReminder, user *models.User, tr InvoiceTranslations, title, text string) {
+	switch {
+	case ds.emailAlerter == nil:
+		reminder.EmailError = "SMTP is not configured"
+	case user == nil || user.Email == "":
+		reminder.EmailError = "recipient has no e-mail address"
+	default:
+		subject := fmt.Sprintf("%s %s", title, reminder.ReminderNumber)
+		body := fmt.Sprintf(`<html><body style="font-family: Arial, sans-serif; color: #1f2937; line-height:1.6;">
+<p>%s,</p>
+<p>%s</p>
+<p style="color:#6b7280;font-size:12px;margin-top:24px;">— ZEV Billing</p>
+</body></html>`, template.HTMLEscapeString(fmt.Sprintf(tr.ReminderGreeting, strings.TrimSpace(user.FirstName+" "+user.LastName))),
+			template.HTMLEscapeString(text))
+		if err := ds.emailAlerter.SendEmailWithAttachment(user.Email, subject, body, resolveInvoicePDFPath(reminder.PDFPath)); err != nil {
+			reminder.EmailError = err.Error()
+		} else {
+			now := time.Now().Format("2006-01-02 15:04:05")
+			reminder.EmailedAt = &now
+			ds.db.Exec(`UPDATE invoice_reminders SET emailed_at = ?, email_error = '' WHERE id = ?`, now, reminder.ID)
+			log.Printf("Dunning: %s e-mailed to %s", reminder.ReminderNumber, user.Email)
+			return
+		}
+	}
+	log.Printf("Dunning: %s not e-mailed: %s", reminder.ReminderNumber, reminder.EmailError)
+	ds.db.Exec(`UPDATE invoice_reminders SET email_error = ? WHERE id = ?`, reminder.EmailError, reminder.ID)
+}
+
+// LoadReminders returns the reminders of an invoice, lowest level first.
+func LoadReminders(db *sql.DB, invoiceID int) ([]models.InvoiceReminder, error) {
+	rows, err := db.Query(`
+		SELECT id, invoice_id, level, reminder_number, outstanding_amount, fee, total_due,
+		       date(deadline), pdf_path, emailed_at, email_error, created_at
+		FROM invoice_reminders WHERE invoice_id = ? ORDER BY level
+	`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []models.InvoiceReminder{}
+	for rows.Next() {
+		var r models.InvoiceReminder
+		var emailedAt sql.NullString
+		if err := rows.Scan(&r.ID, &r.InvoiceID, &r.Level, &r.ReminderNumber, &r.OutstandingAmount, &r.Fee,
+			&r.TotalDue, &r.Deadline, &r.PDFPath, &emailedAt, &r.EmailError, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if emailedAt.Valid {
+			r.EmailedAt = &emailedAt.String
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDunningEscalation(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	if _, err := db.Exec(`INSERT INTO dunning_settings (building_id, payment_terms_days, reminder1_fee, reminder2_fee) VALUES (1, 30, 0, 20)`); err != nil {
+		t.Fatalf("insert settings: %v", err)
+	}
+	id := insertInvoice(t, bs, "INV-2026-1-10-1", 10, 1, 50)
+	if _, err := db.Exec(`UPDATE invoices SET due_date = '2026-02-28', paid_amount = 10, payment_status = 'partial' WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return d
+	}
+	if list, _ := ListOutstanding(db, 1, day("2026-02-20"), true); len(list) != 0 {
+		t.Errorf("not yet due, overdue list has %d entries", len(list))
+	}
+	list, err := ListOutstanding(db, 1, day("2026-03-10"), true)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListOutstanding = %d entries, %v", len(list), err)
+	}
+	if o := list[0]; o.DaysOverdue != 10 || o.NextLevel != DunningReminder1 || o.NextLevelDate != "2026-03-10" || !o.EscalationDue || !almostEqual(o.Outstanding, 40) {
+		t.Errorf("outstanding = %+v", o)
+	}
+
+	// Reminders are recorded even when the PDF cannot be rendered here.
+	ds := NewDunningService(db, NewPDFGenerator(db), 8)
+	if _, err := ds.IssueReminder(int(id), nil, nil, false, day("2026-03-10")); err != nil {
+		t.Fatalf("reminder 1: %v", err)
+	}
+	r2, err := ds.IssueReminder(int(id), nil, nil, false, day("2026-03-24"))
+	if err != nil {
+		t.Fatalf("reminder 2: %v", err)
+	}
+	if r2.Level != DunningReminder2 || r2.ReminderNumber != "R2-2026-1-10-1" || !almostEqual(r2.TotalDue, 60) || r2.Deadline != "2026-04-03" {
+		t.Errorf("reminder 2 = %+v", r2)
+	}
+	list, _ = ListOutstanding(db, 1, day("2026-03-24"), true)
+	if len(list) != 1 || list[0].DunningLevel != 2 || list[0].NextLevel != DunningFinalNotice || list[0].EscalationDue {
+		t.Errorf("after reminder 2 = %+v", list)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// EN 16931 guideline ID of the Factur-X / ZUGFeRD "EN 16931" (comfort) profile.
+const en16931Guideline = "urn:cen.eu:en16931:2017"
+
+// EInvoiceFileName is the attachment name Factur-X readers look for.
+const EInvoiceFileName = "factur-x.xml"
+
+// UN/ECE Recommendation 20 unit codes.
+const (
+	unitKWh = "KWH"
+	unitOne = "C62"
+)
+
+// energyItemTypes are the invoice lines billed per kWh.
+var energyItemTypes = map[string]bool{
+	"normal_power":          true,
+	"solar_power":           true,
+	"battery_power":         true,
+	"vzev_self_solar":       true,
+	"vzev_virtual_pv":       true,
+	"car_charging_normal":   true,
+	"car_charging_priority": true,
+	"car_charging_battery":  true,
+}
+
+// Cross Industry Invoice (CII D16B) as profiled by EN 16931. Element order
+// follows the CII schema; the rsm/ram/udt prefixes are written literally.
+type ciiInvoice struct {
+	XMLName     xml.Name       `xml:"rsm:CrossIndustryInvoice"`
+	NSRsm       string         `xml:"xmlns:rsm,attr"`
+	NSRam       string         `xml:"xmlns:ram,attr"`
+	NSUdt       string         `xml:"xmlns:udt,attr"`
+	Guideline   string         `xml:"rsm:ExchangedDocumentContext>ram:GuidelineSpecifiedDocumentContextParameter>ram:ID"`
+	Document    ciiDocument    `xml:"rsm:ExchangedDocument"`
+	Transaction ciiTransaction `xml:"rsm:SupplyChainTradeTransaction"`
+}
+
+type ciiDate struct {
+	Format string `xml:"format,attr"`
+	Value  string `xml:",chardata"`
+}
+
+func ciiDay(day string) *ciiDate {
+	t, err := time.Parse("2006-01-02", storedDay(day))
+	if err != nil {
+		return nil
+	}
+	return &ciiDate{Format: "102", Value: t.Format("20060102")}
+}
+
+type ciiDocument struct {
+	ID       string    `xml:"ram:ID"`
+	TypeCode string    `xml:"ram:TypeCode"`
+	Issued   *ciiDate  `xml:"ram:IssueDateTime>udt:DateTimeString"`
+	Notes    []ciiNote `xml:"ram:IncludedNote"`
+}
+
+type ciiNote struct {
+	Content string `xml:"ram:Content"`
+}
+
+type ciiTransaction struct {
+	Lines      []ciiLine     `xml:"ram:IncludedSupplyChainTradeLineItem"`
+	Agreement  ciiAgreement  `xml:"ram:ApplicableHeaderTradeAgreement"`
+	Delivery   struct{}      `xml:"ram:ApplicableHeaderTradeDelivery"`
+	Settlement ciiSettlement `xml:"ram:ApplicableHeaderTradeSettlement"`
+}
+
+type ciiQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ciiLine struct {
+	LineID     string      `xml:"ram:AssociatedDocumentLineDocument>ram:LineID"`
+	Name       string      `xml:"ram:SpecifiedTradeProduct>ram:Name"`
+	NetPrice   string      `xml:"ram:SpecifiedLineTradeAgreement>ram:NetPriceProductTradePrice>ram:ChargeAmount"`
+	Quantity   ciiQuantity `xml:"ram:SpecifiedLineTradeDelivery>ram:BilledQuantity"`
+	Tax        ciiLineTax  `xml:"ram:SpecifiedLineTradeSettlement>ram:ApplicableTradeTax"`
+	LineAmount string      `xml:"ram:SpecifiedLineTradeSettlement>ram:SpecifiedTradeSettlementLineMonetarySummation>ram:LineTotalAmount"`
+}
+
+type ciiLineTax struct {
+	TypeCode     string `xml:"ram:TypeCode"`
+	CategoryCode string `xml:"ram:CategoryCode"`
+	Rate         string `xml:"ram:RateApplicablePercent,omitempty"`
+}
+
+type ciiAddress struct {
+	Postcode string `xml:"ram:PostcodeCode,omitempty"`
+	Line     string `xml:"ram:LineOne,omitempty"`
+	City     string `xml:"ram:CityName,omitempty"`
+	Country  string `xml:"ram:CountryID"`
+}
+
+type ciiSchemeID struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ciiParty struct {
+	Name    string       `xml:"ram:Name"`
+	Address ciiAddress   `xml:"ram:PostalTradeAddress"`
+	Email   *ciiSchemeID `xml:"ram:URIUniversalCommunication>ram:URIID,omitempty"`
+	VATID   *ciiSchemeID `xml:"ram:SpecifiedTaxRegistration>ram:ID,omitempty"`
+}
+
+type ciiAgreement struct {
+	Seller ciiParty `xml:"ram:SellerTradeParty"`
+	Buyer  ciiParty `xml:"ram:BuyerTradeParty"`
+}
+
+type ciiPaymentMeans struct {
+	TypeCode    string `xml:"ram:TypeCode"`
+	IBAN        string `xml:"ram:PayeePartyCreditorFinancialAccount>ram:IBANID,omitempty"`
+	AccountName string `xml:"ram:PayeePartyCreditorFinancialAccount>ram:AccountName,omitempty"`
+}
+
+type ciiHeaderTax struct {
+	Calculated      string `xml:"ram:CalculatedAmount"`
+	TypeCode        string `xml:"ram:TypeCode"`
+	ExemptionReason string `xml:"ram:ExemptionReason,omitempty"`
+	Basis           string `xml:"ram:BasisAmount"`
+	CategoryCode    string `xml:"ram:CategoryCode"`
+	Rate            string `xml:"ram:RateApplicablePercent,omitempty"`
+}
+
+type ciiPeriod struct {
+	Start *ciiDate `xml:"ram:StartDateTime>udt:DateTimeString"`
+	End   *ciiDate `xml:"ram:EndDateTime>udt:DateTimeString"`
+}
+
+type ciiCurrencyAmount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ciiSummation struct {
+	LineTotal    string            `xml:"ram:LineTotalAmount"`
+	TaxBasis     string            `xml:"ram:TaxBasisTotalAmount"`
+	TaxTotal     ciiCurrencyAmount `xml:"ram:TaxTotalAmount"`
+	Rounding     string            `xml:"ram:RoundingAmount,omitempty"`
+	GrandTotal   string            `xml:"ram:GrandTotalAmount"`
+	TotalPrepaid string            `xml:"ram:TotalPrepaidAmount,omitempty"`
+	DuePayable   string            `xml:"ram:DuePayableAmount"`
+}
+
+type ciiReference struct {
+	ID string `xml:"ram:IssuerAssignedID"`
+}
+
+type ciiSettlement struct {
+	PaymentReference string           `xml:"ram:PaymentReference,omitempty"`
+	Currency         string           `xml:"ram:InvoiceCurrencyCode"`
+	PaymentMeans     *ciiPaymentMeans `xml:"ram:SpecifiedTradeSettlementPaymentMeans,omitempty"`
+	Tax              []ciiHeaderTax   `xml:"ram:ApplicableTradeTax"`
+	Period           *ciiPeriod       `xml:"ram:BillingSpecifiedPeriod,omitempty"`
+	DueDate          *ciiDate         `xml:"ram:SpecifiedTradePaymentTerms>ram:DueDateDateTime>udt:DateTimeString,omitempty"`
+	Summation        ciiSummation     `xml:"ram:SpecifiedTradeSettlementHeaderMonetarySummation"`
+	PrecedingInvoice *ciiReference    `xml:"ram:InvoiceReferencedDocument,omitempty"`
+}
+
+// einvoiceTypeCode maps a document type to UNTDID 1001.
+func einvoiceTypeCode(documentType string) string {
+	switch documentType {
+	case DocumentTypeCreditNote, DocumentTypeProducerCredit:
+		return "381"
+	case DocumentTypeAdvance:
+		return "386"
+	}
+	return "380"
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("%.2f", math.Round(v*100)/100)
+}
+
+// BuildEInvoiceXML renders an issued invoice (with items and user loaded) as
+// an EN 16931 CII document. Amounts are re-derived per line from the stored
+// items; a cent difference to the stored total is carried as RoundingAmount.
+// Credit notes are written with positive amounts, as type code 381 requires.
+func BuildEInvoiceXML(inv models.Invoice, sender SenderInfo, banking BankingInfo) ([]byte, error) {
+	if inv.User == nil {
+		return nil, fmt.Errorf("invoice %s has no recipient", inv.InvoiceNumber)
+	}
+	if strings.TrimSpace(sender.Name) == "" {
+		return nil, fmt.Errorf("sender name is required")
+	}
+	vatNumber := strings.TrimSpace(sender.VATNumber)
+	if inv.VATRate > 0 && vatNumber == "" {
+		return nil, fmt.Errorf("a seller VAT number is required for an invoice with VAT")
+	}
+
+	sign := 1.0
+	if IsCreditDocument(inv.DocumentType) {
+		sign = -1
+	}
+	currency := strings.ToUpper(inv.Currency)
+
+	// VAT category: S (standard) with a rate; without VAT, E (exempt) for a
+	// VAT-registered seller, O (outside the scope of VAT) otherwise. Lines
+	// with a VAT code carry their own rate, exempt ones category E.
+	invoiceTax := ciiLineTax{TypeCode: "VAT", CategoryCode: "S", Rate: fmt.Sprintf("%g", inv.VATRate)}
+	exemption := ""
+	switch {
+	case inv.VATRate > 0:
+	case vatNumber != "":
+		invoiceTax = ciiLineTax{TypeCode: "VAT", CategoryCode: "E", Rate: "0"}
+		exemption = "Exempt from VAT"
+	default:
+		invoiceTax = ciiLineTax{TypeCode: "VAT", CategoryCode: "O"}
+		exemption = "Not subject to VAT"
+	}
+
+	// Tax subtotals per category and rate, in order of first use.
+	type taxGroup struct {
+		tax   ciiLineTax
+		rate  float64
+		basis float64
+	}
+	var taxGroups []*taxGroup
+
+	var lines []ciiLine
+	lineTotal := 0.0
+	for _, item := range inv.Items {
+		if math.Abs(item.TotalPrice) < 0.005 {
+			continue // headers, meter readings, separators and notes
+		}
+		lineTax, rate := invoiceTax, inv.VATRate
+		if inv.VATRate > 0 && item.VATCode != "" {
+			rate = item.VATRate
+			lineTax = ciiLineTax{TypeCode: "VAT", CategoryCode: "S", Rate: fmt.Sprintf("%g", rate)}
+			if rate == 0 {
+				lineTax = ciiLineTax{TypeCode: "VAT", CategoryCode: "E", Rate: "0"}
+			}
+		}
+		divisor := 1.0
+		if inv.VATIncluded && rate > 0 {
+			divisor = 1 + rate/100
+		}
+		net := math.Round(sign*item.TotalPrice/divisor*100) / 100
+		qty, price, unit := math.Abs(item.Quantity), math.Abs(item.UnitPrice)/divisor, unitOne
+		if energyItemTypes[item.ItemType] {
+			unit = unitKWh
+		}
+		// Lines whose amount is not quantity × price (prorated shares, fees,
+		// deductions) are billed as one unit at the line amount.
+		if qty == 0 || math.Abs(qty*price-math.Abs(net)) > 0.01 {
+			qty, price, unit = 1, math.Abs(net), unitOne
+		}
+		if net < 0 {
+			qty = -qty
+		}
+		lineTotal += net
+		lines = append(lines, ciiLine{
+			LineID:     fmt.Sprintf("%d", len(lines)+1),
+			Name:       strings.TrimSpace(item.Description),
+			NetPrice:   fmt.Sprintf("%.4f", price),
+			Quantity:   ciiQuantity{UnitCode: unit, Value: fmt.Sprintf("%.3f", qty)},
+			Tax:        lineTax,
+			LineAmount: money(net),
+		})
+
+		var g *taxGroup
+		for _, tg := range taxGroups {
+			if tg.tax == lineTax {
+				g = tg
+				break
+			}
+		}
+		if g == nil {
+			g = &taxGroup{tax: lineTax, rate: rate}
+			taxGroups = append(taxGroups, g)
+		}
+		g.basis += net
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("invoice %s has no billable lines", inv.InvoiceNumber)
+	}
+
+	lineTotal = math.Round(lineTotal*100) / 100
+	taxTotal := 0.0
+	var headerTax []ciiHeaderTax
+	for _, g := range taxGroups {
+		basis := math.Round(g.basis*100) / 100
+		calculated := 0.0
+		if g.tax.CategoryCode == "S" {
+			calculated = math.Round(basis*g.rate) / 100
+		}
+		taxTotal += calculated
+		reason := ""
+		if g.tax.CategoryCode != "S" {
+			reason = exemption
+			if reason == "" {
+				reason = "Exempt from VAT"
+			}
+		}
+		headerTax = append(headerTax, ciiHeaderTax{
+			Calculated:      money(calculated),
+			TypeCode:        "VAT",
+			ExemptionReason: reason,
+			Basis:           money(basis),
+			CategoryCode:    g.tax.CategoryCode,
+			Rate:            g.tax.Rate,
+		})
+	}
+	taxTotal = math.Round(taxTotal*100) / 100
+	grand := lineTotal + taxTotal
+	rounding := math.Round((sign*inv.TotalAmount-grand)*100) / 100
+	prepaid := math.Round(sign*inv.PaidAmount*100) / 100
+
+	summation := ciiSummation{
+		LineTotal:  money(lineTotal),
+		TaxBasis:   money(lineTotal),
+		TaxTotal:   ciiCurrencyAmount{Currency: currency, Value: money(taxTotal)},
+		GrandTotal: money(grand),
+		DuePayable: money(grand + rounding - prepaid),
+	}
+	if rounding != 0 {
+		summation.Rounding = money(rounding)
+	}
+	if prepaid != 0 {
+		summation.TotalPrepaid = money(prepaid)
+	}
+
+	senderStreet, senderHouseNo := parseAddress(sender.Address)
+	seller := ciiParty{
+		Name: strings.TrimSpace(sender.Name),
+		Address: ciiAddress{
+			Postcode: strings.TrimSpace(sender.Zip),
+			Line:     strings.TrimSpace(senderStreet + " " + senderHouseNo),
+			City:     strings.TrimSpace(sender.City),
+			Country:  normalizeCountryCode(sender.Country),
+		},
+	}
+	if vatNumber != "" {
+		seller.VATID = &ciiSchemeID{SchemeID: "VA", Value: vatNumber}
+	}
+	u := inv.User
+	buyer := ciiParty{
+		Name: strings.TrimSpace(u.FirstName + " " + u.LastName),
+		Address: ciiAddress{
+			Postcode: strings.TrimSpace(u.AddressZip),
+			Line:     strings.TrimSpace(u.AddressStreet),
+			City:     strings.TrimSpace(u.AddressCity),
+			Country:  normalizeCountryCode(u.AddressCountry),
+		},
+	}
+	if email := strings.TrimSpace(u.Email); email != "" {
+		buyer.Email = &ciiSchemeID{SchemeID: "EM", Value: email}
+	}
+
+	settlement := ciiSettlement{
+		Currency:  currency,
+		Tax:       headerTax,
+		Period:    &ciiPeriod{Start: ciiDay(inv.PeriodStart), End: ciiDay(inv.PeriodEnd)},
+		DueDate:   ciiDay(inv.DueDate),
+		Summation: summation,
+	}
+	if inv.OriginalInvoiceNumber != "" {
+		settlement.PrecedingInvoice = &ciiReference{ID: inv.OriginalInvoiceNumber}
+	}
+	if settlement.Period.Start == nil || settlement.Period.End == nil {
+		settlement.Period = nil
+	}
+	if iban := normalizeReference(banking.IBAN); iban != "" && !IsCreditDocument(inv.DocumentType) {
+		means := &ciiPaymentMeans{TypeCode: "30", IBAN: iban, AccountName: strings.TrimSpace(banking.AccountHolder)}
+		if currency == "EUR" {
+			means.TypeCode = "58" // SEPA credit transfer
+		}
+		settlement.PaymentMeans = means
+		settlement.PaymentReference = inv.InvoiceNumber
+		if isQRIBAN(iban) {
+			settlement.PaymentReference = invoiceReference(inv.ID, inv.InvoiceNumber)
+		}
+	}
+
+	doc := ciiInvoice{
+		NSRsm:     "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100",
+		NSRam:     "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100",
+		NSUdt:     "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100",
+		Guideline: en16931Guideline,
+		Document: ciiDocument{
+			ID:       inv.InvoiceNumber,
+			TypeCode: einvoiceTypeCode(inv.DocumentType),
+			Issued:   &ciiDate{Format: "102", Value: inv.GeneratedAt.Format("20060102")},
+		},
+		Transaction: ciiTransaction{
+			Lines:      lines,
+			Agreement:  ciiAgreement{Seller: seller, Buyer: buyer},
+			Settlement: settlement,
+		},
+	}
+	if inv.CancellationReason != "" && inv.DocumentType == DocumentTypeCreditNote {
+		doc.Document.Notes = []ciiNote{{Content: inv.CancellationReason}}
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestEInvoiceAndFacturX(t *testing.T) {
+	inv := models.Invoice{
+		InvoiceNumber: "INV-2026-1-10-1", PeriodStart: "2026-01-01", PeriodEnd: "2026-01-31",
+		TotalAmount: 108.1, VATRate: 8.1, VATIncluded: false, Currency: "CHF", DocumentType: "invoice",
+		GeneratedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), DueDate: "2026-03-03",
+		User: &models.User{FirstName: "Anna", LastName: "Muster", Email: "a@b.c", AddressStreet: "Dorfstrasse 1", AddressZip: "8000", AddressCity: "Zürich", AddressCountry: "Switzerland"},
+		Items: []models.InvoiceItem{
+			{Description: "Meter reading", ItemType: "meter_info"},
+			{Description: "Solar power", Quantity: 200, UnitPrice: 0.2, TotalPrice: 40, ItemType: "solar_power"},
+			{Description: "Basic fee (prorated)", Quantity: 1, UnitPrice: 75, TotalPrice: 60, ItemType: "custom_item"},
+		},
+	}
+	sender := SenderInfo{Name: "ZEV Sonnenhof", Address: "Hauptstrasse 5", City: "Zürich", Zip: "8001", Country: "CH"}
+	if _, err := BuildEInvoiceXML(inv, sender, BankingInfo{}); err == nil {
+		t.Fatal("expected an error without seller VAT number")
+	}
+	sender.VATNumber = "CHE-123.456.789 MWST"
+	data, err := BuildEInvoiceXML(inv, sender, BankingInfo{IBAN: "CH93 0076 2011 6238 5295 7", AccountHolder: "ZEV Sonnenhof"})
+	if err != nil {
+		t.Fatalf("BuildEInvoiceXML: %v", err)
+	}
+	xmlText := string(data)
+	for _, want := range []string{
+		`<ram:BilledQuantity unitCode="KWH">200.000</ram:BilledQuantity>`,
+		`<ram:BilledQuantity unitCode="C62">1.000</ram:BilledQuantity>`,
+		`<ram:LineTotalAmount>100.00</ram:LineTotalAmount>`,
+		`<ram:TaxTotalAmount currencyID="CHF">8.10</ram:TaxTotalAmount>`,
+		`<ram:DuePayableAmount>108.10</ram:DuePayableAmount>`,
+		`<ram:CategoryCode>S</ram:CategoryCode>`,
+		`<ram:IBANID>CH9300762011623852957</ram:IBANID>`,
+		`<udt:DateTimeString format="102">20260303</udt:DateTimeString>`,
+	} {
+		if !strings.Contains(xmlText, want) {
+			t.Errorf("e-invoice lacks %s", want)
+		}
+	}
+	if strings.Contains(xmlText, "Meter reading") {
+		t.Error("zero-amount info line was exported")
+	}
+
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+	xref := len(pdf)
+	pdf += fmt.Sprintf("xref\n0 3\n0000000000 65535 f \n%010d 00000 n \n%010d 00000 n \ntrailer\n<< /Size 3 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		9, strings.Index(pdf, "2 0 obj"), xref)
+	out, err := EmbedFacturX([]byte(pdf), data, inv.GeneratedAt)
+	if err != nil {
+		t.Fatalf("EmbedFacturX: %v", err)
+	}
+	text := string(out)
+	newXref, _ := strconv.Atoi(strings.Fields(text[strings.LastIndex(text, "startxref")+len("startxref"):])[0])
+	if !strings.HasPrefix(text[newXref:], "xref\n1 1\n") || !strings.Contains(text, "/Prev "+fmt.Sprint(xref)) {
+		t.Errorf("incremental xref not found at %d", newXref)
+	}
+	for _, want := range []string{"/AF [4 0 R]", "/Names << /EmbeddedFiles 5 0 R >>", "(factur-x.xml)", "<fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("PDF lacks %s", want)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmbedFacturX attaches an EN 16931 XML document to a PDF as a Factur-X
+// invoice: an associated file (/AF, AFRelationship Data) named factur-x.xml,
+// listed in the EmbeddedFiles name tree, plus XMP metadata declaring PDF/A-3
+// and the Factur-X EN 16931 profile.
+//
+// The original bytes are kept untouched and the new objects are appended as an
+// incremental update, so any existing signature-free PDF keeps rendering the
+// same. Only classic cross-reference tables are supported (which is what
+// wkhtmltopdf and Chromium write). Whether the result validates as strict
+// PDF/A-3 still depends on the renderer (embedded fonts, output intent).
+func EmbedFacturX(pdf, xmlData []byte, modTime time.Time) ([]byte, error) {
+	u, err := openPDFUpdate(pdf)
+	if err != nil {
+		return nil, err
+	}
+	pdfDate := modTime.Format("D:20060102150405") + pdfTZ(modTime)
+
+	fileNum := u.add(fmt.Sprintf("<< /Type /EmbeddedFile /Subtype /text#2Fxml /Length %d /Params << /Size %d /ModDate (%s) >> >>\nstream\n%s\nendstream",
+		len(xmlData), len(xmlData), pdfDate, xmlData))
+	specNum := u.add(fmt.Sprintf("<< /Type /Filespec /F (%s) /UF (%s) /Desc (Factur-X invoice) /AFRelationship /Data /EF << /F %d 0 R /UF %d 0 R >> >>",
+		EInvoiceFileName, EInvoiceFileName, fileNum, fileNum))
+	namesNum := u.add(fmt.Sprintf("<< /Names [(%s) %d 0 R] >>", EInvoiceFileName, specNum))
+	xmp := facturXMetadata(modTime)
+	metaNum := u.add(fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp), xmp))
+
+	// The document's name dictionary gains EmbeddedFiles; when it is an
+	// indirect object it is rewritten in the update as well.
+	embedded := fmt.Sprintf("%d 0 R", namesNum)
+	switch names := u.catalog.get("/Names"); {
+	case names == "":
+		u.catalog.set("/Names", "<< /EmbeddedFiles "+embedded+" >>")
+	case strings.HasPrefix(names, "<<"):
+		d, _, err := parsePDFDict(names)
+		if err != nil {
+			return nil, fmt.Errorf("catalog /Names: %v", err)
+		}
+		d.set("/EmbeddedFiles", embedded)
+		u.catalog.set("/Names", d.String())
+	default:
+		num, gen, ok := parsePDFRef(names)
+		if !ok {
+			return nil, fmt.Errorf("unexpected catalog /Names value %q", names)
+		}
+		d, err := readPDFDictObject(pdf, num, gen)
+		if err != nil {
+			return nil, fmt.Errorf("names dictionary: %v", err)
+		}
+		d.set("/EmbeddedFiles", embedded)
+		u.replace(num, gen, d.String())
+	}
+	u.catalog.set("/AF", fmt.Sprintf("[%d 0 R]", specNum))
+	u.catalog.set("/Metadata", fmt.Sprintf("%d 0 R", metaNum))
+	return u.bytes(), nil
+}
+
+// pdfUpdate collects the objects of an incremental update: new objects are
+// numbered after the document's current /Size and the catalog is always
+// rewritten.
+type pdfUpdate struct {
+	pdf              []byte
+	trailer          pdfDict
+	prevXref, size   int
+	rootNum, rootGen int
+	catalog          pdfDict
+	objects          map[int]string
+	gens             map[int]int
+}
+
+// openPDFUpdate reads the trailer and catalog of pdf to start an incremental
+// update. Only classic cross-reference tables are supported.
+func openPDFUpdate(pdf []byte) (*pdfUpdate, error) {
+	sx := bytes.LastIndex(pdf, []byte("startxref"))
+	if sx < 0 {
+		return nil, fmt.Errorf("not a PDF: startxref not found")
+	}
+	prevXref, err := strconv.Atoi(strings.Fields(string(pdf[sx+len("startxref"):]) + " x")[0])
+	if err != nil || prevXref <= 0 || prevXref >= len(pdf) {
+		return nil, fmt.Errorf("invalid startxref offset")
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(pdf[prevXref:], " \r\n\t"), []byte("xref")) {
+		return nil, fmt.Errorf("cross-reference streams are not supported")
+	}
+	tr := bytes.LastIndex(pdf[:sx], []byte("trailer"))
+	if tr < 0 {
+		return nil, fmt.Errorf("trailer not found")
+	}
+	trailer, _, err := parsePDFDict(string(pdf[tr+len("trailer"):]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trailer: %v", err)
+	}
+	size, err := strconv.Atoi(trailer.get("/Size"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trailer /Size")
+	}
+	rootNum, rootGen, ok := parsePDFRef(trailer.get("/Root"))
+	if !ok {
+		return nil, fmt.Errorf("trailer has no /Root reference")
+	}
+	catalog, err := readPDFDictObject(pdf, rootNum, rootGen)
+	if err != nil {
+		return nil, fmt.Errorf("catalog: %v", err)
+	}
+	return &pdfUpdate{
+		pdf: pdf, trailer: trailer, prevXref: prevXref, size: size,
+		rootNum: rootNum, rootGen: rootGen, catalog: catalog,
+		objects: map[int]string{}, gens: map[int]int{},
+	}, nil
+}
+
+// add appends a new object and returns its number.
+func (u *pdfUpdate) add(body string) int {
+	num := u.size
+	u.size++
+	u.objects[num] = body
+	return num
+}
+
+// replace rewrites the existing object num gen.
+func (u *pdfUpdate) replace(num, gen int, body string) {
+	u.objects[num], u.gens[num] = body, gen
+}
+
+// bytes returns the original document followed by the update: the collected
+// objects, the catalog, their cross-reference section and a trailer pointing
+// back to the previous one.
+func (u *pdfUpdate) bytes() []byte {
+	u.replace(u.rootNum, u.rootGen, u.catalog.String())
+
+	var out bytes.Buffer
+	out.Write(u.pdf)
+	if len(u.pdf) > 0 && u.pdf[len(u.pdf)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	offsets := map[int]int{}
+	for _, num := range nums {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d %d obj\n%s\nendobj\n", num, u.gens[num], u.objects[num])
+	}
+
+	xrefOffset := out.Len()
+	out.WriteString("xref\n")
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		fmt.Fprintf(&out, "%d %d\n", nums[i], j-i+1)
+		for _, num := range nums[i : j+1] {
+			fmt.Fprintf(&out, "%010d %05d n \n", offsets[num], u.gens[num])
+		}
+		i = j + 1
+	}
+
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d %d R /Prev %d", u.size, u.rootNum, u.rootGen, u.prevXref)
+	if info := u.trailer.get("/Info"); info != "" {
+		out.WriteString(" /Info " + info)
+	}
+	if id := u.trailer.get("/ID"); id != "" {
+		out.WriteString(" /ID " + id)
+	}
+	fmt.Fprintf(&out, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
+
+func pdfTZ(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d'%02d'", sign, offset/3600, offset%3600/60)
+}
+
+// facturXMetadata is the XMP packet declaring PDF/A-3B and the Factur-X
+// extension schema (document type, file name, version, conformance level).
+func facturXMetadata(modTime time.Time) string {
+	stamp := modTime.Format(time.RFC3339)
+	return `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+   <pdfaid:part>3</pdfaid:part>
+   <pdfaid:conformance>B</pdfaid:conformance>
+  </rdf:Description>
+  <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+   <xmp:CreatorTool>ZEV Billing</xmp:CreatorTool>
+   <xmp:CreateDate>` + stamp + `</xmp:CreateDate>
+   <xmp:ModifyDate>` + stamp + `</xmp:ModifyDate>
+   <xmp:MetadataDate>` + stamp + `</xmp:MetadataDate>
+  </rdf:Description>
+  <rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+   <fx:DocumentType>INVOICE</fx:DocumentType>
+   <fx:DocumentFileName>` + EInvoiceFileName + `</fx:DocumentFileName>
+   <fx:Version>1.0</fx:Version>
+   <fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>
+  </rdf:Description>
+  <rdf:Description rdf:about=""
+    xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/"
+    xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#"
+    xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+   <pdfaExtension:schemas>
+    <rdf:Bag>
+     <rdf:li rdf:parseType="Resource">
+      <pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+      <pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+      <pdfaSchema:prefix>fx</pdfaSchema:prefix>
+      <pdfaSchema:property>
+       <rdf:Seq>` + facturXProperty("DocumentFileName", "name of the embedded XML invoice file") +
+		facturXProperty("DocumentType", "INVOICE") +
+		facturXProperty("Version", "version of the Factur-X XML schema") +
+		facturXProperty("ConformanceLevel", "conformance level of the embedded XML invoice") + `
+       </rdf:Seq>
+      </pdfaSchema:property>
+     </rdf:li>
+    </rdf:Bag>
+   </pdfaExtension:schemas>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+}
+
+func facturXProperty(name, description string) string {
+	return `
+        <rdf:li rdf:parseType="Resource">
+         <pdfaProperty:name>` + name + `</pdfaProperty:name>
+         <pdfaProperty:valueType>Text</pdfaProperty:valueType>
+         <pdfaProperty:category>external</pdfaProperty:category>
+         <pdfaProperty:description>` + description + `</pdfaProperty:description>
+        </rdf:li>`
+}
+
+// pdfDict is a PDF dictionary kept as raw key/value tokens in source order,
+// enough to rewrite a few keys without a full object model.
+type pdfDict []pdfEntry
+
+type pdfEntry struct {
+	Key, Value string
+}
+
+func (d pdfDict) get(key string) string {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+func (d *pdfDict) set(key, value string) {
+	for i, e := range *d {
+		if e.Key == key {
+			(*d)[i].Value = value
+			return
+		}
+	}
+	*d = append(*d, pdfEntry{key, value})
+}
+
+func (d pdfDict) String() string {
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, e := range d {
+		b.WriteString(" " + e.Key + " " + e.Value)
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+var (
+	pdfRefPattern     = regexp.MustCompile(`^(\d+)\s+(\d+)\s+R$`)
+	pdfRefTailPattern = regexp.MustCompile(`^\s+\d+\s+R\b`)
+)
+
+func parsePDFRef(v string) (int, int, bool) {
+	m := pdfRefPattern.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return 0, 0, false
+	}
+	num, _ := strconv.Atoi(m[1])
+	gen, _ := strconv.Atoi(m[2])
+	return num, gen, true
+}
+
+// readPDFDictObject returns the dictionary of the last definition of object
+// num gen, which is the current one after earlier incremental updates.
+func readPDFDictObject(pdf []byte, num, gen int) (pdfDict, error) {
+	pattern := regexp.MustCompile(fmt.Sprintf(`(?:^|[^0-9])%d\s+%d\s+obj\b`, num, gen))
+	matches := pattern.FindAllIndex(pdf, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("object %d %d not found", num, gen)
+	}
+	d, _, err := parsePDFDict(string(pdf[matches[len(matches)-1][1]:]))
+	return d, err
+}
+
+// parsePDFDict parses the dictionary at the start of s (after whitespace)
+// and returns it with the number of bytes consumed.
+func parsePDFDict(s string) (pdfDict, int, error) {
+	i := skipPDFSpace(s, 0)
+	if !strings.HasPrefix(s[i:], "<<") {
+		return nil, 0, fmt.Errorf("dictionary expected")
+	}
+	i += 2
+	var d pdfDict
+	for {
+		i = skipPDFSpace(s, i)
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated dictionary")
+		}
+		if strings.HasPrefix(s[i:], ">>") {
+			return d, i + 2, nil
+		}
+		if s[i] != '/' {
+			return nil, 0, fmt.Errorf("name expected at offset %d", i)
+		}
+		end := scanPDFValue(s, i)
+		key := s[i:end]
+		start := skipPDFSpace(s, end)
+		end = scanPDFValue(s, start)
+		if end <= start {
+			return nil, 0, fmt.Errorf("value expected for %s", key)
+		}
+		// An indirect reference spans three tokens: "num gen R".
+		if m := pdfRefTailPattern.FindString(s[end:]); m != "" && isPDFInteger(s[start:end]) {
+			end += len(m)
+		}
+		d = append(d, pdfEntry{key, strings.TrimSpace(s[start:end])})
+		i = end
+	}
+}
+
+func isPDFInteger(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func skipPDFSpace(s string, i int) int {
+	for i < len(s) {
+		switch s[i] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			i++
+		case '%':
+			for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// scanPDFValue returns the end offset of the single PDF token or composite
+// value (dictionary, array, string) starting at i.
+func scanPDFValue(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+	switch {
+	case strings.HasPrefix(s[i:], "<<"):
+		depth := 0
+		for i < len(s) {
+			switch {
+			case strings.HasPrefix(s[i:], "<<"):
+				depth++
+				i += 2
+			case strings.HasPrefix(s[i:], ">>"):
+				depth--
+				i += 2
+				if depth == 0 {
+					return i
+				}
+			case s[i] == '(' || s[i] == '<':
+				i = scanPDFValue(s, i)
+			default:
+				i++
+			}
+		}
+		return i
+	case s[i] == '[':
+		depth := 0
+		for i < len(s) {
+			switch s[i] {
+			case '[':
+				depth++
+				i++
+			case ']':
+				depth--
+				i++
+				if depth == 0 {
+					return i
+				}
+			case '(', '<':
+				i = scanPDFValue(s, i)
+			default:
+				i++
+			}
+		}
+		return i
+	case s[i] == '(':
+		depth := 0
+		for i < len(s) {
+			switch s[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return i
+	case s[i] == '<':
+		if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+			return i + end + 1
+		}
+		return len(s)
+	}
+	// Names, numbers, booleans, null, the R keyword: read to a delimiter.
+	j := i + 1
+	for j < len(s) && !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(s[j])) {
+		j++
+	}
+	return j
+}
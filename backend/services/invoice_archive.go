@@ -0,0 +1,282 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Archive problems reported by InvoiceArchive.Verify.
+const (
+	ArchiveProblemMissing      = "missing"
+	ArchiveProblemModified     = "modified"
+	ArchiveProblemChainBroken  = "chain_broken"
+	ArchiveProblemHeadMismatch = "head_mismatch"
+)
+
+// archiveMu serialises archiving so that each entry chains to the one
+// written just before it.
+var archiveMu sync.Mutex
+
+// invoiceArchiveDir is where archived PDFs are kept, configured at startup
+// with SetInvoiceArchiveDir.
+var invoiceArchiveDir = "./archive"
+
+// SetInvoiceArchiveDir sets the archive directory, kept apart from the
+// invoices directory so nothing that manages the working PDFs touches it.
+func SetInvoiceArchiveDir(dir string) {
+	if dir != "" {
+		invoiceArchiveDir = dir
+	}
+}
+
+// InvoiceArchive keeps issued documents unchanged for the retention period:
+// a copy of each in the archive directory, written once and never replaced,
+// with its SHA-256 recorded in invoice_archive and chained to the entry
+// before it. invoice_archive_head records the entry count and the last chain
+// hash, so that removing the newest entries shows up as well as a changed or
+// removed file or a rewritten entry.
+type InvoiceArchive struct {
+	db      *sql.DB
+	dir     string
+	convert func(pdf []byte) ([]byte, error)
+}
+
+func NewInvoiceArchive(db *sql.DB) *InvoiceArchive {
+	return &InvoiceArchive{db: db, dir: invoiceArchiveDir, convert: ConvertToPDFA}
+}
+
+// archiveChainHash links an entry to the chain hash of the one before it.
+func archiveChainHash(previous string, e models.InvoiceArchiveEntry) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		previous, strconv.Itoa(e.InvoiceID), e.InvoiceNumber, e.Format, e.SHA256, e.ArchivedAt,
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Archive stores the PDF of an issued document, at pdfPath as recorded in
+// invoices.pdf_path, and appends it to the chain. The copy is converted to
+// PDF/A-3B; when the conversion fails nothing is archived and the error is
+// returned, so the document stays unarchived until it can be converted. A
+// document is archived once: later calls return the existing entry, whatever
+// PDF is on disk by then.
+func (a *InvoiceArchive) Archive(invoiceID int, pdfPath string) (*models.InvoiceArchiveEntry, error) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+
+	if e, err := a.Entry(invoiceID); err == nil {
+		return e, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var number, status string
+	if err := a.db.QueryRow(`SELECT invoice_number, status FROM invoices WHERE id = ?`, invoiceID).Scan(&number, &status); err != nil {
+		return nil, fmt.Errorf("failed to load invoice %d: %v", invoiceID, err)
+	}
+	if status == InvoiceStatusDraft || status == InvoiceStatusPreview {
+		return nil, fmt.Errorf("invoice %s is a %s and cannot be archived", number, status)
+	}
+	if pdfPath == "" {
+		return nil, fmt.Errorf("invoice %s has no PDF", number)
+	}
+	pdf, err := os.ReadFile(resolveInvoicePDFPath(pdfPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF of invoice %s: %v", number, err)
+	}
+
+	data, err := a.convert(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert invoice %s to PDF/A: %v", number, err)
+	}
+	sum := sha256.Sum256(data)
+	e := models.InvoiceArchiveEntry{
+		InvoiceID:     invoiceID,
+		InvoiceNumber: number,
+		FileName:      fmt.Sprintf("%d_%s.pdf", invoiceID, filepath.Base(number)),
+		Format:        ArchiveFormatPDFA3B,
+		SHA256:        hex.EncodeToString(sum[:]),
+		ArchivedAt:    time.Now().UTC().Format("2006-01-02 15:04:05"),
+	}
+	err = a.db.QueryRow(`SELECT chain_hash FROM invoice_archive ORDER BY id DESC LIMIT 1`).Scan(&e.PreviousHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	e.ChainHash = archiveChainHash(e.PreviousHash, e)
+
+	// The file is written before the entry so an entry never points at a
+	// file that does not exist; a file left over from an attempt whose entry
+	// was never recorded is replaced.
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(a.dir, e.FileName)
+	if _, err := os.Stat(path); err == nil {
+		log.Printf("WARNING: Replacing unrecorded archive file %s", path)
+		os.Remove(path)
+	}
+	if err := os.WriteFile(path, data, 0444); err != nil {
+		return nil, fmt.Errorf("failed to write archive file: %v", err)
+	}
+
+	id, err := a.record(e)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to record archive entry: %v", err)
+	}
+	e.ID = id
+	log.Printf("Archived invoice %s as %s (%s, sha256 %s)", number, e.FileName, e.Format, e.SHA256)
+	return &e, nil
+}
+
+// record inserts an entry and moves the archive head to it in one
+// transaction.
+func (a *InvoiceArchive) record(e models.InvoiceArchiveEntry) (int, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO invoice_archive (invoice_id, invoice_number, file_name, format, sha256, previous_hash, chain_hash, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.InvoiceID, e.InvoiceNumber, e.FileName, e.Format, e.SHA256, e.PreviousHash, e.ChainHash, e.ArchivedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+
+	_, err = tx.Exec(`
+		INSERT INTO invoice_archive_head (id, entry_count, head_hash, updated_at)
+		VALUES (1, 1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			entry_count = entry_count + 1,
+			head_hash = excluded.head_hash,
+			updated_at = CURRENT_TIMESTAMP
+	`, e.ChainHash)
+	if err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// Entry returns the archive entry of a document, sql.ErrNoRows when it is not
+// archived.
+func (a *InvoiceArchive) Entry(invoiceID int) (*models.InvoiceArchiveEntry, error) {
+	var e models.InvoiceArchiveEntry
+	err := a.db.QueryRow(`
+		SELECT id, invoice_id, invoice_number, file_name, format, sha256, previous_hash, chain_hash, archived_at
+		FROM invoice_archive WHERE invoice_id = ?
+	`, invoiceID).Scan(&e.ID, &e.InvoiceID, &e.InvoiceNumber, &e.FileName, &e.Format, &e.SHA256, &e.PreviousHash, &e.ChainHash, &e.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Path returns the archived PDF of a document, "" when it is not archived.
+func (a *InvoiceArchive) Path(invoiceID int) string {
+	e, err := a.Entry(invoiceID)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(a.dir, e.FileName)
+}
+
+// Verify checks every archive entry in order: that it chains to the entry
+// before it, and that its file exists with the recorded hash. The last entry
+// and the entry count must match the archive head. Issued
+// documents that were never archived are listed separately and do not make
+// the archive invalid.
+func (a *InvoiceArchive) Verify() (models.ArchiveVerification, error) {
+	v := models.ArchiveVerification{Problems: []models.ArchiveProblem{}, Unarchived: []models.InvoiceRef{}}
+
+	rows, err := a.db.Query(`
+		SELECT id, invoice_id, invoice_number, file_name, format, sha256, previous_hash, chain_hash, archived_at
+		FROM invoice_archive ORDER BY id
+	`)
+	if err != nil {
+		return v, err
+	}
+	var entries []models.InvoiceArchiveEntry
+	for rows.Next() {
+		var e models.InvoiceArchiveEntry
+		if err := rows.Scan(&e.ID, &e.InvoiceID, &e.InvoiceNumber, &e.FileName, &e.Format, &e.SHA256, &e.PreviousHash, &e.ChainHash, &e.ArchivedAt); err != nil {
+			rows.Close()
+			return v, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	previous := ""
+	for _, e := range entries {
+		v.Checked++
+		problem := func(kind, detail string) {
+			v.Problems = append(v.Problems, models.ArchiveProblem{
+				EntryID: e.ID, InvoiceID: e.InvoiceID, InvoiceNumber: e.InvoiceNumber, Problem: kind, Detail: detail,
+			})
+		}
+		if e.PreviousHash != previous {
+			problem(ArchiveProblemChainBroken, "previous hash does not match the entry before")
+		} else if archiveChainHash(previous, e) != e.ChainHash {
+			problem(ArchiveProblemChainBroken, "entry does not match its chain hash")
+		}
+		previous = e.ChainHash
+
+		data, err := os.ReadFile(filepath.Join(a.dir, e.FileName))
+		if err != nil {
+			problem(ArchiveProblemMissing, err.Error())
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != e.SHA256 {
+			problem(ArchiveProblemModified, fmt.Sprintf("sha256 %x, recorded %s", sum, e.SHA256))
+		}
+	}
+
+	// Entries removed from the end leave a valid chain; only the head tells.
+	var headCount int
+	var headHash string
+	err = a.db.QueryRow(`SELECT entry_count, head_hash FROM invoice_archive_head WHERE id = 1`).Scan(&headCount, &headHash)
+	if err != nil && err != sql.ErrNoRows {
+		return v, err
+	}
+	if headCount != len(entries) || headHash != previous {
+		v.Problems = append(v.Problems, models.ArchiveProblem{
+			Problem: ArchiveProblemHeadMismatch,
+			Detail:  fmt.Sprintf("head records %d entries ending in %q, found %d ending in %q", headCount, headHash, len(entries), previous),
+		})
+	}
+	v.Valid = len(v.Problems) == 0
+
+	rows, err = a.db.Query(`
+		SELECT id, invoice_number, COALESCE(document_type, 'invoice'), status, total_amount, COALESCE(generated_at, '')
+		FROM invoices
+		WHERE status NOT IN (?, ?) AND COALESCE(pdf_path, '') <> ''
+		  AND id NOT IN (SELECT invoice_id FROM invoice_archive)
+		ORDER BY id
+	`, InvoiceStatusDraft, InvoiceStatusPreview)
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ref models.InvoiceRef
+		if err := rows.Scan(&ref.ID, &ref.InvoiceNumber, &ref.DocumentType, &ref.Status, &ref.TotalAmount, &ref.GeneratedAt); err != nil {
+			return v, err
+		}
+		v.Unarchived = append(v.Unarchived, ref)
+	}
+	return v, rows.Err()
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestInvoiceArchive(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	pg := NewPDFGenerator(db)
+	// Ghostscript is not needed for the chain: the stand-in conversion only
+	// has to change the bytes.
+	archive := &InvoiceArchive{db: db, dir: t.TempDir(), convert: func(pdf []byte) ([]byte, error) {
+		return append(pdf, "%PDF/A\n"...), nil
+	}}
+	pdfDir := t.TempDir()
+
+	var ids []int
+	var entries []*models.InvoiceArchiveEntry
+	for _, number := range []string{"INV-1", "INV-2"} {
+		id := insertInvoice(t, bs, number, 10, 1, 100)
+		pdf, err := pg.renderNativeInvoicePDF(InvoiceToMap(models.Invoice{InvoiceNumber: number, BuildingID: 1, Currency: "CHF", Status: "issued"}), SenderInfo{}, BankingInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(pdfDir, number+".pdf")
+		if err := os.WriteFile(path, pdf, 0644); err != nil {
+			t.Fatal(err)
+		}
+		e, err := archive.Archive(id, path)
+		if err != nil {
+			t.Fatalf("archive %s: %v", number, err)
+		}
+		ids, entries = append(ids, id), append(entries, e)
+	}
+	if entries[0].PreviousHash != "" || entries[1].PreviousHash != entries[0].ChainHash {
+		t.Errorf("chain %+v", entries)
+	}
+	archived, err := os.ReadFile(archive.Path(ids[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := os.ReadFile(filepath.Join(pdfDir, "INV-1.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Format != ArchiveFormatPDFA3B || !bytes.Equal(archived, append(issued, "%PDF/A\n"...)) {
+		t.Errorf("archived %q, not the converted copy", entries[0].Format)
+	}
+	if _, err := exec.LookPath("gs"); err == nil {
+		pdfa, err := ConvertToPDFA(issued)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(pdfa, []byte("pdfaid:part")) || !bytes.Contains(pdfa, []byte("/GTS_PDFA1")) {
+			t.Errorf("PDF/A copy lacks its identification or output intent")
+		}
+	}
+	if profile := srgbICCProfile(); int(binary.BigEndian.Uint32(profile)) != len(profile) || string(profile[36:40]) != "acsp" {
+		t.Errorf("ICC profile header")
+	}
+
+	// Archiving again keeps the first copy, even after the PDF changed.
+	if err := os.WriteFile(filepath.Join(pdfDir, "INV-1.pdf"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := archive.Archive(ids[0], filepath.Join(pdfDir, "INV-1.pdf")); err != nil || e.SHA256 != entries[0].SHA256 {
+		t.Errorf("re-archive: %+v %v", e, err)
+	}
+	draft := insertInvoice(t, bs, "INV-3", 10, 1, 100)
+	if _, err := db.Exec(`UPDATE invoices SET status = ?, pdf_path = 'INV-3.pdf' WHERE id = ?`, InvoiceStatusDraft, draft); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Archive(draft, filepath.Join(pdfDir, "INV-1.pdf")); err == nil {
+		t.Errorf("draft archived")
+	}
+
+	// A document that cannot be converted is not archived at all and stays
+	// listed as unarchived.
+	failing := &InvoiceArchive{db: db, dir: archive.dir, convert: func([]byte) ([]byte, error) {
+		return nil, fmt.Errorf("Ghostscript (gs) is not installed")
+	}}
+	unconverted := insertInvoice(t, bs, "INV-4", 10, 1, 100)
+	if _, err := db.Exec(`UPDATE invoices SET pdf_path = 'INV-4.pdf' WHERE id = ?`, unconverted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := failing.Archive(unconverted, filepath.Join(pdfDir, "INV-2.pdf")); err == nil {
+		t.Errorf("unconverted invoice archived")
+	}
+	if files, _ := os.ReadDir(archive.dir); len(files) != 2 {
+		t.Errorf("%d archive files, want 2", len(files))
+	}
+
+	if v, err := archive.Verify(); err != nil || !v.Valid || v.Checked != 2 ||
+		len(v.Unarchived) != 1 || v.Unarchived[0].ID != unconverted {
+		t.Fatalf("verify %+v %v", v, err)
+	}
+	if _, err := db.Exec(`DELETE FROM invoices WHERE id = ?`, ids[0]); err == nil {
+		t.Errorf("archived invoice deleted")
+	}
+	if _, err := db.Exec(`UPDATE invoice_archive SET sha256 = 'x' WHERE id = ?`, entries[0].ID); err == nil {
+		t.Errorf("archive entry changed")
+	}
+
+	// A modified and a missing file are both reported.
+	os.Chmod(archive.Path(ids[0]), 0644)
+	if err := os.WriteFile(archive.Path(ids[0]), append(archived, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(archive.Path(ids[1])); err != nil {
+		t.Fatal(err)
+	}
+	v, err := archive.Verify()
+	if err != nil || v.Valid || len(v.Problems) != 2 ||
+		v.Problems[0].Problem != ArchiveProblemModified || v.Problems[1].Problem != ArchiveProblemMissing {
+		t.Errorf("verify after tampering %+v %v", v, err)
+	}
+
+	// Dropping the newest entry together with its file leaves the chain
+	// intact but no longer matches the head.
+	if err := os.WriteFile(archive.Path(ids[0]), archived, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DROP TRIGGER invoice_archive_no_delete`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM invoice_archive WHERE id = ?`, entries[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	v, err = archive.Verify()
+	if err != nil || v.Valid || v.Checked != 1 || len(v.Problems) != 1 || v.Problems[0].Problem != ArchiveProblemHeadMismatch {
+		t.Errorf("verify after truncation %+v %v", v, err)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Counter reset policies of a numbering scheme.
+const (
+	NumberResetYearly = "yearly"
+	NumberResetNever  = "never"
+)
+
+// DefaultNumberPattern is used when a scheme is saved without a pattern.
+const DefaultNumberPattern = "{PREFIX}{YYYY}-{COUNTER}"
+
+// DefaultCreditNotePrefix is used when a scheme is saved without a credit
+// note prefix.
+const DefaultCreditNotePrefix = "CN-"
+
+// creditNoteCounterSeries keeps the credit note counter of a scheme apart
+// from its invoice counter in invoice_number_counters.period.
+const creditNoteCounterSeries = "credit_note"
+
+var numberPatternToken = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ValidateNumberScheme checks a scheme before it is stored: the pattern must
+// contain exactly one {COUNTER} and only known placeholders, and per-year
+// reset needs the year in the number so numbers stay unique.
+func ValidateNumberScheme(s models.InvoiceNumberScheme) error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.ResetPolicy != NumberResetYearly && s.ResetPolicy != NumberResetNever {
+		return fmt.Errorf("reset_policy must be %q or %q", NumberResetYearly, NumberResetNever)
+	}
+	if s.CounterDigits < 1 || s.CounterDigits > 10 {
+		return fmt.Errorf("counter_digits must be between 1 and 10")
+	}
+	counters := 0
+	for _, token := range numberPatternToken.FindAllString(s.Pattern, -1) {
+		switch token {
+		case "{COUNTER}":
+			counters++
+		case "{PREFIX}", "{YYYY}", "{YY}", "{BUILDING}":
+		default:
+			return fmt.Errorf("unknown placeholder %s in pattern", token)
+		}
+	}
+	if counters != 1 {
+		return fmt.Errorf("pattern must contain {COUNTER} exactly once")
+	}
+	if s.ResetPolicy == NumberResetYearly && !strings.Contains(s.Pattern, "{YYYY}") && !strings.Contains(s.Pattern, "{YY}") {
+		return fmt.Errorf("a yearly reset needs {YYYY} or {YY} in the pattern")
+	}
+	if s.PerBuilding && !strings.Contains(s.Pattern, "{BUILDING}") {
+		return fmt.Errorf("per-building counters need {BUILDING} in the pattern")
+	}
+	if strings.TrimSpace(s.CreditNotePrefix) == "" || s.CreditNotePrefix == s.Prefix {
+		return fmt.Errorf("credit_note_prefix is required and must differ from the prefix")
+	}
+	return nil
+}
+
+// FormatInvoiceNumber expands a scheme pattern for one counter value.
+func FormatInvoiceNumber(s models.InvoiceNumberScheme, buildingCode string, year, counter int) string {
+	return strings.NewReplacer(
+		"{PREFIX}", s.Prefix,
+		"{YYYY}", fmt.Sprintf("%04d", year),
+		"{YY}", fmt.Sprintf("%02d", year%100),
+		"{BUILDING}", buildingCode,
+		"{COUNTER}", fmt.Sprintf("%0*d", s.CounterDigits, counter),
+	).Replace(s.Pattern)
+}
+
+// creditNoteScheme is the scheme credit notes are numbered with: the same
+// pattern with the credit note prefix, which leads the number when the
+// pattern has no {PREFIX}.
+func creditNoteScheme(s models.InvoiceNumberScheme) models.InvoiceNumberScheme {
+	if !strings.Contains(s.Pattern, "{PREFIX}") {
+		s.Pattern = "{PREFIX}" + s.Pattern
+	}
+	s.Prefix = s.CreditNotePrefix
+	return s
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// resolveNumberScheme finds the scheme that numbers a building's documents:
+// the explicit override (an auto-billing config's scheme) or the building's
+// assignment. It returns nil when the building has no scheme.
+func resolveNumberScheme(q queryer, overrideID, buildingID int) (*models.InvoiceNumberScheme, string, error) {
+	var assignedID int
+	var code sql.NullString
+	err := q.QueryRow(`SELECT scheme_id, building_code FROM invoice_number_scheme_buildings WHERE building_id = ?`,
+		buildingID).Scan(&assignedID, &code)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "", err
+	}
+	schemeID := overrideID
+	if schemeID == 0 {
+		schemeID = assignedID
+	}
+	if schemeID == 0 {
+		return nil, "", nil
+	}
+
+	var s models.InvoiceNumberScheme
+	err = q.QueryRow(`
+		SELECT id, name, prefix, credit_note_prefix, pattern, counter_digits, reset_policy, per_building
+		FROM invoice_number_schemes WHERE id = ?
+	`, schemeID).Scan(&s.ID, &s.Name, &s.Prefix, &s.CreditNotePrefix, &s.Pattern, &s.CounterDigits, &s.ResetPolicy, &s.PerBuilding)
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("invoice number scheme %d not found", schemeID)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	buildingCode := strings.TrimSpace(code.String)
+	if buildingCode == "" {
+		buildingCode = strconv.Itoa(buildingID)
+	}
+	return &s, buildingCode, nil
+}
+
+// numberCounterKey is the counter a scheme draws from for a building and date.
+// Credit notes count in their own series.
+func numberCounterKey(s *models.InvoiceNumberScheme, buildingID int, issued time.Time, creditNote bool) (int, string) {
+	counterBuilding, period := 0, ""
+	if s.PerBuilding {
+		counterBuilding = buildingID
+	}
+	if s.ResetPolicy == NumberResetYearly {
+		period = strconv.Itoa(issued.Year())
+	}
+	if creditNote {
+		period = strings.TrimSuffix(creditNoteCounterSeries+":"+period, ":")
+	}
+	return counterBuilding, period
+}
+
+// allocateInvoiceNumber draws the next number of the building's scheme inside
+// the caller's invoice transaction, so the counter only advances when the
+// invoice row is committed: a failed insert rolls the counter back and no gap
+// appears. The year is the issue year. It returns schemeID 0 when the
+// building has no scheme and the caller's legacy number applies.
+func allocateInvoiceNumber(tx *sql.Tx, overrideID, buildingID int, issued time.Time) (string, int, error) {
+	return allocateDocumentNumber(tx, overrideID, buildingID, issued, false)
+}
+
+// allocateCreditNoteNumber is allocateInvoiceNumber for credit notes, which
+// carry the scheme's credit note prefix and have a gap-free counter of their
+// own. overrideID is the scheme of the invoice being credited.
+func allocateCreditNoteNumber(tx *sql.Tx, overrideID, buildingID int, issued time.Time) (string, int, error) {
+	return allocateDocumentNumber(tx, overrideID, buildingID, issued, true)
+}
+
+func allocateDocumentNumber(tx *sql.Tx, overrideID, buildingID int, issued time.Time, creditNote bool) (string, int, error) {
+	s, buildingCode, err := resolveNumberScheme(tx, overrideID, buildingID)
+	if err != nil || s == nil {
+		return "", 0, err
+	}
+	counterBuilding, period := numberCounterKey(s, buildingID, issued, creditNote)
+	if _, err := tx.Exec(`
+		INSERT INTO invoice_number_counters (scheme_id, building_id, period, last_value)
+		VALUES (?, ?, ?, 1)
+		ON CONFLICT(scheme_id, building_id, period) DO UPDATE SET last_value = last_value + 1
+	`, s.ID, counterBuilding, period); err != nil {
+		return "", 0, fmt.Errorf("failed to advance invoice number counter: %v", err)
+	}
+	var counter int
+	if err := tx.QueryRow(`
+		SELECT last_value FROM invoice_number_counters
+		WHERE scheme_id = ? AND building_id = ? AND period = ?
+	`, s.ID, counterBuilding, period).Scan(&counter); err != nil {
+		return "", 0, err
+	}
+	format := *s
+	if creditNote {
+		format = creditNoteScheme(format)
+	}
+	return FormatInvoiceNumber(format, buildingCode, issued.Year(), counter), s.ID, nil
+}
+
+// PeekInvoiceNumber returns the number the next invoice of a scheme for a
+// building would get, without allocating it.
+func PeekInvoiceNumber(db *sql.DB, schemeID, buildingID int, issued time.Time) (string, error) {
+	s, buildingCode, err := resolveNumberScheme(db, schemeID, buildingID)
+	if err != nil {
+		return "", err
+	}
+	if s == nil {
+		return "", fmt.Errorf("building %d has no invoice number scheme", buildingID)
+	}
+	counterBuilding, period := numberCounterKey(s, buildingID, issued, false)
+	var last int
+	db.QueryRow(`
+		SELECT last_value FROM invoice_number_counters
+		WHERE scheme_id = ? AND building_id = ? AND period = ?
+	`, s.ID, counterBuilding, period).Scan(&last)
+	return FormatInvoiceNumber(*s, buildingCode, issued.Year(), last+1), nil
+}
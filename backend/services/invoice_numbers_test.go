@@ -0,0 +1,84 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestInvoiceNumberSequence(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES (10,'Anna','Muster','a@b.c',1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_schemes (id, name, prefix, pattern, counter_digits, reset_policy, per_building)
+		VALUES (1, 'Sonnenhof', 'ZEV-', '{PREFIX}{YYYY}-{BUILDING}-{COUNTER}', 4, 'yearly', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_scheme_buildings (building_id, scheme_id, building_code) VALUES (1, 1, 'SH')`); err != nil {
+		t.Fatal(err)
+	}
+
+	items := []models.InvoiceItem{{Description: "Normal power", TotalPrice: 10, ItemType: "normal_power"}}
+	insert := func(b *BillingService, legacy string) (string, error) {
+		_, number, err := b.insertInvoiceWithItems(legacy, 10, 1, "2026-01-01", "2026-01-31", 10, 10, 0, 0, false, "CHF", false, items)
+		return number, err
+	}
+	year := time.Now().Year()
+
+	if n, _ := insert(&BillingService{db: db, dryRun: true}, "INV-legacy-0"); n != "INV-legacy-0" {
+		t.Errorf("dry run number = %s", n)
+	}
+	first, err := insert(bs, "INV-legacy-1")
+	if err != nil || first != fmt.Sprintf("ZEV-%d-SH-0001", year) {
+		t.Fatalf("first number = %s, %v", first, err)
+	}
+	// A failed insert must not consume a number.
+	if _, err := db.Exec(`INSERT INTO invoices (invoice_number, user_id, building_id, period_start, period_end, total_amount, currency, status)
+		VALUES (?, 10, 1, '2026-01-01', '2026-01-31', 0, 'CHF', 'issued')`, fmt.Sprintf("ZEV-%d-SH-0002", year)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := insert(bs, "INV-legacy-2"); err == nil {
+		t.Fatal("expected a duplicate number error")
+	}
+	if _, err := db.Exec(`DELETE FROM invoices WHERE number_scheme_id IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+	second, err := insert(bs, "INV-legacy-3")
+	if err != nil || second != fmt.Sprintf("ZEV-%d-SH-0002", year) {
+		t.Fatalf("second number = %s, %v", second, err)
+	}
+	if next, _ := PeekInvoiceNumber(db, 0, 1, time.Now()); next != fmt.Sprintf("ZEV-%d-SH-0003", year) {
+		t.Errorf("next number = %s", next)
+	}
+
+	// Credit notes draw from the scheme's own credit note series.
+	var secondID int
+	if err := db.QueryRow(`SELECT id FROM invoices WHERE invoice_number = ?`, second).Scan(&secondID); err != nil {
+		t.Fatal(err)
+	}
+	credit, err := bs.CancelInvoice(secondID, "wrong tariff")
+	if err != nil || credit.InvoiceNumber != fmt.Sprintf("CN-%d-SH-0001", year) {
+		t.Fatalf("credit note number = %+v, %v", credit, err)
+	}
+	var creditScheme sql.NullInt64
+	db.QueryRow(`SELECT number_scheme_id FROM invoices WHERE id = ?`, credit.ID).Scan(&creditScheme)
+	if creditScheme.Int64 != 1 {
+		t.Errorf("credit note scheme = %v", creditScheme)
+	}
+	if next, _ := PeekInvoiceNumber(db, 0, 1, time.Now()); next != fmt.Sprintf("ZEV-%d-SH-0003", year) {
+		t.Errorf("next number after credit note = %s", next)
+	}
+
+	if err := ValidateNumberScheme(models.InvoiceNumberScheme{Name: "x", Pattern: "{PREFIX}-{COUNTER}", CounterDigits: 5, ResetPolicy: NumberResetYearly}); err == nil {
+		t.Error("yearly reset without a year placeholder was accepted")
+	}
+	if err := ValidateNumberScheme(models.InvoiceNumberScheme{Name: "x", Prefix: "R-", CreditNotePrefix: "R-", Pattern: "{PREFIX}{COUNTER}", CounterDigits: 5, ResetPolicy: NumberResetNever}); err == nil {
+		t.Error("credit note prefix equal to the prefix was accepted")
+	}
+}
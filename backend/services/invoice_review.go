@@ -0,0 +1,421 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// InvoiceStatusDraft marks an invoice generated by an auto-billing config
+// with require_approval that waits in the review queue. A draft has no
+// number from a scheme, no due date that counts and is not payable; it is
+// deleted to reject it.
+const InvoiceStatusDraft = "draft"
+
+// Review states of an invoice that went through the review queue.
+const (
+	ReviewDraft    = "draft"
+	ReviewApproved = "approved"
+	ReviewSent     = "sent"
+)
+
+// reviewJumpThreshold is the relative change of consumption against the
+// previous period above which a draft is flagged.
+const reviewJumpThreshold = 0.5
+
+// invoiceConsumption sums the consumption lines of an invoice: the meter
+// reading summaries and the charging session summaries. ok is false when the
+// invoice bills no consumption at all (e.g. only custom items).
+func invoiceConsumption(items []models.InvoiceItem) (total float64, ok bool) {
+	for _, item := range items {
+		if item.ItemType == "meter_reading_compact" || item.ItemType == "charging_session_compact" {
+			total += item.Quantity
+			ok = true
+		}
+	}
+	return total, ok
+}
+
+// ReviewWarnings checks a stored invoice for what an admin should look at
+// before approving it: zero consumption, a consumption jump of more than 50%
+// against the tenant's previous invoice, and days of the period without
+// readings on one of the tenant's meters.
+func ReviewWarnings(db *sql.DB, invoiceID int) ([]models.ReviewWarning, error) {
+	var userID, buildingID int
+	var documentType, periodStart, periodEnd string
+	err := db.QueryRow(`
+		SELECT user_id, building_id, COALESCE(document_type, 'invoice'), period_start, period_end
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&userID, &buildingID, &documentType, &periodStart, &periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	warnings := []models.ReviewWarning{}
+	if documentType != DocumentTypeInvoice {
+		return warnings, nil
+	}
+	periodStart, periodEnd = storedDay(periodStart), storedDay(periodEnd)
+
+	items, err := loadDocumentItems(db, []int{invoiceID})
+	if err != nil {
+		return nil, err
+	}
+	consumption, billsConsumption := invoiceConsumption(items)
+	if billsConsumption && consumption < recalcQuantityEpsilon {
+		warnings = append(warnings, models.ReviewWarning{
+			Code:    "zero_consumption",
+			Message: "No consumption in the billing period",
+		})
+	}
+
+	// Previous issued invoice of the tenant in the building.
+	var prevID int
+	var prevNumber string
+	err = db.QueryRow(`
+		SELECT id, invoice_number FROM invoices
+		WHERE user_id = ? AND building_id = ? AND COALESCE(document_type, 'invoice') = ?
+		  AND status = ? AND id != ? AND substr(period_end, 1, 10) < ?
+		ORDER BY period_end DESC, id DESC LIMIT 1
+	`, userID, buildingID, DocumentTypeInvoice, InvoiceStatusIssued, invoiceID, periodStart).Scan(&prevID, &prevNumber)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if prevID != 0 && billsConsumption {
+		prevItems, err := loadDocumentItems(db, []int{prevID})
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := invoiceConsumption(prevItems); ok && prev > recalcQuantityEpsilon {
+			if change := (consumption - prev) / prev; math.Abs(change) > reviewJumpThreshold {
+				warnings = append(warnings, models.ReviewWarning{
+					Code: "consumption_jump",
+					Message: fmt.Sprintf("Consumption %.3f against %.3f on invoice %s (%+.0f%%)",
+						consumption, prev, prevNumber, change*100),
+				})
+			}
+		}
+	}
+
+	missing, err := missingReadingDays(db, userID, buildingID, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	return append(warnings, missing...), nil
+}
+
+// missingReadingDays reports each active meter of a tenant in a building that
+// has days of [start, end] without a single reading.
+func missingReadingDays(db *sql.DB, userID, buildingID int, start, end string) ([]models.ReviewWarning, error) {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid period start: %v", err)
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid period end: %v", err)
+	}
+	to = to.AddDate(0, 0, 1)
+
+	rows, err := db.Query(`
+		SELECT id, name FROM meters
+		WHERE user_id = ? AND building_id = ? AND is_active = 1 AND COALESCE(is_archived, 0) = 0
+		ORDER BY id
+	`, userID, buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meters: %v", err)
+	}
+	type meter struct {
+		id   int
+		name string
+	}
+	var meters []meter
+	for rows.Next() {
+		var m meter
+		if err := rows.Scan(&m.id, &m.name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		meters = append(meters, m)
+	}
+	rows.Close()
+
+	var warnings []models.ReviewWarning
+	for _, m := range meters {
+		days, err := readingDays(db, m.id, from, to)
+		if err != nil {
+			return nil, err
+		}
+		var gaps []string
+		total := 0
+		for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+			total++
+			if !days[d.Format("2006-01-02")] {
+				gaps = append(gaps, d.Format("02.01.2006"))
+			}
+		}
+		if len(gaps) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("Meter %s: no readings on %d of %d days", m.name, len(gaps), total)
+		if len(gaps) <= 3 {
+			msg += " (" + strings.Join(gaps, ", ") + ")"
+		} else {
+			msg += fmt.Sprintf(" (%s to %s)", gaps[0], gaps[len(gaps)-1])
+		}
+		warnings = append(warnings, models.ReviewWarning{Code: "missing_data", Message: msg})
+	}
+	return warnings, nil
+}
+
+// readingDays returns the days of [from, to) on which a meter has readings.
+func readingDays(db *sql.DB, meterID int, from, to time.Time) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT reading_time FROM meter_readings
+		WHERE meter_id = ? AND reading_time >= ? AND reading_time < ?
+	`, meterID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %v", err)
+	}
+	defer rows.Close()
+
+	days := make(map[string]bool)
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			return nil, err
+		}
+		days[ts.Format("2006-01-02")] = true
+	}
+	return days, rows.Err()
+}
+
+// LoadReviewQueue returns the draft invoices waiting for approval with their
+// items, recipient and warnings, oldest first. configID 0 lists the drafts of
+// all configs.
+func LoadReviewQueue(db *sql.DB, configID int) ([]models.Invoice, error) {
+	query := `
+		SELECT id, invoice_number, user_id, building_id, period_start, period_end,
+		       total_amount, COALESCE(net_amount, 0), COALESCE(vat_amount, 0), COALESCE(vat_rate, 0),
+		       COALESCE(vat_included, 0), currency, status, COALESCE(is_vzev, 0),
+		       COALESCE(document_type, 'invoice'), COALESCE(review_warnings, ''),
+		       auto_billing_config_id, generated_at
+		FROM invoices
+		WHERE status = ?`
+	args := []interface{}{InvoiceStatusDraft}
+	if configID > 0 {
+		query += ` AND auto_billing_config_id = ?`
+		args = append(args, configID)
+	}
+	rows, err := db.Query(query+` ORDER BY generated_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drafts: %v", err)
+	}
+
+	invoices := []models.Invoice{}
+	var ids []int
+	for rows.Next() {
+		var inv models.Invoice
+		var warningsJSON string
+		var cfg sql.NullInt64
+		if err := rows.Scan(&inv.ID, &inv.InvoiceNumber, &inv.UserID, &inv.BuildingID,
+			&inv.PeriodStart, &inv.PeriodEnd, &inv.TotalAmount, &inv.NetAmount, &inv.VATAmount, &inv.VATRate,
+			&inv.VATIncluded, &inv.Currency, &inv.Status, &inv.IsVZEV, &inv.DocumentType, &warningsJSON,
+			&cfg, &inv.GeneratedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inv.PeriodStart, inv.PeriodEnd = storedDay(inv.PeriodStart), storedDay(inv.PeriodEnd)
+		inv.ReviewStatus = ReviewDraft
+		if warningsJSON != "" {
+			json.Unmarshal([]byte(warningsJSON), &inv.ReviewWarnings)
+		}
+		if cfg.Valid {
+			id := int(cfg.Int64)
+			inv.AutoBillingConfigID = &id
+		}
+		invoices = append(invoices, inv)
+		ids = append(ids, inv.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := loadDocumentItems(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	byInvoice := make(map[int][]models.InvoiceItem)
+	for _, item := range items {
+		byInvoice[item.InvoiceID] = append(byInvoice[item.InvoiceID], item)
+	}
+	for i := range invoices {
+		invoices[i].Items = byInvoice[invoices[i].ID]
+		invoices[i].User = loadInvoiceUser(db, invoices[i].UserID)
+	}
+	return invoices, nil
+}
+
+// queueForReview stores the review state and warnings of freshly generated
+// drafts of an auto-billing config.
+func queueForReview(db *sql.DB, configID, invoiceID int) ([]models.ReviewWarning, error) {
+	warnings, err := ReviewWarnings(db, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	warningsJSON, _ := json.Marshal(warnings)
+	if _, err := db.Exec(`
+		UPDATE invoices SET review_status = ?, review_warnings = ?, auto_billing_config_id = ?
+		WHERE id = ?
+	`, ReviewDraft, string(warningsJSON), configID, invoiceID); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// ApprovalResult summarises the approval of draft invoices.
+type ApprovalResult struct {
+	Approved      int      `json:"approved"`
+	InvoiceIDs    []int    `json:"invoice_ids"`
+	PDFsGenerated int      `json:"pdfs_generated"`
+	EmailsSent    int      `json:"emails_sent"`
+	EmailsFailed  int      `json:"emails_failed"`
+	Warnings      []string `json:"warnings"`
+}
+
+// ApproveInvoices issues draft invoices: each gets its number from the
+// scheme of its config (or building), is dated today with a fresh due date,
+// and only then is its PDF rendered and, when the config has auto_send_email
+// set, e-mailed to the tenant. All ids must be drafts; nothing is approved
+// otherwise.
+func (s *AutoBillingScheduler) ApproveInvoices(ids []int) (*ApprovalResult, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no invoices to approve")
+	}
+	type draft struct {
+		id, buildingID, configID int
+	}
+	drafts := make([]draft, 0, len(ids))
+	for _, id := range ids {
+		d := draft{id: id}
+		var status string
+		var cfg sql.NullInt64
+		err := s.db.QueryRow(`SELECT status, building_id, auto_billing_config_id FROM invoices WHERE id = ?`, id).
+			Scan(&status, &d.buildingID, &cfg)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invoice %d not found", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status != InvoiceStatusDraft {
+			return nil, fmt.Errorf("invoice %d is not a draft (status %s)", id, status)
+		}
+		d.configID = int(cfg.Int64)
+		drafts = append(drafts, d)
+	}
+
+	result := &ApprovalResult{InvoiceIDs: []int{}, Warnings: []string{}}
+	settings := make(map[int]*deliverySettings)
+	for _, d := range drafts {
+		ds, loaded := settings[d.configID]
+		if !loaded {
+			var err error
+			if ds, err = s.loadDeliverySettings(d.configID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Invoice %d: %v", d.id, err))
+				ds = &deliverySettings{}
+			}
+			settings[d.configID] = ds
+		}
+
+		if err := s.approveDraft(d.id, d.buildingID, ds.numberSchemeID); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to approve invoice %d: %v", d.id, err))
+			continue
+		}
+		result.Approved++
+		result.InvoiceIDs = append(result.InvoiceIDs, d.id)
+
+		delivery := s.deliverInvoice(d.configID, d.id, ds.sender, ds.banking, ds.autoSendEmail)
+		result.Warnings = append(result.Warnings, delivery.warnings...)
+		if delivery.pdf {
+			result.PDFsGenerated++
+		}
+		if delivery.emailed {
+			result.EmailsSent++
+			s.db.Exec(`UPDATE invoices SET review_status = ?, sent_at = ? WHERE id = ?`, ReviewSent, time.Now(), d.id)
+		}
+		if delivery.failed {
+			result.EmailsFailed++
+		}
+	}
+	log.Printf("SUCCESS: Approved %d of %d draft invoice(s)", result.Approved, len(drafts))
+	return result, nil
+}
+
+// approveDraft turns a draft into an issued invoice in one transaction, so a
+// failed update does not consume a scheme number.
+func (s *AutoBillingScheduler) approveDraft(id, buildingID, numberSchemeID int) error {
+	now := time.Now()
+	dueDate := PaymentDueDate(s.db, buildingID, now)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	number, schemeID, err := allocateInvoiceNumber(tx, numberSchemeID, buildingID, now)
+	if err != nil {
+		return fmt.Errorf("failed to allocate invoice number: %v", err)
+	}
+	if schemeID != 0 {
+		if _, err := tx.Exec(`UPDATE invoices SET invoice_number = ?, number_scheme_id = ? WHERE id = ?`,
+			number, schemeID, id); err != nil {
+			return err
+		}
+	}
+	res, err := tx.Exec(`
+		UPDATE invoices
+		SET status = ?, review_status = ?, approved_at = ?, generated_at = ?, due_date = ?
+		WHERE id = ? AND status = ?
+	`, InvoiceStatusIssued, ReviewApproved, now, now, dueDate, id, InvoiceStatusDraft)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("invoice is no longer a draft")
+	}
+	if _, err := applyCreditBalance(tx, id); err != nil {
+		return fmt.Errorf("failed to apply credit balance: %v", err)
+	}
+	return tx.Commit()
+}
+
+// RenderDrafts renders draft invoices with the sender and banking details of
+// their configs, the way PreviewConfig renders a dry run.
+func (s *AutoBillingScheduler) RenderDrafts(drafts []models.Invoice, withPDF bool) []InvoicePreview {
+	previews := make([]InvoicePreview, 0, len(drafts))
+	settings := make(map[int]*deliverySettings)
+	for _, inv := range drafts {
+		configID := 0
+		if inv.AutoBillingConfigID != nil {
+			configID = *inv.AutoBillingConfigID
+		}
+		ds, loaded := settings[configID]
+		if !loaded {
+			var err error
+			if ds, err = s.loadDeliverySettings(configID); err != nil {
+				ds = &deliverySettings{}
+			}
+			settings[configID] = ds
+		}
+		inv.Status = InvoiceStatusPreview
+		previews = append(previews, RenderInvoicePreviews(s.pdfGenerator, []models.Invoice{inv}, ds.sender, ds.banking, withPDF)...)
+	}
+	return previews
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestInvoiceApproval(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertPricing(t, db, 1, "2025-12-01", "", 0.25)
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id, is_active) VALUES (10,'Anna','Muster','a@b.c',1,1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_schemes (id, name, prefix, pattern, counter_digits, reset_policy, per_building)
+		VALUES (1, 'Sonnenhof', 'ZEV-', '{PREFIX}{YYYY}-{BUILDING}-{COUNTER}', 4, 'yearly', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO invoice_number_scheme_buildings (building_id, scheme_id, building_code) VALUES (1, 1, 'SH')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO auto_billing_configs (id, name, building_ids, frequency, generation_day, require_approval)
+		VALUES (1, 'Monthly', '1', 'monthly', 1, 1)`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 2, "Wohnung A", "apartment_meter", 1, 10)
+	for d := 1; d <= 31; d++ {
+		insertZEVReading(t, db, 2, time.Date(2025, 12, d, 12, 0, 0, 0, time.Local), 10, 0)
+	}
+	insertZEVReading(t, db, 2, time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local), 20, 0)
+
+	bs := NewBillingService(db)
+	if _, _, err := bs.GenerateBillsWithOptions([]int{1}, []int{10}, "2025-12-01", "2025-12-31", false, nil, BillingScope{}); err != nil {
+		t.Fatal(err)
+	}
+	drafts, _, err := bs.AsDrafts().GenerateBillsWithOptions([]int{1}, []int{10}, "2026-01-01", "2026-01-31", false, nil, BillingScope{})
+	if err != nil || len(drafts) != 1 {
+		t.Fatalf("generate drafts: %d, %v", len(drafts), err)
+	}
+	if drafts[0].Status != InvoiceStatusDraft || drafts[0].DocumentType != DocumentTypeInvoice {
+		t.Errorf("returned draft %s %s", drafts[0].Status, drafts[0].DocumentType)
+	}
+	id := drafts[0].ID
+	var status string
+	var scheme sql.NullInt64
+	db.QueryRow(`SELECT status, number_scheme_id FROM invoices WHERE id = ?`, id).Scan(&status, &scheme)
+	if status != InvoiceStatusDraft || scheme.Valid {
+		t.Fatalf("draft status %s, scheme %v", status, scheme)
+	}
+
+	warnings, err := queueForReview(db, 1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string]bool{}
+	for _, w := range warnings {
+		codes[w.Code] = true
+	}
+	if !codes["consumption_jump"] || !codes["missing_data"] || codes["zero_consumption"] {
+		t.Errorf("warnings %+v", warnings)
+	}
+	queue, err := LoadReviewQueue(db, 1)
+	if err != nil || len(queue) != 1 || len(queue[0].ReviewWarnings) != len(warnings) || len(queue[0].Items) == 0 {
+		t.Fatalf("review queue %+v, %v", queue, err)
+	}
+
+	s := &AutoBillingScheduler{db: db}
+	if err := s.approveDraft(id, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var number, review string
+	db.QueryRow(`SELECT status, invoice_number, review_status FROM invoices WHERE id = ?`, id).Scan(&status, &number, &review)
+	if status != InvoiceStatusIssued || review != ReviewApproved || number != fmt.Sprintf("ZEV-%d-SH-0002", time.Now().Year()) {
+		t.Errorf("approved invoice %s %s %s", status, number, review)
+	}
+	if err := s.approveDraft(id, 1, 0); err == nil {
+		t.Error("invoice approved twice")
+	}
+	if queue, _ := LoadReviewQueue(db, 0); len(queue) != 0 {
+		t.Errorf("approved invoice still queued")
+	}
+}
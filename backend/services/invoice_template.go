@@ -0,0 +1,340 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// builtinInvoiceTemplate is the invoice layout of buildings without a
+// template of their own, and the fallback when a custom one fails.
+//
+//go:embed templates/invoice.html
+var builtinInvoiceTemplate string
+
+// BuiltinInvoiceTemplate returns the source of the built-in invoice template,
+// the starting point for custom ones.
+func BuiltinInvoiceTemplate() string {
+	return builtinInvoiceTemplate
+}
+
+// invoiceTemplateFuncs are the functions invoice templates can call.
+var invoiceTemplateFuncs = template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"rate":  func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"date":  formatDate,
+	"iban":  formatIBAN,
+	"upper": strings.ToUpper,
+}
+
+var defaultInvoiceTemplate = template.Must(template.New("invoice").Funcs(invoiceTemplateFuncs).Parse(builtinInvoiceTemplate))
+
+// InvoiceTemplateData is the data model of invoice templates. Dates are
+// formatted as DD.MM.YYYY, amounts are plain numbers (see the money
+// function).
+type InvoiceTemplateData struct {
+	Invoice InvoiceTemplateInvoice
+	Items   []InvoiceTemplateItem
+	User    InvoiceTemplateUser
+	Sender  SenderInfo
+	Banking BankingInfo
+	// ShowPayment is false when nothing is payable (credit notes, refunds,
+	// fully credited invoices, previews) or no bank account is set.
+	ShowPayment bool
+	QR          InvoiceTemplateQR
+	Building    InvoiceTemplateBuilding
+	Tr          InvoiceTranslations
+	// ConsumptionChart is the consumption history block when the bill
+	// layout enables it.
+	ConsumptionChart template.HTML
+	RenderedAt       string
+}
+
+// InvoiceTemplateInvoice is the document being rendered.
+type InvoiceTemplateInvoice struct {
+	Number           string
+	Title            string // layout title, or the document type's translated title
+	DocumentType     string
+	OriginalNumber   string // the invoice a credit note or correction refers to
+	Status           string
+	StatusColor      string
+	StatusBackground string
+	PeriodStart      string
+	PeriodEnd        string
+	GeneratedAt      string
+	DueDate          string
+	Currency         string
+	Total            float64
+	NetAmount        float64
+	VATAmount        float64
+	VATRate          float64
+	VATIncluded      bool
+	// VATSummary splits the VAT per rate when the lines are billed at more
+	// than one; empty otherwise.
+	VATSummary    []models.VATSummaryLine
+	CreditApplied float64
+	AmountDue     float64
+	Intro         []string // text blocks above the lines
+	Footer        string   // text block below the totals
+}
+
+// InvoiceTemplateItem is one invoice line. Type is the item_type
+// (solar_power, meter_info, separator, ...); Currency repeats the invoice's.
+type InvoiceTemplateItem struct {
+	Type        string
+	Description string
+	Quantity    float64
+	UnitPrice   float64
+	Total       float64
+	Currency    string
+}
+
+// InvoiceTemplateUser is the billed tenant.
+type InvoiceTemplateUser struct {
+	Name      string
+	FirstName string
+	LastName  string
+	Email     string
+	Street    string
+	Zip       string
+	City      string
+	Country   string
+	Language  string
+	Archived  bool
+}
+
+// InvoiceTemplateQR holds the Swiss QR-bill payload, empty when no valid
+// QR-bill could be built.
+type InvoiceTemplateQR struct {
+	Payload string
+}
+
+// InvoiceTemplateBuilding is the building the invoice is for.
+type InvoiceTemplateBuilding struct {
+	ID          int
+	Name        string
+	AccentColor string
+}
+
+// VATLabel names a VAT summary line: its rate, or the exempt label.
+func (d InvoiceTemplateData) VATLabel(l models.VATSummaryLine) string {
+	return vatSummaryLabel(l, d.Tr)
+}
+
+// ParseInvoiceTemplate parses a custom invoice template and renders it
+// against sample invoices, so errors that only show when executing (unknown
+// fields, wrong argument types) are reported on upload.
+func ParseInvoiceTemplate(src string) (*template.Template, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("template is empty")
+	}
+	t, err := template.New("invoice").Funcs(invoiceTemplateFuncs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range sampleInvoiceTemplateData(GetTranslations("de")) {
+		if err := t.Execute(io.Discard, data); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// sampleInvoiceTemplateData returns sample invoices covering the template's
+// branches: a payable invoice with lines at two VAT rates and applied
+// credit, and a credit note at a single rate.
+func sampleInvoiceTemplateData(tr InvoiceTranslations) []InvoiceTemplateData {
+	items := []InvoiceTemplateItem{
+		{Type: "meter_info", Description: tr.ApartmentMeter + ": Wohnung 1"},
+		{Type: "meter_reading_compact", Description: tr.Consumption + ": 400.000 kWh", Quantity: 400},
+		{Type: "separator"},
+		{Type: "solar_power", Description: tr.SolarPower + ": 150.000 kWh × 0.200 CHF/kWh", Quantity: 150, UnitPrice: 0.2, Total: 30},
+		{Type: "battery_power", Description: tr.BatteryPower + ": 50.000 kWh × 0.220 CHF/kWh", Quantity: 50, UnitPrice: 0.22, Total: 11},
+		{Type: "normal_power", Description: tr.NormalPower + ": 200.000 kWh × 0.295 CHF/kWh", Quantity: 200, UnitPrice: 0.295, Total: 59},
+		{Type: "custom_item", Description: "Zählermiete", Quantity: 1, UnitPrice: 5, Total: 5},
+	}
+	for i := range items {
+		items[i].Currency = "CHF"
+	}
+	invoice := InvoiceTemplateData{
+		Invoice: InvoiceTemplateInvoice{
+			Number: "INV-2026-001", Title: tr.Invoice, DocumentType: DocumentTypeInvoice,
+			Status: "issued", StatusColor: "#155724", StatusBackground: "#d4edda",
+			PeriodStart: "01.01.2026", PeriodEnd: "31.03.2026", GeneratedAt: "01.04.2026", DueDate: "01.05.2026",
+			Currency: "CHF", Total: 113.12, NetAmount: 104.89, VATAmount: 8.23, VATRate: 8.1,
+			VATSummary: []models.VATSummaryLine{
+				{Code: VATCodeStandard, Rate: 8.1, Net: 100, VAT: 8.1, Gross: 108.1},
+				{Code: "reduced", Rate: 2.6, Net: 4.89, VAT: 0.13, Gross: 5.02},
+			},
+			CreditApplied: 10, AmountDue: 103.12,
+			Intro:  []string{"Intro"},
+			Footer: "Footer",
+		},
+		Items: items,
+		User: InvoiceTemplateUser{
+			Name: "Anna Muster", FirstName: "Anna", LastName: "Muster", Email: "anna@example.ch",
+			Street: "Sonnenweg 1", Zip: "8000", City: "Zürich", Country: "CH", Language: "de",
+		},
+		Sender:           SenderInfo{Name: "ZEV Sonnenweg", Address: "Sonnenweg 1", Zip: "8000", City: "Zürich", Country: "CH"},
+		Banking:          BankingInfo{Name: "Bank", IBAN: "CH9300762011623852957", AccountHolder: "ZEV Sonnenweg"},
+		ShowPayment:      true,
+		QR:               InvoiceTemplateQR{Payload: "SPC\n0200\n1"},
+		Building:         InvoiceTemplateBuilding{ID: 1, Name: "Sonnenweg", AccentColor: "#667EEA"},
+		Tr:               tr,
+		ConsumptionChart: template.HTML(`<div class="consumption-chart"></div>`),
+		RenderedAt:       "01.04.2026 08:00",
+	}
+
+	creditNote := invoice
+	creditNote.Invoice.Title = tr.CreditNote
+	creditNote.Invoice.DocumentType = DocumentTypeCreditNote
+	creditNote.Invoice.OriginalNumber = invoice.Invoice.Number
+	creditNote.Invoice.VATSummary = nil
+	creditNote.Invoice.VATIncluded = true
+	creditNote.Invoice.CreditApplied = 0
+	creditNote.Invoice.DueDate = ""
+	creditNote.User.Archived = true
+	creditNote.ShowPayment = false
+	creditNote.QR = InvoiceTemplateQR{}
+	creditNote.ConsumptionChart = ""
+	return []InvoiceTemplateData{invoice, creditNote}
+}
+
+// NativeRendererTemplateWarning is reported with template saves and previews
+// while the native renderer is active, since it never uses custom templates.
+const NativeRendererTemplateWarning = "The native PDF renderer is active: invoice PDFs use the built-in layout and ignore custom templates until an external converter (wkhtmltopdf or Chromium) is used"
+
+// cachedInvoiceTemplate is a parsed custom template and the updated_at of the
+// row it was parsed from; t is nil when the source does not parse.
+type cachedInvoiceTemplate struct {
+	updatedAt string
+	t         *template.Template
+}
+
+// invoiceTemplateFor returns the custom invoice template of buildingID, nil
+// when it has none. Parsed templates are cached until the row's updated_at
+// changes. A stored template that no longer parses is logged and skipped.
+func (pg *PDFGenerator) invoiceTemplateFor(buildingID int) *template.Template {
+	if buildingID == 0 || pg.db == nil {
+		return nil
+	}
+	var updatedAt string
+	if err := pg.db.QueryRow(`SELECT COALESCE(updated_at, '') FROM invoice_templates WHERE building_id = ?`, buildingID).Scan(&updatedAt); err != nil {
+		return nil
+	}
+	pg.templateMu.Lock()
+	cached, ok := pg.templates[buildingID]
+	pg.templateMu.Unlock()
+	if ok && cached.updatedAt == updatedAt {
+		return cached.t
+	}
+
+	var src string
+	if err := pg.db.QueryRow(`SELECT content FROM invoice_templates WHERE building_id = ?`, buildingID).Scan(&src); err != nil {
+		return nil
+	}
+	t, err := template.New("invoice").Funcs(invoiceTemplateFuncs).Parse(src)
+	if err != nil {
+		log.Printf("WARNING: Invoice template of building %d does not parse, using the built-in one: %v", buildingID, err)
+		t = nil
+	}
+	pg.templateMu.Lock()
+	if pg.templates == nil {
+		pg.templates = make(map[int]cachedInvoiceTemplate)
+	}
+	pg.templates[buildingID] = cachedInvoiceTemplate{updatedAt: updatedAt, t: t}
+	pg.templateMu.Unlock()
+	return t
+}
+
+// ForgetInvoiceTemplate drops the cached template of buildingID. updated_at
+// has one-second resolution, so saves call this rather than rely on it alone.
+func (pg *PDFGenerator) ForgetInvoiceTemplate(buildingID int) {
+	pg.templateMu.Lock()
+	delete(pg.templates, buildingID)
+	pg.templateMu.Unlock()
+}
+
+// executeInvoiceTemplate renders data with t, falling back to the built-in
+// template when t is nil or fails.
+func executeInvoiceTemplate(t *template.Template, data InvoiceTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if t != nil {
+		err := t.Execute(&buf, data)
+		if err == nil {
+			return buf.String(), nil
+		}
+		log.Printf("WARNING: Invoice template of building %d failed on %s, using the built-in one: %v",
+			data.Building.ID, data.Invoice.Number, err)
+		buf.Reset()
+	}
+	if err := defaultInvoiceTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderInvoiceTemplatePreview renders a sample invoice of buildingID with
+// the template source src (the built-in template when empty) in the given
+// language and, when withPDF is set, converts it to PDF. Templates only apply
+// to the HTML converter path, so the PDF always goes through the external
+// converter. Template errors are returned as is.
+func (pg *PDFGenerator) RenderInvoiceTemplatePreview(src string, buildingID int, language string, sender SenderInfo, banking BankingInfo, withPDF bool) (string, []byte, error) {
+	t := defaultInvoiceTemplate
+	if src != "" {
+		var err error
+		if t, err = ParseInvoiceTemplate(src); err != nil {
+			return "", nil, err
+		}
+	}
+	data := sampleInvoiceTemplateData(GetTranslations(language))[0]
+	layout := pg.loadBillLayout(buildingID)
+	data.Building = pg.invoiceTemplateBuilding(buildingID, layout)
+	if layout.Title != "" {
+		data.Invoice.Title = layout.Title
+	}
+	data.Invoice.Intro, data.Invoice.Footer = nil, layout.FooterText
+	if layout.IntroText != "" {
+		data.Invoice.Intro = []string{layout.IntroText}
+	}
+	if !layout.ShowConsumptionChart {
+		data.ConsumptionChart = ""
+	}
+	if sender.Name != "" {
+		data.Sender = sender
+	}
+	if banking.IBAN != "" {
+		data.Banking = banking
+	}
+	data.RenderedAt = time.Now().Format("02.01.2006 15:04")
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", nil, err
+	}
+	if !withPDF {
+		return buf.String(), nil, nil
+	}
+	pdf, err := pg.convertHTMLToPDFBytes(buf.String())
+	return buf.String(), pdf, err
+}
+
+// invoiceTemplateBuilding loads the name of buildingID and resolves the
+// accent colour of its layout.
+func (pg *PDFGenerator) invoiceTemplateBuilding(buildingID int, layout billLayout) InvoiceTemplateBuilding {
+	b := InvoiceTemplateBuilding{ID: buildingID, AccentColor: layout.PrimaryColor}
+	if b.AccentColor == "" {
+		b.AccentColor = "#667EEA"
+	}
+	if buildingID != 0 && pg.db != nil {
+		_ = pg.db.QueryRow(`SELECT name FROM buildings WHERE id = ?`, buildingID).Scan(&b.Name)
+	}
+	return b
+}
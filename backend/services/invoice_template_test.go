@@ -0,0 +1,58 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestInvoiceTemplates(t *testing.T) {
+	for _, src := range []string{"", "{{.Invoice.Number", "{{.Invoice.Nope}}", "{{money .Invoice.Number}}"} {
+		if _, err := ParseInvoiceTemplate(src); err == nil {
+			t.Errorf("template %q accepted", src)
+		}
+	}
+	if _, err := ParseInvoiceTemplate(BuiltinInvoiceTemplate()); err != nil {
+		t.Fatalf("built-in template rejected: %v", err)
+	}
+
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "B")
+	pg := NewPDFGenerator(db)
+	inv := InvoiceToMap(models.Invoice{InvoiceNumber: "INV-1", BuildingID: 1, PeriodStart: "2026-06-01", PeriodEnd: "2026-06-30",
+		DocumentType: DocumentTypeInvoice, Currency: "CHF", Status: "issued", TotalAmount: 12.5})
+	builtin, err := pg.generateHTML(inv, SenderInfo{}, BankingInfo{})
+	if err != nil || !strings.Contains(builtin, "INV-1") {
+		t.Fatalf("built-in template: %v", err)
+	}
+
+	custom := `<p>{{.Invoice.Number}} {{.Building.Name}} {{money .Invoice.Total}} {{.Invoice.Currency}}</p>`
+	if _, err := db.Exec(`INSERT INTO invoice_templates (building_id, content) VALUES (1, ?)`, custom); err != nil {
+		t.Fatal(err)
+	}
+	if html, err := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); err != nil || html != "<p>INV-1 B 12.50 CHF</p>" {
+		t.Errorf("custom template: %q %v", html, err)
+	}
+
+	// The parsed template is cached until updated_at changes.
+	if _, err := db.Exec(`UPDATE invoice_templates SET content = '{{index .Items 0}}' WHERE building_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if html, err := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); err != nil || html != "<p>INV-1 B 12.50 CHF</p>" {
+		t.Errorf("cached template: %q %v", html, err)
+	}
+
+	// A template failing on a real invoice falls back to the built-in one.
+	if _, err := db.Exec(`UPDATE invoice_templates SET updated_at = '2030-01-01 00:00:00' WHERE building_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if html, err := pg.generateHTML(inv, SenderInfo{}, BankingInfo{}); err != nil || !strings.Contains(html, "INV-1") || strings.HasPrefix(html, "<p>") {
+		t.Errorf("fallback: %v", err)
+	}
+
+	html, _, err := pg.RenderInvoiceTemplatePreview(custom, 1, "en", SenderInfo{}, BankingInfo{}, false)
+	if err != nil || html != "<p>INV-2026-001 B 113.12 CHF</p>" {
+		t.Errorf("preview: %q %v", html, err)
+	}
+}
@@ -15,13 +15,39 @@ import (
 // counter (re)initialisation and recorded as 0. Cumulative power_kwh is kept.
 const maxIntervalConsumptionKwh = 1000.0
 
+// maxIntervalUtility bounds a 15-min interval of the utility meters in their
+// billing unit (m³ of water or gas, kWh of heat).
+var maxIntervalUtility = map[string]float64{
+	"cold_water_meter": 25, // 100 m³/h, far beyond a building's water main
+	"hot_water_meter":  25,
+	"heat_meter":       1000, // same 4 MW bound as electricity
+	"gas_meter":        100,  // ~4 MW of natural gas
+}
+
+// maxIntervalConsumption is the plausibility bound of one 15-min delta of the
+// raw counter. Utility counters are in the meter's own unit, so their bound
+// is divided by reading_factor (a litre counter with factor 0.001 may move
+// 25'000 per interval).
+func maxIntervalConsumption(meterType string, readingFactor float64) float64 {
+	limit, ok := maxIntervalUtility[meterType]
+	if !ok {
+		return maxIntervalConsumptionKwh
+	}
+	if readingFactor <= 0 {
+		readingFactor = 1
+	}
+	return limit / readingFactor
+}
+
 // processMeterData processes meter readings from Loxone responses
 func (conn *WebSocketConnection) processMeterData(device *Device, response LoxoneResponse, db *sql.DB, isExport bool) {
 	var reading float64
 
 	// Determine if this meter type supports export
 	var meterType string
-	db.QueryRow("SELECT meter_type FROM meters WHERE id = ?", device.ID).Scan(&meterType)
+	readingFactor := 1.0
+	db.QueryRow("SELECT meter_type, COALESCE(reading_factor, 1) FROM meters WHERE id = ?", device.ID).Scan(&meterType, &readingFactor)
+	maxInterval := maxIntervalConsumption(meterType, readingFactor)
 	isBatteryMeter := (meterType == "battery_meter")
 	// Battery meters store two cumulative counters too (charge as import, discharge
 	// as export), so they go through the same dual-value persistence path.
@@ -255,6 +281,34 @@ func (conn *WebSocketConnection) processMeterData(device *Device, response Loxon
 		device.ReadingGaps = 0
 		log.Printf("   📊 Reading (output1/Mr): %.3f kWh", reading)
 
+	} else if device.LoxoneMode == "utility_meter" {
+		// UTILITY METER MODE - water/heat/gas counter from output1 (Mr) of a
+		// meter block, or the value of a virtual output. The counter is in the
+		// meter's own unit, so no live power is derived from it.
+		if output1, ok := response.LL.Outputs["output1"]; ok {
+			switch v := output1.Value.(type) {
+			case float64:
+				reading = v
+			case string:
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					reading = f
+				}
+			}
+		} else if response.LL.Value != "" {
+			if f, err := strconv.ParseFloat(response.LL.Value, 64); err == nil {
+				reading = f
+			}
+		}
+
+		if reading <= 0 {
+			return
+		}
+
+		device.LastReading = reading
+		device.LastUpdate = time.Now()
+		device.ReadingGaps = 0
+		log.Printf("   💧 Reading (%s): %.3f (factor %g)", meterType, reading, readingFactor)
+
 	} else if device.LoxoneMode == "virtual_output_dual" {
 		// VIRTUAL OUTPUT DUAL MODE - Separate UUIDs for import and export
 		// CRITICAL FIX: Buffer readings and only save when BOTH are available
@@ -494,7 +548,7 @@ func (conn *WebSocketConnection) processMeterData(device *Device, response Loxon
 
 		for i, point := range interpolated {
 			intervalConsumption := point.value - lastReading
-			if intervalConsumption < 0 || intervalConsumption > maxIntervalConsumptionKwh {
+			if intervalConsumption < 0 || intervalConsumption > maxInterval {
 				intervalConsumption = 0
 			}
 
@@ -503,7 +557,7 @@ func (conn *WebSocketConnection) processMeterData(device *Device, response Loxon
 			if supportsExport && i < len(interpolatedExport) {
 				exportValue = interpolatedExport[i].value
 				intervalExport = exportValue - lastReadingExport
-				if intervalExport < 0 || intervalExport > maxIntervalConsumptionKwh {
+				if intervalExport < 0 || intervalExport > maxInterval {
 					intervalExport = 0
 				}
 			}
@@ -531,8 +585,8 @@ func (conn *WebSocketConnection) processMeterData(device *Device, response Loxon
 		if consumption < 0 {
 			consumption = 0
 		}
-		if consumption > maxIntervalConsumptionKwh {
-			log.Printf("   ⚠️ Meter %s: implausible interval consumption %.1f kWh (counter init/reset?) — recording 0", device.Name, consumption)
+		if consumption > maxInterval {
+			log.Printf("   ⚠️ Meter %s: implausible interval consumption %.1f (counter init/reset?) — recording 0", device.Name, consumption)
 			consumption = 0
 		}
 
@@ -541,7 +595,7 @@ func (conn *WebSocketConnection) processMeterData(device *Device, response Loxon
 			if consumptionExport < 0 {
 				consumptionExport = 0
 			}
-			if consumptionExport > maxIntervalConsumptionKwh {
+			if consumptionExport > maxInterval {
 				consumptionExport = 0
 			}
 		}
@@ -241,7 +241,9 @@ type Device struct {
 	// - virtual_output_single: One UUID for single value (apartment/heating/other)
 	// - battery_block: For battery_meter (output1=Mrd discharge, output8=Mrc charge,
 	//   output15=Slvl SoC%, output0=Pf power flow)
-	LoxoneMode     string // "meter_block", "energy_meter_block", "virtual_output_dual", "virtual_output_single", "battery_block"
+	// - utility_meter: For water/heat/gas meters (output1=Mr or a virtual output,
+	//   in the meter's own unit; no live power)
+	LoxoneMode     string // "meter_block", "energy_meter_block", "virtual_output_dual", "virtual_output_single", "battery_block", "utility_meter"
 	ExportDeviceID string // For virtual_output_dual mode only
 
 	// FOR BATTERY METERS (battery_block mode) — live state, not persisted to DB.
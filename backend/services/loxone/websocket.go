@@ -324,7 +324,7 @@ func (conn *WebSocketConnection) requestLivePower() {
 	conn.Mu.Lock()
 	var meterBlockCount, otherModeCount int
 	for _, device := range conn.Devices {
-		if device.Type == "meter" {
+		if device.Type == "meter" && device.LoxoneMode != "utility_meter" {
 			if device.LoxoneMode == "meter_block" {
 				meterBlockCount++
 				log.Printf("⚡ [%s] Meter '%s' (ID:%d) mode=%s - will use Pf for live power", conn.Host, device.Name, device.ID, device.LoxoneMode)
@@ -381,10 +381,10 @@ func (conn *WebSocketConnection) requestLivePower() {
 				continue
 			}
 
-			// Get ALL meters for live power polling
+			// Get ALL meters for live power polling (utility counters have none)
 			var meters []*Device
 			for _, device := range conn.Devices {
-				if device.Type == "meter" {
+				if device.Type == "meter" && device.LoxoneMode != "utility_meter" {
 					meters = append(meters, device)
 				}
 			}
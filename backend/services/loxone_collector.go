@@ -637,8 +637,12 @@ func (lc *LoxoneCollector) loadMeters(connectionDevices map[string]*loxone.WebSo
 		var meterType string
 		lc.db.QueryRow("SELECT meter_type FROM meters WHERE id = ?", id).Scan(&meterType)
 
-		// Default mode based on meter type
-		if loxoneMode == "" {
+		// Default mode based on meter type. Utility meters always read their
+		// counter as is: the energy meter block mode the form offers for
+		// single-register meters would derive a live power in W from it.
+		if UtilityOfMeterType(meterType) != "" && (loxoneMode == "" || loxoneMode == "energy_meter_block") {
+			loxoneMode = "utility_meter"
+		} else if loxoneMode == "" {
 			if meterType == "battery_meter" {
 				loxoneMode = "battery_block"
 			} else if meterType == "total_meter" || meterType == "solar_meter" {
@@ -666,6 +670,8 @@ func (lc *LoxoneCollector) loadMeters(connectionDevices map[string]*loxone.WebSo
 			log.Printf("   â””â”€ (Energy meter block: output1=Mr)")
 		} else if loxoneMode == "battery_block" {
 			log.Printf("   â””â”€ (Battery block: output1=Mrd, output8=Mrc, output15=Slvl/SoC)")
+		} else if loxoneMode == "utility_meter" {
+			log.Printf("   â””â”€ (Utility meter: output1=Mr or virtual output, meter unit)")
 		} else {
 			log.Printf("   â””â”€ (Virtual output: single value)")
 		}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Payment sources: where a row of the payment ledger came from.
+const (
+	PaymentSourceManual        = "manual"
+	PaymentSourceCamt          = "camt"
+	PaymentSourceDirectDebit   = "direct_debit"
+	PaymentSourceCreditNote    = "credit_note"
+	PaymentSourceCreditBalance = "credit_balance"
+)
+
+// Payment methods. Offset is money that never moved: a credit note or a
+// credit balance settling a document.
+const (
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodCash         = "cash"
+	PaymentMethodCard         = "card"
+	PaymentMethodDirectDebit  = "direct_debit"
+	PaymentMethodOffset       = "offset"
+	PaymentMethodOther        = "other"
+)
+
+// ValidPaymentMethod reports whether m can be chosen for a payment entered by
+// hand.
+func ValidPaymentMethod(m string) bool {
+	switch m {
+	case PaymentMethodBankTransfer, PaymentMethodCash, PaymentMethodCard, PaymentMethodDirectDebit, PaymentMethodOther:
+		return true
+	}
+	return false
+}
+
+// paymentExecer is satisfied by *sql.Tx: the ledger is only written inside
+// the caller's transaction.
+type paymentExecer interface {
+	queryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordPayment books a payment on a document and derives the document's
+// payment state from the ledger. The payment's user and currency are the
+// document's; a date in "YYYY-MM-DD HH:MM:SS" form is cut to the day.
+func recordPayment(tx paymentExecer, p models.Payment) (int, error) {
+	var userID int
+	var currency string
+	if err := tx.QueryRow(`SELECT user_id, currency FROM invoices WHERE id = ?`, p.InvoiceID).Scan(&userID, &currency); err != nil {
+		return 0, err
+	}
+	if p.Method == "" {
+		p.Method = PaymentMethodBankTransfer
+	}
+	if p.Source == "" {
+		p.Source = PaymentSourceManual
+	}
+	res, err := tx.Exec(`
+		INSERT INTO payments (invoice_id, user_id, payment_date, amount, currency, method, reference, source, bank_transaction_id, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.InvoiceID, userID, storedDay(p.PaymentDate), math.Round(p.Amount*100)/100, currency,
+		p.Method, p.Reference, p.Source, p.BankTransactionID, p.Notes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record payment: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id), refreshPaymentState(tx, p.InvoiceID)
+}
+
+// refreshPaymentState derives paid_amount, paid_at and payment_status of a
+// document from its payments: paid once they cover the total, partial while
+// below. A pending direct debit collection stays pending until it is settled.
+func refreshPaymentState(tx paymentExecer, invoiceID int) error {
+	_, err := tx.Exec(`
+		UPDATE invoices SET
+			paid_amount = ROUND(COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = invoices.id), 0), 2),
+			paid_at = (SELECT MAX(p.payment_date) FROM payments p WHERE p.invoice_id = invoices.id),
+			payment_status = CASE
+				WHEN EXISTS(SELECT 1 FROM payments p WHERE p.invoice_id = invoices.id)
+				 AND (SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = invoices.id) >= total_amount - 0.005 THEN 'paid'
+				WHEN payment_status = ? THEN payment_status
+				WHEN COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = invoices.id), 0) > 0.005 THEN 'partial'
+				ELSE 'unpaid' END
+		WHERE id = ?
+	`, PaymentCollectionPending, invoiceID)
+	return err
+}
+
+// creditBalance is a tenant's credit in one currency: everything paid beyond
+// the amount due (total plus reminder fees) of their documents, less what
+// later invoices already used up. Payments on a cancelled invoice count in
+// full, as its credit note offsets the whole total. Credit notes and producer
+// statements do not take part.
+func creditBalance(q queryer, userID int, currency string) (float64, error) {
+	var overpaid, used float64
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(MAX(paid - due, 0)), 0) FROM (
+			SELECT (SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.invoice_id = i.id) AS paid,
+			       i.total_amount + (SELECT COALESCE(SUM(r.fee), 0) FROM invoice_reminders r WHERE r.invoice_id = i.id) AS due
+			FROM invoices i
+			WHERE i.user_id = ? AND UPPER(i.currency) = UPPER(?) AND i.status IN (?, ?)
+			  AND COALESCE(i.document_type, 'invoice') NOT IN (?, ?)
+		)
+	`, userID, currency, InvoiceStatusIssued, InvoiceStatusCancelled,
+		DocumentTypeCreditNote, DocumentTypeProducerCredit).Scan(&overpaid)
+	if err != nil {
+		return 0, err
+	}
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM payments
+		WHERE user_id = ? AND UPPER(currency) = UPPER(?) AND source = ?
+	`, userID, currency, PaymentSourceCreditBalance).Scan(&used)
+	return math.Round((overpaid-used)*100) / 100, err
+}
+
+// applyCreditBalance settles a newly issued document from the tenant's credit
+// balance, as far as it reaches. Returns the amount used.
+func applyCreditBalance(tx paymentExecer, invoiceID int) (float64, error) {
+	var userID int
+	var currency, documentType, status, number string
+	var total, paid float64
+	err := tx.QueryRow(`
+		SELECT user_id, currency, COALESCE(document_type, 'invoice'), status, invoice_number,
+		       total_amount, COALESCE(paid_amount, 0)
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&userID, &currency, &documentType, &status, &number, &total, &paid)
+	if err != nil {
+		return 0, err
+	}
+	if status != InvoiceStatusIssued || IsCreditDocument(documentType) || total-paid < 0.01 {
+		return 0, nil
+	}
+	balance, err := creditBalance(tx, userID, currency)
+	if err != nil || balance < 0.01 {
+		return 0, err
+	}
+	amount := math.Min(balance, math.Round((total-paid)*100)/100)
+	if _, err := recordPayment(tx, models.Payment{
+		InvoiceID:   invoiceID,
+		PaymentDate: time.Now().Format("2006-01-02"),
+		Amount:      amount,
+		Method:      PaymentMethodOffset,
+		Source:      PaymentSourceCreditBalance,
+		Notes:       "Credit balance",
+	}); err != nil {
+		return 0, err
+	}
+	log.Printf("Invoice %s: %.2f %s settled from the credit balance of user %d", number, amount, currency, userID)
+	return amount, nil
+}
+
+// bookCreditNote offsets a credit note against the invoice it reverses or
+// corrects: the credited amount is booked as a payment on the invoice, and
+// the credit note itself counts as settled. Whatever the invoice was paid
+// beyond its new amount due becomes the tenant's credit balance.
+func bookCreditNote(tx paymentExecer, creditNoteID, invoiceID int, creditNoteNumber string, amount float64) error {
+	if _, err := recordPayment(tx, models.Payment{
+		InvoiceID:   invoiceID,
+		PaymentDate: time.Now().Format("2006-01-02"),
+		Amount:      math.Abs(amount),
+		Method:      PaymentMethodOffset,
+		Reference:   creditNoteNumber,
+		Source:      PaymentSourceCreditNote,
+	}); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE invoices SET payment_status = 'paid', paid_amount = total_amount, paid_at = ? WHERE id = ?`,
+		time.Now().Format("2006-01-02"), creditNoteID)
+	return err
+}
+
+// CreditApplied is the part of a document settled from the tenant's credit
+// balance, as printed on the invoice.
+func CreditApplied(payments []models.Payment) float64 {
+	sum := 0.0
+	for _, p := range payments {
+		if p.Source == PaymentSourceCreditBalance {
+			sum += p.Amount
+		}
+	}
+	return math.Round(sum*100) / 100
+}
+
+// RecordPayment books a payment entered by hand on an issued or cancelled
+// document and returns it with its id. Money paid on a cancelled invoice, or
+// beyond the amount due, becomes the tenant's credit balance.
+func RecordPayment(db *sql.DB, p models.Payment) (*models.Payment, error) {
+	var status, documentType string
+	err := db.QueryRow(`SELECT status, COALESCE(document_type, 'invoice') FROM invoices WHERE id = ?`, p.InvoiceID).
+		Scan(&status, &documentType)
+	if err != nil {
+		return nil, err
+	}
+	if documentType == DocumentTypeCreditNote || (status != InvoiceStatusIssued && status != InvoiceStatusCancelled) {
+		return nil, fmt.Errorf("document %d is %s and cannot take a payment", p.InvoiceID, status)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	id, err := recordPayment(tx, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	list, err := LoadPayments(db, PaymentFilter{ID: id})
+	if err != nil || len(list) == 0 {
+		return nil, fmt.Errorf("failed to load payment %d: %v", id, err)
+	}
+	return &list[0], nil
+}
+
+// DeletePayment removes a manually entered payment. Payments from bank
+// statements, direct debits and offsets are undone where they came from. A
+// payment whose excess a later invoice already used as credit cannot be
+// removed. Returns sql.ErrNoRows for an unknown payment.
+func DeletePayment(db *sql.DB, paymentID int) error {
+	var invoiceID, userID int
+	var source, currency string
+	err := db.QueryRow(`SELECT invoice_id, user_id, source, currency FROM payments WHERE id = ?`, paymentID).
+		Scan(&invoiceID, &userID, &source, &currency)
+	if err != nil {
+		return err
+	}
+	if source != PaymentSourceManual {
+		return fmt.Errorf("payment %d comes from %s and cannot be deleted here", paymentID, source)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM payments WHERE id = ?`, paymentID); err != nil {
+		return err
+	}
+	if err := refreshPaymentState(tx, invoiceID); err != nil {
+		return err
+	}
+	if balance, err := creditBalance(tx, userID, currency); err != nil {
+		return err
+	} else if balance < -0.005 {
+		return fmt.Errorf("payment %d was already used as credit on a later invoice", paymentID)
+	}
+	return tx.Commit()
+}
+
+// SetPaymentStatus is the shortcut behind the invoice payment toggle. "paid"
+// and "partial" book a manual payment that brings the paid total up to
+// paidAmount (the invoice total for "paid" without an amount); "unpaid"
+// removes the manual payments. Lowering the paid total below payments from
+// other sources is refused.
+func SetPaymentStatus(db *sql.DB, invoiceID int, status string, paidAmount *float64) error {
+	var total, paid float64
+	var userID int
+	var currency string
+	err := db.QueryRow(`SELECT total_amount, COALESCE(paid_amount, 0), user_id, currency FROM invoices WHERE id = ?`, invoiceID).
+		Scan(&total, &paid, &userID, &currency)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if status == "unpaid" {
+		if _, err := tx.Exec(`DELETE FROM payments WHERE invoice_id = ? AND source = ?`, invoiceID, PaymentSourceManual); err != nil {
+			return err
+		}
+		if err := refreshPaymentState(tx, invoiceID); err != nil {
+			return err
+		}
+		var remaining float64
+		if err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = ?`, invoiceID).Scan(&remaining); err != nil {
+			return err
+		}
+		if remaining > 0.005 {
+			return fmt.Errorf("%.2f %s of the payments come from bank statements or offsets", remaining, currency)
+		}
+		if balance, err := creditBalance(tx, userID, currency); err != nil {
+			return err
+		} else if balance < -0.005 {
+			return fmt.Errorf("the payments were already used as credit on a later invoice")
+		}
+		return tx.Commit()
+	}
+
+	target := total
+	if paidAmount != nil {
+		target = *paidAmount
+	}
+	if target < paid-0.005 {
+		return fmt.Errorf("%.2f %s is already recorded; remove payments from the ledger to lower it", paid, currency)
+	}
+	if target-paid > 0.005 {
+		if _, err := recordPayment(tx, models.Payment{
+			InvoiceID:   invoiceID,
+			PaymentDate: time.Now().Format("2006-01-02"),
+			Amount:      target - paid,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PaymentFilter narrows LoadPayments; zero fields do not filter.
+type PaymentFilter struct {
+	ID        int
+	InvoiceID int
+	UserID    int
+}
+
+// LoadPayments returns payments with their document numbers, oldest first.
+func LoadPayments(db *sql.DB, f PaymentFilter) ([]models.Payment, error) {
+	query := `
+		SELECT p.id, p.invoice_id, COALESCE(i.invoice_number, ''), p.user_id, p.payment_date, p.amount,
+		       p.currency, p.method, p.reference, p.source, p.bank_transaction_id, p.notes, p.created_at
+		FROM payments p
+		LEFT JOIN invoices i ON i.id = p.invoice_id
+		WHERE 1 = 1`
+	var args []interface{}
+	if f.ID > 0 {
+		query += ` AND p.id = ?`
+		args = append(args, f.ID)
+	}
+	if f.InvoiceID > 0 {
+		query += ` AND p.invoice_id = ?`
+		args = append(args, f.InvoiceID)
+	}
+	if f.UserID > 0 {
+		query += ` AND p.user_id = ?`
+		args = append(args, f.UserID)
+	}
+	rows, err := db.Query(query+` ORDER BY p.payment_date, p.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %v", err)
+	}
+	defer rows.Close()
+
+	list := []models.Payment{}
+	for rows.Next() {
+		var p models.Payment
+		var bankTx sql.NullInt64
+		var createdAt sql.NullString
+		if err := rows.Scan(&p.ID, &p.InvoiceID, &p.InvoiceNumber, &p.UserID, &p.PaymentDate, &p.Amount,
+			&p.Currency, &p.Method, &p.Reference, &p.Source, &bankTx, &p.Notes, &createdAt); err != nil {
+			return nil, err
+		}
+		if bankTx.Valid {
+			id := int(bankTx.Int64)
+			p.BankTransactionID = &id
+		}
+		p.CreatedAt = createdAt.String
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// UserCreditBalances returns a tenant's credit balance per currency they were
+// billed in (userID 0 = every tenant with a balance).
+func UserCreditBalances(db *sql.DB, userID int) ([]models.CreditBalance, error) {
+	query := `SELECT DISTINCT user_id, UPPER(currency) FROM invoices WHERE status IN (?, ?)`
+	args := []interface{}{InvoiceStatusIssued, InvoiceStatusCancelled}
+	if userID > 0 {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := db.Query(query+` ORDER BY user_id, UPPER(currency)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	type key struct {
+		userID   int
+		currency string
+	}
+	var keys []key
+	for rows.Next() {
+		var k key
+		if err := rows.Scan(&k.userID, &k.currency); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+
+	list := []models.CreditBalance{}
+	for _, k := range keys {
+		balance, err := creditBalance(db, k.userID, k.currency)
+		if err != nil {
+			return nil, err
+		}
+		if userID == 0 && balance < 0.01 {
+			continue
+		}
+		list = append(list, models.CreditBalance{UserID: k.userID, Currency: strings.ToUpper(k.currency), Balance: balance})
+	}
+	return list, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestPaymentLedger(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	state := func(id int) (string, float64) {
+		var status string
+		var paid float64
+		db.QueryRow(`SELECT payment_status, paid_amount FROM invoices WHERE id = ?`, id).Scan(&status, &paid)
+		return status, paid
+	}
+
+	first := insertInvoice(t, bs, "INV-1", 10, 1, 100)
+	if _, err := RecordPayment(db, models.Payment{InvoiceID: first, PaymentDate: "2026-02-10", Amount: 40, Method: PaymentMethodCash}); err != nil {
+		t.Fatal(err)
+	}
+	if status, paid := state(first); status != "partial" || !almostEqual(paid, 40) {
+		t.Errorf("after first payment %s %.2f", status, paid)
+	}
+	manual, err := RecordPayment(db, models.Payment{InvoiceID: first, PaymentDate: "2026-02-20", Amount: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, paid := state(first); status != "paid" || !almostEqual(paid, 130) {
+		t.Errorf("after overpayment %s %.2f", status, paid)
+	}
+	if balance, _ := creditBalance(db, 10, "CHF"); !almostEqual(balance, 30) {
+		t.Errorf("credit balance %.2f, want 30", balance)
+	}
+
+	// The next invoice uses the credit up; the overpayment is then locked.
+	second := insertInvoice(t, bs, "INV-2", 10, 1, 100)
+	if status, paid := state(second); status != "partial" || !almostEqual(paid, 30) {
+		t.Errorf("next invoice %s %.2f, want partial 30 from credit", status, paid)
+	}
+	if balance, _ := creditBalance(db, 10, "CHF"); !almostEqual(balance, 0) {
+		t.Errorf("credit balance %.2f after use", balance)
+	}
+	if err := DeletePayment(db, manual.ID); err == nil {
+		t.Error("deleted a payment already used as credit")
+	}
+
+	// Cancelling a paid invoice turns what was paid into credit again.
+	if _, err := RecordPayment(db, models.Payment{InvoiceID: second, PaymentDate: "2026-03-05", Amount: 70}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.CancelInvoice(second, "wrong tariff"); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := creditBalance(db, 10, "CHF"); !almostEqual(balance, 100) {
+		t.Errorf("credit balance %.2f after cancellation, want 100", balance)
+	}
+	third := insertInvoice(t, bs, "INV-3", 10, 1, 100)
+	if status, paid := state(third); status != "paid" || !almostEqual(paid, 100) {
+		t.Errorf("third invoice %s %.2f, want paid from credit", status, paid)
+	}
+	payments, err := LoadPayments(db, PaymentFilter{UserID: 10})
+	if err != nil || len(payments) != 6 {
+		t.Fatalf("ledger has %d payments, %v", len(payments), err)
+	}
+	if applied := CreditApplied(payments[len(payments)-1:]); !almostEqual(applied, 100) {
+		t.Errorf("credit applied %.2f", applied)
+	}
+}
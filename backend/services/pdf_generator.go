@@ -10,15 +10,71 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// PDF renderers. The external renderer converts the HTML invoice with
+// wkhtmltopdf or a headless Chromium; the native one draws the PDF in-process.
+// Auto uses an external converter when one is installed and falls back to
+// native otherwise.
+const (
+	PDFRendererAuto     = "auto"
+	PDFRendererNative   = "native"
+	PDFRendererExternal = "external"
 )
 
+// externalPDFConverters are the binaries convertHTMLToPDF can use.
+var externalPDFConverters = []string{"wkhtmltopdf", "chromium-browser", "chromium", "google-chrome", "chrome"}
+
 type PDFGenerator struct {
-	db *sql.DB
+	db       *sql.DB
+	renderer string
+
+	templateMu sync.Mutex
+	templates  map[int]cachedInvoiceTemplate // parsed custom templates by building
 }
 
 func NewPDFGenerator(db *sql.DB) *PDFGenerator {
-	return &PDFGenerator{db: db}
+	return &PDFGenerator{db: db, renderer: PDFRendererAuto}
+}
+
+// SetRenderer selects the PDF renderer (auto, native or external). Unknown
+// names fall back to auto.
+func (pg *PDFGenerator) SetRenderer(name string) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case PDFRendererNative, PDFRendererExternal:
+		pg.renderer = name
+	default:
+		if name != "" && name != PDFRendererAuto {
+			log.Printf("WARNING: Unknown PDF renderer %q, using auto", name)
+		}
+		pg.renderer = PDFRendererAuto
+	}
+}
+
+// UsesNativeRenderer reports whether invoice PDFs are drawn in-process, which
+// ignores custom invoice templates.
+func (pg *PDFGenerator) UsesNativeRenderer() bool {
+	return pg.useNativeRenderer()
+}
+
+// useNativeRenderer reports whether PDFs are drawn in-process.
+func (pg *PDFGenerator) useNativeRenderer() bool {
+	switch pg.renderer {
+	case PDFRendererNative:
+		return true
+	case PDFRendererExternal:
+		return false
+	}
+	for _, bin := range externalPDFConverters {
+		if _, err := exec.LookPath(bin); err == nil {
+			return false
+		}
+	}
+	return true
 }
 
 type SenderInfo struct {
@@ -27,15 +83,19 @@ type SenderInfo struct {
 	City    string
 	Zip     string
 	Country string
+	// VATNumber is the seller's VAT registration (e.g. CHE-123.456.789 MWST),
+	// required for structured e-invoices that charge VAT.
+	VATNumber string
 }
 
 // billLayout mirrors the bill_layouts row used to customise the main invoice
 // page. Empty strings mean "use the default" — the QR-bill page is unaffected.
 type billLayout struct {
-	Title        string
-	IntroText    string
-	FooterText   string
-	PrimaryColor string
+	Title                string
+	IntroText            string
+	FooterText           string
+	PrimaryColor         string
+	ShowConsumptionChart bool
 }
 
 // loadBillLayout fetches the per-building override (or returns zero-value
@@ -48,9 +108,10 @@ func (pg *PDFGenerator) loadBillLayout(buildingID int) billLayout {
 	}
 	_ = pg.db.QueryRow(`
 		SELECT COALESCE(title, ''), COALESCE(intro_text, ''),
-		       COALESCE(footer_text, ''), COALESCE(primary_color, '')
+		       COALESCE(footer_text, ''), COALESCE(primary_color, ''),
+		       show_consumption_chart
 		FROM bill_layouts WHERE building_id = ?
-	`, buildingID).Scan(&l.Title, &l.IntroText, &l.FooterText, &l.PrimaryColor)
+	`, buildingID).Scan(&l.Title, &l.IntroText, &l.FooterText, &l.PrimaryColor, &l.ShowConsumptionChart)
 	return l
 }
 
@@ -60,6 +121,67 @@ type BankingInfo struct {
 	AccountHolder string
 }
 
+// InvoiceToMap converts an invoice (with items and user) into the map form the
+// PDF generator renders.
+func InvoiceToMap(inv models.Invoice) map[string]interface{} {
+	invoiceMap := make(map[string]interface{})
+
+	invoiceMap["id"] = inv.ID
+	invoiceMap["invoice_number"] = inv.InvoiceNumber
+	invoiceMap["user_id"] = inv.UserID
+	invoiceMap["building_id"] = inv.BuildingID
+	invoiceMap["period_start"] = inv.PeriodStart
+	invoiceMap["period_end"] = inv.PeriodEnd
+	invoiceMap["total_amount"] = inv.TotalAmount
+	invoiceMap["net_amount"] = inv.NetAmount
+	invoiceMap["vat_amount"] = inv.VATAmount
+	invoiceMap["vat_rate"] = inv.VATRate
+	invoiceMap["vat_included"] = inv.VATIncluded
+	invoiceMap["currency"] = inv.Currency
+	invoiceMap["status"] = inv.Status
+	invoiceMap["generated_at"] = inv.GeneratedAt.Format("2006-01-02")
+	invoiceMap["document_type"] = inv.DocumentType
+	invoiceMap["original_invoice_number"] = inv.OriginalInvoiceNumber
+	invoiceMap["correction"] = inv.Correction
+	invoiceMap["due_date"] = inv.DueDate
+	invoiceMap["credit_applied"] = CreditApplied(inv.Payments)
+	invoiceMap["vat_summary"] = VATSummary(inv)
+
+	// Convert items
+	items := make([]interface{}, len(inv.Items))
+	for i, item := range inv.Items {
+		itemMap := make(map[string]interface{})
+		itemMap["id"] = item.ID
+		itemMap["invoice_id"] = item.InvoiceID
+		itemMap["description"] = item.Description
+		itemMap["quantity"] = item.Quantity
+		itemMap["unit_price"] = item.UnitPrice
+		itemMap["total_price"] = item.TotalPrice
+		itemMap["item_type"] = item.ItemType
+		items[i] = itemMap
+	}
+	invoiceMap["items"] = items
+
+	// Convert user
+	if inv.User != nil {
+		userMap := make(map[string]interface{})
+		userMap["id"] = inv.User.ID
+		userMap["first_name"] = inv.User.FirstName
+		userMap["last_name"] = inv.User.LastName
+		userMap["email"] = inv.User.Email
+		userMap["phone"] = inv.User.Phone
+		userMap["address_street"] = inv.User.AddressStreet
+		userMap["address_city"] = inv.User.AddressCity
+		userMap["address_zip"] = inv.User.AddressZip
+		userMap["address_country"] = inv.User.AddressCountry
+		userMap["language"] = inv.User.Language
+		userMap["is_active"] = inv.User.IsActive
+		invoiceMap["user"] = userMap
+	}
+
+	return invoiceMap
+}
+
 func (pg *PDFGenerator) GenerateInvoicePDF(invoice interface{}, senderInfo SenderInfo, bankingInfo BankingInfo) (string, error) {
 	// Type assertion to get invoice details
 	inv, ok := invoice.(map[string]interface{})
@@ -69,12 +191,6 @@ func (pg *PDFGenerator) GenerateInvoicePDF(invoice interface{}, senderInfo Sende
 
 	invoiceNumber := fmt.Sprintf("%v", inv["invoice_number"])
 
-	// Generate HTML content
-	htmlContent, err := pg.generateHTML(inv, senderInfo, bankingInfo)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate HTML: %v", err)
-	}
-
 	// Create invoices directory
 	invoicesDir := "/home/pi/zev-billing/backend/invoices"
 	if err := os.MkdirAll(invoicesDir, 0755); err != nil {
@@ -86,6 +202,24 @@ func (pg *PDFGenerator) GenerateInvoicePDF(invoice interface{}, senderInfo Sende
 	filename := fmt.Sprintf("%s.pdf", invoiceNumber)
 	pdfPath := filepath.Join(invoicesDir, filename)
 
+	if pg.useNativeRenderer() {
+		pdf, err := pg.renderNativeInvoicePDF(inv, senderInfo, bankingInfo)
+		if err != nil {
+			return "", fmt.Errorf("failed to render PDF: %v", err)
+		}
+		if err := os.WriteFile(pdfPath, pdf, 0644); err != nil {
+			return "", fmt.Errorf("failed to write PDF: %v", err)
+		}
+		log.Printf("Generated PDF: %s (native)", filename)
+		return filename, nil
+	}
+
+	// Generate HTML content
+	htmlContent, err := pg.generateHTML(inv, senderInfo, bankingInfo)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate HTML: %v", err)
+	}
+
 	// Save HTML temporarily
 	tempHTML := filepath.Join(os.TempDir(), fmt.Sprintf("invoice_%s.html", invoiceNumber))
 	if err := os.WriteFile(tempHTML, []byte(htmlContent), 0644); err != nil {
@@ -102,6 +236,52 @@ func (pg *PDFGenerator) GenerateInvoicePDF(invoice interface{}, senderInfo Sende
 	return filename, nil
 }
 
+// RenderInvoicePreview renders an unsaved (dry-run) invoice to HTML and, when
+// withPDF is set, to PDF bytes. Nothing is written to the invoices directory.
+func (pg *PDFGenerator) RenderInvoicePreview(invoice map[string]interface{}, senderInfo SenderInfo, bankingInfo BankingInfo, withPDF bool) (string, []byte, error) {
+	htmlContent, err := pg.generateHTML(invoice, senderInfo, bankingInfo)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate HTML: %v", err)
+	}
+	if !withPDF {
+		return htmlContent, nil, nil
+	}
+	if pg.useNativeRenderer() {
+		pdf, err := pg.renderNativeInvoicePDF(invoice, senderInfo, bankingInfo)
+		if err != nil {
+			return htmlContent, nil, fmt.Errorf("failed to render PDF: %v", err)
+		}
+		return htmlContent, pdf, nil
+	}
+
+	pdf, err := pg.convertHTMLToPDFBytes(htmlContent)
+	return htmlContent, pdf, err
+}
+
+// convertHTMLToPDFBytes converts an HTML document with the external converter
+// through temporary files, which are removed before returning.
+func (pg *PDFGenerator) convertHTMLToPDFBytes(htmlContent string) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "zev-pdf-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	htmlPath := filepath.Join(tmpDir, "document.html")
+	pdfPath := filepath.Join(tmpDir, "document.pdf")
+	if err := os.WriteFile(htmlPath, []byte(htmlContent), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write HTML: %v", err)
+	}
+	if err := pg.convertHTMLToPDF(htmlPath, pdfPath); err != nil {
+		return nil, fmt.Errorf("failed to convert to PDF: %v", err)
+	}
+	pdf, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %v", err)
+	}
+	return pdf, nil
+}
+
 func (pg *PDFGenerator) convertHTMLToPDF(htmlPath, pdfPath string) error {
 	// Try wkhtmltopdf first (better for production)
 	cmd := exec.Command("wkhtmltopdf",
@@ -132,7 +312,7 @@ func (pg *PDFGenerator) convertHTMLToPDF(htmlPath, pdfPath string) error {
 	log.Printf("wkhtmltopdf not available: %v, trying chromium...", err)
 
 	// Try chromium/chrome as fallback
-	chromiumCmds := []string{"chromium-browser", "chromium", "google-chrome", "chrome"}
+	chromiumCmds := externalPDFConverters[1:]
 
 	for _, chromiumCmd := range chromiumCmds {
 		cmd = exec.Command(chromiumCmd,
@@ -153,8 +333,15 @@ func (pg *PDFGenerator) convertHTMLToPDF(htmlPath, pdfPath string) error {
 	return fmt.Errorf("no PDF converter available (tried wkhtmltopdf and chromium): %s", string(output))
 }
 
+// generateHTML renders an invoice with its building's template, the
+// built-in one when it has none.
 func (pg *PDFGenerator) generateHTML(inv map[string]interface{}, sender SenderInfo, banking BankingInfo) (string, error) {
-	invoiceNumber := fmt.Sprintf("%v", inv["invoice_number"])
+	data := pg.invoiceTemplateData(inv, sender, banking)
+	return executeInvoiceTemplate(pg.invoiceTemplateFor(data.Building.ID), data)
+}
+
+// invoiceTemplateData builds the template data of an invoice map.
+func (pg *PDFGenerator) invoiceTemplateData(inv map[string]interface{}, sender SenderInfo, banking BankingInfo) InvoiceTemplateData {
 	status := fmt.Sprintf("%v", inv["status"])
 	currency := fmt.Sprintf("%v", inv["currency"])
 	if currency == "" {
@@ -183,1019 +370,145 @@ func (pg *PDFGenerator) generateHTML(inv map[string]interface{}, sender SenderIn
 	}
 	layout := pg.loadBillLayout(buildingID)
 
-	titleText := tr.Invoice
+	statusColors := getStatusColors(status)
+	d := InvoiceTemplateData{
+		Invoice: InvoiceTemplateInvoice{
+			Number:           fmt.Sprintf("%v", inv["invoice_number"]),
+			Title:            tr.Invoice,
+			Status:           status,
+			StatusColor:      statusColors.color,
+			StatusBackground: statusColors.bg,
+			PeriodStart:      formatDate(fmt.Sprintf("%v", inv["period_start"])),
+			PeriodEnd:        formatDate(fmt.Sprintf("%v", inv["period_end"])),
+			GeneratedAt:      formatDate(fmt.Sprintf("%v", inv["generated_at"])),
+			Currency:         currency,
+			Footer:           layout.FooterText,
+		},
+		Sender:     sender,
+		Banking:    banking,
+		Building:   pg.invoiceTemplateBuilding(buildingID, layout),
+		Tr:         tr,
+		RenderedAt: time.Now().Format("02.01.2006 15:04"),
+	}
+	in := &d.Invoice
 	if layout.Title != "" {
-		titleText = layout.Title
-	}
-	accentColor := layout.PrimaryColor
-	if accentColor == "" {
-		accentColor = "#667EEA"
+		in.Title = layout.Title
 	}
-	introHTML := ""
 	if layout.IntroText != "" {
-		introHTML = fmt.Sprintf(`<div class="intro-text">%s</div>`, template.HTMLEscapeString(layout.IntroText))
-	}
-	footerHTML := ""
-	if layout.FooterText != "" {
-		footerHTML = fmt.Sprintf(`<div class="footer-text">%s</div>`, template.HTMLEscapeString(layout.FooterText))
-	}
-
-	totalAmount := 0.0
-	if ta, ok := inv["total_amount"].(float64); ok {
-		totalAmount = ta
-	}
-
-	// VAT (MwSt.) breakdown. When vat_rate > 0 we render Subtotal (net) and a
-	// VAT line above the total; the total itself is always the gross amount.
-	vatRate := 0.0
-	if v, ok := inv["vat_rate"].(float64); ok {
-		vatRate = v
-	}
-	vatAmount := 0.0
-	if v, ok := inv["vat_amount"].(float64); ok {
-		vatAmount = v
-	}
-	netAmount := totalAmount
+		in.Intro = []string{layout.IntroText}
+	}
+
+	// Credit notes (Storno) get their own title and point at the invoice they
+	// reverse; a corrected replacement names the invoice it replaces.
+	in.DocumentType, _ = inv["document_type"].(string)
+	in.OriginalNumber, _ = inv["original_invoice_number"].(string)
+	isCreditNote := in.DocumentType == DocumentTypeCreditNote
+	switch in.DocumentType {
+	case DocumentTypeCreditNote:
+		in.Title = tr.CreditNote
+	case DocumentTypeAdvance:
+		in.Title = tr.AdvanceInvoice
+	case DocumentTypeSettlement:
+		in.Title = tr.SettlementInvoice
+	case DocumentTypeSupplementary:
+		in.Title = tr.SupplementaryInvoice
+	case DocumentTypeProducerCredit:
+		in.Title = tr.ProducerCredit
+	case DocumentTypeReminder:
+		level, _ := inv["dunning_level"].(int)
+		in.Title = reminderTitle(tr, level)
+	}
+	if text, _ := inv["reminder_text"].(string); text != "" {
+		// A reminder opens with its own letter text instead of the bill layout intro.
+		in.Intro = []string{text}
+	} else if in.OriginalNumber != "" {
+		refText := tr.ReplacesInvoice
+		if correction, _ := inv["correction"].(bool); correction {
+			refText = tr.CorrectsInvoice
+		} else if isCreditNote {
+			refText = tr.CreditNoteFor
+		}
+		in.Intro = append([]string{fmt.Sprintf("%s #%s", refText, in.OriginalNumber)}, in.Intro...)
+	}
+	// Nothing is payable on a credit note: no payment details, no QR-bill. A
+	// dry-run preview never carries a scannable QR-bill either.
+	d.ShowPayment = banking.IBAN != "" && banking.AccountHolder != "" && !isCreditNote && status != InvoiceStatusPreview
+
+	if history := pg.consumptionHistory(inv, layout); history != nil {
+		d.ConsumptionChart = template.HTML(consumptionChartHTML(history, tr))
+	}
+
+	in.Total, _ = inv["total_amount"].(float64)
+	// A settlement that ends in a refund has nothing to pay either.
+	if in.Total <= 0 {
+		d.ShowPayment = false
+	}
+	// Credit balance already used on the invoice is not asked for again.
+	in.CreditApplied, _ = inv["credit_applied"].(float64)
+	in.AmountDue = in.Total - in.CreditApplied
+	if in.CreditApplied > 0 && in.AmountDue < 0.005 {
+		d.ShowPayment = false
+	}
+
+	// VAT (MwSt.) breakdown. When vat_rate > 0 the template shows Subtotal
+	// (net) and a VAT line above the total; the total itself is always the
+	// gross amount.
+	in.VATRate, _ = inv["vat_rate"].(float64)
+	in.VATAmount, _ = inv["vat_amount"].(float64)
+	in.NetAmount = in.Total
 	if v, ok := inv["net_amount"].(float64); ok && v > 0 {
-		netAmount = v
-	}
-	vatIncluded := false
-	if v, ok := inv["vat_included"].(bool); ok {
-		vatIncluded = v
-	}
-	vatLinesHTML := ""
-	if vatRate > 0 {
-		if vatIncluded {
-			// Prices already contain VAT: line items sum to the (gross) total,
-			// so we only note the contained VAT amount.
-			vatLinesHTML = fmt.Sprintf(
-				`<p class="total-sub">%s %.1f%%: %s %.2f</p>`,
-				tr.ThereofVAT, vatRate, currency, vatAmount,
-			)
-		} else {
-			// Prices are net: show the net subtotal and the VAT added on top.
-			vatLinesHTML = fmt.Sprintf(
-				`<p class="total-sub">%s: %s %.2f</p><p class="total-sub">%s %.1f%%: %s %.2f</p>`,
-				tr.Subtotal, currency, netAmount,
-				tr.VAT, vatRate, currency, vatAmount,
-			)
-		}
+		in.NetAmount = v
 	}
+	in.VATIncluded, _ = inv["vat_included"].(bool)
+	in.VATSummary = multiRateVATSummary(inv)
 
-	periodStart := fmt.Sprintf("%v", inv["period_start"])
-	periodEnd := fmt.Sprintf("%v", inv["period_end"])
-	generatedAt := fmt.Sprintf("%v", inv["generated_at"])
-
-	// Get status colors
-	statusColors := getStatusColors(status)
+	if due, _ := inv["due_date"].(string); due != "" && d.ShowPayment {
+		in.DueDate = formatDate(due)
+	}
 
-	// Check if user is archived
-	isArchived := false
-	var userInfo string
 	if user, ok := inv["user"].(map[string]interface{}); ok {
-		firstName := fmt.Sprintf("%v", user["first_name"])
-		lastName := fmt.Sprintf("%v", user["last_name"])
-		email := fmt.Sprintf("%v", user["email"])
-		street := fmt.Sprintf("%v", user["address_street"])
-		zip := fmt.Sprintf("%v", user["address_zip"])
-		city := fmt.Sprintf("%v", user["address_city"])
-
+		str := func(key string) string {
+			v, _ := user[key].(string)
+			return v
+		}
+		d.User = InvoiceTemplateUser{
+			FirstName: str("first_name"),
+			LastName:  str("last_name"),
+			Email:     str("email"),
+			Street:    str("address_street"),
+			Zip:       str("address_zip"),
+			City:      str("address_city"),
+			Country:   str("address_country"),
+			Language:  userLanguage,
+		}
+		d.User.Name = d.User.FirstName + " " + d.User.LastName
 		if isActive, ok := user["is_active"].(bool); ok {
-			isArchived = !isActive
+			d.User.Archived = !isActive
 		}
-
-		archivedLabel := ""
-		if isArchived {
-			archivedLabel = " <em>(Archived)</em>"
-		}
-
-		userInfo = fmt.Sprintf(`<strong>%s %s</strong>%s<br>%s<br>%s %s<br>%s`,
-			firstName, lastName, archivedLabel, street, zip, city, email)
 	}
 
-	// Generate items HTML
-	itemsHTML := ""
 	if items, ok := inv["items"].([]interface{}); ok {
 		for _, item := range items {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				itemsHTML += pg.generateItemHTML(itemMap, currency)
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
 			}
-		}
-	}
-
-	// Generate QR code data
-	qrData := ""
-	hasValidQR := false
-	if banking.IBAN != "" && banking.AccountHolder != "" {
-		qrData = pg.generateSwissQRData(inv, sender, banking)
-		hasValidQR = qrData != ""
-	}
-
-	// Archived banner
-	archivedBanner := ""
-	if isArchived {
-		archivedBanner = `
-		<div class="archived-banner">
-    		⚠️ ARCHIVED USER - This invoice is for an archived user
-		</div>`
-	}
-
-	// Sender info section
-	senderSection := ""
-	if sender.Name != "" {
-		senderSection = fmt.Sprintf(`
-			<div class="header-right">
-				<strong>%s</strong>
-				%s<br>
-				%s %s<br>
-				%s
-			</div>`,
-			sender.Name,
-			sender.Address,
-			sender.Zip, sender.City,
-			sender.Country,
-		)
-	}
-
-	// Payment details section
-	paymentSection := ""
-	if banking.IBAN != "" && banking.AccountHolder != "" {
-		paymentSection = fmt.Sprintf(`
-		<div class="payment-details-bottom">
-			<h4>%s</h4>
-			<p><strong>%s:</strong> %s</p>
-			<p><strong>%s:</strong> %s</p>
-			<p><strong>%s:</strong> %s</p>
-			<div class="footer-timestamp">
-				<p>%s: %s</p>
-			</div>
-		</div>`,
-			tr.PaymentInfo,
-			tr.BankDetails,
-			banking.Name,
-			tr.AccountHolder,
-			banking.AccountHolder,
-			tr.IBAN,
-			formatIBAN(banking.IBAN),
-			tr.Generated,
-			time.Now().Format("02.01.2006 15:04"),
-		)
-	}
-
-	// QR code page
-	qrPage := ""
-	if banking.IBAN != "" && banking.AccountHolder != "" {
-		qrCodeContent := ""
-		if hasValidQR {
-			qrCodeContent = fmt.Sprintf(`
-				<div class="qr-code-wrapper">
-					<img src="https://api.qrserver.com/v1/create-qr-code/?size=160x160&ecc=M&data=%s" alt="QR Code" style="width: 46mm; height: 46mm;">
-				</div>`,
-				template.URLQueryEscaper(qrData),
-			)
-		} else {
-			qrCodeContent = `
-				<div style="padding: 10px; color: #dc3545; text-align: center;">
-					<p style="margin: 0; font-size: 9pt;">QR Code could not be generated</p>
-				</div>`
-		}
-
-		// Format user info for display (plain text, line breaks)
-		userName := ""
-		userStreet := ""
-		userLocation := ""
-		if user, ok := inv["user"].(map[string]interface{}); ok {
-			firstName := fmt.Sprintf("%v", user["first_name"])
-			lastName := fmt.Sprintf("%v", user["last_name"])
-			userName = fmt.Sprintf("%s %s", firstName, lastName)
-			street := fmt.Sprintf("%v", user["address_street"])
-			zip := fmt.Sprintf("%v", user["address_zip"])
-			city := fmt.Sprintf("%v", user["address_city"])
-			userStreet = street
-			userLocation = fmt.Sprintf("%s %s", zip, city)
-		}
-
-		// Format sender info for display
-		senderName := banking.AccountHolder
-		senderStreet := sender.Address
-		senderLocation := fmt.Sprintf("%s %s", sender.Zip, sender.City)
-
-		// Build QR sections WITH TRANSLATIONS
-		qrPage = fmt.Sprintf(`
-		<div class="page qr-page">
-			<div class="qr-container">
-				<div class="qr-left">
-					<div class="qr-section-title">%s</div>
-					<div class="qr-info">
-						<strong>%s</strong>
-						<p>%s</p>
-						<p>%s</p>
-						<p>%s</p>
-						<p>%s</p>
-					</div>
-					<div class="qr-info">
-						<strong>%s</strong>
-						<p>%s</p>
-						<p>%s</p>
-						<p>%s</p>
-					</div>
-					<div class="qr-amount-box">
-						<div style="display: grid; grid-template-columns: 12mm auto;">
-                            <p style="font-size: 6pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 6pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 8pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 8pt; font-weight: bold; margin: 0;">%.2f</p>
-                        </div>
-					</div>
-					<div class="qr-acceptance-point">%s</div>
-				</div>
-				<div class="qr-right">
-					<div class="qr-section-title">%s</div>
-					<div class="qr-right-layout">
-						<div class="qr-code-column">
-							%s
-						</div>
-						<div class="qr-info-column">
-							<div class="qr-info">
-								<strong>%s</strong>
-								<p>%s</p>
-								<p>%s</p>
-								<p>%s</p>
-								<p>%s</p>
-							</div>
-							<div class="qr-info">
-								<strong>%s</strong>
-								<p>%s %s</p>
-							</div>
-							<div class="qr-info">
-								<strong>%s</strong>
-								<p>%s</p>
-								<p>%s</p>
-								<p>%s</p>
-							</div>
-						</div>
-					</div>
-					<div class="qr-amount-box">
-						<div style="display: grid; grid-template-columns: 12mm auto;">
-                            <p style="font-size: 6pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 6pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 8pt; font-weight: bold; margin: 0;">%s</p>
-                            <p style="font-size: 8pt; font-weight: bold; margin: 0;">%.2f</p>
-                        </div>
-					</div>
-				</div>
-			</div>
-		</div>`,
-			tr.ReceiptSection,   // "Empfangsschein" / "Receipt" / etc.
-			tr.AccountPayableTo, // "Konto / Zahlbar an"
-			banking.IBAN,
-			senderName,
-			senderStreet,
-			senderLocation,
-			tr.PayableBy, // "Zahlbar durch"
-			userName,
-			userStreet,
-			userLocation,
-			tr.Currency,    // "Währung"
-			tr.AmountLabel, // "Betrag"
-			currency,
-			totalAmount,
-			tr.AcceptancePoint, // "Annahmestelle"
-			tr.PaymentPart,     // "Zahlteil"
-			qrCodeContent,
-			tr.AccountPayableTo, // "Konto / Zahlbar an"
-			banking.IBAN,
-			senderName,
-			senderStreet,
-			senderLocation,
-			tr.AdditionalInfo, // "Zusätzliche Informationen"
-			tr.InvoiceLabel,   // "Invoice" / "Rechnung" / etc.
-			invoiceNumber,
-			tr.PayableBy, // "Zahlbar durch"
-			userName,
-			userStreet,
-			userLocation,
-			tr.Currency,    // "Währung"
-			tr.AmountLabel, // "Betrag"
-			currency,
-			totalAmount,
-		)
-	}
-
-	// Build complete HTML
-	html := fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-	<title>Invoice %s</title>
-	<meta charset="UTF-8">
-	<style>
-		@page {
-			size: A4;
-			margin: 15mm;
-		}
-		
-		body { 
-			font-family: Arial, sans-serif; 
-			padding: 0;
-			margin: 0;
-			max-width: 210mm;
-			font-size: 10pt;
-		}
-		
-		.page {
-			padding: 20px;
-			position: relative;
-			box-sizing: border-box;
-		}
-		
-		.qr-page {
-			page-break-before: always;
-			padding: 0;
-			margin: 0;
-		}
-		
-		.header {
-			border-bottom: 2px solid #667EEA;
-			padding-bottom: 15px;
-			margin-bottom: 20px;
-			display: flex;
-			justify-content: space-between;
-			align-items: flex-start;
-		}
-
-		.header-left h1 {
-			margin: 0;
-			font-size: 24pt;
-			color: #667EEA;
-		}
-
-		.intro-text, .footer-text {
-			margin: 0 0 18px 0;
-			padding: 12px 14px;
-			background: #f8fafc;
-			border-left: 3px solid #667EEA;
-			font-size: 10pt;
-			line-height: 1.5;
-			white-space: pre-wrap;
-		}
-		.footer-text { margin: 18px 0 0 0; }
-		
-		.header-left .invoice-number { 
-			color: #666; 
-			font-size: 10pt; 
-			margin-top: 4px;
-		}
-		
-		.header-right {
-			text-align: right;
-			font-size: 9pt;
-			line-height: 1.4;
-		}
-		
-		.header-right strong {
-			display: block;
-			font-size: 10pt;
-			margin-bottom: 3px;
-		}
-		
-		.status-badge {
-			display: inline-block;
-			padding: 4px 12px;
-			border-radius: 15px;
-			font-size: 9pt;
-			font-weight: 600;
-			margin-top: 8px;
-			background-color: %s;
-			color: %s;
-		}
-		
-		.archived-banner {
-			background-color: #f8d7da;
-			color: #721c24;
-			padding: 10px;
-			text-align: center;
-			font-weight: bold;
-			border-radius: 6px;
-			margin-bottom: 15px;
-			border: 2px solid #f5c6cb;
-			font-size: 10pt;
-		}
-		
-		.addresses {
-			display: flex;
-			justify-content: space-between;
-			margin-bottom: 20px;
-		}
-		
-		.info-section { 
-			flex: 1;
-		}
-		
-		.info-section h3 { 
-			font-size: 10pt; 
-			text-transform: uppercase; 
-			color: #666; 
-			margin-bottom: 8px;
-			font-weight: 600;
-		}
-		
-		.info-section p { 
-			margin: 3px 0; 
-			line-height: 1.4;
-			font-size: 9pt;
-		}
-		
-		table { 
-			width: 100%%; 
-			border-collapse: collapse; 
-			margin: 20px 0;
-			font-size: 9pt;
-		}
-		
-		th { 
-			background-color: #f9f9f9; 
-			padding: 8px; 
-			text-align: left; 
-			border-bottom: 2px solid #ddd;
-			font-weight: 600;
-			font-size: 9pt;
-		}
-		
-		td { 
-			padding: 8px; 
-			border-bottom: 1px solid #eee;
-			font-size: 9pt;
-		}
-		
-		.text-right { 
-			text-align: right;
-		}
-		
-		.item-header { 
-			font-weight: 600;
-			background-color: #f9f9f9;
-			border-bottom: 2px solid #ddd;
-		}
-		
-		.item-info { 
-			color: #666;
-			font-size: 8pt;
-			background-color: white;
-			border-bottom: none;
-		}
-		
-		.item-info-compact { 
-			color: #666;
-			font-size: 8pt;
-			background-color: white;
-			padding: 4px 8px;
-		}
-		
-		.item-cost { 
-			font-weight: 500;
-		}
-		
-		.solar-highlight {
-			background-color: rgba(254, 243, 199, 0.4);
-		}
-		
-		.normal-highlight {
-			background-color: rgba(219, 234, 254, 0.4);
-		}
-		
-		.charging-highlight {
-			background-color: rgba(209, 250, 229, 0.4);
-		}
-		
-		.section-separator {
-			height: 12px;
-			background-color: transparent;
-			border: none;
-		}
-		
-		.total-section { 
-			background-color: #f9f9f9; 
-			padding: 15px; 
-			text-align: right; 
-			margin-top: 20px;
-			border-radius: 6px;
-			margin-bottom: 20px;
-		}
-		
-		.total-section p {
-			font-size: 18pt;
-			font-weight: bold;
-			margin: 0;
-		}
-
-		.total-section p.total-sub {
-			font-size: 11pt;
-			font-weight: normal;
-			color: #555;
-			margin: 0 0 4px 0;
-		}
-
-		.payment-details-bottom {
-			padding: 15px 0;
-			margin-top: 30px;
-			border-top: 2px solid #ddd;
-			font-size: 8pt;
-			color: #666;
-		}
-		
-		.payment-details-bottom h4 {
-			font-size: 9pt;
-			font-weight: 600;
-			margin: 0 0 8px 0;
-			color: #333;
-		}
-		
-		.payment-details-bottom p {
-			margin: 2px 0;
-			line-height: 1.4;
-			font-size: 8pt;
-		}
-
-		.vzev-notice td {
-			background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
-			color: white;
-			padding: 12px 16px;
-			border-radius: 6px;
-			font-weight: 600;
-			text-align: center;
-			margin-bottom: 12px;
-		}
-
-		.proration-notice td {
-			background-color: #fff3cd;
-			color: #856404;
-			padding: 10px 16px;
-			border-left: 4px solid #ffc107;
-			border-radius: 4px;
-			font-size: 13px;
-		}
-
-		.vzev-breakdown-header td {
-			background-color: #f3f4f6;
-			padding: 10px 16px;
-			font-weight: 600;
-			color: #4338ca;
-			border-left: 4px solid #8b5cf6;
-			font-size: 15px;
-		}
-
-		.vzev-self-solar td {
-			background-color: #fef3c7;
-		}
-
-		.vzev-self-solar strong {
-			color: #92400e;
-		}
-
-		.vzev-virtual-pv td {
-			background-color: #ede9fe;
-		}
-
-		.vzev-virtual-pv strong {
-			color: #5b21b6;
-		}
-		
-		.footer-timestamp {
-			text-align: right;
-			font-size: 7pt;
-			color: #999;
-			margin-top: 8px;
-		}
-		
-		.qr-page {
-			page-break-before: always;
-			padding: 0;
-			margin: 0;
-		}
-		
-		.qr-container {
-			width: 210mm;
-			height: 105mm;
-			margin: 0;
-			padding: 0;
-			position: relative;
-			overflow: hidden;
-		}
-		
-		.qr-left {
-			border-right: 1px dashed #000;
-			width: 62mm;
-			height: 105mm;
-			position: absolute;
-			left: 0;
-			top: 0;
-			padding: 5mm 5mm 15mm 5mm;
-			box-sizing: border-box;
-			font-size: 6pt;
-		}
-		
-		.qr-right {
-			width: 148mm;
-			height: 105mm;
-			position: absolute;
-			left: 62mm;
-			top: 0;
-			padding: 5mm 5mm 15mm 5mm;
-			box-sizing: border-box;
-			font-size: 8pt;
-		}
-		
-		.qr-right-layout {
-			display: flex;
-			gap: 5mm;
-			margin-top: 3mm;
-		}
-		
-		.qr-code-column {
-			flex-shrink: 0;
-			width: 52mm;
-		}
-		
-		.qr-info-column {
-			flex: 1;
-		}
-		
-		.qr-section-title {
-			font-size: 11pt;
-			font-weight: bold;
-			margin: 0 0 3mm 0;
-		}
-		
-		.qr-info {
-			margin-bottom: 4mm;
-		}
-		
-		.qr-info p {
-			margin: 0 0 1mm 0;
-			padding: 0;
-			line-height: 1.3;
-		}
-		
-		.qr-info strong {
-			font-weight: bold;
-			display: block;
-			margin-bottom: 1mm;
-		}
-		
-		.qr-code-wrapper {
-			text-align: left;
-			margin: 0;
-			width: 46mm;
-			height: 46mm;
-		}
-		
-		.qr-code-wrapper img {
-			width: 46mm;
-			height: 46mm;
-			display: block;
-		}
-		
-		.qr-amount-box {
-			position: absolute;
-			bottom: 25mm;
-			left: 5mm;
-			right: 5mm;
-			padding-top: 2mm;
-			border-top: none;
-		}
-		
-		.qr-amount-box p {
-			margin: 0.5mm 0;
-			line-height: 1.1;
-		}
-		
-		.qr-acceptance-point {
-			position: absolute;
-			bottom: 20mm;
-			right: 5mm;
-			font-size: 6pt;
-			font-weight: bold;
-			text-align: right;
-		}
-		
-		@media print {
-			body { 
-				padding: 0; 
-				font-size: 10pt; 
-			}
-			
-			.page { 
-				padding: 15px;
-			}
-			
-			.qr-page {
-				page-break-before: always;
-				padding: 0;
-				margin: 0;
-				height: 105mm;
-			}
-			
-			@page { 
-				margin: 15mm;
-				size: A4 portrait;
-			}
-			
-			.qr-page {
-				page: qr-bill;
-			}
-			
-			@page qr-bill {
-				margin: 0;
-				size: 210mm 105mm;
-			}
-			
-			* {
-				-webkit-print-color-adjust: exact !important;
-				print-color-adjust: exact !important;
-				color-adjust: exact !important;
+			it := InvoiceTemplateItem{
+				Type:        fmt.Sprintf("%v", itemMap["item_type"]),
+				Description: fmt.Sprintf("%v", itemMap["description"]),
+				Currency:    currency,
 			}
+			it.Quantity, _ = itemMap["quantity"].(float64)
+			it.UnitPrice, _ = itemMap["unit_price"].(float64)
+			it.Total, _ = itemMap["total_price"].(float64)
+			d.Items = append(d.Items, it)
 		}
-	</style>
-</head>
-<body>
-	<div class="page">
-		%s
-		
-		<div class="header">
-			<div class="header-left">
-				<h1>%s</h1>
-				<div class="invoice-number">#%s</div>
-				<div class="status-badge">%s</div>
-			</div>
-			%s
-		</div>
-
-		<div class="addresses">
-			<div class="info-section">
-				<h3>%s</h3>
-				<p>%s</p>
-			</div>
-
-			<div class="info-section">
-				<h3>%s</h3>
-				<p>
-					<strong>%s:</strong> %s to %s<br>
-					<strong>%s:</strong> %s<br>
-					<strong>%s:</strong> %s
-				</p>
-			</div>
-		</div>
-
-		%s
-
-		<table>
-			<thead>
-				<tr>
-					<th>%s</th>
-					<th class="text-right">%s</th>
-				</tr>
-			</thead>
-			<tbody>
-				%s
-			</tbody>
-		</table>
-
-		<div class="total-section">
-			%s
-			<p>%s %s %.2f</p>
-		</div>
-
-		%s
-
-		%s
-	</div>
-
-	%s
-</body>
-</html>`,
-		invoiceNumber,
-		statusColors.bg, statusColors.color,
-		archivedBanner,
-		titleText,
-		invoiceNumber,
-		strings.ToUpper(status),
-		senderSection,
-		tr.BillTo,
-		userInfo,
-		tr.InvoiceDetails,
-		tr.Period,
-		formatDate(periodStart), formatDate(periodEnd),
-		tr.Generated,
-		formatDate(generatedAt),
-		tr.Status,
-		status,
-		introHTML,
-		tr.Description,
-		tr.Amount,
-		itemsHTML,
-		vatLinesHTML,
-		tr.Total,
-		currency, totalAmount,
-		footerHTML,
-		paymentSection,
-		qrPage,
-	)
-
-	// Apply per-building accent colour to the main page only. The QR-bill page
-	// uses different class names (qr-*) and is left untouched.
-	if accentColor != "" && accentColor != "#667EEA" {
-		html = strings.ReplaceAll(html, "#667EEA", accentColor)
 	}
 
-	return html, nil
-}
-
-func (pg *PDFGenerator) generateItemHTML(item map[string]interface{}, currency string) string {
-	description := fmt.Sprintf("%v", item["description"])
-	itemType := fmt.Sprintf("%v", item["item_type"])
-	totalPrice := 0.0
-	if tp, ok := item["total_price"].(float64); ok {
-		totalPrice = tp
-	}
-
-	// Icons as inline SVG
-	sunIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="#f59e0b" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><circle cx="12" cy="12" r="4"/><path d="M12 2v2"/><path d="M12 20v2"/><path d="m4.93 4.93 1.41 1.41"/><path d="m17.66 17.66 1.41 1.41"/><path d="M2 12h2"/><path d="M20 12h2"/><path d="m6.34 17.66-1.41 1.41"/><path d="m19.07 4.93-1.41 1.41"/></svg>`
-
-	boltIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="#3b82f6" stroke-width="2"><path d="M13 2 3 14h9l-1 8 10-12h-9l1-8z"/></svg>`
-
-	carIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="#10b981" stroke-width="2"><path d="M19 17h2c.6 0 1-.4 1-1v-3c0-.9-.7-1.7-1.5-1.9C18.7 10.6 16 10 16 10s-1.3-1.4-2.2-2.3c-.5-.4-1.1-.7-1.8-.7H5c-.6 0-1.1.4-1.4.9l-1.4 2.9A3.7 3.7 0 0 0 2 12v4c0 .6.4 1 1 1h2"/><circle cx="7" cy="17" r="2"/><path d="M9 17h6"/><circle cx="17" cy="17" r="2"/></svg>`
-
-	vzevIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="#8b5cf6" stroke-width="2"><path d="M13 2 3 14h9l-1 8 10-12h-9l1-8z"/><circle cx="12" cy="12" r="3" fill="#8b5cf6" opacity="0.2"/></svg>`
-
-	// Battery with a charging bolt, matching the dashboard/list battery colour.
-	batteryIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="#a855f7" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M15 7h1a2 2 0 0 1 2 2v6a2 2 0 0 1-2 2h-2"/><path d="M6 7H5a2 2 0 0 0-2 2v6a2 2 0 0 0 2 2h1"/><path d="m11 7-3 5h4l-3 5"/><line x1="22" x2="22" y1="11" y2="13"/></svg>`
-
-	switch itemType {
-	case "meter_info":
-		return fmt.Sprintf(`<tr class="item-header"><td colspan="2"><strong>%s</strong></td></tr>`, description)
-
-	case "charging_header":
-		return fmt.Sprintf(`<tr class="section-separator"><td colspan="2"></td></tr><tr class="item-header"><td colspan="2"><strong>%s</strong></td></tr>`, description)
-
-	case "meter_reading_compact":
-		// This should be a SINGLE line with all info: date range, old/new readings, consumption
-		return fmt.Sprintf(`<tr class="item-info-compact"><td colspan="2" style="padding: 6px 8px;">%s</td></tr>`, description)
-
-	case "charging_session_compact":
-		// Single line with charging session period and total
-		return fmt.Sprintf(`<tr class="item-info-compact"><td colspan="2" style="padding: 6px 8px;">%s</td></tr>`, description)
-
-	case "separator":
-		return `<tr class="section-separator"><td colspan="2"></td></tr>`
-
-	case "solar_power":
-		return fmt.Sprintf(`<tr class="item-cost solar-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, sunIcon, description, currency, totalPrice)
-
-	case "battery_power":
-		// Battery-supplied energy (stored solar released later). Shares the solar
-		// highlight since it is renewable, with a battery icon.
-		return fmt.Sprintf(`<tr class="item-cost solar-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, batteryIcon, description, currency, totalPrice)
-
-	case "normal_power":
-		return fmt.Sprintf(`<tr class="item-cost normal-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, boltIcon, description, currency, totalPrice)
-
-	case "car_charging_normal":
-		// Solar charging (mode "Solar Mode" / solar-split solar share): car + sun,
-		// mirroring the meter solar icon so solar is recognisable everywhere.
-		return fmt.Sprintf(`<tr class="item-cost charging-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, carIcon, sunIcon, description, currency, totalPrice)
-
-	case "car_charging_battery":
-		// Battery charging (solar-split battery share): car + battery icon.
-		return fmt.Sprintf(`<tr class="item-cost charging-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, carIcon, batteryIcon, description, currency, totalPrice)
-
-	case "car_charging_priority":
-		// Priority / grid charging (mode "Priority Mode" / solar-split grid share):
-		// car + bolt, mirroring the meter grid icon.
-		return fmt.Sprintf(`<tr class="item-cost charging-highlight">
-			<td style="padding-left: 20px;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s%s
-					<strong>%s</strong>
-				</span>
-			</td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, carIcon, boltIcon, description, currency, totalPrice)
-
-	case "custom_item_header":
-		return fmt.Sprintf(`<tr class="section-separator"><td colspan="2"></td></tr><tr class="item-header"><td colspan="2"><strong>%s</strong></td></tr>`, description)
-
-	case "custom_item":
-		// Custom items with price
-		return fmt.Sprintf(`<tr class="item-cost">
-			<td style="padding-left: 8px;"><strong>%s</strong></td>
-			<td class="text-right"><strong>%s %.2f</strong></td>
-		</tr>`, description, currency, totalPrice)
-
-	case "vzev_notice":
-		// Special styling for vZEV mode indicator
-		return fmt.Sprintf(`<tr class="vzev-notice">
-			<td colspan="2" style="background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white; padding: 12px 16px; border-radius: 6px; font-weight: 600; text-align: center; margin-bottom: 12px;">
-				%s
-			</td>
-		</tr>`, description)
-
-	case "proration_notice":
-		// Notice for partial billing periods
-		return fmt.Sprintf(`<tr class="proration-notice">
-			<td colspan="2" style="background-color: #fff3cd; color: #856404; padding: 10px 16px; border-left: 4px solid #ffc107; border-radius: 4px; font-size: 13px;">
-				%s
-			</td>
-		</tr>`, description)
-
-	case "charging_warning":
-		// Prominent warning: a charger counter reset/glitch occurred in the period.
-		return fmt.Sprintf(`<tr class="charging-warning">
-			<td colspan="2" style="background-color: #fde8e8; color: #9b1c1c; padding: 10px 16px; border-left: 4px solid #e02424; border-radius: 4px; font-size: 13px;">
-				%s
-			</td>
-		</tr>`, description)
-
-	case "vzev_breakdown_header":
-		// Header for the vZEV energy breakdown section
-		return fmt.Sprintf(`<tr class="section-separator"><td colspan="2"></td></tr><tr class="vzev-breakdown-header">
-			<td colspan="2" style="background-color: #f3f4f6; padding: 10px 16px; font-weight: 600; color: #4338ca; border-left: 4px solid #8b5cf6; font-size: 15px;">
-				%s
-			</td>
-		</tr>`, description)
-
-	case "vzev_self_solar":
-		// Self-consumed solar (from own building) - bright yellow/orange highlight
-		return fmt.Sprintf(`<tr class="item-cost vzev-self-solar">
-			<td style="padding-left: 24px; background-color: #fef3c7;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s
-					<strong style="color: #92400e;">%s</strong>
-				</span>
-			</td>
-			<td class="text-right" style="background-color: #fef3c7;"><strong style="color: #92400e;">%s %.2f</strong></td>
-		</tr>`, sunIcon, description, currency, totalPrice)
-
-	case "vzev_virtual_pv":
-		// Virtual PV from other buildings in vZEV - purple highlight
-		return fmt.Sprintf(`<tr class="item-cost vzev-virtual-pv">
-			<td style="padding-left: 24px; background-color: #ede9fe;">
-				<span style="display: inline-flex; align-items: center; gap: 6px;">
-					%s
-					<strong style="color: #5b21b6;">%s</strong>
-				</span>
-			</td>
-			<td class="text-right" style="background-color: #ede9fe;"><strong style="color: #5b21b6;">%s %.2f</strong></td>
-		</tr>`, vzevIcon, description, currency, totalPrice)
-
-	default:
-		if totalPrice > 0 {
-			return fmt.Sprintf(`<tr class="item-cost">
-				<td><strong>%s</strong></td>
-				<td class="text-right"><strong>%s %.2f</strong></td>
-			</tr>`, description, currency, totalPrice)
-		}
-		return fmt.Sprintf(`<tr class="item-info-compact"><td colspan="2">%s</td></tr>`, description)
+	if d.ShowPayment {
+		d.QR.Payload = pg.generateSwissQRData(inv, sender, banking)
 	}
+	return d
 }
 
 type statusColor struct {
@@ -1215,6 +528,14 @@ func getStatusColors(status string) statusColor {
 		return statusColor{bg: "#f8d7da", color: "#721c24"}
 	case "archived":
 		return statusColor{bg: "#e2e3e5", color: "#383d41"}
+	case "cancelled":
+		return statusColor{bg: "#f8d7da", color: "#721c24"}
+	case "credited":
+		return statusColor{bg: "#e2d9f3", color: "#4a2a7a"}
+	case "preview":
+		return statusColor{bg: "#fff3cd", color: "#856404"}
+	case "overdue":
+		return statusColor{bg: "#f8d7da", color: "#721c24"}
 	default:
 		return statusColor{bg: "#e2e3e5", color: "#383d41"}
 	}
@@ -1267,6 +588,9 @@ func (pg *PDFGenerator) generateSwissQRData(inv map[string]interface{}, sender S
 	if ta, ok := inv["total_amount"].(float64); ok {
 		totalAmount = ta
 	}
+	if credit, ok := inv["credit_applied"].(float64); ok {
+		totalAmount -= credit
+	}
 	currency := strings.ToUpper(fmt.Sprintf("%v", inv["currency"]))
 	// Swiss QR-bill only permits CHF or EUR.
 	if currency != "CHF" && currency != "EUR" {
@@ -1280,7 +604,12 @@ func (pg *PDFGenerator) generateSwissQRData(inv map[string]interface{}, sender S
 	reference := ""
 	if isQRIBAN(iban) {
 		referenceType = "QRR"
-		reference = qrReference(invoiceNumber)
+		// A reminder pays the original invoice, so it carries that reference.
+		invoiceID, _ := inv["id"].(int)
+		reference = invoiceReference(invoiceID, invoiceNumber)
+		if r, ok := inv["payment_reference"].(string); ok && r != "" {
+			reference = r
+		}
 	}
 
 	// Parse addresses
@@ -1445,6 +774,24 @@ func qrReference(source string) string {
 	return base + fmt.Sprintf("%d", check)
 }
 
+// invoiceReference is the QR reference of an invoice: the last 16 digits of
+// its number followed by its id in 10 digits. The number alone is not unique
+// once number schemes with different prefixes or building codes exist
+// ("A-2026-00001" and "B-2026-00001"); the id is.
+func invoiceReference(invoiceID int, invoiceNumber string) string {
+	var digits strings.Builder
+	for _, r := range invoiceNumber {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	base := digits.String()
+	if len(base) > 16 {
+		base = base[len(base)-16:]
+	}
+	return qrReference(fmt.Sprintf("%s%010d", base, invoiceID))
+}
+
 // isQRIBAN reports whether a CH/LI IBAN is a QR-IBAN (institution ID in the
 // reserved 30000–31999 range), which mandates a QRR reference.
 func isQRIBAN(iban string) bool {
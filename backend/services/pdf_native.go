@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal PDF 1.4 writer for the native invoice renderer. It only knows what
+// the invoice needs: A4 pages, the two standard Helvetica fonts (no embedding),
+// filled rectangles, lines, circles and single-line text. Coordinates are in
+// millimetres from the top-left corner, like the HTML layout. The output
+// depends only on what was drawn — no clock, no random IDs — so rendering the
+// same invoice twice produces identical bytes.
+
+const (
+	pdfPageWidthMM  = 210.0
+	pdfPageHeightMM = 297.0
+	pdfPtPerMM      = 72.0 / 25.4
+)
+
+type pdfDoc struct {
+	title   string
+	created time.Time
+	pages   []*pdfPage
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func newPDFDoc(title string, created time.Time) *pdfDoc {
+	return &pdfDoc{title: title, created: created}
+}
+
+func (d *pdfDoc) addPage() *pdfPage {
+	p := &pdfPage{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// pdfNum formats a coordinate with two decimals and no trailing zeros.
+func pdfNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func pdfX(mm float64) string { return pdfNum(mm * pdfPtPerMM) }
+func pdfY(mm float64) string { return pdfNum((pdfPageHeightMM - mm) * pdfPtPerMM) }
+
+// pdfColor converts "#rrggbb" (or "#rgb") to PDF RGB components; anything
+// unparsable becomes black.
+func pdfColor(hex string) string {
+	h := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if len(h) != 6 || err != nil {
+		return "0 0 0"
+	}
+	return fmt.Sprintf("%s %s %s",
+		pdfNum(float64(v>>16&0xff)/255), pdfNum(float64(v>>8&0xff)/255), pdfNum(float64(v&0xff)/255))
+}
+
+func (p *pdfPage) rect(x, y, w, h float64, fill string) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		pdfColor(fill), pdfX(x), pdfY(y+h), pdfNum(w*pdfPtPerMM), pdfNum(h*pdfPtPerMM))
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2, width float64, stroke string, dashed bool) {
+	dash := "[] 0 d"
+	if dashed {
+		dash = "[2 2] 0 d"
+	}
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s %s m %s %s l S\n",
+		pdfColor(stroke), pdfNum(width*pdfPtPerMM), dash, pdfX(x1), pdfY(y1), pdfX(x2), pdfY(y2))
+}
+
+// circle fills a circle using four Bézier arcs.
+func (p *pdfPage) circle(cx, cy, r float64, fill string) {
+	k := 0.5523 * r
+	fmt.Fprintf(&p.content, "%s rg %s %s m ", pdfColor(fill), pdfX(cx+r), pdfY(cy))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfX(cx+r), pdfY(cy+k), pdfX(cx+k), pdfY(cy+r), pdfX(cx), pdfY(cy+r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfX(cx-k), pdfY(cy+r), pdfX(cx-r), pdfY(cy+k), pdfX(cx-r), pdfY(cy))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfX(cx-r), pdfY(cy-k), pdfX(cx-k), pdfY(cy-r), pdfX(cx), pdfY(cy-r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c f\n", pdfX(cx+k), pdfY(cy-r), pdfX(cx+r), pdfY(cy-k), pdfX(cx+r), pdfY(cy))
+}
+
+// text draws s with its baseline at y.
+func (p *pdfPage) text(x, y, size float64, bold bool, color, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n",
+		font, pdfNum(size), pdfColor(color), pdfX(x), pdfY(y), pdfEscape(s))
+}
+
+func (p *pdfPage) textRight(right, y, size float64, bold bool, color, s string) {
+	p.text(right-textWidthMM(s, size, bold), y, size, bold, color, s)
+}
+
+func (p *pdfPage) textCenter(center, y, size float64, bold bool, color, s string) {
+	p.text(center-textWidthMM(s, size, bold)/2, y, size, bold, color, s)
+}
+
+// pdfEscape encodes s in WinAnsiEncoding and escapes it for a string literal.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, c := range winAnsiBytes(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// winAnsiExtra maps the non-Latin-1 characters that occur in invoice texts to
+// their WinAnsiEncoding code.
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+func winAnsiBytes(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			out = append(out, ' ')
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case winAnsiExtra[r] != 0:
+			out = append(out, winAnsiExtra[r])
+		case r == 0xFE0F: // emoji variation selector: drop silently
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// Glyph widths (1/1000 em) of Helvetica and Helvetica-Bold for ASCII 32–126.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// latin1Base gives the unaccented letter for 0xC0–0xFF, whose width is close
+// enough for layout purposes.
+const latin1Base = "AAAAAAACEEEEIIIIDNOOOOO*OUUUUYPsaaaaaaaceeeeiiiidnooooo/ouuuuypy"
+
+// textWidthMM measures s in millimetres at the given font size.
+func textWidthMM(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range winAnsiBytes(s) {
+		if c >= 0xC0 {
+			c = latin1Base[c-0xC0]
+		}
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000 / pdfPtPerMM
+}
+
+// wrapText breaks s into lines no wider than maxWidth millimetres, keeping
+// explicit line breaks.
+func wrapText(s string, size float64, bold bool, maxWidth float64) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		current := words[0]
+		for _, w := range words[1:] {
+			if textWidthMM(current+" "+w, size, bold) > maxWidth {
+				lines = append(lines, current)
+				current = w
+			} else {
+				current += " " + w
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// fitText shortens s with an ellipsis so it fits into maxWidth millimetres.
+func fitText(s string, size float64, bold bool, maxWidth float64) string {
+	if textWidthMM(s, size, bold) <= maxWidth {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && textWidthMM(string(r)+"…", size, bold) > maxWidth {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// bytes serialises the document with compressed page contents and a classic
+// cross-reference table (which EmbedFacturX relies on).
+func (d *pdfDoc) bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+	var out bytes.Buffer
+	var offsets []int
+	begin := func() int {
+		offsets = append(offsets, out.Len())
+		n := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n", n)
+		return n
+	}
+	end := func() { out.WriteString("endobj\n") }
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1–5 are fixed; page i uses objects 6+2i (page) and 7+2i (content).
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	begin()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\n")
+	end()
+	begin()
+	fmt.Fprintf(&out, "<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(d.pages))
+	end()
+	begin()
+	out.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\n")
+	end()
+	begin()
+	out.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\n")
+	end()
+	begin()
+	date := d.created.UTC().Format("20060102150405") + "Z"
+	fmt.Fprintf(&out, "<< /Title (%s) /Producer (ZEV Billing) /CreationDate (D:%s) /ModDate (D:%s) >>\n",
+		pdfEscape(d.title), date, date)
+	end()
+
+	hash := md5.New()
+	hash.Write([]byte(d.title))
+	for i, p := range d.pages {
+		begin()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\n",
+			pdfX(pdfPageWidthMM), pdfNum(pdfPageHeightMM*pdfPtPerMM), 7+2*i)
+		end()
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(p.content.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		hash.Write(p.content.Bytes())
+		begin()
+		fmt.Fprintf(&out, "<< /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\n")
+		end()
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	id := fmt.Sprintf("%x", hash.Sum(nil))
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R /ID [<%s> <%s>] >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, id, id, xref)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,617 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Page geometry of the native renderer (mm). The main pages use the same 15mm
+// margins as the wkhtmltopdf conversion.
+const (
+	nativeLeft   = 15.0
+	nativeRight  = 195.0
+	nativeTop    = 15.0
+	nativeBottom = 282.0
+	nativeWidth  = nativeRight - nativeLeft
+)
+
+// nativeInvoice tracks the current page and vertical cursor while the main
+// invoice pages are laid out top to bottom.
+type nativeInvoice struct {
+	doc  *pdfDoc
+	page *pdfPage
+	y    float64
+}
+
+// need starts a new page when the next h millimetres no longer fit.
+func (n *nativeInvoice) need(h float64) {
+	if n.y+h > nativeBottom {
+		n.page = n.doc.addPage()
+		n.y = nativeTop
+	}
+}
+
+// renderNativeInvoicePDF draws the same invoice as the built-in invoice
+// template — layout overrides, document types, item styling, totals, payment
+// details and the Swiss QR-bill — straight into a PDF, without an external
+// converter. Custom invoice templates do not apply here.
+func (pg *PDFGenerator) renderNativeInvoicePDF(inv map[string]interface{}, sender SenderInfo, banking BankingInfo) ([]byte, error) {
+	invoiceNumber := fmt.Sprintf("%v", inv["invoice_number"])
+	status := fmt.Sprintf("%v", inv["status"])
+	currency := fmt.Sprintf("%v", inv["currency"])
+	if currency == "" {
+		currency = "CHF"
+	}
+
+	userLanguage := "de"
+	if user, ok := inv["user"].(map[string]interface{}); ok {
+		if lang, ok := user["language"].(string); ok && lang != "" {
+			userLanguage = lang
+		}
+	}
+	tr := GetTranslations(userLanguage)
+
+	buildingID := 0
+	switch v := inv["building_id"].(type) {
+	case int:
+		buildingID = v
+	case int64:
+		buildingID = int(v)
+	case float64:
+		buildingID = int(v)
+	}
+	layout := pg.loadBillLayout(buildingID)
+
+	titleText := tr.Invoice
+	if layout.Title != "" {
+		titleText = layout.Title
+	}
+	accentColor := layout.PrimaryColor
+	if accentColor == "" {
+		accentColor = "#667EEA"
+	}
+	introText := layout.IntroText
+
+	documentType, _ := inv["document_type"].(string)
+	originalNumber, _ := inv["original_invoice_number"].(string)
+	isCreditNote := documentType == DocumentTypeCreditNote
+	switch documentType {
+	case DocumentTypeCreditNote:
+		titleText = tr.CreditNote
+	case DocumentTypeAdvance:
+		titleText = tr.AdvanceInvoice
+	case DocumentTypeSettlement:
+		titleText = tr.SettlementInvoice
+	case DocumentTypeSupplementary:
+		titleText = tr.SupplementaryInvoice
+	case DocumentTypeProducerCredit:
+		titleText = tr.ProducerCredit
+	case DocumentTypeReminder:
+		level, _ := inv["dunning_level"].(int)
+		titleText = reminderTitle(tr, level)
+	}
+	var introBoxes []string
+	if text, _ := inv["reminder_text"].(string); text != "" {
+		introBoxes = []string{text}
+	} else {
+		if originalNumber != "" {
+			refText := tr.ReplacesInvoice
+			if correction, _ := inv["correction"].(bool); correction {
+				refText = tr.CorrectsInvoice
+			} else if isCreditNote {
+				refText = tr.CreditNoteFor
+			}
+			introBoxes = append(introBoxes, fmt.Sprintf("%s #%s", refText, originalNumber))
+		}
+		if introText != "" {
+			introBoxes = append(introBoxes, introText)
+		}
+	}
+	showPayment := banking.IBAN != "" && banking.AccountHolder != "" && !isCreditNote && status != InvoiceStatusPreview
+
+	totalAmount, _ := inv["total_amount"].(float64)
+	if totalAmount <= 0 {
+		showPayment = false
+	}
+	creditApplied, _ := inv["credit_applied"].(float64)
+	amountDue := totalAmount - creditApplied
+	if creditApplied > 0 && amountDue < 0.005 {
+		showPayment = false
+	}
+	vatRate, _ := inv["vat_rate"].(float64)
+	vatAmount, _ := inv["vat_amount"].(float64)
+	netAmount := totalAmount
+	if v, ok := inv["net_amount"].(float64); ok && v > 0 {
+		netAmount = v
+	}
+	vatIncluded, _ := inv["vat_included"].(bool)
+
+	periodStart := fmt.Sprintf("%v", inv["period_start"])
+	periodEnd := fmt.Sprintf("%v", inv["period_end"])
+	generatedAt := fmt.Sprintf("%v", inv["generated_at"])
+
+	// The document date is the only timestamp in the file, which keeps the
+	// output identical across runs.
+	created, err := time.Parse("2006-01-02", generatedAt)
+	if err != nil {
+		created, _ = time.Parse(time.RFC3339, generatedAt)
+	}
+	doc := newPDFDoc(fmt.Sprintf("%s %s", titleText, invoiceNumber), created)
+	n := &nativeInvoice{doc: doc, page: doc.addPage(), y: nativeTop}
+
+	isArchived := false
+	user, _ := inv["user"].(map[string]interface{})
+	if user != nil {
+		if isActive, ok := user["is_active"].(bool); ok {
+			isArchived = !isActive
+		}
+	}
+	if isArchived {
+		n.page.rect(nativeLeft, n.y, nativeWidth, 10, "#f5c6cb")
+		n.page.rect(nativeLeft+0.5, n.y+0.5, nativeWidth-1, 9, "#f8d7da")
+		n.page.textCenter(105, n.y+6.3, 10, true, "#721c24", "ARCHIVED USER - This invoice is for an archived user")
+		n.y += 14
+	}
+
+	// Header: title, number and status badge on the left, sender on the right.
+	top := n.y
+	n.page.text(nativeLeft, top+8, 24, true, accentColor, fitText(titleText, 24, true, 110))
+	n.page.text(nativeLeft, top+14, 10, false, "#666666", "#"+invoiceNumber)
+	badge := strings.ToUpper(status)
+	colors := getStatusColors(status)
+	badgeWidth := textWidthMM(badge, 9, true) + 8
+	n.page.rect(nativeLeft, top+17, badgeWidth, 6, colors.bg)
+	n.page.text(nativeLeft+4, top+21.2, 9, true, colors.color, badge)
+	headerBottom := top + 25
+	if sender.Name != "" {
+		n.page.textRight(nativeRight, top+4, 10, true, "#000000", sender.Name)
+		sy := top + 9
+		for _, l := range []string{sender.Address, strings.TrimSpace(sender.Zip + " " + sender.City), sender.Country} {
+			n.page.textRight(nativeRight, sy, 9, false, "#000000", l)
+			sy += 4.5
+		}
+		if sy > headerBottom {
+			headerBottom = sy
+		}
+	}
+	n.y = headerBottom + 2
+	n.page.rect(nativeLeft, n.y, nativeWidth, 0.7, accentColor)
+	n.y += 8
+
+	// Bill-to and invoice details side by side.
+	col2 := nativeLeft + nativeWidth/2
+	n.page.text(nativeLeft, n.y, 10, true, "#666666", strings.ToUpper(tr.BillTo))
+	n.page.text(col2, n.y, 10, true, "#666666", strings.ToUpper(tr.InvoiceDetails))
+	left := n.y + 6
+	if user != nil {
+		name := strings.TrimSpace(fmt.Sprintf("%v %v", user["first_name"], user["last_name"]))
+		if isArchived {
+			name += " (Archived)"
+		}
+		n.page.text(nativeLeft, left, 9, true, "#000000", name)
+		left += 4.5
+		for _, l := range []string{
+			fmt.Sprintf("%v", user["address_street"]),
+			strings.TrimSpace(fmt.Sprintf("%v %v", user["address_zip"], user["address_city"])),
+			fmt.Sprintf("%v", user["email"]),
+		} {
+			n.page.text(nativeLeft, left, 9, false, "#000000", fitText(l, 9, false, nativeWidth/2-4))
+			left += 4.5
+		}
+	}
+	details := [][2]string{
+		{tr.Period, fmt.Sprintf("%s to %s", formatDate(periodStart), formatDate(periodEnd))},
+		{tr.Generated, formatDate(generatedAt)},
+		{tr.Status, status},
+	}
+	if due, _ := inv["due_date"].(string); due != "" && showPayment {
+		details = append(details, [2]string{tr.DueDate, formatDate(due)})
+	}
+	right := n.y + 6
+	for _, d := range details {
+		label := d[0] + ": "
+		n.page.text(col2, right, 9, true, "#000000", label)
+		n.page.text(col2+textWidthMM(label, 9, true), right, 9, false, "#000000", d[1])
+		right += 4.5
+	}
+	if right > left {
+		left = right
+	}
+	n.y = left + 4
+
+	for _, text := range introBoxes {
+		n.textBox(text, accentColor)
+	}
+
+	// Items table.
+	n.need(20)
+	n.y += 2
+	n.page.rect(nativeLeft, n.y, nativeWidth, 8, "#f9f9f9")
+	n.page.rect(nativeLeft, n.y+8, nativeWidth, 0.5, "#dddddd")
+	n.page.text(nativeLeft+2.5, n.y+5.3, 9, true, "#000000", tr.Description)
+	n.page.textRight(nativeRight-2.5, n.y+5.3, 9, true, "#000000", tr.Amount)
+	n.y += 8.5
+	if items, ok := inv["items"].([]interface{}); ok {
+		for _, item := range items {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				n.item(itemMap, currency)
+			}
+		}
+	}
+	n.y += 6
+
+	// Totals.
+	var subLines []string
+	summary := multiRateVATSummary(inv)
+	if summary != nil {
+		n.vatSummaryTable(summary, tr)
+		if vatIncluded {
+			subLines = []string{fmt.Sprintf("%s: %s %.2f", tr.ThereofVAT, currency, vatAmount)}
+		} else {
+			subLines = []string{
+				fmt.Sprintf("%s: %s %.2f", tr.Subtotal, currency, netAmount),
+				fmt.Sprintf("%s: %s %.2f", tr.VAT, currency, vatAmount),
+			}
+		}
+	} else if vatRate > 0 {
+		if vatIncluded {
+			subLines = []string{fmt.Sprintf("%s %.1f%%: %s %.2f", tr.ThereofVAT, vatRate, currency, vatAmount)}
+		} else {
+			subLines = []string{
+				fmt.Sprintf("%s: %s %.2f", tr.Subtotal, currency, netAmount),
+				fmt.Sprintf("%s %.1f%%: %s %.2f", tr.VAT, vatRate, currency, vatAmount),
+			}
+		}
+	}
+	boxHeight := 14 + 5.5*float64(len(subLines))
+	if creditApplied > 0 {
+		boxHeight += 13
+	}
+	n.need(boxHeight + 6)
+	n.page.rect(nativeLeft, n.y, nativeWidth, boxHeight, "#f9f9f9")
+	ty := n.y + 8
+	for _, l := range subLines {
+		n.page.textRight(nativeRight-5, ty, 11, false, "#555555", l)
+		ty += 5.5
+	}
+	n.page.textRight(nativeRight-5, ty+2.5, 18, true, "#000000", fmt.Sprintf("%s %s %.2f", tr.Total, currency, totalAmount))
+	if creditApplied > 0 {
+		n.page.textRight(nativeRight-5, ty+8.5, 11, false, "#555555", fmt.Sprintf("%s: %s -%.2f", tr.CreditApplied, currency, creditApplied))
+		n.page.textRight(nativeRight-5, ty+15, 12, true, "#000000", fmt.Sprintf("%s %s %.2f", tr.AmountDue, currency, amountDue))
+	}
+	n.y += boxHeight + 7
+
+	if history := pg.consumptionHistory(inv, layout); history != nil {
+		n.consumptionChart(history, tr)
+	}
+	if layout.FooterText != "" {
+		n.textBox(layout.FooterText, accentColor)
+	}
+
+	if showPayment {
+		n.need(32)
+		n.y += 6
+		n.page.rect(nativeLeft, n.y, nativeWidth, 0.7, "#dddddd")
+		n.y += 7
+		n.page.text(nativeLeft, n.y, 9, true, "#333333", tr.PaymentInfo)
+		n.y += 5
+		for _, d := range [][2]string{
+			{tr.BankDetails, banking.Name},
+			{tr.AccountHolder, banking.AccountHolder},
+			{tr.IBAN, formatIBAN(banking.IBAN)},
+		} {
+			label := d[0] + ": "
+			n.page.text(nativeLeft, n.y, 8, true, "#666666", label)
+			n.page.text(nativeLeft+textWidthMM(label, 8, true), n.y, 8, false, "#666666", d[1])
+			n.y += 4
+		}
+		n.page.textRight(nativeRight, n.y+2, 7, false, "#999999", fmt.Sprintf("%s: %s", tr.Generated, formatDate(generatedAt)))
+
+		qrData := pg.generateSwissQRData(inv, sender, banking)
+		if err := drawNativeQRBill(doc.addPage(), tr, inv, sender, banking, qrData, currency, amountDue); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc.bytes()
+}
+
+// vatSummaryTable draws the VAT per rate of an invoice with lines at more
+// than one rate, right-aligned above the totals box.
+func (n *nativeInvoice) vatSummaryTable(summary []models.VATSummaryLine, tr InvoiceTranslations) {
+	cols := []float64{nativeRight - 66, nativeRight - 44, nativeRight - 24, nativeRight - 5}
+	n.need(7 + 5*float64(len(summary)))
+	n.page.text(nativeLeft+5, n.y+4, 8, true, "#555555", tr.VATSummary)
+	for i, h := range []string{tr.VATRate, tr.NetAmount, tr.VAT, tr.GrossAmount} {
+		n.page.textRight(cols[i], n.y+4, 8, true, "#555555", h)
+	}
+	n.page.rect(cols[0]-30, n.y+5.5, nativeRight-5-cols[0]+30, 0.2, "#dddddd")
+	n.y += 6
+	for _, l := range summary {
+		for i, v := range []string{vatSummaryLabel(l, tr), fmt.Sprintf("%.2f", l.Net), fmt.Sprintf("%.2f", l.VAT), fmt.Sprintf("%.2f", l.Gross)} {
+			n.page.textRight(cols[i], n.y+4, 8, false, "#555555", v)
+		}
+		n.y += 5
+	}
+	n.y += 3
+}
+
+// consumptionChart draws the consumption history the way
+// consumptionChartHTML renders it, followed by its legend.
+func (n *nativeInvoice) consumptionChart(history []ConsumptionMonth, tr InvoiceTranslations) {
+	const plotHeight = 36.0
+	n.need(plotHeight + 26)
+	n.page.text(nativeLeft, n.y+4, 10, true, "#333333", tr.ConsumptionHistory)
+	top := n.y + 9
+	bottom := top + plotHeight
+	left := nativeLeft + 12
+	scale := consumptionChartMax(history)
+	slot := (nativeRight - left) / float64(len(history))
+	y := func(kwh float64) float64 { return bottom - kwh/scale*plotHeight }
+
+	for _, v := range []float64{0, scale / 2, scale} {
+		n.page.line(left, y(v), nativeRight, y(v), 0.2, chartColorAxis, false)
+		n.page.textRight(left-1.5, y(v)+1, 7, false, "#666666", fmt.Sprintf("%.0f", v))
+	}
+	for i, m := range history {
+		center := left + slot*(float64(i)+0.5)
+		base := bottom
+		for _, seg := range m.chartSegments() {
+			h := seg.kwh / scale * plotHeight
+			n.page.rect(center-slot*0.25, base-h, slot*0.5, h, seg.color)
+			base -= h
+		}
+		if m.PreviousYear > 0 {
+			n.page.line(center-slot*0.35, y(m.PreviousYear), center+slot*0.35, y(m.PreviousYear), 0.6, chartColorPreviousYear, false)
+		}
+		if i > 0 {
+			n.page.line(center-slot, y(history[i-1].BuildingAverage), center, y(m.BuildingAverage), 0.4, chartColorAverage, true)
+		}
+		n.page.textCenter(center, bottom+4, 7, false, "#666666", m.Month.Format("01/06"))
+	}
+
+	x, ly := nativeLeft, bottom+10
+	for _, l := range consumptionChartLegend(history, tr) {
+		n.page.rect(x, ly-2.5, 3, 3, l[0])
+		n.page.text(x+4.5, ly, 7, false, "#555555", l[1])
+		x += 10.5 + textWidthMM(l[1], 7, false)
+	}
+	n.y = ly + 7
+}
+
+// textBox draws the intro/footer box: light background, accent bar on the left.
+func (n *nativeInvoice) textBox(text, accent string) {
+	lines := wrapText(text, 10, false, nativeWidth-10)
+	h := 6 + 5*float64(len(lines))
+	n.need(h + 5)
+	n.page.rect(nativeLeft, n.y, nativeWidth, h, "#f8fafc")
+	n.page.rect(nativeLeft, n.y, 1, h, accent)
+	ly := n.y + 7
+	for _, l := range lines {
+		n.page.text(nativeLeft+5, ly, 10, false, "#000000", l)
+		ly += 5
+	}
+	n.y += h + 5
+}
+
+// nativeIconColors are the stroke colours of the HTML item icons; the native
+// renderer draws each icon as a dot in that colour.
+var nativeIconColors = map[string][]string{
+	"solar_power":           {"#f59e0b"},
+	"battery_power":         {"#a855f7"},
+	"normal_power":          {"#3b82f6"},
+	"car_charging_normal":   {"#10b981", "#f59e0b"},
+	"car_charging_battery":  {"#10b981", "#a855f7"},
+	"car_charging_priority": {"#10b981", "#3b82f6"},
+	"vzev_self_solar":       {"#f59e0b"},
+	"vzev_virtual_pv":       {"#8b5cf6"},
+}
+
+// item draws one invoice line following the item rows of the built-in
+// invoice template.
+func (n *nativeInvoice) item(item map[string]interface{}, currency string) {
+	description := fmt.Sprintf("%v", item["description"])
+	itemType := fmt.Sprintf("%v", item["item_type"])
+	totalPrice, _ := item["total_price"].(float64)
+	amount := fmt.Sprintf("%s %.2f", currency, totalPrice)
+
+	header := func() {
+		n.need(8)
+		n.page.rect(nativeLeft, n.y, nativeWidth, 8, "#f9f9f9")
+		n.page.rect(nativeLeft, n.y+8, nativeWidth, 0.5, "#dddddd")
+		n.page.text(nativeLeft+2.5, n.y+5.3, 9, true, "#000000", fitText(description, 9, true, nativeWidth-5))
+		n.y += 8.5
+	}
+	compact := func() {
+		lines := wrapText(description, 8, false, nativeWidth-5)
+		h := 3 + 4*float64(len(lines))
+		n.need(h)
+		ly := n.y + 4.5
+		for _, l := range lines {
+			n.page.text(nativeLeft+2.5, ly, 8, false, "#666666", l)
+			ly += 4
+		}
+		n.y += h
+		n.page.rect(nativeLeft, n.y, nativeWidth, 0.25, "#eeeeee")
+		n.y += 0.25
+	}
+	// cost draws a priced row with an optional tint, icon dots and text colour.
+	cost := func(tint, color string, indent float64) {
+		n.need(8)
+		if tint != "" {
+			n.page.rect(nativeLeft, n.y, nativeWidth, 8, tint)
+		}
+		x := nativeLeft + indent
+		for _, c := range nativeIconColors[itemType] {
+			n.page.circle(x+1.5, n.y+4, 1.5, c)
+			x += 4
+		}
+		if len(nativeIconColors[itemType]) > 0 {
+			x += 1
+		}
+		amountWidth := textWidthMM(amount, 9, true)
+		n.page.text(x, n.y+5.3, 9, true, color, fitText(description, 9, true, nativeRight-2.5-amountWidth-4-x))
+		n.page.textRight(nativeRight-2.5, n.y+5.3, 9, true, color, amount)
+		n.y += 8
+		n.page.rect(nativeLeft, n.y, nativeWidth, 0.25, "#eeeeee")
+		n.y += 0.25
+	}
+	// notice draws a full-width coloured box, optionally with a left bar.
+	notice := func(bg, color, bar string, size float64, bold, centered bool) {
+		lines := wrapText(description, size, bold, nativeWidth-12)
+		h := 5 + size*0.45*float64(len(lines))
+		n.need(h + 2)
+		n.page.rect(nativeLeft, n.y, nativeWidth, h, bg)
+		if bar != "" {
+			n.page.rect(nativeLeft, n.y, 1.4, h, bar)
+		}
+		ly := n.y + 2.5 + size*0.35
+		for _, l := range lines {
+			if centered {
+				n.page.textCenter(nativeLeft+nativeWidth/2, ly, size, bold, color, l)
+			} else {
+				n.page.text(nativeLeft+5.5, ly, size, bold, color, l)
+			}
+			ly += size * 0.45
+		}
+		n.y += h + 2
+	}
+
+	switch itemType {
+	case "meter_info", "settlement_balance":
+		header()
+	case "charging_header", "custom_item_header", "utility_header":
+		n.y += 4
+		header()
+	case "meter_reading_compact", "charging_session_compact":
+		compact()
+	case "separator":
+		n.y += 4
+	case "solar_power", "battery_power":
+		cost("#fefaeb", "#000000", 7)
+	case "normal_power":
+		cost("#f0f6fe", "#000000", 7)
+	case "car_charging_normal", "car_charging_battery", "car_charging_priority":
+		cost("#edfcf4", "#000000", 7)
+	case "utility_charge", "utility_base_fee":
+		cost("", "#000000", 7)
+	case "custom_item":
+		cost("", "#000000", 2.5)
+	case "advance_deduction":
+		cost("", "#000000", 2.5)
+	case "vzev_self_solar":
+		cost("#fef3c7", "#92400e", 8.5)
+	case "vzev_virtual_pv":
+		cost("#ede9fe", "#5b21b6", 8.5)
+	case "vzev_notice":
+		notice("#667eea", "#ffffff", "", 9, true, true)
+	case "proration_notice":
+		notice("#fff3cd", "#856404", "#ffc107", 10, false, false)
+	case "charging_warning":
+		notice("#fde8e8", "#9b1c1c", "#e02424", 10, false, false)
+	case "vzev_breakdown_header":
+		n.y += 4
+		notice("#f3f4f6", "#4338ca", "#8b5cf6", 11, true, false)
+	default:
+		if totalPrice > 0 {
+			cost("", "#000000", 2.5)
+		} else {
+			compact()
+		}
+	}
+}
+
+// drawNativeQRBill draws the Swiss QR-bill payment slip (receipt and payment
+// part) at the bottom of its own A4 page, with the QR code rendered in-process.
+func drawNativeQRBill(p *pdfPage, tr InvoiceTranslations, inv map[string]interface{}, sender SenderInfo, banking BankingInfo, qrData, currency string, amount float64) error {
+	const slip = pdfPageHeightMM - 105
+
+	userName, userStreet, userLocation := "", "", ""
+	if user, ok := inv["user"].(map[string]interface{}); ok {
+		userName = fmt.Sprintf("%v %v", user["first_name"], user["last_name"])
+		userStreet = fmt.Sprintf("%v", user["address_street"])
+		userLocation = fmt.Sprintf("%v %v", user["address_zip"], user["address_city"])
+	}
+	creditor := []string{banking.IBAN, banking.AccountHolder, sender.Address, fmt.Sprintf("%s %s", sender.Zip, sender.City)}
+	debtor := []string{userName, userStreet, userLocation}
+	invoiceNumber := fmt.Sprintf("%v", inv["invoice_number"])
+	amountText := fmt.Sprintf("%.2f", amount)
+
+	p.line(0, slip, pdfPageWidthMM, slip, 0.2, "#000000", true)
+	p.line(62, slip, 62, pdfPageHeightMM, 0.2, "#000000", true)
+
+	// block draws a heading with its value lines and returns the next y.
+	block := func(x, y, width, headSize, valueSize float64, heading string, values []string) float64 {
+		p.text(x, y, headSize, true, "#000000", heading)
+		y += headSize*0.35 + 1.5
+		for _, v := range values {
+			p.text(x, y, valueSize, false, "#000000", fitText(v, valueSize, false, width))
+			y += valueSize * 0.45
+		}
+		return y + 2.5
+	}
+
+	// Receipt.
+	p.text(5, slip+9, 11, true, "#000000", tr.ReceiptSection)
+	y := block(5, slip+16, 52, 6, 8, tr.AccountPayableTo, creditor)
+	block(5, y, 52, 6, 8, tr.PayableBy, debtor)
+	p.text(5, slip+72, 6, true, "#000000", tr.Currency)
+	p.text(17, slip+72, 6, true, "#000000", tr.AmountLabel)
+	p.text(5, slip+76, 8, false, "#000000", currency)
+	p.text(17, slip+76, 8, false, "#000000", amountText)
+	p.textRight(57, slip+85, 6, true, "#000000", tr.AcceptancePoint)
+
+	// Payment part.
+	p.text(67, slip+9, 11, true, "#000000", tr.PaymentPart)
+	if err := drawNativeQRCode(p, 67, slip+17, 46, qrData); err != nil {
+		return err
+	}
+	y = block(118, slip+9, 87, 8, 10, tr.AccountPayableTo, creditor)
+	y = block(118, y, 87, 8, 10, tr.AdditionalInfo, []string{fmt.Sprintf("%s %s", tr.InvoiceLabel, invoiceNumber)})
+	block(118, y, 87, 8, 10, tr.PayableBy, debtor)
+	p.text(67, slip+72, 8, true, "#000000", tr.Currency)
+	p.text(81, slip+72, 8, true, "#000000", tr.AmountLabel)
+	p.text(67, slip+77, 10, false, "#000000", currency)
+	p.text(81, slip+77, 10, false, "#000000", amountText)
+	return nil
+}
+
+// drawNativeQRCode draws the QR code with the Swiss cross in its centre, or
+// the same notice as the HTML when there is no valid payload.
+func drawNativeQRCode(p *pdfPage, x, y, size float64, data string) error {
+	if data == "" {
+		p.textCenter(x+size/2, y+size/2, 9, false, "#dc3545", "QR Code could not be generated")
+		return nil
+	}
+	qr, err := encodeQR([]byte(data))
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	m := size / float64(qr.size)
+	for row := 0; row < qr.size; row++ {
+		// Runs of dark modules become one rectangle each.
+		for col := 0; col < qr.size; {
+			if !qr.modules[row][col] {
+				col++
+				continue
+			}
+			start := col
+			for col < qr.size && qr.modules[row][col] {
+				col++
+			}
+			p.rect(x+float64(start)*m, y+float64(row)*m, float64(col-start)*m, m, "#000000")
+		}
+	}
+
+	// Swiss cross: 7mm black square with a white cross, on a white border.
+	cx, cy := x+size/2, y+size/2
+	p.rect(cx-4, cy-4, 8, 8, "#ffffff")
+	p.rect(cx-3.5, cy-3.5, 7, 7, "#000000")
+	arm, width := 7.0*20/32, 7.0*6/32
+	p.rect(cx-width/2, cy-arm/2, width, arm, "#ffffff")
+	p.rect(cx-arm/2, cy-width/2, arm, width, "#ffffff")
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Right edges of the amount columns of a native account statement (mm).
+const (
+	statementDebitRight   = 150.0
+	statementCreditRight  = 172.0
+	statementBalanceRight = nativeRight - 2.5
+)
+
+// renderNativeStatementsPDF draws the same statements as
+// generateStatementsHTML, one after the other, each on a new page.
+func (pg *PDFGenerator) renderNativeStatementsPDF(statements []models.AccountStatement) ([]byte, error) {
+	created, err := time.Parse("2006-01-02", statements[0].To)
+	if err != nil {
+		created = time.Now()
+	}
+	doc := newPDFDoc(GetTranslations(statements[0].Language).AccountStatement, created)
+	for _, st := range statements {
+		pg.drawNativeStatement(&nativeInvoice{doc: doc, page: doc.addPage(), y: nativeTop}, st)
+	}
+	return doc.bytes()
+}
+
+func (pg *PDFGenerator) drawNativeStatement(n *nativeInvoice, st models.AccountStatement) {
+	tr := GetTranslations(st.Language)
+	sender, accent := pg.statementParties(st)
+
+	// Header: title on the left, sender on the right.
+	top := n.y
+	n.page.text(nativeLeft, top+8, 22, true, accent, fitText(tr.AccountStatement, 22, true, 110))
+	headerBottom := top + 14
+	if sender.Name != "" {
+		n.page.textRight(nativeRight, top+4, 10, true, "#000000", sender.Name)
+		sy := top + 9
+		for _, l := range []string{sender.Address, strings.TrimSpace(sender.Zip + " " + sender.City), sender.Country} {
+			n.page.textRight(nativeRight, sy, 9, false, "#000000", l)
+			sy += 4.5
+		}
+		if sy > headerBottom {
+			headerBottom = sy
+		}
+	}
+	n.y = headerBottom + 2
+	n.page.rect(nativeLeft, n.y, nativeWidth, 0.7, accent)
+	n.y += 8
+
+	// Tenant on the left, period and currency on the right.
+	col2 := nativeLeft + nativeWidth/2
+	n.page.text(nativeLeft, n.y, 9, true, "#000000", st.UserName)
+	left := n.y + 4.5
+	for _, l := range st.Address {
+		n.page.text(nativeLeft, left, 9, false, "#000000", fitText(l, 9, false, nativeWidth/2-4))
+		left += 4.5
+	}
+	right := n.y
+	for _, d := range [][2]string{{tr.Period, statementPeriod(st)}, {tr.Currency, st.Currency}} {
+		label := d[0] + ": "
+		n.page.text(col2, right, 9, true, "#000000", label)
+		n.page.text(col2+textWidthMM(label, 9, true), right, 9, false, "#000000", d[1])
+		right += 4.5
+	}
+	if right > left {
+		left = right
+	}
+	n.y = left + 4
+
+	// Lines table, framed by the opening and closing balance.
+	n.need(20)
+	n.page.rect(nativeLeft, n.y, nativeWidth, 8, "#f9f9f9")
+	n.page.rect(nativeLeft, n.y+8, nativeWidth, 0.5, "#dddddd")
+	n.page.text(nativeLeft+2.5, n.y+5.3, 8, true, "#000000", tr.Date)
+	n.page.text(nativeLeft+22, n.y+5.3, 8, true, "#000000", tr.Document)
+	n.page.text(nativeLeft+56, n.y+5.3, 8, true, "#000000", tr.Description)
+	n.page.textRight(statementDebitRight, n.y+5.3, 8, true, "#000000", tr.Debit)
+	n.page.textRight(statementCreditRight, n.y+5.3, 8, true, "#000000", tr.CreditColumn)
+	n.page.textRight(statementBalanceRight, n.y+5.3, 8, true, "#000000", tr.Balance)
+	n.y += 8.5
+
+	n.statementBalanceRow(tr.OpeningBalance, "", "", fmt.Sprintf("%.2f", st.OpeningBalance))
+	for _, l := range st.Lines {
+		n.need(6)
+		n.page.text(nativeLeft+2.5, n.y+4, 8, false, "#000000", formatDate(l.Date))
+		n.page.text(nativeLeft+22, n.y+4, 8, false, "#000000", fitText(l.Reference, 8, false, 32))
+		n.page.text(nativeLeft+56, n.y+4, 8, false, "#000000", fitText(l.Description, 8, false, statementDebitRight-nativeLeft-56-18))
+		n.page.textRight(statementDebitRight, n.y+4, 8, false, "#000000", statementAmount(l.Debit))
+		n.page.textRight(statementCreditRight, n.y+4, 8, false, "#000000", statementAmount(l.Credit))
+		n.page.textRight(statementBalanceRight, n.y+4, 8, false, "#000000", fmt.Sprintf("%.2f", l.Balance))
+		n.page.rect(nativeLeft, n.y+5.8, nativeWidth, 0.2, "#eeeeee")
+		n.y += 6
+	}
+	n.statementBalanceRow(tr.ClosingBalance, fmt.Sprintf("%.2f", st.TotalDebit), fmt.Sprintf("%.2f", st.TotalCredit),
+		fmt.Sprintf("%s %.2f", st.Currency, st.ClosingBalance))
+}
+
+// statementBalanceRow draws a shaded opening or closing balance row.
+func (n *nativeInvoice) statementBalanceRow(label, debit, credit, balance string) {
+	n.need(7)
+	n.page.rect(nativeLeft, n.y, nativeWidth, 6.5, "#f9f9f9")
+	n.page.text(nativeLeft+2.5, n.y+4.4, 8, true, "#000000", label)
+	n.page.textRight(statementDebitRight, n.y+4.4, 8, true, "#000000", debit)
+	n.page.textRight(statementCreditRight, n.y+4.4, 8, true, "#000000", credit)
+	n.page.textRight(statementBalanceRight, n.y+4.4, 8, true, "#000000", balance)
+	n.y += 7
+}
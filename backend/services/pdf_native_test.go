@@ -0,0 +1,48 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestNativePDFRenderer(t *testing.T) {
+	inv := models.Invoice{
+		InvoiceNumber: "INV-2026-1-10-1", PeriodStart: "2026-01-01", PeriodEnd: "2026-01-31",
+		TotalAmount: 100, Currency: "CHF", DocumentType: "invoice", Status: "issued",
+		GeneratedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), DueDate: "2026-03-03",
+		User: &models.User{FirstName: "Anna", LastName: "Muster", AddressStreet: "Dorfstrasse 1", AddressZip: "8000", AddressCity: "Zürich", IsActive: true},
+		Items: []models.InvoiceItem{
+			{Description: "Meter reading", ItemType: "meter_info"},
+			{Description: "Solar power", TotalPrice: 40, ItemType: "solar_power"},
+			{Description: "Grid power", TotalPrice: 60, ItemType: "normal_power"},
+		},
+	}
+	sender := SenderInfo{Name: "ZEV Sonnenhof", Address: "Hauptstrasse 5", City: "Zürich", Zip: "8001", Country: "CH"}
+	banking := BankingInfo{Name: "Bank", IBAN: "CH93 0076 2011 6238 5295 7", AccountHolder: "ZEV Sonnenhof"}
+
+	pg := NewPDFGenerator(nil)
+	pg.SetRenderer(PDFRendererNative)
+	first, err := pg.renderNativeInvoicePDF(InvoiceToMap(inv), sender, banking)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	second, _ := pg.renderNativeInvoicePDF(InvoiceToMap(inv), sender, banking)
+	if !bytes.Equal(first, second) {
+		t.Error("native PDF differs between runs")
+	}
+	if !bytes.HasPrefix(first, []byte("%PDF-1.4")) || !bytes.Contains(first, []byte("/Count 2")) {
+		t.Error("expected a two-page PDF (invoice and QR-bill)")
+	}
+	if _, err := EmbedFacturX(first, []byte("<x/>"), inv.GeneratedAt); err != nil {
+		t.Errorf("native PDF cannot carry an e-invoice: %v", err)
+	}
+
+	qr, err := encodeQR([]byte(strings.Repeat("x", 300)))
+	if err != nil || qr.size != 13*4+17 {
+		t.Errorf("300 bytes should need version 13 at level M, got %v %v", qr, err)
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFormatPDFA3B is recorded in invoice_archive.format for documents
+// archived as converted and validated PDF/A-3B.
+const ArchiveFormatPDFA3B = "pdfa-3b"
+
+// pdfaOutputCondition names the output intent of converted documents.
+const pdfaOutputCondition = "sRGB IEC61966-2.1"
+
+// ConvertToPDFA converts a PDF to PDF/A-3B with Ghostscript, which embeds and
+// subsets every font and attaches an sRGB output intent. Ghostscript is told
+// to abort rather than emit a file that does not conform, and when veraPDF is
+// installed the result is validated with it as well. A Factur-X invoice whose
+// attachment or metadata would not survive the conversion is refused, since
+// the structured invoice matters more than the archive format.
+func ConvertToPDFA(pdf []byte) ([]byte, error) {
+	gs, err := exec.LookPath("gs")
+	if err != nil {
+		return nil, fmt.Errorf("Ghostscript (gs) is not installed")
+	}
+
+	dir, err := os.MkdirTemp("", "zev-pdfa-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in, out := filepath.Join(dir, "in.pdf"), filepath.Join(dir, "out.pdf")
+	icc, def := filepath.Join(dir, "srgb.icc"), filepath.Join(dir, "PDFA_def.ps")
+	if err := os.WriteFile(in, pdf, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(icc, srgbICCProfile(), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(def, []byte(pdfaDefinition(icc)), 0600); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(gs,
+		"-dPDFA=3",
+		"-dPDFACompatibilityPolicy=2", // abort instead of writing a non-conforming file
+		"-dBATCH", "-dNOPAUSE", "-dQUIET",
+		"-sDEVICE=pdfwrite",
+		"-sColorConversionStrategy=RGB",
+		"--permit-file-read="+dir+string(filepath.Separator),
+		"-sOutputFile="+out,
+		def, in,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("gs failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	result, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("gs wrote no output: %v", err)
+	}
+
+	if !bytes.Contains(result, []byte("pdfaid:part")) {
+		return nil, fmt.Errorf("gs output carries no PDF/A identification")
+	}
+	if bytes.Contains(pdf, []byte(EInvoiceFileName)) {
+		for _, keep := range []string{EInvoiceFileName, "urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#"} {
+			if !bytes.Contains(result, []byte(keep)) {
+				return nil, fmt.Errorf("conversion would drop the Factur-X data (%s)", keep)
+			}
+		}
+	}
+
+	if verapdf, err := exec.LookPath("verapdf"); err == nil {
+		output, _ := exec.Command(verapdf, "--flavour", "3b", "--format", "text", out).CombinedOutput()
+		if !strings.HasPrefix(strings.TrimSpace(string(output)), "PASS") {
+			return nil, fmt.Errorf("veraPDF rejected the PDF/A-3B copy: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return result, nil
+}
+
+// pdfaDefinition is the PostScript prologue Ghostscript needs for PDF/A: the
+// output intent with the ICC profile at iccPath.
+func pdfaDefinition(iccPath string) string {
+	return `%!
+[/_objdef {icc_PDFA} /type /stream /OBJ pdfmark
+[{icc_PDFA} << /N 3 >> /PUT pdfmark
+[{icc_PDFA} (` + pdfEscape(filepath.ToSlash(iccPath)) + `) (r) file /PUT pdfmark
+[/_objdef {OutputIntent_PDFA} /type /dict /OBJ pdfmark
+[{OutputIntent_PDFA} <<
+  /Type /OutputIntent
+  /S /GTS_PDFA1
+  /DestOutputProfile {icc_PDFA}
+  /OutputConditionIdentifier (` + pdfaOutputCondition + `)
+  /Info (` + pdfaOutputCondition + `)
+>> /PUT pdfmark
+[{Catalog} << /OutputIntents [ {OutputIntent_PDFA} ] >> /PUT pdfmark
+`
+}
+
+// srgbICCProfile builds a version 2 display profile for sRGB: the D50-adapted
+// primaries and the sRGB transfer curve sampled at 256 points.
+func srgbICCProfile() []byte {
+	s15 := func(v float64) []byte {
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+	}
+	xyz := func(x, y, z float64) []byte {
+		b := append([]byte("XYZ \x00\x00\x00\x00"), s15(x)...)
+		return append(append(b, s15(y)...), s15(z)...)
+	}
+
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = binary.BigEndian.AppendUint32(desc, uint32(len(pdfaOutputCondition)+1))
+	desc = append(append(desc, pdfaOutputCondition...), 0)
+	desc = append(desc, make([]byte, 4+4+2+1+67)...) // empty Unicode and ScriptCode descriptions
+
+	curve := binary.BigEndian.AppendUint32([]byte("curv\x00\x00\x00\x00"), 256)
+	for i := 0; i < 256; i++ {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*65535)))
+	}
+
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	tags := []tag{
+		{"desc", desc},
+		{"cprt", append([]byte("text\x00\x00\x00\x00No copyright, use freely"), 0)},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", nil}, // the three channels share one curve
+		{"bTRC", nil},
+	}
+
+	var table, data bytes.Buffer
+	base := 128 + 4 + 12*len(tags)
+	curveOffset := 0
+	for _, t := range tags {
+		offset, size := base+data.Len(), len(t.data)
+		switch {
+		case t.data == nil:
+			offset, size = curveOffset, len(curve)
+		case t.sig == "rTRC":
+			curveOffset = offset
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, [2]uint32{uint32(offset), uint32(size)})
+		data.Write(t.data)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(base+data.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{2026, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], xyz(0.9642, 1.0, 0.8249)[8:])
+
+	var out bytes.Buffer
+	out.Write(header)
+	binary.Write(&out, binary.BigEndian, uint32(len(tags)))
+	out.Write(table.Bytes())
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
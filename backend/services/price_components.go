@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Price component types. They only label the component for reporting; every
+// type is priced the same way (per kWh of grid power).
+const (
+	PriceComponentEnergy       = "energy"
+	PriceComponentGridUsage    = "grid_usage"    // Netznutzung
+	PriceComponentFederalLevy  = "federal_levy"  // Netzzuschlag
+	PriceComponentMunicipalFee = "municipal_fee" // Abgaben an das Gemeinwesen
+	PriceComponentMetering     = "metering"
+	PriceComponentOther        = "other"
+)
+
+// ValidPriceComponentType reports whether t is a known component type.
+func ValidPriceComponentType(t string) bool {
+	switch t {
+	case PriceComponentEnergy, PriceComponentGridUsage, PriceComponentFederalLevy,
+		PriceComponentMunicipalFee, PriceComponentMetering, PriceComponentOther:
+		return true
+	}
+	return false
+}
+
+// gridComponents is the grid tariff of one price segment split into
+// components. Each share is the fraction of the segment's days the component
+// was valid on; uncovered is the fraction of days no component covered, which
+// stays priced at the segment's NormalPowerPrice.
+type gridComponents struct {
+	shares    []componentShare
+	uncovered float64
+}
+
+type componentShare struct {
+	component models.PriceComponent
+	fraction  float64
+}
+
+// priceComponentsFor loads the active components of a building that are valid
+// on at least one day of [start, end). Grid kWh are split across validity
+// changes by days, the same way shared meters are pro-rated. It returns nil
+// when no component applies, so the segment keeps its single grid price.
+func (bs *BillingService) priceComponentsFor(buildingID int, start, end time.Time) (*gridComponents, error) {
+	rows, err := bs.db.Query(`
+		SELECT id, building_id, name, component_type, price, vat_exempt,
+		       valid_from, valid_to, sort_order, is_active
+		FROM price_components
+		WHERE building_id = ? AND is_active = 1
+		  AND valid_from < ? AND (valid_to = '' OR valid_to >= ?)
+		ORDER BY sort_order ASC, id ASC
+	`, buildingID, end.Format("2006-01-02"), start.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price_components: %v", err)
+	}
+	defer rows.Close()
+
+	var components []models.PriceComponent
+	for rows.Next() {
+		var c models.PriceComponent
+		if err := rows.Scan(&c.ID, &c.BuildingID, &c.Name, &c.ComponentType, &c.Price, &c.VATExempt,
+			&c.ValidFrom, &c.ValidTo, &c.SortOrder, &c.IsActive); err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, nil
+	}
+
+	days := make([]int, len(components))
+	totalDays, uncoveredDays := 0, 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		totalDays++
+		covered := false
+		for i, c := range components {
+			if c.ValidFrom <= day && (c.ValidTo == "" || day <= c.ValidTo) {
+				days[i]++
+				covered = true
+			}
+		}
+		if !covered {
+			uncoveredDays++
+		}
+	}
+	if totalDays == 0 {
+		return nil, nil
+	}
+
+	gc := &gridComponents{uncovered: float64(uncoveredDays) / float64(totalDays)}
+	for i, c := range components {
+		if days[i] > 0 {
+			gc.shares = append(gc.shares, componentShare{component: c, fraction: float64(days[i]) / float64(totalDays)})
+		}
+	}
+	return gc, nil
+}
+
+// appendPriceComponentItems emits one grid line per price component for kwh
+// of grid power, plus a line at NormalPowerPrice for the days no component
+// covered. Returns the cost added; VAT-exempt components get the exempt VAT
+// code.
+func appendPriceComponentItems(items *[]models.InvoiceItem, gc *gridComponents, kwh float64,
+	s models.BillingSettings, suffix, prefix string, tr InvoiceTranslations) (total float64) {
+	add := func(label string, kwh, price float64, vatExempt bool) {
+		if kwh <= 0 {
+			return
+		}
+		cost := kwh * price
+		total += cost
+		vatCode := ""
+		if vatExempt && s.VATRate > 0 {
+			vatCode = VATCodeExempt
+			label += " (" + tr.VATExempt + ")"
+		}
+		*items = append(*items, models.InvoiceItem{
+			Description: fmt.Sprintf("%s%s%s: %.3f kWh × %.3f %s/kWh", prefix, label, suffix, kwh, price, s.Currency),
+			Quantity:    kwh,
+			UnitPrice:   price,
+			TotalPrice:  cost,
+			ItemType:    "normal_power",
+			VATCode:     vatCode,
+		})
+		log.Printf("  %s%s: %.3f kWh × %.3f = %.3f %s", label, suffix, kwh, price, cost, s.Currency)
+	}
+	for _, sh := range gc.shares {
+		add(fmt.Sprintf("%s – %s", tr.NormalPowerGrid, sh.component.Name), kwh*sh.fraction, sh.component.Price, sh.component.VATExempt)
+	}
+	add(tr.NormalPowerGrid, kwh*gc.uncovered, s.NormalPowerPrice, false)
+	return total
+}
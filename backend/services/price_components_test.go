@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestPriceComponents(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	for _, c := range []struct {
+		name, from, to string
+		price          float64
+		exempt         bool
+	}{
+		{"Energie", "2026-01-01", "", 0.10, false},
+		{"Netznutzung", "2026-01-01", "2026-01-15", 0.08, false},
+		{"Netznutzung", "2026-01-16", "", 0.06, false},
+		{"Netzzuschlag", "2026-01-01", "", 0.023, true},
+	} {
+		if _, err := db.Exec(`INSERT INTO price_components (building_id, name, price, vat_exempt, valid_from, valid_to) VALUES (1, ?, ?, ?, ?, ?)`,
+			c.name, c.price, c.exempt, c.from, c.to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs := NewBillingService(db)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	gc, err := bs.priceComponentsFor(1, start, start.AddDate(0, 1, 0))
+	if err != nil || gc == nil {
+		t.Fatalf("priceComponentsFor: %v %v", gc, err)
+	}
+	if len(gc.shares) != 4 || gc.uncovered != 0 || !almostEqual(gc.shares[1].fraction, 15.0/31) {
+		t.Fatalf("unexpected shares: %+v", gc)
+	}
+
+	var items []models.InvoiceItem
+	s := models.BillingSettings{VATRate: 8.1, Currency: "CHF", NormalPowerPrice: 0.30}
+	total := appendPriceComponentItems(&items, gc, 310, s, "", "", GetTranslations("en"))
+	// 310 kWh: energy 31.00, grid usage 150×0.08 + 160×0.06 = 21.60, levy 7.13
+	if len(items) != 4 || !almostEqual(total, 59.73) || items[3].VATCode != VATCodeExempt {
+		t.Fatalf("total %.3f items %+v", total, items)
+	}
+	bs.assignItemVAT(items, s)
+	net, vat, gross := vatBreakdownItems(total, items, s)
+	if !almostEqual(net, 59.73) || !almostEqual(vat, 52.6*0.081) || !almostEqual(gross, 59.73+52.6*0.081) {
+		t.Errorf("net %.3f vat %.3f gross %.3f", net, vat, gross)
+	}
+
+	// Before the first component the segment keeps its single grid price.
+	if gc, _ := bs.priceComponentsFor(1, start.AddDate(0, -1, 0), start); gc != nil {
+		t.Error("components applied before their validity")
+	}
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// LoadProducerAccount loads a producer account with its solar meters.
+func LoadProducerAccount(db *sql.DB, accountID int) (*models.ProducerAccount, error) {
+	var a models.ProducerAccount
+	var selfPrice, exportPrice sql.NullFloat64
+	err := db.QueryRow(`
+		SELECT id, user_id, building_id, name, self_consumption_price, export_price, vat_rate,
+		       is_active, notes, created_at, updated_at
+		FROM producer_accounts WHERE id = ?
+	`, accountID).Scan(&a.ID, &a.UserID, &a.BuildingID, &a.Name, &selfPrice, &exportPrice, &a.VATRate,
+		&a.IsActive, &a.Notes, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if selfPrice.Valid {
+		a.SelfConsumptionPrice = &selfPrice.Float64
+	}
+	if exportPrice.Valid {
+		a.ExportPrice = &exportPrice.Float64
+	}
+
+	rows, err := db.Query(`
+		SELECT pm.id, pm.producer_account_id, pm.meter_id, COALESCE(m.name, ''), pm.share_percent,
+		       pm.valid_from, pm.valid_to, pm.created_at
+		FROM producer_meters pm
+		LEFT JOIN meters m ON m.id = pm.meter_id
+		WHERE pm.producer_account_id = ?
+		ORDER BY pm.valid_from, pm.id
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pm models.ProducerMeter
+		if err := rows.Scan(&pm.ID, &pm.ProducerAccountID, &pm.MeterID, &pm.MeterName, &pm.SharePercent,
+			&pm.ValidFrom, &pm.ValidTo, &pm.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Meters = append(a.Meters, pm)
+	}
+	return &a, rows.Err()
+}
+
+// producerEnergy splits the production of a producer account's meters over
+// [start, end) into the part the building used and the part exported. Per
+// interval the building uses min(production, consumption + battery charge) of
+// all its solar; each meter gets its production-weighted share of that, times
+// the account's ownership share.
+func (bs *BillingService) producerEnergy(a *models.ProducerAccount, start, end time.Time) (produced, selfConsumed, exported float64, err error) {
+	meters := make(map[int][]models.ProducerMeter)
+	ids := []int{}
+	for _, pm := range a.Meters {
+		if _, seen := meters[pm.MeterID]; !seen {
+			ids = append(ids, pm.MeterID)
+		}
+		meters[pm.MeterID] = append(meters[pm.MeterID], pm)
+	}
+	if len(ids) == 0 {
+		return 0, 0, 0, nil
+	}
+
+	mainRegister := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		mainRegister[id] = bs.solarUsesMainRegister(id)
+	}
+	args := make([]interface{}, 0, len(ids)+2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, start, end)
+	rows, err := bs.db.Query(`
+		SELECT meter_id, reading_time, consumption_kwh, consumption_export
+		FROM meter_readings
+		WHERE meter_id IN (`+buildPlaceholders(len(ids))+`)
+		AND reading_time >= ? AND reading_time < ?
+	`, args...)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to query solar readings: %v", err)
+	}
+	owned := make(map[time.Time]float64) // the account's share of production per interval
+	for rows.Next() {
+		var meterID int
+		var ts time.Time
+		var kwh, export float64
+		if err := rows.Scan(&meterID, &ts, &kwh, &export); err != nil {
+			rows.Close()
+			return 0, 0, 0, err
+		}
+		if !mainRegister[meterID] {
+			kwh = export
+		}
+		day := ts.Format("2006-01-02")
+		for _, pm := range meters[meterID] {
+			if day >= pm.ValidFrom && (pm.ValidTo == "" || day <= pm.ValidTo) {
+				owned[floorTo15min(ts)] += kwh * pm.SharePercent / 100
+				break
+			}
+		}
+	}
+	rows.Close()
+
+	agg := bs.buildingIntervalAggregates(a.BuildingID, start, end)
+	for ts, kwh := range owned {
+		produced += kwh
+		used := 0.0
+		if b := agg[ts]; b != nil && b.SolarProduction > 0 {
+			used = math.Min(b.SolarProduction, b.TotalConsumption+b.BatteryCharge) / b.SolarProduction
+		}
+		selfConsumed += kwh * used
+		exported += kwh * (1 - used)
+	}
+	return produced, selfConsumed, exported, nil
+}
+
+// GenerateProducerStatements issues a credit statement (Gutschrift) to every
+// active producer account of the given buildings (or the given accounts) for
+// [startDate, endDate]: the solar the building's tenants used at the account's
+// self-consumption price and the exported kWh at its export price, each
+// falling back to the building's solar and vZEV export price per price
+// segment. Statements are producer credit documents (negative amounts); an
+// account that already has an issued statement for the same period start is
+// skipped. The payout is booked with RecordProducerPayout.
+func (bs *BillingService) GenerateProducerStatements(buildingIDs, accountIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	start, end, err := parseBillingPeriod(startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `SELECT id FROM producer_accounts WHERE is_active = 1`
+	args := []interface{}{}
+	if len(buildingIDs) > 0 {
+		query += ` AND building_id IN (` + buildPlaceholders(len(buildingIDs)) + `)`
+		for _, id := range buildingIDs {
+			args = append(args, id)
+		}
+	}
+	if len(accountIDs) > 0 {
+		query += ` AND id IN (` + buildPlaceholders(len(accountIDs)) + `)`
+		for _, id := range accountIDs {
+			args = append(args, id)
+		}
+	}
+	rows, err := bs.db.Query(query+` ORDER BY building_id, id`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load producer accounts: %v", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	log.Printf("=== PRODUCER STATEMENTS: %d account(s), period %s to %s ===", len(ids), startDate, endDate)
+
+	invoices := []models.Invoice{}
+	skipped := []SkippedBill{}
+	for _, id := range ids {
+		account, err := LoadProducerAccount(bs.db, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		user := loadInvoiceUser(bs.db, account.UserID)
+		if user == nil {
+			log.Printf("WARNING: Producer account %d references missing user %d", account.ID, account.UserID)
+			continue
+		}
+		invoice, err := bs.generateProducerStatement(account, user, start, end)
+		if err != nil {
+			log.Printf("ERROR: Producer statement for account %d: %v", account.ID, err)
+			skipped = append(skipped, SkippedBill{
+				UserID: account.UserID, UserName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+				BuildingID: account.BuildingID, Reason: err.Error(),
+			})
+			continue
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, skipped, nil
+}
+
+// PreviewProducerStatements is GenerateProducerStatements without writing
+// anything.
+func (bs *BillingService) PreviewProducerStatements(buildingIDs, accountIDs []int, startDate, endDate string) ([]models.Invoice, []SkippedBill, error) {
+	dry := &BillingService{db: bs.db, dryRun: true}
+	return dry.GenerateProducerStatements(buildingIDs, accountIDs, startDate, endDate)
+}
+
+func (bs *BillingService) generateProducerStatement(a *models.ProducerAccount, user *models.User, start, end time.Time) (*models.Invoice, error) {
+	periodStart, periodEnd := start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")
+	var existing string
+	if err := bs.db.QueryRow(`
+		SELECT invoice_number FROM invoices
+		WHERE producer_account_id = ? AND document_type = ? AND status = ? AND date(period_start) = ?
+		LIMIT 1
+	`, a.ID, DocumentTypeProducerCredit, InvoiceStatusIssued, periodStart).Scan(&existing); err == nil {
+		return nil, fmt.Errorf("credit statement %s already issued for this period", existing)
+	}
+
+	segments, err := bs.loadPriceSegments(a.BuildingID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	primary := segments[0].Settings
+	multiSeg := len(segments) > 1
+	tr := GetTranslations(user.Language)
+
+	label := a.Name
+	if label == "" {
+		label = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	items := []models.InvoiceItem{{Description: fmt.Sprintf("☀️ %s", label), ItemType: "producer_notice"}}
+	for _, pm := range a.Meters {
+		desc := fmt.Sprintf("%s: %s", tr.ProductionMeter, pm.MeterName)
+		if pm.SharePercent < 100 {
+			desc += fmt.Sprintf(" (%s %.1f%%)", tr.OwnershipShare, pm.SharePercent)
+		}
+		items = append(items, models.InvoiceItem{Description: desc, ItemType: "meter_info"})
+	}
+	items = append(items, models.InvoiceItem{ItemType: "separator"})
+
+	net := 0.0
+	for _, seg := range segments {
+		suffix := segmentSuffix(seg, multiSeg)
+		s := seg.Settings
+		produced, selfConsumed, exported, err := bs.producerEnergy(a, seg.Start, seg.End)
+		if err != nil {
+			return nil, err
+		}
+		selfPrice, exportPrice := s.SolarPowerPrice, s.VZEVExportPrice
+		if a.SelfConsumptionPrice != nil {
+			selfPrice = *a.SelfConsumptionPrice
+		}
+		if a.ExportPrice != nil {
+			exportPrice = *a.ExportPrice
+		}
+
+		items = append(items, models.InvoiceItem{
+			Description: fmt.Sprintf("%s%s: %.3f kWh", tr.LocalProduction, suffix, produced),
+			Quantity:    produced,
+			ItemType:    "producer_production",
+		})
+		for _, line := range []struct {
+			label, prefix, itemType string
+			kwh, price              float64
+		}{
+			{tr.SolarSoldToTenants, "├─", "producer_self_consumption", selfConsumed, selfPrice},
+			{tr.SolarExported, "└─", "producer_export", exported, exportPrice},
+		} {
+			if line.kwh <= 0 {
+				continue
+			}
+			amount := line.kwh * line.price
+			net += amount
+			items = append(items, models.InvoiceItem{
+				Description: fmt.Sprintf("  %s %s%s: %.3f kWh × %.3f %s/kWh", line.prefix, line.label, suffix, line.kwh, line.price, s.Currency),
+				Quantity:    negateAmount(line.kwh),
+				UnitPrice:   line.price,
+				TotalPrice:  negateAmount(amount),
+				ItemType:    line.itemType,
+			})
+		}
+		log.Printf("  Producer %d%s: produced %.3f, self-consumed %.3f × %.3f, exported %.3f × %.3f",
+			a.ID, suffix, produced, selfConsumed, selfPrice, exported, exportPrice)
+	}
+
+	net = math.Round(net*100) / 100
+	if net <= zeroBillEpsilon {
+		return nil, fmt.Errorf("no remunerable production for this period")
+	}
+	vat := math.Round(net*a.VATRate) / 100
+	gross := net + vat
+
+	invoiceNumber := fmt.Sprintf("PC-%d-%d-%d-%s", start.Year(), a.BuildingID, a.ID, time.Now().Format("20060102150405"))
+	invoiceID, invoiceNumber, err := bs.insertDocumentWithItems(DocumentTypeProducerCredit, invoiceNumber, a.UserID, a.BuildingID,
+		periodStart, periodEnd, -gross, -net, negateAmount(vat), a.VATRate, false, primary.Currency, false, items)
+	if err != nil {
+		return nil, err
+	}
+	if invoiceID != 0 {
+		if _, err := bs.db.Exec(`UPDATE invoices SET producer_account_id = ? WHERE id = ?`, a.ID, invoiceID); err != nil {
+			return nil, fmt.Errorf("failed to link statement to producer account: %v", err)
+		}
+	}
+	log.Printf("  Producer statement %s: %s %.2f for account %d", invoiceNumber, primary.Currency, gross, a.ID)
+
+	return &models.Invoice{
+		ID:            int(invoiceID),
+		InvoiceNumber: invoiceNumber,
+		UserID:        a.UserID,
+		BuildingID:    a.BuildingID,
+		PeriodStart:   periodStart,
+		PeriodEnd:     periodEnd,
+		TotalAmount:   -gross,
+		NetAmount:     -net,
+		VATAmount:     negateAmount(vat),
+		VATRate:       a.VATRate,
+		Currency:      primary.Currency,
+		Status:        bs.storedStatus(),
+		DocumentType:  DocumentTypeProducerCredit,
+		Items:         items,
+		GeneratedAt:   time.Now(),
+	}, nil
+}
+
+// RecordProducerPayout books the payout of a producer credit statement (LEG
+// or producer account) in full on paidAt (YYYY-MM-DD), marking it paid.
+func RecordProducerPayout(db *sql.DB, invoiceID int, paidAt string) error {
+	var status, documentType, paymentStatus string
+	var total float64
+	err := db.QueryRow(`
+		SELECT status, COALESCE(document_type, 'invoice'), COALESCE(payment_status, 'unpaid'), total_amount
+		FROM invoices WHERE id = ?
+	`, invoiceID).Scan(&status, &documentType, &paymentStatus, &total)
+	if err != nil {
+		return err
+	}
+	if documentType != DocumentTypeProducerCredit || status != InvoiceStatusIssued {
+		return fmt.Errorf("document %d is not an issued producer credit statement", invoiceID)
+	}
+	if paymentStatus == "paid" {
+		return fmt.Errorf("credit statement %d has already been paid out", invoiceID)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := recordPayment(tx, models.Payment{
+		InvoiceID:   invoiceID,
+		PaymentDate: paidAt,
+		Amount:      total,
+		Method:      PaymentMethodBankTransfer,
+		Source:      PaymentSourceManual,
+		Notes:       "Producer payout",
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProducerStatements(t *testing.T) {
+	db := newTestDB(t)
+	insertBuilding(t, db, 1, "A")
+	insertPricing(t, db, 1, "2026-01-01", "", 0.30)
+	if _, err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, building_id) VALUES
+		(10,'Anna','Muster','a@b.c',1), (12,'Paul','Solar','p@b.c',1)`); err != nil {
+		t.Fatal(err)
+	}
+	insertZEVMeter(t, db, 1, "Wohnung A", "apartment_meter", 1, 10)
+	insertZEVMeter(t, db, 2, "PV Dach", "solar_meter", 1, nil)
+	if _, err := db.Exec(`INSERT INTO producer_accounts (id, user_id, building_id, name, self_consumption_price, export_price, is_active)
+		VALUES (1, 12, 1, 'PV Paul', 0.20, 0.10, 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO producer_meters (producer_account_id, meter_id, share_percent, valid_from)
+		VALUES (1, 2, 100, '2026-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	insertZEVReading(t, db, 1, at, 2, 0)
+	insertZEVReading(t, db, 2, at, 0, 3)
+
+	bs := NewBillingService(db)
+	statements, skipped, err := bs.GenerateProducerStatements(nil, []int{1}, "2026-01-01", "2026-01-31")
+	if err != nil || len(statements) != 1 || len(skipped) != 0 {
+		t.Fatalf("producer statements: %v %v %v", statements, skipped, err)
+	}
+	// 2 kWh used by the tenant × 0.20 + 1 kWh exported × 0.10, no VAT.
+	s := statements[0]
+	if s.DocumentType != DocumentTypeProducerCredit || s.UserID != 12 || !almostEqual(s.TotalAmount, -0.50) {
+		t.Fatalf("unexpected statement: %+v", s)
+	}
+
+	if again, skipped, err := bs.GenerateProducerStatements(nil, []int{1}, "2026-01-01", "2026-01-31"); err != nil || len(again) != 0 || len(skipped) != 1 {
+		t.Errorf("duplicate statement: %v %v %v", again, skipped, err)
+	}
+
+	if err := RecordProducerPayout(db, s.ID, "2026-02-10"); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	db.QueryRow(`SELECT payment_status FROM invoices WHERE id = ?`, s.ID).Scan(&status)
+	if status != "paid" {
+		t.Errorf("payment status %q", status)
+	}
+	if err := RecordProducerPayout(db, s.ID, "2026-02-11"); err == nil {
+		t.Error("payout recorded twice")
+	}
+}
@@ -0,0 +1,529 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Grid pricing modes stored in billing_settings.grid_pricing_mode.
+const (
+	GridPricingFixed = "fixed"
+	GridPricingSpot  = "spot"
+)
+
+// spotInterval is the billing grid spot prices are stored on; hourly imports
+// are expanded onto it.
+const spotInterval = 15 * time.Minute
+
+// spotTimeLayout is how spot_prices.interval_start is stored (always UTC).
+const spotTimeLayout = "2006-01-02 15:04:05"
+
+// maxReportedMissing caps how many missing intervals are listed in errors and
+// coverage reports; the total count is always given.
+const maxReportedMissing = 8
+
+// SpotImportOptions describes an incoming price file.
+type SpotImportOptions struct {
+	Area string
+	// Unit is "kwh" (default) or "mwh". A CSV header or JSON "unit" mentioning
+	// MWh switches to MWh automatically (exchange prices are usually €/MWh).
+	Unit string
+	// ResolutionMinutes is 15 or 60; 0 detects it from the timestamp spacing.
+	// Hourly prices are expanded into four identical 15-minute prices.
+	ResolutionMinutes int
+	Source            string
+}
+
+type spotJSONRow struct {
+	Timestamp     string   `json:"timestamp"`
+	Start         string   `json:"start"`
+	IntervalStart string   `json:"interval_start"`
+	Price         *float64 `json:"price"`
+}
+
+type spotJSONDoc struct {
+	Unit              string        `json:"unit"`
+	ResolutionMinutes int           `json:"resolution_minutes"`
+	Prices            []spotJSONRow `json:"prices"`
+}
+
+type rawSpotPrice struct {
+	at    time.Time
+	price float64
+}
+
+// parseSpotTimestamp accepts RFC 3339 (with offset) or a local wall-clock time
+// in ISO or Swiss (dd.mm.yyyy) notation.
+func parseSpotTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{
+		"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04",
+		"02.01.2006 15:04:05", "02.01.2006 15:04",
+	} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// ParseSpotPrices reads a CSV (timestamp,price — comma or semicolon separated,
+// header optional) or JSON (array of {timestamp, price}, or an object with
+// "unit", "resolution_minutes" and "prices") price file. The result is sorted,
+// on the 15-minute grid and in price per kWh.
+func ParseSpotPrices(data []byte, opts SpotImportOptions) ([]models.SpotPrice, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(data) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(opts.Unit))
+	resolution := opts.ResolutionMinutes
+	var raw []rawSpotPrice
+	var err error
+	if data[0] == '[' || data[0] == '{' {
+		raw, err = parseSpotJSON(data, &unit, &resolution)
+	} else {
+		raw, err = parseSpotCSV(data, &unit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no price rows found")
+	}
+
+	sort.Slice(raw, func(i, j int) bool { return raw[i].at.Before(raw[j].at) })
+	if resolution == 0 {
+		resolution = 15
+		gap := time.Duration(0)
+		for i := 1; i < len(raw); i++ {
+			if d := raw[i].at.Sub(raw[i-1].at); d > 0 && (gap == 0 || d < gap) {
+				gap = d
+			}
+		}
+		if gap >= time.Hour {
+			resolution = 60
+		}
+	}
+	if resolution != 15 && resolution != 60 {
+		return nil, fmt.Errorf("unsupported resolution %d minutes (use 15 or 60)", resolution)
+	}
+	divisor := 1.0
+	switch unit {
+	case "", "kwh":
+	case "mwh":
+		divisor = 1000
+	default:
+		return nil, fmt.Errorf("unsupported unit %q (use kwh or mwh)", opts.Unit)
+	}
+
+	// Later rows for the same interval win, so a corrected file can simply be
+	// appended to or re-imported.
+	byInterval := make(map[int64]float64)
+	for _, r := range raw {
+		if r.at.Unix()%int64(resolution*60) != 0 {
+			return nil, fmt.Errorf("timestamp %s is not on a %d-minute boundary", r.at.Format(time.RFC3339), resolution)
+		}
+		for k := 0; k < resolution/15; k++ {
+			byInterval[r.at.Add(time.Duration(k)*spotInterval).Unix()] = r.price / divisor
+		}
+	}
+
+	out := make([]models.SpotPrice, 0, len(byInterval))
+	for ts, price := range byInterval {
+		out = append(out, models.SpotPrice{
+			PriceArea:     opts.Area,
+			IntervalStart: time.Unix(ts, 0).UTC(),
+			Price:         price,
+			Source:        opts.Source,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].IntervalStart.Before(out[j].IntervalStart) })
+	return out, nil
+}
+
+func parseSpotJSON(data []byte, unit *string, resolution *int) ([]rawSpotPrice, error) {
+	var rows []spotJSONRow
+	if data[0] == '{' {
+		var doc spotJSONDoc
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		if *unit == "" && strings.Contains(strings.ToLower(doc.Unit), "mwh") {
+			*unit = "mwh"
+		}
+		if *resolution == 0 {
+			*resolution = doc.ResolutionMinutes
+		}
+		rows = doc.Prices
+	} else if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	raw := make([]rawSpotPrice, 0, len(rows))
+	for i, row := range rows {
+		stamp := row.Timestamp
+		if stamp == "" {
+			stamp = row.Start
+		}
+		if stamp == "" {
+			stamp = row.IntervalStart
+		}
+		if row.Price == nil {
+			return nil, fmt.Errorf("entry %d: price is missing", i+1)
+		}
+		at, err := parseSpotTimestamp(stamp)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i+1, err)
+		}
+		raw = append(raw, rawSpotPrice{at: at, price: *row.Price})
+	}
+	return raw, nil
+}
+
+func parseSpotCSV(data []byte, unit *string) ([]rawSpotPrice, error) {
+	firstLine := string(data)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	semicolon := strings.Count(firstLine, ";") > strings.Count(firstLine, ",")
+	if semicolon {
+		reader.Comma = ';'
+	}
+
+	var raw []rawSpotPrice
+	line := 0
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(rec) < 2 || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		priceStr := strings.TrimSpace(rec[1])
+		if semicolon {
+			priceStr = strings.ReplaceAll(priceStr, ",", ".") // decimal comma
+		}
+		price, perr := strconv.ParseFloat(priceStr, 64)
+		if perr != nil {
+			if len(raw) == 0 {
+				// Header row: only its unit is of interest.
+				if *unit == "" && strings.Contains(strings.ToLower(strings.Join(rec, " ")), "mwh") {
+					*unit = "mwh"
+				}
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid price %q", line, rec[1])
+		}
+		at, err := parseSpotTimestamp(rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		raw = append(raw, rawSpotPrice{at: at, price: price})
+	}
+	return raw, nil
+}
+
+// StoreSpotPrices upserts prices for an area in one transaction and returns
+// the number of intervals written.
+func StoreSpotPrices(db *sql.DB, area string, prices []models.SpotPrice, source string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO spot_prices (price_area, interval_start, price, source) VALUES (?, ?, ?, ?)
+		ON CONFLICT(price_area, interval_start) DO UPDATE SET
+			price = excluded.price, source = excluded.source, created_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, p := range prices {
+		if _, err := stmt.Exec(area, p.IntervalStart.UTC().Format(spotTimeLayout), p.Price, source); err != nil {
+			return 0, fmt.Errorf("failed to store price for %s: %v", p.IntervalStart.Format(time.RFC3339), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(prices), nil
+}
+
+// LoadSpotPrices returns the prices of an area in [start, end), keyed by the
+// interval start as Unix seconds.
+func LoadSpotPrices(db *sql.DB, area string, start, end time.Time) (map[int64]float64, error) {
+	rows, err := db.Query(`
+		SELECT interval_start, price FROM spot_prices
+		WHERE price_area = ? AND interval_start >= ? AND interval_start < ?
+	`, area, start.UTC().Format(spotTimeLayout), end.UTC().Format(spotTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spot_prices: %v", err)
+	}
+	defer rows.Close()
+
+	prices := make(map[int64]float64)
+	for rows.Next() {
+		var stamp string
+		var price float64
+		if err := rows.Scan(&stamp, &price); err != nil {
+			return nil, err
+		}
+		t, err := time.ParseInLocation(spotTimeLayout, stamp, time.UTC)
+		if err != nil {
+			continue
+		}
+		prices[t.Unix()] = price
+	}
+	return prices, rows.Err()
+}
+
+// SpotCoverage reports which 15-minute intervals of a range have no price.
+type SpotCoverage struct {
+	Area         string   `json:"price_area"`
+	Expected     int      `json:"expected_intervals"`
+	Present      int      `json:"present_intervals"`
+	MissingCount int      `json:"missing_count"`
+	Missing      []string `json:"missing"` // first few missing interval starts (RFC 3339)
+}
+
+// SpotPriceCoverage checks an area's prices over [start, end).
+func SpotPriceCoverage(db *sql.DB, area string, start, end time.Time) (*SpotCoverage, error) {
+	prices, err := LoadSpotPrices(db, area, start, end)
+	if err != nil {
+		return nil, err
+	}
+	cov := &SpotCoverage{Area: area, Missing: []string{}}
+	for t := floorTo15min(start); t.Before(end); t = t.Add(spotInterval) {
+		cov.Expected++
+		if _, ok := prices[t.Unix()]; ok {
+			cov.Present++
+			continue
+		}
+		cov.MissingCount++
+		if len(cov.Missing) < maxReportedMissing {
+			cov.Missing = append(cov.Missing, t.Format(time.RFC3339))
+		}
+	}
+	return cov, nil
+}
+
+// spotPricer prices a tenant's grid share interval by interval and remembers
+// intervals that had consumption but no price.
+type spotPricer struct {
+	area    string
+	prices  map[int64]float64
+	markup  float64
+	kwh     float64
+	cost    float64
+	missing []time.Time
+}
+
+// spotPricerFor returns the pricer for a segment, or nil when the segment's
+// pricing row does not use spot prices.
+func (bs *BillingService) spotPricerFor(settings models.BillingSettings, start, end time.Time) (*spotPricer, error) {
+	if settings.GridPricingMode != GridPricingSpot {
+		return nil, nil
+	}
+	if settings.SpotPriceArea == "" {
+		return nil, fmt.Errorf("pricing row %d uses spot pricing but has no spot price area", settings.ID)
+	}
+	// Readings in [start, end] are priced by the interval they measured, which
+	// starts 15 minutes earlier, so the reading at start needs the price of the
+	// interval before the segment.
+	prices, err := LoadSpotPrices(bs.db, settings.SpotPriceArea, start.Add(-spotInterval), end)
+	if err != nil {
+		return nil, err
+	}
+	return &spotPricer{area: settings.SpotPriceArea, prices: prices, markup: settings.SpotPriceMarkup}, nil
+}
+
+// add prices kwh consumed in the interval starting at ts.
+func (sp *spotPricer) add(ts time.Time, kwh float64) {
+	price, ok := sp.prices[ts.Unix()]
+	if !ok {
+		sp.missing = append(sp.missing, ts)
+		return
+	}
+	sp.kwh += kwh
+	sp.cost += kwh * (price + sp.markup)
+}
+
+// averagePrice is the consumption-weighted average price per kWh.
+func (sp *spotPricer) averagePrice() float64 {
+	if sp.kwh <= 0 {
+		return 0
+	}
+	return sp.cost / sp.kwh
+}
+
+// missingError describes the intervals that carried grid consumption but have
+// no imported price; nil when every interval was priced.
+func (sp *spotPricer) missingError() error {
+	if len(sp.missing) == 0 {
+		return nil
+	}
+	sort.Slice(sp.missing, func(i, j int) bool { return sp.missing[i].Before(sp.missing[j]) })
+	var first []string
+	for i, t := range sp.missing {
+		if i == maxReportedMissing {
+			first = append(first, "…")
+			break
+		}
+		first = append(first, t.Local().Format("02.01.2006 15:04"))
+	}
+	return fmt.Errorf("no spot price for %d interval(s) with grid consumption in area %s (%s) — import the missing prices and bill again",
+		len(sp.missing), sp.area, strings.Join(first, ", "))
+}
+
+// appendSpotPriceItem emits the grid line of a spot-priced segment: the priced
+// kWh at the consumption-weighted average price. Returns the cost added.
+func appendSpotPriceItem(items *[]models.InvoiceItem, sp *spotPricer, s models.BillingSettings, suffix string, tr InvoiceTranslations) float64 {
+	if sp.kwh <= 0 {
+		return 0
+	}
+	avg := sp.averagePrice()
+	*items = append(*items, models.InvoiceItem{
+		Description: fmt.Sprintf("%s%s: %.3f kWh × Ø %.4f %s/kWh", tr.SpotPriceGrid, suffix, sp.kwh, avg, s.Currency),
+		Quantity:    sp.kwh,
+		UnitPrice:   avg,
+		TotalPrice:  sp.cost,
+		ItemType:    "normal_power",
+	})
+	log.Printf("  Spot Cost%s: %.3f kWh × Ø %.4f = %.3f %s (area %s, markup %.4f)",
+		suffix, sp.kwh, avg, sp.cost, s.Currency, sp.area, sp.markup)
+	return sp.cost
+}
+
+// SpotPriceWatcher imports price files dropped into a directory. A file's
+// price area is its name up to the first "_" or "." (e.g. CH_2026-10-16.csv
+// → area "CH"). Imported files move to imported/, rejected ones to failed/.
+type SpotPriceWatcher struct {
+	db       *sql.DB
+	dir      string
+	interval time.Duration
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func NewSpotPriceWatcher(db *sql.DB, dir string, pollMinutes int) *SpotPriceWatcher {
+	if pollMinutes < 1 {
+		pollMinutes = 15
+	}
+	return &SpotPriceWatcher{
+		db:       db,
+		dir:      dir,
+		interval: time.Duration(pollMinutes) * time.Minute,
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (sw *SpotPriceWatcher) Start() {
+	log.Printf("=== Spot Price Watcher starting (%s, every %s) ===", sw.dir, sw.interval)
+	sw.ScanOnce()
+	ticker := time.NewTicker(sw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sw.ScanOnce()
+		case <-sw.stopChan:
+			log.Println("Spot Price Watcher stopped")
+			return
+		}
+	}
+}
+
+func (sw *SpotPriceWatcher) Stop() {
+	sw.stopOnce.Do(func() { close(sw.stopChan) })
+}
+
+// spotAreaFromFilename derives the price area from a dropped file's name.
+func spotAreaFromFilename(name string) string {
+	base := filepath.Base(name)
+	if i := strings.IndexAny(base, "_."); i > 0 {
+		base = base[:i]
+	}
+	return strings.ToUpper(strings.TrimSpace(base))
+}
+
+// ScanOnce imports every .csv/.json file currently in the watch directory.
+func (sw *SpotPriceWatcher) ScanOnce() {
+	entries, err := os.ReadDir(sw.dir)
+	if err != nil {
+		log.Printf("Spot prices: cannot read %s: %v", sw.dir, err)
+		return
+	}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".csv" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(sw.dir, e.Name())
+		n, err := sw.importFile(path)
+		target := "imported"
+		if err != nil {
+			target = "failed"
+			log.Printf("Spot prices: import of %s failed: %v", e.Name(), err)
+			if _, derr := sw.db.Exec(
+				`INSERT INTO admin_logs (action, details, ip_address) VALUES ('Spot Price Import Failed', ?, 'system')`,
+				fmt.Sprintf("%s: %v", e.Name(), err),
+			); derr != nil {
+				log.Printf("Spot prices: could not record failure to admin_logs: %v", derr)
+			}
+		} else {
+			log.Printf("Spot prices: imported %d intervals from %s", n, e.Name())
+		}
+		if err := os.MkdirAll(filepath.Join(sw.dir, target), 0755); err == nil {
+			if err := os.Rename(path, filepath.Join(sw.dir, target, e.Name())); err != nil {
+				log.Printf("Spot prices: could not move %s to %s/: %v", e.Name(), target, err)
+			}
+		}
+	}
+}
+
+func (sw *SpotPriceWatcher) importFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	area := spotAreaFromFilename(path)
+	if area == "" {
+		return 0, fmt.Errorf("cannot derive a price area from the file name")
+	}
+	prices, err := ParseSpotPrices(data, SpotImportOptions{Area: area, Source: "file"})
+	if err != nil {
+		return 0, err
+	}
+	return StoreSpotPrices(sw.db, area, prices, "file")
+}
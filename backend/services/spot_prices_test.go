@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestParseSpotPricesHourlyMWh(t *testing.T) {
+	csvData := []byte("timestamp;price_eur_mwh\n2026-10-01T00:00:00Z;100,0\n2026-10-01T01:00:00Z;200,0\n")
+	prices, err := ParseSpotPrices(csvData, SpotImportOptions{Area: "CH"})
+	if err != nil {
+		t.Fatalf("ParseSpotPrices: %v", err)
+	}
+	if len(prices) != 8 {
+		t.Fatalf("got %d intervals, want 8 (two hours expanded to 15 min)", len(prices))
+	}
+	if !almostEqual(prices[3].Price, 0.1) || !almostEqual(prices[4].Price, 0.2) {
+		t.Errorf("prices = %.4f, %.4f; want 0.1, 0.2 per kWh", prices[3].Price, prices[4].Price)
+	}
+
+	if _, err := ParseSpotPrices([]byte(`[{"timestamp":"2026-10-01T00:07:00Z","price":0.1}]`), SpotImportOptions{}); err == nil {
+		t.Error("misaligned timestamp should be rejected")
+	}
+}
+
+func TestSpotPricerWeightedAverageAndMissing(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sp := &spotPricer{area: "CH", markup: 0.05, prices: map[int64]float64{
+		t0.Unix():                       0.10,
+		t0.Add(15 * time.Minute).Unix(): 0.30,
+	}}
+	sp.add(t0, 3)
+	sp.add(t0.Add(15*time.Minute), 1)
+	if err := sp.missingError(); err != nil {
+		t.Fatalf("unexpected missing error: %v", err)
+	}
+	// (3×0.15 + 1×0.35) / 4 = 0.20
+	if !almostEqual(sp.averagePrice(), 0.20) || !almostEqual(sp.cost, 0.80) {
+		t.Errorf("average %.4f cost %.4f; want 0.20, 0.80", sp.averagePrice(), sp.cost)
+	}
+
+	sp.add(t0.Add(30*time.Minute), 2)
+	if sp.missingError() == nil {
+		t.Error("interval without a price must be reported, not priced at zero")
+	}
+	if !almostEqual(sp.kwh, 4) {
+		t.Errorf("unpriced kWh must not be billed: kwh = %.3f", sp.kwh)
+	}
+}
+
+// TestSpotPricerUsesMeasuredInterval checks that the reading at T, which
+// measured [T-15m, T), is priced at the price of the interval starting at
+// T-15m, including the readings at the segment start and end.
+func TestSpotPricerUsesMeasuredInterval(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	insertZEVMeter(t, db, 1, "Apt", "apartment_meter", 1, 10)
+
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	// Prices from 23:45 to 00:30 are 0.10, 0.20, 0.30, 0.40.
+	var prices []models.SpotPrice
+	for i := 0; i < 4; i++ {
+		prices = append(prices, models.SpotPrice{PriceArea: "CH", IntervalStart: start.Add(time.Duration(i-1) * 15 * time.Minute), Price: 0.1 * float64(i+1)})
+	}
+	if _, err := StoreSpotPrices(db, "CH", prices, "test"); err != nil {
+		t.Fatalf("StoreSpotPrices: %v", err)
+	}
+	for _, ts := range []time.Time{start, start.Add(15 * time.Minute), end} {
+		insertZEVReading(t, db, 1, ts, 1, 0)
+	}
+
+	sp, err := bs.spotPricerFor(models.BillingSettings{GridPricingMode: GridPricingSpot, SpotPriceArea: "CH"}, start, end)
+	if err != nil || sp == nil {
+		t.Fatalf("spotPricerFor = %v, %v", sp, err)
+	}
+	bs.calculateZEVConsumptionWithGrid(10, 1, start, end, sp.add)
+	if err := sp.missingError(); err != nil {
+		t.Fatalf("unexpected missing prices: %v", err)
+	}
+	// 00:00 → 0.10, 00:15 → 0.20, 00:30 → 0.30; the 00:30 interval is not used.
+	if !almostEqual(sp.kwh, 3) || !almostEqual(sp.cost, 0.6) {
+		t.Errorf("priced %.3f kWh for %.4f; want 3 kWh for 0.60", sp.kwh, sp.cost)
+	}
+}
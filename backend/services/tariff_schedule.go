@@ -0,0 +1,181 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// noTariffWindow is the window index for intervals no window covers; their
+// grid share falls back to the pricing row's NormalPowerPrice.
+const noTariffWindow = -1
+
+// tariffSchedule is a building's time-of-use windows (HT/NT) plus its holiday
+// calendar, ready to classify 15-minute intervals.
+type tariffSchedule struct {
+	windows  []models.TariffWindow
+	starts   []int // minutes after midnight, parallel to windows
+	ends     []int
+	holidays map[string]bool // "YYYY-MM-DD"
+}
+
+// ParseTariffClock parses "HH:MM" into minutes after midnight. "24:00" is
+// accepted as end-of-day.
+func ParseTariffClock(s string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// weekdayBit maps a time.Weekday onto the tariff_windows.weekday_mask bit
+// (bit 0 = Monday … bit 6 = Sunday).
+func weekdayBit(d time.Weekday) int {
+	return 1 << ((int(d) + 6) % 7)
+}
+
+// loadTariffSchedule loads the active windows and holidays of a building.
+// Returns nil when the building has no active windows.
+func (bs *BillingService) loadTariffSchedule(buildingID int) (*tariffSchedule, error) {
+	rows, err := bs.db.Query(`
+		SELECT id, building_id, name, weekday_mask, start_time, end_time,
+		       applies_on_holidays, price, sort_order, is_active
+		FROM tariff_windows
+		WHERE building_id = ? AND is_active = 1
+		ORDER BY sort_order ASC, id ASC
+	`, buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tariff_windows: %v", err)
+	}
+	defer rows.Close()
+
+	ts := &tariffSchedule{holidays: map[string]bool{}}
+	for rows.Next() {
+		var w models.TariffWindow
+		if err := rows.Scan(&w.ID, &w.BuildingID, &w.Name, &w.WeekdayMask, &w.StartTime, &w.EndTime,
+			&w.AppliesOnHolidays, &w.Price, &w.SortOrder, &w.IsActive); err != nil {
+			return nil, err
+		}
+		start, err := ParseTariffClock(w.StartTime)
+		if err != nil {
+			log.Printf("WARNING: tariff window %d (%s) skipped: %v", w.ID, w.Name, err)
+			continue
+		}
+		end, err := ParseTariffClock(w.EndTime)
+		if err != nil {
+			log.Printf("WARNING: tariff window %d (%s) skipped: %v", w.ID, w.Name, err)
+			continue
+		}
+		ts.windows = append(ts.windows, w)
+		ts.starts = append(ts.starts, start)
+		ts.ends = append(ts.ends, end)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts.windows) == 0 {
+		return nil, nil
+	}
+
+	hRows, err := bs.db.Query(`SELECT holiday_date FROM tariff_holidays WHERE building_id = ?`, buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tariff_holidays: %v", err)
+	}
+	defer hRows.Close()
+	for hRows.Next() {
+		var d string
+		if err := hRows.Scan(&d); err == nil {
+			ts.holidays[storedDay(d)] = true
+		}
+	}
+	return ts, hRows.Err()
+}
+
+// windowIndex returns the index of the first window (by sort order) covering
+// the interval starting at t, or noTariffWindow. The interval is classified by
+// its local wall-clock start, which is 15 minutes before the reading that
+// measured it. A window that wraps past midnight belongs to the
+// day it starts on, so Friday 22:00–06:00 covers Saturday 03:00.
+func (ts *tariffSchedule) windowIndex(t time.Time) int {
+	t = t.In(time.Local)
+	minute := t.Hour()*60 + t.Minute()
+	for i, w := range ts.windows {
+		start, end := ts.starts[i], ts.ends[i]
+		day := t
+		switch {
+		case start == end || end == 24*60 && start == 0:
+			// whole day
+		case start < end:
+			if minute < start || minute >= end {
+				continue
+			}
+		default: // wraps past midnight
+			if minute < end {
+				day = t.AddDate(0, 0, -1) // tail of the previous day's window
+			} else if minute < start {
+				continue
+			}
+		}
+		if ts.holidays[day.Format("2006-01-02")] {
+			if !w.AppliesOnHolidays {
+				continue
+			}
+		} else if w.WeekdayMask&weekdayBit(day.Weekday()) == 0 {
+			continue
+		}
+		return i
+	}
+	return noTariffWindow
+}
+
+// tariffWindowsFor returns the schedule to price a segment with, or nil when
+// the segment's pricing row does not use time-of-use windows.
+func (bs *BillingService) tariffWindowsFor(buildingID int, settings models.BillingSettings) *tariffSchedule {
+	if !settings.TariffWindowsEnabled {
+		return nil
+	}
+	ts, err := bs.loadTariffSchedule(buildingID)
+	if err != nil {
+		log.Printf("WARNING: failed to load tariff windows for building %d, using single grid price: %v", buildingID, err)
+		return nil
+	}
+	return ts
+}
+
+// appendTariffWindowItems emits one grid line per tariff window that carried
+// consumption, plus a line at NormalPowerPrice for kWh outside every window.
+// Returns the total cost added.
+func appendTariffWindowItems(items *[]models.InvoiceItem, ts *tariffSchedule, gridByWindow map[int]float64,
+	s models.BillingSettings, suffix string, tr InvoiceTranslations) float64 {
+	total := 0.0
+	add := func(label string, kwh, price float64) {
+		if kwh <= 0 {
+			return
+		}
+		cost := kwh * price
+		total += cost
+		*items = append(*items, models.InvoiceItem{
+			Description: fmt.Sprintf("%s%s: %.3f kWh × %.3f %s/kWh", label, suffix, kwh, price, s.Currency),
+			Quantity:    kwh,
+			UnitPrice:   price,
+			TotalPrice:  cost,
+			ItemType:    "normal_power",
+		})
+		log.Printf("  %s%s: %.3f kWh × %.3f = %.3f %s", label, suffix, kwh, price, cost, s.Currency)
+	}
+	for i, w := range ts.windows {
+		add(fmt.Sprintf("%s – %s", tr.NormalPowerGrid, w.Name), gridByWindow[i], w.Price)
+	}
+	add(tr.NormalPowerGrid, gridByWindow[noTariffWindow], s.NormalPowerPrice)
+	return total
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestTariffWindowIndex(t *testing.T) {
+	ts := &tariffSchedule{
+		windows: []models.TariffWindow{
+			{Name: "HT", WeekdayMask: 0x1F},                                // Mon–Fri 06:00–22:00
+			{Name: "NT-night", WeekdayMask: 0x7F, AppliesOnHolidays: true}, // every day 22:00–06:00 (wraps)
+		},
+		starts:   []int{6 * 60, 22 * 60},
+		ends:     []int{22 * 60, 6 * 60},
+		holidays: map[string]bool{"2026-08-01": true}, // Saturday, Swiss national day
+	}
+	at := func(y, m, d, hh, mm int) time.Time { return time.Date(y, time.Month(m), d, hh, mm, 0, 0, time.Local) }
+
+	cases := []struct {
+		name string
+		t    time.Time
+		want int
+	}{
+		{"weekday daytime is HT", at(2026, 7, 29, 10, 0), 0},          // Wednesday
+		{"HT end is exclusive", at(2026, 7, 29, 22, 0), 1},            // 22:00 → night window
+		{"weekday early morning is night", at(2026, 7, 29, 5, 45), 1}, // tail of Tuesday's night
+		{"saturday daytime has no window", at(2026, 8, 8, 12, 0), noTariffWindow},
+		{"holiday night applies", at(2026, 8, 1, 23, 0), 1},
+		{"friday-night window covers saturday 03:00", at(2026, 8, 8, 3, 0), 1},
+	}
+	for _, c := range cases {
+		if got := ts.windowIndex(c.t); got != c.want {
+			t.Errorf("%s: windowIndex(%s) = %d, want %d", c.name, c.t.Format("Mon 2006-01-02 15:04"), got, c.want)
+		}
+	}
+
+	// A holiday on a weekday drops HT even though the weekday mask matches.
+	ts.holidays["2026-07-29"] = true
+	if got := ts.windowIndex(at(2026, 7, 29, 10, 0)); got != noTariffWindow {
+		t.Errorf("holiday weekday daytime = %d, want no window", got)
+	}
+}
+
+func TestParseTariffClock(t *testing.T) {
+	for in, want := range map[string]int{"00:00": 0, "06:30": 390, "24:00": 1440} {
+		if got, err := ParseTariffClock(in); err != nil || got != want {
+			t.Errorf("ParseTariffClock(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "6", "25:00", "24:30", "12:60", "ab:cd"} {
+		if _, err := ParseTariffClock(bad); err == nil {
+			t.Errorf("ParseTariffClock(%q) should fail", bad)
+		}
+	}
+}
+
+// TestTariffWindowsUseIntervalStart checks that a reading is classified by the
+// interval it measured: the reading at 22:00 covers 21:45–22:00 (HT), the one
+// at 06:00 covers 05:45–06:00 (NT).
+func TestTariffWindowsUseIntervalStart(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	insertBuilding(t, db, 1, "B")
+	insertUser(t, db, 10, 1)
+	insertZEVMeter(t, db, 1, "Apt", "apartment_meter", 1, 10)
+	at := func(hh, mm int) time.Time { return time.Date(2026, 7, 29, hh, mm, 0, 0, time.Local) }
+	insertZEVReading(t, db, 1, at(6, 0), 1, 0)
+	insertZEVReading(t, db, 1, at(22, 0), 2, 0)
+
+	ts := &tariffSchedule{
+		windows: []models.TariffWindow{{Name: "HT", WeekdayMask: 0x7F}, {Name: "NT", WeekdayMask: 0x7F}},
+		starts:  []int{6 * 60, 22 * 60},
+		ends:    []int{22 * 60, 6 * 60},
+	}
+	byWindow := map[int]float64{}
+	bs.calculateZEVConsumptionWithGrid(10, 1, at(0, 0), at(23, 0), func(start time.Time, kwh float64) {
+		byWindow[ts.windowIndex(start)] += kwh
+	})
+	if !almostEqual(byWindow[0], 2) || !almostEqual(byWindow[1], 1) {
+		t.Errorf("HT %.3f kWh, NT %.3f kWh; want 2 and 1", byWindow[0], byWindow[1])
+	}
+}
@@ -14,6 +14,8 @@ type InvoiceTranslations struct {
 	VAT            string
 	ThereofVAT     string
 	Total          string
+	CreditApplied  string
+	AmountDue      string
 	PaymentInfo    string
 	BankDetails    string
 	AccountHolder  string
@@ -85,6 +87,92 @@ type InvoiceTranslations struct {
 	// NEW: Proration description translations
 	Days     string
 	OfPeriod string
+
+	// Cancellation (Storno) documents
+	CreditNote      string // "Gutschrift" / "Credit note" / "Note de crédit" / "Nota di credito"
+	CreditNoteFor   string // reference line on a credit note: "... invoice #X"
+	ReplacesInvoice string // reference line on a corrected replacement invoice
+	// Corrections after a recalculation
+	SupplementaryInvoice string // document title: "Nachtragsrechnung"
+	CorrectsInvoice      string // reference line on a correction credit note or supplementary invoice
+
+	// Dynamic (spot) grid pricing
+	SpotPriceGrid string // grid line priced per interval, shown with the weighted average
+
+	// Grid price components
+	VATExempt string // marks a price component line outside the VAT base
+
+	// Peak-demand (kW) charges
+	DemandCharge    string // default line label of a demand tariff
+	PeakAt          string // followed by the start of the peak interval
+	TopPeaksAverage string // format: number of averaged peaks
+
+	// LEG (local electricity community)
+	LEGCommunity        string
+	LocalEnergy         string // locally produced kWh allocated to a consumer
+	LocalGridFee        string // discounted grid usage fee on local kWh
+	LocalProduction     string
+	ProductionMeter     string
+	LocalProductionSold string // producer credit line: kWh the community consumed
+	GridFeedIn          string // producer surplus beyond the community
+	ProducerCredit      string // document title of a producer credit statement
+
+	// Producer (PV owner / investor) credit statements
+	SolarSoldToTenants string
+	SolarExported      string
+	OwnershipShare     string // a producer's share of a jointly owned system
+
+	// Water, heat and gas
+	ColdWater          string
+	HotWater           string
+	Heat               string
+	Gas                string
+	BaseFee            string // monthly base fee per utility meter
+	SplitByConsumption string // shared meter cost allocated by measured consumption
+
+	// Advance payments (Akonto) and annual settlement
+	AdvanceInvoice    string // document title of an advance invoice
+	AdvancePayment    string // line label: "Akontozahlung"
+	SettlementInvoice string // document title of the annual settlement
+	AdvancesDeducted  string // line deducting the advances billed/paid in the period
+	SettlementDue     string // balance line when the tenant owes the difference
+	SettlementRefund  string // balance line when the advances exceeded consumption
+	SuggestedAdvance  string // suggested monthly advance for the next period
+
+	// Dunning (payment reminders)
+	DueDate           string // "payable by" line on invoices
+	PaymentReminder   string // title of reminder level 1
+	SecondReminder    string // title of reminder level 2
+	FinalNotice       string // title of reminder level 3
+	ReminderIntro     string // format: invoice number, invoice date, due date, new deadline
+	FinalNoticeIntro  string // same arguments as ReminderIntro
+	OutstandingAmount string // open invoice amount on a reminder
+	ReminderFee       string // reminder fee line
+	ReminderGreeting  string // e-mail salutation, format: tenant name
+
+	// Account statements
+	AccountStatement string // document title
+	Date             string
+	Document         string // document number column
+	Debit            string
+	CreditColumn     string // "Haben" column; CreditNote is the document title
+	Balance          string
+	OpeningBalance   string
+	ClosingBalance   string
+	PaymentReceived  string
+	Payout           string // payment to a producer
+
+	// Per-rate VAT summary
+	VATSummary  string
+	VATRate     string
+	NetAmount   string
+	GrossAmount string
+
+	// Consumption history chart
+	ConsumptionHistory string
+	GridPower          string
+	PreviousYear       string
+	BuildingAverage    string
 }
 
 // GetTranslations returns translations for the specified language
@@ -104,6 +192,8 @@ func GetTranslations(language string) InvoiceTranslations {
 			VAT:                        "MwSt.",
 			ThereofVAT:                 "davon MwSt.",
 			Total:                      "Gesamt",
+			CreditApplied:              "Guthaben verrechnet",
+			AmountDue:                  "Zu bezahlen",
 			PaymentInfo:                "Zahlungsinformationen",
 			BankDetails:                "Bankverbindung",
 			AccountHolder:              "Kontoinhaber",
@@ -162,6 +252,82 @@ func GetTranslations(language string) InvoiceTranslations {
 			// Proration translations
 			Days:     "Tage",
 			OfPeriod: "des Zeitraums",
+			// Cancellation documents
+			CreditNote:      "Gutschrift",
+			CreditNoteFor:   "Diese Gutschrift storniert Rechnung",
+			ReplacesInvoice: "Diese Rechnung ersetzt die stornierte Rechnung",
+			// Corrections after a recalculation
+			SupplementaryInvoice: "Nachtragsrechnung",
+			CorrectsInvoice:      "Korrektur zu Rechnung",
+			// Dynamic (spot) grid pricing
+			SpotPriceGrid: "Netzstrom (dynamischer Tarif, Ø-Preis)",
+			// Grid price components
+			VATExempt: "MWST-befreit",
+			// Peak-demand charges
+			DemandCharge:    "Leistungspreis",
+			PeakAt:          "Spitze am",
+			TopPeaksAverage: "Ø der %d höchsten Spitzen",
+			// LEG
+			LEGCommunity:        "Lokale Elektrizitätsgemeinschaft (LEG)",
+			LocalEnergy:         "Lokalstrom",
+			LocalGridFee:        "Netznutzung Lokalstrom",
+			LocalProduction:     "Produktion",
+			ProductionMeter:     "Produktionszähler",
+			LocalProductionSold: "An die Gemeinschaft geliefert",
+			GridFeedIn:          "Ins Netz eingespeist (Überschuss)",
+			ProducerCredit:      "Produzentengutschrift",
+			// Producer statements
+			SolarSoldToTenants: "An Mieter verkaufter Solarstrom",
+			SolarExported:      "Ins Netz eingespeist",
+			OwnershipShare:     "Anteil",
+			// Water, heat and gas
+			ColdWater:          "Kaltwasser",
+			HotWater:           "Warmwasser",
+			Heat:               "Wärme",
+			Gas:                "Gas",
+			BaseFee:            "Grundgebühr",
+			SplitByConsumption: "Nach Verbrauch",
+			// Advance payments and settlement
+			AdvanceInvoice:    "Akontorechnung",
+			AdvancePayment:    "Akontozahlung",
+			SettlementInvoice: "Jahresabrechnung",
+			AdvancesDeducted:  "Abzüglich Akontozahlungen",
+			SettlementDue:     "Nachzahlung",
+			SettlementRefund:  "Guthaben zu Ihren Gunsten",
+			SuggestedAdvance:  "Vorschlag neue monatliche Akontozahlung",
+			// Dunning
+			DueDate:           "Zahlbar bis",
+			PaymentReminder:   "Zahlungserinnerung",
+			SecondReminder:    "2. Mahnung",
+			FinalNotice:       "Letzte Mahnung",
+			ReminderIntro:     "Unsere Rechnung %s vom %s war am %s zur Zahlung fällig. Leider konnten wir bis heute keinen Zahlungseingang feststellen. Bitte überweisen Sie den offenen Betrag bis %s. Sollte sich Ihre Zahlung mit diesem Schreiben gekreuzt haben, betrachten Sie es bitte als gegenstandslos.",
+			FinalNoticeIntro:  "Trotz unserer Mahnungen ist die Rechnung %s vom %s (fällig am %s) noch immer offen. Wir fordern Sie letztmals auf, den ausstehenden Betrag bis %s zu begleichen. Andernfalls sehen wir uns gezwungen, weitere Schritte einzuleiten.",
+			OutstandingAmount: "Offener Rechnungsbetrag",
+			ReminderFee:       "Mahngebühr",
+			ReminderGreeting:  "Guten Tag %s",
+			// Account statements
+			AccountStatement: "Kontoauszug",
+			Date:             "Datum",
+			Document:         "Beleg",
+			Debit:            "Soll",
+			CreditColumn:     "Haben",
+			Balance:          "Saldo",
+			OpeningBalance:   "Anfangssaldo",
+			ClosingBalance:   "Schlusssaldo",
+			PaymentReceived:  "Zahlungseingang",
+			Payout:           "Auszahlung",
+
+			// Per-rate VAT summary
+			VATSummary:  "MWST-Übersicht",
+			VATRate:     "Satz",
+			NetAmount:   "Netto",
+			GrossAmount: "Brutto",
+
+			// Consumption history chart
+			ConsumptionHistory: "Ihr Verbrauch der letzten 12 Monate (kWh)",
+			GridPower:          "Netzstrom",
+			PreviousYear:       "Vorjahr",
+			BuildingAverage:    "Durchschnitt pro Wohnung",
 		}
 	case "fr": // French
 		return InvoiceTranslations{
@@ -177,6 +343,8 @@ func GetTranslations(language string) InvoiceTranslations {
 			VAT:                        "TVA",
 			ThereofVAT:                 "dont TVA",
 			Total:                      "Total",
+			CreditApplied:              "Avoir imputé",
+			AmountDue:                  "Montant à payer",
 			PaymentInfo:                "Informations de paiement",
 			BankDetails:                "Coordonnées bancaires",
 			AccountHolder:              "Titulaire du compte",
@@ -235,6 +403,82 @@ func GetTranslations(language string) InvoiceTranslations {
 			// Proration translations
 			Days:     "jours",
 			OfPeriod: "de la période",
+			// Cancellation documents
+			CreditNote:      "Note de crédit",
+			CreditNoteFor:   "Cette note de crédit annule la facture",
+			ReplacesInvoice: "Cette facture remplace la facture annulée",
+			// Corrections after a recalculation
+			SupplementaryInvoice: "Facture complémentaire",
+			CorrectsInvoice:      "Correction de la facture",
+			// Dynamic (spot) grid pricing
+			SpotPriceGrid: "Électricité du réseau (tarif dynamique, prix moyen)",
+			// Grid price components
+			VATExempt: "exonéré de TVA",
+			// Peak-demand charges
+			DemandCharge:    "Prix de la puissance",
+			PeakAt:          "pointe le",
+			TopPeaksAverage: "moyenne des %d plus hautes pointes",
+			// LEG
+			LEGCommunity:        "Communauté électrique locale (CEL)",
+			LocalEnergy:         "Électricité locale",
+			LocalGridFee:        "Utilisation du réseau électricité locale",
+			LocalProduction:     "Production",
+			ProductionMeter:     "Compteur de production",
+			LocalProductionSold: "Livré à la communauté",
+			GridFeedIn:          "Injecté dans le réseau (excédent)",
+			ProducerCredit:      "Décompte de crédit producteur",
+			// Producer statements
+			SolarSoldToTenants: "Énergie solaire vendue aux locataires",
+			SolarExported:      "Injecté dans le réseau",
+			OwnershipShare:     "Part",
+			// Water, heat and gas
+			ColdWater:          "Eau froide",
+			HotWater:           "Eau chaude",
+			Heat:               "Chaleur",
+			Gas:                "Gaz",
+			BaseFee:            "Taxe de base",
+			SplitByConsumption: "Selon la consommation",
+			// Advance payments and settlement
+			AdvanceInvoice:    "Facture d'acompte",
+			AdvancePayment:    "Acompte",
+			SettlementInvoice: "Décompte annuel",
+			AdvancesDeducted:  "Moins acomptes",
+			SettlementDue:     "Solde à payer",
+			SettlementRefund:  "Solde en votre faveur",
+			SuggestedAdvance:  "Nouvel acompte mensuel proposé",
+			// Dunning
+			DueDate:           "Payable jusqu'au",
+			PaymentReminder:   "Rappel de paiement",
+			SecondReminder:    "2e rappel",
+			FinalNotice:       "Dernier rappel",
+			ReminderIntro:     "Notre facture %s du %s était payable le %s. À ce jour, nous n'avons pas reçu votre paiement. Nous vous prions de régler le montant dû d'ici le %s. Si votre paiement a croisé ce courrier, veuillez ne pas en tenir compte.",
+			FinalNoticeIntro:  "Malgré nos rappels, la facture %s du %s (échue le %s) reste impayée. Nous vous prions une dernière fois de régler le montant dû d'ici le %s, faute de quoi nous devrons engager d'autres démarches.",
+			OutstandingAmount: "Montant impayé",
+			ReminderFee:       "Frais de rappel",
+			ReminderGreeting:  "Bonjour %s",
+			// Account statements
+			AccountStatement: "Relevé de compte",
+			Date:             "Date",
+			Document:         "Pièce",
+			Debit:            "Débit",
+			CreditColumn:     "Crédit",
+			Balance:          "Solde",
+			OpeningBalance:   "Solde initial",
+			ClosingBalance:   "Solde final",
+			PaymentReceived:  "Paiement reçu",
+			Payout:           "Versement",
+
+			// Per-rate VAT summary
+			VATSummary:  "Récapitulatif TVA",
+			VATRate:     "Taux",
+			NetAmount:   "Net",
+			GrossAmount: "Brut",
+
+			// Consumption history chart
+			ConsumptionHistory: "Votre consommation des 12 derniers mois (kWh)",
+			GridPower:          "Réseau",
+			PreviousYear:       "Année précédente",
+			BuildingAverage:    "Moyenne par logement",
 		}
 	case "it": // Italian
 		return InvoiceTranslations{
@@ -250,6 +494,8 @@ func GetTranslations(language string) InvoiceTranslations {
 			VAT:                        "IVA",
 			ThereofVAT:                 "di cui IVA",
 			Total:                      "Totale",
+			CreditApplied:              "Credito compensato",
+			AmountDue:                  "Importo da pagare",
 			PaymentInfo:                "Informazioni di pagamento",
 			BankDetails:                "Dati bancari",
 			AccountHolder:              "Titolare del conto",
@@ -308,6 +554,82 @@ func GetTranslations(language string) InvoiceTranslations {
 			// Proration translations
 			Days:     "giorni",
 			OfPeriod: "del periodo",
+			// Cancellation documents
+			CreditNote:      "Nota di credito",
+			CreditNoteFor:   "Questa nota di credito annulla la fattura",
+			ReplacesInvoice: "Questa fattura sostituisce la fattura annullata",
+			// Corrections after a recalculation
+			SupplementaryInvoice: "Fattura integrativa",
+			CorrectsInvoice:      "Correzione della fattura",
+			// Dynamic (spot) grid pricing
+			SpotPriceGrid: "Energia dalla rete (tariffa dinamica, prezzo medio)",
+			// Grid price components
+			VATExempt: "esente IVA",
+			// Peak-demand charges
+			DemandCharge:    "Prezzo della potenza",
+			PeakAt:          "picco il",
+			TopPeaksAverage: "media dei %d picchi più alti",
+			// LEG
+			LEGCommunity:        "Comunità elettrica locale (CEL)",
+			LocalEnergy:         "Elettricità locale",
+			LocalGridFee:        "Utilizzo della rete elettricità locale",
+			LocalProduction:     "Produzione",
+			ProductionMeter:     "Contatore di produzione",
+			LocalProductionSold: "Fornito alla comunità",
+			GridFeedIn:          "Immesso in rete (eccedenza)",
+			ProducerCredit:      "Nota di accredito produttore",
+			// Producer statements
+			SolarSoldToTenants: "Energia solare venduta agli inquilini",
+			SolarExported:      "Immesso in rete",
+			OwnershipShare:     "Quota",
+			// Water, heat and gas
+			ColdWater:          "Acqua fredda",
+			HotWater:           "Acqua calda",
+			Heat:               "Calore",
+			Gas:                "Gas",
+			BaseFee:            "Tassa di base",
+			SplitByConsumption: "In base al consumo",
+			// Advance payments and settlement
+			AdvanceInvoice:    "Fattura d'acconto",
+			AdvancePayment:    "Acconto",
+			SettlementInvoice: "Conteggio annuale",
+			AdvancesDeducted:  "Meno acconti",
+			SettlementDue:     "Saldo da pagare",
+			SettlementRefund:  "Saldo a vostro favore",
+			SuggestedAdvance:  "Nuovo acconto mensile proposto",
+			// Dunning
+			DueDate:           "Pagabile entro",
+			PaymentReminder:   "Sollecito di pagamento",
+			SecondReminder:    "2° sollecito",
+			FinalNotice:       "Ultimo sollecito",
+			ReminderIntro:     "La nostra fattura %s del %s era esigibile il %s. Ad oggi non abbiamo ricevuto alcun pagamento. La preghiamo di versare l'importo dovuto entro il %s. Se il pagamento si è incrociato con questa lettera, la consideri nulla.",
+			FinalNoticeIntro:  "Nonostante i nostri solleciti, la fattura %s del %s (scaduta il %s) risulta ancora aperta. La invitiamo per l'ultima volta a saldare l'importo dovuto entro il %s; in caso contrario saremo costretti ad avviare ulteriori passi.",
+			OutstandingAmount: "Importo scoperto",
+			ReminderFee:       "Spese di sollecito",
+			ReminderGreeting:  "Buongiorno %s",
+			// Account statements
+			AccountStatement: "Estratto conto",
+			Date:             "Data",
+			Document:         "Documento",
+			Debit:            "Dare",
+			CreditColumn:     "Avere",
+			Balance:          "Saldo",
+			OpeningBalance:   "Saldo iniziale",
+			ClosingBalance:   "Saldo finale",
+			PaymentReceived:  "Pagamento ricevuto",
+			Payout:           "Versamento",
+
+			// Per-rate VAT summary
+			VATSummary:  "Riepilogo IVA",
+			VATRate:     "Aliquota",
+			NetAmount:   "Netto",
+			GrossAmount: "Lordo",
+
+			// Consumption history chart
+			ConsumptionHistory: "Il suo consumo degli ultimi 12 mesi (kWh)",
+			GridPower:          "Rete",
+			PreviousYear:       "Anno precedente",
+			BuildingAverage:    "Media per appartamento",
 		}
 	default: // English
 		return InvoiceTranslations{
@@ -323,6 +645,8 @@ func GetTranslations(language string) InvoiceTranslations {
 			VAT:                        "VAT",
 			ThereofVAT:                 "thereof VAT",
 			Total:                      "Total",
+			CreditApplied:              "Credit balance applied",
+			AmountDue:                  "Amount due",
 			PaymentInfo:                "Payment Information",
 			BankDetails:                "Bank Details",
 			AccountHolder:              "Account Holder",
@@ -381,6 +705,82 @@ func GetTranslations(language string) InvoiceTranslations {
 			// Proration translations
 			Days:     "days",
 			OfPeriod: "of period",
+			// Cancellation documents
+			CreditNote:      "Credit note",
+			CreditNoteFor:   "This credit note cancels invoice",
+			ReplacesInvoice: "This invoice replaces cancelled invoice",
+			// Corrections after a recalculation
+			SupplementaryInvoice: "Supplementary invoice",
+			CorrectsInvoice:      "Correction to invoice",
+			// Dynamic (spot) grid pricing
+			SpotPriceGrid: "Grid power (dynamic tariff, avg. price)",
+			// Grid price components
+			VATExempt: "VAT exempt",
+			// Peak-demand charges
+			DemandCharge:    "Demand charge",
+			PeakAt:          "peak on",
+			TopPeaksAverage: "avg. of the %d highest peaks",
+			// LEG
+			LEGCommunity:        "Local electricity community (LEG)",
+			LocalEnergy:         "Local energy",
+			LocalGridFee:        "Grid usage on local energy",
+			LocalProduction:     "Production",
+			ProductionMeter:     "Production meter",
+			LocalProductionSold: "Supplied to the community",
+			GridFeedIn:          "Fed into the grid (surplus)",
+			ProducerCredit:      "Producer credit statement",
+			// Producer statements
+			SolarSoldToTenants: "Solar power sold to tenants",
+			SolarExported:      "Exported to the grid",
+			OwnershipShare:     "Share",
+			// Water, heat and gas
+			ColdWater:          "Cold water",
+			HotWater:           "Hot water",
+			Heat:               "Heat",
+			Gas:                "Gas",
+			BaseFee:            "Base fee",
+			SplitByConsumption: "By consumption",
+			// Advance payments and settlement
+			AdvanceInvoice:    "Advance invoice",
+			AdvancePayment:    "Advance payment",
+			SettlementInvoice: "Annual settlement",
+			AdvancesDeducted:  "Less advance payments",
+			SettlementDue:     "Balance due",
+			SettlementRefund:  "Credit in your favour",
+			SuggestedAdvance:  "Suggested new monthly advance",
+			// Dunning
+			DueDate:           "Due date",
+			PaymentReminder:   "Payment reminder",
+			SecondReminder:    "Second reminder",
+			FinalNotice:       "Final notice",
+			ReminderIntro:     "Our invoice %s dated %s was due for payment on %s. We have not yet received your payment. Please transfer the outstanding amount by %s. If your payment has crossed with this letter, please disregard it.",
+			FinalNoticeIntro:  "Despite our reminders, invoice %s dated %s (due %s) is still unpaid. We ask you one final time to settle the outstanding amount by %s; otherwise we will have to take further steps.",
+			OutstandingAmount: "Outstanding amount",
+			ReminderFee:       "Reminder fee",
+			ReminderGreeting:  "Hello %s",
+			// Account statements
+			AccountStatement: "Statement of account",
+			Date:             "Date",
+			Document:         "Document",
+			Debit:            "Debit",
+			CreditColumn:     "Credit",
+			Balance:          "Balance",
+			OpeningBalance:   "Opening balance",
+			ClosingBalance:   "Closing balance",
+			PaymentReceived:  "Payment received",
+			Payout:           "Payout",
+
+			// Per-rate VAT summary
+			VATSummary:  "VAT summary",
+			VATRate:     "Rate",
+			NetAmount:   "Net",
+			GrossAmount: "Gross",
+
+			// Consumption history chart
+			ConsumptionHistory: "Your consumption over the last 12 months (kWh)",
+			GridPower:          "Grid",
+			PreviousYear:       "Previous year",
+			BuildingAverage:    "Average per unit",
 		}
 	}
 }
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+// Built-in VAT codes. Standard bills at the building's VAT rate, exempt
+// keeps a line outside the VAT base; both always exist.
+const (
+	VATCodeStandard = "standard"
+	VATCodeExempt   = "exempt"
+)
+
+// Sources a VAT code is assigned to (vat_code_assignments.source). Custom
+// items use "custom_" + their category.
+const (
+	VATSourceEnergy      = "energy"
+	VATSourceCharging    = "charging"
+	VATSourceSharedMeter = "shared_meter"
+	VATSourceUtility     = "utility"
+)
+
+// VATSourceCustom is the assignment source of a custom item category.
+func VATSourceCustom(category string) string {
+	return "custom_" + category
+}
+
+// ValidVATSource reports whether source can be assigned a VAT code.
+func ValidVATSource(source string) bool {
+	switch source {
+	case VATSourceEnergy, VATSourceCharging, VATSourceSharedMeter, VATSourceUtility,
+		VATSourceCustom("meter_rent"), VATSourceCustom("maintenance"),
+		VATSourceCustom("service"), VATSourceCustom("other"):
+		return true
+	}
+	return false
+}
+
+// vatSourceOf maps a billable line type to its assignment source.
+func vatSourceOf(itemType string) string {
+	switch {
+	case strings.HasPrefix(itemType, "car_charging"):
+		return VATSourceCharging
+	case itemType == "shared_meter_charge":
+		return VATSourceSharedMeter
+	case strings.HasPrefix(itemType, "utility_"):
+		return VATSourceUtility
+	}
+	return VATSourceEnergy
+}
+
+// vatTable holds the VAT codes and their assignments.
+type vatTable struct {
+	codes       map[string]models.VATCode
+	assignments map[string]string
+}
+
+func loadVATTable(db *sql.DB) (*vatTable, error) {
+	t := &vatTable{codes: map[string]models.VATCode{}, assignments: map[string]string{}}
+	rows, err := db.Query(`SELECT id, code, name, rate, exempt FROM vat_codes`)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.VATCode
+		var rate sql.NullFloat64
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &rate, &c.Exempt); err != nil {
+			return t, err
+		}
+		if rate.Valid {
+			c.Rate = &rate.Float64
+		}
+		t.codes[c.Code] = c
+	}
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+
+	arows, err := db.Query(`SELECT source, vat_code FROM vat_code_assignments`)
+	if err != nil {
+		return t, err
+	}
+	defer arows.Close()
+	for arows.Next() {
+		var source, code string
+		if err := arows.Scan(&source, &code); err != nil {
+			return t, err
+		}
+		t.assignments[source] = code
+	}
+	return t, arows.Err()
+}
+
+// codeFor returns the VAT code assigned to source, standard when there is
+// none or it no longer exists.
+func (t *vatTable) codeFor(source string) string {
+	if code, ok := t.assignments[source]; ok {
+		if _, exists := t.codes[code]; exists {
+			return code
+		}
+	}
+	return VATCodeStandard
+}
+
+// rate resolves the VAT rate of code under the building settings s. Unknown
+// codes bill at the standard rate.
+func (t *vatTable) rate(code string, s models.BillingSettings) float64 {
+	if code == VATCodeExempt {
+		return 0
+	}
+	c, ok := t.codes[code]
+	switch {
+	case !ok || code == VATCodeStandard:
+		return s.VATRate
+	case c.Exempt:
+		return 0
+	case c.Rate == nil:
+		return s.VATRate
+	}
+	return *c.Rate
+}
+
+func (t *vatTable) exempt(code string) bool {
+	return code == VATCodeExempt || t.codes[code].Exempt
+}
+
+// customItemVATCode returns the VAT code of a custom item: its own override,
+// else the code assigned to its category.
+func (bs *BillingService) customItemVATCode(override, category string) string {
+	if override != "" {
+		return override
+	}
+	t, err := loadVATTable(bs.db)
+	if err != nil {
+		log.Printf("WARNING: Failed to load VAT codes: %v", err)
+	}
+	return t.codeFor(VATSourceCustom(category))
+}
+
+// assignItemVAT gives every billable line without a VAT code the code of its
+// source and stores the rate the code resolves to under s. Buildings that
+// charge no VAT keep their lines uncoded.
+func (bs *BillingService) assignItemVAT(items []models.InvoiceItem, s models.BillingSettings) {
+	if s.VATRate <= 0 {
+		return
+	}
+	t, err := loadVATTable(bs.db)
+	if err != nil {
+		log.Printf("WARNING: Failed to load VAT codes, billing at the standard rate: %v", err)
+	}
+	for i := range items {
+		it := &items[i]
+		if it.TotalPrice == 0 {
+			continue
+		}
+		if it.VATCode == "" {
+			it.VATCode = t.codeFor(vatSourceOf(it.ItemType))
+		}
+		if t.exempt(it.VATCode) {
+			it.VATRate = 0
+		} else {
+			it.VATRate = t.rate(it.VATCode, s)
+		}
+	}
+}
+
+// vatGroups sums the lines coded other than standard per code and rate and
+// splits each sum into net, VAT and gross. Also returns the part of the
+// invoice total the groups account for.
+func vatGroups(items []models.InvoiceItem, included bool) (groups []models.VATSummaryLine, base float64) {
+	type groupKey struct {
+		code string
+		rate float64
+	}
+	index := map[groupKey]int{}
+	for _, it := range items {
+		if it.VATCode == "" || it.VATCode == VATCodeStandard || it.TotalPrice == 0 {
+			continue
+		}
+		key := groupKey{it.VATCode, it.VATRate}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, models.VATSummaryLine{Code: it.VATCode, Rate: it.VATRate, Exempt: it.VATRate == 0})
+		}
+		groups[i].Gross += it.TotalPrice
+		base += it.TotalPrice
+	}
+	for i := range groups {
+		g := &groups[i]
+		r := g.Rate / 100
+		if included {
+			g.Net = g.Gross / (1 + r)
+		} else {
+			g.Net = g.Gross
+			g.Gross = g.Net * (1 + r)
+		}
+		g.VAT = g.Gross - g.Net
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a].Rate > groups[b].Rate })
+	return groups, base
+}
+
+// vatBreakdownItems is vatBreakdown for an invoice whose lines carry VAT
+// codes: lines at another code than standard are split at their own rate
+// (exempt ones stay outside the VAT base), the rest of total at the
+// building's rate.
+func vatBreakdownItems(total float64, items []models.InvoiceItem, s models.BillingSettings) (net, vat, gross float64) {
+	groups, base := vatGroups(items, s.VATIncluded)
+	net, vat, gross = vatBreakdown(total-base, s)
+	for _, g := range groups {
+		net += g.Net
+		vat += g.VAT
+		gross += g.Gross
+	}
+	return net, vat, gross
+}
+
+// VATSummary splits the VAT of inv per code and rate. The standard line is
+// what remains of the stored net and VAT after the other codes, so the
+// summary always adds up to the invoice totals; lines billed before VAT
+// codes existed count as standard.
+func VATSummary(inv models.Invoice) []models.VATSummaryLine {
+	groups, _ := vatGroups(inv.Items, inv.VATIncluded)
+	std := models.VATSummaryLine{Code: VATCodeStandard, Rate: inv.VATRate, Net: inv.NetAmount, VAT: inv.VATAmount}
+	for _, g := range groups {
+		std.Net -= g.Net
+		std.VAT -= g.VAT
+	}
+	std.Gross = std.Net + std.VAT
+
+	var out []models.VATSummaryLine
+	if len(groups) == 0 || math.Abs(std.Net) >= 0.005 || math.Abs(std.VAT) >= 0.005 {
+		out = append(out, std)
+	}
+	out = append(out, groups...)
+	for i := range out {
+		out[i].Net = math.Round(out[i].Net*100) / 100
+		out[i].VAT = math.Round(out[i].VAT*100) / 100
+		out[i].Gross = math.Round(out[i].Gross*100) / 100
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Rate > out[b].Rate })
+	return out
+}
+
+// multiRateVATSummary returns the VAT summary of a rendered invoice when its
+// lines are billed at more than one rate, nil otherwise.
+func multiRateVATSummary(inv map[string]interface{}) []models.VATSummaryLine {
+	summary, _ := inv["vat_summary"].([]models.VATSummaryLine)
+	if len(summary) < 2 {
+		return nil
+	}
+	return summary
+}
+
+// vatSummaryLabel names a VAT summary line on an invoice: its rate, or the
+// exempt label.
+func vatSummaryLabel(l models.VATSummaryLine, tr InvoiceTranslations) string {
+	if l.Exempt {
+		return tr.VATExempt
+	}
+	return fmt.Sprintf("%s %.1f%%", tr.VAT, l.Rate)
+}
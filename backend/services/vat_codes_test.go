@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aj9599/zev-billing/backend/models"
+)
+
+func TestVATCodes(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBillingService(db)
+	if _, err := db.Exec(`UPDATE vat_code_assignments SET vat_code = 'reduced' WHERE source = 'utility'`); err != nil {
+		t.Fatal(err)
+	}
+	if got := bs.customItemVATCode("", "service"); got != VATCodeStandard {
+		t.Errorf("service category code %q", got)
+	}
+	if got := bs.customItemVATCode("exempt", "service"); got != VATCodeExempt {
+		t.Errorf("override code %q", got)
+	}
+
+	items := []models.InvoiceItem{
+		{Description: "Grid", TotalPrice: 100, ItemType: "normal_power"},
+		{Description: "Water", TotalPrice: 50, ItemType: "utility_charge"},
+		{Description: "Levy", TotalPrice: 10, ItemType: "normal_power", VATCode: VATCodeExempt},
+		{Description: "Header", ItemType: "utility_header"},
+	}
+	s := models.BillingSettings{VATRate: 8.1}
+	bs.assignItemVAT(items, s)
+	if items[0].VATCode != VATCodeStandard || items[1].VATCode != "reduced" || items[1].VATRate != 2.6 ||
+		items[2].VATRate != 0 || items[3].VATCode != "" {
+		t.Fatalf("assigned codes: %+v", items)
+	}
+	net, vat, gross := vatBreakdownItems(160, items, s)
+	if !almostEqual(net, 160) || !almostEqual(vat, 8.1+1.3) || !almostEqual(gross, 169.4) {
+		t.Fatalf("net %.3f vat %.3f gross %.3f", net, vat, gross)
+	}
+
+	inv := models.Invoice{NetAmount: net, VATAmount: vat, TotalAmount: gross, VATRate: 8.1, Items: items}
+	summary := VATSummary(inv)
+	if len(summary) != 3 || summary[0].Code != VATCodeStandard || !almostEqual(summary[0].VAT, 8.1) ||
+		summary[1].Code != "reduced" || !almostEqual(summary[1].Gross, 51.3) || !summary[2].Exempt || summary[2].VAT != 0 {
+		t.Fatalf("summary: %+v", summary)
+	}
+
+	// Without VAT the lines stay uncoded and the summary is one line.
+	plain := []models.InvoiceItem{{TotalPrice: 100, ItemType: "normal_power"}}
+	bs.assignItemVAT(plain, models.BillingSettings{})
+	if plain[0].VATCode != "" || len(VATSummary(models.Invoice{NetAmount: 100, Items: plain})) != 1 {
+		t.Errorf("non-VAT invoice got codes: %+v", plain)
+	}
+}
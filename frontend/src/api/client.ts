@@ -5,7 +5,8 @@ import type {
   GenerateBillsRequest, GenerateBillsResult, MeterReplacement, MeterReplacementRequest,
   SelfConsumptionData, SystemHealth, DataHealth, CostOverview, EnergyFlowData, EnergyFlowLiveData,
   EmailAlertSettings, Device, DeviceLiveStatus, DeviceSwitchEvent, LoxoneControl,
-  LicenseStatus, SmartMeDevice, MeterLiveReading, BillingProfile
+  LicenseStatus, SmartMeDevice, MeterLiveReading, BillingProfile, InvoiceArchiveEntry,
+  AccountStatement, ApprovalResult, BankImport, BankTransaction
 } from '../types';
 
 const API_BASE = '/api';
@@ -627,6 +628,95 @@ class ApiClient {
     return this.request(`/billing/invoices/${id}`, { method: 'DELETE' });
   }
 
+  // Tamper-evident invoice archive
+  async getInvoiceArchiveEntry(id: number): Promise<InvoiceArchiveEntry> {
+    return this.request(`/billing/invoices/${id}/archive`);
+  }
+
+  async archiveInvoice(id: number): Promise<InvoiceArchiveEntry> {
+    return this.request(`/billing/invoices/${id}/archive`, { method: 'POST' });
+  }
+
+  async verifyInvoiceArchive(): Promise<{
+    valid: boolean;
+    checked: number;
+    problems: {
+      entry_id: number;
+      invoice_id: number;
+      invoice_number: string;
+      problem: 'missing' | 'modified' | 'chain_broken' | 'head_mismatch';
+      detail: string;
+    }[];
+    unarchived: { id: number; invoice_number: string; document_type: string; status: string; total_amount: number; generated_at: string }[];
+  }> {
+    return this.request('/billing/archive/verify');
+  }
+
+  // Bank statements (camt.053/054): import, then review unmatched credits
+  async importBankStatement(file: File): Promise<{ import: BankImport; transactions: BankTransaction[] }> {
+    const formData = new FormData();
+    formData.append('file', file);
+    const response = await fetch(`${API_BASE}/billing/bank-imports`, {
+      method: 'POST',
+      headers: { 'Authorization': `Bearer ${this.token}` },
+      body: formData,
+    });
+    if (!response.ok) throw new Error((await response.text()) || 'Import failed');
+    return response.json();
+  }
+
+  async getBankTransactions(status?: BankTransaction['status']): Promise<BankTransaction[]> {
+    const query = status ? `?status=${status}` : '';
+    return this.request(`/billing/bank-transactions${query}`);
+  }
+
+  async assignBankTransaction(id: number, invoiceId: number): Promise<{ transaction_id: number; invoice_id: number; status: string }> {
+    return this.request(`/billing/bank-transactions/${id}/assign`, {
+      method: 'POST',
+      body: JSON.stringify({ invoice_id: invoiceId }),
+    });
+  }
+
+  async ignoreBankTransaction(id: number, note: string) {
+    return this.request(`/billing/bank-transactions/${id}/ignore`, {
+      method: 'POST',
+      body: JSON.stringify({ note }),
+    });
+  }
+
+  // Account statements of one tenant (user_id) or every tenant of a building
+  async getAccountStatements(params: { user_id?: number; building_id?: number; from?: string; to?: string }): Promise<AccountStatement[]> {
+    return this.request(`/billing/account-statements?${this.statementQuery(params)}`);
+  }
+
+  async downloadAccountStatementsPDF(params: { user_id?: number; building_id?: number; from?: string; to?: string }, fileName: string): Promise<void> {
+    const response = await fetch(`${API_BASE}/billing/account-statements?${this.statementQuery(params)}&format=pdf`, {
+      headers: { 'Authorization': `Bearer ${this.token}` },
+    });
+    if (!response.ok) throw new Error((await response.text()) || 'Download failed');
+    this.saveBlob(await response.blob(), fileName);
+  }
+
+  private statementQuery(params: { user_id?: number; building_id?: number; from?: string; to?: string }): string {
+    const query = new URLSearchParams();
+    if (params.user_id) query.append('user_id', params.user_id.toString());
+    if (params.building_id) query.append('building_id', params.building_id.toString());
+    if (params.from) query.append('from', params.from);
+    if (params.to) query.append('to', params.to);
+    return query.toString();
+  }
+
+  private saveBlob(blob: Blob, fileName: string) {
+    const url = URL.createObjectURL(blob);
+    const a = document.createElement('a');
+    a.href = url;
+    a.download = fileName;
+    document.body.appendChild(a);
+    a.click();
+    a.remove();
+    URL.revokeObjectURL(url);
+  }
+
   async getBillingProfiles(): Promise<BillingProfile[]> {
     return this.request('/billing/profiles');
   }
@@ -726,6 +816,19 @@ class ApiClient {
     return this.request(`/billing/auto-configs/${id}`, { method: 'DELETE' });
   }
 
+  // Review queue: drafts of configs that require approval, with warnings
+  async getReviewQueue(configId?: number): Promise<Invoice[]> {
+    const query = configId ? `?config_id=${configId}` : '';
+    return this.request(`/billing/review-queue${query}`);
+  }
+
+  async approveInvoices(invoiceIds: number[]): Promise<ApprovalResult> {
+    return this.request('/billing/review-queue/approve', {
+      method: 'POST',
+      body: JSON.stringify({ invoice_ids: invoiceIds }),
+    });
+  }
+
   // Manually run an auto-billing config now (test run). Generates the bill
   // for the period that the next scheduled run would cover, produces the
   // PDF, and (when the config has auto_send_email enabled) e-mails it via
@@ -779,6 +882,7 @@ class ApiClient {
     intro_text: string;
     footer_text: string;
     primary_color: string;
+    show_consumption_chart: boolean;
   }> {
     return this.request(`/billing/layouts/${buildingId}`);
   }
@@ -788,6 +892,7 @@ class ApiClient {
     intro_text: string;
     footer_text: string;
     primary_color: string;
+    show_consumption_chart: boolean;
   }): Promise<any> {
     return this.request(`/billing/layouts/${buildingId}`, {
       method: 'PUT',
@@ -795,6 +900,49 @@ class ApiClient {
     });
   }
 
+  // Invoice templates (per building, html/template source; built-in fallback)
+  async getInvoiceTemplate(buildingId: number): Promise<{
+    building_id: number;
+    name: string;
+    content: string;
+    custom: boolean;
+    updated_at: string;
+  }> {
+    return this.request(`/billing/templates/${buildingId}`);
+  }
+
+  // warning is set while the native PDF renderer ignores custom templates.
+  async updateInvoiceTemplate(buildingId: number, template: { name: string; content: string }): Promise<{
+    building_id: number;
+    name: string;
+    content: string;
+    custom: boolean;
+    warning?: string;
+  }> {
+    return this.request(`/billing/templates/${buildingId}`, {
+      method: 'PUT',
+      body: JSON.stringify(template),
+    });
+  }
+
+  async deleteInvoiceTemplate(buildingId: number): Promise<void> {
+    await this.request(`/billing/templates/${buildingId}`, { method: 'DELETE' });
+  }
+
+  // Renders a sample invoice with the template and returns the HTML.
+  async previewInvoiceTemplate(buildingId: number, content: string, language: string = 'de'): Promise<string> {
+    const response = await fetch(`${API_BASE}/billing/templates/${buildingId}/preview`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'Authorization': `Bearer ${this.token}`,
+      },
+      body: JSON.stringify({ content, language }),
+    });
+    if (!response.ok) throw new Error((await response.text()) || 'Preview failed');
+    return response.text();
+  }
+
   // Dashboard
   async getDashboardStats(): Promise<DashboardStats> {
     return this.request('/dashboard/stats');
@@ -1007,6 +1155,7 @@ class ApiClient {
   async portalInvoices(): Promise<Array<{
     id: number; invoice_number: string; period_start: string; period_end: string;
     total_amount: number; currency: string; status: string; has_pdf: boolean;
+    document_type: string; original_invoice_number?: string;
   }>> {
     return this.portalRequest('/invoices');
   }
@@ -1017,6 +1166,19 @@ class ApiClient {
     return this.portalRequest('/charging');
   }
 
+  async portalStatement(from: string, to: string): Promise<AccountStatement[]> {
+    return this.portalRequest(`/statement?${this.statementQuery({ from, to })}`);
+  }
+
+  async portalDownloadStatement(from: string, to: string): Promise<void> {
+    const token = localStorage.getItem('portal_token');
+    const res = await fetch(`${API_BASE}/portal/statement?${this.statementQuery({ from, to })}&format=pdf`, {
+      headers: { 'Authorization': `Bearer ${token}` },
+    });
+    if (!res.ok) throw new Error('Download failed');
+    this.saveBlob(await res.blob(), `statement-${to}.pdf`);
+  }
+
   async portalDownloadInvoice(id: number, invoiceNumber: string): Promise<void> {
     const token = localStorage.getItem('portal_token');
     const res = await fetch(`${API_BASE}/portal/invoices/${id}/pdf`, {
@@ -0,0 +1,214 @@
+import { useEffect, useState } from 'react';
+import { BookOpen, Download, RefreshCw } from 'lucide-react';
+import { api } from '../api/client';
+import { useTranslation } from '../i18n';
+import { notify } from '../utils/toast';
+import { formatDate } from './billing/utils/billingUtils';
+import type { AccountStatement, Building, User } from '../types';
+
+interface AccountStatementsProps {
+  selectedBuildingId: number | null;
+  buildings: Building[];
+  users: User[];
+}
+
+const isoDate = (d: Date) =>
+  `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`;
+
+/**
+ * Account statements: every document, reminder fee and payment of a tenant,
+ * or of all tenants of the selected building, with the running balance.
+ */
+export default function AccountStatements({ selectedBuildingId, buildings, users }: AccountStatementsProps) {
+  const { t } = useTranslation();
+  const [userId, setUserId] = useState<number | null>(null);
+  const [from, setFrom] = useState(() => `${new Date().getFullYear()}-01-01`);
+  const [to, setTo] = useState(() => isoDate(new Date()));
+  const [statements, setStatements] = useState<AccountStatement[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [downloading, setDownloading] = useState(false);
+
+  const tenants = users.filter(u =>
+    u.user_type === 'regular' && (!selectedBuildingId || u.building_id === selectedBuildingId)
+  );
+  const params = userId
+    ? { user_id: userId, from, to }
+    : selectedBuildingId ? { building_id: selectedBuildingId, from, to } : null;
+
+  // A tenant chosen in another building no longer belongs to the filter.
+  useEffect(() => {
+    if (userId && !tenants.some(u => u.id === userId)) setUserId(null);
+  }, [selectedBuildingId]);
+
+  const load = async () => {
+    if (!params) {
+      setStatements([]);
+      return;
+    }
+    setLoading(true);
+    try {
+      setStatements(await api.getAccountStatements(params) ?? []);
+    } catch (err) {
+      notify.error(`${t('statements.loadFailed')}: ${(err as Error).message}`);
+      setStatements([]);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [userId, selectedBuildingId, from, to]);
+
+  const download = async () => {
+    if (!params) return;
+    setDownloading(true);
+    try {
+      const name = userId ? `statement-user-${userId}` : `statement-building-${selectedBuildingId}`;
+      await api.downloadAccountStatementsPDF(params, `${name}-${to}.pdf`);
+    } catch (err) {
+      notify.error(`${t('statements.downloadFailed')}: ${(err as Error).message}`);
+    } finally {
+      setDownloading(false);
+    }
+  };
+
+  const buildingName = (id: number) => buildings.find(b => b.id === id)?.name ?? '';
+  const inputStyle: React.CSSProperties = {
+    padding: '9px 12px', border: '1px solid #e5e7eb', borderRadius: '10px', fontSize: '14px',
+    backgroundColor: 'white', outline: 'none'
+  };
+  const th: React.CSSProperties = {
+    padding: '10px 14px', fontWeight: 600, fontSize: '12px', color: '#6b7280',
+    textTransform: 'uppercase', letterSpacing: '0.5px', textAlign: 'left'
+  };
+  const td: React.CSSProperties = { padding: '10px 14px', fontSize: '13px', color: '#374151' };
+  const amount = (v: number) => (v ? v.toFixed(2) : '');
+
+  return (
+    <div>
+      {/* Filters */}
+      <div style={{ display: 'flex', gap: '10px', flexWrap: 'wrap', alignItems: 'flex-end', marginBottom: '20px' }}>
+        <label style={{ display: 'flex', flexDirection: 'column', gap: '4px', fontSize: '12px', color: '#6b7280', fontWeight: 600 }}>
+          {t('statements.tenant')}
+          <select
+            value={userId ?? ''}
+            onChange={(e) => setUserId(e.target.value ? Number(e.target.value) : null)}
+            style={{ ...inputStyle, minWidth: '240px' }}
+          >
+            <option value="">{selectedBuildingId ? t('statements.allTenants') : t('statements.chooseTenant')}</option>
+            {tenants.map(u => (
+              <option key={u.id} value={u.id}>
+                {u.first_name} {u.last_name}{u.apartment_unit ? ` (${u.apartment_unit})` : ''}{!u.is_active ? ` – ${t('billing.archived')}` : ''}
+              </option>
+            ))}
+          </select>
+        </label>
+        <label style={{ display: 'flex', flexDirection: 'column', gap: '4px', fontSize: '12px', color: '#6b7280', fontWeight: 600 }}>
+          {t('statements.from')}
+          <input type="date" value={from} max={to} onChange={(e) => setFrom(e.target.value)} style={inputStyle} />
+        </label>
+        <label style={{ display: 'flex', flexDirection: 'column', gap: '4px', fontSize: '12px', color: '#6b7280', fontWeight: 600 }}>
+          {t('statements.to')}
+          <input type="date" value={to} min={from} onChange={(e) => setTo(e.target.value)} style={inputStyle} />
+        </label>
+        <button
+          onClick={load}
+          disabled={!params || loading}
+          title={t('statements.refresh')}
+          style={{ ...inputStyle, display: 'flex', alignItems: 'center', gap: '6px', color: '#667eea', cursor: params ? 'pointer' : 'not-allowed' }}
+        >
+          <RefreshCw size={15} />
+        </button>
+        <button
+          onClick={download}
+          disabled={!params || downloading || statements.length === 0}
+          style={{
+            display: 'flex', alignItems: 'center', gap: '8px', padding: '10px 18px', border: 'none', borderRadius: '10px',
+            backgroundColor: statements.length > 0 ? '#667eea' : '#9ca3af', color: 'white', fontSize: '14px', fontWeight: 600,
+            cursor: statements.length > 0 && !downloading ? 'pointer' : 'not-allowed'
+          }}
+        >
+          <Download size={16} />
+          {t('statements.downloadPdf')}
+        </button>
+      </div>
+
+      {!params && (
+        <div style={{ textAlign: 'center', padding: '40px', color: '#9ca3af', backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb' }}>
+          {t('statements.selectHint')}
+        </div>
+      )}
+
+      {params && !loading && statements.length === 0 && (
+        <div style={{ textAlign: 'center', padding: '40px', color: '#9ca3af', backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb' }}>
+          {t('statements.empty')}
+        </div>
+      )}
+
+      {statements.map(s => (
+        <div key={s.user_id} style={{
+          backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb',
+          marginBottom: '16px', overflow: 'hidden', boxShadow: '0 1px 3px rgba(0,0,0,0.06)'
+        }}>
+          <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', padding: '14px 16px', borderBottom: '1px solid #f3f4f6', flexWrap: 'wrap', gap: '8px' }}>
+            <div style={{ display: 'flex', alignItems: 'center', gap: '10px' }}>
+              <BookOpen size={18} color="#667eea" />
+              <div>
+                <div style={{ fontWeight: 700, color: '#1f2937' }}>{s.user_name}</div>
+                <div style={{ fontSize: '12px', color: '#9ca3af' }}>
+                  {[buildingName(s.building_id), `${formatDate(s.from)} – ${formatDate(s.to)}`].filter(Boolean).join(' · ')}
+                </div>
+              </div>
+            </div>
+            <div style={{ fontSize: '14px', fontWeight: 700, color: s.closing_balance > 0.005 ? '#b91c1c' : '#166534' }}>
+              {t('statements.closing')}: {s.currency} {s.closing_balance.toFixed(2)}
+            </div>
+          </div>
+          <div style={{ overflowX: 'auto' }}>
+            <table style={{ width: '100%', borderCollapse: 'collapse' }}>
+              <thead>
+                <tr style={{ backgroundColor: '#f9fafb' }}>
+                  <th style={th}>{t('statements.date')}</th>
+                  <th style={th}>{t('statements.reference')}</th>
+                  <th style={th}>{t('statements.description')}</th>
+                  <th style={{ ...th, textAlign: 'right' }}>{t('statements.debit')}</th>
+                  <th style={{ ...th, textAlign: 'right' }}>{t('statements.credit')}</th>
+                  <th style={{ ...th, textAlign: 'right' }}>{t('statements.balance')}</th>
+                </tr>
+              </thead>
+              <tbody>
+                <tr style={{ borderTop: '1px solid #f3f4f6' }}>
+                  <td style={td}>{formatDate(s.from)}</td>
+                  <td style={td} />
+                  <td style={{ ...td, fontStyle: 'italic' }}>{t('statements.opening')}</td>
+                  <td style={td} />
+                  <td style={td} />
+                  <td style={{ ...td, textAlign: 'right', fontWeight: 600 }}>{s.opening_balance.toFixed(2)}</td>
+                </tr>
+                {s.lines.map((line, i) => (
+                  <tr key={i} style={{ borderTop: '1px solid #f3f4f6' }}>
+                    <td style={td}>{formatDate(line.date)}</td>
+                    <td style={{ ...td, fontFamily: 'monospace', color: '#6b7280' }}>{line.reference}</td>
+                    <td style={td}>{line.description}</td>
+                    <td style={{ ...td, textAlign: 'right' }}>{amount(line.debit)}</td>
+                    <td style={{ ...td, textAlign: 'right' }}>{amount(line.credit)}</td>
+                    <td style={{ ...td, textAlign: 'right', fontWeight: 600 }}>{line.balance.toFixed(2)}</td>
+                  </tr>
+                ))}
+                <tr style={{ borderTop: '2px solid #e5e7eb', backgroundColor: '#f9fafb' }}>
+                  <td style={td} />
+                  <td style={td} />
+                  <td style={{ ...td, fontWeight: 700 }}>{t('statements.closing')}</td>
+                  <td style={{ ...td, textAlign: 'right', fontWeight: 600 }}>{s.total_debit.toFixed(2)}</td>
+                  <td style={{ ...td, textAlign: 'right', fontWeight: 600 }}>{s.total_credit.toFixed(2)}</td>
+                  <td style={{ ...td, textAlign: 'right', fontWeight: 700 }}>{s.closing_balance.toFixed(2)}</td>
+                </tr>
+              </tbody>
+            </table>
+          </div>
+        </div>
+      ))}
+    </div>
+  );
+}
@@ -10,6 +10,7 @@ import AutoBillingConfigModal from './autobilling/components/AutoBillingConfigMo
 import AutoBillingInstructionsModal from './autobilling/components/AutoBillingInstructionsModal';
 import AutoBillingEmptyState from './autobilling/components/AutoBillingEmptyState';
 import AutoBillingTestRunModal from './autobilling/components/AutoBillingTestRunModal';
+import AutoBillingReviewQueue from './autobilling/components/AutoBillingReviewQueue';
 import type { TestRunResult } from './autobilling/components/AutoBillingTestRunModal';
 import BillLayoutEditor from './BillLayoutEditor';
 import type { AutoBillingConfig } from './autobilling/hooks/useAutoBillingConfig';
@@ -22,6 +23,8 @@ export default function AutoBilling() {
   const [testRunModalOpen, setTestRunModalOpen] = useState(false);
   const [testRunResult, setTestRunResult] = useState<TestRunResult | null>(null);
   const [testRunError, setTestRunError] = useState<string>('');
+  // Bumped after a test run so the review queue picks up new drafts.
+  const [reviewQueueKey, setReviewQueueKey] = useState(0);
   // building filter (matches Meters/Chargers/Devices)
   const [selectedBuildingId, setSelectedBuildingId] = useState<number | null>(null);
   const [searchQuery, setSearchQuery] = useState('');
@@ -126,6 +129,7 @@ export default function AutoBilling() {
       setTestRunError(message || t('autoBilling.testRunFailed'));
     } finally {
       setTestRunningId(null);
+      setReviewQueueKey((k) => k + 1);
     }
   };
 
@@ -242,6 +246,8 @@ export default function AutoBilling() {
             </div>
           )}
 
+          <AutoBillingReviewQueue configs={configs} selectedBuildingId={selectedBuildingId} reloadKey={reviewQueueKey} />
+
           {filteredConfigs.length === 0 ? (
             <div className="app-fade-in" style={{ textAlign: 'center', padding: '40px', color: '#9ca3af', backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb' }}>
               {t('autoBilling.emptyBuilding')}
@@ -0,0 +1,216 @@
+import { useEffect, useRef, useState } from 'react';
+import { Landmark, Upload, Link2, EyeOff, RefreshCw } from 'lucide-react';
+import { api } from '../api/client';
+import { useTranslation } from '../i18n';
+import { notify } from '../utils/toast';
+import { formatDate } from './billing/utils/billingUtils';
+import type { BankTransaction, Invoice, User } from '../types';
+
+interface BankPaymentsProps {
+  selectedBuildingId: number | null;
+  users: User[];
+}
+
+/**
+ * Bank statement import and the review queue of credits that could not be
+ * matched to an invoice: each is assigned to an open invoice by hand or
+ * ignored (refunds, payments that do not belong to billing).
+ */
+export default function BankPayments({ selectedBuildingId, users }: BankPaymentsProps) {
+  const { t } = useTranslation();
+  const [transactions, setTransactions] = useState<BankTransaction[]>([]);
+  const [invoices, setInvoices] = useState<Invoice[]>([]);
+  const [choice, setChoice] = useState<Record<number, number>>({});
+  const [busyId, setBusyId] = useState<number | null>(null);
+  const [importing, setImporting] = useState(false);
+  const [loading, setLoading] = useState(true);
+  const fileInput = useRef<HTMLInputElement>(null);
+
+  const load = async () => {
+    setLoading(true);
+    try {
+      const [txs, invs] = await Promise.all([
+        api.getBankTransactions('unmatched'),
+        api.getInvoices(undefined, selectedBuildingId ?? undefined)
+      ]);
+      setTransactions((txs ?? []).filter(tx => tx.credit_debit === 'CRDT'));
+      setInvoices(invs ?? []);
+    } catch (err) {
+      notify.error(`${t('bankPayments.loadFailed')}: ${(err as Error).message}`);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [selectedBuildingId]);
+
+  // Only issued invoices with an open amount can take a payment.
+  const openInvoices = invoices.filter(inv =>
+    inv.status === 'issued' &&
+    (inv.document_type ?? 'invoice') !== 'credit_note' &&
+    inv.payment_status !== 'paid'
+  );
+  const openAmount = (inv: Invoice) => inv.total_amount - (inv.paid_amount ?? 0);
+  const userName = (id: number) => {
+    const u = users.find(u => u.id === id);
+    return u ? `${u.first_name} ${u.last_name}` : `#${id}`;
+  };
+  // Closest open amount first, so the likely invoice is preselected.
+  const candidates = (tx: BankTransaction) =>
+    [...openInvoices].sort((a, b) => Math.abs(openAmount(a) - tx.amount) - Math.abs(openAmount(b) - tx.amount));
+
+  const handleImport = async (file: File) => {
+    setImporting(true);
+    try {
+      const result = await api.importBankStatement(file);
+      notify.success(t('bankPayments.imported')
+        .replace('{count}', String(result.import.transactions))
+        .replace('{matched}', String(result.import.matched))
+        .replace('{unmatched}', String(result.import.unmatched))
+        .replace('{duplicates}', String(result.import.duplicates)));
+      await load();
+    } catch (err) {
+      notify.error(`${t('bankPayments.importFailed')}: ${(err as Error).message}`);
+    } finally {
+      setImporting(false);
+      if (fileInput.current) fileInput.current.value = '';
+    }
+  };
+
+  const handleAssign = async (tx: BankTransaction) => {
+    const invoiceId = choice[tx.id] ?? candidates(tx)[0]?.id;
+    if (!invoiceId) return;
+    setBusyId(tx.id);
+    try {
+      await api.assignBankTransaction(tx.id, invoiceId);
+      notify.success(t('bankPayments.assigned'));
+      await load();
+    } catch (err) {
+      notify.error(`${t('bankPayments.assignFailed')}: ${(err as Error).message}`);
+    } finally {
+      setBusyId(null);
+    }
+  };
+
+  const handleIgnore = async (tx: BankTransaction) => {
+    const note = prompt(t('bankPayments.ignorePrompt'));
+    if (note === null) return;
+    setBusyId(tx.id);
+    try {
+      await api.ignoreBankTransaction(tx.id, note);
+      notify.success(t('bankPayments.ignored'));
+      await load();
+    } catch (err) {
+      notify.error(`${t('bankPayments.ignoreFailed')}: ${(err as Error).message}`);
+    } finally {
+      setBusyId(null);
+    }
+  };
+
+  const buttonStyle: React.CSSProperties = {
+    display: 'flex', alignItems: 'center', gap: '6px', padding: '8px 12px', borderRadius: '8px',
+    fontSize: '13px', fontWeight: 600, cursor: 'pointer', whiteSpace: 'nowrap'
+  };
+
+  return (
+    <div>
+      <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', gap: '10px', flexWrap: 'wrap', marginBottom: '16px' }}>
+        <div>
+          <div style={{ fontWeight: 700, color: '#1f2937', fontSize: '16px' }}>
+            {t('bankPayments.title')} ({transactions.length})
+          </div>
+          <div style={{ fontSize: '13px', color: '#6b7280' }}>{t('bankPayments.description')}</div>
+        </div>
+        <div style={{ display: 'flex', gap: '8px' }}>
+          <button onClick={load} title={t('statements.refresh')} style={{ ...buttonStyle, border: '1px solid #e5e7eb', backgroundColor: 'white', color: '#667eea' }}>
+            <RefreshCw size={15} />
+          </button>
+          <input
+            ref={fileInput}
+            type="file"
+            accept=".xml,application/xml,text/xml"
+            style={{ display: 'none' }}
+            onChange={(e) => { const f = e.target.files?.[0]; if (f) handleImport(f); }}
+          />
+          <button
+            onClick={() => fileInput.current?.click()}
+            disabled={importing}
+            style={{ ...buttonStyle, border: 'none', backgroundColor: importing ? '#9ca3af' : '#667eea', color: 'white', cursor: importing ? 'not-allowed' : 'pointer' }}
+          >
+            <Upload size={15} />
+            {importing ? t('bankPayments.importing') : t('bankPayments.import')}
+          </button>
+        </div>
+      </div>
+
+      {!loading && transactions.length === 0 && (
+        <div style={{ textAlign: 'center', padding: '40px', color: '#9ca3af', backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb' }}>
+          {t('bankPayments.empty')}
+        </div>
+      )}
+
+      {transactions.length > 0 && (
+        <div style={{ backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb', overflow: 'hidden', boxShadow: '0 1px 3px rgba(0,0,0,0.06)' }}>
+          {transactions.map((tx, i) => {
+            const options = candidates(tx);
+            const selected = choice[tx.id] ?? options[0]?.id;
+            return (
+              <div key={tx.id} style={{
+                display: 'flex', alignItems: 'center', gap: '14px', padding: '14px 16px', flexWrap: 'wrap',
+                borderTop: i > 0 ? '1px solid #f3f4f6' : 'none'
+              }}>
+                <Landmark size={18} color="#667eea" style={{ flexShrink: 0 }} />
+                <div style={{ flex: 1, minWidth: '220px' }}>
+                  <div style={{ fontWeight: 600, color: '#1f2937', fontSize: '14px' }}>
+                    {tx.debtor_name || t('bankPayments.unknownDebtor')}
+                    <span style={{ color: '#9ca3af', fontWeight: 500, fontSize: '12px' }}> · {formatDate(tx.booking_date)}</span>
+                  </div>
+                  <div style={{ fontSize: '12px', color: '#6b7280', fontFamily: tx.reference ? 'monospace' : undefined }}>
+                    {tx.reference || tx.remittance_info || tx.debtor_iban || '—'}
+                  </div>
+                  {tx.reference && tx.remittance_info && (
+                    <div style={{ fontSize: '12px', color: '#9ca3af' }}>{tx.remittance_info}</div>
+                  )}
+                </div>
+                <div style={{ fontWeight: 700, color: '#16a34a', fontSize: '15px', whiteSpace: 'nowrap' }}>
+                  {tx.currency} {tx.amount.toFixed(2)}
+                </div>
+                <select
+                  value={selected ?? ''}
+                  onChange={(e) => setChoice({ ...choice, [tx.id]: Number(e.target.value) })}
+                  disabled={options.length === 0}
+                  style={{ padding: '8px 10px', border: '1px solid #e5e7eb', borderRadius: '8px', fontSize: '13px', maxWidth: '280px' }}
+                >
+                  {options.length === 0 && <option value="">{t('bankPayments.noOpenInvoices')}</option>}
+                  {options.map(inv => (
+                    <option key={inv.id} value={inv.id}>
+                      {inv.invoice_number} · {userName(inv.user_id)} · {inv.currency} {openAmount(inv).toFixed(2)}
+                    </option>
+                  ))}
+                </select>
+                <button
+                  onClick={() => handleAssign(tx)}
+                  disabled={busyId === tx.id || !selected}
+                  style={{ ...buttonStyle, border: '1px solid #bbf7d0', backgroundColor: '#f0fdf4', color: '#166534', cursor: busyId === tx.id || !selected ? 'not-allowed' : 'pointer' }}
+                >
+                  <Link2 size={14} />
+                  {t('bankPayments.assign')}
+                </button>
+                <button
+                  onClick={() => handleIgnore(tx)}
+                  disabled={busyId === tx.id}
+                  style={{ ...buttonStyle, border: '1px solid #e5e7eb', backgroundColor: 'white', color: '#6b7280', cursor: busyId === tx.id ? 'not-allowed' : 'pointer' }}
+                >
+                  <EyeOff size={14} />
+                  {t('bankPayments.ignore')}
+                </button>
+              </div>
+            );
+          })}
+        </div>
+      )}
+    </div>
+  );
+}
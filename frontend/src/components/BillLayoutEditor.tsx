@@ -16,6 +16,7 @@ interface LayoutForm {
   intro_text: string;
   footer_text: string;
   primary_color: string;
+  show_consumption_chart: boolean;
 }
 
 const DEFAULT_LAYOUT: LayoutForm = {
@@ -23,6 +24,7 @@ const DEFAULT_LAYOUT: LayoutForm = {
   intro_text: '',
   footer_text: '',
   primary_color: '#667EEA',
+  show_consumption_chart: false,
 };
 
 export default function BillLayoutEditor({
@@ -62,6 +64,7 @@ export default function BillLayoutEditor({
           intro_text: data.intro_text || '',
           footer_text: data.footer_text || '',
           primary_color: data.primary_color || '#667EEA',
+          show_consumption_chart: !!data.show_consumption_chart,
         });
       })
       .catch(() => {
@@ -270,6 +273,20 @@ export default function BillLayoutEditor({
                 {t('billLayout.footerHelp')}
               </p>
             </div>
+
+            <div style={{ marginBottom: '16px' }}>
+              <label style={{ display: 'flex', alignItems: 'center', gap: '8px', fontSize: '13px', fontWeight: 600, color: '#374151', cursor: 'pointer' }}>
+                <input
+                  type="checkbox"
+                  checked={form.show_consumption_chart}
+                  onChange={(e) => setForm({ ...form, show_consumption_chart: e.target.checked })}
+                />
+                {t('billLayout.chartField')}
+              </label>
+              <p style={{ fontSize: '11px', color: '#9ca3af', margin: '4px 0 0 0' }}>
+                {t('billLayout.chartHelp')}
+              </p>
+            </div>
           </div>
 
           {/* Mini preview */}
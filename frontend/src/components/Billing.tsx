@@ -4,17 +4,20 @@ import { useTranslation } from '../i18n';
 import { useBillingData } from './billing/hooks/useBillingData';
 import BuildingSelector from './billing/components/common/BuildingSelector';
 import ViewSwitcher from './billing/components/common/ViewSwitcher';
+import type { BillingView } from './billing/components/common/ViewSwitcher';
 import InstructionsModal from './billing/components/common/InstructionsModal';
 import BillConfiguration from './BillConfiguration';
 import SharedMeterConfig from './SharedMeterConfig';
 import CustomItems from './CustomItem';
+import AccountStatements from './AccountStatements';
+import BankPayments from './BankPayments';
 import Bills from './Bills';
 import ErrorBoundary from './billing/components/common/ErrorBoundary';
 import BillLayoutEditor from './BillLayoutEditor';
 
 /**
  * Main Billing module component
- * Manages invoices, shared meters, and custom items
+ * Manages invoices, shared meters, custom items, account statements and bank payments
  * Wrapped with ErrorBoundary for graceful error handling
  */
 export default function Billing() {
@@ -25,7 +28,7 @@ export default function Billing() {
   const [showInstructions, setShowInstructions] = useState(false);
   const [showAdvancedConfig, setShowAdvancedConfig] = useState(false);
   const [showLayoutEditor, setShowLayoutEditor] = useState(false);
-  const [currentView, setCurrentView] = useState<'invoices' | 'shared-meters' | 'custom-items'>('invoices');
+  const [currentView, setCurrentView] = useState<BillingView>('invoices');
   const [refreshKey, setRefreshKey] = useState(0);
   const [isMobile] = useState(() => window.innerWidth <= 768);
 
@@ -231,6 +234,14 @@ export default function Billing() {
           {currentView === 'custom-items' && (
             <CustomItems onSave={refresh} selectedBuildingId={selectedBuildingId} />
           )}
+
+          {currentView === 'statements' && (
+            <AccountStatements selectedBuildingId={selectedBuildingId} buildings={buildings} users={users} />
+          )}
+
+          {currentView === 'payments' && (
+            <BankPayments selectedBuildingId={selectedBuildingId} users={users} />
+          )}
         </div>
 
         {/* Modals */}
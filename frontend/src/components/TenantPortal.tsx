@@ -3,19 +3,22 @@ import { api } from '../api/client';
 import { useTranslation } from '../i18n';
 import {
   FileText, Zap, Sun, LogOut, Download, KeyRound, AlertTriangle,
-  Battery, Clock, LayoutDashboard, Activity, Building2,
+  Battery, Clock, LayoutDashboard, Activity, Building2, BookOpen,
 } from 'lucide-react';
+import type { AccountStatement } from '../types';
+import { documentTypeLabel, chainNote } from './billing/utils/billingUtils';
 
 interface Me { name: string; email: string; apartment: string; building: string; }
 interface Invoice {
   id: number; invoice_number: string; period_start: string; period_end: string;
   total_amount: number; currency: string; status: string; has_pdf: boolean;
+  document_type: string; original_invoice_number?: string;
 }
 interface ChargingSession {
   start_time: string; end_time: string; total_kwh: number; solar_kwh: number; grid_kwh: number;
 }
 
-type Tab = 'invoices' | 'charging' | 'consumption' | 'live';
+type Tab = 'invoices' | 'statement' | 'charging' | 'consumption' | 'live';
 
 const fmtDate = (s: string) => {
   const d = new Date(s);
@@ -26,6 +29,8 @@ const fmtDateTime = (s: string) => {
   if (isNaN(d.getTime()) || d.getFullYear() < 1972) return '—';
   return d.toLocaleString(undefined, { day: '2-digit', month: 'short', hour: '2-digit', minute: '2-digit', hour12: false });
 };
+const isoDate = (d: Date) =>
+  `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`;
 
 export default function TenantPortal() {
   const { t } = useTranslation();
@@ -39,6 +44,9 @@ export default function TenantPortal() {
 
   const [invoices, setInvoices] = useState<Invoice[]>([]);
   const [charging, setCharging] = useState<ChargingSession[]>([]);
+  const [statement, setStatement] = useState<AccountStatement | null>(null);
+  const [stmtFrom, setStmtFrom] = useState(() => `${new Date().getFullYear()}-01-01`);
+  const [stmtTo, setStmtTo] = useState(() => isoDate(new Date()));
 
   const logout = useCallback(() => {
     localStorage.removeItem('portal_token');
@@ -46,6 +54,7 @@ export default function TenantPortal() {
     setMe(null);
     setInvoices([]);
     setCharging([]);
+    setStatement(null);
   }, []);
 
   const doLogin = useCallback(async (c: string) => {
@@ -84,6 +93,14 @@ export default function TenantPortal() {
     })();
   }, [token, logout]);
 
+  // The statement is loaded when its tab is opened and whenever the period changes.
+  useEffect(() => {
+    if (!token || tab !== 'statement' || !stmtFrom || !stmtTo || stmtFrom > stmtTo) return;
+    api.portalStatement(stmtFrom, stmtTo)
+      .then((res) => setStatement(res?.[0] ?? null))
+      .catch(() => setStatement(null));
+  }, [token, tab, stmtFrom, stmtTo]);
+
   // --- Login screen ---
   if (!token || !me) {
     return (
@@ -124,6 +141,7 @@ export default function TenantPortal() {
 
   const tabs: { key: Tab; label: string; Icon: typeof FileText }[] = [
     { key: 'invoices', label: t('portal.tabInvoices'), Icon: FileText },
+    { key: 'statement', label: t('portal.tabStatement'), Icon: BookOpen },
     { key: 'charging', label: t('portal.tabCharging'), Icon: Zap },
     { key: 'consumption', label: t('portal.tabConsumption'), Icon: LayoutDashboard },
     { key: 'live', label: t('portal.tabLive'), Icon: Activity },
@@ -171,8 +189,19 @@ export default function TenantPortal() {
               <div key={inv.id} style={cardStyle}>
                 <FileText size={18} color="#667eea" style={{ flexShrink: 0 }} />
                 <div style={{ flex: 1, minWidth: 0 }}>
-                  <div style={{ fontSize: 14, fontWeight: 700, color: '#1f2937' }}>{inv.invoice_number}</div>
+                  <div style={{ fontSize: 14, fontWeight: 700, color: '#1f2937', display: 'flex', alignItems: 'center', gap: 7, flexWrap: 'wrap' }}>
+                    {inv.invoice_number}
+                    {inv.document_type !== 'invoice' && (
+                      <Chip icon={null} bg="#ede9fe" color="#5b21b6" text={documentTypeLabel(inv.document_type, t)} />
+                    )}
+                    {inv.status === 'cancelled' && (
+                      <Chip icon={null} bg="#fee2e2" color="#991b1b" text={t('portal.cancelled')} />
+                    )}
+                  </div>
                   <div style={{ fontSize: 12, color: '#9ca3af' }}>{fmtDate(inv.period_start)} – {fmtDate(inv.period_end)}</div>
+                  {inv.original_invoice_number && (
+                    <div style={{ fontSize: 12, color: '#7c3aed' }}>{chainNote(inv, t)}</div>
+                  )}
                 </div>
                 <div style={{ fontSize: 15, fontWeight: 700, color: '#1f2937', whiteSpace: 'nowrap' }}>
                   {inv.total_amount.toFixed(2)} {inv.currency}
@@ -191,6 +220,47 @@ export default function TenantPortal() {
           </div>
         )}
 
+        {tab === 'statement' && (
+          <div style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
+            <div style={{ ...cardStyle, flexWrap: 'wrap' }}>
+              <input type="date" value={stmtFrom} max={stmtTo} onChange={(e) => setStmtFrom(e.target.value)} style={dateInputStyle} />
+              <span style={{ color: '#9ca3af' }}>–</span>
+              <input type="date" value={stmtTo} min={stmtFrom} onChange={(e) => setStmtTo(e.target.value)} style={dateInputStyle} />
+              <div style={{ flex: 1 }} />
+              {statement && (
+                <button
+                  onClick={() => api.portalDownloadStatement(stmtFrom, stmtTo).catch(() => setError(t('portal.downloadFailed')))}
+                  title={t('common.download')}
+                  style={iconBtnStyle}
+                >
+                  <Download size={15} />
+                </button>
+              )}
+            </div>
+            {!statement && <Empty text={t('statements.empty')} />}
+            {statement && (
+              <div style={{ ...cardStyle, flexDirection: 'column', alignItems: 'stretch', gap: 0, padding: 0, overflow: 'hidden' }}>
+                <StatementRow date={fmtDate(statement.from)} text={t('statements.opening')} balance={statement.opening_balance} muted />
+                {statement.lines.map((line, i) => (
+                  <StatementRow
+                    key={i}
+                    date={fmtDate(line.date)}
+                    text={[line.reference, line.description].filter(Boolean).join(' · ')}
+                    amount={line.credit ? -line.credit : line.debit}
+                    balance={line.balance}
+                  />
+                ))}
+                <StatementRow date={fmtDate(statement.to)} text={t('statements.closing')} balance={statement.closing_balance} bold />
+              </div>
+            )}
+            {statement && (
+              <div style={{ fontSize: 12, color: '#9ca3af', textAlign: 'right' }}>
+                {t('portal.statementHint').replace('{currency}', statement.currency)}
+              </div>
+            )}
+          </div>
+        )}
+
         {tab === 'charging' && (
           <div style={{ display: 'flex', flexDirection: 'column', gap: 10 }}>
             {charging.length === 0 && <Empty text={t('portal.noCharging')} />}
@@ -231,6 +301,27 @@ const iconBtnStyle: React.CSSProperties = {
   display: 'flex', alignItems: 'center', justifyContent: 'center', cursor: 'pointer', color: '#667eea', flexShrink: 0,
 };
 
+const dateInputStyle: React.CSSProperties = {
+  padding: '7px 10px', border: '1px solid #e5e7eb', borderRadius: 8, fontSize: 13, outline: 'none', background: 'white',
+};
+
+// StatementRow is one line of the account statement: a positive amount is
+// owed, a negative one paid or credited.
+function StatementRow({ date, text, amount, balance, muted, bold }: {
+  date: string; text: string; amount?: number; balance: number; muted?: boolean; bold?: boolean;
+}) {
+  return (
+    <div style={{ display: 'flex', alignItems: 'center', gap: 12, padding: '10px 14px', borderTop: '1px solid #f3f4f6', fontSize: 13, background: bold ? '#f9fafb' : 'white' }}>
+      <div style={{ width: 92, flexShrink: 0, color: '#9ca3af', fontSize: 12 }}>{date}</div>
+      <div style={{ flex: 1, minWidth: 0, color: muted ? '#9ca3af' : '#1f2937', fontStyle: muted ? 'italic' : 'normal', fontWeight: bold ? 700 : 500 }}>{text}</div>
+      <div style={{ width: 80, textAlign: 'right', color: amount !== undefined && amount < 0 ? '#16a34a' : '#1f2937' }}>
+        {amount ? amount.toFixed(2) : ''}
+      </div>
+      <div style={{ width: 80, textAlign: 'right', fontWeight: 700, color: '#1f2937' }}>{balance.toFixed(2)}</div>
+    </div>
+  );
+}
+
 function Empty({ text }: { text: string }) {
   return <div style={{ textAlign: 'center', color: '#9ca3af', padding: '40px 0', fontSize: 14 }}>{text}</div>;
 }
@@ -225,11 +225,13 @@ export default function AutoBillingConfigModal({
                 generationDay={formData.generation_day}
                 firstExecutionDate={formData.first_execution_date}
                 autoSendEmail={!!formData.auto_send_email}
+                requireApproval={!!formData.require_approval}
                 onNameChange={(value) => onFormDataChange({ name: value })}
                 onFrequencyChange={(value) => onFormDataChange({ frequency: value })}
                 onGenerationDayChange={(value) => onFormDataChange({ generation_day: value })}
                 onFirstExecutionDateChange={(value) => onFormDataChange({ first_execution_date: value })}
                 onAutoSendEmailChange={(value) => onFormDataChange({ auto_send_email: value })}
+                onRequireApprovalChange={(value) => onFormDataChange({ require_approval: value })}
               />
             )}
             {step === 3 && (
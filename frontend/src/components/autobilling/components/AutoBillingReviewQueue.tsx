@@ -0,0 +1,166 @@
+import { useEffect, useState } from 'react';
+import { ClipboardCheck, CheckCircle, AlertTriangle, Loader2 } from 'lucide-react';
+import { api } from '../../../api/client';
+import { useTranslation } from '../../../i18n';
+import { notify } from '../../../utils/toast';
+import type { Invoice } from '../../../types';
+import type { AutoBillingConfig } from '../hooks/useAutoBillingConfig';
+
+interface AutoBillingReviewQueueProps {
+  configs: AutoBillingConfig[];
+  selectedBuildingId: number | null;
+  reloadKey: number;
+}
+
+/**
+ * Drafts generated by configs that require approval. Each can be approved on
+ * its own or together with others; approving numbers, issues and (when the
+ * config says so) e-mails them.
+ */
+export default function AutoBillingReviewQueue({ configs, selectedBuildingId, reloadKey }: AutoBillingReviewQueueProps) {
+  const { t } = useTranslation();
+  const [drafts, setDrafts] = useState<Invoice[]>([]);
+  const [selected, setSelected] = useState<Set<number>>(new Set());
+  const [approving, setApproving] = useState(false);
+
+  const load = async () => {
+    try {
+      setDrafts(await api.getReviewQueue() ?? []);
+    } catch (err) {
+      notify.error(`${t('autoBilling.reviewQueue.loadFailed')}: ${(err as Error).message}`);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [reloadKey]);
+
+  const visible = selectedBuildingId ? drafts.filter(d => d.building_id === selectedBuildingId) : drafts;
+  if (visible.length === 0) return null;
+
+  const selectedVisible = visible.filter(d => selected.has(d.id)).map(d => d.id);
+  const allSelected = selectedVisible.length === visible.length;
+
+  const toggle = (id: number) => {
+    const next = new Set(selected);
+    if (next.has(id)) next.delete(id); else next.add(id);
+    setSelected(next);
+  };
+
+  const approve = async (ids: number[]) => {
+    if (ids.length === 0) return;
+    if (!confirm(t('autoBilling.reviewQueue.approveConfirm').replace('{count}', String(ids.length)))) return;
+    setApproving(true);
+    try {
+      const result = await api.approveInvoices(ids);
+      notify.success(t('autoBilling.reviewQueue.approved')
+        .replace('{count}', String(result.approved))
+        .replace('{emails}', String(result.emails_sent)));
+      if (result.emails_failed > 0 || (result.warnings && result.warnings.length > 0)) {
+        notify.error([
+          result.emails_failed > 0 ? `${t('autoBilling.testRunEmailsFailed')}: ${result.emails_failed}` : '',
+          ...(result.warnings ?? [])
+        ].filter(Boolean).join('\n'));
+      }
+      setSelected(new Set());
+      await load();
+    } catch (err) {
+      notify.error(`${t('autoBilling.reviewQueue.approveFailed')}: ${(err as Error).message}`);
+    } finally {
+      setApproving(false);
+    }
+  };
+
+  const configName = (id?: number) => configs.find(c => c.id === id)?.name ?? '';
+  const formatDate = (s: string) => new Date(s).toLocaleDateString('de-CH');
+
+  return (
+    <div className="app-fade-in" style={{
+      backgroundColor: 'white', borderRadius: '14px', border: '1px solid #fde68a',
+      marginBottom: '24px', overflow: 'hidden', boxShadow: '0 1px 3px rgba(0,0,0,0.06)'
+    }}>
+      <div style={{
+        display: 'flex', justifyContent: 'space-between', alignItems: 'center', gap: '10px', flexWrap: 'wrap',
+        padding: '14px 18px', backgroundColor: '#fffbeb', borderBottom: '1px solid #fde68a'
+      }}>
+        <div style={{ display: 'flex', alignItems: 'center', gap: '10px' }}>
+          <ClipboardCheck size={20} color="#b45309" />
+          <div>
+            <div style={{ fontWeight: 700, color: '#92400e' }}>
+              {t('autoBilling.reviewQueue.title')} ({visible.length})
+            </div>
+            <div style={{ fontSize: '12px', color: '#b45309' }}>{t('autoBilling.reviewQueue.description')}</div>
+          </div>
+        </div>
+        <div style={{ display: 'flex', gap: '8px' }}>
+          <button
+            onClick={() => setSelected(allSelected ? new Set() : new Set(visible.map(d => d.id)))}
+            style={{ padding: '8px 14px', borderRadius: '8px', border: '1px solid #fde68a', backgroundColor: 'white', color: '#92400e', fontSize: '13px', fontWeight: 600, cursor: 'pointer' }}
+          >
+            {allSelected ? t('autoBilling.reviewQueue.deselectAll') : t('autoBilling.reviewQueue.selectAll')}
+          </button>
+          <button
+            onClick={() => approve(selectedVisible)}
+            disabled={approving || selectedVisible.length === 0}
+            style={{
+              display: 'flex', alignItems: 'center', gap: '6px', padding: '8px 14px', borderRadius: '8px', border: 'none',
+              backgroundColor: selectedVisible.length > 0 ? '#16a34a' : '#9ca3af', color: 'white', fontSize: '13px', fontWeight: 600,
+              cursor: approving || selectedVisible.length === 0 ? 'not-allowed' : 'pointer'
+            }}
+          >
+            {approving ? <Loader2 size={14} /> : <CheckCircle size={14} />}
+            {t('autoBilling.reviewQueue.approveSelected')} ({selectedVisible.length})
+          </button>
+        </div>
+      </div>
+
+      {visible.map((draft, i) => {
+        const name = draft.user ? `${draft.user.first_name} ${draft.user.last_name}` : `#${draft.user_id}`;
+        return (
+          <div key={draft.id} style={{
+            display: 'flex', alignItems: 'flex-start', gap: '12px', padding: '12px 18px',
+            borderTop: i > 0 ? '1px solid #f3f4f6' : 'none'
+          }}>
+            <input
+              type="checkbox"
+              checked={selected.has(draft.id)}
+              onChange={() => toggle(draft.id)}
+              style={{ width: '16px', height: '16px', marginTop: '3px', cursor: 'pointer' }}
+            />
+            <div style={{ flex: 1, minWidth: 0 }}>
+              <div style={{ fontWeight: 600, color: '#1f2937', fontSize: '14px' }}>
+                {name}
+                {draft.user?.apartment_unit && <span style={{ color: '#9ca3af', fontWeight: 500 }}> · {draft.user.apartment_unit}</span>}
+              </div>
+              <div style={{ fontSize: '12px', color: '#9ca3af' }}>
+                {formatDate(draft.period_start)} – {formatDate(draft.period_end)}
+                {configName(draft.auto_billing_config_id) && ` · ${configName(draft.auto_billing_config_id)}`}
+              </div>
+              {(draft.review_warnings ?? []).map((w, j) => (
+                <div key={j} style={{ display: 'flex', alignItems: 'center', gap: '6px', marginTop: '4px', fontSize: '12px', color: '#b45309' }}>
+                  <AlertTriangle size={12} />
+                  {w.message}
+                </div>
+              ))}
+            </div>
+            <div style={{ fontWeight: 700, color: '#1f2937', fontSize: '14px', whiteSpace: 'nowrap' }}>
+              {draft.currency} {draft.total_amount.toFixed(2)}
+            </div>
+            <button
+              onClick={() => approve([draft.id])}
+              disabled={approving}
+              style={{
+                display: 'flex', alignItems: 'center', gap: '5px', padding: '6px 12px', borderRadius: '8px',
+                border: '1px solid #bbf7d0', backgroundColor: '#f0fdf4', color: '#166534', fontSize: '12px', fontWeight: 600,
+                cursor: approving ? 'not-allowed' : 'pointer', whiteSpace: 'nowrap'
+              }}
+            >
+              <CheckCircle size={13} />
+              {t('autoBilling.reviewQueue.approve')}
+            </button>
+          </div>
+        );
+      })}
+    </div>
+  );
+}
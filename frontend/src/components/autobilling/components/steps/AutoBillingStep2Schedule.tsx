@@ -1,4 +1,4 @@
-import { Lightbulb, Mail } from 'lucide-react';
+import { Lightbulb, Mail, ClipboardCheck } from 'lucide-react';
 import { useTranslation } from '../../../../i18n';
 
 interface AutoBillingStep2ScheduleProps {
@@ -7,11 +7,13 @@ interface AutoBillingStep2ScheduleProps {
   generationDay: number;
   firstExecutionDate: string;
   autoSendEmail: boolean;
+  requireApproval: boolean;
   onNameChange: (value: string) => void;
   onFrequencyChange: (value: 'monthly' | 'quarterly' | 'half_yearly' | 'yearly') => void;
   onGenerationDayChange: (value: number) => void;
   onFirstExecutionDateChange: (value: string) => void;
   onAutoSendEmailChange: (value: boolean) => void;
+  onRequireApprovalChange: (value: boolean) => void;
 }
 
 export default function AutoBillingStep2Schedule({
@@ -20,11 +22,13 @@ export default function AutoBillingStep2Schedule({
   generationDay,
   firstExecutionDate,
   autoSendEmail,
+  requireApproval,
   onNameChange,
   onFrequencyChange,
   onGenerationDayChange,
   onFirstExecutionDateChange,
-  onAutoSendEmailChange
+  onAutoSendEmailChange,
+  onRequireApprovalChange
 }: AutoBillingStep2ScheduleProps) {
   const { t } = useTranslation();
 
@@ -137,6 +141,33 @@ export default function AutoBillingStep2Schedule({
         </label>
       </div>
 
+      {/* Approval before issuing */}
+      <div style={{
+        marginTop: '12px',
+        padding: '16px',
+        backgroundColor: '#fffbeb',
+        borderRadius: '8px',
+        border: '1px solid #fde68a'
+      }}>
+        <label style={{ display: 'flex', alignItems: 'flex-start', gap: '12px', cursor: 'pointer' }}>
+          <input
+            type="checkbox"
+            checked={requireApproval}
+            onChange={(e) => onRequireApprovalChange(e.target.checked)}
+            style={{ width: '18px', height: '18px', cursor: 'pointer', marginTop: '2px' }}
+          />
+          <div style={{ flex: 1 }}>
+            <div style={{ display: 'flex', alignItems: 'center', gap: '6px', fontWeight: 600, color: '#92400e', fontSize: '14px' }}>
+              <ClipboardCheck size={14} />
+              {t('autoBilling.requireApproval.toggle')}
+            </div>
+            <div style={{ fontSize: '12px', color: '#b45309', marginTop: '4px' }}>
+              {t('autoBilling.requireApproval.description')}
+            </div>
+          </div>
+        </label>
+      </div>
+
       {/* Info Box */}
       <div style={{
         marginTop: '20px',
@@ -18,6 +18,8 @@ export interface AutoBillingFormData {
   bill_content: BillContent;
   charger_id?: number;
   auto_send_email: boolean;
+  // Hold generated invoices as drafts in the review queue until approved.
+  require_approval: boolean;
   // Shared meters and custom items
   shared_meter_ids: number[];
   custom_item_ids: number[];
@@ -53,6 +55,7 @@ export interface AutoBillingConfig {
   bill_content?: BillContent;
   charger_id?: number;
   auto_send_email?: boolean;
+  require_approval?: boolean;
   shared_meter_ids?: number[];
   custom_item_ids?: number[];
   last_run?: string;
@@ -82,6 +85,7 @@ const DEFAULT_FORM_DATA: AutoBillingFormData = {
   bill_content: 'both',
   charger_id: undefined,
   auto_send_email: false,
+  require_approval: false,
   shared_meter_ids: [],
   custom_item_ids: [],
   sender_name: '',
@@ -583,6 +587,7 @@ export function useAutoBillingConfig() {
       bill_content: (config.bill_content as BillContent) || 'both',
       charger_id: config.charger_id,
       auto_send_email: !!config.auto_send_email,
+      require_approval: !!config.require_approval,
       shared_meter_ids: config.shared_meter_ids || [],
       custom_item_ids: config.custom_item_ids || [],
       sender_name: config.sender_name || '',
@@ -2,7 +2,7 @@ import { useState } from 'react';
 import { Eye, Download, Trash2, CheckCircle2, Circle } from 'lucide-react';
 import type { Invoice, User } from '../../../../types';
 import { useTranslation } from '../../../../i18n';
-import { formatDate, getStatusColor, paymentBadge } from '../../utils/billingUtils';
+import { formatDate, getStatusColor, paymentBadge, isDeletable, documentTypeLabel, chainNote } from '../../utils/billingUtils';
 import { api } from '../../../../api/client';
 import { notify } from '../../../../utils/toast';
 
@@ -24,6 +24,8 @@ export default function InvoiceCard({
   const { t } = useTranslation();
   const statusColors = getStatusColor(invoice.status);
   const isArchived = !user?.is_active;
+  const canDelete = isDeletable(invoice);
+  const note = chainNote(invoice, t);
   const userName = user ? `${user.first_name} ${user.last_name}` : '-';
 
   const [paymentStatus, setPaymentStatus] = useState(invoice.payment_status || 'unpaid');
@@ -69,7 +71,15 @@ export default function InvoiceCard({
           marginBottom: '4px'
         }}>
           {invoice.invoice_number}
+          {invoice.document_type && invoice.document_type !== 'invoice' && (
+            <span style={{ fontFamily: 'inherit', marginLeft: '6px', color: '#7c3aed', fontWeight: 600 }}>
+              {documentTypeLabel(invoice.document_type, t)}
+            </span>
+          )}
         </div>
+        {note && (
+          <div style={{ fontSize: '12px', color: '#7c3aed', marginBottom: '4px' }}>{note}</div>
+        )}
 
         <h3 style={{
           fontSize: '16px',
@@ -171,7 +181,7 @@ export default function InvoiceCard({
 
       <div style={{
         display: 'grid',
-        gridTemplateColumns: '1fr 1fr 1fr',
+        gridTemplateColumns: canDelete ? '1fr 1fr 1fr' : '1fr 1fr',
         gap: '8px',
         borderTop: '1px solid #f3f4f6',
         paddingTop: '12px'
@@ -223,7 +233,7 @@ export default function InvoiceCard({
           <span>{t('billing.pdfBtn')}</span>
         </button>
 
-        <button
+        {canDelete && <button
           onClick={() => onDelete(invoice.id)}
           title={t('billing.deleteBtn')}
           style={{
@@ -244,7 +254,7 @@ export default function InvoiceCard({
         >
           <Trash2 size={13} />
           <span>{t('billing.deleteBtn')}</span>
-        </button>
+        </button>}
       </div>
     </article>
   );
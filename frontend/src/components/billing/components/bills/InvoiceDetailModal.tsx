@@ -1,8 +1,8 @@
 import { useEffect, useRef } from 'react';
-import { ExternalLink, X, Zap, Sun, Car, Battery, AlertTriangle } from 'lucide-react';
+import { ExternalLink, X, Zap, Sun, Car, Battery, AlertTriangle, Link2 } from 'lucide-react';
 import type { Invoice } from '../../../../types';
 import { useTranslation } from '../../../../i18n';
-import { formatDate, getStatusColor } from '../../utils/billingUtils';
+import { formatDate, getStatusColor, documentTypeLabel, chainNote } from '../../utils/billingUtils';
 
 interface InvoiceDetailModalProps {
   invoice: Invoice;
@@ -17,6 +17,8 @@ export default function InvoiceDetailModal({
 }: InvoiceDetailModalProps) {
   const { t } = useTranslation();
   const statusColors = getStatusColor(invoice.status);
+  const note = chainNote(invoice, t);
+  const hasChain = !!note || !!invoice.cancelled_at || (invoice.related?.length ?? 0) > 0;
   const modalRef = useRef<HTMLDivElement>(null);
 
   useEffect(() => {
@@ -99,7 +101,9 @@ export default function InvoiceDetailModal({
           <div>
             <div style={{ display: 'flex', alignItems: 'center', gap: '10px', marginBottom: '6px' }}>
               <h2 id="invoice-modal-title" style={{ fontSize: '20px', fontWeight: '700', margin: 0, color: '#1f2937' }}>
-                {t('billing.invoice')}
+                {invoice.document_type && invoice.document_type !== 'invoice'
+                  ? documentTypeLabel(invoice.document_type, t)
+                  : t('billing.invoice')}
               </h2>
               <span style={{
                 padding: '4px 12px', borderRadius: '20px', fontSize: '11px', fontWeight: '700',
@@ -174,6 +178,40 @@ export default function InvoiceDetailModal({
             </div>
           </div>
 
+          {/* Cancellation chain */}
+          {hasChain && (
+            <div style={{
+              backgroundColor: 'white', padding: '16px', borderRadius: '12px', border: '1px solid #ddd6fe', marginBottom: '18px'
+            }}>
+              <h3 style={{ display: 'flex', alignItems: 'center', gap: '6px', fontSize: '12px', fontWeight: '600', color: '#6b7280', textTransform: 'uppercase', letterSpacing: '0.5px', marginBottom: '8px' }}>
+                <Link2 size={13} /> {t('billing.chain.title')}
+              </h3>
+              {note && <p style={{ fontSize: '14px', color: '#5b21b6', margin: '0 0 6px' }}>{note}</p>}
+              {invoice.cancelled_at && (
+                <p style={{ fontSize: '14px', color: '#991b1b', margin: '0 0 6px' }}>
+                  {t('billing.chain.cancelledOn').replace('{date}', formatDate(invoice.cancelled_at))}
+                  {invoice.cancellation_reason && ` – ${t('billing.chain.reason')}: ${invoice.cancellation_reason}`}
+                </p>
+              )}
+              {invoice.related?.map(ref => (
+                <div key={ref.id} style={{ display: 'flex', alignItems: 'center', gap: '10px', padding: '6px 0', borderTop: '1px solid #f3f4f6', fontSize: '13px' }}>
+                  <span style={{ fontFamily: 'monospace', color: '#374151' }}>{ref.invoice_number}</span>
+                  <span style={{ color: '#7c3aed', fontWeight: 600 }}>{documentTypeLabel(ref.document_type, t)}</span>
+                  <span style={{ flex: 1, color: '#9ca3af' }}>{formatDate(ref.generated_at)}</span>
+                  <span style={{
+                    padding: '2px 10px', borderRadius: '20px', fontSize: '11px', fontWeight: '700',
+                    backgroundColor: getStatusColor(ref.status).bg, color: getStatusColor(ref.status).color
+                  }}>
+                    {ref.status.toUpperCase()}
+                  </span>
+                  <span style={{ fontWeight: 600, color: '#1f2937', minWidth: '80px', textAlign: 'right' }}>
+                    {invoice.currency} {ref.total_amount.toFixed(2)}
+                  </span>
+                </div>
+              ))}
+            </div>
+          )}
+
           {/* Line Items */}
           <div style={{
             backgroundColor: 'white', borderRadius: '12px', border: '1px solid #e5e7eb', overflow: 'hidden'
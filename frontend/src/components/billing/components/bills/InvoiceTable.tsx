@@ -2,7 +2,7 @@ import { useState } from 'react';
 import { Eye, Download, Trash2 } from 'lucide-react';
 import type { Invoice, User } from '../../../../types';
 import { useTranslation } from '../../../../i18n';
-import { formatDate, getStatusColor, paymentBadge } from '../../utils/billingUtils';
+import { formatDate, getStatusColor, paymentBadge, isDeletable, documentTypeLabel, chainNote } from '../../utils/billingUtils';
 import { api } from '../../../../api/client';
 import { notify } from '../../../../utils/toast';
 
@@ -131,6 +131,7 @@ export default function InvoiceTable({
             const statusColors = getStatusColor(invoice.status);
             const isArchived = !user?.is_active;
             const userName = user ? `${user.first_name} ${user.last_name}` : '-';
+            const note = chainNote(invoice, t);
 
             return (
               <tr
@@ -150,6 +151,14 @@ export default function InvoiceTable({
                   color: '#6b7280'
                 }}>
                   {invoice.invoice_number}
+                  {invoice.document_type && invoice.document_type !== 'invoice' && (
+                    <div style={{ fontFamily: 'inherit', fontSize: '11px', fontWeight: 600, color: '#7c3aed' }}>
+                      {documentTypeLabel(invoice.document_type, t)}
+                    </div>
+                  )}
+                  {note && (
+                    <div style={{ fontFamily: 'inherit', fontSize: '11px', color: '#7c3aed' }}>{note}</div>
+                  )}
                 </td>
                 <td style={{ padding: '14px 16px', fontWeight: '500', color: '#1f2937' }}>
                   {userName}
@@ -229,7 +238,7 @@ export default function InvoiceTable({
                     >
                       <Download size={14} />
                     </button>
-                    <button
+                    {isDeletable(invoice) && <button
                       onClick={() => onDelete(invoice.id)}
                       title={t('common.delete')}
                       style={{
@@ -242,7 +251,7 @@ export default function InvoiceTable({
                       onMouseLeave={(e) => { e.currentTarget.style.backgroundColor = 'rgba(239,68,68,0.08)'; }}
                     >
                       <Trash2 size={14} />
-                    </button>
+                    </button>}
                   </div>
                 </td>
               </tr>
@@ -1,5 +1,6 @@
 import { Building as BuildingIcon, Layers } from 'lucide-react';
 import type { Building } from '../../../../types';
+import type { BillingView } from './ViewSwitcher';
 import { useTranslation } from '../../../../i18n';
 
 interface BuildingSelectorProps {
@@ -7,7 +8,7 @@ interface BuildingSelectorProps {
   selectedBuildingId: number | null;
   onSelect: (id: number | null) => void;
   searchQuery: string;
-  currentView: 'invoices' | 'shared-meters' | 'custom-items';
+  currentView: BillingView;
 }
 
 export default function BuildingSelector({
@@ -1,9 +1,11 @@
-import { FileText, Settings, DollarSign } from 'lucide-react';
+import { FileText, Settings, DollarSign, BookOpen, Landmark } from 'lucide-react';
 import { useTranslation } from '../../../../i18n';
 
+export type BillingView = 'invoices' | 'shared-meters' | 'custom-items' | 'statements' | 'payments';
+
 interface ViewSwitcherProps {
-  currentView: 'invoices' | 'shared-meters' | 'custom-items';
-  onViewChange: (view: 'invoices' | 'shared-meters' | 'custom-items') => void;
+  currentView: BillingView;
+  onViewChange: (view: BillingView) => void;
 }
 
 export default function ViewSwitcher({ currentView, onViewChange }: ViewSwitcherProps) {
@@ -12,7 +14,9 @@ export default function ViewSwitcher({ currentView, onViewChange }: ViewSwitcher
   const views = [
     { id: 'invoices' as const, icon: FileText, label: t('billing.tabs.invoices') },
     { id: 'shared-meters' as const, icon: Settings, label: t('billing.tabs.sharedMeters') },
-    { id: 'custom-items' as const, icon: DollarSign, label: t('billing.tabs.customItems') }
+    { id: 'custom-items' as const, icon: DollarSign, label: t('billing.tabs.customItems') },
+    { id: 'statements' as const, icon: BookOpen, label: t('billing.tabs.statements') },
+    { id: 'payments' as const, icon: Landmark, label: t('billing.tabs.payments') }
   ];
 
   return (
@@ -36,7 +36,8 @@ export function useInvoiceOperations(onRefresh: () => void) {
       return true;
     } catch (err) {
       console.error('Failed to delete invoice:', err);
-      notify.error(t('billing.deleteFailed'));
+      // Issued invoices are refused with a hint to cancel them instead.
+      notify.error(err instanceof Error && err.message ? err.message : t('billing.deleteFailed'));
       return false;
     } finally {
       setLoading(false);
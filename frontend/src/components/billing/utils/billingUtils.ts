@@ -17,6 +17,10 @@ export const getStatusColor = (status: string): { bg: string; color: string } =>
       return { bg: '#f8d7da', color: '#721c24' };
     case 'archived':
       return { bg: '#e2e3e5', color: '#383d41' };
+    case 'cancelled':
+      return { bg: '#fee2e2', color: '#991b1b' };
+    case 'credited':
+      return { bg: '#ede9fe', color: '#5b21b6' };
     default:
       return { bg: '#e2e3e5', color: '#383d41' };
   }
@@ -37,6 +41,29 @@ export const paymentBadge = (
     : { label: 'UNPAID', bg: '#f3f4f6', color: '#6b7280' };
 };
 
+// Only drafts can be deleted; issued documents are cancelled by a credit note
+// instead.
+export const isDeletable = (invoice: Invoice): boolean => invoice.status === 'draft';
+
+export const documentTypeLabel = (type: string | undefined, t: (key: string) => string): string =>
+  t(`billing.documentType.${type || 'invoice'}`);
+
+// chainNote tells how a document relates to the one it refers to: the credit
+// note reversing or correcting it, a supplement, or the replacement invoice.
+export const chainNote = (
+  invoice: { document_type?: string; original_invoice_number?: string },
+  t: (key: string) => string
+): string | null => {
+  if (!invoice.original_invoice_number) return null;
+  const keys: Record<string, string> = {
+    credit_note: 'billing.chain.creditNoteFor',
+    supplementary: 'billing.chain.supplementTo',
+    invoice: 'billing.chain.replaces'
+  };
+  const key = keys[invoice.document_type || 'invoice'] ?? 'billing.chain.refersTo';
+  return t(key).replace('{number}', invoice.original_invoice_number);
+};
+
 export const organizeInvoicesByYear = (
   invoices: Invoice[]
 ): Record<string, Invoice[]> => {
@@ -400,6 +400,8 @@ export const de: Translations = {
   'portal.invalidCode': 'Ungültiger oder abgelaufener Zugangscode',
   'portal.logout': 'Abmelden',
   'portal.tabInvoices': 'Rechnungen',
+  'portal.tabStatement': 'Kontoauszug',
+  'portal.cancelled': 'Storniert',
   'portal.tabCharging': 'Laden',
   'portal.tabConsumption': 'Verbrauch',
   'portal.tabLive': 'Live',
@@ -407,6 +409,7 @@ export const de: Translations = {
   'portal.noCharging': 'Noch keine Ladesitzungen.',
   'portal.comingSoon': 'Demnächst verfügbar.',
   'portal.downloadFailed': 'Download fehlgeschlagen',
+  'portal.statementHint': 'Beträge in {currency}. Ein positiver Saldo ist noch offen.',
   'portal.admin.title': 'Portalzugang',
   'portal.admin.desc': 'Teilen Sie diesen privaten Link mit dem Mieter, damit er seine Rechnungen und Ladevorgänge im Self-Service-Portal einsehen kann.',
   'portal.admin.generate': 'Zugangslink erstellen',
@@ -2026,16 +2029,70 @@ export const de: Translations = {
   'billing.openPdf': 'PDF öffnen',
   'billing.archived': 'Archiviert',
   'billing.archiveSection': 'Archiv (Archivierte Benutzer)',
+  'billing.documentType.invoice': 'Rechnung',
+  'billing.documentType.credit_note': 'Gutschrift',
+  'billing.documentType.supplementary': 'Nachtragsrechnung',
+  'billing.documentType.advance': 'Akontorechnung',
+  'billing.documentType.settlement': 'Schlussabrechnung',
+  'billing.chain.title': 'Verknüpfte Dokumente',
+  'billing.chain.creditNoteFor': 'Gutschrift zu {number}',
+  'billing.chain.supplementTo': 'Nachtrag zu {number}',
+  'billing.chain.replaces': 'Ersetzt {number}',
+  'billing.chain.refersTo': 'Bezug: {number}',
+  'billing.chain.cancelledOn': 'Storniert am {date}',
+  'billing.chain.reason': 'Grund',
 
   // Tabs
   'billing.tabs.invoices': 'Rechnungen',
   'billing.tabs.sharedMeters': 'Gemeinsame Zähler',
   'billing.tabs.customItems': 'Eigene Positionen',
+  'billing.tabs.statements': 'Kontoauszüge',
+  'billing.tabs.payments': 'Zahlungen',
   'billing.createBill': 'Rechnung erstellen',
   'billing.allBuildingsDesc': 'Alle Rechnungen',
   'billing.allBuildingsDescSharedMeters': 'Alle Zählerkonfigurationen',
   'billing.allBuildingsDescCustomItems': 'Alle benutzerdefinierten Posten',
 
+  // Account statements
+  'statements.tenant': 'Mieter',
+  'statements.allTenants': 'Alle Mieter der Liegenschaft',
+  'statements.chooseTenant': 'Mieter wählen',
+  'statements.from': 'Von',
+  'statements.to': 'Bis',
+  'statements.refresh': 'Neu laden',
+  'statements.downloadPdf': 'PDF herunterladen',
+  'statements.selectHint': 'Wählen Sie eine Liegenschaft oder einen Mieter, um Kontoauszüge anzuzeigen.',
+  'statements.empty': 'Keine Buchungen in diesem Zeitraum.',
+  'statements.loadFailed': 'Kontoauszüge konnten nicht geladen werden',
+  'statements.downloadFailed': 'Kontoauszüge konnten nicht heruntergeladen werden',
+  'statements.date': 'Datum',
+  'statements.reference': 'Referenz',
+  'statements.description': 'Beschreibung',
+  'statements.debit': 'Soll',
+  'statements.credit': 'Haben',
+  'statements.balance': 'Saldo',
+  'statements.opening': 'Anfangssaldo',
+  'statements.closing': 'Schlusssaldo',
+
+  // Bank payments (camt import and review queue)
+  'bankPayments.title': 'Nicht zugeordnete Zahlungen',
+  'bankPayments.description': 'Gutschriften aus importierten Kontoauszügen, die keiner Rechnung zugeordnet werden konnten.',
+  'bankPayments.import': 'Bankauszug importieren',
+  'bankPayments.importing': 'Importiere…',
+  'bankPayments.imported': '{count} Buchung(en) importiert: {matched} zugeordnet, {unmatched} zu prüfen, {duplicates} bereits importiert',
+  'bankPayments.importFailed': 'Bankauszug konnte nicht importiert werden',
+  'bankPayments.loadFailed': 'Bankbuchungen konnten nicht geladen werden',
+  'bankPayments.empty': 'Keine nicht zugeordneten Zahlungen.',
+  'bankPayments.unknownDebtor': 'Unbekannter Zahler',
+  'bankPayments.noOpenInvoices': 'Keine offenen Rechnungen',
+  'bankPayments.assign': 'Zuordnen',
+  'bankPayments.assigned': 'Zahlung auf der Rechnung verbucht',
+  'bankPayments.assignFailed': 'Zahlung konnte nicht zugeordnet werden',
+  'bankPayments.ignore': 'Ignorieren',
+  'bankPayments.ignorePrompt': 'Diese Zahlung ignorieren? Optional den Grund angeben (z. B. Rückerstattung, nicht abrechnungsrelevant):',
+  'bankPayments.ignored': 'Zahlung aus der Prüfliste entfernt',
+  'bankPayments.ignoreFailed': 'Zahlung konnte nicht ignoriert werden',
+
   // Instructions
   'billing.instructions.title': 'Anleitung zur Abrechnung',
   'billing.instructions.whatIsBilling': 'Was ist Abrechnung?',
@@ -2540,6 +2597,18 @@ export const de: Translations = {
   'autoBilling.review.billingScope': 'Abrechnungsumfang',
   'autoBilling.autoEmail.toggle': 'PDF-Rechnung automatisch per E-Mail an den Empfänger senden',
   'autoBilling.autoEmail.description': 'SMTP muss in den E-Mail-Einstellungen konfiguriert sein und der Empfänger benötigt eine E-Mail-Adresse.',
+  'autoBilling.requireApproval.toggle': 'Rechnungen vor der Ausstellung zur Freigabe zurückhalten',
+  'autoBilling.requireApproval.description': 'Erstellte Rechnungen warten als Entwürfe in der Prüfliste, mit Hinweisen auf auffälligen Verbrauch. Erst nach der Freigabe werden sie nummeriert, ausgestellt und versendet.',
+  'autoBilling.reviewQueue.title': 'Rechnungen zur Freigabe',
+  'autoBilling.reviewQueue.description': 'Entwürfe aus Konfigurationen mit Freigabepflicht. Die Freigabe stellt sie aus und versendet sie, falls aktiviert, per E-Mail.',
+  'autoBilling.reviewQueue.selectAll': 'Alle auswählen',
+  'autoBilling.reviewQueue.deselectAll': 'Auswahl aufheben',
+  'autoBilling.reviewQueue.approve': 'Freigeben',
+  'autoBilling.reviewQueue.approveSelected': 'Auswahl freigeben',
+  'autoBilling.reviewQueue.approveConfirm': '{count} Rechnung(en) freigeben und ausstellen? Sie werden nummeriert und können danach nicht mehr gelöscht, nur noch storniert werden.',
+  'autoBilling.reviewQueue.approved': '{count} Rechnung(en) freigegeben, {emails} E-Mail(s) versendet',
+  'autoBilling.reviewQueue.approveFailed': 'Rechnungen konnten nicht freigegeben werden',
+  'autoBilling.reviewQueue.loadFailed': 'Prüfliste konnte nicht geladen werden',
   'autoBilling.editLayout': 'Layout',
   'autoBilling.testRun': 'Jetzt testen',
   'autoBilling.testRunHelp': 'Konfiguration sofort ausführen, um Rechnung und E-Mail-Versand zu prüfen. Der nächste geplante Lauf wird nicht verändert.',
@@ -2575,6 +2644,8 @@ export const de: Translations = {
   'billLayout.footerField': 'Schlusstext (optional)',
   'billLayout.footerPlaceholder': 'Wird unterhalb des Totals angezeigt.',
   'billLayout.footerHelp': 'Zahlungsbedingungen, Kontakt, Dankesnotiz. Klartext, Zeilenumbrüche bleiben erhalten.',
+  'billLayout.chartField': 'Verbrauchsgrafik anzeigen',
+  'billLayout.chartHelp': 'Monatlicher Verbrauch der letzten 12 Monate (Solar, Batterie, Netz) mit Vorjahr und Durchschnitt pro Wohnung.',
   'billLayout.preview': 'Vorschau',
   'billLayout.previewTitleFallback': 'Rechnung',
   'billLayout.previewPlaceholder': '— Positionen erscheinen hier —',
@@ -400,6 +400,8 @@ export const en: Translations = {
   'portal.invalidCode': 'Invalid or expired access code',
   'portal.logout': 'Log out',
   'portal.tabInvoices': 'Invoices',
+  'portal.tabStatement': 'Statement',
+  'portal.cancelled': 'Cancelled',
   'portal.tabCharging': 'Charging',
   'portal.tabConsumption': 'Consumption',
   'portal.tabLive': 'Live',
@@ -407,6 +409,7 @@ export const en: Translations = {
   'portal.noCharging': 'No charging sessions yet.',
   'portal.comingSoon': 'Coming soon.',
   'portal.downloadFailed': 'Download failed',
+  'portal.statementHint': 'Amounts in {currency}. A positive balance is still owed.',
   'portal.admin.title': 'Portal access',
   'portal.admin.desc': 'Share this private link with the tenant so they can view their invoices and charging in the self-service portal.',
   'portal.admin.generate': 'Generate access link',
@@ -2025,16 +2028,70 @@ export const en: Translations = {
   'billing.openPdf': 'Open PDF',
   'billing.archived': 'Archived',
   'billing.archiveSection': 'Archive (Archived Users)',
+  'billing.documentType.invoice': 'Invoice',
+  'billing.documentType.credit_note': 'Credit note',
+  'billing.documentType.supplementary': 'Supplementary invoice',
+  'billing.documentType.advance': 'Advance invoice',
+  'billing.documentType.settlement': 'Final settlement',
+  'billing.chain.title': 'Related documents',
+  'billing.chain.creditNoteFor': 'Credit note for {number}',
+  'billing.chain.supplementTo': 'Supplement to {number}',
+  'billing.chain.replaces': 'Replaces {number}',
+  'billing.chain.refersTo': 'Refers to {number}',
+  'billing.chain.cancelledOn': 'Cancelled on {date}',
+  'billing.chain.reason': 'Reason',
 
   // Tabs
   'billing.tabs.invoices': 'Invoices',
   'billing.tabs.sharedMeters': 'Shared Meters',
   'billing.tabs.customItems': 'Custom Items',
+  'billing.tabs.statements': 'Statements',
+  'billing.tabs.payments': 'Payments',
   'billing.createBill': 'Create Bill',
   'billing.allBuildingsDesc': 'All invoices',
   'billing.allBuildingsDescSharedMeters': 'All shared meter',
   'billing.allBuildingsDescCustomItems': 'All custom line items',
 
+  // Account statements
+  'statements.tenant': 'Tenant',
+  'statements.allTenants': 'All tenants of the building',
+  'statements.chooseTenant': 'Choose a tenant',
+  'statements.from': 'From',
+  'statements.to': 'To',
+  'statements.refresh': 'Reload',
+  'statements.downloadPdf': 'Download PDF',
+  'statements.selectHint': 'Select a building or a tenant to show account statements.',
+  'statements.empty': 'No entries in this period.',
+  'statements.loadFailed': 'Failed to load account statements',
+  'statements.downloadFailed': 'Failed to download account statements',
+  'statements.date': 'Date',
+  'statements.reference': 'Reference',
+  'statements.description': 'Description',
+  'statements.debit': 'Debit',
+  'statements.credit': 'Credit',
+  'statements.balance': 'Balance',
+  'statements.opening': 'Opening balance',
+  'statements.closing': 'Closing balance',
+
+  // Bank payments (camt import and review queue)
+  'bankPayments.title': 'Unmatched payments',
+  'bankPayments.description': 'Credits from imported bank statements that could not be matched to an invoice.',
+  'bankPayments.import': 'Import bank statement',
+  'bankPayments.importing': 'Importing…',
+  'bankPayments.imported': '{count} transaction(s) imported: {matched} matched, {unmatched} to review, {duplicates} already imported',
+  'bankPayments.importFailed': 'Failed to import bank statement',
+  'bankPayments.loadFailed': 'Failed to load bank transactions',
+  'bankPayments.empty': 'No unmatched payments.',
+  'bankPayments.unknownDebtor': 'Unknown payer',
+  'bankPayments.noOpenInvoices': 'No open invoices',
+  'bankPayments.assign': 'Assign',
+  'bankPayments.assigned': 'Payment booked on the invoice',
+  'bankPayments.assignFailed': 'Failed to assign payment',
+  'bankPayments.ignore': 'Ignore',
+  'bankPayments.ignorePrompt': 'Ignore this payment? Optionally note why (e.g. refund, not billing related):',
+  'bankPayments.ignored': 'Payment removed from the review queue',
+  'bankPayments.ignoreFailed': 'Failed to ignore payment',
+
   // Instructions
   'billing.instructions.title': 'How to Use Billing',
   'billing.instructions.whatIsBilling': 'What is Billing?',
@@ -2538,6 +2595,18 @@ export const en: Translations = {
   'autoBilling.review.billingScope': 'Billing scope',
   'autoBilling.autoEmail.toggle': 'Email the PDF invoice to the recipient automatically',
   'autoBilling.autoEmail.description': 'Requires SMTP to be configured in Email Settings and the recipient user to have an email address.',
+  'autoBilling.requireApproval.toggle': 'Hold invoices for approval before they are issued',
+  'autoBilling.requireApproval.description': 'Generated invoices wait as drafts in the review queue, with warnings for unusual consumption. They are numbered, issued and e-mailed only once approved.',
+  'autoBilling.reviewQueue.title': 'Invoices awaiting approval',
+  'autoBilling.reviewQueue.description': 'Drafts from configurations that require approval. Approving issues them and, if enabled, e-mails them.',
+  'autoBilling.reviewQueue.selectAll': 'Select all',
+  'autoBilling.reviewQueue.deselectAll': 'Deselect all',
+  'autoBilling.reviewQueue.approve': 'Approve',
+  'autoBilling.reviewQueue.approveSelected': 'Approve selected',
+  'autoBilling.reviewQueue.approveConfirm': 'Approve and issue {count} invoice(s)? They are numbered and can no longer be deleted, only cancelled.',
+  'autoBilling.reviewQueue.approved': '{count} invoice(s) approved, {emails} e-mail(s) sent',
+  'autoBilling.reviewQueue.approveFailed': 'Failed to approve invoices',
+  'autoBilling.reviewQueue.loadFailed': 'Failed to load the review queue',
   'autoBilling.editLayout': 'Layout',
   'autoBilling.testRun': 'Test now',
   'autoBilling.testRunHelp': 'Run this configuration immediately to verify the bill and email delivery. Does not change the next scheduled run.',
@@ -2573,6 +2642,8 @@ export const en: Translations = {
   'billLayout.footerField': 'Footer text (optional)',
   'billLayout.footerPlaceholder': 'Shown below the totals.',
   'billLayout.footerHelp': 'Payment terms, contact, thank-you note. Plain text; line breaks preserved.',
+  'billLayout.chartField': 'Show consumption chart',
+  'billLayout.chartHelp': 'Monthly consumption of the last 12 months (solar, battery, grid) with the previous year and the average per unit.',
   'billLayout.preview': 'Preview',
   'billLayout.previewTitleFallback': 'Invoice',
   'billLayout.previewPlaceholder': '— line items appear here —',
@@ -272,6 +272,44 @@ export interface Invoice {
   items?: InvoiceItem[];
   user?: User;
   generated_at: string;
+  document_type?: 'invoice' | 'credit_note' | 'supplementary' | 'advance' | 'settlement';
+  // Cancellation chain: a credit note reverses original_invoice_id, a
+  // replacement invoice corrects it; related lists the linked documents.
+  original_invoice_id?: number;
+  original_invoice_number?: string;
+  cancelled_at?: string;
+  cancellation_reason?: string;
+  related?: InvoiceRef[];
+  correction?: boolean;
+  // Approval workflow: drafts of an auto-billing config wait for approval
+  review_status?: 'draft' | 'approved' | 'sent';
+  review_warnings?: ReviewWarning[];
+  auto_billing_config_id?: number;
+}
+
+// A compact pointer to another document of an invoice's cancellation chain.
+export interface InvoiceRef {
+  id: number;
+  invoice_number: string;
+  document_type: string;
+  status: string;
+  total_amount: number;
+  generated_at: string;
+}
+
+export interface ReviewWarning {
+  code: 'zero_consumption' | 'consumption_jump' | 'missing_data';
+  message: string;
+}
+
+// Outcome of approving drafts: issued, rendered and (optionally) e-mailed.
+export interface ApprovalResult {
+  approved: number;
+  invoice_ids: number[];
+  pdfs_generated: number;
+  emails_sent: number;
+  emails_failed: number;
+  warnings: string[] | null;
 }
 
 export interface InvoiceItem {
@@ -284,6 +322,86 @@ export interface InvoiceItem {
   item_type: string;
 }
 
+// An archived invoice: its copy's SHA-256, chained to the entry before.
+export interface InvoiceArchiveEntry {
+  id: number;
+  invoice_id: number;
+  invoice_number: string;
+  file_name: string;
+  format: 'pdfa-3b';
+  sha256: string;
+  previous_hash: string;
+  chain_hash: string;
+  archived_at: string;
+}
+
+// A tenant's account over a period: documents and fees as debits, payments
+// and credit notes as credits, with the running balance after each line.
+export interface AccountStatement {
+  user_id: number;
+  user_name: string;
+  address?: string[];
+  language: string;
+  building_id: number;
+  currency: string;
+  from: string;
+  to: string;
+  opening_balance: number;
+  total_debit: number;
+  total_credit: number;
+  closing_balance: number;
+  lines: AccountStatementLine[];
+}
+
+export interface AccountStatementLine {
+  date: string;
+  kind: string; // a document type, reminder_fee, payment or payout
+  invoice_id?: number;
+  reference: string;
+  description: string;
+  debit: number;
+  credit: number;
+  balance: number;
+}
+
+// An imported camt.053/054 statement file and how its credits were matched.
+export interface BankImport {
+  id: number;
+  file_name: string;
+  message_id: string;
+  format: string;
+  account_iban: string;
+  transactions: number;
+  duplicates: number;
+  matched: number;
+  unmatched: number;
+  imported_at: string;
+}
+
+// A booked credit or debit from a bank statement; unmatched credits wait for
+// an admin to assign them to an invoice or ignore them.
+export interface BankTransaction {
+  id: number;
+  import_id: number;
+  booking_date: string;
+  value_date: string;
+  amount: number;
+  currency: string;
+  credit_debit: 'CRDT' | 'DBIT';
+  reference: string;
+  reference_type: string;
+  end_to_end_id: string;
+  debtor_name: string;
+  debtor_iban: string;
+  remittance_info: string;
+  status: 'matched' | 'unmatched' | 'assigned' | 'ignored';
+  invoice_id?: number;
+  invoice_number?: string;
+  match_method?: string;
+  review_note?: string;
+  matched_at?: string;
+}
+
 export type SharedMeterPricingMode = 'single' | 'solar_grid_custom' | 'solar_grid_pricing';
 
 export interface SharedMeterConfig {
@@ -299,6 +299,16 @@ else
     fi
 fi
 
+# Install Ghostscript for PDF/A copies in the invoice archive
+print_info "Installing Ghostscript for PDF/A archiving..."
+if command -v gs &> /dev/null; then
+    print_success "Ghostscript is already installed: $(gs --version)"
+elif apt-get install -y -qq ghostscript 2>/dev/null; then
+    print_success "Ghostscript installed successfully"
+else
+    print_warning "Ghostscript installation failed - archived invoices are kept as plain PDF"
+fi
+
 # Install Mosquitto MQTT Broker
 print_header "MQTT Broker Setup"
 